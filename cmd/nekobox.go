@@ -21,6 +21,12 @@ func main() {
 	app.Commands = []*cli.Command{
 		cmd.Web,
 		cmd.Censor,
+		cmd.Migrate,
+		cmd.Sitemap,
+		cmd.Admin,
+		cmd.Push,
+		cmd.Export,
+		cmd.Import,
 	}
 	if err := app.Run(os.Args); err != nil {
 		logrus.WithError(err).Fatal("Failed to start application")
@@ -0,0 +1,248 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package activitypub publishes the public answers of the boxes to the fediverse. Each box is an
+// ActivityPub actor which can be followed by the Mastodon users, the answered questions are
+// delivered to the followers as Notes.
+package activitypub
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NekoWheel/NekoBox/internal/conf"
+	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/markdown"
+	"github.com/NekoWheel/NekoBox/internal/security/censor"
+)
+
+const (
+	// ContentType is the media type of the ActivityPub objects.
+	ContentType = "application/activity+json"
+	// PublicAddress addresses the activity to everyone, the public answers are listed on the public timelines.
+	PublicAddress = "https://www.w3.org/ns/activitystreams#Public"
+
+	// ActivityStreamsContext is the JSON-LD context of the top-level objects.
+	ActivityStreamsContext = "https://www.w3.org/ns/activitystreams"
+	securityContext        = "https://w3id.org/security/v1"
+)
+
+// Enabled returns whether the federation is enabled by the server operator.
+func Enabled() bool {
+	return conf.Federation.Enabled
+}
+
+// Host returns the host of the external URL, which is the server part of the boxes' fediverse addresses.
+func Host() string {
+	u, err := url.Parse(conf.App.ExternalURL)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+// ActorURL returns the ID of the user's actor. The actors are identified by the user IDs rather
+// than the domains, so the followers are kept after the user changes the domain.
+func ActorURL(userID uint) string {
+	return fmt.Sprintf("%s/ap/users/%d", conf.App.ExternalURL, userID)
+}
+
+// SharedInboxURL returns the inbox shared by all the actors of the server.
+func SharedInboxURL() string {
+	return conf.App.ExternalURL + "/ap/inbox"
+}
+
+// NoteURL returns the ID of the Note of the answered question.
+func NoteURL(userID, questionID uint) string {
+	return fmt.Sprintf("%s/notes/%d", ActorURL(userID), questionID)
+}
+
+// userIDFromActorURL returns the user ID of the local actor, it is zero if the URL is not a local actor.
+func userIDFromActorURL(actorURL string) uint {
+	prefix := conf.App.ExternalURL + "/ap/users/"
+	if !strings.HasPrefix(actorURL, prefix) {
+		return 0
+	}
+	userID, err := strconv.ParseUint(strings.TrimPrefix(actorURL, prefix), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return uint(userID)
+}
+
+func profileURL(user *db.User) string {
+	return fmt.Sprintf("%s/_/%s", conf.App.ExternalURL, user.Domain)
+}
+
+type PublicKey struct {
+	ID           string `json:"id"`
+	Owner        string `json:"owner"`
+	PublicKeyPEM string `json:"publicKeyPem"`
+}
+
+type Image struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+type Endpoints struct {
+	SharedInbox string `json:"sharedInbox,omitempty"`
+}
+
+// Actor is the ActivityPub actor, which is either the local box or the remote follower.
+type Actor struct {
+	Context                   interface{} `json:"@context,omitempty"`
+	ID                        string      `json:"id"`
+	Type                      string      `json:"type"`
+	PreferredUsername         string      `json:"preferredUsername,omitempty"`
+	Name                      string      `json:"name,omitempty"`
+	Summary                   string      `json:"summary,omitempty"`
+	URL                       string      `json:"url,omitempty"`
+	Inbox                     string      `json:"inbox"`
+	Outbox                    string      `json:"outbox,omitempty"`
+	Followers                 string      `json:"followers,omitempty"`
+	Endpoints                 *Endpoints  `json:"endpoints,omitempty"`
+	Icon                      *Image      `json:"icon,omitempty"`
+	Image                     *Image      `json:"image,omitempty"`
+	ManuallyApprovesFollowers bool        `json:"manuallyApprovesFollowers"`
+	Published                 string      `json:"published,omitempty"`
+	PublicKey                 *PublicKey  `json:"publicKey,omitempty"`
+}
+
+// NewActor returns the actor of the user's box.
+func NewActor(user *db.User, publicKeyPEM string) *Actor {
+	actorURL := ActorURL(user.ID)
+	actor := &Actor{
+		Context:           []string{ActivityStreamsContext, securityContext},
+		ID:                actorURL,
+		Type:              "Person",
+		PreferredUsername: user.Domain,
+		Name:              user.Name,
+		Summary:           formatHTML(user.Intro),
+		URL:               profileURL(user),
+		Inbox:             actorURL + "/inbox",
+		Outbox:            actorURL + "/outbox",
+		Followers:         actorURL + "/followers",
+		Endpoints:         &Endpoints{SharedInbox: SharedInboxURL()},
+		Published:         user.CreatedAt.UTC().Format(time.RFC3339),
+		PublicKey: &PublicKey{
+			ID:           actorURL + "#main-key",
+			Owner:        actorURL,
+			PublicKeyPEM: publicKeyPEM,
+		},
+	}
+	if user.Avatar != "" {
+		actor.Icon = &Image{Type: "Image", URL: user.Avatar}
+	}
+	if user.Background != "" {
+		actor.Image = &Image{Type: "Image", URL: user.Background}
+	}
+	return actor
+}
+
+// Note is the ActivityPub object of the answered question.
+type Note struct {
+	Context      interface{} `json:"@context,omitempty"`
+	ID           string      `json:"id"`
+	Type         string      `json:"type"`
+	AttributedTo string      `json:"attributedTo"`
+	Content      string      `json:"content"`
+	URL          string      `json:"url"`
+	Published    string      `json:"published"`
+	Updated      string      `json:"updated,omitempty"`
+	To           []string    `json:"to"`
+	Cc           []string    `json:"cc"`
+}
+
+// NewNote returns the Note of the answered question, the question is quoted before the answer.
+func NewNote(user *db.User, question *db.Question) *Note {
+	published := question.UpdatedAt
+	if question.AnsweredAt != nil {
+		published = *question.AnsweredAt
+	}
+
+	answer := formatHTML(question.Answer)
+	if user.BoxSettings.EnableMarkdown {
+		answer = string(markdown.Render(question.Answer))
+	}
+
+	note := &Note{
+		ID:           NoteURL(user.ID, question.ID),
+		Type:         "Note",
+		AttributedTo: ActorURL(user.ID),
+		Content:      fmt.Sprintf("<p><strong>%s</strong></p>%s", html.EscapeString(censor.QuestionContent(user, question)), answer),
+		URL:          fmt.Sprintf("%s/%d", profileURL(user), question.ID),
+		Published:    published.UTC().Format(time.RFC3339),
+		To:           []string{PublicAddress},
+		Cc:           []string{ActorURL(user.ID) + "/followers"},
+	}
+	if question.AnswerUpdatedAt != nil {
+		note.Updated = question.AnswerUpdatedAt.UTC().Format(time.RFC3339)
+	}
+	return note
+}
+
+// formatHTML returns the HTML paragraph of the plain text, it is empty if the text is empty.
+func formatHTML(text string) string {
+	if text == "" {
+		return ""
+	}
+	return "<p>" + strings.ReplaceAll(html.EscapeString(text), "\n", "<br>") + "</p>"
+}
+
+// Activity is the ActivityPub activity, the Object is the embedded object or the ID of it.
+type Activity struct {
+	Context   interface{} `json:"@context,omitempty"`
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	Actor     string      `json:"actor"`
+	Published string      `json:"published,omitempty"`
+	To        []string    `json:"to,omitempty"`
+	Cc        []string    `json:"cc,omitempty"`
+	Object    interface{} `json:"object"`
+}
+
+// NewCreate returns the Create activity of the answered question, which is listed in the outbox.
+func NewCreate(user *db.User, question *db.Question) *Activity {
+	note := NewNote(user, question)
+	return &Activity{
+		ID:        note.ID + "/activity",
+		Type:      "Create",
+		Actor:     note.AttributedTo,
+		Published: note.Published,
+		To:        note.To,
+		Cc:        note.Cc,
+		Object:    note,
+	}
+}
+
+// IsPublished returns whether the question is published to the fediverse, only the public answers
+// which can be seen on the box page are published.
+func IsPublished(question *db.Question) bool {
+	return question.Answer != "" && question.HiddenAt == nil &&
+		(question.Visibility == db.QuestionVisibilityPublic || question.Visibility == "")
+}
+
+// OrderedCollection is the collection of the outbox and the followers, the items are
+// listed in the pages which are linked by First.
+type OrderedCollection struct {
+	Context    interface{} `json:"@context,omitempty"`
+	ID         string      `json:"id"`
+	Type       string      `json:"type"`
+	TotalItems int64       `json:"totalItems"`
+	First      string      `json:"first,omitempty"`
+}
+
+type OrderedCollectionPage struct {
+	Context      interface{}   `json:"@context,omitempty"`
+	ID           string        `json:"id"`
+	Type         string        `json:"type"`
+	PartOf       string        `json:"partOf"`
+	Next         string        `json:"next,omitempty"`
+	OrderedItems []interface{} `json:"orderedItems"`
+}
@@ -0,0 +1,274 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package activitypub
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/security/ssrf"
+)
+
+const (
+	workers        = 2
+	queueSize      = 1024
+	maxAttempts    = 5
+	baseBackoff    = time.Minute
+	requestTimeout = 10 * time.Second
+	// maxResponseSize limits the remote objects fetched from the other servers.
+	maxResponseSize = 1 << 20
+
+	userAgent = "NekoBox-ActivityPub"
+)
+
+type delivery struct {
+	// UserID is the local user who sends the activity, the request is signed with the user's key.
+	UserID uint
+	Inbox  string
+	Body   []byte
+
+	attempt int
+}
+
+var (
+	deliveries = make(chan delivery, queueSize)
+
+	// The inboxes and the actors are provided by the remote servers, which must not point to the internal network.
+	client = &http.Client{
+		Timeout: requestTimeout,
+		Transport: &http.Transport{
+			DialContext: (&net.Dialer{
+				Timeout: requestTimeout,
+				Control: ssrf.DenyPrivateAddress,
+			}).DialContext,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+)
+
+// PublishAnswer delivers the Create activity of the newly answered question to the followers of the box.
+func PublishAnswer(ctx context.Context, pageUser *db.User, question *db.Question) {
+	if !Enabled() || !IsPublished(question) {
+		return
+	}
+	broadcast(ctx, pageUser, NewCreate(pageUser, question))
+}
+
+// UpdateAnswer delivers the Update activity of the edited answer to the followers of the box.
+func UpdateAnswer(ctx context.Context, pageUser *db.User, question *db.Question) {
+	if !Enabled() || !IsPublished(question) {
+		return
+	}
+
+	note := NewNote(pageUser, question)
+	broadcast(ctx, pageUser, &Activity{
+		ID:     note.ID + "/update/" + time.Now().UTC().Format("20060102150405"),
+		Type:   "Update",
+		Actor:  note.AttributedTo,
+		To:     note.To,
+		Cc:     note.Cc,
+		Object: note,
+	})
+}
+
+// DeleteAnswer delivers the Delete activity of the deleted question, so the followers' servers
+// remove the Note from the timelines.
+func DeleteAnswer(ctx context.Context, pageUser *db.User, question *db.Question) {
+	if !Enabled() || !IsPublished(question) {
+		return
+	}
+
+	noteURL := NoteURL(pageUser.ID, question.ID)
+	broadcast(ctx, pageUser, &Activity{
+		ID:    noteURL + "/delete",
+		Type:  "Delete",
+		Actor: ActorURL(pageUser.ID),
+		To:    []string{PublicAddress},
+		Object: map[string]string{
+			"id":   noteURL,
+			"type": "Tombstone",
+		},
+	})
+}
+
+// broadcast sends the activity to all the followers of the user, the activity is sent once
+// to each shared inbox. The activities are delivered by the workers in the background.
+func broadcast(ctx context.Context, user *db.User, activity *Activity) {
+	followers, err := db.ActivityPubFollowers.GetByUserID(ctx, user.ID)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("Failed to get ActivityPub followers by user ID")
+		return
+	}
+	if len(followers) == 0 {
+		return
+	}
+
+	activity.Context = ActivityStreamsContext
+	body, err := json.Marshal(activity)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("Failed to marshal ActivityPub activity")
+		return
+	}
+
+	inboxes := make(map[string]struct{}, len(followers))
+	for _, follower := range followers {
+		inbox := follower.SharedInbox
+		if inbox == "" {
+			inbox = follower.Inbox
+		}
+		if _, ok := inboxes[inbox]; ok {
+			continue
+		}
+		inboxes[inbox] = struct{}{}
+
+		enqueue(ctx, delivery{UserID: user.ID, Inbox: inbox, Body: body})
+	}
+}
+
+func enqueue(ctx context.Context, d delivery) {
+	select {
+	case deliveries <- d:
+	default:
+		logrus.WithContext(ctx).WithField("inbox", d.Inbox).Warn("ActivityPub delivery queue is full, drop the delivery")
+	}
+}
+
+// Start starts the ActivityPub delivery workers, the workers stop when the context is done.
+func Start(ctx context.Context) {
+	for i := 0; i < workers; i++ {
+		go work(ctx)
+	}
+}
+
+func work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case d := <-deliveries:
+			deliver(ctx, d)
+		}
+	}
+}
+
+func deliver(ctx context.Context, d delivery) {
+	logger := logrus.WithContext(ctx).
+		WithField("user_id", d.UserID).
+		WithField("inbox", d.Inbox).
+		WithField("attempt", d.attempt+1)
+
+	err := send(ctx, d)
+	if err == nil {
+		return
+	}
+
+	d.attempt++
+	if d.attempt >= maxAttempts {
+		logger.WithError(err).Error("Failed to deliver ActivityPub activity, give up")
+		return
+	}
+
+	backoff := baseBackoff << (d.attempt - 1)
+	logger.WithError(err).WithField("backoff", backoff.String()).Warn("Failed to deliver ActivityPub activity, retry later")
+
+	time.AfterFunc(backoff, func() {
+		select {
+		case <-ctx.Done():
+		case deliveries <- d:
+		}
+	})
+}
+
+func send(ctx context.Context, d delivery) error {
+	signer, err := newSigner(ctx, d.UserID)
+	if err != nil {
+		return errors.Wrap(err, "new signer")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.Inbox, bytes.NewReader(d.Body))
+	if err != nil {
+		return errors.Wrap(err, "new request")
+	}
+	req.Header.Set("Content-Type", ContentType)
+	req.Header.Set("User-Agent", userAgent)
+	if err := signer.sign(req, d.Body); err != nil {
+		return errors.Wrap(err, "sign request")
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "do request")
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// fetchActor fetches the remote actor, the request is signed by the local actor if the signer is given,
+// which is required by the servers in the secure mode.
+func fetchActor(ctx context.Context, actorID string, signer *signer) (*Actor, error) {
+	if !isRemoteURL(actorID) {
+		return nil, errors.Errorf("invalid actor ID %q", actorID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, actorID, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "new request")
+	}
+	req.Header.Set("Accept", ContentType)
+	req.Header.Set("User-Agent", userAgent)
+	if signer != nil {
+		if err := signer.sign(req, nil); err != nil {
+			return nil, errors.Wrap(err, "sign request")
+		}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "do request")
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	var actor Actor
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&actor); err != nil {
+		return nil, errors.Wrap(err, "decode actor")
+	}
+	// The actor must be served by its own server, so that the other server can't forge it.
+	if actor.ID != actorID {
+		return nil, errors.Errorf("actor ID mismatch %q", actor.ID)
+	}
+	if !isRemoteURL(actor.Inbox) || (actor.Endpoints != nil && actor.Endpoints.SharedInbox != "" && !isRemoteURL(actor.Endpoints.SharedInbox)) {
+		return nil, errors.New("invalid actor inbox")
+	}
+	return &actor, nil
+}
+
+// isRemoteURL reports whether the URL is a valid HTTPS URL of the remote server.
+func isRemoteURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	return u.Scheme == "https" && u.Host != "" && u.Host != Host()
+}
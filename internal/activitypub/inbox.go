@@ -0,0 +1,171 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package activitypub
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"github.com/thanhpk/randstr"
+
+	"github.com/NekoWheel/NekoBox/internal/db"
+)
+
+var ErrInvalidActivity = errors.New("invalid activity")
+
+// incomingActivity is the activity posted to the inbox, only the fields used by the handlers are parsed.
+type incomingActivity struct {
+	ID     string          `json:"id"`
+	Type   string          `json:"type"`
+	Actor  string          `json:"actor"`
+	Object json.RawMessage `json:"object"`
+}
+
+// Receive handles the activity posted to the inbox of the local actor, inboxUserID is zero
+// for the shared inbox. Only the Follow and the Undo Follow activities are handled, the other
+// activities are ignored without verifying the signatures.
+func Receive(ctx context.Context, req *http.Request, body []byte, inboxUserID uint) error {
+	var activity incomingActivity
+	if err := json.Unmarshal(body, &activity); err != nil {
+		return errors.Wrapf(ErrInvalidActivity, "unmarshal activity: %v", err)
+	}
+	if activity.Actor == "" {
+		return errors.Wrap(ErrInvalidActivity, "missing actor")
+	}
+
+	switch activity.Type {
+	case "Follow":
+		return receiveFollow(ctx, req, body, inboxUserID, &activity)
+	case "Undo":
+		return receiveUndo(ctx, req, body, inboxUserID, &activity)
+	}
+	return nil
+}
+
+// targetUser returns the local user who is followed by the Follow activity.
+func targetUser(ctx context.Context, inboxUserID uint, follow *incomingActivity) (*db.User, error) {
+	var object string
+	if err := json.Unmarshal(follow.Object, &object); err != nil {
+		return nil, errors.Wrap(ErrInvalidActivity, "object is not an actor ID")
+	}
+
+	userID := userIDFromActorURL(object)
+	if userID == 0 || (inboxUserID != 0 && inboxUserID != userID) {
+		return nil, errors.Wrap(ErrInvalidActivity, "object is not the actor of the inbox")
+	}
+
+	user, err := db.Users.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, db.ErrUserNotExists) {
+			return nil, errors.Wrap(ErrInvalidActivity, "user does not exist")
+		}
+		return nil, errors.Wrap(err, "get user by ID")
+	}
+	return user, nil
+}
+
+// verifyActor fetches the actor of the activity and checks the request is signed by the actor's key.
+func verifyActor(ctx context.Context, req *http.Request, body []byte, actorID string, signer *signer) (*Actor, error) {
+	sig, err := parseSignature(req.Header.Get("Signature"))
+	if err != nil {
+		return nil, errors.Wrapf(ErrInvalidSignature, "parse signature: %v", err)
+	}
+
+	actor, err := fetchActor(ctx, actorID, signer)
+	if err != nil {
+		return nil, errors.Wrapf(ErrInvalidSignature, "fetch actor: %v", err)
+	}
+	if actor.PublicKey == nil || actor.PublicKey.ID != sig.KeyID {
+		return nil, errors.Wrap(ErrInvalidSignature, "key is not owned by the actor")
+	}
+
+	publicKey, err := parsePublicKey(actor.PublicKey.PublicKeyPEM)
+	if err != nil {
+		return nil, errors.Wrapf(ErrInvalidSignature, "parse public key: %v", err)
+	}
+	if err := sig.verify(req, body, publicKey); err != nil {
+		return nil, err
+	}
+	return actor, nil
+}
+
+func receiveFollow(ctx context.Context, req *http.Request, body []byte, inboxUserID uint, follow *incomingActivity) error {
+	user, err := targetUser(ctx, inboxUserID, follow)
+	if err != nil {
+		return err
+	}
+	signer, err := newSigner(ctx, user.ID)
+	if err != nil {
+		return errors.Wrap(err, "new signer")
+	}
+
+	actor, err := verifyActor(ctx, req, body, follow.Actor, signer)
+	if err != nil {
+		return err
+	}
+
+	sharedInbox := ""
+	if actor.Endpoints != nil {
+		sharedInbox = actor.Endpoints.SharedInbox
+	}
+	if err := db.ActivityPubFollowers.Create(ctx, db.CreateActivityPubFollowerOptions{
+		UserID:      user.ID,
+		ActorID:     actor.ID,
+		Inbox:       actor.Inbox,
+		SharedInbox: sharedInbox,
+	}); err != nil {
+		return errors.Wrap(err, "create follower")
+	}
+
+	// The follow requests are approved automatically, for the answers are public anyway.
+	accept, err := json.Marshal(&Activity{
+		Context: ActivityStreamsContext,
+		ID:      ActorURL(user.ID) + "#accepts/follows/" + randstr.Hex(16),
+		Type:    "Accept",
+		Actor:   ActorURL(user.ID),
+		Object: &Activity{
+			ID:     follow.ID,
+			Type:   "Follow",
+			Actor:  follow.Actor,
+			Object: ActorURL(user.ID),
+		},
+	})
+	if err != nil {
+		return errors.Wrap(err, "marshal accept")
+	}
+	enqueue(ctx, delivery{UserID: user.ID, Inbox: actor.Inbox, Body: accept})
+	return nil
+}
+
+func receiveUndo(ctx context.Context, req *http.Request, body []byte, inboxUserID uint, undo *incomingActivity) error {
+	// Only the embedded Follow activity can be undone, the servers always embed it when unfollowing.
+	var follow incomingActivity
+	if err := json.Unmarshal(undo.Object, &follow); err != nil || follow.Type != "Follow" {
+		return nil
+	}
+	if follow.Actor != undo.Actor {
+		return errors.Wrap(ErrInvalidActivity, "undo the activity of another actor")
+	}
+
+	user, err := targetUser(ctx, inboxUserID, &follow)
+	if err != nil {
+		return err
+	}
+	signer, err := newSigner(ctx, user.ID)
+	if err != nil {
+		return errors.Wrap(err, "new signer")
+	}
+
+	if _, err := verifyActor(ctx, req, body, undo.Actor, signer); err != nil {
+		return err
+	}
+
+	if err := db.ActivityPubFollowers.DeleteByActorID(ctx, user.ID, undo.Actor); err != nil && !errors.Is(err, db.ErrActivityPubFollowerNotExist) {
+		return errors.Wrap(err, "delete follower")
+	}
+	return nil
+}
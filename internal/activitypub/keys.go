@@ -0,0 +1,110 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package activitypub
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+
+	"github.com/pkg/errors"
+
+	"github.com/NekoWheel/NekoBox/internal/db"
+)
+
+// keyBits is the size of the actors' RSA keys, which is what Mastodon uses.
+const keyBits = 2048
+
+// loadKey returns the key pair of the user's actor, the key pair is generated on the first use.
+func loadKey(ctx context.Context, userID uint) (*db.ActivityPubKey, error) {
+	key, err := db.ActivityPubKeys.GetByUserID(ctx, userID)
+	if err == nil {
+		return key, nil
+	} else if !errors.Is(err, db.ErrActivityPubKeyNotExist) {
+		return nil, errors.Wrap(err, "get key by user ID")
+	}
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, keyBits)
+	if err != nil {
+		return nil, errors.Wrap(err, "generate key")
+	}
+	publicKeyDER, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal public key")
+	}
+
+	key, err = db.ActivityPubKeys.Create(ctx, db.CreateActivityPubKeyOptions{
+		UserID:        userID,
+		PrivateKeyPEM: string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privateKey)})),
+		PublicKeyPEM:  string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKeyDER})),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "create key")
+	}
+	return key, nil
+}
+
+// PublicKeyPEM returns the public key of the user's actor, which is embedded in the actor object.
+func PublicKeyPEM(ctx context.Context, userID uint) (string, error) {
+	key, err := loadKey(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	return key.PublicKeyPEM, nil
+}
+
+// signer signs the requests on behalf of the local actor.
+type signer struct {
+	keyID      string
+	privateKey *rsa.PrivateKey
+}
+
+func newSigner(ctx context.Context, userID uint) (*signer, error) {
+	key, err := loadKey(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode([]byte(key.PrivateKeyPEM))
+	if block == nil {
+		return nil, errors.New("decode private key PEM")
+	}
+	privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse private key")
+	}
+	return &signer{
+		keyID:      ActorURL(userID) + "#main-key",
+		privateKey: privateKey,
+	}, nil
+}
+
+func parsePublicKey(publicKeyPEM string) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode([]byte(publicKeyPEM))
+	if block == nil {
+		return nil, errors.New("decode public key PEM")
+	}
+
+	// Some servers publish the PKCS #1 keys rather than the PKIX ones.
+	if block.Type == "RSA PUBLIC KEY" {
+		publicKey, err := x509.ParsePKCS1PublicKey(block.Bytes)
+		if err != nil {
+			return nil, errors.Wrap(err, "parse PKCS #1 public key")
+		}
+		return publicKey, nil
+	}
+
+	publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse PKIX public key")
+	}
+	rsaPublicKey, ok := publicKey.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.Errorf("unsupported public key type %T", publicKey)
+	}
+	return rsaPublicKey, nil
+}
@@ -0,0 +1,161 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package activitypub
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// The requests are signed with the HTTP signatures of draft-cavage-http-signatures,
+// which is the scheme supported by Mastodon and the other fediverse servers.
+
+// maxClockSkew is how far the Date header of the signed request can be from now.
+const maxClockSkew = time.Hour
+
+var ErrInvalidSignature = errors.New("invalid HTTP signature")
+
+// sign adds the Signature header to the request, the body is covered by the Digest header.
+func (s *signer) sign(req *http.Request, body []byte) error {
+	req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	headers := []string{"(request-target)", "host", "date"}
+	if body != nil {
+		req.Header.Set("Digest", digest(body))
+		headers = append(headers, "digest")
+	}
+
+	signingString, err := signingString(req, headers)
+	if err != nil {
+		return errors.Wrap(err, "build signing string")
+	}
+	hashed := sha256.Sum256([]byte(signingString))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.privateKey, crypto.SHA256, hashed[:])
+	if err != nil {
+		return errors.Wrap(err, "sign")
+	}
+
+	req.Header.Set("Signature", fmt.Sprintf(`keyId="%s",algorithm="rsa-sha256",headers="%s",signature="%s"`,
+		s.keyID, strings.Join(headers, " "), base64.StdEncoding.EncodeToString(signature)))
+	return nil
+}
+
+func digest(body []byte) string {
+	sum := sha256.Sum256(body)
+	return "SHA-256=" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// signingString returns the string covered by the signature, which is the lines of the given headers.
+func signingString(req *http.Request, headers []string) (string, error) {
+	lines := make([]string, 0, len(headers))
+	for _, header := range headers {
+		switch header {
+		case "(request-target)":
+			lines = append(lines, fmt.Sprintf("(request-target): %s %s", strings.ToLower(req.Method), req.URL.RequestURI()))
+		case "host":
+			host := req.Host
+			if host == "" {
+				host = req.URL.Host
+			}
+			lines = append(lines, "host: "+host)
+		default:
+			values := req.Header.Values(header)
+			if len(values) == 0 {
+				return "", errors.Errorf("missing header %q", header)
+			}
+			lines = append(lines, header+": "+strings.Join(values, ", "))
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// httpSignature is the parsed Signature header.
+type httpSignature struct {
+	KeyID     string
+	Algorithm string
+	Headers   []string
+	Signature []byte
+}
+
+func parseSignature(header string) (*httpSignature, error) {
+	var sig httpSignature
+	for _, param := range strings.Split(header, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+		if !ok {
+			continue
+		}
+		value = strings.Trim(value, `"`)
+		switch name {
+		case "keyId":
+			sig.KeyID = value
+		case "algorithm":
+			sig.Algorithm = value
+		case "headers":
+			sig.Headers = strings.Fields(value)
+		case "signature":
+			signature, err := base64.StdEncoding.DecodeString(value)
+			if err != nil {
+				return nil, errors.Wrap(err, "decode signature")
+			}
+			sig.Signature = signature
+		}
+	}
+
+	if sig.KeyID == "" || len(sig.Signature) == 0 {
+		return nil, errors.New("missing key ID or signature")
+	}
+	// The "hs2019" algorithm is resolved by the key, only the RSA keys are supported.
+	if sig.Algorithm != "" && sig.Algorithm != "rsa-sha256" && sig.Algorithm != "hs2019" {
+		return nil, errors.Errorf("unsupported algorithm %q", sig.Algorithm)
+	}
+	if len(sig.Headers) == 0 {
+		sig.Headers = []string{"date"}
+	}
+	return &sig, nil
+}
+
+// verify checks the signature of the request with the public key. The signature must cover the
+// request target, the date and the digest of the body, so it can't be replayed to another endpoint
+// or with another body.
+func (sig *httpSignature) verify(req *http.Request, body []byte, publicKey *rsa.PublicKey) error {
+	covered := make(map[string]bool, len(sig.Headers))
+	for _, header := range sig.Headers {
+		covered[header] = true
+	}
+	if !covered["(request-target)"] || !covered["date"] || !covered["digest"] {
+		return errors.Wrap(ErrInvalidSignature, "required headers are not signed")
+	}
+
+	date, err := http.ParseTime(req.Header.Get("Date"))
+	if err != nil {
+		return errors.Wrap(ErrInvalidSignature, "parse date")
+	}
+	if skew := time.Since(date); skew > maxClockSkew || skew < -maxClockSkew {
+		return errors.Wrap(ErrInvalidSignature, "date is out of range")
+	}
+
+	if subtle.ConstantTimeCompare([]byte(req.Header.Get("Digest")), []byte(digest(body))) != 1 {
+		return errors.Wrap(ErrInvalidSignature, "digest mismatch")
+	}
+
+	signingString, err := signingString(req, sig.Headers)
+	if err != nil {
+		return errors.Wrapf(ErrInvalidSignature, "build signing string: %v", err)
+	}
+	hashed := sha256.Sum256([]byte(signingString))
+	if err := rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, hashed[:], sig.Signature); err != nil {
+		return errors.Wrap(ErrInvalidSignature, "signature mismatch")
+	}
+	return nil
+}
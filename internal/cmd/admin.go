@@ -0,0 +1,84 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+
+	"github.com/NekoWheel/NekoBox/internal/conf"
+	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/logging"
+)
+
+var Admin = &cli.Command{
+	Name:  "admin",
+	Usage: "Manage the administrators",
+	Subcommands: []*cli.Command{
+		{
+			Name:  "grant",
+			Usage: "Grant the administrator privilege to the user",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:     "email",
+					Usage:    "The email of the user",
+					Required: true,
+				},
+			},
+			Action: func(ctx *cli.Context) error { return runAdminSet(ctx, true) },
+		},
+		{
+			Name:  "revoke",
+			Usage: "Revoke the administrator privilege from the user",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:     "email",
+					Usage:    "The email of the user",
+					Required: true,
+				},
+			},
+			Action: func(ctx *cli.Context) error { return runAdminSet(ctx, false) },
+		},
+	},
+}
+
+func runAdminSet(ctx *cli.Context, isAdmin bool) error {
+	if err := conf.Init(); err != nil {
+		return errors.Wrap(err, "load configuration")
+	}
+	if err := logging.Init(); err != nil {
+		return errors.Wrap(err, "init logging")
+	}
+
+	if _, err := db.Init(); err != nil {
+		return errors.Wrap(err, "connect to database")
+	}
+
+	user, err := db.Users.GetByEmail(ctx.Context, ctx.String("email"))
+	if err != nil {
+		return errors.Wrap(err, "get user by email")
+	}
+	if err := db.Users.SetAdmin(ctx.Context, user.ID, isAdmin); err != nil {
+		return errors.Wrap(err, "set admin")
+	}
+
+	// The actor of the actions from the command line is unknown.
+	action := db.AuditActionAdminGrant
+	if !isAdmin {
+		action = db.AuditActionAdminRevoke
+	}
+	if err := db.AuditLogs.Create(ctx.Context, db.CreateAuditLogOptions{
+		Action:     action,
+		TargetType: db.AuditTargetUser,
+		TargetID:   user.ID,
+		Metadata:   map[string]interface{}{"source": "cli", "email": user.Email},
+	}); err != nil {
+		return errors.Wrap(err, "create audit log")
+	}
+
+	logrus.WithField("email", user.Email).WithField("is_admin", isAdmin).Info("Updated administrator privilege")
+	return nil
+}
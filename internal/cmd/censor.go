@@ -11,6 +11,7 @@ import (
 
 	"github.com/NekoWheel/NekoBox/internal/conf"
 	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/logging"
 	"github.com/NekoWheel/NekoBox/internal/security/censor"
 )
 
@@ -24,6 +25,9 @@ func runCensor(ctx *cli.Context) error {
 	if err := conf.Init(); err != nil {
 		return errors.Wrap(err, "load configuration")
 	}
+	if err := logging.Init(); err != nil {
+		return errors.Wrap(err, "init logging")
+	}
 
 	if !conf.Security.EnableTextCensor {
 		return errors.New("text censor is disabled")
@@ -52,7 +56,7 @@ func runCensor(ctx *cli.Context) error {
 			logrus.WithContext(ctx.Context).WithField("question_id", question.ID).WithError(err).Error("Failed to censor content")
 		} else {
 			// We don't want to update the `updated_at` field, so just execute the raw SQL.
-			if err := database.Debug().Exec(`UPDATE questions SET content_censor_metadata = ? WHERE id = ?`, contentCensorResponse.ToJSON(), question.ID).Error; err != nil {
+			if err := database.Debug().Exec(`UPDATE questions SET content_censor_metadata = ?, content_censor_pass = ? WHERE id = ?`, contentCensorResponse.ToJSON(), contentCensorResponse.Pass, question.ID).Error; err != nil {
 				logrus.WithContext(ctx.Context).WithField("question_id", question.ID).WithError(err).Error("Failed to update content censor metadata")
 			}
 		}
@@ -63,7 +67,7 @@ func runCensor(ctx *cli.Context) error {
 				logrus.WithContext(ctx.Context).WithField("question_id", question.ID).WithError(err).Error("Failed to censor answer")
 			} else {
 				// We don't want to update the `updated_at` field, so just execute the raw SQL.
-				if err := database.Exec(`UPDATE questions SET answer_censor_metadata = ? WHERE id = ?`, answerCensorResponse.ToJSON(), question.ID).Error; err != nil {
+				if err := database.Exec(`UPDATE questions SET answer_censor_metadata = ?, answer_censor_pass = ? WHERE id = ?`, answerCensorResponse.ToJSON(), answerCensorResponse.Pass, question.ID).Error; err != nil {
 					logrus.WithContext(ctx.Context).WithField("question_id", question.ID).WithError(err).Error("Failed to update answer censor metadata")
 				}
 			}
@@ -0,0 +1,128 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+	"gorm.io/gorm"
+
+	"github.com/NekoWheel/NekoBox/internal/conf"
+	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/db/migrations"
+	"github.com/NekoWheel/NekoBox/internal/logging"
+)
+
+var Migrate = &cli.Command{
+	Name:  "migrate",
+	Usage: "Manage the database schema migrations",
+	Subcommands: []*cli.Command{
+		{
+			Name:  "up",
+			Usage: "Apply the pending migrations",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "to",
+					Usage: "Apply the migrations up to the given version",
+				},
+			},
+			Action: runMigrateUp,
+		},
+		{
+			Name:  "down",
+			Usage: "Revert the last applied migration",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "to",
+					Usage: "Revert the migrations applied after the given version",
+				},
+			},
+			Action: runMigrateDown,
+		},
+		{
+			Name:   "status",
+			Usage:  "Show the applied and pending migrations",
+			Action: runMigrateStatus,
+		},
+	},
+	Action: runMigrateUp,
+}
+
+func openDatabase() (*gorm.DB, error) {
+	if err := conf.Init(); err != nil {
+		return nil, errors.Wrap(err, "load configuration")
+	}
+	if err := logging.Init(); err != nil {
+		return nil, errors.Wrap(err, "init logging")
+	}
+
+	database, err := db.Open()
+	if err != nil {
+		return nil, errors.Wrap(err, "connect to database")
+	}
+	return database, nil
+}
+
+func runMigrateUp(ctx *cli.Context) error {
+	database, err := openDatabase()
+	if err != nil {
+		return err
+	}
+
+	if version := ctx.String("to"); version != "" {
+		err = migrations.MigrateTo(database, version)
+	} else {
+		err = migrations.Migrate(database)
+	}
+	if err != nil {
+		return errors.Wrap(err, "migrate")
+	}
+
+	logrus.WithContext(ctx.Context).Info("Database migrated")
+	return nil
+}
+
+func runMigrateDown(ctx *cli.Context) error {
+	database, err := openDatabase()
+	if err != nil {
+		return err
+	}
+
+	if version := ctx.String("to"); version != "" {
+		err = migrations.RollbackTo(database, version)
+	} else {
+		err = migrations.Rollback(database)
+	}
+	if err != nil {
+		return errors.Wrap(err, "rollback")
+	}
+
+	logrus.WithContext(ctx.Context).Info("Database rolled back")
+	return nil
+}
+
+func runMigrateStatus(ctx *cli.Context) error {
+	database, err := openDatabase()
+	if err != nil {
+		return err
+	}
+
+	versions, err := migrations.Status(database)
+	if err != nil {
+		return errors.Wrap(err, "get migration status")
+	}
+
+	for _, version := range versions {
+		status := "pending"
+		if version.Applied {
+			status = "applied"
+		}
+		fmt.Printf("%-40s %s\n", version.Version, status)
+	}
+	return nil
+}
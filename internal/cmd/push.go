@@ -0,0 +1,37 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+
+	"github.com/NekoWheel/NekoBox/internal/push"
+)
+
+var Push = &cli.Command{
+	Name:  "push",
+	Usage: "Manage the Web Push notifications",
+	Subcommands: []*cli.Command{
+		{
+			Name:   "generate-keys",
+			Usage:  "Generate a new pair of the VAPID keys",
+			Action: runPushGenerateKeys,
+		},
+	},
+}
+
+func runPushGenerateKeys(*cli.Context) error {
+	privateKey, publicKey, err := push.GenerateKeys()
+	if err != nil {
+		return errors.Wrap(err, "generate keys")
+	}
+
+	fmt.Printf("vapid_public_key = %q\n", publicKey)
+	fmt.Printf("vapid_private_key = %q\n", privateKey)
+	return nil
+}
@@ -0,0 +1,123 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+
+	"github.com/NekoWheel/NekoBox/internal/conf"
+	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/export"
+	"github.com/NekoWheel/NekoBox/internal/importer"
+	"github.com/NekoWheel/NekoBox/internal/logging"
+)
+
+var Export = &cli.Command{
+	Name:  "export",
+	Usage: "Export the questions of the user in the JSONL interchange format",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "user",
+			Usage:    "The domain of the user",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:    "output",
+			Aliases: []string{"o"},
+			Usage:   "The file to write, the questions are written to the standard output if not set",
+		},
+	},
+	Action: runExport,
+}
+
+var Import = &cli.Command{
+	Name:  "import",
+	Usage: "Import the questions exported from another NekoBox instance to the user",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "user",
+			Usage:    "The domain of the user",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:     "file",
+			Aliases:  []string{"f"},
+			Usage:    "The JSONL file exported by NekoBox",
+			Required: true,
+		},
+	},
+	Action: runImport,
+}
+
+func initQuestionsCommand(ctx *cli.Context) (*db.User, error) {
+	if err := conf.Init(); err != nil {
+		return nil, errors.Wrap(err, "load configuration")
+	}
+	if err := logging.Init(); err != nil {
+		return nil, errors.Wrap(err, "init logging")
+	}
+
+	if _, err := db.Init(); err != nil {
+		return nil, errors.Wrap(err, "connect to database")
+	}
+
+	user, err := db.Users.GetByDomain(ctx.Context, ctx.String("user"))
+	if err != nil {
+		return nil, errors.Wrap(err, "get user by domain")
+	}
+	return user, nil
+}
+
+func runExport(ctx *cli.Context) error {
+	user, err := initQuestionsCommand(ctx)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if output := ctx.String("output"); output != "" {
+		file, err := os.Create(output)
+		if err != nil {
+			return errors.Wrap(err, "create output file")
+		}
+		defer func() { _ = file.Close() }()
+		w = file
+	}
+
+	if err := export.QuestionsJSONL(ctx.Context, w, user.ID); err != nil {
+		return errors.Wrap(err, "export questions")
+	}
+	return nil
+}
+
+func runImport(ctx *cli.Context) error {
+	user, err := initQuestionsCommand(ctx)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(ctx.String("file"))
+	if err != nil {
+		return errors.Wrap(err, "open file")
+	}
+	defer func() { _ = file.Close() }()
+
+	questions, err := importer.Parse(importer.SourceNekoBox, file)
+	if err != nil {
+		return errors.Wrap(err, "parse file")
+	}
+	created, err := importer.Import(ctx.Context, user.ID, questions)
+	if err != nil {
+		return errors.Wrap(err, "import questions")
+	}
+
+	logrus.WithField("imported", created).WithField("skipped", len(questions)-created).Info("Questions imported")
+	return nil
+}
@@ -0,0 +1,36 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+
+	"github.com/NekoWheel/NekoBox/internal/conf"
+	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/logging"
+	"github.com/NekoWheel/NekoBox/internal/sitemap"
+)
+
+var Sitemap = &cli.Command{
+	Name:   "sitemap",
+	Usage:  "Regenerate the sitemap of the public boxes and the answered questions",
+	Action: runSitemap,
+}
+
+func runSitemap(ctx *cli.Context) error {
+	if err := conf.Init(); err != nil {
+		return errors.Wrap(err, "load configuration")
+	}
+	if err := logging.Init(); err != nil {
+		return errors.Wrap(err, "init logging")
+	}
+
+	if _, err := db.Init(); err != nil {
+		return errors.Wrap(err, "connect to database")
+	}
+
+	return sitemap.Generate(ctx.Context)
+}
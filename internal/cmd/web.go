@@ -5,28 +5,54 @@
 package cmd
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"github.com/uptrace/opentelemetry-go-extra/otellogrus"
 	"github.com/uptrace/uptrace-go/uptrace"
 	"github.com/urfave/cli/v2"
 
+	"github.com/NekoWheel/NekoBox/internal/activitypub"
 	"github.com/NekoWheel/NekoBox/internal/conf"
+	"github.com/NekoWheel/NekoBox/internal/cron"
+	"github.com/NekoWheel/NekoBox/internal/crosspost"
 	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/health"
+	"github.com/NekoWheel/NekoBox/internal/logging"
+	"github.com/NekoWheel/NekoBox/internal/mailer"
+	"github.com/NekoWheel/NekoBox/internal/pubsub"
+	"github.com/NekoWheel/NekoBox/internal/push"
 	"github.com/NekoWheel/NekoBox/internal/route"
+	"github.com/NekoWheel/NekoBox/internal/security/censor"
 	"github.com/NekoWheel/NekoBox/internal/tracing"
+	"github.com/NekoWheel/NekoBox/internal/webhook"
 )
 
 var Web = &cli.Command{
-	Name:   "web",
-	Usage:  "Start web server",
-	Action: runWeb,
+	Name:    "web",
+	Aliases: []string{"serve"},
+	Usage:   "Start web server",
+	Action:  runWeb,
 }
 
+// shutdownTimeout is the time to drain the in-flight requests and the workers, which is
+// shorter than the default 30 seconds grace period of Kubernetes before killing the pod.
+const shutdownTimeout = 25 * time.Second
+
 func runWeb(ctx *cli.Context) error {
 	if err := conf.Init(); err != nil {
 		return errors.Wrap(err, "load configuration")
 	}
+	if err := logging.Init(); err != nil {
+		return errors.Wrap(err, "init logging")
+	}
 
 	if conf.App.UptraceDSN != "" {
 		uptrace.ConfigureOpentelemetry(
@@ -35,6 +61,13 @@ func runWeb(ctx *cli.Context) error {
 			uptrace.WithServiceVersion(conf.BuildCommit),
 		)
 		logrus.WithContext(ctx.Context).Debug("Tracing enabled.")
+	} else if conf.Tracing.Exporter != "" {
+		tp, err := tracing.Init(ctx.Context)
+		if err != nil {
+			return errors.Wrap(err, "init tracing")
+		}
+		defer func() { _ = tp.Shutdown(context.Background()) }()
+		logrus.WithContext(ctx.Context).WithField("exporter", conf.Tracing.Exporter).Debug("Tracing enabled.")
 	}
 
 	logrus.AddHook(otellogrus.NewHook(otellogrus.WithLevels(
@@ -44,14 +77,91 @@ func runWeb(ctx *cli.Context) error {
 		logrus.WarnLevel,
 	)))
 
-	_, err := db.Init()
+	database, err := db.Init()
 	if err != nil {
 		return errors.Wrap(err, "connect to database")
 	}
 
+	health.Register("database", health.Database(database))
+	if conf.Redis.Addr != "" {
+		health.Register("redis", health.Redis(conf.Redis.Addr, conf.Redis.Password))
+	}
+	health.Register("mail_queue", health.MailQueue)
+
+	// The workers are stopped after the in-flight requests are drained, which may still enqueue the jobs.
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+
+	censor.StartQueue(workerCtx)
+	pubsub.Start(workerCtx)
+	webhook.Start(workerCtx)
+	activitypub.Start(workerCtx)
+	push.Start(workerCtx)
+	mailer.Start(workerCtx)
+	crosspost.Start(workerCtx)
+	cron.Start(workerCtx)
+
+	// The log level is reloaded from the configuration file on SIGHUP, so the debug logs
+	// can be turned on to diagnose the running server.
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	defer signal.Stop(reload)
+	go func() {
+		for range reload {
+			if err := logging.Reload(); err != nil {
+				logrus.WithError(err).Error("Failed to reload log level")
+				continue
+			}
+			logrus.WithField("log_level", logrus.GetLevel().String()).Info("Log level reloaded")
+		}
+	}()
+
 	r := route.New()
 	r.Use(tracing.Middleware("NekoBox"))
-	r.Run(conf.Server.Port)
 
+	server := &http.Server{
+		Addr:    fmt.Sprintf(":%d", conf.Server.Port),
+		Handler: r,
+	}
+	serveErr := make(chan error, 1)
+	go func() {
+		logrus.WithField("addr", server.Addr).Info("Listening")
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serveErr <- err
+		}
+	}()
+
+	signalCtx, stopSignal := signal.NotifyContext(ctx.Context, os.Interrupt, syscall.SIGTERM)
+	defer stopSignal()
+
+	select {
+	case err := <-serveErr:
+		return errors.Wrap(err, "listen and serve")
+	case <-signalCtx.Done():
+	}
+	// The second signal kills the process at once.
+	stopSignal()
+	logrus.Info("Shutting down, draining in-flight requests and workers")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logrus.WithError(err).Error("Failed to drain in-flight requests")
+	}
+
+	stopWorkers()
+	drained := make(chan struct{})
+	go func() {
+		censor.WaitQueue()
+		mailer.Wait()
+		crosspost.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		logrus.Info("Server stopped")
+	case <-shutdownCtx.Done():
+		logrus.Warn("Timed out waiting for the workers to finish")
+	}
 	return nil
 }
@@ -5,7 +5,9 @@
 package conf
 
 import (
+	"net"
 	"os"
+	"strings"
 
 	"github.com/pkg/errors"
 	"gopkg.in/ini.v1"
@@ -31,14 +33,56 @@ func Init() error {
 	if err := File.Section("app").MapTo(&App); err != nil {
 		return errors.Wrap(err, "map 'server'")
 	}
+	App.ExternalURL = strings.TrimRight(App.ExternalURL, "/")
+	if App.ExternalURL == "" {
+		App.ExternalURL = "https://box.n3ko.co"
+	}
 
 	if err := File.Section("security").MapTo(&Security); err != nil {
 		return errors.Wrap(err, "map 'security'")
 	}
+	switch Security.IPStorage {
+	case "", "raw", "hash":
+	default:
+		return errors.Errorf("unknown IP storage %q", Security.IPStorage)
+	}
+	switch Security.IPRetentionAction {
+	case "", "hash", "clear":
+	default:
+		return errors.Errorf("unknown IP retention action %q", Security.IPRetentionAction)
+	}
+	if Security.PasswordMinScore < 0 || Security.PasswordMinScore > 4 {
+		return errors.Errorf("password min score %d is out of range [0, 4]", Security.PasswordMinScore)
+	}
+	if Security.SuspensionDays <= 0 {
+		Security.SuspensionDays = 7
+	}
+	if Security.MaxSuspensionDays <= 0 {
+		Security.MaxSuspensionDays = 365
+	}
+	if Security.SuspensionDays > Security.MaxSuspensionDays {
+		return errors.Errorf("suspension days %d exceeds the max suspension days %d", Security.SuspensionDays, Security.MaxSuspensionDays)
+	}
+	if Security.CaptchaExemptDays < 0 || Security.CaptchaExemptQuestions < 0 {
+		return errors.New("captcha exemption days and questions must not be negative")
+	}
+
+	if err := File.Section("tracing").MapTo(&Tracing); err != nil {
+		return errors.Wrap(err, "map 'tracing'")
+	}
+
+	if err := mapLog(); err != nil {
+		return err
+	}
 
 	if err := File.Section("server").MapTo(&Server); err != nil {
 		return errors.Wrap(err, "map 'server'")
 	}
+	for _, proxy := range Server.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			return errors.Errorf("invalid trusted proxy %q", proxy)
+		}
+	}
 
 	if err := File.Section("database").MapTo(&Database); err != nil {
 		return errors.Wrap(err, "map 'database'")
@@ -48,6 +92,20 @@ func Init() error {
 		return errors.Wrap(err, "map 'redis'")
 	}
 
+	Session.RedisDB = 1
+	if err := File.Section("session").MapTo(&Session); err != nil {
+		return errors.Wrap(err, "map 'session'")
+	}
+	switch Session.Store {
+	case "", "memory", "file", "database":
+	case "redis":
+		if Redis.Addr == "" {
+			return errors.New("redis session store requires the redis address")
+		}
+	default:
+		return errors.Errorf("unknown session store %q", Session.Store)
+	}
+
 	if err := File.Section("recaptcha").MapTo(&Recaptcha); err != nil {
 		return errors.Wrap(err, "map 'recaptcha'")
 	}
@@ -55,10 +113,76 @@ func Init() error {
 	if err := File.Section("upload").MapTo(&Upload); err != nil {
 		return errors.Wrap(err, "map 'upload'")
 	}
+	if len(Upload.AllowedPictureTypes) == 0 {
+		Upload.AllowedPictureTypes = []string{"image/jpeg", "image/png", "image/gif", "image/webp"}
+	}
+	if Upload.MaxPictureSize <= 0 {
+		Upload.MaxPictureSize = 2
+	}
+	if Upload.MaxAudioSize <= 0 {
+		Upload.MaxAudioSize = 5
+	}
+	if Upload.MaxAudioDuration <= 0 {
+		Upload.MaxAudioDuration = 60
+	}
+
+	if err := File.Section("limits").MapTo(&Limits); err != nil {
+		return errors.Wrap(err, "map 'limits'")
+	}
+	if Limits.QuestionMaxLength <= 0 {
+		Limits.QuestionMaxLength = 1000
+	}
+	if Limits.AnswerMaxLength <= 0 {
+		Limits.AnswerMaxLength = 1000
+	}
+	if Limits.DailyAskLimit < 0 {
+		return errors.Errorf("daily ask limit %d must not be negative", Limits.DailyAskLimit)
+	}
+
+	if err := File.Section("push").MapTo(&Push); err != nil {
+		return errors.Wrap(err, "map 'push'")
+	}
 
 	if err := File.Section("mail").MapTo(&Mail); err != nil {
 		return errors.Wrap(err, "map 'mail'")
 	}
 
+	if err := File.Section("federation").MapTo(&Federation); err != nil {
+		return errors.Wrap(err, "map 'federation'")
+	}
+
+	if err := File.Section("crosspost").MapTo(&Crosspost); err != nil {
+		return errors.Wrap(err, "map 'crosspost'")
+	}
+
+	if err := File.Section("translation").MapTo(&Translation); err != nil {
+		return errors.Wrap(err, "map 'translation'")
+	}
+
+	if err := File.Section("spam").MapTo(&Spam); err != nil {
+		return errors.Wrap(err, "map 'spam'")
+	}
+
+	return nil
+}
+
+func mapLog() error {
+	if err := File.Section("log").MapTo(&Log); err != nil {
+		return errors.Wrap(err, "map 'log'")
+	}
+	switch Log.Format {
+	case "", "text", "json":
+	default:
+		return errors.Errorf("unknown log format %q", Log.Format)
+	}
 	return nil
 }
+
+// ReloadLog reloads the log section from the configuration file, so the log level
+// can be changed without restarting the server.
+func ReloadLog() error {
+	if err := File.Reload(); err != nil {
+		return errors.Wrap(err, "reload configuration")
+	}
+	return mapLog()
+}
@@ -15,30 +15,69 @@ var (
 var (
 	App struct {
 		Production            bool   `ini:"production"`
+		ExternalURL           string `ini:"external_url"`
 		ICP                   string `ini:"icp"`
 		UptraceDSN            string `ini:"uptrace_dsn"`
 		QiniuAccessKey        string `ini:"qiniu_access_key"`
 		QiniuAccessSecret     string `ini:"qiniu_access_secret"`
 		AliyunAccessKey       string `ini:"aliyun_access_key"`
 		AliyunAccessKeySecret string `ini:"aliyun_access_key_secret"`
+		ShareCardFontFile     string `ini:"share_card_font_file"`
 	}
 
 	Security struct {
-		EnableTextCensor bool `ini:"enable_text_censor"`
+		EnableTextCensor       bool     `ini:"enable_text_censor"`
+		TextCensorProviders    []string `ini:"text_censor_providers" delim:","`
+		TextCensorKeywordsFile string   `ini:"text_censor_keywords_file"`
+		IPStorage              string   `ini:"ip_storage"`
+		IPRetentionDays        int      `ini:"ip_retention_days"`
+		IPRetentionAction      string   `ini:"ip_retention_action"`
+		GeoIPDatabase          string   `ini:"geoip_database"`
+		ReportHideThreshold    int      `ini:"report_hide_threshold"`
+		PasswordMinScore       int      `ini:"password_min_score"`
+		PasswordBreachCheck    bool     `ini:"password_breach_check"`
+		SuspensionDays         int      `ini:"suspension_days"`
+		MaxSuspensionDays      int      `ini:"max_suspension_days"`
+		CaptchaExemptDays      int      `ini:"captcha_exempt_days"`
+		CaptchaExemptQuestions int      `ini:"captcha_exempt_questions"`
+		DisposableEmailCheck   bool     `ini:"disposable_email_check"`
+		DisposableEmailListURL string   `ini:"disposable_email_list_url"`
+	}
+
+	Tracing struct {
+		Exporter     string   `ini:"exporter"`
+		OTLPEndpoint string   `ini:"otlp_endpoint"`
+		OTLPInsecure bool     `ini:"otlp_insecure"`
+		OTLPHeaders  []string `ini:"otlp_headers" delim:","`
+		SampleRatio  float64  `ini:"sample_ratio"`
+	}
+
+	Log struct {
+		Level  string `ini:"level"`
+		Format string `ini:"format"`
 	}
 
 	Server struct {
 		Port    int    `ini:"port"`
 		Salt    string `ini:"salt"`
 		XSRFKey string `ini:"xsrf_key"`
+		JWTKey  string `ini:"jwt_key"`
+		// TrustedProxies are the IP addresses or CIDR ranges of the reverse proxies and the CDN,
+		// the client IP address is only read from the forwarding headers sent by them.
+		TrustedProxies []string `ini:"trusted_proxies" delim:","`
 	}
 
 	Database struct {
 		DSN      string
+		Type     string `ini:"type"`
 		User     string `ini:"user"`
 		Password string `ini:"password"`
 		Address  string `ini:"address"`
 		Name     string `ini:"name"`
+		SSLMode  string `ini:"ssl_mode"`
+		Path     string `ini:"path"`
+		// Replicas are the addresses of the read replicas, which share the credentials with the primary.
+		Replicas []string `ini:"replicas" delim:","`
 	}
 
 	Redis struct {
@@ -46,7 +85,16 @@ var (
 		Password string `ini:"password"`
 	}
 
+	Session struct {
+		// Store is where the sessions are saved, available values: memory, file, database, redis.
+		// It is chosen by the database and the Redis configuration if it is empty.
+		Store string `ini:"store"`
+		// RedisDB is the Redis database of the sessions, which defaults to 1.
+		RedisDB int `ini:"redis_db"`
+	}
+
 	Recaptcha struct {
+		Provider  string `ini:"provider"`
 		Domain    string `ini:"domain"`
 		SiteKey   string `ini:"site_key"`
 		ServerKey string `ini:"server_key"`
@@ -60,12 +108,77 @@ var (
 		AliyunAccessSecret  string `ini:"aliyun_access_secret"`
 		AliyunBucket        string `ini:"aliyun_bucket"`
 		AliyunBucketCDNHost string `ini:"aliyun_bucket_cdn_host"`
+		// AllowedPictureTypes are the content types of the avatars and the backgrounds.
+		AllowedPictureTypes []string `ini:"allowed_picture_types" delim:","`
+		// MaxPictureSize is the max size of the avatars and the backgrounds in MB.
+		MaxPictureSize int64 `ini:"max_picture_size"`
+		// MaxAudioSize is the max size of the voice notes of the answers in MB.
+		MaxAudioSize int64 `ini:"max_audio_size"`
+		// MaxAudioDuration is the max length of the voice notes in seconds.
+		MaxAudioDuration int `ini:"max_audio_duration"`
+		// FFmpegPath is the ffmpeg binary to transcode the voice notes, only the formats which
+		// the browsers can play are accepted if it is empty.
+		FFmpegPath string `ini:"ffmpeg_path"`
+	}
+
+	Limits struct {
+		QuestionMaxLength int `ini:"question_max_length"`
+		AnswerMaxLength   int `ini:"answer_max_length"`
+		// DailyAskLimit is the most questions an asker can send to a box in 24 hours,
+		// the anonymous askers are identified by the IP addresses. Zero means no limit.
+		DailyAskLimit int `ini:"daily_ask_limit"`
+	}
+
+	Push struct {
+		VAPIDPublicKey  string `ini:"vapid_public_key"`
+		VAPIDPrivateKey string `ini:"vapid_private_key"`
+		Subject         string `ini:"subject"`
 	}
 
 	Mail struct {
+		Provider string `ini:"provider"`
+		From     string `ini:"from"`
 		Account  string `ini:"account"`
 		Password string `ini:"password"`
 		Port     int    `ini:"port"`
 		SMTP     string `ini:"smtp"`
+		APIKey   string `ini:"api_key"`
+		Domain   string `ini:"domain"`
+		Endpoint string `ini:"endpoint"`
+
+		// ReplyDomain is the domain receiving the replies to the new question mails, the owners
+		// answer the questions by replying, which are forwarded to the inbound mail webhook.
+		ReplyDomain   string `ini:"reply_domain"`
+		InboundSecret string `ini:"inbound_secret"`
+
+		// MaxQueueDepth is the most pending mails in the outbox before the instance is reported as not ready.
+		MaxQueueDepth int64 `ini:"max_queue_depth"`
+	}
+
+	Federation struct {
+		Enabled bool `ini:"enabled"`
+	}
+
+	Crosspost struct {
+		// TwitterClientID and TwitterClientSecret are the OAuth 2.0 credentials of the Twitter app,
+		// the cross-posting to Twitter is disabled if they are empty.
+		TwitterClientID     string `ini:"twitter_client_id"`
+		TwitterClientSecret string `ini:"twitter_client_secret"`
+	}
+
+	Translation struct {
+		// Provider is the machine translation service, the translation is disabled if it is empty.
+		Provider string `ini:"provider"`
+		APIKey   string `ini:"api_key"`
+		// APIURL overrides the API endpoint of the provider, e.g. the free API of DeepL.
+		APIURL string `ini:"api_url"`
+	}
+
+	Spam struct {
+		Scorers                    []string `ini:"scorers" delim:","`
+		RejectScore                int      `ini:"reject_score"`
+		ReviewScore                int      `ini:"review_score"`
+		DisposableEmailDomainsFile string   `ini:"disposable_email_domains_file"`
+		MinFillSeconds             int      `ini:"min_fill_seconds"`
 	}
 )
@@ -5,26 +5,160 @@
 package context
 
 import (
+	"net/http"
+	"strings"
+
 	"github.com/flamego/flamego"
-	"github.com/flamego/session"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
 
 	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/security/token"
 )
 
-// authenticatedUser returns the user object of the authenticated user.
-func authenticatedUser(ctx flamego.Context, sess session.Session) *db.User {
-	uid, ok := sess.Get("uid").(uint)
+// bearerToken returns the token in the `Authorization: Bearer <token>` header.
+func bearerToken(r *http.Request) string {
+	authorization := r.Header.Get("Authorization")
+	if len(authorization) < 7 || !strings.EqualFold(authorization[:7], "Bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(authorization[7:])
+}
+
+// sessionTokenKey is the session key of the token which identifies the server-side session record.
+const sessionTokenKey = "session_token"
+
+// SignIn signs in the user in the current session, and records the session so that
+// it can be listed and signed out remotely.
+func (c *Context) SignIn(userID uint) error {
+	userSession, token, err := db.UserSessions.Create(c.Request().Context(), db.CreateUserSessionOptions{
+		UserID:    userID,
+		UserAgent: c.Request().UserAgent(),
+		IP:        c.RealIP(),
+	})
+	if err != nil {
+		return errors.Wrap(err, "create user session")
+	}
+
+	c.Session.Set("uid", userID)
+	c.Session.Set(sessionTokenKey, token)
+	c.UserSession = userSession
+	return nil
+}
+
+// SignOut signs out the current session and deletes its record.
+func (c *Context) SignOut() {
+	if c.UserSession != nil {
+		if err := db.UserSessions.DeleteByID(c.Request().Context(), c.UserSession.UserID, c.UserSession.ID); err != nil && !errors.Is(err, db.ErrUserSessionNotExist) {
+			logrus.WithContext(c.Request().Context()).WithError(err).Error("Failed to delete user session")
+		}
+		c.UserSession = nil
+	}
+	c.Session.Flush()
+}
+
+// authenticatedUser returns the user object of the authenticated user, and the personal
+// access token or the session record which authenticates the request.
+func authenticatedUser(ctx *Context) (*db.User, *db.AccessToken, *db.UserSession) {
+	// API clients are authenticated by the bearer token instead of the session.
+	if bearer := bearerToken(ctx.Request().Request); bearer != "" {
+		// The bearer tokens can only be used to call the API.
+		if !strings.HasPrefix(ctx.Request().URL.Path, "/api/") {
+			return nil, nil, nil
+		}
+
+		if strings.HasPrefix(bearer, db.AccessTokenPrefix) {
+			accessToken, err := db.AccessTokens.GetByToken(ctx.Request().Context(), bearer)
+			if err != nil {
+				if !errors.Is(err, db.ErrAccessTokenNotExist) {
+					logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get access token")
+				}
+				return nil, nil, nil
+			}
+			user, err := db.Users.GetByID(ctx.Request().Context(), accessToken.UserID)
+			if err != nil {
+				return nil, nil, nil
+			}
+
+			if err := db.AccessTokens.Touch(ctx.Request().Context(), accessToken.ID); err != nil {
+				logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to touch access token")
+			}
+			return user, accessToken, nil
+		}
+
+		// The login token is bound to a session record, so that it is revoked along with
+		// the record, e.g. signed out remotely or the password has been changed.
+		uid, sessionToken, err := token.Parse(bearer)
+		if err != nil {
+			return nil, nil, nil
+		}
+		userSession, err := db.UserSessions.GetByToken(ctx.Request().Context(), sessionToken)
+		if err != nil {
+			if !errors.Is(err, db.ErrUserSessionNotExist) {
+				logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get user session")
+			}
+			return nil, nil, nil
+		}
+		if userSession.UserID != uid {
+			return nil, nil, nil
+		}
+
+		user, err := db.Users.GetByID(ctx.Request().Context(), uid)
+		if err != nil {
+			return nil, nil, nil
+		}
+
+		if err := db.UserSessions.Touch(ctx.Request().Context(), userSession.ID, ctx.RealIP()); err != nil {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to touch user session")
+		}
+		return user, nil, nil
+	}
+
+	uid, ok := ctx.Session.Get("uid").(uint)
 	if !ok {
-		return nil
+		return nil, nil, nil
+	}
+
+	// The session is signed out if its record has been deleted, e.g. revoked on the other device
+	// or the password has been changed. The sessions signed in before the records were introduced
+	// don't have the token and are signed out as well.
+	sessionToken, _ := ctx.Session.Get(sessionTokenKey).(string)
+	if sessionToken == "" {
+		ctx.Session.Delete("uid")
+		return nil, nil, nil
+	}
+	userSession, err := db.UserSessions.GetByToken(ctx.Request().Context(), sessionToken)
+	if err != nil {
+		if !errors.Is(err, db.ErrUserSessionNotExist) {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get user session")
+			return nil, nil, nil
+		}
+		ctx.Session.Delete("uid")
+		ctx.Session.Delete(sessionTokenKey)
+		return nil, nil, nil
+	}
+	if userSession.UserID != uid {
+		return nil, nil, nil
+	}
+
+	user, err := db.Users.GetByID(ctx.Request().Context(), uid)
+	if err != nil {
+		return nil, nil, nil
 	}
 
-	user, _ := db.Users.GetByID(ctx.Request().Context(), uid)
-	return user
+	if err := db.UserSessions.Touch(ctx.Request().Context(), userSession.ID, ctx.RealIP()); err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to touch user session")
+	}
+	return user, nil, userSession
 }
 
 type ToggleOptions struct {
 	UserSignInRequired  bool
 	UserSignOutRequired bool
+	AdminRequired       bool
+	// Scope is the scope that the personal access token must have to access the endpoint,
+	// the requests authenticated by the personal access token are rejected if it is empty.
+	Scope db.AccessTokenScope
 }
 
 func Toggle(options *ToggleOptions) flamego.Handler {
@@ -42,6 +176,18 @@ func Toggle(options *ToggleOptions) flamego.Handler {
 			ctx.Redirect("/login")
 			return nil
 		}
+
+		if ctx.AccessToken != nil && (options.Scope == "" || !ctx.AccessToken.HasScope(options.Scope)) {
+			return ctx.JSONError(40300, "访问令牌没有访问该接口的权限")
+		}
+
+		if options.AdminRequired && (!ctx.IsLogged || !ctx.User.IsAdmin) {
+			if endpoint.IsAPI() {
+				return ctx.JSONError(40300, "权限不足")
+			}
+			ctx.Redirect("/")
+			return nil
+		}
 		return nil
 	}
 }
@@ -6,21 +6,26 @@ package context
 
 import (
 	"encoding/json"
-	"fmt"
+	"net"
 	"net/http"
 	"reflect"
+	"strings"
+	"sync"
 
 	"github.com/flamego/csrf"
 	"github.com/flamego/flamego"
 	"github.com/flamego/session"
 	"github.com/flamego/template"
 	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
 	"github.com/unknwon/com"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 
 	"github.com/NekoWheel/NekoBox/internal/conf"
 	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/i18n"
+	"github.com/NekoWheel/NekoBox/internal/security/captcha"
 	templatepkg "github.com/NekoWheel/NekoBox/internal/template"
 )
 
@@ -53,6 +58,14 @@ type Context struct {
 
 	User     *db.User
 	IsLogged bool
+	// AccessToken is the personal access token which authenticates the request, it is nil
+	// if the request is authenticated by the session or the login token.
+	AccessToken *db.AccessToken
+	// UserSession is the session record of the signed-in session, it is nil if the request
+	// is not authenticated by the session.
+	UserSession *db.UserSession
+
+	Locale *i18n.Locale
 }
 
 // HasError returns true if error occurs in form validation.
@@ -66,7 +79,7 @@ func (c *Context) HasError() bool {
 
 func (c *Context) SetError(err error, f ...interface{}) {
 	c.Data["HasError"] = true
-	c.Data["Error"] = err.Error()
+	c.Data["Error"] = c.TrError(err)
 
 	// Set back the form data.
 	if len(f) > 0 {
@@ -95,12 +108,18 @@ func (c *Context) SetError(err error, f ...interface{}) {
 	}
 }
 
+// ErrorMessage returns the error message set by SetError.
+func (c *Context) ErrorMessage() string {
+	message, _ := c.Data["Error"].(string)
+	return message
+}
+
 func (c *Context) SetInternalError(f ...interface{}) {
 	span := trace.SpanFromContext(c.Request().Context())
 	traceID := span.SpanContext().TraceID()
 
-	c.Data["FlashTip"] = fmt.Sprintf("若问题一直出现，请带上该段字符 %s 提交反馈。", traceID.String())
-	c.SetError(errors.New("服务内部错误，请稍后重试。"), f...)
+	c.Data["FlashTip"] = c.Tr("error.internal_tip", map[string]interface{}{"TraceID": traceID.String()})
+	c.SetError(errors.New(c.Tr("error.internal")), f...)
 }
 
 // Success renders HTML template with given name with 200 OK status code.
@@ -108,6 +127,16 @@ func (c *Context) Success(templateName string) {
 	c.Template.HTML(http.StatusOK, templateName)
 }
 
+// Tr returns the message translated into the language of the request.
+func (c *Context) Tr(messageID string, data ...map[string]interface{}) string {
+	return c.Locale.Tr(messageID, data...)
+}
+
+// TrError returns the error message translated into the language of the request.
+func (c *Context) TrError(err error) string {
+	return c.Locale.Error(err)
+}
+
 func (c *Context) SetTitle(title string) {
 	c.Data["Title"] = title
 }
@@ -116,6 +145,65 @@ func (c *Context) Refresh() {
 	c.Redirect(c.Request().URL.Path)
 }
 
+// RealIP returns the real IP address of the request. The forwarding headers can be set by
+// anyone, so they are only trusted when the request comes from one of the trusted proxies.
+func (c *Context) RealIP() string {
+	remoteIP, _, err := net.SplitHostPort(c.Request().RemoteAddr)
+	if err != nil {
+		remoteIP = c.Request().RemoteAddr
+	}
+	if !isTrustedProxy(remoteIP) {
+		warnUntrustedForwarding(c, remoteIP)
+		return remoteIP
+	}
+
+	for _, header := range realIPHeaders {
+		if ip := strings.TrimSpace(c.Request().Header.Get(header)); net.ParseIP(ip) != nil {
+			return ip
+		}
+	}
+	return remoteIP
+}
+
+// `Ali-CDN-Real-IP` is the origin IP header of the aliyun CDN.
+var realIPHeaders = []string{"Ali-CDN-Real-IP", "X-Real-IP"}
+
+var untrustedForwardingOnce sync.Once
+
+// warnUntrustedForwarding warns once if the request carries the client IP headers but doesn't come
+// from a trusted proxy, which usually means the proxy or the CDN is missing in `trusted_proxies`,
+// and all the visitors behind it are seen as the same IP address.
+func warnUntrustedForwarding(c *Context, remoteIP string) {
+	for _, header := range []string{"Ali-CDN-Real-IP", "X-Real-IP", "X-Forwarded-For"} {
+		if c.Request().Header.Get(header) == "" {
+			continue
+		}
+		untrustedForwardingOnce.Do(func() {
+			logrus.WithContext(c.Request().Context()).WithField("remote_ip", remoteIP).WithField("header", header).
+				Warn("Ignored the client IP header from an untrusted proxy, add the proxy to `trusted_proxies` in the [server] section if it is yours")
+		})
+		return
+	}
+}
+
+// isTrustedProxy returns true if the given IP address is one of the trusted proxies.
+func isTrustedProxy(ip string) bool {
+	parsedIP := net.ParseIP(ip)
+	if parsedIP == nil {
+		return false
+	}
+	for _, proxy := range conf.Server.TrustedProxies {
+		if _, ipNet, err := net.ParseCIDR(proxy); err == nil {
+			if ipNet.Contains(parsedIP) {
+				return true
+			}
+		} else if proxyIP := net.ParseIP(proxy); proxyIP != nil && proxyIP.Equal(parsedIP) {
+			return true
+		}
+	}
+	return false
+}
+
 func (c *Context) JSON(data interface{}) error {
 	resp := map[string]interface{}{
 		"code":    0,
@@ -123,11 +211,12 @@ func (c *Context) JSON(data interface{}) error {
 		"message": "success",
 	}
 
+	c.ResponseWriter().Header().Set("Content-Type", "application/json; charset=utf-8")
 	return json.NewEncoder(c.ResponseWriter()).Encode(resp)
 }
 
 func (c *Context) ServerError() error {
-	return c.JSONError(50000, "服务器内部错误")
+	return c.JSONError(50000, c.Tr("error.internal_api"))
 }
 
 func (c *Context) JSONError(errorCode int, message string) error {
@@ -143,14 +232,40 @@ func (c *Context) JSONError(errorCode int, message string) error {
 	if statusCode < 100 || statusCode > 999 {
 		statusCode = http.StatusInternalServerError
 	}
+	c.ResponseWriter().Header().Set("Content-Type", "application/json; charset=utf-8")
 	c.ResponseWriter().WriteHeader(statusCode)
 
 	return json.NewEncoder(c.ResponseWriter()).Encode(resp)
 }
 
+// Audit records the sensitive action to the audit trail with the IP address of the request.
+// The actor defaults to the logged user, the failure is logged without interrupting the request.
+func (c *Context) Audit(opts db.CreateAuditLogOptions) {
+	if opts.ActorUserID == 0 && c.IsLogged {
+		opts.ActorUserID = c.User.ID
+	}
+	opts.FromIP = c.RealIP()
+	if err := db.AuditLogs.Create(c.Request().Context(), opts); err != nil {
+		logrus.WithContext(c.Request().Context()).WithError(err).WithField("action", opts.Action).Error("Failed to create audit log")
+	}
+}
+
+// csrfExemptPaths are the webhooks called by the third-party services, which are
+// authenticated by their own secrets rather than the cookies, and the API login
+// endpoint which issues the bearer token without signing in the session.
+var csrfExemptPaths = map[string]struct{}{
+	"/mail/inbound":      {},
+	"/api/v1/auth/login": {},
+}
+
+// csrfExemptPrefixes are the prefixes of the endpoints which don't trust the cookies.
+// The activities posted to the ActivityPub endpoints are authenticated by the HTTP signatures,
+// and the questions sent from the embedded ask widgets are always anonymous.
+var csrfExemptPrefixes = []string{"/ap/", "/embed/"}
+
 // Contexter initializes a classic context for a request.
 func Contexter() flamego.Handler {
-	return func(ctx flamego.Context, data template.Data, session session.Session, x csrf.CSRF, t template.Template, flash session.Flash) {
+	return func(ctx flamego.Context, data template.Data, session session.Session, x csrf.CSRF, t template.Template, flash session.Flash, cpt *captcha.Captcha) {
 		c := Context{
 			Context:  ctx,
 			Data:     data,
@@ -158,12 +273,30 @@ func Contexter() flamego.Handler {
 			Template: t,
 		}
 
-		if ctx.Request().Method == http.MethodPost {
-			x.Validate(ctx)
+		// The API requests authenticated by the bearer token don't carry the cookies,
+		// so they are not affected by CSRF. The bearer token is ignored by the other
+		// endpoints, which always check the CSRF token.
+		switch ctx.Request().Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+			_, exempt := csrfExemptPaths[ctx.Request().URL.Path]
+			for _, prefix := range csrfExemptPrefixes {
+				exempt = exempt || strings.HasPrefix(ctx.Request().URL.Path, prefix)
+			}
+			exempt = exempt || (bearerToken(ctx.Request().Request) != "" && strings.HasPrefix(ctx.Request().URL.Path, "/api/"))
+			if !exempt {
+				x.Validate(ctx)
+			}
 		}
 
 		// Get user from session or header when possible
-		c.User = authenticatedUser(c.Context, c.Session)
+		c.User, c.AccessToken, c.UserSession = authenticatedUser(&c)
+		// The suspended and banned users are signed out, the access tokens of them are rejected.
+		if c.User != nil && c.User.IsRestricted() {
+			if c.UserSession != nil {
+				c.SignOut()
+			}
+			c.User, c.AccessToken, c.UserSession = nil, nil, nil
+		}
 
 		var userID uint
 		if c.User != nil {
@@ -172,6 +305,15 @@ func Contexter() flamego.Handler {
 			c.Data["LoggedUser"] = c.User
 			c.Data["LoggedUserID"] = c.User.ID
 			c.Data["LoggedUserName"] = c.User.Name
+			// The unread count is only queried when the navigation bar is rendered.
+			c.Data["UnreadCount"] = func() int64 {
+				count, err := db.Questions.Count(ctx.Request().Context(), c.User.ID, db.GetQuestionsCountOptions{FilterUnread: true, FilterArchived: db.ArchivedFilterExclude})
+				if err != nil {
+					logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to count unread questions")
+					return 0
+				}
+				return count
+			}
 
 			userID = c.User.ID
 		} else {
@@ -179,6 +321,14 @@ func Contexter() flamego.Handler {
 			c.Data["LoggedUserName"] = ""
 		}
 
+		// The language setting of the user takes precedence over the browser's preference.
+		var userLocale string
+		if c.User != nil {
+			userLocale = c.User.Locale
+		}
+		c.Locale = i18n.NewLocale(userLocale, ctx.Request().Header.Get("Accept-Language"))
+		c.Data["Lang"] = c.Locale.Lang()
+
 		span := trace.SpanFromContext(ctx.Request().Context())
 		if span.IsRecording() {
 			span.SetAttributes(
@@ -191,6 +341,9 @@ func Contexter() flamego.Handler {
 		if flash != nil {
 			flash, ok := flash.(Flash)
 			if ok {
+				if flash.FlashTip != "" {
+					c.Data["FlashTip"] = flash.FlashTip
+				}
 				switch flash.Type {
 				case "success":
 					c.Data["Success"] = flash.Message
@@ -209,8 +362,10 @@ func Contexter() flamego.Handler {
 		c.Data["CSRFToken"] = x.Token()
 		c.Data["CSRFTokenHTML"] = templatepkg.Safe(`<input type="hidden" name="_csrf" value="` + x.Token() + `">`)
 
-		c.Data["RecaptchaDomain"] = conf.Recaptcha.Domain
-		c.Data["RecaptchaSiteKey"] = conf.Recaptcha.SiteKey
+		c.Data["CaptchaProvider"] = string(cpt.Provider())
+		c.Data["CaptchaScriptURL"] = cpt.ScriptURL()
+		c.Data["CaptchaWidgetClass"] = cpt.WidgetClass()
+		c.Data["CaptchaSiteKey"] = conf.Recaptcha.SiteKey
 		c.Data["CurrentURI"] = ctx.Request().Request.RequestURI
 
 		// 🚨 SECURITY: Prevent MIME type sniffing in some browsers,
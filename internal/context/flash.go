@@ -5,7 +5,6 @@
 package context
 
 import (
-	"fmt"
 	"strings"
 
 	"go.opentelemetry.io/otel/trace"
@@ -46,5 +45,5 @@ func (c Context) SetInternalErrorFlash() {
 	span := trace.SpanFromContext(c.Request().Context())
 	traceID := span.SpanContext().TraceID()
 
-	c.Session.SetFlash(Flash{Type: Error, Message: "服务内部错误，请稍后重试。", FlashTip: fmt.Sprintf("若问题一直出现，请带上该段字符 %s 提交反馈。", traceID.String())})
+	c.Session.SetFlash(Flash{Type: Error, Message: c.Tr("error.internal"), FlashTip: c.Tr("error.internal_tip", map[string]interface{}{"TraceID": traceID.String()})})
 }
@@ -0,0 +1,56 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cron
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/conf"
+	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/push"
+)
+
+// awardAchievements awards the achievements to the users who have reached the milestones,
+// the users are notified of the new achievements.
+func awardAchievements(ctx context.Context) error {
+	now := time.Now()
+	for _, kind := range db.AchievementKinds {
+		userIDs, err := db.Achievements.ListEligible(ctx, kind, now)
+		if err != nil {
+			return errors.Wrapf(err, "list eligible users of %q", kind)
+		}
+
+		for _, userID := range userIDs {
+			awarded, err := db.Achievements.Award(ctx, userID, kind)
+			if err != nil {
+				logrus.WithContext(ctx).WithError(err).WithField("user_id", userID).WithField("kind", kind).Error("Failed to award achievement")
+				continue
+			}
+			if awarded {
+				notifyAchievement(ctx, userID, kind)
+			}
+		}
+	}
+	return nil
+}
+
+func notifyAchievement(ctx context.Context, userID uint, kind db.AchievementKind) {
+	user, err := db.Users.GetByID(ctx, userID)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).WithField("user_id", userID).Error("Failed to get user by ID")
+		return
+	}
+
+	push.Notify(ctx, user.ID, push.Notification{
+		Title: fmt.Sprintf("🎉 你获得了新成就「%s」", kind.Title()),
+		Body:  kind.Description(),
+		URL:   fmt.Sprintf("%s/_/%s", conf.App.ExternalURL, user.Domain),
+	})
+}
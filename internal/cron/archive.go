@@ -0,0 +1,37 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cron
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/db"
+)
+
+// archiveUnansweredQuestions archives the stale unanswered questions of the users who have enabled the auto-archival.
+func archiveUnansweredQuestions(ctx context.Context) error {
+	users, err := db.Users.ListAutoArchiveEnabled(ctx)
+	if err != nil {
+		return errors.Wrap(err, "list auto-archive enabled users")
+	}
+
+	now := time.Now()
+	for _, user := range users {
+		count, err := db.Questions.ArchiveUnanswered(ctx, user.ID, now.AddDate(0, 0, -user.BoxSettings.AutoArchiveDays))
+		if err != nil {
+			logrus.WithContext(ctx).WithError(err).WithField("user_id", user.ID).Error("Failed to archive unanswered questions")
+			continue
+		}
+
+		if count > 0 {
+			logrus.WithContext(ctx).WithField("user_id", user.ID).WithField("count", count).Info("Archived unanswered questions")
+		}
+	}
+	return nil
+}
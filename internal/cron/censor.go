@@ -0,0 +1,62 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cron
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/conf"
+	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/security/censor"
+)
+
+// maxCensorRedrives is the number of times a question is re-driven before it is given up,
+// so the text which keeps failing in the censor service does not take up the whole batch.
+const maxCensorRedrives = 5
+
+// redrivePendingCensor puts the questions which have not been censored back to the censor queue.
+func redrivePendingCensor(ctx context.Context) error {
+	if !conf.Security.EnableTextCensor {
+		return nil
+	}
+
+	questions, err := db.Questions.ListPendingCensor(ctx, db.ListPendingCensorOptions{
+		CreatedBefore:   time.Now().Add(-10 * time.Minute),
+		MaxRedriveCount: maxCensorRedrives,
+		Limit:           100,
+	})
+	if err != nil {
+		return errors.Wrap(err, "list pending censor questions")
+	}
+
+	var count int
+	ids := make([]uint, 0, len(questions))
+	for _, question := range questions {
+		ids = append(ids, question.ID)
+		if len(question.ContentCensorMetadata) == 0 {
+			if censor.Enqueue(ctx, censor.Job{Type: censor.JobTypeQuestionContent, ID: question.ID, Text: question.Content}) {
+				count++
+			}
+		}
+		if question.Answer != "" && len(question.AnswerCensorMetadata) == 0 {
+			if censor.Enqueue(ctx, censor.Job{Type: censor.JobTypeQuestionAnswer, ID: question.ID, Text: question.Answer}) {
+				count++
+			}
+		}
+	}
+
+	if err := db.Questions.IncreaseCensorRedriveCount(ctx, ids...); err != nil {
+		return errors.Wrap(err, "increase censor redrive count")
+	}
+
+	if count > 0 {
+		logrus.WithContext(ctx).WithField("count", count).Info("Re-drove pending censor jobs")
+	}
+	return nil
+}
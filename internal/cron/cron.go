@@ -0,0 +1,168 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cron
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/db"
+)
+
+// Job is a task which runs periodically in the background.
+type Job struct {
+	Name string
+	// Schedule is the cron expression in the local time, e.g. `0 4 * * *`. The job runs
+	// at the start and then every Interval if the schedule is empty.
+	Schedule string
+	Interval time.Duration
+	// Local jobs update the files or the memory of the instance, they run on every instance without the lock.
+	Local bool
+	Run   func(ctx context.Context) error
+}
+
+var (
+	jobsMu sync.RWMutex
+	jobs   = []Job{
+		{Name: "purge-trashed-questions", Interval: time.Hour, Run: purgeTrashedQuestions},
+		{Name: "purge-sent-mails", Interval: time.Hour, Run: purgeSentMails},
+		{Name: "purge-undelivered-mails", Interval: time.Hour, Run: purgeUndeliveredMails},
+		{Name: "purge-inactive-sessions", Interval: time.Hour, Run: purgeInactiveSessions},
+		{Name: "purge-login-attempts", Interval: time.Hour, Run: purgeLoginAttempts},
+		{Name: "purge-expired-login-links", Interval: time.Hour, Run: purgeExpiredLoginLinks},
+		{Name: "purge-expired-email-changes", Interval: time.Hour, Run: purgeExpiredEmailChanges},
+		{Name: "purge-cron-runs", Schedule: "30 3 * * *", Run: purgeCronRuns},
+		{Name: "expire-asker-ips", Interval: time.Hour, Run: expireAskerIPs},
+		{Name: "redrive-pending-censor", Interval: 10 * time.Minute, Run: redrivePendingCensor},
+		{Name: "archive-unanswered-questions", Interval: time.Hour, Run: archiveUnansweredQuestions},
+		{Name: "send-question-digests", Interval: 5 * time.Minute, Run: sendQuestionDigests},
+		{Name: "send-answer-reminders", Interval: time.Hour, Run: sendAnswerReminders},
+		{Name: "award-achievements", Schedule: "0 10 * * *", Run: awardAchievements},
+		{Name: "generate-sitemap", Interval: 24 * time.Hour, Local: true, Run: generateSitemap},
+		{Name: "refresh-disposable-email-domains", Interval: 24 * time.Hour, Local: true, Run: refreshDisposableEmailDomains},
+	}
+)
+
+// Register adds the job to the registry, it should be called before Start.
+func Register(job Job) {
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+	jobs = append(jobs, job)
+}
+
+// Jobs returns all the registered jobs.
+func Jobs() []Job {
+	jobsMu.RLock()
+	defer jobsMu.RUnlock()
+	return append([]Job(nil), jobs...)
+}
+
+// holder identifies this instance in the locks and the run history of the jobs.
+var holder = func() string {
+	hostname, _ := os.Hostname()
+	return fmt.Sprintf("%s:%d", hostname, os.Getpid())
+}()
+
+// Start starts all the jobs in the background, the jobs stop when the context is done.
+// Each run of the job takes the lock of the job first unless the job is local, so the job
+// runs once in each period when there are multiple instances.
+func Start(ctx context.Context) {
+	for _, job := range Jobs() {
+		var sched *schedule
+		if job.Schedule != "" {
+			var err error
+			sched, err = parseSchedule(job.Schedule)
+			if err != nil {
+				logrus.WithContext(ctx).WithError(err).WithField("job", job.Name).Error("Failed to parse cron job schedule")
+				continue
+			}
+		} else if job.Interval <= 0 {
+			logrus.WithContext(ctx).WithField("job", job.Name).Error("Cron job has neither schedule nor interval")
+			continue
+		}
+
+		go run(ctx, job, sched)
+	}
+}
+
+func run(ctx context.Context, job Job, sched *schedule) {
+	next := time.Now()
+	if sched != nil {
+		next = sched.next(next)
+	}
+
+	for !next.IsZero() {
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		var following time.Time
+		if sched != nil {
+			following = sched.next(next)
+		} else {
+			following = next.Add(job.Interval)
+		}
+
+		runOnce(ctx, job, next, following)
+
+		// The missed runs of the scheduled job are skipped if the run takes too long.
+		if sched != nil && following.Before(time.Now()) {
+			following = sched.next(time.Now())
+		}
+		next = following
+	}
+	logrus.WithContext(ctx).WithField("job", job.Name).Warn("Cron job has no next run")
+}
+
+// runOnce runs the job if the lock of the job is taken or the job is local, the lock is held until a while
+// before the following run, so the instances with a slightly different clock don't run the job again.
+func runOnce(ctx context.Context, job Job, current, following time.Time) {
+	logger := logrus.WithContext(ctx).WithField("job", job.Name)
+
+	if !job.Local {
+		lockedUntil := following
+		if !following.IsZero() {
+			slack := following.Sub(current) / 10
+			if slack > time.Minute {
+				slack = time.Minute
+			}
+			lockedUntil = following.Add(-slack)
+		}
+
+		locked, err := db.CronRuns.Lock(ctx, job.Name, holder, lockedUntil)
+		if err != nil {
+			logger.WithError(err).Error("Failed to lock cron job")
+			return
+		}
+		if !locked {
+			return
+		}
+	}
+
+	record, err := db.CronRuns.Start(ctx, job.Name, holder)
+	if err != nil {
+		logger.WithError(err).Error("Failed to record cron job run")
+	}
+
+	runErr := job.Run(ctx)
+	if runErr != nil {
+		logger.WithError(runErr).Error("Failed to run cron job")
+	}
+
+	if record != nil {
+		if err := db.CronRuns.Finish(ctx, record.ID, runErr); err != nil {
+			logger.WithError(err).Error("Failed to finish cron job run")
+		}
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cron
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/mail"
+)
+
+// maxDigestQuestions is the max number of questions in one digest mail,
+// the rest questions are sent in the next digest.
+const maxDigestQuestions = 20
+
+// sendQuestionDigests batches the unanswered questions received since the last digest
+// into a single mail for the users who prefer the hourly or daily notifications.
+func sendQuestionDigests(ctx context.Context) error {
+	for _, frequency := range []db.DigestFrequency{db.DigestFrequencyHourly, db.DigestFrequencyDaily} {
+		now := time.Now()
+		users, err := db.Users.ListDigestSubscribers(ctx, frequency, now.Add(-frequency.Interval()))
+		if err != nil {
+			return errors.Wrap(err, "list digest subscribers")
+		}
+
+		for _, user := range users {
+			if err := sendQuestionDigest(ctx, user, now); err != nil {
+				logrus.WithContext(ctx).WithError(err).WithField("user_id", user.ID).Error("Failed to send question digest")
+			}
+		}
+	}
+	return nil
+}
+
+func sendQuestionDigest(ctx context.Context, user *db.User, now time.Time) error {
+	questions, err := db.Questions.ListUnansweredAfter(ctx, user.ID, user.DigestWatermark, maxDigestQuestions)
+	if err != nil {
+		return errors.Wrap(err, "list unanswered questions")
+	}
+	// Nothing new, the next question will be sent in the next run.
+	if len(questions) == 0 {
+		return nil
+	}
+
+	digestQuestions := make([]mail.DigestQuestion, 0, len(questions))
+	for _, question := range questions {
+		digestQuestions = append(digestQuestions, mail.DigestQuestion{ID: question.ID, Content: question.Content})
+	}
+	if err := mail.SendNewQuestionDigestMail(ctx, user.Email, user.Domain, digestQuestions); err != nil {
+		return errors.Wrap(err, "send digest mail")
+	}
+
+	watermark := questions[len(questions)-1].ID
+	if err := db.Users.UpdateDigestWatermark(ctx, user.ID, watermark, now); err != nil {
+		return errors.Wrap(err, "update digest watermark")
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cron
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/conf"
+	"github.com/NekoWheel/NekoBox/internal/security/disposable"
+)
+
+// refreshDisposableEmailDomains downloads the latest disposable email domains, the bundled list
+// is used until the first refresh succeeds.
+func refreshDisposableEmailDomains(ctx context.Context) error {
+	if !conf.Security.DisposableEmailCheck || conf.Security.DisposableEmailListURL == "" {
+		return nil
+	}
+
+	count, err := disposable.Refresh(ctx)
+	if err != nil {
+		return errors.Wrap(err, "refresh disposable email domains")
+	}
+	logrus.WithContext(ctx).WithField("count", count).Info("Refreshed disposable email domains")
+	return nil
+}
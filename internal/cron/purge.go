@@ -0,0 +1,176 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cron
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/conf"
+	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/storage"
+)
+
+// purgeTrashedQuestions permanently deletes the questions which have been in the trash for too long,
+// along with the voice notes of their answers.
+func purgeTrashedQuestions(ctx context.Context) error {
+	count, audioURLs, err := db.Questions.PurgeTrashed(ctx, time.Now().Add(-db.QuestionTrashRetention))
+	if err != nil {
+		return errors.Wrap(err, "purge trashed questions")
+	}
+
+	for _, audioURL := range audioURLs {
+		if err := storage.DeleteAudioFromOSS(audioURL); err != nil {
+			logrus.WithContext(ctx).WithError(err).WithField("url", audioURL).Error("Failed to delete answer audio of the purged question")
+		}
+	}
+
+	if count > 0 {
+		logrus.WithContext(ctx).WithField("count", count).Info("Purged trashed questions")
+	}
+	return nil
+}
+
+// sentMailRetention is how long the delivered mails are kept in the outbox.
+const sentMailRetention = 7 * 24 * time.Hour
+
+// purgeSentMails permanently deletes the delivered mails from the outbox.
+func purgeSentMails(ctx context.Context) error {
+	count, err := db.MailOutbox.PurgeSent(ctx, time.Now().Add(-sentMailRetention))
+	if err != nil {
+		return errors.Wrap(err, "purge sent mails")
+	}
+
+	if count > 0 {
+		logrus.WithContext(ctx).WithField("count", count).Info("Purged sent mails")
+	}
+	return nil
+}
+
+// undeliveredMailRetention is how long the failed and bounced mails are kept in the outbox,
+// which is longer than the delivered ones to diagnose the lost notification mails.
+const undeliveredMailRetention = 30 * 24 * time.Hour
+
+// purgeUndeliveredMails permanently deletes the failed and bounced mails from the outbox.
+func purgeUndeliveredMails(ctx context.Context) error {
+	count, err := db.MailOutbox.PurgeUndelivered(ctx, time.Now().Add(-undeliveredMailRetention))
+	if err != nil {
+		return errors.Wrap(err, "purge undelivered mails")
+	}
+
+	if count > 0 {
+		logrus.WithContext(ctx).WithField("count", count).Info("Purged undelivered mails")
+	}
+	return nil
+}
+
+// purgeInactiveSessions deletes the sessions whose session cookies have expired.
+func purgeInactiveSessions(ctx context.Context) error {
+	count, err := db.UserSessions.DeleteInactive(ctx, time.Now().Add(-db.UserSessionLifetime))
+	if err != nil {
+		return errors.Wrap(err, "delete inactive sessions")
+	}
+
+	if count > 0 {
+		logrus.WithContext(ctx).WithField("count", count).Info("Purged inactive sessions")
+	}
+	return nil
+}
+
+// loginAttemptRetention is how long the failed logins are kept to be shown to the users.
+const loginAttemptRetention = 30 * 24 * time.Hour
+
+// purgeLoginAttempts deletes the failed logins older than the retention.
+func purgeLoginAttempts(ctx context.Context) error {
+	count, err := db.LoginAttempts.DeleteBefore(ctx, time.Now().Add(-loginAttemptRetention))
+	if err != nil {
+		return errors.Wrap(err, "delete login attempts")
+	}
+
+	if count > 0 {
+		logrus.WithContext(ctx).WithField("count", count).Info("Purged login attempts")
+	}
+	return nil
+}
+
+// purgeExpiredLoginLinks deletes the login links which have expired for a day,
+// the used links are kept for a while to tell the users the link has been used.
+func purgeExpiredLoginLinks(ctx context.Context) error {
+	count, err := db.LoginLinks.DeleteExpired(ctx, time.Now().Add(-24*time.Hour))
+	if err != nil {
+		return errors.Wrap(err, "delete expired login links")
+	}
+
+	if count > 0 {
+		logrus.WithContext(ctx).WithField("count", count).Info("Purged expired login links")
+	}
+	return nil
+}
+
+// purgeExpiredEmailChanges deletes the email changes which have expired for a week,
+// the finished changes are kept for a while to tell the users the link has been used.
+func purgeExpiredEmailChanges(ctx context.Context) error {
+	count, err := db.EmailChanges.DeleteExpired(ctx, time.Now().Add(-7*24*time.Hour))
+	if err != nil {
+		return errors.Wrap(err, "delete expired email changes")
+	}
+
+	if count > 0 {
+		logrus.WithContext(ctx).WithField("count", count).Info("Purged expired email changes")
+	}
+	return nil
+}
+
+// expireAskerIPs hashes or clears the raw IP addresses of the askers and the audit logs which are older than the retention.
+func expireAskerIPs(ctx context.Context) error {
+	if conf.Security.IPRetentionDays <= 0 {
+		return nil
+	}
+
+	opts := db.ExpireIPsOptions{
+		CreatedBefore: time.Now().AddDate(0, 0, -conf.Security.IPRetentionDays),
+		Clear:         conf.Security.IPRetentionAction == "clear",
+	}
+	questions, err := db.Questions.ExpireIPs(ctx, opts)
+	if err != nil {
+		return errors.Wrap(err, "expire IP addresses of questions")
+	}
+	replies, err := db.QuestionReplies.ExpireIPs(ctx, opts)
+	if err != nil {
+		return errors.Wrap(err, "expire IP addresses of replies")
+	}
+	auditLogs, err := db.AuditLogs.ExpireIPs(ctx, opts)
+	if err != nil {
+		return errors.Wrap(err, "expire IP addresses of audit logs")
+	}
+
+	if questions > 0 || replies > 0 || auditLogs > 0 {
+		logrus.WithContext(ctx).
+			WithField("questions", questions).
+			WithField("replies", replies).
+			WithField("audit_logs", auditLogs).
+			Info("Expired IP addresses")
+	}
+	return nil
+}
+
+// cronRunRetention is how long the run history of the cron jobs is kept.
+const cronRunRetention = 30 * 24 * time.Hour
+
+// purgeCronRuns deletes the run history of the cron jobs older than the retention.
+func purgeCronRuns(ctx context.Context) error {
+	count, err := db.CronRuns.Purge(ctx, time.Now().Add(-cronRunRetention))
+	if err != nil {
+		return errors.Wrap(err, "purge cron runs")
+	}
+
+	if count > 0 {
+		logrus.WithContext(ctx).WithField("count", count).Info("Purged cron runs")
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cron
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/conf"
+	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/mail"
+	"github.com/NekoWheel/NekoBox/internal/push"
+)
+
+// maxReminderQuestions is the max number of questions listed in one reminder mail.
+const maxReminderQuestions = 20
+
+// sendAnswerReminders reminds the users who have enabled the reminders of the questions which have been
+// unanswered for the configured days, each question is reminded only once.
+func sendAnswerReminders(ctx context.Context) error {
+	users, err := db.Users.ListReminderEnabled(ctx)
+	if err != nil {
+		return errors.Wrap(err, "list reminder enabled users")
+	}
+
+	now := time.Now()
+	for _, user := range users {
+		if err := sendAnswerReminder(ctx, user, now); err != nil {
+			logrus.WithContext(ctx).WithError(err).WithField("user_id", user.ID).Error("Failed to send answer reminder")
+		}
+	}
+	return nil
+}
+
+func sendAnswerReminder(ctx context.Context, user *db.User, now time.Time) error {
+	days := user.NotificationPreferences.ReminderDays
+	createdBefore := now.AddDate(0, 0, -days)
+
+	questions, err := db.Questions.ListUnreminded(ctx, user.ID, createdBefore, maxReminderQuestions)
+	if err != nil {
+		return errors.Wrap(err, "list unreminded questions")
+	}
+	if len(questions) == 0 {
+		return nil
+	}
+
+	reminderQuestions := make([]mail.DigestQuestion, 0, len(questions))
+	for _, question := range questions {
+		reminderQuestions = append(reminderQuestions, mail.DigestQuestion{ID: question.ID, Content: question.Content})
+	}
+	if err := mail.SendAnswerReminderMail(ctx, user.Email, user.Domain, days, reminderQuestions); err != nil {
+		return errors.Wrap(err, "send reminder mail")
+	}
+
+	// The questions beyond the mail are marked as well, they can be found in the inbox.
+	count, err := db.Questions.MarkReminded(ctx, user.ID, createdBefore)
+	if err != nil {
+		return errors.Wrap(err, "mark questions reminded")
+	}
+
+	push.Notify(ctx, user.ID, push.Notification{
+		Title: fmt.Sprintf("你有 %d 个提问已超过 %d 天未回答", count, days),
+		Body:  questions[0].Content,
+		URL:   fmt.Sprintf("%s/_/%s/%d", conf.App.ExternalURL, user.Domain, questions[0].ID),
+	})
+	return nil
+}
@@ -0,0 +1,134 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cron
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// schedule is the parsed cron expression of the five fields: minute, hour, day of month, month
+// and day of week. Each field is a bit set of the matched values.
+type schedule struct {
+	minute, hour, dom, month, dow uint64
+	// domAny and dowAny are set if the day of month or the day of week is `*`. The day matches
+	// either of them if both are restricted, which is the behaviour of the standard cron.
+	domAny, dowAny bool
+}
+
+// parseSchedule parses the cron expression, each field supports `*`, the values, the ranges `a-b`,
+// the steps `*/n` or `a-b/n` and the lists separated by commas. The day of week is 0-7, both 0 and 7 are Sunday.
+func parseSchedule(expr string) (*schedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, errors.Errorf("expected 5 fields but got %d", len(fields))
+	}
+
+	var s schedule
+	var err error
+	if s.minute, _, err = parseField(fields[0], 0, 59); err != nil {
+		return nil, errors.Wrap(err, "minute")
+	}
+	if s.hour, _, err = parseField(fields[1], 0, 23); err != nil {
+		return nil, errors.Wrap(err, "hour")
+	}
+	if s.dom, s.domAny, err = parseField(fields[2], 1, 31); err != nil {
+		return nil, errors.Wrap(err, "day of month")
+	}
+	if s.month, _, err = parseField(fields[3], 1, 12); err != nil {
+		return nil, errors.Wrap(err, "month")
+	}
+	if s.dow, s.dowAny, err = parseField(fields[4], 0, 7); err != nil {
+		return nil, errors.Wrap(err, "day of week")
+	}
+	// Sunday is both 0 and 7.
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	return &s, nil
+}
+
+// parseField returns the bit set of the values matched by the field, and whether the field is `*`.
+func parseField(field string, min, max int) (uint64, bool, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepPart)
+			if err != nil || step <= 0 {
+				return 0, false, errors.Errorf("invalid step %q", stepPart)
+			}
+		}
+
+		start, end := min, max
+		if rangePart != "*" {
+			startPart, endPart, isRange := strings.Cut(rangePart, "-")
+			var err error
+			start, err = strconv.Atoi(startPart)
+			if err != nil {
+				return 0, false, errors.Errorf("invalid value %q", startPart)
+			}
+			end = start
+			if isRange {
+				if end, err = strconv.Atoi(endPart); err != nil {
+					return 0, false, errors.Errorf("invalid value %q", endPart)
+				}
+			} else if hasStep {
+				// `a/n` starts from a to the max.
+				end = max
+			}
+		}
+		if start < min || end > max || start > end {
+			return 0, false, errors.Errorf("%q is out of range %d-%d", part, min, max)
+		}
+
+		for v := start; v <= end; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, field == "*", nil
+}
+
+// next returns the first time matched by the schedule after t, in the location of t.
+// It returns the zero time if nothing is matched in five years, e.g. February 30th.
+func (s *schedule) next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (s *schedule) matchDay(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domAny || s.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
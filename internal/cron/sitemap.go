@@ -0,0 +1,21 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cron
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/NekoWheel/NekoBox/internal/sitemap"
+)
+
+// generateSitemap regenerates the sitemap, so the newly answered questions are indexed by the search engines.
+func generateSitemap(ctx context.Context) error {
+	if err := sitemap.Generate(ctx); err != nil {
+		return errors.Wrap(err, "generate sitemap")
+	}
+	return nil
+}
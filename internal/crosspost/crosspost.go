@@ -0,0 +1,291 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package crosspost posts the summaries of the answers to the social accounts linked by the users,
+// the links are unfurled to the share cards of the questions by Twitter and Mastodon.
+package crosspost
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/conf"
+	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/security/censor"
+	"github.com/NekoWheel/NekoBox/internal/security/ssrf"
+)
+
+// Enabled returns whether the answers can be cross-posted to the provider.
+func Enabled(provider db.CrosspostProvider) bool {
+	switch provider {
+	case db.CrosspostProviderTwitter:
+		return conf.Crosspost.TwitterClientID != "" && conf.Crosspost.TwitterClientSecret != ""
+	case db.CrosspostProviderMastodon:
+		return true
+	default:
+		return false
+	}
+}
+
+// poster posts the content of the crosspost to the account, it returns the URL of the post.
+type poster func(ctx context.Context, account *db.CrosspostAccount, crosspost *db.Crosspost) (string, error)
+
+var posters = map[db.CrosspostProvider]poster{
+	db.CrosspostProviderTwitter:  postTweet,
+	db.CrosspostProviderMastodon: postToot,
+}
+
+// summaryLengths is the max length of the summary in runes, the link is appended after it.
+// The CJK characters are counted as two characters by Twitter.
+var summaryLengths = map[db.CrosspostProvider]int{
+	db.CrosspostProviderTwitter:  100,
+	db.CrosspostProviderMastodon: 400,
+}
+
+const (
+	requestTimeout = 30 * time.Second
+
+	pollInterval = 30 * time.Second
+	batchSize    = 20
+	maxAttempts  = 5
+	baseBackoff  = time.Minute
+	maxBackoff   = time.Hour
+	// claimLease is how long a claimed post is hidden from other workers,
+	// it will be retried after the lease if the posting is interrupted.
+	claimLease = 5 * time.Minute
+)
+
+// client sends the requests to Twitter and the Mastodon instances given by the users,
+// so the private addresses are denied.
+var client = &http.Client{
+	Timeout: requestTimeout,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: requestTimeout,
+			Control: ssrf.DenyPrivateAddress,
+		}).DialContext,
+	},
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// PermanentError is the error that the post is rejected by the provider, e.g. the token has been revoked.
+// The post is not retried.
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+func permanent(err error) error {
+	return &PermanentError{Err: err}
+}
+
+func isPermanent(err error) bool {
+	var permanentErr *PermanentError
+	return errors.As(err, &permanentErr)
+}
+
+// doRequest sends the API request and decodes the JSON response into v if it is not nil. The 4xx
+// responses except 429 Too Many Requests mean the request is rejected, which are not retried.
+func doRequest(req *http.Request, v interface{}) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "do request")
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		err := errors.Errorf("unexpected status code %d: %s", resp.StatusCode, body)
+		if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+			return permanent(err)
+		}
+		return err
+	}
+	if v == nil {
+		return nil
+	}
+	return errors.Wrap(json.NewDecoder(resp.Body).Decode(v), "decode response")
+}
+
+// compose returns the content of the post, which is the summary of the answered question followed by
+// the link of the question. The question takes at most a third of the summary, so the answer is always shown.
+func compose(provider db.CrosspostProvider, pageUser *db.User, question *db.Question) string {
+	link := fmt.Sprintf("%s/_/%s/%d", conf.App.ExternalURL, pageUser.Domain, question.ID)
+	content := truncate(strings.TrimSpace(censor.QuestionContent(pageUser, question)), summaryLengths[provider]/3)
+	answer := truncate(strings.TrimSpace(question.Answer), summaryLengths[provider]-len([]rune(content)))
+	return fmt.Sprintf("Q：%s\nA：%s\n\n%s", content, answer, link)
+}
+
+// truncate truncates the text to at most n runes with an ellipsis.
+func truncate(text string, n int) string {
+	if runes := []rune(text); len(runes) > n {
+		return string(runes[:n-1]) + "…"
+	}
+	return text
+}
+
+// Enqueue saves the answered question to be posted to the accounts, the posts are sent by the worker in the background.
+func Enqueue(ctx context.Context, pageUser *db.User, question *db.Question, accounts []*db.CrosspostAccount) {
+	enqueued := false
+	for _, account := range accounts {
+		if !Enabled(account.Provider) {
+			continue
+		}
+		if _, err := db.Crossposts.Create(ctx, db.CreateCrosspostOptions{
+			UserID:     account.UserID,
+			AccountID:  account.ID,
+			QuestionID: question.ID,
+			Content:    compose(account.Provider, pageUser, question),
+		}); err != nil {
+			logrus.WithContext(ctx).WithError(err).WithField("provider", account.Provider).Error("Failed to create crosspost")
+			continue
+		}
+		enqueued = true
+	}
+
+	if enqueued {
+		select {
+		case wakeup <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// wakeup notifies the worker to send the newly enqueued posts without waiting for the next poll.
+var wakeup = make(chan struct{}, 1)
+
+var workers sync.WaitGroup
+
+// Start starts the posting worker, the worker stops when the context is done.
+func Start(ctx context.Context) {
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		work(ctx)
+	}()
+}
+
+// Wait waits for the worker to finish the post being sent after the context is done.
+func Wait() {
+	workers.Wait()
+}
+
+func work(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := postDue(ctx); err != nil {
+			logrus.WithContext(ctx).WithError(err).Error("Failed to send crossposts")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-wakeup:
+		}
+	}
+}
+
+func postDue(ctx context.Context) error {
+	crossposts, err := db.Crossposts.ListDue(ctx, batchSize)
+	if err != nil {
+		return errors.Wrap(err, "list due crossposts")
+	}
+
+	// The claimed post is sent without the cancellation of the worker's context, so it is not
+	// interrupted on shutdown, otherwise the same answer may be posted twice after the lease.
+	postCtx := context.Background()
+	for _, crosspost := range crossposts {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		claimed, err := db.Crossposts.Claim(postCtx, crosspost.ID, claimLease)
+		if err != nil {
+			return errors.Wrap(err, "claim crosspost")
+		}
+		if !claimed {
+			continue
+		}
+		post(postCtx, crosspost)
+	}
+	return nil
+}
+
+func post(ctx context.Context, crosspost *db.Crosspost) {
+	logger := logrus.WithContext(ctx).
+		WithField("crosspost_id", crosspost.ID).
+		WithField("question_id", crosspost.QuestionID).
+		WithField("attempt", crosspost.Attempts+1)
+
+	postURL, err := send(ctx, crosspost)
+	if err == nil {
+		if err := db.Crossposts.MarkPosted(ctx, crosspost.ID, postURL); err != nil {
+			logger.WithError(err).Error("Failed to mark crosspost posted")
+		}
+		return
+	}
+
+	opts := db.MarkCrosspostFailedOptions{
+		Error:  err.Error(),
+		Status: db.CrosspostStatusPending,
+	}
+	switch {
+	case isPermanent(err):
+		opts.Status = db.CrosspostStatusFailed
+		logger.WithError(err).Warn("Crosspost rejected")
+	case crosspost.Attempts+1 >= maxAttempts:
+		opts.Status = db.CrosspostStatusFailed
+		logger.WithError(err).Error("Failed to send crosspost, give up")
+	default:
+		backoff := baseBackoff << crosspost.Attempts
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+		opts.NextAttemptAt = time.Now().Add(backoff)
+		logger.WithError(err).WithField("backoff", backoff.String()).Warn("Failed to send crosspost, retry later")
+	}
+
+	if err := db.Crossposts.MarkFailed(ctx, crosspost.ID, opts); err != nil {
+		logger.WithError(err).Error("Failed to mark crosspost failed")
+	}
+}
+
+func send(ctx context.Context, crosspost *db.Crosspost) (string, error) {
+	// The account may have been unlinked after the answer is published.
+	account, err := db.CrosspostAccounts.GetByID(ctx, crosspost.AccountID)
+	if err != nil {
+		if errors.Is(err, db.ErrCrosspostAccountNotExist) {
+			return "", permanent(err)
+		}
+		return "", errors.Wrap(err, "get crosspost account")
+	}
+
+	postTo, ok := posters[account.Provider]
+	if !ok || !Enabled(account.Provider) {
+		return "", permanent(errors.Errorf("provider %q is not available", account.Provider))
+	}
+	return postTo(ctx, account, crosspost)
+}
@@ -0,0 +1,91 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package crosspost
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/NekoWheel/NekoBox/internal/db"
+)
+
+var (
+	ErrInvalidMastodonInstance = errors.New("Mastodon 实例地址不正确")
+	ErrInvalidMastodonToken    = errors.New("Mastodon 访问令牌无效，请确认令牌拥有 write:statuses 和 read:accounts 权限")
+)
+
+// normalizeInstanceURL returns the base URL of the Mastodon instance, e.g. "https://mastodon.social".
+// The instance can be given with or without the scheme, only HTTPS is allowed.
+func normalizeInstanceURL(instance string) (string, error) {
+	instance = strings.TrimSpace(instance)
+	if !strings.Contains(instance, "://") {
+		instance = "https://" + instance
+	}
+	u, err := url.Parse(instance)
+	if err != nil || u.Scheme != "https" || u.Hostname() == "" {
+		return "", ErrInvalidMastodonInstance
+	}
+	return "https://" + strings.ToLower(u.Host), nil
+}
+
+// LinkMastodon verifies the access token created in the Mastodon settings, and links the account to the user.
+func LinkMastodon(ctx context.Context, userID uint, instance, accessToken string) (*db.CrosspostAccount, error) {
+	instanceURL, err := normalizeInstanceURL(instance)
+	if err != nil {
+		return nil, err
+	}
+	accessToken = strings.TrimSpace(accessToken)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, instanceURL+"/api/v1/accounts/verify_credentials", nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "new request")
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	var account struct {
+		Acct string `json:"acct"`
+	}
+	if err := doRequest(req, &account); err != nil {
+		if isPermanent(err) {
+			return nil, ErrInvalidMastodonToken
+		}
+		return nil, ErrInvalidMastodonInstance
+	}
+
+	return db.CrosspostAccounts.Save(ctx, db.SaveCrosspostAccountOptions{
+		UserID:      userID,
+		Provider:    db.CrosspostProviderMastodon,
+		InstanceURL: instanceURL,
+		Username:    account.Acct,
+		AccessToken: accessToken,
+	})
+}
+
+func postToot(ctx context.Context, account *db.CrosspostAccount, crosspost *db.Crosspost) (string, error) {
+	form := url.Values{
+		"status":     {crosspost.Content},
+		"visibility": {"public"},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, account.InstanceURL+"/api/v1/statuses", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", errors.Wrap(err, "new request")
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer "+account.AccessToken)
+	// The retries of the same post are deduplicated by the instance.
+	req.Header.Set("Idempotency-Key", fmt.Sprintf("nekobox-crosspost-%d", crosspost.ID))
+
+	var status struct {
+		URL string `json:"url"`
+	}
+	if err := doRequest(req, &status); err != nil {
+		return "", errors.Wrap(err, "create status")
+	}
+	return status.URL, nil
+}
@@ -0,0 +1,192 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package crosspost
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/thanhpk/randstr"
+
+	"github.com/NekoWheel/NekoBox/internal/conf"
+	"github.com/NekoWheel/NekoBox/internal/db"
+)
+
+const (
+	twitterAuthorizeURL = "https://twitter.com/i/oauth2/authorize"
+	twitterTokenURL     = "https://api.twitter.com/2/oauth2/token"
+	twitterMeURL        = "https://api.twitter.com/2/users/me"
+	twitterTweetsURL    = "https://api.twitter.com/2/tweets"
+
+	// twitterScopes allows posting the tweets, the offline access grants the refresh token.
+	twitterScopes = "tweet.read tweet.write users.read offline.access"
+	// tokenRefreshMargin refreshes the access token a little earlier before it expires.
+	tokenRefreshMargin = time.Minute
+)
+
+var ErrTwitterAuthorization = errors.New("Twitter 授权失败，请重试")
+
+func twitterRedirectURI() string {
+	return conf.App.ExternalURL + "/user/crosspost/twitter/callback"
+}
+
+// NewCodeVerifier returns the PKCE code verifier of the OAuth 2.0 authorization.
+func NewCodeVerifier() string {
+	return randstr.Hex(32)
+}
+
+// TwitterAuthorizeURL returns the URL which the user is redirected to for authorizing the Twitter app.
+func TwitterAuthorizeURL(state, codeVerifier string) string {
+	challenge := sha256.Sum256([]byte(codeVerifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {conf.Crosspost.TwitterClientID},
+		"redirect_uri":          {twitterRedirectURI()},
+		"scope":                 {twitterScopes},
+		"state":                 {state},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	return twitterAuthorizeURL + "?" + query.Encode()
+}
+
+type twitterToken struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+func (t *twitterToken) expiresAt() *time.Time {
+	if t.ExpiresIn <= 0 {
+		return nil
+	}
+	expiresAt := time.Now().Add(time.Duration(t.ExpiresIn) * time.Second)
+	return &expiresAt
+}
+
+// requestTwitterToken requests the token with the authorization code or the refresh token.
+func requestTwitterToken(ctx context.Context, form url.Values) (*twitterToken, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, twitterTokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, errors.Wrap(err, "new request")
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(conf.Crosspost.TwitterClientID, conf.Crosspost.TwitterClientSecret)
+
+	var token twitterToken
+	if err := doRequest(req, &token); err != nil {
+		return nil, err
+	}
+	if token.AccessToken == "" {
+		return nil, errors.New("empty access token")
+	}
+	return &token, nil
+}
+
+// LinkTwitter exchanges the authorization code for the tokens, and links the Twitter account to the user.
+func LinkTwitter(ctx context.Context, userID uint, code, codeVerifier string) (*db.CrosspostAccount, error) {
+	token, err := requestTwitterToken(ctx, url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {twitterRedirectURI()},
+		"code_verifier": {codeVerifier},
+	})
+	if err != nil {
+		if isPermanent(err) {
+			return nil, ErrTwitterAuthorization
+		}
+		return nil, errors.Wrap(err, "request token")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, twitterMeURL, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "new request")
+	}
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	var me struct {
+		Data struct {
+			Username string `json:"username"`
+		} `json:"data"`
+	}
+	if err := doRequest(req, &me); err != nil {
+		return nil, errors.Wrap(err, "get user")
+	}
+
+	return db.CrosspostAccounts.Save(ctx, db.SaveCrosspostAccountOptions{
+		UserID:       userID,
+		Provider:     db.CrosspostProviderTwitter,
+		Username:     me.Data.Username,
+		AccessToken:  token.AccessToken,
+		RefreshToken: token.RefreshToken,
+		ExpiresAt:    token.expiresAt(),
+	})
+}
+
+// twitterAccessToken returns the access token of the account, the expired token is refreshed.
+// The refresh token is rotated by Twitter, so the new one is saved as well.
+func twitterAccessToken(ctx context.Context, account *db.CrosspostAccount) (string, error) {
+	if account.ExpiresAt == nil || time.Now().Add(tokenRefreshMargin).Before(*account.ExpiresAt) {
+		return account.AccessToken, nil
+	}
+	if account.RefreshToken == "" {
+		return "", permanent(errors.New("access token expired"))
+	}
+
+	token, err := requestTwitterToken(ctx, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {account.RefreshToken},
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "refresh token")
+	}
+	if token.RefreshToken == "" {
+		token.RefreshToken = account.RefreshToken
+	}
+	if err := db.CrosspostAccounts.UpdateToken(ctx, account.ID, db.UpdateCrosspostTokenOptions{
+		AccessToken:  token.AccessToken,
+		RefreshToken: token.RefreshToken,
+		ExpiresAt:    token.expiresAt(),
+	}); err != nil {
+		return "", errors.Wrap(err, "update token")
+	}
+	return token.AccessToken, nil
+}
+
+func postTweet(ctx context.Context, account *db.CrosspostAccount, crosspost *db.Crosspost) (string, error) {
+	accessToken, err := twitterAccessToken(ctx, account)
+	if err != nil {
+		return "", errors.Wrap(err, "get access token")
+	}
+
+	body, err := json.Marshal(map[string]string{"text": crosspost.Content})
+	if err != nil {
+		return "", errors.Wrap(err, "marshal tweet")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, twitterTweetsURL, bytes.NewReader(body))
+	if err != nil {
+		return "", errors.Wrap(err, "new request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	var tweet struct {
+		Data struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := doRequest(req, &tweet); err != nil {
+		return "", errors.Wrap(err, "create tweet")
+	}
+	return fmt.Sprintf("https://twitter.com/%s/status/%s", account.Username, tweet.Data.ID), nil
+}
@@ -0,0 +1,195 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/thanhpk/randstr"
+	"gorm.io/gorm"
+
+	"github.com/NekoWheel/NekoBox/internal/dbutil"
+)
+
+var AccessTokens AccessTokensStore
+
+var _ AccessTokensStore = (*accessTokens)(nil)
+
+type AccessTokensStore interface {
+	Create(ctx context.Context, opts CreateAccessTokenOptions) (*AccessToken, string, error)
+	GetByToken(ctx context.Context, token string) (*AccessToken, error)
+	GetByUserID(ctx context.Context, userID uint) ([]*AccessToken, error)
+	Touch(ctx context.Context, id uint) error
+	DeleteByID(ctx context.Context, userID, id uint) error
+}
+
+func NewAccessTokensStore(db *gorm.DB) AccessTokensStore {
+	return &accessTokens{db}
+}
+
+type accessTokens struct {
+	*gorm.DB
+}
+
+type AccessTokenScope string
+
+const (
+	AccessTokenScopeReadQuestions AccessTokenScope = "read:questions"
+	AccessTokenScopeWriteAnswers  AccessTokenScope = "write:answers"
+)
+
+// AccessTokenScopes are all the scopes which can be granted to the access tokens.
+var AccessTokenScopes = []AccessTokenScope{
+	AccessTokenScopeReadQuestions,
+	AccessTokenScopeWriteAnswers,
+}
+
+// AccessTokenPrefix is the prefix of the personal access tokens, which tells them apart from the login tokens.
+const AccessTokenPrefix = "nbp_"
+
+// AccessToken is the personal access token used by the scripts to call the API.
+// Only the SHA-256 hash of the token is stored, the token itself is shown once on creation.
+type AccessToken struct {
+	dbutil.Model
+	UserID     uint       `gorm:"index:idx_access_token_user_id" json:"-"`
+	Name       string     `gorm:"type:varchar(50)" json:"name"`
+	TokenHash  string     `gorm:"type:varchar(64);uniqueIndex:idx_access_token_token_hash" json:"-"`
+	Scopes     string     `gorm:"type:varchar(255)" json:"scopes"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+// ScopeList returns the scopes granted to the token.
+func (t *AccessToken) ScopeList() []AccessTokenScope {
+	var scopes []AccessTokenScope
+	for _, scope := range strings.Split(t.Scopes, ",") {
+		if scope != "" {
+			scopes = append(scopes, AccessTokenScope(scope))
+		}
+	}
+	return scopes
+}
+
+// HasScope returns whether the scope is granted to the token.
+func (t *AccessToken) HasScope(scope AccessTokenScope) bool {
+	for _, s := range t.ScopeList() {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// MaxAccessTokensPerUser is the maximum number of the access tokens that a user can create.
+const MaxAccessTokensPerUser = 10
+
+// accessTokenTouchInterval is how often the last used time is updated, so that a busy script
+// doesn't write the database on every request.
+const accessTokenTouchInterval = time.Minute
+
+var (
+	ErrAccessTokenNotExist     = errors.New("访问令牌不存在")
+	ErrTooManyAccessTokens     = errors.New("最多只能创建 10 个访问令牌")
+	ErrInvalidAccessTokenScope = errors.New("访问令牌的权限范围不合法")
+)
+
+// hashAccessToken returns the hash of the token stored in the database.
+func hashAccessToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+type CreateAccessTokenOptions struct {
+	UserID uint
+	Name   string
+	Scopes []AccessTokenScope
+}
+
+// Create creates a new access token, it returns the token which can't be retrieved later.
+func (db *accessTokens) Create(ctx context.Context, opts CreateAccessTokenOptions) (*AccessToken, string, error) {
+	if len(opts.Scopes) == 0 {
+		return nil, "", ErrInvalidAccessTokenScope
+	}
+	scopes := make([]string, 0, len(opts.Scopes))
+	for _, scope := range opts.Scopes {
+		valid := false
+		for _, s := range AccessTokenScopes {
+			if scope == s {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return nil, "", ErrInvalidAccessTokenScope
+		}
+		scopes = append(scopes, string(scope))
+	}
+
+	var count int64
+	if err := db.WithContext(ctx).Model(&AccessToken{}).Where("user_id = ?", opts.UserID).Count(&count).Error; err != nil {
+		return nil, "", errors.Wrap(err, "count access tokens")
+	}
+	if count >= MaxAccessTokensPerUser {
+		return nil, "", ErrTooManyAccessTokens
+	}
+
+	token := AccessTokenPrefix + randstr.Hex(20)
+	accessToken := AccessToken{
+		UserID:    opts.UserID,
+		Name:      opts.Name,
+		TokenHash: hashAccessToken(token),
+		Scopes:    strings.Join(scopes, ","),
+	}
+	if err := db.WithContext(ctx).Create(&accessToken).Error; err != nil {
+		return nil, "", errors.Wrap(err, "create access token")
+	}
+	return &accessToken, token, nil
+}
+
+func (db *accessTokens) GetByToken(ctx context.Context, token string) (*AccessToken, error) {
+	var accessToken AccessToken
+	if err := db.WithContext(ctx).Where("token_hash = ?", hashAccessToken(token)).First(&accessToken).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAccessTokenNotExist
+		}
+		return nil, errors.Wrap(err, "get access token by token")
+	}
+	return &accessToken, nil
+}
+
+func (db *accessTokens) GetByUserID(ctx context.Context, userID uint) ([]*AccessToken, error) {
+	var accessTokens []*AccessToken
+	if err := db.WithContext(ctx).Where("user_id = ?", userID).Order("id ASC").Find(&accessTokens).Error; err != nil {
+		return nil, errors.Wrap(err, "get access tokens by user ID")
+	}
+	return accessTokens, nil
+}
+
+// Touch records the token is used now, it is skipped if the token has been used recently.
+func (db *accessTokens) Touch(ctx context.Context, id uint) error {
+	now := time.Now()
+	if err := db.WithContext(ctx).Model(&AccessToken{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", id, now.Add(-accessTokenTouchInterval)).
+		Update("last_used_at", now).Error; err != nil {
+		return errors.Wrap(err, "update last used at")
+	}
+	return nil
+}
+
+// DeleteByID revokes the access token, the token can't be used anymore.
+func (db *accessTokens) DeleteByID(ctx context.Context, userID, id uint) error {
+	result := db.WithContext(ctx).Unscoped().Where("user_id = ? AND id = ?", userID, id).Delete(&AccessToken{})
+	if result.Error != nil {
+		return errors.Wrap(result.Error, "delete access token")
+	}
+	if result.RowsAffected == 0 {
+		return ErrAccessTokenNotExist
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var Achievements AchievementsStore
+
+var _ AchievementsStore = (*achievements)(nil)
+
+type AchievementsStore interface {
+	// GetByUserID returns the achievements of the user in the order they are awarded.
+	GetByUserID(ctx context.Context, userID uint) ([]*Achievement, error)
+	// ListEligible returns the IDs of the users who have reached the achievement but haven't been awarded it,
+	// the users who have opted out of the achievements are excluded.
+	ListEligible(ctx context.Context, kind AchievementKind, now time.Time) ([]uint, error)
+	// Award awards the achievement to the user, it returns false if the user already has it.
+	Award(ctx context.Context, userID uint, kind AchievementKind) (bool, error)
+}
+
+func NewAchievementsStore(db *gorm.DB) AchievementsStore {
+	return &achievements{db}
+}
+
+// AchievementKind is the milestone of the box which is celebrated with an achievement.
+type AchievementKind string
+
+const (
+	AchievementKindFirstAnswer      AchievementKind = "first_answer"
+	AchievementKindHundredQuestions AchievementKind = "hundred_questions"
+	AchievementKindFirstAnniversary AchievementKind = "first_anniversary"
+)
+
+// AchievementKinds are all the achievements, which are checked by the scheduled job in order.
+var AchievementKinds = []AchievementKind{
+	AchievementKindFirstAnswer,
+	AchievementKindHundredQuestions,
+	AchievementKindFirstAnniversary,
+}
+
+// hundredQuestions is the number of the received questions of the AchievementKindHundredQuestions.
+const hundredQuestions = 100
+
+// Title returns the name of the achievement shown on the box page.
+func (k AchievementKind) Title() string {
+	switch k {
+	case AchievementKindFirstAnswer:
+		return "🌱 第一次回答"
+	case AchievementKindHundredQuestions:
+		return "💯 收到 100 个提问"
+	case AchievementKindFirstAnniversary:
+		return "🎂 提问箱一周年"
+	}
+	return string(k)
+}
+
+// Description returns how the achievement is reached.
+func (k AchievementKind) Description() string {
+	switch k {
+	case AchievementKindFirstAnswer:
+		return "回答了提问箱中的第一个提问"
+	case AchievementKindHundredQuestions:
+		return "提问箱累计收到了 100 个提问"
+	case AchievementKindFirstAnniversary:
+		return "提问箱已经开设一年了"
+	}
+	return ""
+}
+
+// Achievement is the milestone reached by the user, each achievement is awarded only once.
+type Achievement struct {
+	ID uint `gorm:"primarykey"`
+	// CreatedAt is when the achievement is awarded.
+	CreatedAt time.Time
+	UserID    uint            `gorm:"uniqueIndex:idx_achievement_user_kind"`
+	Kind      AchievementKind `gorm:"type:varchar(32);uniqueIndex:idx_achievement_user_kind"`
+}
+
+type achievements struct {
+	*gorm.DB
+}
+
+func (db *achievements) GetByUserID(ctx context.Context, userID uint) ([]*Achievement, error) {
+	var achievements []*Achievement
+	if err := db.WithContext(ctx).Where("user_id = ?", userID).Order("id ASC").Find(&achievements).Error; err != nil {
+		return nil, errors.Wrap(err, "get achievements")
+	}
+	return achievements, nil
+}
+
+func (db *achievements) ListEligible(ctx context.Context, kind AchievementKind, now time.Time) ([]uint, error) {
+	tx := db.WithContext(ctx)
+	awarded := tx.Model(&Achievement{}).Select("user_id").Where("kind = ?", kind)
+	// The key is omitted unless the user has opted out.
+	optedOut := tx.Model(&User{}).Select("id").Where(jsonExtractText(db.DB, "box_settings", "disable_achievements") + " IS NOT NULL")
+
+	var userIDs []uint
+	var err error
+	switch kind {
+	case AchievementKindFirstAnswer:
+		err = tx.Model(&Question{}).
+			Where("answer <> '' AND user_id NOT IN (?) AND user_id NOT IN (?)", awarded, optedOut).
+			Distinct().Pluck("user_id", &userIDs).Error
+	case AchievementKindHundredQuestions:
+		err = tx.Model(&Question{}).Select("user_id").
+			Where("user_id NOT IN (?) AND user_id NOT IN (?)", awarded, optedOut).
+			Group("user_id").Having("COUNT(*) >= ?", hundredQuestions).
+			Scan(&userIDs).Error
+	case AchievementKindFirstAnniversary:
+		err = tx.Model(&User{}).
+			Where("created_at <= ? AND id NOT IN (?) AND id NOT IN (?)", now.AddDate(-1, 0, 0), awarded, optedOut).
+			Pluck("id", &userIDs).Error
+	default:
+		return nil, errors.Errorf("unknown achievement kind %q", kind)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "list eligible users")
+	}
+	return userIDs, nil
+}
+
+func (db *achievements) Award(ctx context.Context, userID uint, kind AchievementKind) (bool, error) {
+	result := db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&Achievement{
+		UserID: userID,
+		Kind:   kind,
+	})
+	if result.Error != nil {
+		return false, errors.Wrap(result.Error, "create achievement")
+	}
+	return result.RowsAffected > 0, nil
+}
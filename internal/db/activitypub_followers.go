@@ -0,0 +1,112 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+
+	"github.com/NekoWheel/NekoBox/internal/dbutil"
+)
+
+var ActivityPubFollowers ActivityPubFollowersStore
+
+var _ ActivityPubFollowersStore = (*activityPubFollowers)(nil)
+
+type ActivityPubFollowersStore interface {
+	Create(ctx context.Context, opts CreateActivityPubFollowerOptions) error
+	GetByUserID(ctx context.Context, userID uint) ([]*ActivityPubFollower, error)
+	CountByUserID(ctx context.Context, userID uint) (int64, error)
+	DeleteByActorID(ctx context.Context, userID uint, actorID string) error
+}
+
+func NewActivityPubFollowersStore(db *gorm.DB) ActivityPubFollowersStore {
+	return &activityPubFollowers{db}
+}
+
+type activityPubFollowers struct {
+	*gorm.DB
+}
+
+// ActivityPubFollower is the remote actor on the fediverse who follows the user's box,
+// the answered questions are delivered to the inbox of the follower.
+type ActivityPubFollower struct {
+	dbutil.Model
+	UserID  uint   `gorm:"uniqueIndex:idx_activitypub_follower_user_actor" json:"-"`
+	ActorID string `gorm:"type:varchar(512);uniqueIndex:idx_activitypub_follower_user_actor" json:"actor_id"`
+	Inbox   string `gorm:"type:varchar(512)" json:"-"`
+	// SharedInbox receives the activity once for all the followers on the same server, it is empty
+	// if the server of the follower doesn't support it.
+	SharedInbox string `gorm:"type:varchar(512)" json:"-"`
+}
+
+var ErrActivityPubFollowerNotExist = errors.New("关注者不存在")
+
+type CreateActivityPubFollowerOptions struct {
+	UserID      uint
+	ActorID     string
+	Inbox       string
+	SharedInbox string
+}
+
+// Create saves the follower of the user, the inboxes are updated if the actor has followed the user,
+// which happens when the remote server resends the Follow activity.
+func (db *activityPubFollowers) Create(ctx context.Context, opts CreateActivityPubFollowerOptions) error {
+	ctx = WithPrimary(ctx)
+
+	var follower ActivityPubFollower
+	err := db.WithContext(ctx).Where("user_id = ? AND actor_id = ?", opts.UserID, opts.ActorID).First(&follower).Error
+	if err == nil {
+		if err := db.WithContext(ctx).Model(&follower).Updates(map[string]interface{}{
+			"inbox":        opts.Inbox,
+			"shared_inbox": opts.SharedInbox,
+		}).Error; err != nil {
+			return errors.Wrap(err, "update activitypub follower")
+		}
+		return nil
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return errors.Wrap(err, "get activitypub follower")
+	}
+
+	if err := db.WithContext(ctx).Create(&ActivityPubFollower{
+		UserID:      opts.UserID,
+		ActorID:     opts.ActorID,
+		Inbox:       opts.Inbox,
+		SharedInbox: opts.SharedInbox,
+	}).Error; err != nil {
+		return errors.Wrap(err, "create activitypub follower")
+	}
+	return nil
+}
+
+func (db *activityPubFollowers) GetByUserID(ctx context.Context, userID uint) ([]*ActivityPubFollower, error) {
+	var followers []*ActivityPubFollower
+	if err := db.WithContext(ctx).Where("user_id = ?", userID).Order("id ASC").Find(&followers).Error; err != nil {
+		return nil, errors.Wrap(err, "get activitypub followers by user ID")
+	}
+	return followers, nil
+}
+
+func (db *activityPubFollowers) CountByUserID(ctx context.Context, userID uint) (int64, error) {
+	var count int64
+	if err := db.WithContext(ctx).Model(&ActivityPubFollower{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+		return 0, errors.Wrap(err, "count activitypub followers")
+	}
+	return count, nil
+}
+
+// DeleteByActorID removes the follower when the remote actor undoes the Follow activity.
+func (db *activityPubFollowers) DeleteByActorID(ctx context.Context, userID uint, actorID string) error {
+	result := db.WithContext(ctx).Unscoped().Where("user_id = ? AND actor_id = ?", userID, actorID).Delete(&ActivityPubFollower{})
+	if result.Error != nil {
+		return errors.Wrap(result.Error, "delete activitypub follower")
+	}
+	if result.RowsAffected == 0 {
+		return ErrActivityPubFollowerNotExist
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+
+	"github.com/NekoWheel/NekoBox/internal/dbutil"
+)
+
+var ActivityPubKeys ActivityPubKeysStore
+
+var _ ActivityPubKeysStore = (*activityPubKeys)(nil)
+
+type ActivityPubKeysStore interface {
+	Create(ctx context.Context, opts CreateActivityPubKeyOptions) (*ActivityPubKey, error)
+	GetByUserID(ctx context.Context, userID uint) (*ActivityPubKey, error)
+}
+
+func NewActivityPubKeysStore(db *gorm.DB) ActivityPubKeysStore {
+	return &activityPubKeys{db}
+}
+
+type activityPubKeys struct {
+	*gorm.DB
+}
+
+// ActivityPubKey is the RSA key pair of the user's ActivityPub actor, which signs the activities
+// delivered to the followers. The key pair is generated when the actor is first requested.
+type ActivityPubKey struct {
+	dbutil.Model
+	UserID        uint   `gorm:"uniqueIndex:idx_activitypub_key_user_id" json:"-"`
+	PrivateKeyPEM string `gorm:"type:text" json:"-"`
+	PublicKeyPEM  string `gorm:"type:text" json:"-"`
+}
+
+var ErrActivityPubKeyNotExist = errors.New("ActivityPub 密钥不存在")
+
+type CreateActivityPubKeyOptions struct {
+	UserID        uint
+	PrivateKeyPEM string
+	PublicKeyPEM  string
+}
+
+// Create saves the key pair of the user, the existing key pair is returned if it has been created
+// by the concurrent request, so that the actor never changes its key.
+func (db *activityPubKeys) Create(ctx context.Context, opts CreateActivityPubKeyOptions) (*ActivityPubKey, error) {
+	ctx = WithPrimary(ctx)
+
+	key := ActivityPubKey{
+		UserID:        opts.UserID,
+		PrivateKeyPEM: opts.PrivateKeyPEM,
+		PublicKeyPEM:  opts.PublicKeyPEM,
+	}
+	if err := db.WithContext(ctx).Create(&key).Error; err != nil {
+		existing, getErr := db.GetByUserID(ctx, opts.UserID)
+		if getErr == nil {
+			return existing, nil
+		}
+		return nil, errors.Wrap(err, "create activitypub key")
+	}
+	return &key, nil
+}
+
+func (db *activityPubKeys) GetByUserID(ctx context.Context, userID uint) (*ActivityPubKey, error) {
+	var key ActivityPubKey
+	if err := db.WithContext(ctx).Where("user_id = ?", userID).First(&key).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrActivityPubKeyNotExist
+		}
+		return nil, errors.Wrap(err, "get activitypub key by user ID")
+	}
+	return &key, nil
+}
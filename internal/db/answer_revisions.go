@@ -0,0 +1,88 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+var AnswerRevisions AnswerRevisionsStore
+
+var _ AnswerRevisionsStore = (*answerRevisions)(nil)
+
+type AnswerRevisionsStore interface {
+	// GetByQuestionID returns the previous answers of the question, the latest one comes first.
+	GetByQuestionID(ctx context.Context, questionID uint) ([]*AnswerRevision, error)
+	// GetByID returns the revision of the question's answer with the given ID.
+	GetByID(ctx context.Context, questionID, id uint) (*AnswerRevision, error)
+}
+
+func NewAnswerRevisionsStore(db *gorm.DB) AnswerRevisionsStore {
+	return &answerRevisions{db}
+}
+
+// AnswerRevision is the previous answer of the question which is kept when the answer is edited.
+type AnswerRevision struct {
+	ID uint `gorm:"primarykey"`
+	// CreatedAt is when the answer is replaced.
+	CreatedAt  time.Time
+	QuestionID uint   `gorm:"index:idx_answer_revision_question_id"`
+	Answer     string `gorm:"type:text"`
+	// EditorUserID is the user who wrote the answer of the revision.
+	EditorUserID uint
+	// EditedAt is when the answer of the revision was written.
+	EditedAt *time.Time
+}
+
+type answerRevisions struct {
+	*gorm.DB
+}
+
+var ErrAnswerRevisionNotExist = errors.New("回答的历史版本不存在")
+
+func (db *answerRevisions) GetByQuestionID(ctx context.Context, questionID uint) ([]*AnswerRevision, error) {
+	var revisions []*AnswerRevision
+	if err := db.WithContext(ctx).Where("question_id = ?", questionID).Order("id DESC").Find(&revisions).Error; err != nil {
+		return nil, errors.Wrap(err, "get answer revisions")
+	}
+	return revisions, nil
+}
+
+func (db *answerRevisions) GetByID(ctx context.Context, questionID, id uint) (*AnswerRevision, error) {
+	var revision AnswerRevision
+	if err := db.WithContext(ctx).Where("id = ? AND question_id = ?", id, questionID).First(&revision).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAnswerRevisionNotExist
+		}
+		return nil, errors.Wrap(err, "get answer revision")
+	}
+	return &revision, nil
+}
+
+// saveAnswerRevision keeps the current answer of the question before it is replaced by the new answer,
+// nothing is saved if the question hasn't been answered or the answer isn't changed.
+func saveAnswerRevision(tx *gorm.DB, question *Question, newAnswer string) error {
+	if question.Answer == "" || question.Answer == newAnswer {
+		return nil
+	}
+
+	editedAt := question.AnswerUpdatedAt
+	if editedAt == nil {
+		editedAt = question.AnsweredAt
+	}
+	if err := tx.Create(&AnswerRevision{
+		QuestionID:   question.ID,
+		Answer:       question.Answer,
+		EditorUserID: question.AnswerUserID,
+		EditedAt:     editedAt,
+	}).Error; err != nil {
+		return errors.Wrap(err, "create answer revision")
+	}
+	return nil
+}
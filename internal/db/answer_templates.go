@@ -0,0 +1,140 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"context"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+
+	"github.com/NekoWheel/NekoBox/internal/dbutil"
+)
+
+var AnswerTemplates AnswerTemplatesStore
+
+var _ AnswerTemplatesStore = (*answerTemplates)(nil)
+
+type AnswerTemplatesStore interface {
+	Create(ctx context.Context, opts CreateAnswerTemplateOptions) (*AnswerTemplate, error)
+	GetByUserID(ctx context.Context, userID uint) ([]*AnswerTemplate, error)
+	Update(ctx context.Context, userID, id uint, opts UpdateAnswerTemplateOptions) error
+	DeleteByID(ctx context.Context, userID, id uint) error
+}
+
+func NewAnswerTemplatesStore(db *gorm.DB) AnswerTemplatesStore {
+	return &answerTemplates{db}
+}
+
+type answerTemplates struct {
+	*gorm.DB
+}
+
+// AnswerTemplate is the reusable answer snippet saved by the user, which can be inserted into the answer form,
+// e.g. "Thanks for asking!". The templates belong to the user rather than the box, so the box members have their own.
+type AnswerTemplate struct {
+	dbutil.Model
+	UserID  uint   `gorm:"index:idx_answer_template_user_id" json:"-"`
+	Title   string `gorm:"type:varchar(50)" json:"title"`
+	Content string `gorm:"type:text" json:"content"`
+}
+
+const (
+	// MaxAnswerTemplatesPerUser is the maximum number of the answer templates that a user can save.
+	MaxAnswerTemplatesPerUser = 20
+	// MaxAnswerTemplateTitleLength is the maximum length of the answer template title.
+	MaxAnswerTemplateTitleLength = 50
+)
+
+var (
+	ErrAnswerTemplateNotExist = errors.New("回答模板不存在")
+	ErrTooManyAnswerTemplates = errors.New("最多只能保存 20 个回答模板")
+	ErrInvalidAnswerTemplate  = errors.New("回答模板的标题和内容不能为空，标题不能超过 50 个字")
+)
+
+// validateAnswerTemplate checks the title and the content of the answer template,
+// the content is limited to the max length of the answers.
+func validateAnswerTemplate(title, content string) error {
+	if title == "" || utf8.RuneCountInString(title) > MaxAnswerTemplateTitleLength || strings.TrimSpace(content) == "" {
+		return ErrInvalidAnswerTemplate
+	}
+	return ValidateAnswerLength(content)
+}
+
+type CreateAnswerTemplateOptions struct {
+	UserID  uint
+	Title   string
+	Content string
+}
+
+func (db *answerTemplates) Create(ctx context.Context, opts CreateAnswerTemplateOptions) (*AnswerTemplate, error) {
+	opts.Title = strings.TrimSpace(opts.Title)
+	if err := validateAnswerTemplate(opts.Title, opts.Content); err != nil {
+		return nil, err
+	}
+
+	var count int64
+	if err := db.WithContext(WithPrimary(ctx)).Model(&AnswerTemplate{}).Where("user_id = ?", opts.UserID).Count(&count).Error; err != nil {
+		return nil, errors.Wrap(err, "count answer templates")
+	}
+	if count >= MaxAnswerTemplatesPerUser {
+		return nil, ErrTooManyAnswerTemplates
+	}
+
+	template := AnswerTemplate{
+		UserID:  opts.UserID,
+		Title:   opts.Title,
+		Content: opts.Content,
+	}
+	if err := db.WithContext(ctx).Create(&template).Error; err != nil {
+		return nil, errors.Wrap(err, "create answer template")
+	}
+	return &template, nil
+}
+
+func (db *answerTemplates) GetByUserID(ctx context.Context, userID uint) ([]*AnswerTemplate, error) {
+	var templates []*AnswerTemplate
+	if err := db.WithContext(ctx).Where("user_id = ?", userID).Order("id ASC").Find(&templates).Error; err != nil {
+		return nil, errors.Wrap(err, "get answer templates by user ID")
+	}
+	return templates, nil
+}
+
+type UpdateAnswerTemplateOptions struct {
+	Title   string
+	Content string
+}
+
+func (db *answerTemplates) Update(ctx context.Context, userID, id uint, opts UpdateAnswerTemplateOptions) error {
+	opts.Title = strings.TrimSpace(opts.Title)
+	if err := validateAnswerTemplate(opts.Title, opts.Content); err != nil {
+		return err
+	}
+
+	result := db.WithContext(ctx).Model(&AnswerTemplate{}).Where("user_id = ? AND id = ?", userID, id).Updates(map[string]interface{}{
+		"title":   opts.Title,
+		"content": opts.Content,
+	})
+	if result.Error != nil {
+		return errors.Wrap(result.Error, "update answer template")
+	}
+	if result.RowsAffected == 0 {
+		return ErrAnswerTemplateNotExist
+	}
+	return nil
+}
+
+func (db *answerTemplates) DeleteByID(ctx context.Context, userID, id uint) error {
+	result := db.WithContext(ctx).Where("user_id = ? AND id = ?", userID, id).Delete(&AnswerTemplate{})
+	if result.Error != nil {
+		return errors.Wrap(result.Error, "delete answer template")
+	}
+	if result.RowsAffected == 0 {
+		return ErrAnswerTemplateNotExist
+	}
+	return nil
+}
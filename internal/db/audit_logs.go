@@ -0,0 +1,193 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+
+	"github.com/NekoWheel/NekoBox/internal/dbutil"
+)
+
+var AuditLogs AuditLogsStore
+
+var _ AuditLogsStore = (*auditLogs)(nil)
+
+// AuditLogsStore is the append-only trail of the sensitive actions, the logs are never updated or deleted.
+type AuditLogsStore interface {
+	Create(ctx context.Context, opts CreateAuditLogOptions) error
+	List(ctx context.Context, opts ListAuditLogsOptions) ([]*AuditLog, *dbutil.PageInfo, error)
+	ExpireIPs(ctx context.Context, opts ExpireIPsOptions) (int64, error)
+}
+
+func NewAuditLogsStore(db *gorm.DB) AuditLogsStore {
+	return &auditLogs{db}
+}
+
+type auditLogs struct {
+	*gorm.DB
+}
+
+type AuditAction string
+
+const (
+	AuditActionLogin             AuditAction = "login"
+	AuditActionLoginFailed       AuditAction = "login_failed"
+	AuditActionPasswordChange    AuditAction = "password_change"
+	AuditActionPasswordReset     AuditAction = "password_reset"
+	AuditActionAccountDeactivate AuditAction = "account_deactivate"
+	AuditActionAccountDelete     AuditAction = "account_delete"
+	AuditActionQuestionDelete    AuditAction = "question_delete"
+	AuditActionAdminGrant        AuditAction = "admin_grant"
+	AuditActionAdminRevoke       AuditAction = "admin_revoke"
+	AuditActionReportResolve     AuditAction = "report_resolve"
+	AuditActionAccessTokenCreate AuditAction = "access_token_create"
+	AuditActionAccessTokenDelete AuditAction = "access_token_delete"
+	AuditActionSessionRevoke     AuditAction = "session_revoke"
+	AuditActionBoxMemberAdd      AuditAction = "box_member_add"
+	AuditActionBoxMemberUpdate   AuditAction = "box_member_update"
+	AuditActionBoxMemberRemove   AuditAction = "box_member_remove"
+	AuditActionUserStateChange   AuditAction = "user_state_change"
+	AuditActionAskerShadowBan    AuditAction = "asker_shadow_ban"
+	AuditActionAskerShadowUnban  AuditAction = "asker_shadow_unban"
+	AuditActionQuestionExport    AuditAction = "question_export"
+	AuditActionEmailChange       AuditAction = "email_change"
+	AuditActionQuestionMerge     AuditAction = "question_merge"
+	AuditActionAnswerRestore     AuditAction = "answer_restore"
+)
+
+// AuditActions are all the audit actions, which are listed in the filter of the viewer.
+var AuditActions = []AuditAction{
+	AuditActionLogin,
+	AuditActionLoginFailed,
+	AuditActionPasswordChange,
+	AuditActionPasswordReset,
+	AuditActionAccountDeactivate,
+	AuditActionAccountDelete,
+	AuditActionQuestionDelete,
+	AuditActionAdminGrant,
+	AuditActionAdminRevoke,
+	AuditActionReportResolve,
+	AuditActionAccessTokenCreate,
+	AuditActionAccessTokenDelete,
+	AuditActionSessionRevoke,
+	AuditActionBoxMemberAdd,
+	AuditActionBoxMemberUpdate,
+	AuditActionBoxMemberRemove,
+	AuditActionUserStateChange,
+	AuditActionAskerShadowBan,
+	AuditActionAskerShadowUnban,
+	AuditActionQuestionExport,
+	AuditActionEmailChange,
+	AuditActionQuestionMerge,
+	AuditActionAnswerRestore,
+}
+
+type AuditTargetType string
+
+const (
+	AuditTargetUser     AuditTargetType = "user"
+	AuditTargetQuestion AuditTargetType = "question"
+)
+
+// AuditLog is a record of a sensitive action. The actor is zero if the action is done
+// by an anonymous visitor or from the command line.
+type AuditLog struct {
+	ID          uint            `gorm:"primarykey" json:"id"`
+	CreatedAt   time.Time       `gorm:"index:idx_audit_log_created_at" json:"created_at"`
+	ActorUserID uint            `gorm:"index:idx_audit_log_actor_user_id" json:"actor_user_id"`
+	Action      AuditAction     `gorm:"type:varchar(32);index:idx_audit_log_action" json:"action"`
+	TargetType  AuditTargetType `gorm:"type:varchar(32);index:idx_audit_log_target" json:"target_type"`
+	TargetID    uint            `gorm:"index:idx_audit_log_target" json:"target_id"`
+	FromIP      string          `json:"-"`
+	Metadata    datatypes.JSON  `json:"metadata"`
+}
+
+// Target returns the readable target of the log, e.g. "question#1".
+func (l *AuditLog) Target() string {
+	if l.TargetType == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s#%d", l.TargetType, l.TargetID)
+}
+
+type CreateAuditLogOptions struct {
+	ActorUserID uint
+	Action      AuditAction
+	TargetType  AuditTargetType
+	TargetID    uint
+	FromIP      string
+	Metadata    map[string]interface{}
+}
+
+func (db *auditLogs) Create(ctx context.Context, opts CreateAuditLogOptions) error {
+	auditLog := AuditLog{
+		ActorUserID: opts.ActorUserID,
+		Action:      opts.Action,
+		TargetType:  opts.TargetType,
+		TargetID:    opts.TargetID,
+		FromIP:      storeIP(opts.FromIP),
+	}
+	if len(opts.Metadata) > 0 {
+		metadata, err := json.Marshal(opts.Metadata)
+		if err != nil {
+			return errors.Wrap(err, "marshal metadata")
+		}
+		auditLog.Metadata = metadata
+	}
+
+	if err := db.WithContext(ctx).Create(&auditLog).Error; err != nil {
+		return errors.Wrap(err, "create audit log")
+	}
+	return nil
+}
+
+type ListAuditLogsOptions struct {
+	*dbutil.Cursor
+	ActorUserID uint
+	Action      AuditAction
+	TargetType  AuditTargetType
+	TargetID    uint
+}
+
+// List returns the audit logs matching the filters, the latest logs come first.
+func (db *auditLogs) List(ctx context.Context, opts ListAuditLogsOptions) ([]*AuditLog, *dbutil.PageInfo, error) {
+	q := db.WithContext(ctx).Model(&AuditLog{})
+	if opts.ActorUserID != 0 {
+		q = q.Where("actor_user_id = ?", opts.ActorUserID)
+	}
+	if opts.Action != "" {
+		q = q.Where("action = ?", opts.Action)
+	}
+	if opts.TargetType != "" {
+		q = q.Where("target_type = ? AND target_id = ?", opts.TargetType, opts.TargetID)
+	}
+
+	var limit int
+	if opts.Cursor != nil {
+		limit = opts.Cursor.Limit()
+		if cursorID := opts.Cursor.Value; cursorID != nil && fmt.Sprintf("%v", cursorID) != "" {
+			q = q.Where("id < ?", cursorID)
+		}
+	}
+
+	logs, pageInfo, err := dbutil.Paginate(q.Order("id DESC"), limit, func(auditLog *AuditLog) interface{} { return auditLog.ID })
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "paginate")
+	}
+	return logs, pageInfo, nil
+}
+
+// ExpireIPs hashes or clears the raw IP addresses of the audit logs which are expired.
+// It returns the number of the updated logs.
+func (db *auditLogs) ExpireIPs(ctx context.Context, opts ExpireIPsOptions) (int64, error) {
+	return expireIPs(ctx, db.DB, &AuditLog{}, opts)
+}
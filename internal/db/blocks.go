@@ -0,0 +1,203 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+
+	"github.com/NekoWheel/NekoBox/internal/dbutil"
+)
+
+var Blocks BlocksStore
+
+var _ BlocksStore = (*blocks)(nil)
+
+type BlocksStore interface {
+	Create(ctx context.Context, opts CreateBlockOptions) error
+	GetByUserID(ctx context.Context, userID uint) ([]*Block, error)
+	IsBlocked(ctx context.Context, userID uint, opts IsBlockedOptions) (bool, error)
+	IsShadowBanned(ctx context.Context, userID uint, opts IsBlockedOptions) (bool, error)
+	CountByAsker(ctx context.Context, opts IsBlockedOptions) (int64, error)
+	DeleteByID(ctx context.Context, userID, id uint) error
+}
+
+func NewBlocksStore(db *gorm.DB) BlocksStore {
+	return &blocks{db}
+}
+
+type blocks struct {
+	*gorm.DB
+}
+
+// Block is an asker blocked by the box owner, the asker is identified by
+// the user ID if the asker has logged in, otherwise by the salted hash of the IP address.
+// The asker shadow-banned by the administrators is blocked from all the boxes, whose UserID is zero.
+type Block struct {
+	dbutil.Model
+	UserID      uint      `gorm:"uniqueIndex:idx_block_source" json:"-"`
+	AskerUserID uint      `gorm:"uniqueIndex:idx_block_source" json:"-"`
+	AskerIPHash string    `gorm:"uniqueIndex:idx_block_source;type:varchar(64)" json:"-"`
+	Mode        BlockMode `gorm:"type:varchar(16);not null;default:reject" json:"mode"`
+	// QuestionID is the question which the owner blocked the asker from.
+	QuestionID      uint   `json:"question_id"`
+	QuestionContent string `json:"question_content"`
+}
+
+// BlockMode is how the questions of the blocked asker are handled.
+type BlockMode string
+
+const (
+	// BlockModeReject rejects the questions of the asker.
+	BlockModeReject BlockMode = "reject"
+	// BlockModeShadow accepts the questions of the asker as usual, but puts them into the shadow folder
+	// which the owner only sees on purpose, so the asker doesn't notice the block and try another way.
+	BlockModeShadow BlockMode = "shadow"
+)
+
+var (
+	ErrBlockExists   = errors.New("已经屏蔽过该提问者了")
+	ErrBlockNotExist = errors.New("屏蔽记录不存在")
+	ErrBlockNoSource = errors.New("无法识别该提问者的来源")
+)
+
+type CreateBlockOptions struct {
+	UserID      uint
+	AskerUserID uint
+	// AskerIP is the stored IP address of the question, which may have been hashed.
+	AskerIP  string
+	Question *Question
+	// Mode defaults to BlockModeReject if it is empty.
+	Mode BlockMode
+}
+
+func (db *blocks) Create(ctx context.Context, opts CreateBlockOptions) error {
+	ctx = WithPrimary(ctx)
+
+	block := Block{
+		UserID:      opts.UserID,
+		AskerUserID: opts.AskerUserID,
+		Mode:        opts.Mode,
+	}
+	if block.Mode == "" {
+		block.Mode = BlockModeReject
+	}
+	// Prefer the user ID, the IP address of the logged user may change.
+	if block.AskerUserID == 0 {
+		if opts.AskerIP == "" {
+			return ErrBlockNoSource
+		}
+		block.AskerIPHash = storedIPHash(opts.AskerIP)
+	}
+
+	var count int64
+	if err := db.WithContext(ctx).Model(&Block{}).
+		Where("user_id = ? AND asker_user_id = ? AND asker_ip_hash = ?", block.UserID, block.AskerUserID, block.AskerIPHash).
+		Count(&count).Error; err != nil {
+		return errors.Wrap(err, "count blocks")
+	}
+	if count > 0 {
+		return ErrBlockExists
+	}
+
+	if opts.Question != nil {
+		block.QuestionID = opts.Question.ID
+		block.QuestionContent = opts.Question.Content
+	}
+	if err := db.WithContext(ctx).Create(&block).Error; err != nil {
+		return errors.Wrap(err, "create block")
+	}
+	return nil
+}
+
+func (db *blocks) GetByUserID(ctx context.Context, userID uint) ([]*Block, error) {
+	var blocks []*Block
+	if err := db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at DESC").Find(&blocks).Error; err != nil {
+		return nil, errors.Wrap(err, "get blocks by user ID")
+	}
+	return blocks, nil
+}
+
+type IsBlockedOptions struct {
+	AskerUserID uint
+	AskerIP     string
+}
+
+// whereAsker adds the condition of the asker to the query, either by the user ID or the IP address.
+// It returns false if the asker can't be identified.
+func whereAsker(q *gorm.DB, opts IsBlockedOptions) (*gorm.DB, bool) {
+	// The blocks created before the IP addresses were salted are matched by the unsalted hash.
+	var ipHashes []string
+	if opts.AskerIP != "" {
+		ipHashes = []string{saltedHashIP(opts.AskerIP), hashIP(opts.AskerIP)}
+	}
+
+	switch {
+	case opts.AskerUserID != 0 && opts.AskerIP != "":
+		return q.Where("asker_user_id = ? OR asker_ip_hash IN (?)", opts.AskerUserID, ipHashes), true
+	case opts.AskerUserID != 0:
+		return q.Where("asker_user_id = ?", opts.AskerUserID), true
+	case opts.AskerIP != "":
+		return q.Where("asker_ip_hash IN (?)", ipHashes), true
+	}
+	return q, false
+}
+
+// IsBlocked checks whether the questions of the asker are rejected by the user, either by the user ID or the IP address.
+func (db *blocks) IsBlocked(ctx context.Context, userID uint, opts IsBlockedOptions) (bool, error) {
+	q, ok := whereAsker(db.WithContext(ctx).Model(&Block{}).Where("user_id = ? AND mode = ?", userID, BlockModeReject), opts)
+	if !ok {
+		return false, nil
+	}
+
+	var count int64
+	if err := q.Count(&count).Error; err != nil {
+		return false, errors.Wrap(err, "count blocks")
+	}
+	return count > 0, nil
+}
+
+// IsShadowBanned checks whether the asker is shadow-banned by the user or the administrators,
+// either by the user ID or the IP address.
+func (db *blocks) IsShadowBanned(ctx context.Context, userID uint, opts IsBlockedOptions) (bool, error) {
+	q, ok := whereAsker(db.WithContext(ctx).Model(&Block{}).Where("user_id IN (?) AND mode = ?", []uint{userID, 0}, BlockModeShadow), opts)
+	if !ok {
+		return false, nil
+	}
+
+	var count int64
+	if err := q.Count(&count).Error; err != nil {
+		return false, errors.Wrap(err, "count shadow bans")
+	}
+	return count > 0, nil
+}
+
+// CountByAsker returns the number of the boxes which have blocked the asker, either by the user ID or the IP address.
+func (db *blocks) CountByAsker(ctx context.Context, opts IsBlockedOptions) (int64, error) {
+	q, ok := whereAsker(db.WithContext(ctx).Model(&Block{}), opts)
+	if !ok {
+		return 0, nil
+	}
+
+	var count int64
+	if err := q.Distinct("user_id").Count(&count).Error; err != nil {
+		return 0, errors.Wrap(err, "count blocks")
+	}
+	return count, nil
+}
+
+func (db *blocks) DeleteByID(ctx context.Context, userID, id uint) error {
+	// Delete permanently, so the asker can be blocked again with the unique index.
+	result := db.WithContext(ctx).Unscoped().Where("user_id = ? AND id = ?", userID, id).Delete(&Block{})
+	if result.Error != nil {
+		return errors.Wrap(result.Error, "delete block")
+	}
+	if result.RowsAffected == 0 {
+		return ErrBlockNotExist
+	}
+	return nil
+}
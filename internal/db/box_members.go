@@ -0,0 +1,187 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+
+	"github.com/NekoWheel/NekoBox/internal/dbutil"
+)
+
+var BoxMembers BoxMembersStore
+
+var _ BoxMembersStore = (*boxMembers)(nil)
+
+type BoxMembersStore interface {
+	Create(ctx context.Context, opts CreateBoxMemberOptions) error
+	GetByBoxUserID(ctx context.Context, boxUserID uint) ([]*BoxMember, error)
+	GetByUserID(ctx context.Context, userID uint) ([]*BoxMember, error)
+	GetRole(ctx context.Context, boxUserID, userID uint) (BoxMemberRole, error)
+	UpdateRole(ctx context.Context, boxUserID, userID uint, role BoxMemberRole) error
+	Delete(ctx context.Context, boxUserID, userID uint) error
+}
+
+func NewBoxMembersStore(db *gorm.DB) BoxMembersStore {
+	return &boxMembers{db}
+}
+
+type boxMembers struct {
+	*gorm.DB
+}
+
+// BoxMember is a user who helps to manage the box of another user, which makes the box a team box.
+// The user of the box is always the owner and is not stored as a member, only they can manage the members.
+type BoxMember struct {
+	dbutil.Model
+	BoxUserID uint          `gorm:"uniqueIndex:idx_box_member_box_user" json:"-"`
+	UserID    uint          `gorm:"uniqueIndex:idx_box_member_box_user;index:idx_box_member_user_id" json:"-"`
+	Role      BoxMemberRole `gorm:"type:varchar(16);not null" json:"role"`
+	// User is the member user or the box user for the memberships, which is loaded by the caller.
+	User *User `gorm:"-" json:"-"`
+}
+
+// BoxMemberRole is the permission of the member in the box.
+type BoxMemberRole string
+
+const (
+	// BoxMemberRoleOwner can do everything to the questions as the user of the box.
+	BoxMemberRoleOwner BoxMemberRole = "owner"
+	// BoxMemberRoleModerator can answer, delete, pin the questions and block the askers.
+	BoxMemberRoleModerator BoxMemberRole = "moderator"
+	// BoxMemberRoleAnswerer can only answer the questions and edit the answers written by themselves.
+	BoxMemberRoleAnswerer BoxMemberRole = "answerer"
+)
+
+func (r BoxMemberRole) IsValid() bool {
+	switch r {
+	case BoxMemberRoleOwner, BoxMemberRoleModerator, BoxMemberRoleAnswerer:
+		return true
+	}
+	return false
+}
+
+// CanAnswer reports whether the role can answer the questions, the empty role is not a member.
+func (r BoxMemberRole) CanAnswer() bool {
+	return r.IsValid()
+}
+
+// CanModerate reports whether the role can delete, pin the questions and block the askers.
+func (r BoxMemberRole) CanModerate() bool {
+	return r == BoxMemberRoleOwner || r == BoxMemberRoleModerator
+}
+
+// MaxBoxMembersPerBox is the maximum number of the members that a box can have.
+const MaxBoxMembersPerBox = 20
+
+var (
+	ErrBoxMemberExists      = errors.New("该用户已经是提问箱的成员了")
+	ErrBoxMemberNotExist    = errors.New("提问箱成员不存在")
+	ErrTooManyBoxMembers    = errors.New("每个提问箱最多只能添加 20 名成员")
+	ErrInvalidBoxMemberRole = errors.New("成员角色不合法")
+)
+
+type CreateBoxMemberOptions struct {
+	BoxUserID uint
+	UserID    uint
+	Role      BoxMemberRole
+}
+
+func (db *boxMembers) Create(ctx context.Context, opts CreateBoxMemberOptions) error {
+	ctx = WithPrimary(ctx)
+
+	if !opts.Role.IsValid() {
+		return ErrInvalidBoxMemberRole
+	}
+	// The user of the box is the owner already.
+	if opts.UserID == opts.BoxUserID {
+		return ErrBoxMemberExists
+	}
+
+	var members []*BoxMember
+	if err := db.WithContext(ctx).Where("box_user_id = ?", opts.BoxUserID).Find(&members).Error; err != nil {
+		return errors.Wrap(err, "get box members")
+	}
+	for _, member := range members {
+		if member.UserID == opts.UserID {
+			return ErrBoxMemberExists
+		}
+	}
+	if len(members) >= MaxBoxMembersPerBox {
+		return ErrTooManyBoxMembers
+	}
+
+	if err := db.WithContext(ctx).Create(&BoxMember{
+		BoxUserID: opts.BoxUserID,
+		UserID:    opts.UserID,
+		Role:      opts.Role,
+	}).Error; err != nil {
+		return errors.Wrap(err, "create box member")
+	}
+	return nil
+}
+
+// GetByBoxUserID returns the members of the box, the earlier added ones come first.
+func (db *boxMembers) GetByBoxUserID(ctx context.Context, boxUserID uint) ([]*BoxMember, error) {
+	var members []*BoxMember
+	if err := db.WithContext(ctx).Where("box_user_id = ?", boxUserID).Order("id ASC").Find(&members).Error; err != nil {
+		return nil, errors.Wrap(err, "get box members by box user ID")
+	}
+	return members, nil
+}
+
+// GetByUserID returns the memberships of the user in the other users' boxes.
+func (db *boxMembers) GetByUserID(ctx context.Context, userID uint) ([]*BoxMember, error) {
+	var members []*BoxMember
+	if err := db.WithContext(ctx).Where("user_id = ?", userID).Order("id ASC").Find(&members).Error; err != nil {
+		return nil, errors.Wrap(err, "get box members by user ID")
+	}
+	return members, nil
+}
+
+// GetRole returns the role of the user in the box, it returns ErrBoxMemberNotExist if the user is not a member.
+func (db *boxMembers) GetRole(ctx context.Context, boxUserID, userID uint) (BoxMemberRole, error) {
+	var member BoxMember
+	if err := db.WithContext(ctx).Where("box_user_id = ? AND user_id = ?", boxUserID, userID).First(&member).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", ErrBoxMemberNotExist
+		}
+		return "", errors.Wrap(err, "get box member")
+	}
+	return member.Role, nil
+}
+
+func (db *boxMembers) UpdateRole(ctx context.Context, boxUserID, userID uint, role BoxMemberRole) error {
+	ctx = WithPrimary(ctx)
+
+	if !role.IsValid() {
+		return ErrInvalidBoxMemberRole
+	}
+	// The affected rows are zero when the role is unchanged on MySQL, so the member is checked first.
+	if _, err := db.GetRole(ctx, boxUserID, userID); err != nil {
+		return err
+	}
+
+	if err := db.WithContext(ctx).Model(&BoxMember{}).
+		Where("box_user_id = ? AND user_id = ?", boxUserID, userID).
+		Update("role", role).Error; err != nil {
+		return errors.Wrap(err, "update box member role")
+	}
+	return nil
+}
+
+// Delete removes the member from the box, the answers written by the member are kept.
+func (db *boxMembers) Delete(ctx context.Context, boxUserID, userID uint) error {
+	result := db.WithContext(ctx).Unscoped().Where("box_user_id = ? AND user_id = ?", boxUserID, userID).Delete(&BoxMember{})
+	if result.Error != nil {
+		return errors.Wrap(result.Error, "delete box member")
+	}
+	if result.RowsAffected == 0 {
+		return ErrBoxMemberNotExist
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+
+	"github.com/NekoWheel/NekoBox/internal/dbutil"
+)
+
+var CensorFeedbacks CensorFeedbacksStore
+
+var _ CensorFeedbacksStore = (*censorFeedbacks)(nil)
+
+type CensorFeedbacksStore interface {
+	Create(ctx context.Context, opts CreateCensorFeedbackOptions) error
+	StatsBySource(ctx context.Context) ([]*CensorSourceStats, error)
+}
+
+func NewCensorFeedbacksStore(db *gorm.DB) CensorFeedbacksStore {
+	return &censorFeedbacks{db}
+}
+
+type censorFeedbacks struct {
+	*gorm.DB
+}
+
+// CensorFeedback is the administrator's verdict on the question checked by the censor provider,
+// which is recorded when the reports of the question are resolved.
+type CensorFeedback struct {
+	dbutil.Model
+	QuestionID    uint   `gorm:"index:idx_censor_feedback_question_id"`
+	SourceName    string `gorm:"type:varchar(20);index:idx_censor_feedback_source_name"`
+	ForbiddenType string `gorm:"type:varchar(32)"`
+	// CensorPass is the verdict of the provider, and AdminPass is whether the administrator keeps the question.
+	CensorPass  bool `gorm:"not null;default:false"`
+	AdminPass   bool `gorm:"not null;default:false"`
+	AdminUserID uint
+}
+
+func (*CensorFeedback) TableName() string {
+	return "censor_feedback"
+}
+
+type CreateCensorFeedbackOptions struct {
+	QuestionID    uint
+	SourceName    string
+	ForbiddenType string
+	CensorPass    bool
+	AdminPass     bool
+	AdminUserID   uint
+}
+
+func (db *censorFeedbacks) Create(ctx context.Context, opts CreateCensorFeedbackOptions) error {
+	return db.WithContext(ctx).Create(&CensorFeedback{
+		QuestionID:    opts.QuestionID,
+		SourceName:    opts.SourceName,
+		ForbiddenType: opts.ForbiddenType,
+		CensorPass:    opts.CensorPass,
+		AdminPass:     opts.AdminPass,
+		AdminUserID:   opts.AdminUserID,
+	}).Error
+}
+
+// CensorSourceStats is how often the administrators disagree with the censor provider.
+type CensorSourceStats struct {
+	SourceName string
+	Total      int64
+	// FalsePositives are the questions rejected by the provider but kept by the administrators.
+	FalsePositives int64
+	// FalseNegatives are the questions passed by the provider but hidden by the administrators.
+	FalseNegatives int64
+}
+
+// DisagreementRate returns the percentage of the feedbacks disagreeing with the provider.
+func (s *CensorSourceStats) DisagreementRate() float64 {
+	if s.Total == 0 {
+		return 0
+	}
+	return float64(s.FalsePositives+s.FalseNegatives) * 100 / float64(s.Total)
+}
+
+// StatsBySource returns the feedback statistics of each censor provider, ordered by the provider name.
+func (db *censorFeedbacks) StatsBySource(ctx context.Context) ([]*CensorSourceStats, error) {
+	var stats []*CensorSourceStats
+	if err := db.WithContext(ctx).Model(&CensorFeedback{}).
+		Select(`source_name, COUNT(*) AS total,
+		COALESCE(SUM(CASE WHEN censor_pass = ? AND admin_pass = ? THEN 1 ELSE 0 END), 0) AS false_positives,
+		COALESCE(SUM(CASE WHEN censor_pass = ? AND admin_pass = ? THEN 1 ELSE 0 END), 0) AS false_negatives`, false, true, true, false).
+		Group("source_name").Order("source_name ASC").
+		Scan(&stats).Error; err != nil {
+		return nil, errors.Wrap(err, "count censor feedbacks")
+	}
+	return stats, nil
+}
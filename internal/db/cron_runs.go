@@ -0,0 +1,148 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/NekoWheel/NekoBox/internal/dbutil"
+)
+
+var CronRuns CronRunsStore
+
+var _ CronRunsStore = (*cronRuns)(nil)
+
+type CronRunsStore interface {
+	// Lock takes the lock of the cron job until the given time, it returns false if the lock is held
+	// by another instance. The lock is not released after the run, so the job runs once in each period
+	// no matter how many instances are running.
+	Lock(ctx context.Context, name, holder string, until time.Time) (bool, error)
+	Start(ctx context.Context, name, holder string) (*CronRun, error)
+	Finish(ctx context.Context, id uint, runErr error) error
+	ListLatest(ctx context.Context) ([]*CronRun, error)
+	ListFailed(ctx context.Context, limit int) ([]*CronRun, error)
+	Purge(ctx context.Context, before time.Time) (int64, error)
+}
+
+func NewCronRunsStore(db *gorm.DB) CronRunsStore {
+	return &cronRuns{db}
+}
+
+type cronRuns struct {
+	*gorm.DB
+}
+
+// CronRun is a run of the cron job, the creation time is when the run starts.
+type CronRun struct {
+	dbutil.Model
+	Name string `gorm:"type:varchar(64);index:idx_cron_run_name"`
+	// Holder is the instance running the job, which is the host name and the process ID.
+	Holder     string `gorm:"type:varchar(128)"`
+	FinishedAt *time.Time
+	// Error is empty if the run succeeded.
+	Error string `gorm:"type:text"`
+}
+
+// Duration returns how long the run took, it is zero if the run has not finished.
+func (r *CronRun) Duration() time.Duration {
+	if r.FinishedAt == nil {
+		return 0
+	}
+	return r.FinishedAt.Sub(r.CreatedAt)
+}
+
+// CronLock is the lock of the cron job shared by the instances.
+type CronLock struct {
+	Name        string `gorm:"type:varchar(64);primaryKey"`
+	Holder      string `gorm:"type:varchar(128)"`
+	LockedUntil time.Time
+}
+
+func (db *cronRuns) Lock(ctx context.Context, name, holder string, until time.Time) (bool, error) {
+	ctx = WithPrimary(ctx)
+
+	result := db.WithContext(ctx).Model(&CronLock{}).Where("name = ? AND locked_until <= ?", name, time.Now()).Updates(map[string]interface{}{
+		"holder":       holder,
+		"locked_until": until,
+	})
+	if result.Error != nil {
+		return false, errors.Wrap(result.Error, "take expired lock")
+	}
+	if result.RowsAffected > 0 {
+		return true, nil
+	}
+
+	// The lock of the job has never been taken, the insert fails if another instance takes it first.
+	result = db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&CronLock{
+		Name:        name,
+		Holder:      holder,
+		LockedUntil: until,
+	})
+	if result.Error != nil {
+		return false, errors.Wrap(result.Error, "create lock")
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (db *cronRuns) Start(ctx context.Context, name, holder string) (*CronRun, error) {
+	run := &CronRun{
+		Name:   name,
+		Holder: holder,
+	}
+	if err := db.WithContext(ctx).Create(run).Error; err != nil {
+		return nil, errors.Wrap(err, "create cron run")
+	}
+	return run, nil
+}
+
+func (db *cronRuns) Finish(ctx context.Context, id uint, runErr error) error {
+	var errorMessage string
+	if runErr != nil {
+		errorMessage = runErr.Error()
+	}
+
+	if err := db.WithContext(ctx).Model(&CronRun{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"finished_at": time.Now(),
+		"error":       errorMessage,
+	}).Error; err != nil {
+		return errors.Wrap(err, "update cron run")
+	}
+	return nil
+}
+
+// ListLatest returns the latest run of each cron job.
+func (db *cronRuns) ListLatest(ctx context.Context) ([]*CronRun, error) {
+	var runs []*CronRun
+	if err := db.WithContext(ctx).
+		Where("id IN (?)", db.WithContext(ctx).Model(&CronRun{}).Select("MAX(id)").Group("name")).
+		Order("name ASC").
+		Find(&runs).Error; err != nil {
+		return nil, errors.Wrap(err, "list latest cron runs")
+	}
+	return runs, nil
+}
+
+// ListFailed returns the latest failed runs of all the cron jobs.
+func (db *cronRuns) ListFailed(ctx context.Context, limit int) ([]*CronRun, error) {
+	var runs []*CronRun
+	if err := db.WithContext(ctx).Where("error <> ''").Order("id DESC").Limit(limit).Find(&runs).Error; err != nil {
+		return nil, errors.Wrap(err, "list failed cron runs")
+	}
+	return runs, nil
+}
+
+// Purge permanently deletes the runs started before the given time.
+func (db *cronRuns) Purge(ctx context.Context, before time.Time) (int64, error) {
+	result := db.WithContext(ctx).Unscoped().Where("created_at < ?", before).Delete(&CronRun{})
+	if result.Error != nil {
+		return 0, errors.Wrap(result.Error, "purge cron runs")
+	}
+	return result.RowsAffected, nil
+}
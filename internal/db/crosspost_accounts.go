@@ -0,0 +1,155 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+
+	"github.com/NekoWheel/NekoBox/internal/dbutil"
+)
+
+var CrosspostAccounts CrosspostAccountsStore
+
+var _ CrosspostAccountsStore = (*crosspostAccounts)(nil)
+
+type CrosspostAccountsStore interface {
+	Save(ctx context.Context, opts SaveCrosspostAccountOptions) (*CrosspostAccount, error)
+	GetByID(ctx context.Context, id uint) (*CrosspostAccount, error)
+	GetByUserID(ctx context.Context, userID uint) ([]*CrosspostAccount, error)
+	UpdateToken(ctx context.Context, id uint, opts UpdateCrosspostTokenOptions) error
+	DeleteByProvider(ctx context.Context, userID uint, provider CrosspostProvider) error
+}
+
+func NewCrosspostAccountsStore(db *gorm.DB) CrosspostAccountsStore {
+	return &crosspostAccounts{db}
+}
+
+type crosspostAccounts struct {
+	*gorm.DB
+}
+
+type CrosspostProvider string
+
+const (
+	CrosspostProviderTwitter  CrosspostProvider = "twitter"
+	CrosspostProviderMastodon CrosspostProvider = "mastodon"
+)
+
+// IsValid returns whether the provider is supported.
+func (p CrosspostProvider) IsValid() bool {
+	switch p {
+	case CrosspostProviderTwitter, CrosspostProviderMastodon:
+		return true
+	default:
+		return false
+	}
+}
+
+// CrosspostAccount is the social account linked by the user, the answers are cross-posted to it.
+// A user can link one account of each provider.
+type CrosspostAccount struct {
+	dbutil.Model
+	UserID   uint              `gorm:"uniqueIndex:idx_crosspost_account_user_id_provider" json:"-"`
+	Provider CrosspostProvider `gorm:"type:varchar(20);uniqueIndex:idx_crosspost_account_user_id_provider" json:"provider"`
+	// InstanceURL is the base URL of the Mastodon instance, it is empty for Twitter.
+	InstanceURL string `gorm:"type:varchar(255)" json:"instance_url"`
+	Username    string `gorm:"type:varchar(255)" json:"username"`
+	AccessToken string `json:"-"`
+	// RefreshToken and ExpiresAt are empty if the access token never expires, e.g. Mastodon.
+	RefreshToken string     `json:"-"`
+	ExpiresAt    *time.Time `json:"-"`
+}
+
+var ErrCrosspostAccountNotExist = errors.New("社交账号未绑定")
+
+type SaveCrosspostAccountOptions struct {
+	UserID       uint
+	Provider     CrosspostProvider
+	InstanceURL  string
+	Username     string
+	AccessToken  string
+	RefreshToken string
+	ExpiresAt    *time.Time
+}
+
+// Save links the account to the user, the account of the same provider linked before is replaced.
+func (db *crosspostAccounts) Save(ctx context.Context, opts SaveCrosspostAccountOptions) (*CrosspostAccount, error) {
+	var account CrosspostAccount
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("user_id = ? AND provider = ?", opts.UserID, opts.Provider).Delete(&CrosspostAccount{}).Error; err != nil {
+			return errors.Wrap(err, "delete linked account")
+		}
+
+		account = CrosspostAccount{
+			UserID:       opts.UserID,
+			Provider:     opts.Provider,
+			InstanceURL:  opts.InstanceURL,
+			Username:     opts.Username,
+			AccessToken:  opts.AccessToken,
+			RefreshToken: opts.RefreshToken,
+			ExpiresAt:    opts.ExpiresAt,
+		}
+		if err := tx.Create(&account).Error; err != nil {
+			return errors.Wrap(err, "create account")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+func (db *crosspostAccounts) GetByID(ctx context.Context, id uint) (*CrosspostAccount, error) {
+	var account CrosspostAccount
+	if err := db.WithContext(ctx).Where("id = ?", id).First(&account).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCrosspostAccountNotExist
+		}
+		return nil, errors.Wrap(err, "get crosspost account by ID")
+	}
+	return &account, nil
+}
+
+func (db *crosspostAccounts) GetByUserID(ctx context.Context, userID uint) ([]*CrosspostAccount, error) {
+	var accounts []*CrosspostAccount
+	if err := db.WithContext(ctx).Where("user_id = ?", userID).Order("id ASC").Find(&accounts).Error; err != nil {
+		return nil, errors.Wrap(err, "get crosspost accounts by user ID")
+	}
+	return accounts, nil
+}
+
+type UpdateCrosspostTokenOptions struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresAt    *time.Time
+}
+
+// UpdateToken saves the refreshed tokens of the account.
+func (db *crosspostAccounts) UpdateToken(ctx context.Context, id uint, opts UpdateCrosspostTokenOptions) error {
+	if err := db.WithContext(ctx).Model(&CrosspostAccount{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"access_token":  opts.AccessToken,
+		"refresh_token": opts.RefreshToken,
+		"expires_at":    opts.ExpiresAt,
+	}).Error; err != nil {
+		return errors.Wrap(err, "update crosspost token")
+	}
+	return nil
+}
+
+func (db *crosspostAccounts) DeleteByProvider(ctx context.Context, userID uint, provider CrosspostProvider) error {
+	result := db.WithContext(ctx).Unscoped().Where("user_id = ? AND provider = ?", userID, provider).Delete(&CrosspostAccount{})
+	if result.Error != nil {
+		return errors.Wrap(result.Error, "delete crosspost account")
+	}
+	if result.RowsAffected == 0 {
+		return ErrCrosspostAccountNotExist
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+
+	"github.com/NekoWheel/NekoBox/internal/dbutil"
+)
+
+var Crossposts CrosspostsStore
+
+var _ CrosspostsStore = (*crossposts)(nil)
+
+type CrosspostsStore interface {
+	Create(ctx context.Context, opts CreateCrosspostOptions) (*Crosspost, error)
+	ListDue(ctx context.Context, limit int) ([]*Crosspost, error)
+	Claim(ctx context.Context, id uint, lease time.Duration) (bool, error)
+	MarkPosted(ctx context.Context, id uint, postURL string) error
+	MarkFailed(ctx context.Context, id uint, opts MarkCrosspostFailedOptions) error
+}
+
+func NewCrosspostsStore(db *gorm.DB) CrosspostsStore {
+	return &crossposts{db}
+}
+
+type crossposts struct {
+	*gorm.DB
+}
+
+type CrosspostStatus string
+
+const (
+	CrosspostStatusPending CrosspostStatus = "pending"
+	CrosspostStatusPosted  CrosspostStatus = "posted"
+	// CrosspostStatusFailed means the post is given up after too many failed attempts,
+	// or it is rejected by the provider, e.g. the token has been revoked.
+	CrosspostStatusFailed CrosspostStatus = "failed"
+)
+
+// Crosspost is the answer waiting to be posted to the linked social account,
+// the content is composed when the answer is published.
+type Crosspost struct {
+	dbutil.Model
+	UserID        uint `gorm:"index:idx_crosspost_user_id"`
+	AccountID     uint
+	QuestionID    uint
+	Content       string
+	Status        CrosspostStatus `gorm:"type:varchar(20);index:idx_crosspost_status_next_attempt"`
+	Attempts      int
+	NextAttemptAt time.Time `gorm:"index:idx_crosspost_status_next_attempt"`
+	LastError     string
+	PostURL       string `gorm:"type:varchar(255)"`
+	PostedAt      *time.Time
+}
+
+type CreateCrosspostOptions struct {
+	UserID     uint
+	AccountID  uint
+	QuestionID uint
+	Content    string
+}
+
+func (db *crossposts) Create(ctx context.Context, opts CreateCrosspostOptions) (*Crosspost, error) {
+	crosspost := Crosspost{
+		UserID:        opts.UserID,
+		AccountID:     opts.AccountID,
+		QuestionID:    opts.QuestionID,
+		Content:       opts.Content,
+		Status:        CrosspostStatusPending,
+		NextAttemptAt: time.Now(),
+	}
+	if err := db.WithContext(ctx).Create(&crosspost).Error; err != nil {
+		return nil, errors.Wrap(err, "create crosspost")
+	}
+	return &crosspost, nil
+}
+
+// ListDue returns the pending posts which should be posted now.
+func (db *crossposts) ListDue(ctx context.Context, limit int) ([]*Crosspost, error) {
+	var crossposts []*Crosspost
+	if err := db.WithContext(ctx).
+		Where("status = ? AND next_attempt_at <= ?", CrosspostStatusPending, time.Now()).
+		Order("next_attempt_at ASC").
+		Limit(limit).
+		Find(&crossposts).Error; err != nil {
+		return nil, errors.Wrap(err, "list due crossposts")
+	}
+	return crossposts, nil
+}
+
+// Claim takes the pending post by postponing its next attempt with the given lease,
+// it returns false if the post has been claimed by others.
+func (db *crossposts) Claim(ctx context.Context, id uint, lease time.Duration) (bool, error) {
+	now := time.Now()
+	tx := db.WithContext(ctx).Model(&Crosspost{}).
+		Where("id = ? AND status = ? AND next_attempt_at <= ?", id, CrosspostStatusPending, now).
+		Update("next_attempt_at", now.Add(lease))
+	if tx.Error != nil {
+		return false, errors.Wrap(tx.Error, "claim crosspost")
+	}
+	return tx.RowsAffected == 1, nil
+}
+
+func (db *crossposts) MarkPosted(ctx context.Context, id uint, postURL string) error {
+	if err := db.WithContext(ctx).Model(&Crosspost{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":    CrosspostStatusPosted,
+		"attempts":  gorm.Expr("attempts + 1"),
+		"post_url":  postURL,
+		"posted_at": time.Now(),
+	}).Error; err != nil {
+		return errors.Wrap(err, "mark crosspost posted")
+	}
+	return nil
+}
+
+type MarkCrosspostFailedOptions struct {
+	Error string
+	// Status is the status after the failure, the post is retried at NextAttemptAt when it is still pending.
+	Status        CrosspostStatus
+	NextAttemptAt time.Time
+}
+
+func (db *crossposts) MarkFailed(ctx context.Context, id uint, opts MarkCrosspostFailedOptions) error {
+	updates := map[string]interface{}{
+		"status":     opts.Status,
+		"attempts":   gorm.Expr("attempts + 1"),
+		"last_error": opts.Error,
+	}
+	if !opts.NextAttemptAt.IsZero() {
+		updates["next_attempt_at"] = opts.NextAttemptAt
+	}
+
+	if err := db.WithContext(ctx).Model(&Crosspost{}).Where("id = ?", id).Updates(updates).Error; err != nil {
+		return errors.Wrap(err, "mark crosspost failed")
+	}
+	return nil
+}
@@ -6,38 +6,76 @@ package db
 
 import (
 	"fmt"
+	"net"
+	"os"
+	"path/filepath"
 
+	"github.com/glebarez/sqlite"
 	"github.com/pkg/errors"
 	"github.com/uptrace/opentelemetry-go-extra/otelgorm"
 	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 
 	"github.com/NekoWheel/NekoBox/internal/conf"
+	"github.com/NekoWheel/NekoBox/internal/db/migrations"
+	"github.com/NekoWheel/NekoBox/internal/logging"
 )
 
+// Init connects to the database, applies the pending migrations and initializes the stores.
 func Init() (*gorm.DB, error) {
-	dsn := fmt.Sprintf("%s:%s@%s/%s?charset=utf8mb4&parseTime=True&loc=Local",
-		conf.Database.User,
-		conf.Database.Password,
-		conf.Database.Address,
-		conf.Database.Name,
-	)
-	conf.Database.DSN = dsn
-
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{
-		SkipDefaultTransaction: true,
-	})
+	db, err := Open()
 	if err != nil {
-		return nil, errors.Wrap(err, "connect to database")
+		return nil, err
+	}
+
+	if err := migrations.Migrate(db); err != nil {
+		return nil, errors.Wrap(err, "migrate")
+	}
+
+	// The replicas are registered after the migration, the schema is only inspected on the primary.
+	if err := registerReplicas(db); err != nil {
+		return nil, errors.Wrap(err, "register replicas")
 	}
 
-	if err := db.AutoMigrate(&User{}, &Question{}, &CensorLog{}); err != nil {
-		return nil, errors.Wrap(err, "auto migrate")
+	questionsCache, err := newQuestionsCache()
+	if err != nil {
+		return nil, errors.Wrap(err, "new questions cache")
 	}
 
 	Users = NewUsersStore(db)
-	Questions = NewQuestionsStore(db)
+	Questions = newTracedQuestionsStore(newPublishedQuestionsStore(newCachedQuestionsStore(NewQuestionsStore(db), questionsCache)))
+	QuestionReplies = NewQuestionRepliesStore(db)
+	QuestionReactions = NewQuestionReactionsStore(db)
+	QuestionTags = NewQuestionTagsStore(db)
+	QuestionDrafts = NewQuestionDraftsStore(db)
+	QuestionTranslations = NewQuestionTranslationsStore(db)
+	AnswerRevisions = NewAnswerRevisionsStore(db)
+	Achievements = NewAchievementsStore(db)
+	Prompts = NewPromptsStore(db)
+	Blocks = NewBlocksStore(db)
+	WordFilters = NewWordFiltersStore(db)
+	AnswerTemplates = NewAnswerTemplatesStore(db)
+	CrosspostAccounts = NewCrosspostAccountsStore(db)
+	Crossposts = NewCrosspostsStore(db)
+	BoxMembers = NewBoxMembersStore(db)
+	ActivityPubKeys = NewActivityPubKeysStore(db)
+	ActivityPubFollowers = NewActivityPubFollowersStore(db)
+	Webhooks = NewWebhooksStore(db)
+	PushSubscriptions = NewPushSubscriptionsStore(db)
+	EmailSuppressions = NewEmailSuppressionsStore(db)
+	Reports = NewReportsStore(db)
+	AccessTokens = NewAccessTokensStore(db)
+	UserSessions = NewUserSessionsStore(db)
+	LoginAttempts = NewLoginAttemptsStore(db)
+	LoginLinks = NewLoginLinksStore(db)
+	EmailChanges = NewEmailChangesStore(db)
+	TwoFactors = NewTwoFactorsStore(db)
+	MailOutbox = NewMailOutboxStore(db)
 	CensorLogs = NewCensorLogsStore(db)
+	CensorFeedbacks = NewCensorFeedbacksStore(db)
+	CronRuns = NewCronRunsStore(db)
+	AuditLogs = NewAuditLogsStore(db)
 
 	if err := db.Use(otelgorm.NewPlugin(
 		otelgorm.WithDBName(conf.Database.Name),
@@ -47,3 +85,82 @@ func Init() (*gorm.DB, error) {
 
 	return db, nil
 }
+
+// Open connects to the configured database without touching the schema.
+func Open() (*gorm.DB, error) {
+	dialector, err := newDialector()
+	if err != nil {
+		return nil, errors.Wrap(err, "new dialector")
+	}
+
+	db, err := gorm.Open(dialector, &gorm.Config{
+		SkipDefaultTransaction: true,
+		Logger:                 &logging.GORMLogger{},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "connect to database")
+	}
+	return db, nil
+}
+
+// newDialector returns the dialector of the configured database type, MySQL is used by default.
+// The DSN is saved to the configuration, which is also used by the session store.
+func newDialector() (gorm.Dialector, error) {
+	dialector, dsn, err := openDialector(conf.Database.Address)
+	if err != nil {
+		return nil, err
+	}
+	conf.Database.DSN = dsn
+	return dialector, nil
+}
+
+// openDialector returns the dialector and the DSN of the database at the given address,
+// the other connection options are shared by the primary and the replicas.
+func openDialector(address string) (gorm.Dialector, string, error) {
+	switch conf.Database.Type {
+	case "", "mysql":
+		dsn := fmt.Sprintf("%s:%s@%s/%s?charset=utf8mb4&parseTime=True&loc=Local",
+			conf.Database.User,
+			conf.Database.Password,
+			address,
+			conf.Database.Name,
+		)
+		return mysql.Open(dsn), dsn, nil
+
+	case "postgres":
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, "", errors.Wrapf(err, "split host and port of %q", address)
+		}
+		sslMode := conf.Database.SSLMode
+		if sslMode == "" {
+			sslMode = "disable"
+		}
+		dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
+			host, port,
+			conf.Database.User,
+			conf.Database.Password,
+			conf.Database.Name,
+			sslMode,
+		)
+		return postgres.Open(dsn), dsn, nil
+
+	case "sqlite":
+		if len(conf.Database.Replicas) > 0 {
+			return nil, "", errors.New("replicas are not supported by SQLite")
+		}
+		path := conf.Database.Path
+		if path == "" {
+			path = "data/nekobox.db"
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return nil, "", errors.Wrap(err, "create database directory")
+		}
+		// SQLite allows only one writer at a time, wait for the lock rather than failing immediately.
+		dsn := path + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+		return sqlite.Open(dsn), dsn, nil
+
+	default:
+		return nil, "", errors.Errorf("unsupported database type %q", conf.Database.Type)
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// jsonExtractText returns the SQL expression which extracts the given top-level key
+// of the JSON column as text.
+func jsonExtractText(db *gorm.DB, column, key string) string {
+	switch db.Dialector.Name() {
+	case "postgres":
+		return fmt.Sprintf("%s->>'%s'", column, key)
+	case "sqlite":
+		return fmt.Sprintf("json_extract(%s, '$.%s')", column, key)
+	default:
+		return fmt.Sprintf("%s->>'$.%s'", column, key)
+	}
+}
+
+// dateOf returns the SQL expression which truncates the timestamp column to the date.
+func dateOf(db *gorm.DB, column string) string {
+	switch db.Dialector.Name() {
+	case "sqlite":
+		return fmt.Sprintf("date(%s)", column)
+	default:
+		return fmt.Sprintf("DATE(%s)", column)
+	}
+}
+
+// secondsBetween returns the SQL expression of the seconds elapsed from one timestamp column to another.
+func secondsBetween(db *gorm.DB, from, to string) string {
+	switch db.Dialector.Name() {
+	case "postgres":
+		return fmt.Sprintf("EXTRACT(EPOCH FROM (%s - %s))", to, from)
+	case "sqlite":
+		return fmt.Sprintf("(julianday(%s) - julianday(%s)) * 86400", to, from)
+	default:
+		return fmt.Sprintf("TIMESTAMPDIFF(SECOND, %s, %s)", from, to)
+	}
+}
@@ -0,0 +1,270 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/thanhpk/randstr"
+	"gorm.io/gorm"
+
+	"github.com/NekoWheel/NekoBox/internal/security/disposable"
+)
+
+var EmailChanges EmailChangesStore
+
+var _ EmailChangesStore = (*emailChanges)(nil)
+
+type EmailChangesStore interface {
+	Create(ctx context.Context, opts CreateEmailChangeOptions) (*EmailChange, *EmailChangeTokens, error)
+	GetPendingByUserID(ctx context.Context, userID uint) (*EmailChange, error)
+	Confirm(ctx context.Context, verifyToken string) (*EmailChange, error)
+	Cancel(ctx context.Context, cancelToken string) (*EmailChange, error)
+	CancelByUserID(ctx context.Context, userID uint) (int64, error)
+	DeleteExpired(ctx context.Context, expiredBefore time.Time) (int64, error)
+}
+
+func NewEmailChangesStore(db *gorm.DB) EmailChangesStore {
+	return &emailChanges{db}
+}
+
+type emailChanges struct {
+	*gorm.DB
+}
+
+// EmailChange is the pending change of the account email, the email is changed after the new
+// address is verified, and the change can be canceled from the notice sent to the old address.
+// Only the SHA-256 hashes of the tokens are stored.
+type EmailChange struct {
+	ID              uint `gorm:"primarykey"`
+	CreatedAt       time.Time
+	UserID          uint      `gorm:"index:idx_email_change_user_id"`
+	OldEmail        string    `gorm:"type:varchar(255)"`
+	NewEmail        string    `gorm:"type:varchar(255)"`
+	VerifyTokenHash string    `gorm:"type:varchar(64);uniqueIndex:idx_email_change_verify_token_hash"`
+	CancelTokenHash string    `gorm:"type:varchar(64);uniqueIndex:idx_email_change_cancel_token_hash"`
+	FromIP          string    `gorm:"type:varchar(255)"`
+	ExpiresAt       time.Time `gorm:"index:idx_email_change_expires_at"`
+	ConfirmedAt     *time.Time
+	CanceledAt      *time.Time
+}
+
+// EmailChangeTokens are the tokens of the email change which are only sent by mail.
+type EmailChangeTokens struct {
+	// Verify is sent to the new address to confirm the change.
+	Verify string
+	// Cancel is sent to the old address to cancel the change.
+	Cancel string
+}
+
+var (
+	ErrEmailChangeNotExist  = errors.New("邮箱修改申请不存在")
+	ErrEmailChangeExpired   = errors.New("邮箱修改链接已过期，请重新申请")
+	ErrEmailChangeConfirmed = errors.New("邮箱修改已经确认过了")
+	ErrEmailChangeCanceled  = errors.New("邮箱修改申请已被取消")
+	ErrEmailChangeOutdated  = errors.New("账号邮箱已经变更，请重新申请修改")
+	ErrEmailChangeSame      = errors.New("新邮箱与当前邮箱相同")
+)
+
+// hashEmailChangeToken returns the hash of the token stored in the database.
+func hashEmailChangeToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+type CreateEmailChangeOptions struct {
+	UserID   uint
+	OldEmail string
+	NewEmail string
+	FromIP   string
+	TTL      time.Duration
+}
+
+// Create saves the pending change of the user's email, the previous pending change of the user
+// is canceled so only the latest link works. It returns the tokens which are only sent by mail.
+func (db *emailChanges) Create(ctx context.Context, opts CreateEmailChangeOptions) (*EmailChange, *EmailChangeTokens, error) {
+	if opts.NewEmail == opts.OldEmail {
+		return nil, nil, ErrEmailChangeSame
+	}
+	if err := disposable.Check(opts.NewEmail); err != nil {
+		return nil, nil, err
+	}
+
+	var count int64
+	if err := db.WithContext(ctx).Model(&User{}).Where("email = ?", opts.NewEmail).Count(&count).Error; err != nil {
+		return nil, nil, errors.Wrap(err, "count users by email")
+	}
+	if count > 0 {
+		return nil, nil, ErrDuplicateEmail
+	}
+
+	tokens := &EmailChangeTokens{
+		Verify: randstr.Hex(32),
+		Cancel: randstr.Hex(32),
+	}
+	change := EmailChange{
+		UserID:          opts.UserID,
+		OldEmail:        opts.OldEmail,
+		NewEmail:        opts.NewEmail,
+		VerifyTokenHash: hashEmailChangeToken(tokens.Verify),
+		CancelTokenHash: hashEmailChangeToken(tokens.Cancel),
+		FromIP:          opts.FromIP,
+		ExpiresAt:       time.Now().Add(opts.TTL),
+	}
+	if err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&EmailChange{}).
+			Where("user_id = ? AND confirmed_at IS NULL AND canceled_at IS NULL", opts.UserID).
+			Update("canceled_at", time.Now()).Error; err != nil {
+			return errors.Wrap(err, "cancel pending email changes")
+		}
+		if err := tx.Create(&change).Error; err != nil {
+			return errors.Wrap(err, "create email change")
+		}
+		return nil
+	}); err != nil {
+		return nil, nil, err
+	}
+	return &change, tokens, nil
+}
+
+// GetPendingByUserID returns the pending change of the user which has not expired.
+func (db *emailChanges) GetPendingByUserID(ctx context.Context, userID uint) (*EmailChange, error) {
+	var change EmailChange
+	if err := db.WithContext(ctx).
+		Where("user_id = ? AND confirmed_at IS NULL AND canceled_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("id DESC").
+		First(&change).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrEmailChangeNotExist
+		}
+		return nil, errors.Wrap(err, "get pending email change")
+	}
+	return &change, nil
+}
+
+// getPendingByToken returns the change with the given token, the change which is not pending is returned as an error.
+func getPendingByToken(tx *gorm.DB, column, token string) (*EmailChange, error) {
+	var change EmailChange
+	if err := tx.Where(column+" = ?", hashEmailChangeToken(token)).First(&change).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrEmailChangeNotExist
+		}
+		return nil, errors.Wrap(err, "get email change by token")
+	}
+
+	switch {
+	case change.CanceledAt != nil:
+		return nil, ErrEmailChangeCanceled
+	case change.ConfirmedAt != nil:
+		return nil, ErrEmailChangeConfirmed
+	case time.Now().After(change.ExpiresAt):
+		return nil, ErrEmailChangeExpired
+	}
+	return &change, nil
+}
+
+// Confirm changes the email of the user to the verified new address. The email of the account and
+// the reply address of the questions asked by the user are changed in the same transaction, so the
+// notifications are never sent to the mixed addresses.
+func (db *emailChanges) Confirm(ctx context.Context, verifyToken string) (*EmailChange, error) {
+	ctx = WithPrimary(ctx)
+
+	var change *EmailChange
+	if err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		change, err = getPendingByToken(tx, "verify_token_hash", verifyToken)
+		if err != nil {
+			return err
+		}
+
+		// The new address may have been registered by others after the change is requested.
+		var count int64
+		if err := tx.Model(&User{}).Where("email = ? AND id <> ?", change.NewEmail, change.UserID).Count(&count).Error; err != nil {
+			return errors.Wrap(err, "count users by email")
+		}
+		if count > 0 {
+			return ErrDuplicateEmail
+		}
+
+		result := tx.Model(&User{}).Where("id = ? AND email = ?", change.UserID, change.OldEmail).Update("email", change.NewEmail)
+		if result.Error != nil {
+			return errors.Wrap(result.Error, "update user email")
+		}
+		if result.RowsAffected == 0 {
+			return ErrEmailChangeOutdated
+		}
+
+		if err := tx.Model(&Question{}).
+			Where("asker_user_id = ? AND receive_reply_email = ?", change.UserID, change.OldEmail).
+			Update("receive_reply_email", change.NewEmail).Error; err != nil {
+			return errors.Wrap(err, "update reply email of asked questions")
+		}
+
+		// The change may be confirmed or canceled by the concurrent request, only one of them succeeds.
+		now := time.Now()
+		result = tx.Model(&EmailChange{}).
+			Where("id = ? AND confirmed_at IS NULL AND canceled_at IS NULL", change.ID).
+			Update("confirmed_at", now)
+		if result.Error != nil {
+			return errors.Wrap(result.Error, "confirm email change")
+		}
+		if result.RowsAffected == 0 {
+			return ErrEmailChangeConfirmed
+		}
+		change.ConfirmedAt = &now
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return change, nil
+}
+
+// Cancel cancels the pending change with the token sent to the old address.
+func (db *emailChanges) Cancel(ctx context.Context, cancelToken string) (*EmailChange, error) {
+	ctx = WithPrimary(ctx)
+
+	change, err := getPendingByToken(db.WithContext(ctx), "cancel_token_hash", cancelToken)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	result := db.WithContext(ctx).Model(&EmailChange{}).
+		Where("id = ? AND confirmed_at IS NULL AND canceled_at IS NULL", change.ID).
+		Update("canceled_at", now)
+	if result.Error != nil {
+		return nil, errors.Wrap(result.Error, "cancel email change")
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrEmailChangeConfirmed
+	}
+	change.CanceledAt = &now
+	return change, nil
+}
+
+// CancelByUserID cancels all the pending changes of the user, it returns the number of the canceled changes.
+func (db *emailChanges) CancelByUserID(ctx context.Context, userID uint) (int64, error) {
+	result := db.WithContext(ctx).Model(&EmailChange{}).
+		Where("user_id = ? AND confirmed_at IS NULL AND canceled_at IS NULL", userID).
+		Update("canceled_at", time.Now())
+	if result.Error != nil {
+		return 0, errors.Wrap(result.Error, "cancel email changes")
+	}
+	return result.RowsAffected, nil
+}
+
+// DeleteExpired deletes the changes which expired before the given time,
+// it returns the number of the deleted changes.
+func (db *emailChanges) DeleteExpired(ctx context.Context, expiredBefore time.Time) (int64, error) {
+	result := db.WithContext(ctx).Where("expires_at < ?", expiredBefore).Delete(&EmailChange{})
+	if result.Error != nil {
+		return 0, errors.Wrap(result.Error, "delete expired email changes")
+	}
+	return result.RowsAffected, nil
+}
@@ -0,0 +1,79 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+
+	"github.com/NekoWheel/NekoBox/internal/dbutil"
+)
+
+var EmailSuppressions EmailSuppressionsStore
+
+var _ EmailSuppressionsStore = (*emailSuppressions)(nil)
+
+type EmailSuppressionsStore interface {
+	Create(ctx context.Context, email string, reason EmailSuppressionReason) error
+	IsSuppressed(ctx context.Context, email string) (bool, error)
+}
+
+func NewEmailSuppressionsStore(db *gorm.DB) EmailSuppressionsStore {
+	return &emailSuppressions{db}
+}
+
+type emailSuppressions struct {
+	*gorm.DB
+}
+
+type EmailSuppressionReason string
+
+const (
+	EmailSuppressionReasonUnsubscribed EmailSuppressionReason = "unsubscribed"
+)
+
+// EmailSuppression is the email address which no longer receives the notification mails,
+// e.g. the asker who unsubscribed from the answer notifications.
+type EmailSuppression struct {
+	dbutil.Model
+	Email  string                 `gorm:"type:varchar(255);uniqueIndex:idx_email_suppression_email" json:"email"`
+	Reason EmailSuppressionReason `gorm:"type:varchar(32)" json:"reason"`
+}
+
+// normalizeSuppressionEmail returns the email address as stored in the suppression list,
+// the addresses are matched case-insensitively.
+func normalizeSuppressionEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// Create adds the email address to the suppression list, it does nothing if the address is already suppressed.
+func (db *emailSuppressions) Create(ctx context.Context, email string, reason EmailSuppressionReason) error {
+	email = normalizeSuppressionEmail(email)
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&EmailSuppression{}).Where("email = ?", email).Count(&count).Error; err != nil {
+			return errors.Wrap(err, "count email suppression")
+		}
+		if count > 0 {
+			return nil
+		}
+
+		if err := tx.Create(&EmailSuppression{Email: email, Reason: reason}).Error; err != nil {
+			return errors.Wrap(err, "create email suppression")
+		}
+		return nil
+	})
+}
+
+func (db *emailSuppressions) IsSuppressed(ctx context.Context, email string) (bool, error) {
+	var count int64
+	if err := db.WithContext(ctx).Model(&EmailSuppression{}).Where("email = ?", normalizeSuppressionEmail(email)).Count(&count).Error; err != nil {
+		return false, errors.Wrap(err, "count email suppression")
+	}
+	return count > 0, nil
+}
@@ -0,0 +1,108 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+
+	"github.com/NekoWheel/NekoBox/internal/conf"
+)
+
+// ipHashPrefix marks the stored IP address which has been replaced by its salted hash.
+const ipHashPrefix = "hash:"
+
+// hashIP hashes the IP address, so that we don't need to store the raw IP address of the asker.
+func hashIP(ip string) string {
+	sum := sha256.Sum256([]byte(ip))
+	return hex.EncodeToString(sum[:])
+}
+
+// saltedHashIP hashes the IP address with the server salt. Unlike hashIP, the hash
+// can't be reversed by enumerating all the IP addresses without knowing the salt.
+func saltedHashIP(ip string) string {
+	mac := hmac.New(sha256.New, []byte(conf.Server.Salt))
+	_, _ = mac.Write([]byte(ip))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// visitorIPHashes returns the hashes which identify the visitor with the IP address,
+// the records saved before the IP address was hashed with the salt are still matched.
+func visitorIPHashes(ip string) []string {
+	return []string{saltedHashIP(ip), hashIP(ip)}
+}
+
+// storeIP returns the value of the `from_ip` field for the IP address,
+// it is the salted hash if the raw IP addresses are not kept.
+func storeIP(ip string) string {
+	if ip == "" || conf.Security.IPStorage != "hash" {
+		return ip
+	}
+	return ipHashPrefix + saltedHashIP(ip)
+}
+
+// storedIPHash returns the salted hash of the `from_ip` field, which is either the raw or the hashed IP address.
+func storedIPHash(stored string) string {
+	if strings.HasPrefix(stored, ipHashPrefix) {
+		return strings.TrimPrefix(stored, ipHashPrefix)
+	}
+	return saltedHashIP(stored)
+}
+
+type ExpireIPsOptions struct {
+	// CreatedBefore is the time before which the raw IP addresses are expired.
+	CreatedBefore time.Time
+	// Clear clears the expired IP addresses instead of hashing them,
+	// the askers of the cleared questions can't be blocked any more.
+	Clear bool
+}
+
+// expireIPsBatchSize is the number of the distinct IP addresses hashed in one round.
+const expireIPsBatchSize = 500
+
+// expireIPs hashes or clears the raw IP addresses of the model which are created before the given time,
+// and returns the number of the updated rows. The trashed rows are included.
+func expireIPs(ctx context.Context, db *gorm.DB, model interface{}, opts ExpireIPsOptions) (int64, error) {
+	expired := func() *gorm.DB {
+		return db.WithContext(ctx).Unscoped().Model(model).
+			Where("created_at < ? AND from_ip <> '' AND from_ip NOT LIKE ?", opts.CreatedBefore, ipHashPrefix+"%")
+	}
+
+	// The `updated_at` field is kept, the hashing is not a change made by the user.
+	if opts.Clear {
+		result := expired().UpdateColumn("from_ip", "")
+		if result.Error != nil {
+			return 0, errors.Wrap(result.Error, "clear IP addresses")
+		}
+		return result.RowsAffected, nil
+	}
+
+	// The hash can't be computed by the database, so the distinct IP addresses are hashed in batches.
+	var total int64
+	for {
+		var ips []string
+		if err := expired().Distinct("from_ip").Limit(expireIPsBatchSize).Pluck("from_ip", &ips).Error; err != nil {
+			return total, errors.Wrap(err, "pluck IP addresses")
+		}
+		if len(ips) == 0 {
+			return total, nil
+		}
+
+		for _, ip := range ips {
+			result := expired().Where("from_ip = ?", ip).UpdateColumn("from_ip", ipHashPrefix+saltedHashIP(ip))
+			if result.Error != nil {
+				return total, errors.Wrap(result.Error, "hash IP address")
+			}
+			total += result.RowsAffected
+		}
+	}
+}
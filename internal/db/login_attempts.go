@@ -0,0 +1,130 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+var LoginAttempts LoginAttemptsStore
+
+var _ LoginAttemptsStore = (*loginAttempts)(nil)
+
+type LoginAttemptsStore interface {
+	Create(ctx context.Context, opts CreateLoginAttemptOptions) error
+	Count(ctx context.Context, opts CountLoginAttemptsOptions) (int64, *time.Time, error)
+	GetByUserID(ctx context.Context, userID uint, limit int) ([]*LoginAttempt, error)
+	ClearByEmail(ctx context.Context, email string) error
+	DeleteBefore(ctx context.Context, createdBefore time.Time) (int64, error)
+}
+
+func NewLoginAttemptsStore(db *gorm.DB) LoginAttemptsStore {
+	return &loginAttempts{db}
+}
+
+type loginAttempts struct {
+	*gorm.DB
+}
+
+// LoginAttempt is a failed login. The attempts are counted per account and per IP address to
+// throttle the brute-force logins, the cleared attempts are not counted but still shown to the user.
+type LoginAttempt struct {
+	ID        uint      `gorm:"primarykey" json:"-"`
+	CreatedAt time.Time `gorm:"index:idx_login_attempt_created_at" json:"created_at"`
+	UserID    uint      `gorm:"index:idx_login_attempt_user_id" json:"-"`
+	Email     string    `gorm:"type:varchar(255);index:idx_login_attempt_email" json:"-"`
+	IP        string    `gorm:"type:varchar(255);index:idx_login_attempt_ip" json:"ip"`
+	UserAgent string    `gorm:"type:varchar(255)" json:"user_agent"`
+	Cleared   bool      `gorm:"not null;default:false" json:"-"`
+}
+
+type CreateLoginAttemptOptions struct {
+	UserID    uint
+	Email     string
+	IP        string
+	UserAgent string
+}
+
+func (db *loginAttempts) Create(ctx context.Context, opts CreateLoginAttemptOptions) error {
+	userAgent := opts.UserAgent
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+
+	if err := db.WithContext(ctx).Create(&LoginAttempt{
+		UserID:    opts.UserID,
+		Email:     opts.Email,
+		IP:        opts.IP,
+		UserAgent: userAgent,
+	}).Error; err != nil {
+		return errors.Wrap(err, "create login attempt")
+	}
+	return nil
+}
+
+type CountLoginAttemptsOptions struct {
+	// Email and IP are the conditions of the attempts, only one of them should be set.
+	Email        string
+	IP           string
+	CreatedAfter time.Time
+}
+
+// Count returns the number of the uncleared failed logins matching the options,
+// and the time of the latest one. The time is nil if there is none.
+func (db *loginAttempts) Count(ctx context.Context, opts CountLoginAttemptsOptions) (int64, *time.Time, error) {
+	q := db.WithContext(ctx).Model(&LoginAttempt{}).Where("created_at > ? AND cleared = ?", opts.CreatedAfter, false)
+	if opts.Email != "" {
+		q = q.Where("email = ?", opts.Email)
+	}
+	if opts.IP != "" {
+		q = q.Where("ip = ?", opts.IP)
+	}
+
+	var count int64
+	if err := q.Session(&gorm.Session{}).Count(&count).Error; err != nil {
+		return 0, nil, errors.Wrap(err, "count login attempts")
+	}
+	if count == 0 {
+		return 0, nil, nil
+	}
+
+	var latest LoginAttempt
+	if err := q.Order("created_at DESC").First(&latest).Error; err != nil {
+		return 0, nil, errors.Wrap(err, "get latest login attempt")
+	}
+	return count, &latest.CreatedAt, nil
+}
+
+// GetByUserID returns the latest failed logins of the user, including the cleared ones.
+func (db *loginAttempts) GetByUserID(ctx context.Context, userID uint, limit int) ([]*LoginAttempt, error) {
+	var attempts []*LoginAttempt
+	if err := db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at DESC").Limit(limit).Find(&attempts).Error; err != nil {
+		return nil, errors.Wrap(err, "get login attempts by user ID")
+	}
+	return attempts, nil
+}
+
+// ClearByEmail stops counting the failed logins of the account, it is called after the user signs in successfully.
+func (db *loginAttempts) ClearByEmail(ctx context.Context, email string) error {
+	if err := db.WithContext(ctx).Model(&LoginAttempt{}).
+		Where("email = ? AND cleared = ?", email, false).
+		Update("cleared", true).Error; err != nil {
+		return errors.Wrap(err, "clear login attempts")
+	}
+	return nil
+}
+
+// DeleteBefore deletes the failed logins created before the given time, it returns the number of the deleted attempts.
+func (db *loginAttempts) DeleteBefore(ctx context.Context, createdBefore time.Time) (int64, error) {
+	result := db.WithContext(ctx).Where("created_at < ?", createdBefore).Delete(&LoginAttempt{})
+	if result.Error != nil {
+		return 0, errors.Wrap(result.Error, "delete login attempts")
+	}
+	return result.RowsAffected, nil
+}
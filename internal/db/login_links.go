@@ -0,0 +1,132 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/thanhpk/randstr"
+	"gorm.io/gorm"
+)
+
+var LoginLinks LoginLinksStore
+
+var _ LoginLinksStore = (*loginLinks)(nil)
+
+type LoginLinksStore interface {
+	Create(ctx context.Context, opts CreateLoginLinkOptions) (*LoginLink, string, error)
+	Count(ctx context.Context, userID uint, createdAfter time.Time) (int64, error)
+	Consume(ctx context.Context, token string) (*LoginLink, error)
+	DeleteExpired(ctx context.Context, expiredBefore time.Time) (int64, error)
+}
+
+func NewLoginLinksStore(db *gorm.DB) LoginLinksStore {
+	return &loginLinks{db}
+}
+
+type loginLinks struct {
+	*gorm.DB
+}
+
+// LoginLink is the one-time link sent by mail, with which the user signs in without the password.
+// Only the SHA-256 hash of the token is stored.
+type LoginLink struct {
+	ID         uint `gorm:"primarykey"`
+	CreatedAt  time.Time
+	UserID     uint      `gorm:"index:idx_login_link_user_id"`
+	TokenHash  string    `gorm:"type:varchar(64);uniqueIndex:idx_login_link_token_hash"`
+	FromIP     string    `gorm:"type:varchar(255)"`
+	ExpiresAt  time.Time `gorm:"index:idx_login_link_expires_at"`
+	ConsumedAt *time.Time
+}
+
+var (
+	ErrLoginLinkNotExist = errors.New("登录链接不存在")
+	ErrLoginLinkExpired  = errors.New("登录链接已过期，请重新获取")
+	ErrLoginLinkConsumed = errors.New("登录链接已被使用，请重新获取")
+)
+
+// hashLoginLinkToken returns the hash of the token stored in the database.
+func hashLoginLinkToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+type CreateLoginLinkOptions struct {
+	UserID uint
+	FromIP string
+	TTL    time.Duration
+}
+
+// Create creates a new login link, it returns the token which is only sent by mail.
+func (db *loginLinks) Create(ctx context.Context, opts CreateLoginLinkOptions) (*LoginLink, string, error) {
+	token := randstr.Hex(32)
+	loginLink := LoginLink{
+		UserID:    opts.UserID,
+		TokenHash: hashLoginLinkToken(token),
+		FromIP:    opts.FromIP,
+		ExpiresAt: time.Now().Add(opts.TTL),
+	}
+	if err := db.WithContext(ctx).Create(&loginLink).Error; err != nil {
+		return nil, "", errors.Wrap(err, "create login link")
+	}
+	return &loginLink, token, nil
+}
+
+// Count returns the number of the login links created for the user after the given time.
+func (db *loginLinks) Count(ctx context.Context, userID uint, createdAfter time.Time) (int64, error) {
+	var count int64
+	if err := db.WithContext(ctx).Model(&LoginLink{}).Where("user_id = ? AND created_at > ?", userID, createdAfter).Count(&count).Error; err != nil {
+		return 0, errors.Wrap(err, "count login links")
+	}
+	return count, nil
+}
+
+// Consume marks the login link as used and returns it, each link can only be consumed once.
+func (db *loginLinks) Consume(ctx context.Context, token string) (*LoginLink, error) {
+	ctx = WithPrimary(ctx)
+
+	var loginLink LoginLink
+	if err := db.WithContext(ctx).Where("token_hash = ?", hashLoginLinkToken(token)).First(&loginLink).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrLoginLinkNotExist
+		}
+		return nil, errors.Wrap(err, "get login link by token")
+	}
+	if loginLink.ConsumedAt != nil {
+		return nil, ErrLoginLinkConsumed
+	}
+	now := time.Now()
+	if now.After(loginLink.ExpiresAt) {
+		return nil, ErrLoginLinkExpired
+	}
+
+	// The link may be consumed by the concurrent request, only one of them succeeds.
+	result := db.WithContext(ctx).Model(&LoginLink{}).
+		Where("id = ? AND consumed_at IS NULL", loginLink.ID).
+		Update("consumed_at", now)
+	if result.Error != nil {
+		return nil, errors.Wrap(result.Error, "consume login link")
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrLoginLinkConsumed
+	}
+	loginLink.ConsumedAt = &now
+	return &loginLink, nil
+}
+
+// DeleteExpired deletes the login links which expired before the given time,
+// it returns the number of the deleted links.
+func (db *loginLinks) DeleteExpired(ctx context.Context, expiredBefore time.Time) (int64, error) {
+	result := db.WithContext(ctx).Where("expires_at < ?", expiredBefore).Delete(&LoginLink{})
+	if result.Error != nil {
+		return 0, errors.Wrap(result.Error, "delete expired login links")
+	}
+	return result.RowsAffected, nil
+}
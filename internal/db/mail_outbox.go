@@ -0,0 +1,196 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+
+	"github.com/NekoWheel/NekoBox/internal/dbutil"
+)
+
+var MailOutbox MailOutboxStore
+
+var _ MailOutboxStore = (*mailOutbox)(nil)
+
+type MailOutboxStore interface {
+	Create(ctx context.Context, opts CreateOutboxMailOptions) (*OutboxMail, error)
+	ListDue(ctx context.Context, limit int) ([]*OutboxMail, error)
+	CountPending(ctx context.Context) (int64, error)
+	Claim(ctx context.Context, id uint, lease time.Duration) (bool, error)
+	MarkSent(ctx context.Context, id uint, provider string) error
+	MarkFailed(ctx context.Context, id uint, opts MarkOutboxMailFailedOptions) error
+	PurgeSent(ctx context.Context, sentBefore time.Time) (int64, error)
+	PurgeUndelivered(ctx context.Context, failedBefore time.Time) (int64, error)
+}
+
+func NewMailOutboxStore(db *gorm.DB) MailOutboxStore {
+	return &mailOutbox{db}
+}
+
+type mailOutbox struct {
+	*gorm.DB
+}
+
+type OutboxMailStatus string
+
+const (
+	OutboxMailStatusPending OutboxMailStatus = "pending"
+	OutboxMailStatusSent    OutboxMailStatus = "sent"
+	// OutboxMailStatusFailed means the mail is given up after too many failed attempts.
+	OutboxMailStatusFailed OutboxMailStatus = "failed"
+	// OutboxMailStatusBounced means the mail is rejected permanently by the provider,
+	// e.g. the recipient address does not exist.
+	OutboxMailStatusBounced OutboxMailStatus = "bounced"
+)
+
+// OutboxMail is the mail waiting to be delivered, the delivered and failed mails are kept
+// for a while to diagnose the lost notification mails. The content of the failed mails is cleared,
+// as it may contain the login links and the verification tokens.
+type OutboxMail struct {
+	dbutil.Model
+	To            string `gorm:"index:idx_outbox_mail_to"`
+	ReplyTo       string `gorm:"type:varchar(255)"`
+	Subject       string `gorm:"type:varchar(255)"`
+	Content       string
+	Status        OutboxMailStatus `gorm:"type:varchar(20);index:idx_outbox_mail_status_next_attempt"`
+	Attempts      int
+	NextAttemptAt time.Time `gorm:"index:idx_outbox_mail_status_next_attempt"`
+	Provider      string
+	LastError     string
+	SentAt        *time.Time
+	// TraceParent is the W3C trace context of the request which enqueued the mail,
+	// so the delivery is traced in the same trace.
+	TraceParent string `gorm:"type:varchar(64)"`
+}
+
+type CreateOutboxMailOptions struct {
+	To          string
+	ReplyTo     string
+	Subject     string
+	Content     string
+	TraceParent string
+}
+
+func (db *mailOutbox) Create(ctx context.Context, opts CreateOutboxMailOptions) (*OutboxMail, error) {
+	mail := OutboxMail{
+		To:            opts.To,
+		ReplyTo:       opts.ReplyTo,
+		Subject:       opts.Subject,
+		Content:       opts.Content,
+		Status:        OutboxMailStatusPending,
+		NextAttemptAt: time.Now(),
+		TraceParent:   opts.TraceParent,
+	}
+	if err := db.WithContext(ctx).Create(&mail).Error; err != nil {
+		return nil, errors.Wrap(err, "create outbox mail")
+	}
+	return &mail, nil
+}
+
+// ListDue returns the pending mails which should be delivered now.
+func (db *mailOutbox) ListDue(ctx context.Context, limit int) ([]*OutboxMail, error) {
+	var mails []*OutboxMail
+	if err := db.WithContext(ctx).
+		Where("status = ? AND next_attempt_at <= ?", OutboxMailStatusPending, time.Now()).
+		Order("next_attempt_at ASC").
+		Limit(limit).
+		Find(&mails).Error; err != nil {
+		return nil, errors.Wrap(err, "list due outbox mails")
+	}
+	return mails, nil
+}
+
+// CountPending returns the number of the mails waiting to be delivered, including the ones being retried.
+func (db *mailOutbox) CountPending(ctx context.Context) (int64, error) {
+	var count int64
+	if err := db.WithContext(ctx).Model(&OutboxMail{}).
+		Where("status = ?", OutboxMailStatusPending).
+		Count(&count).Error; err != nil {
+		return 0, errors.Wrap(err, "count pending outbox mails")
+	}
+	return count, nil
+}
+
+// Claim takes the pending mail for delivering by postponing its next attempt with the given lease,
+// it returns false if the mail has been claimed by others. The mail will be retried after the lease
+// if the delivery is interrupted.
+func (db *mailOutbox) Claim(ctx context.Context, id uint, lease time.Duration) (bool, error) {
+	now := time.Now()
+	tx := db.WithContext(ctx).Model(&OutboxMail{}).
+		Where("id = ? AND status = ? AND next_attempt_at <= ?", id, OutboxMailStatusPending, now).
+		Update("next_attempt_at", now.Add(lease))
+	if tx.Error != nil {
+		return false, errors.Wrap(tx.Error, "claim outbox mail")
+	}
+	return tx.RowsAffected == 1, nil
+}
+
+func (db *mailOutbox) MarkSent(ctx context.Context, id uint, provider string) error {
+	if err := db.WithContext(ctx).Model(&OutboxMail{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":   OutboxMailStatusSent,
+		"attempts": gorm.Expr("attempts + 1"),
+		"provider": provider,
+		"sent_at":  time.Now(),
+	}).Error; err != nil {
+		return errors.Wrap(err, "mark outbox mail sent")
+	}
+	return nil
+}
+
+type MarkOutboxMailFailedOptions struct {
+	Provider string
+	Error    string
+	// Status is the status after the failure, the mail is retried at NextAttemptAt when it is still pending.
+	Status        OutboxMailStatus
+	NextAttemptAt time.Time
+}
+
+func (db *mailOutbox) MarkFailed(ctx context.Context, id uint, opts MarkOutboxMailFailedOptions) error {
+	updates := map[string]interface{}{
+		"status":     opts.Status,
+		"attempts":   gorm.Expr("attempts + 1"),
+		"provider":   opts.Provider,
+		"last_error": opts.Error,
+	}
+	if !opts.NextAttemptAt.IsZero() {
+		updates["next_attempt_at"] = opts.NextAttemptAt
+	}
+	// The mail is never sent again once it is given up, only the recipient and the error are needed to diagnose.
+	if opts.Status != OutboxMailStatusPending {
+		updates["content"] = ""
+	}
+
+	if err := db.WithContext(ctx).Model(&OutboxMail{}).Where("id = ?", id).Updates(updates).Error; err != nil {
+		return errors.Wrap(err, "mark outbox mail failed")
+	}
+	return nil
+}
+
+// PurgeSent permanently deletes the mails which have been delivered before the given time,
+// the failed and bounced mails are kept longer for diagnosing, see PurgeUndelivered.
+func (db *mailOutbox) PurgeSent(ctx context.Context, sentBefore time.Time) (int64, error) {
+	tx := db.WithContext(ctx).Unscoped().
+		Where("status = ? AND sent_at < ?", OutboxMailStatusSent, sentBefore).
+		Delete(&OutboxMail{})
+	if tx.Error != nil {
+		return 0, errors.Wrap(tx.Error, "purge sent outbox mails")
+	}
+	return tx.RowsAffected, nil
+}
+
+// PurgeUndelivered permanently deletes the failed and bounced mails which were given up before the given time.
+func (db *mailOutbox) PurgeUndelivered(ctx context.Context, failedBefore time.Time) (int64, error) {
+	tx := db.WithContext(ctx).Unscoped().
+		Where("status IN (?) AND updated_at < ?", []OutboxMailStatus{OutboxMailStatusFailed, OutboxMailStatusBounced}, failedBefore).
+		Delete(&OutboxMail{})
+	if tx.Error != nil {
+		return 0, errors.Wrap(tx.Error, "purge undelivered outbox mails")
+	}
+	return tx.RowsAffected, nil
+}
@@ -0,0 +1,219 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package migrations
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"github.com/pkg/errors"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// initialSchema creates the tables as they were before the versioned migrations were introduced.
+// The existing deployments were created by the auto migration, so every step here must be idempotent.
+var initialSchema = &gormigrate.Migration{
+	ID: "0001_initial_schema",
+	Migrate: func(tx *gorm.DB) error {
+		type Model struct {
+			ID        uint `gorm:"primarykey"`
+			CreatedAt time.Time
+			UpdatedAt time.Time
+			DeletedAt gorm.DeletedAt `gorm:"index"`
+		}
+
+		type User struct {
+			gorm.Model
+			Name                    string
+			Password                string
+			Email                   string
+			Avatar                  string
+			Domain                  string
+			Background              string
+			Intro                   string
+			Notify                  string
+			HarassmentSetting       string
+			BoxSettings             string `gorm:"type:json"`
+			NotificationPreferences string `gorm:"type:json"`
+			DigestWatermark         uint
+			DigestSentAt            *time.Time
+		}
+
+		type Question struct {
+			Model
+			FromIP                string
+			UserID                uint `gorm:"index:idx_question_user_id"`
+			Content               string
+			ContentCensorMetadata datatypes.JSON
+			ContentCensorPass     bool `gorm:"not null;default:false"`
+			Token                 string
+			Answer                string
+			AnswerCensorMetadata  datatypes.JSON
+			AnswerCensorPass      bool `gorm:"not null;default:false"`
+			AnswerUpdatedAt       *time.Time
+			ReceiveReplyEmail     string
+			AskerUserID           uint
+			Pinned                bool `gorm:"index:idx_question_pinned"`
+			PinnedAt              *time.Time
+			LikeCount             uint `gorm:"not null;default:0"`
+		}
+
+		type QuestionReply struct {
+			Model
+			QuestionID            uint `gorm:"index:idx_question_reply_question_id"`
+			FromIP                string
+			IsOwner               bool
+			Content               string
+			ContentCensorMetadata datatypes.JSON
+		}
+
+		type QuestionReaction struct {
+			Model
+			QuestionID uint   `gorm:"uniqueIndex:idx_question_reaction_source"`
+			Type       string `gorm:"uniqueIndex:idx_question_reaction_source;type:varchar(16)"`
+			UserID     uint   `gorm:"uniqueIndex:idx_question_reaction_source"`
+			IPHash     string `gorm:"uniqueIndex:idx_question_reaction_source;type:varchar(64)"`
+		}
+
+		type QuestionTag struct {
+			Model
+			UserID     uint   `gorm:"index:idx_question_tag_user_name"`
+			QuestionID uint   `gorm:"uniqueIndex:idx_question_tag_question_name"`
+			Name       string `gorm:"index:idx_question_tag_user_name;uniqueIndex:idx_question_tag_question_name;type:varchar(20)"`
+		}
+
+		type Block struct {
+			Model
+			UserID          uint   `gorm:"uniqueIndex:idx_block_source"`
+			AskerUserID     uint   `gorm:"uniqueIndex:idx_block_source"`
+			AskerIPHash     string `gorm:"uniqueIndex:idx_block_source;type:varchar(64)"`
+			QuestionID      uint
+			QuestionContent string
+		}
+
+		type Webhook struct {
+			Model
+			UserID uint `gorm:"index:idx_webhook_user_id"`
+			URL    string
+			Secret string
+		}
+
+		type TwoFactor struct {
+			Model
+			UserID uint `gorm:"uniqueIndex:idx_two_factor_user_id"`
+			Secret string
+		}
+
+		type TwoFactorRecoveryCode struct {
+			Model
+			UserID   uint   `gorm:"index:idx_two_factor_recovery_code_user_id"`
+			CodeHash string `gorm:"type:varchar(64)"`
+			IsUsed   bool
+		}
+
+		type OutboxMail struct {
+			Model
+			To            string `gorm:"index:idx_outbox_mail_to"`
+			Subject       string `gorm:"type:varchar(255)"`
+			Content       string
+			Status        string `gorm:"type:varchar(20);index:idx_outbox_mail_status_next_attempt"`
+			Attempts      int
+			NextAttemptAt time.Time `gorm:"index:idx_outbox_mail_status_next_attempt"`
+			Provider      string
+			LastError     string
+			SentAt        *time.Time
+			TraceParent   string `gorm:"type:varchar(64)"`
+		}
+
+		type CensorLog struct {
+			gorm.Model
+			SourceName  string
+			Input       string
+			InputHash   string `gorm:"index"`
+			Pass        bool
+			RawResponse json.RawMessage
+		}
+
+		if err := convertCensorPassColumns(tx); err != nil {
+			return errors.Wrap(err, "convert censor pass columns")
+		}
+		if err := tx.AutoMigrate(&User{}, &Question{}, &QuestionReply{}, &QuestionReaction{}, &QuestionTag{}, &Block{}, &Webhook{}, &TwoFactor{}, &TwoFactorRecoveryCode{}, &OutboxMail{}, &CensorLog{}); err != nil {
+			return errors.Wrap(err, "auto migrate")
+		}
+		if err := createQuestionFullTextIndex(tx); err != nil {
+			return errors.Wrap(err, "create question full-text index")
+		}
+		return nil
+	},
+	Rollback: func(tx *gorm.DB) error {
+		return tx.Migrator().DropTable(
+			"censor_logs", "outbox_mails", "two_factor_recovery_codes", "two_factors", "webhooks",
+			"blocks", "question_tags", "question_reactions", "question_replies", "questions", "users",
+		)
+	},
+}
+
+// convertCensorPassColumns converts the question's censor pass columns, which were the generated
+// columns computed from the censor metadata, to the normal columns maintained by the application.
+// The generated columns are dialect specific, which can not be created on SQLite.
+func convertCensorPassColumns(tx *gorm.DB) error {
+	if !tx.Migrator().HasTable("questions") {
+		return nil
+	}
+
+	for _, column := range []string{"content_censor_pass", "answer_censor_pass"} {
+		var query string
+		switch tx.Dialector.Name() {
+		case "mysql":
+			query = "SELECT COUNT(*) FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'questions' AND COLUMN_NAME = ? AND GENERATION_EXPRESSION <> ''"
+		case "postgres":
+			query = "SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = CURRENT_SCHEMA() AND table_name = 'questions' AND column_name = ? AND is_generated = 'ALWAYS'"
+		default:
+			return nil
+		}
+
+		var count int64
+		if err := tx.Raw(query, column).Scan(&count).Error; err != nil {
+			return errors.Wrapf(err, "check generated column %q", column)
+		}
+		if count == 0 {
+			continue
+		}
+
+		// The computed values are kept after the conversion.
+		var statements []string
+		if tx.Dialector.Name() == "mysql" {
+			statements = []string{fmt.Sprintf("ALTER TABLE questions MODIFY COLUMN %s BOOLEAN NOT NULL DEFAULT FALSE", column)}
+		} else {
+			statements = []string{
+				fmt.Sprintf("ALTER TABLE questions ALTER COLUMN %s DROP EXPRESSION", column),
+				fmt.Sprintf("ALTER TABLE questions ALTER COLUMN %s SET DEFAULT FALSE", column),
+			}
+		}
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return errors.Wrapf(err, "convert generated column %q", column)
+			}
+		}
+	}
+	return nil
+}
+
+const questionFullTextIndexName = "idx_question_fulltext"
+
+// createQuestionFullTextIndex creates the full-text index on the question's content and answer.
+// The ngram parser is used to support CJK characters. Other dialects search with LIKE instead.
+func createQuestionFullTextIndex(tx *gorm.DB) error {
+	if tx.Dialector.Name() != "mysql" {
+		return nil
+	}
+	if tx.Migrator().HasIndex("questions", questionFullTextIndexName) {
+		return nil
+	}
+	return tx.Exec(fmt.Sprintf("CREATE FULLTEXT INDEX %s ON questions (content, answer) WITH PARSER ngram", questionFullTextIndexName)).Error
+}
@@ -0,0 +1,31 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+var questionDrafts = &gormigrate.Migration{
+	ID: "0002_question_drafts",
+	Migrate: func(tx *gorm.DB) error {
+		type QuestionDraft struct {
+			ID             uint `gorm:"primarykey"`
+			CreatedAt      time.Time
+			UpdatedAt      time.Time
+			DeletedAt      gorm.DeletedAt `gorm:"index"`
+			UserID         uint           `gorm:"uniqueIndex:idx_question_draft_user_receiver"`
+			ReceiverUserID uint           `gorm:"uniqueIndex:idx_question_draft_user_receiver"`
+			Content        string
+		}
+		return tx.AutoMigrate(&QuestionDraft{})
+	},
+	Rollback: func(tx *gorm.DB) error {
+		return tx.Migrator().DropTable("question_drafts")
+	},
+}
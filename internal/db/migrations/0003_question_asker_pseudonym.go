@@ -0,0 +1,29 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+var questionAskerPseudonym = &gormigrate.Migration{
+	ID: "0003_question_asker_pseudonym",
+	Migrate: func(tx *gorm.DB) error {
+		type Question struct {
+			AskerPseudonym string `gorm:"type:varchar(32)"`
+		}
+		if tx.Migrator().HasColumn(&Question{}, "AskerPseudonym") {
+			return nil
+		}
+		return tx.Migrator().AddColumn(&Question{}, "AskerPseudonym")
+	},
+	Rollback: func(tx *gorm.DB) error {
+		type Question struct {
+			AskerPseudonym string `gorm:"type:varchar(32)"`
+		}
+		return tx.Migrator().DropColumn(&Question{}, "AskerPseudonym")
+	},
+}
@@ -0,0 +1,54 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+var prompts = &gormigrate.Migration{
+	ID: "0004_prompts",
+	Migrate: func(tx *gorm.DB) error {
+		type Prompt struct {
+			ID        uint `gorm:"primarykey"`
+			CreatedAt time.Time
+			UpdatedAt time.Time
+			DeletedAt gorm.DeletedAt `gorm:"index"`
+			UserID    uint           `gorm:"index:idx_prompt_user_id"`
+			Content   string
+		}
+		if err := tx.AutoMigrate(&Prompt{}); err != nil {
+			return err
+		}
+
+		type Question struct {
+			PromptID uint `gorm:"index:idx_question_prompt_id"`
+		}
+		if !tx.Migrator().HasColumn(&Question{}, "PromptID") {
+			if err := tx.Migrator().AddColumn(&Question{}, "PromptID"); err != nil {
+				return err
+			}
+		}
+		if !tx.Migrator().HasIndex(&Question{}, "idx_question_prompt_id") {
+			return tx.Migrator().CreateIndex(&Question{}, "idx_question_prompt_id")
+		}
+		return nil
+	},
+	Rollback: func(tx *gorm.DB) error {
+		type Question struct {
+			PromptID uint `gorm:"index:idx_question_prompt_id"`
+		}
+		if err := tx.Migrator().DropIndex(&Question{}, "idx_question_prompt_id"); err != nil {
+			return err
+		}
+		if err := tx.Migrator().DropColumn(&Question{}, "PromptID"); err != nil {
+			return err
+		}
+		return tx.Migrator().DropTable("prompts")
+	},
+}
@@ -0,0 +1,29 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+var userLocale = &gormigrate.Migration{
+	ID: "0005_user_locale",
+	Migrate: func(tx *gorm.DB) error {
+		type User struct {
+			Locale string `gorm:"type:varchar(16)"`
+		}
+		if tx.Migrator().HasColumn(&User{}, "Locale") {
+			return nil
+		}
+		return tx.Migrator().AddColumn(&User{}, "Locale")
+	},
+	Rollback: func(tx *gorm.DB) error {
+		type User struct {
+			Locale string `gorm:"type:varchar(16)"`
+		}
+		return tx.Migrator().DropColumn(&User{}, "Locale")
+	},
+}
@@ -0,0 +1,37 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+var questionImportHash = &gormigrate.Migration{
+	ID: "0006_question_import_hash",
+	Migrate: func(tx *gorm.DB) error {
+		type Question struct {
+			ImportHash string `gorm:"type:varchar(64);index:idx_question_import_hash"`
+		}
+		if !tx.Migrator().HasColumn(&Question{}, "ImportHash") {
+			if err := tx.Migrator().AddColumn(&Question{}, "ImportHash"); err != nil {
+				return err
+			}
+		}
+		if !tx.Migrator().HasIndex(&Question{}, "idx_question_import_hash") {
+			return tx.Migrator().CreateIndex(&Question{}, "idx_question_import_hash")
+		}
+		return nil
+	},
+	Rollback: func(tx *gorm.DB) error {
+		type Question struct {
+			ImportHash string `gorm:"type:varchar(64);index:idx_question_import_hash"`
+		}
+		if err := tx.Migrator().DropIndex(&Question{}, "idx_question_import_hash"); err != nil {
+			return err
+		}
+		return tx.Migrator().DropColumn(&Question{}, "ImportHash")
+	},
+}
@@ -0,0 +1,36 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+var questionReadAt = &gormigrate.Migration{
+	ID: "0007_question_read_at",
+	Migrate: func(tx *gorm.DB) error {
+		type Question struct {
+			ReadAt *time.Time
+		}
+		if tx.Migrator().HasColumn(&Question{}, "ReadAt") {
+			return nil
+		}
+		if err := tx.Migrator().AddColumn(&Question{}, "ReadAt"); err != nil {
+			return err
+		}
+		// The existing questions have been seen by the owners in the question list,
+		// only the questions received after the upgrade are unread.
+		return tx.Table("questions").Where("read_at IS NULL").Update("read_at", gorm.Expr("created_at")).Error
+	},
+	Rollback: func(tx *gorm.DB) error {
+		type Question struct {
+			ReadAt *time.Time
+		}
+		return tx.Migrator().DropColumn(&Question{}, "ReadAt")
+	},
+}
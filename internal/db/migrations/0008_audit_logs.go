@@ -0,0 +1,49 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+var auditLogs = &gormigrate.Migration{
+	ID: "0008_audit_logs",
+	Migrate: func(tx *gorm.DB) error {
+		type AuditLog struct {
+			ID          uint      `gorm:"primarykey"`
+			CreatedAt   time.Time `gorm:"index:idx_audit_log_created_at"`
+			ActorUserID uint      `gorm:"index:idx_audit_log_actor_user_id"`
+			Action      string    `gorm:"type:varchar(32);index:idx_audit_log_action"`
+			TargetType  string    `gorm:"type:varchar(32);index:idx_audit_log_target"`
+			TargetID    uint      `gorm:"index:idx_audit_log_target"`
+			FromIP      string
+			Metadata    datatypes.JSON
+		}
+		if err := tx.AutoMigrate(&AuditLog{}); err != nil {
+			return err
+		}
+
+		type User struct {
+			IsAdmin bool `gorm:"not null;default:false"`
+		}
+		if !tx.Migrator().HasColumn(&User{}, "IsAdmin") {
+			return tx.Migrator().AddColumn(&User{}, "IsAdmin")
+		}
+		return nil
+	},
+	Rollback: func(tx *gorm.DB) error {
+		type User struct {
+			IsAdmin bool `gorm:"not null;default:false"`
+		}
+		if err := tx.Migrator().DropColumn(&User{}, "IsAdmin"); err != nil {
+			return err
+		}
+		return tx.Migrator().DropTable("audit_logs")
+	},
+}
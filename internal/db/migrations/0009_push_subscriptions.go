@@ -0,0 +1,33 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+var pushSubscriptions = &gormigrate.Migration{
+	ID: "0009_push_subscriptions",
+	Migrate: func(tx *gorm.DB) error {
+		type PushSubscription struct {
+			ID        uint `gorm:"primarykey"`
+			CreatedAt time.Time
+			UpdatedAt time.Time
+			DeletedAt gorm.DeletedAt `gorm:"index"`
+			UserID    uint           `gorm:"index:idx_push_subscription_user_id"`
+			Endpoint  string         `gorm:"type:varchar(1024)"`
+			P256dh    string
+			Auth      string
+			UserAgent string
+		}
+		return tx.AutoMigrate(&PushSubscription{})
+	},
+	Rollback: func(tx *gorm.DB) error {
+		return tx.Migrator().DropTable("push_subscriptions")
+	},
+}
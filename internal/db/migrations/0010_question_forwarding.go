@@ -0,0 +1,45 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+var questionForwarding = &gormigrate.Migration{
+	ID: "0010_question_forwarding",
+	Migrate: func(tx *gorm.DB) error {
+		type Question struct {
+			ForwardedFromQuestionID uint `gorm:"index:idx_question_forwarded_from_question_id"`
+			ForwardedFromUserID     uint
+		}
+		for _, column := range []string{"ForwardedFromQuestionID", "ForwardedFromUserID"} {
+			if tx.Migrator().HasColumn(&Question{}, column) {
+				continue
+			}
+			if err := tx.Migrator().AddColumn(&Question{}, column); err != nil {
+				return err
+			}
+		}
+		if !tx.Migrator().HasIndex(&Question{}, "idx_question_forwarded_from_question_id") {
+			return tx.Migrator().CreateIndex(&Question{}, "idx_question_forwarded_from_question_id")
+		}
+		return nil
+	},
+	Rollback: func(tx *gorm.DB) error {
+		type Question struct {
+			ForwardedFromQuestionID uint `gorm:"index:idx_question_forwarded_from_question_id"`
+			ForwardedFromUserID     uint
+		}
+		if err := tx.Migrator().DropIndex(&Question{}, "idx_question_forwarded_from_question_id"); err != nil {
+			return err
+		}
+		if err := tx.Migrator().DropColumn(&Question{}, "ForwardedFromUserID"); err != nil {
+			return err
+		}
+		return tx.Migrator().DropColumn(&Question{}, "ForwardedFromQuestionID")
+	},
+}
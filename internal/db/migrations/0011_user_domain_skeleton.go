@@ -0,0 +1,55 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+
+	"github.com/NekoWheel/NekoBox/internal/security/boxdomain"
+)
+
+var userDomainSkeleton = &gormigrate.Migration{
+	ID: "0011_user_domain_skeleton",
+	Migrate: func(tx *gorm.DB) error {
+		type User struct {
+			ID             uint
+			Domain         string
+			DomainSkeleton string `gorm:"type:varchar(20);index:idx_user_domain_skeleton"`
+		}
+		if !tx.Migrator().HasColumn(&User{}, "DomainSkeleton") {
+			if err := tx.Migrator().AddColumn(&User{}, "DomainSkeleton"); err != nil {
+				return err
+			}
+		}
+		if !tx.Migrator().HasIndex(&User{}, "idx_user_domain_skeleton") {
+			if err := tx.Migrator().CreateIndex(&User{}, "idx_user_domain_skeleton"); err != nil {
+				return err
+			}
+		}
+
+		// The existing domains are kept even if they are reserved or confusable with each other,
+		// the skeletons only prevent the new domains from colliding with them.
+		var users []*User
+		return tx.Model(&User{}).Select("id", "domain").FindInBatches(&users, 500, func(*gorm.DB, int) error {
+			for _, user := range users {
+				if err := tx.Model(&User{}).Where("id = ?", user.ID).
+					UpdateColumn("domain_skeleton", boxdomain.Skeleton(user.Domain)).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+	},
+	Rollback: func(tx *gorm.DB) error {
+		type User struct {
+			DomainSkeleton string `gorm:"type:varchar(20);index:idx_user_domain_skeleton"`
+		}
+		if err := tx.Migrator().DropIndex(&User{}, "idx_user_domain_skeleton"); err != nil {
+			return err
+		}
+		return tx.Migrator().DropColumn(&User{}, "DomainSkeleton")
+	},
+}
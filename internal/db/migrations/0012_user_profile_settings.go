@@ -0,0 +1,29 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+var userProfileSettings = &gormigrate.Migration{
+	ID: "0012_user_profile_settings",
+	Migrate: func(tx *gorm.DB) error {
+		type User struct {
+			ProfileSettings string `gorm:"type:json"`
+		}
+		if tx.Migrator().HasColumn(&User{}, "ProfileSettings") {
+			return nil
+		}
+		return tx.Migrator().AddColumn(&User{}, "ProfileSettings")
+	},
+	Rollback: func(tx *gorm.DB) error {
+		type User struct {
+			ProfileSettings string `gorm:"type:json"`
+		}
+		return tx.Migrator().DropColumn(&User{}, "ProfileSettings")
+	},
+}
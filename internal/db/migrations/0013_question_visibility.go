@@ -0,0 +1,40 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+var questionVisibility = &gormigrate.Migration{
+	ID: "0013_question_visibility",
+	Migrate: func(tx *gorm.DB) error {
+		// The existing questions are public, which is filled by the default value.
+		type Question struct {
+			Visibility string `gorm:"type:varchar(16);not null;default:public"`
+			ShareToken string `gorm:"type:varchar(16)"`
+		}
+		for _, column := range []string{"Visibility", "ShareToken"} {
+			if tx.Migrator().HasColumn(&Question{}, column) {
+				continue
+			}
+			if err := tx.Migrator().AddColumn(&Question{}, column); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Rollback: func(tx *gorm.DB) error {
+		type Question struct {
+			Visibility string `gorm:"type:varchar(16);not null;default:public"`
+			ShareToken string `gorm:"type:varchar(16)"`
+		}
+		if err := tx.Migrator().DropColumn(&Question{}, "ShareToken"); err != nil {
+			return err
+		}
+		return tx.Migrator().DropColumn(&Question{}, "Visibility")
+	},
+}
@@ -0,0 +1,30 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+var emailSuppressions = &gormigrate.Migration{
+	ID: "0014_email_suppressions",
+	Migrate: func(tx *gorm.DB) error {
+		type EmailSuppression struct {
+			ID        uint `gorm:"primarykey"`
+			CreatedAt time.Time
+			UpdatedAt time.Time
+			DeletedAt gorm.DeletedAt `gorm:"index"`
+			Email     string         `gorm:"type:varchar(255);uniqueIndex:idx_email_suppression_email"`
+			Reason    string         `gorm:"type:varchar(32)"`
+		}
+		return tx.AutoMigrate(&EmailSuppression{})
+	},
+	Rollback: func(tx *gorm.DB) error {
+		return tx.Migrator().DropTable("email_suppressions")
+	},
+}
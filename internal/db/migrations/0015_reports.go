@@ -0,0 +1,63 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+var reports = &gormigrate.Migration{
+	ID: "0015_reports",
+	Migrate: func(tx *gorm.DB) error {
+		type Report struct {
+			ID               uint `gorm:"primarykey"`
+			CreatedAt        time.Time
+			UpdatedAt        time.Time
+			DeletedAt        gorm.DeletedAt `gorm:"index"`
+			QuestionID       uint           `gorm:"index:idx_report_question_id"`
+			QuestionUserID   uint
+			ReporterUserID   uint
+			ReporterIPHash   string `gorm:"type:varchar(64)"`
+			Reason           string `gorm:"type:varchar(32)"`
+			Detail           string `gorm:"type:varchar(500)"`
+			Status           string `gorm:"type:varchar(16);not null;default:pending;index:idx_report_status"`
+			ResolvedByUserID uint
+			ResolvedAt       *time.Time
+		}
+		if err := tx.AutoMigrate(&Report{}); err != nil {
+			return err
+		}
+
+		type Question struct {
+			HiddenAt *time.Time `gorm:"index:idx_question_hidden_at"`
+		}
+		if !tx.Migrator().HasColumn(&Question{}, "HiddenAt") {
+			if err := tx.Migrator().AddColumn(&Question{}, "HiddenAt"); err != nil {
+				return err
+			}
+		}
+		if !tx.Migrator().HasIndex(&Question{}, "idx_question_hidden_at") {
+			return tx.Migrator().CreateIndex(&Question{}, "idx_question_hidden_at")
+		}
+		return nil
+	},
+	Rollback: func(tx *gorm.DB) error {
+		type Question struct {
+			HiddenAt *time.Time `gorm:"index:idx_question_hidden_at"`
+		}
+		if tx.Migrator().HasIndex(&Question{}, "idx_question_hidden_at") {
+			if err := tx.Migrator().DropIndex(&Question{}, "idx_question_hidden_at"); err != nil {
+				return err
+			}
+		}
+		if err := tx.Migrator().DropColumn(&Question{}, "HiddenAt"); err != nil {
+			return err
+		}
+		return tx.Migrator().DropTable("reports")
+	},
+}
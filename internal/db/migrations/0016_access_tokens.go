@@ -0,0 +1,33 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+var accessTokens = &gormigrate.Migration{
+	ID: "0016_access_tokens",
+	Migrate: func(tx *gorm.DB) error {
+		type AccessToken struct {
+			ID         uint `gorm:"primarykey"`
+			CreatedAt  time.Time
+			UpdatedAt  time.Time
+			DeletedAt  gorm.DeletedAt `gorm:"index"`
+			UserID     uint           `gorm:"index:idx_access_token_user_id"`
+			Name       string         `gorm:"type:varchar(50)"`
+			TokenHash  string         `gorm:"type:varchar(64);uniqueIndex:idx_access_token_token_hash"`
+			Scopes     string         `gorm:"type:varchar(255)"`
+			LastUsedAt *time.Time
+		}
+		return tx.AutoMigrate(&AccessToken{})
+	},
+	Rollback: func(tx *gorm.DB) error {
+		return tx.Migrator().DropTable("access_tokens")
+	},
+}
@@ -0,0 +1,32 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+var questionAnsweredAt = &gormigrate.Migration{
+	ID: "0017_question_answered_at",
+	Migrate: func(tx *gorm.DB) error {
+		// The answered time of the existing questions is unknown, they are not counted in the response time.
+		type Question struct {
+			AnsweredAt *time.Time
+		}
+		if tx.Migrator().HasColumn(&Question{}, "AnsweredAt") {
+			return nil
+		}
+		return tx.Migrator().AddColumn(&Question{}, "AnsweredAt")
+	},
+	Rollback: func(tx *gorm.DB) error {
+		type Question struct {
+			AnsweredAt *time.Time
+		}
+		return tx.Migrator().DropColumn(&Question{}, "AnsweredAt")
+	},
+}
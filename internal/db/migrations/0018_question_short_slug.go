@@ -0,0 +1,40 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+var questionShortSlug = &gormigrate.Migration{
+	ID: "0018_question_short_slug",
+	Migrate: func(tx *gorm.DB) error {
+		// The short slugs are generated lazily when the question page or the QR code is visited.
+		type Question struct {
+			ShortSlug *string `gorm:"type:varchar(16);uniqueIndex:idx_question_short_slug"`
+		}
+		if !tx.Migrator().HasColumn(&Question{}, "ShortSlug") {
+			if err := tx.Migrator().AddColumn(&Question{}, "ShortSlug"); err != nil {
+				return err
+			}
+		}
+		if !tx.Migrator().HasIndex(&Question{}, "idx_question_short_slug") {
+			return tx.Migrator().CreateIndex(&Question{}, "idx_question_short_slug")
+		}
+		return nil
+	},
+	Rollback: func(tx *gorm.DB) error {
+		type Question struct {
+			ShortSlug *string `gorm:"type:varchar(16);uniqueIndex:idx_question_short_slug"`
+		}
+		if tx.Migrator().HasIndex(&Question{}, "idx_question_short_slug") {
+			if err := tx.Migrator().DropIndex(&Question{}, "idx_question_short_slug"); err != nil {
+				return err
+			}
+		}
+		return tx.Migrator().DropColumn(&Question{}, "ShortSlug")
+	},
+}
@@ -0,0 +1,41 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+var questionArchivedAt = &gormigrate.Migration{
+	ID: "0019_question_archived_at",
+	Migrate: func(tx *gorm.DB) error {
+		type Question struct {
+			ArchivedAt *time.Time `gorm:"index:idx_question_archived_at"`
+		}
+		if !tx.Migrator().HasColumn(&Question{}, "ArchivedAt") {
+			if err := tx.Migrator().AddColumn(&Question{}, "ArchivedAt"); err != nil {
+				return err
+			}
+		}
+		if !tx.Migrator().HasIndex(&Question{}, "idx_question_archived_at") {
+			return tx.Migrator().CreateIndex(&Question{}, "idx_question_archived_at")
+		}
+		return nil
+	},
+	Rollback: func(tx *gorm.DB) error {
+		type Question struct {
+			ArchivedAt *time.Time `gorm:"index:idx_question_archived_at"`
+		}
+		if tx.Migrator().HasIndex(&Question{}, "idx_question_archived_at") {
+			if err := tx.Migrator().DropIndex(&Question{}, "idx_question_archived_at"); err != nil {
+				return err
+			}
+		}
+		return tx.Migrator().DropColumn(&Question{}, "ArchivedAt")
+	},
+}
@@ -0,0 +1,40 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+var questionContentHash = &gormigrate.Migration{
+	ID: "0020_question_content_hash",
+	Migrate: func(tx *gorm.DB) error {
+		// The duplicates are only checked among the recent questions, the existing ones are not backfilled.
+		type Question struct {
+			ContentHash string `gorm:"type:varchar(64);index:idx_question_content_hash"`
+		}
+		if !tx.Migrator().HasColumn(&Question{}, "ContentHash") {
+			if err := tx.Migrator().AddColumn(&Question{}, "ContentHash"); err != nil {
+				return err
+			}
+		}
+		if !tx.Migrator().HasIndex(&Question{}, "idx_question_content_hash") {
+			return tx.Migrator().CreateIndex(&Question{}, "idx_question_content_hash")
+		}
+		return nil
+	},
+	Rollback: func(tx *gorm.DB) error {
+		type Question struct {
+			ContentHash string `gorm:"type:varchar(64);index:idx_question_content_hash"`
+		}
+		if tx.Migrator().HasIndex(&Question{}, "idx_question_content_hash") {
+			if err := tx.Migrator().DropIndex(&Question{}, "idx_question_content_hash"); err != nil {
+				return err
+			}
+		}
+		return tx.Migrator().DropColumn(&Question{}, "ContentHash")
+	},
+}
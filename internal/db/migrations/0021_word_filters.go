@@ -0,0 +1,32 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+var wordFilters = &gormigrate.Migration{
+	ID: "0021_word_filters",
+	Migrate: func(tx *gorm.DB) error {
+		type WordFilter struct {
+			ID        uint `gorm:"primarykey"`
+			CreatedAt time.Time
+			UpdatedAt time.Time
+			DeletedAt gorm.DeletedAt `gorm:"index"`
+			UserID    uint           `gorm:"index:idx_word_filter_user_id"`
+			Pattern   string         `gorm:"type:varchar(100)"`
+			IsRegex   bool           `gorm:"not null;default:false"`
+			Action    string         `gorm:"type:varchar(16)"`
+		}
+		return tx.AutoMigrate(&WordFilter{})
+	},
+	Rollback: func(tx *gorm.DB) error {
+		return tx.Migrator().DropTable("word_filters")
+	},
+}
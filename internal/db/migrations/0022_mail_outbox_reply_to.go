@@ -0,0 +1,29 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+var mailOutboxReplyTo = &gormigrate.Migration{
+	ID: "0022_mail_outbox_reply_to",
+	Migrate: func(tx *gorm.DB) error {
+		type OutboxMail struct {
+			ReplyTo string `gorm:"type:varchar(255)"`
+		}
+		if tx.Migrator().HasColumn(&OutboxMail{}, "ReplyTo") {
+			return nil
+		}
+		return tx.Migrator().AddColumn(&OutboxMail{}, "ReplyTo")
+	},
+	Rollback: func(tx *gorm.DB) error {
+		type OutboxMail struct {
+			ReplyTo string `gorm:"type:varchar(255)"`
+		}
+		return tx.Migrator().DropColumn(&OutboxMail{}, "ReplyTo")
+	},
+}
@@ -0,0 +1,33 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+var userSessions = &gormigrate.Migration{
+	ID: "0023_user_sessions",
+	Migrate: func(tx *gorm.DB) error {
+		type UserSession struct {
+			ID         uint `gorm:"primarykey"`
+			CreatedAt  time.Time
+			UpdatedAt  time.Time
+			DeletedAt  gorm.DeletedAt `gorm:"index"`
+			UserID     uint           `gorm:"index:idx_user_session_user_id"`
+			TokenHash  string         `gorm:"type:varchar(64);uniqueIndex:idx_user_session_token_hash"`
+			UserAgent  string         `gorm:"type:varchar(255)"`
+			IP         string         `gorm:"type:varchar(255)"`
+			LastSeenAt time.Time
+		}
+		return tx.AutoMigrate(&UserSession{})
+	},
+	Rollback: func(tx *gorm.DB) error {
+		return tx.Migrator().DropTable("user_sessions")
+	},
+}
@@ -0,0 +1,31 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+var loginAttempts = &gormigrate.Migration{
+	ID: "0024_login_attempts",
+	Migrate: func(tx *gorm.DB) error {
+		type LoginAttempt struct {
+			ID        uint      `gorm:"primarykey"`
+			CreatedAt time.Time `gorm:"index:idx_login_attempt_created_at"`
+			UserID    uint      `gorm:"index:idx_login_attempt_user_id"`
+			Email     string    `gorm:"type:varchar(255);index:idx_login_attempt_email"`
+			IP        string    `gorm:"type:varchar(255);index:idx_login_attempt_ip"`
+			UserAgent string    `gorm:"type:varchar(255)"`
+			Cleared   bool      `gorm:"not null;default:false"`
+		}
+		return tx.AutoMigrate(&LoginAttempt{})
+	},
+	Rollback: func(tx *gorm.DB) error {
+		return tx.Migrator().DropTable("login_attempts")
+	},
+}
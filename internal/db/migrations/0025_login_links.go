@@ -0,0 +1,31 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+var loginLinks = &gormigrate.Migration{
+	ID: "0025_login_links",
+	Migrate: func(tx *gorm.DB) error {
+		type LoginLink struct {
+			ID         uint `gorm:"primarykey"`
+			CreatedAt  time.Time
+			UserID     uint      `gorm:"index:idx_login_link_user_id"`
+			TokenHash  string    `gorm:"type:varchar(64);uniqueIndex:idx_login_link_token_hash"`
+			FromIP     string    `gorm:"type:varchar(255)"`
+			ExpiresAt  time.Time `gorm:"index:idx_login_link_expires_at"`
+			ConsumedAt *time.Time
+		}
+		return tx.AutoMigrate(&LoginLink{})
+	},
+	Rollback: func(tx *gorm.DB) error {
+		return tx.Migrator().DropTable("login_links")
+	},
+}
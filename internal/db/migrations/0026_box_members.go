@@ -0,0 +1,31 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+var boxMembers = &gormigrate.Migration{
+	ID: "0026_box_members",
+	Migrate: func(tx *gorm.DB) error {
+		type BoxMember struct {
+			ID        uint `gorm:"primarykey"`
+			CreatedAt time.Time
+			UpdatedAt time.Time
+			DeletedAt gorm.DeletedAt `gorm:"index"`
+			BoxUserID uint           `gorm:"uniqueIndex:idx_box_member_box_user"`
+			UserID    uint           `gorm:"uniqueIndex:idx_box_member_box_user;index:idx_box_member_user_id"`
+			Role      string         `gorm:"type:varchar(16);not null"`
+		}
+		return tx.AutoMigrate(&BoxMember{})
+	},
+	Rollback: func(tx *gorm.DB) error {
+		return tx.Migrator().DropTable("box_members")
+	},
+}
@@ -0,0 +1,30 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+var questionAnswerUserID = &gormigrate.Migration{
+	ID: "0027_question_answer_user_id",
+	Migrate: func(tx *gorm.DB) error {
+		// The existing answers are written by the box owners, which are shown without the attribution.
+		type Question struct {
+			AnswerUserID uint
+		}
+		if tx.Migrator().HasColumn(&Question{}, "AnswerUserID") {
+			return nil
+		}
+		return tx.Migrator().AddColumn(&Question{}, "AnswerUserID")
+	},
+	Rollback: func(tx *gorm.DB) error {
+		type Question struct {
+			AnswerUserID uint
+		}
+		return tx.Migrator().DropColumn(&Question{}, "AnswerUserID")
+	},
+}
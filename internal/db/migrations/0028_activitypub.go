@@ -0,0 +1,41 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+var activityPub = &gormigrate.Migration{
+	ID: "0028_activitypub",
+	Migrate: func(tx *gorm.DB) error {
+		type ActivityPubKey struct {
+			ID            uint `gorm:"primarykey"`
+			CreatedAt     time.Time
+			UpdatedAt     time.Time
+			DeletedAt     gorm.DeletedAt `gorm:"index"`
+			UserID        uint           `gorm:"uniqueIndex:idx_activitypub_key_user_id"`
+			PrivateKeyPEM string         `gorm:"type:text"`
+			PublicKeyPEM  string         `gorm:"type:text"`
+		}
+		type ActivityPubFollower struct {
+			ID          uint `gorm:"primarykey"`
+			CreatedAt   time.Time
+			UpdatedAt   time.Time
+			DeletedAt   gorm.DeletedAt `gorm:"index"`
+			UserID      uint           `gorm:"uniqueIndex:idx_activitypub_follower_user_actor"`
+			ActorID     string         `gorm:"type:varchar(512);uniqueIndex:idx_activitypub_follower_user_actor"`
+			Inbox       string         `gorm:"type:varchar(512)"`
+			SharedInbox string         `gorm:"type:varchar(512)"`
+		}
+		return tx.AutoMigrate(&ActivityPubKey{}, &ActivityPubFollower{})
+	},
+	Rollback: func(tx *gorm.DB) error {
+		return tx.Migrator().DropTable("activity_pub_keys", "activity_pub_followers")
+	},
+}
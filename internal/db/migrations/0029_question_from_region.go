@@ -0,0 +1,30 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+var questionFromRegion = &gormigrate.Migration{
+	ID: "0029_question_from_region",
+	Migrate: func(tx *gorm.DB) error {
+		// The existing questions are shown without the regions.
+		type Question struct {
+			FromRegion string `gorm:"type:varchar(64)"`
+		}
+		if tx.Migrator().HasColumn(&Question{}, "FromRegion") {
+			return nil
+		}
+		return tx.Migrator().AddColumn(&Question{}, "FromRegion")
+	},
+	Rollback: func(tx *gorm.DB) error {
+		type Question struct {
+			FromRegion string `gorm:"type:varchar(64)"`
+		}
+		return tx.Migrator().DropColumn(&Question{}, "FromRegion")
+	},
+}
@@ -0,0 +1,30 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+var questionSpamScore = &gormigrate.Migration{
+	ID: "0030_question_spam_score",
+	Migrate: func(tx *gorm.DB) error {
+		// The existing questions are not scored.
+		type Question struct {
+			SpamScore int `gorm:"not null;default:0"`
+		}
+		if tx.Migrator().HasColumn(&Question{}, "SpamScore") {
+			return nil
+		}
+		return tx.Migrator().AddColumn(&Question{}, "SpamScore")
+	},
+	Rollback: func(tx *gorm.DB) error {
+		type Question struct {
+			SpamScore int `gorm:"not null;default:0"`
+		}
+		return tx.Migrator().DropColumn(&Question{}, "SpamScore")
+	},
+}
@@ -0,0 +1,32 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+var questionRemindedAt = &gormigrate.Migration{
+	ID: "0031_question_reminded_at",
+	Migrate: func(tx *gorm.DB) error {
+		// The owners have not been reminded of the existing questions.
+		type Question struct {
+			RemindedAt *time.Time
+		}
+		if tx.Migrator().HasColumn(&Question{}, "RemindedAt") {
+			return nil
+		}
+		return tx.Migrator().AddColumn(&Question{}, "RemindedAt")
+	},
+	Rollback: func(tx *gorm.DB) error {
+		type Question struct {
+			RemindedAt *time.Time
+		}
+		return tx.Migrator().DropColumn(&Question{}, "RemindedAt")
+	},
+}
@@ -0,0 +1,31 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+var answerTemplates = &gormigrate.Migration{
+	ID: "0032_answer_templates",
+	Migrate: func(tx *gorm.DB) error {
+		type AnswerTemplate struct {
+			ID        uint `gorm:"primarykey"`
+			CreatedAt time.Time
+			UpdatedAt time.Time
+			DeletedAt gorm.DeletedAt `gorm:"index"`
+			UserID    uint           `gorm:"index:idx_answer_template_user_id"`
+			Title     string         `gorm:"type:varchar(50)"`
+			Content   string         `gorm:"type:text"`
+		}
+		return tx.AutoMigrate(&AnswerTemplate{})
+	},
+	Rollback: func(tx *gorm.DB) error {
+		return tx.Migrator().DropTable("answer_templates")
+	},
+}
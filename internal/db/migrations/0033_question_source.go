@@ -0,0 +1,31 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+var questionSource = &gormigrate.Migration{
+	ID: "0033_question_source",
+	Migrate: func(tx *gorm.DB) error {
+		// The existing questions are not counted in the referral statistics.
+		type Question struct {
+			Source datatypes.JSON
+		}
+		if tx.Migrator().HasColumn(&Question{}, "Source") {
+			return nil
+		}
+		return tx.Migrator().AddColumn(&Question{}, "Source")
+	},
+	Rollback: func(tx *gorm.DB) error {
+		type Question struct {
+			Source datatypes.JSON
+		}
+		return tx.Migrator().DropColumn(&Question{}, "Source")
+	},
+}
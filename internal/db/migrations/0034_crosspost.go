@@ -0,0 +1,51 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+var crosspost = &gormigrate.Migration{
+	ID: "0034_crosspost",
+	Migrate: func(tx *gorm.DB) error {
+		type CrosspostAccount struct {
+			ID           uint `gorm:"primarykey"`
+			CreatedAt    time.Time
+			UpdatedAt    time.Time
+			DeletedAt    gorm.DeletedAt `gorm:"index"`
+			UserID       uint           `gorm:"uniqueIndex:idx_crosspost_account_user_id_provider"`
+			Provider     string         `gorm:"type:varchar(20);uniqueIndex:idx_crosspost_account_user_id_provider"`
+			InstanceURL  string         `gorm:"type:varchar(255)"`
+			Username     string         `gorm:"type:varchar(255)"`
+			AccessToken  string
+			RefreshToken string
+			ExpiresAt    *time.Time
+		}
+		type Crosspost struct {
+			ID            uint `gorm:"primarykey"`
+			CreatedAt     time.Time
+			UpdatedAt     time.Time
+			DeletedAt     gorm.DeletedAt `gorm:"index"`
+			UserID        uint           `gorm:"index:idx_crosspost_user_id"`
+			AccountID     uint
+			QuestionID    uint
+			Content       string
+			Status        string `gorm:"type:varchar(20);index:idx_crosspost_status_next_attempt"`
+			Attempts      int
+			NextAttemptAt time.Time `gorm:"index:idx_crosspost_status_next_attempt"`
+			LastError     string
+			PostURL       string `gorm:"type:varchar(255)"`
+			PostedAt      *time.Time
+		}
+		return tx.AutoMigrate(&CrosspostAccount{}, &Crosspost{})
+	},
+	Rollback: func(tx *gorm.DB) error {
+		return tx.Migrator().DropTable("crosspost_accounts", "crossposts")
+	},
+}
@@ -0,0 +1,44 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+var userState = &gormigrate.Migration{
+	ID: "0035_user_state",
+	Migrate: func(tx *gorm.DB) error {
+		// The existing users are active.
+		type User struct {
+			State          string `gorm:"type:varchar(16);not null;default:active"`
+			SuspendedUntil *time.Time
+		}
+		for _, column := range []string{"State", "SuspendedUntil"} {
+			if tx.Migrator().HasColumn(&User{}, column) {
+				continue
+			}
+			if err := tx.Migrator().AddColumn(&User{}, column); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Rollback: func(tx *gorm.DB) error {
+		type User struct {
+			State          string `gorm:"type:varchar(16);not null;default:active"`
+			SuspendedUntil *time.Time
+		}
+		for _, column := range []string{"State", "SuspendedUntil"} {
+			if err := tx.Migrator().DropColumn(&User{}, column); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
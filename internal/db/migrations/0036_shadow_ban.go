@@ -0,0 +1,50 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+var shadowBan = &gormigrate.Migration{
+	ID: "0036_shadow_ban",
+	Migrate: func(tx *gorm.DB) error {
+		// The existing blocks reject the questions.
+		type Block struct {
+			Mode string `gorm:"type:varchar(16);not null;default:reject"`
+		}
+		if !tx.Migrator().HasColumn(&Block{}, "Mode") {
+			if err := tx.Migrator().AddColumn(&Block{}, "Mode"); err != nil {
+				return err
+			}
+		}
+
+		type Question struct {
+			ShadowBanned bool `gorm:"not null;default:false;index:idx_question_shadow_banned"`
+		}
+		if !tx.Migrator().HasColumn(&Question{}, "ShadowBanned") {
+			if err := tx.Migrator().AddColumn(&Question{}, "ShadowBanned"); err != nil {
+				return err
+			}
+		}
+		if !tx.Migrator().HasIndex(&Question{}, "idx_question_shadow_banned") {
+			return tx.Migrator().CreateIndex(&Question{}, "idx_question_shadow_banned")
+		}
+		return nil
+	},
+	Rollback: func(tx *gorm.DB) error {
+		type Question struct {
+			ShadowBanned bool `gorm:"not null;default:false;index:idx_question_shadow_banned"`
+		}
+		if err := tx.Migrator().DropColumn(&Question{}, "ShadowBanned"); err != nil {
+			return err
+		}
+		type Block struct {
+			Mode string `gorm:"type:varchar(16);not null;default:reject"`
+		}
+		return tx.Migrator().DropColumn(&Block{}, "Mode")
+	},
+}
@@ -0,0 +1,33 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+var questionAskerIndex = &gormigrate.Migration{
+	ID: "0037_question_asker_index",
+	Migrate: func(tx *gorm.DB) error {
+		// The history of the logged askers is queried when they visit the boxes.
+		type Question struct {
+			AskerUserID uint `gorm:"index:idx_question_asker_user_id"`
+		}
+		if !tx.Migrator().HasIndex(&Question{}, "idx_question_asker_user_id") {
+			return tx.Migrator().CreateIndex(&Question{}, "idx_question_asker_user_id")
+		}
+		return nil
+	},
+	Rollback: func(tx *gorm.DB) error {
+		type Question struct {
+			AskerUserID uint `gorm:"index:idx_question_asker_user_id"`
+		}
+		if tx.Migrator().HasIndex(&Question{}, "idx_question_asker_user_id") {
+			return tx.Migrator().DropIndex(&Question{}, "idx_question_asker_user_id")
+		}
+		return nil
+	},
+}
@@ -0,0 +1,33 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+var questionTranslations = &gormigrate.Migration{
+	ID: "0038_question_translations",
+	Migrate: func(tx *gorm.DB) error {
+		type QuestionTranslation struct {
+			ID         uint `gorm:"primarykey"`
+			CreatedAt  time.Time
+			UpdatedAt  time.Time
+			DeletedAt  gorm.DeletedAt `gorm:"index"`
+			QuestionID uint           `gorm:"uniqueIndex:idx_question_translation_question_target"`
+			TargetLang string         `gorm:"type:varchar(16);uniqueIndex:idx_question_translation_question_target"`
+			SourceLang string         `gorm:"type:varchar(16)"`
+			Provider   string         `gorm:"type:varchar(20)"`
+			Text       string         `gorm:"type:text"`
+		}
+		return tx.AutoMigrate(&QuestionTranslation{})
+	},
+	Rollback: func(tx *gorm.DB) error {
+		return tx.Migrator().DropTable("question_translations")
+	},
+}
@@ -0,0 +1,63 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+
+	"github.com/NekoWheel/NekoBox/internal/textstat"
+)
+
+var questionAnswerLength = &gormigrate.Migration{
+	ID: "0039_question_answer_length",
+	Migrate: func(tx *gorm.DB) error {
+		// The length of the answers is stored when they are saved, so the box can be sorted by it.
+		type Question struct {
+			ID                   uint
+			Answer               string
+			AnswerCharCount      int `gorm:"not null;default:0"`
+			AnswerWordCount      int `gorm:"not null;default:0"`
+			AnswerReadingSeconds int `gorm:"not null;default:0"`
+		}
+		for _, column := range []string{"AnswerCharCount", "AnswerWordCount", "AnswerReadingSeconds"} {
+			if !tx.Migrator().HasColumn(&Question{}, column) {
+				if err := tx.Migrator().AddColumn(&Question{}, column); err != nil {
+					return err
+				}
+			}
+		}
+
+		// Count the existing answers, including the ones in the trash as the model has no soft delete here.
+		var questions []*Question
+		return tx.Model(&Question{}).Select("id", "answer").Where("answer <> ''").
+			FindInBatches(&questions, 500, func(*gorm.DB, int) error {
+				for _, question := range questions {
+					stats := textstat.Count(question.Answer)
+					if err := tx.Model(&Question{}).Where("id = ?", question.ID).UpdateColumns(map[string]interface{}{
+						"answer_char_count":      stats.Characters,
+						"answer_word_count":      stats.Words,
+						"answer_reading_seconds": stats.ReadingSeconds,
+					}).Error; err != nil {
+						return err
+					}
+				}
+				return nil
+			}).Error
+	},
+	Rollback: func(tx *gorm.DB) error {
+		type Question struct {
+			AnswerCharCount      int
+			AnswerWordCount      int
+			AnswerReadingSeconds int
+		}
+		for _, column := range []string{"AnswerCharCount", "AnswerWordCount", "AnswerReadingSeconds"} {
+			if err := tx.Migrator().DropColumn(&Question{}, column); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
@@ -0,0 +1,35 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+var censorFeedback = &gormigrate.Migration{
+	ID: "0040_censor_feedback",
+	Migrate: func(tx *gorm.DB) error {
+		type CensorFeedback struct {
+			ID            uint `gorm:"primarykey"`
+			CreatedAt     time.Time
+			UpdatedAt     time.Time
+			DeletedAt     gorm.DeletedAt `gorm:"index"`
+			QuestionID    uint           `gorm:"index:idx_censor_feedback_question_id"`
+			SourceName    string         `gorm:"type:varchar(20);index:idx_censor_feedback_source_name"`
+			ForbiddenType string         `gorm:"type:varchar(32)"`
+			CensorPass    bool           `gorm:"not null;default:false"`
+			AdminPass     bool           `gorm:"not null;default:false"`
+			AdminUserID   uint
+		}
+		// The table name is not pluralized.
+		return tx.Table("censor_feedback").AutoMigrate(&CensorFeedback{})
+	},
+	Rollback: func(tx *gorm.DB) error {
+		return tx.Migrator().DropTable("censor_feedback")
+	},
+}
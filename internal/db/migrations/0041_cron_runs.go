@@ -0,0 +1,37 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+var cronRuns = &gormigrate.Migration{
+	ID: "0041_cron_runs",
+	Migrate: func(tx *gorm.DB) error {
+		type CronRun struct {
+			ID         uint `gorm:"primarykey"`
+			CreatedAt  time.Time
+			UpdatedAt  time.Time
+			DeletedAt  gorm.DeletedAt `gorm:"index"`
+			Name       string         `gorm:"type:varchar(64);index:idx_cron_run_name"`
+			Holder     string         `gorm:"type:varchar(128)"`
+			FinishedAt *time.Time
+			Error      string `gorm:"type:text"`
+		}
+		type CronLock struct {
+			Name        string `gorm:"type:varchar(64);primaryKey"`
+			Holder      string `gorm:"type:varchar(128)"`
+			LockedUntil time.Time
+		}
+		return tx.AutoMigrate(&CronRun{}, &CronLock{})
+	},
+	Rollback: func(tx *gorm.DB) error {
+		return tx.Migrator().DropTable("cron_runs", "cron_locks")
+	},
+}
@@ -0,0 +1,35 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+var emailChanges = &gormigrate.Migration{
+	ID: "0042_email_changes",
+	Migrate: func(tx *gorm.DB) error {
+		type EmailChange struct {
+			ID              uint `gorm:"primarykey"`
+			CreatedAt       time.Time
+			UserID          uint      `gorm:"index:idx_email_change_user_id"`
+			OldEmail        string    `gorm:"type:varchar(255)"`
+			NewEmail        string    `gorm:"type:varchar(255)"`
+			VerifyTokenHash string    `gorm:"type:varchar(64);uniqueIndex:idx_email_change_verify_token_hash"`
+			CancelTokenHash string    `gorm:"type:varchar(64);uniqueIndex:idx_email_change_cancel_token_hash"`
+			FromIP          string    `gorm:"type:varchar(255)"`
+			ExpiresAt       time.Time `gorm:"index:idx_email_change_expires_at"`
+			ConfirmedAt     *time.Time
+			CanceledAt      *time.Time
+		}
+		return tx.AutoMigrate(&EmailChange{})
+	},
+	Rollback: func(tx *gorm.DB) error {
+		return tx.Migrator().DropTable("email_changes")
+	},
+}
@@ -0,0 +1,49 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+var questionSortIndexes = &gormigrate.Migration{
+	ID: "0044_question_sort_indexes",
+	Migrate: func(tx *gorm.DB) error {
+		// The owner's inbox can be sorted by the like count and the update time.
+		type Question struct {
+			UserID    uint      `gorm:"index:idx_question_user_like_count,priority:1;index:idx_question_user_updated_at,priority:1"`
+			LikeCount uint      `gorm:"index:idx_question_user_like_count,priority:2"`
+			UpdatedAt time.Time `gorm:"index:idx_question_user_updated_at,priority:2"`
+		}
+		for _, index := range []string{"idx_question_user_like_count", "idx_question_user_updated_at"} {
+			if tx.Migrator().HasIndex(&Question{}, index) {
+				continue
+			}
+			if err := tx.Migrator().CreateIndex(&Question{}, index); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Rollback: func(tx *gorm.DB) error {
+		type Question struct {
+			UserID    uint      `gorm:"index:idx_question_user_like_count,priority:1;index:idx_question_user_updated_at,priority:1"`
+			LikeCount uint      `gorm:"index:idx_question_user_like_count,priority:2"`
+			UpdatedAt time.Time `gorm:"index:idx_question_user_updated_at,priority:2"`
+		}
+		for _, index := range []string{"idx_question_user_like_count", "idx_question_user_updated_at"} {
+			if !tx.Migrator().HasIndex(&Question{}, index) {
+				continue
+			}
+			if err := tx.Migrator().DropIndex(&Question{}, index); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
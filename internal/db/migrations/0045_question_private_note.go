@@ -0,0 +1,29 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+var questionPrivateNote = &gormigrate.Migration{
+	ID: "0045_question_private_note",
+	Migrate: func(tx *gorm.DB) error {
+		type Question struct {
+			PrivateNote string
+		}
+		if tx.Migrator().HasColumn(&Question{}, "PrivateNote") {
+			return nil
+		}
+		return tx.Migrator().AddColumn(&Question{}, "PrivateNote")
+	},
+	Rollback: func(tx *gorm.DB) error {
+		type Question struct {
+			PrivateNote string
+		}
+		return tx.Migrator().DropColumn(&Question{}, "PrivateNote")
+	},
+}
@@ -0,0 +1,39 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+var questionMergedInto = &gormigrate.Migration{
+	ID: "0046_question_merged_into",
+	Migrate: func(tx *gorm.DB) error {
+		type Question struct {
+			MergedIntoID uint `gorm:"index:idx_question_merged_into_id"`
+		}
+		if !tx.Migrator().HasColumn(&Question{}, "MergedIntoID") {
+			if err := tx.Migrator().AddColumn(&Question{}, "MergedIntoID"); err != nil {
+				return err
+			}
+		}
+		if !tx.Migrator().HasIndex(&Question{}, "idx_question_merged_into_id") {
+			return tx.Migrator().CreateIndex(&Question{}, "idx_question_merged_into_id")
+		}
+		return nil
+	},
+	Rollback: func(tx *gorm.DB) error {
+		type Question struct {
+			MergedIntoID uint `gorm:"index:idx_question_merged_into_id"`
+		}
+		if tx.Migrator().HasIndex(&Question{}, "idx_question_merged_into_id") {
+			if err := tx.Migrator().DropIndex(&Question{}, "idx_question_merged_into_id"); err != nil {
+				return err
+			}
+		}
+		return tx.Migrator().DropColumn(&Question{}, "MergedIntoID")
+	},
+}
@@ -0,0 +1,30 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+var answerRevisions = &gormigrate.Migration{
+	ID: "0047_answer_revisions",
+	Migrate: func(tx *gorm.DB) error {
+		type AnswerRevision struct {
+			ID           uint `gorm:"primarykey"`
+			CreatedAt    time.Time
+			QuestionID   uint   `gorm:"index:idx_answer_revision_question_id"`
+			Answer       string `gorm:"type:text"`
+			EditorUserID uint
+			EditedAt     *time.Time
+		}
+		return tx.AutoMigrate(&AnswerRevision{})
+	},
+	Rollback: func(tx *gorm.DB) error {
+		return tx.Migrator().DropTable("answer_revisions")
+	},
+}
@@ -0,0 +1,28 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+var achievements = &gormigrate.Migration{
+	ID: "0048_achievements",
+	Migrate: func(tx *gorm.DB) error {
+		type Achievement struct {
+			ID        uint `gorm:"primarykey"`
+			CreatedAt time.Time
+			UserID    uint   `gorm:"uniqueIndex:idx_achievement_user_kind"`
+			Kind      string `gorm:"type:varchar(32);uniqueIndex:idx_achievement_user_kind"`
+		}
+		return tx.AutoMigrate(&Achievement{})
+	},
+	Rollback: func(tx *gorm.DB) error {
+		return tx.Migrator().DropTable("achievements")
+	},
+}
@@ -0,0 +1,41 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+var questionAnswerAudio = &gormigrate.Migration{
+	ID: "0049_question_answer_audio",
+	Migrate: func(tx *gorm.DB) error {
+		type Question struct {
+			AnswerAudioURL      string
+			AnswerAudioDuration int `gorm:"not null;default:0"`
+		}
+		for _, column := range []string{"AnswerAudioURL", "AnswerAudioDuration"} {
+			if tx.Migrator().HasColumn(&Question{}, column) {
+				continue
+			}
+			if err := tx.Migrator().AddColumn(&Question{}, column); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Rollback: func(tx *gorm.DB) error {
+		type Question struct {
+			AnswerAudioURL      string
+			AnswerAudioDuration int `gorm:"not null;default:0"`
+		}
+		for _, column := range []string{"AnswerAudioURL", "AnswerAudioDuration"} {
+			if err := tx.Migrator().DropColumn(&Question{}, column); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
@@ -0,0 +1,29 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+var questionVersion = &gormigrate.Migration{
+	ID: "0050_question_version",
+	Migrate: func(tx *gorm.DB) error {
+		type Question struct {
+			Version uint `gorm:"not null;default:0"`
+		}
+		if tx.Migrator().HasColumn(&Question{}, "Version") {
+			return nil
+		}
+		return tx.Migrator().AddColumn(&Question{}, "Version")
+	},
+	Rollback: func(tx *gorm.DB) error {
+		type Question struct {
+			Version uint `gorm:"not null;default:0"`
+		}
+		return tx.Migrator().DropColumn(&Question{}, "Version")
+	},
+}
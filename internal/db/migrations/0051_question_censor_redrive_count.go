@@ -0,0 +1,29 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+var questionCensorRedriveCount = &gormigrate.Migration{
+	ID: "0051_question_censor_redrive_count",
+	Migrate: func(tx *gorm.DB) error {
+		type Question struct {
+			CensorRedriveCount int `gorm:"not null;default:0"`
+		}
+		if tx.Migrator().HasColumn(&Question{}, "CensorRedriveCount") {
+			return nil
+		}
+		return tx.Migrator().AddColumn(&Question{}, "CensorRedriveCount")
+	},
+	Rollback: func(tx *gorm.DB) error {
+		type Question struct {
+			CensorRedriveCount int `gorm:"not null;default:0"`
+		}
+		return tx.Migrator().DropColumn(&Question{}, "CensorRedriveCount")
+	},
+}
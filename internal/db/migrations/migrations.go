@@ -0,0 +1,137 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package migrations contains the versioned database schema migrations.
+//
+// Each migration describes the models with its own copy of the structs at the time
+// it was written, so the migration keeps working after the models in the db package change.
+// New schema changes must be added as a new migration at the end of the list,
+// the applied migrations must never be modified.
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+const (
+	versionTableName  = "schema_version"
+	versionColumnName = "version"
+)
+
+// migrations is the ordered list of all the migrations.
+var migrations = []*gormigrate.Migration{
+	initialSchema,
+	questionDrafts,
+	questionAskerPseudonym,
+	prompts,
+	userLocale,
+	questionImportHash,
+	questionReadAt,
+	auditLogs,
+	pushSubscriptions,
+	questionForwarding,
+	userDomainSkeleton,
+	userProfileSettings,
+	questionVisibility,
+	emailSuppressions,
+	reports,
+	accessTokens,
+	questionAnsweredAt,
+	questionShortSlug,
+	questionArchivedAt,
+	questionContentHash,
+	wordFilters,
+	mailOutboxReplyTo,
+	userSessions,
+	loginAttempts,
+	loginLinks,
+	boxMembers,
+	questionAnswerUserID,
+	activityPub,
+	questionFromRegion,
+	questionSpamScore,
+	questionRemindedAt,
+	answerTemplates,
+	questionSource,
+	crosspost,
+	userState,
+	shadowBan,
+	questionAskerIndex,
+	questionTranslations,
+	questionAnswerLength,
+	censorFeedback,
+	cronRuns,
+	emailChanges,
+	userAwayStatus,
+	questionSortIndexes,
+	questionPrivateNote,
+	questionMergedInto,
+	answerRevisions,
+	achievements,
+	questionAnswerAudio,
+	questionVersion,
+	questionCensorRedriveCount,
+}
+
+func newMigrator(db *gorm.DB) *gormigrate.Gormigrate {
+	return gormigrate.New(db, &gormigrate.Options{
+		TableName:    versionTableName,
+		IDColumnName: versionColumnName,
+		IDColumnSize: 255,
+		// MySQL commits the DDL statements implicitly, the transaction makes no sense.
+		UseTransaction:            false,
+		ValidateUnknownMigrations: true,
+	}, migrations)
+}
+
+// Migrate applies all the pending migrations.
+func Migrate(db *gorm.DB) error {
+	return newMigrator(db).Migrate()
+}
+
+// MigrateTo applies the pending migrations up to the given version.
+func MigrateTo(db *gorm.DB, version string) error {
+	return newMigrator(db).MigrateTo(version)
+}
+
+// Rollback reverts the last applied migration.
+func Rollback(db *gorm.DB) error {
+	return newMigrator(db).RollbackLast()
+}
+
+// RollbackTo reverts the applied migrations until the given version, the given version itself is kept.
+func RollbackTo(db *gorm.DB, version string) error {
+	return newMigrator(db).RollbackTo(version)
+}
+
+// Version describes a migration and whether it has been applied.
+type Version struct {
+	Version string
+	Applied bool
+}
+
+// Status returns all the migrations with their applied status.
+func Status(db *gorm.DB) ([]Version, error) {
+	applied := make(map[string]bool)
+	if db.Migrator().HasTable(versionTableName) {
+		var versions []string
+		if err := db.Table(versionTableName).Pluck(versionColumnName, &versions).Error; err != nil {
+			return nil, errors.Wrap(err, "query applied versions")
+		}
+		for _, version := range versions {
+			applied[version] = true
+		}
+	}
+
+	status := make([]Version, 0, len(migrations))
+	for _, migration := range migrations {
+		status = append(status, Version{
+			Version: migration.ID,
+			Applied: applied[migration.ID],
+		})
+	}
+	return status, nil
+}
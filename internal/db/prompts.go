@@ -0,0 +1,104 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+
+	"github.com/NekoWheel/NekoBox/internal/dbutil"
+)
+
+var Prompts PromptsStore
+
+var _ PromptsStore = (*prompts)(nil)
+
+type PromptsStore interface {
+	Create(ctx context.Context, opts CreatePromptOptions) (*Prompt, error)
+	GetByID(ctx context.Context, id uint) (*Prompt, error)
+	GetByUserID(ctx context.Context, userID uint) ([]*Prompt, error)
+	DeleteByID(ctx context.Context, userID, id uint) error
+}
+
+func NewPromptsStore(db *gorm.DB) PromptsStore {
+	return &prompts{db}
+}
+
+type prompts struct {
+	*gorm.DB
+}
+
+// Prompt is the open question posted by the box owner, e.g. "ask me about X".
+// It is pinned to the top of the box, and the questions asked under it are linked by the PromptID.
+type Prompt struct {
+	dbutil.Model
+	UserID  uint   `gorm:"index:idx_prompt_user_id" json:"-"`
+	Content string `json:"content"`
+}
+
+// MaxPromptsPerUser is the maximum number of prompts that a user can post at the same time.
+const MaxPromptsPerUser = 3
+
+var (
+	ErrPromptNotExist = errors.New("话题不存在")
+	ErrTooManyPrompts = errors.New("最多只能同时发起 3 个话题")
+)
+
+type CreatePromptOptions struct {
+	UserID  uint
+	Content string
+}
+
+func (db *prompts) Create(ctx context.Context, opts CreatePromptOptions) (*Prompt, error) {
+	var count int64
+	if err := db.WithContext(WithPrimary(ctx)).Model(&Prompt{}).Where("user_id = ?", opts.UserID).Count(&count).Error; err != nil {
+		return nil, errors.Wrap(err, "count prompts")
+	}
+	if count >= MaxPromptsPerUser {
+		return nil, ErrTooManyPrompts
+	}
+
+	prompt := Prompt{
+		UserID:  opts.UserID,
+		Content: opts.Content,
+	}
+	if err := db.WithContext(ctx).Create(&prompt).Error; err != nil {
+		return nil, errors.Wrap(err, "create prompt")
+	}
+	return &prompt, nil
+}
+
+func (db *prompts) GetByID(ctx context.Context, id uint) (*Prompt, error) {
+	var prompt Prompt
+	if err := db.WithContext(ctx).Where("id = ?", id).First(&prompt).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPromptNotExist
+		}
+		return nil, errors.Wrap(err, "get prompt by ID")
+	}
+	return &prompt, nil
+}
+
+func (db *prompts) GetByUserID(ctx context.Context, userID uint) ([]*Prompt, error) {
+	var prompts []*Prompt
+	if err := db.WithContext(ctx).Where("user_id = ?", userID).Order("id DESC").Find(&prompts).Error; err != nil {
+		return nil, errors.Wrap(err, "get prompts by user ID")
+	}
+	return prompts, nil
+}
+
+// DeleteByID closes the prompt, the questions asked under it are kept.
+func (db *prompts) DeleteByID(ctx context.Context, userID, id uint) error {
+	result := db.WithContext(ctx).Where("user_id = ? AND id = ?", userID, id).Delete(&Prompt{})
+	if result.Error != nil {
+		return errors.Wrap(result.Error, "delete prompt")
+	}
+	if result.RowsAffected == 0 {
+		return ErrPromptNotExist
+	}
+	return nil
+}
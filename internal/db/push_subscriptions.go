@@ -0,0 +1,114 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+
+	"github.com/NekoWheel/NekoBox/internal/dbutil"
+)
+
+var PushSubscriptions PushSubscriptionsStore
+
+var _ PushSubscriptionsStore = (*pushSubscriptions)(nil)
+
+type PushSubscriptionsStore interface {
+	Create(ctx context.Context, opts CreatePushSubscriptionOptions) error
+	GetByUserID(ctx context.Context, userID uint) ([]*PushSubscription, error)
+	DeleteByEndpoint(ctx context.Context, userID uint, endpoint string) error
+	DeleteByID(ctx context.Context, id uint) error
+}
+
+func NewPushSubscriptionsStore(db *gorm.DB) PushSubscriptionsStore {
+	return &pushSubscriptions{db}
+}
+
+type pushSubscriptions struct {
+	*gorm.DB
+}
+
+// PushSubscription is the Web Push subscription of a browser, the notifications
+// are encrypted with the keys and sent to the endpoint of the browser's push service.
+type PushSubscription struct {
+	dbutil.Model
+	UserID    uint   `gorm:"index:idx_push_subscription_user_id" json:"-"`
+	Endpoint  string `gorm:"type:varchar(1024)" json:"-"`
+	P256dh    string `json:"-"`
+	Auth      string `json:"-"`
+	UserAgent string `json:"user_agent"`
+}
+
+// MaxPushSubscriptionsPerUser is the maximum number of the browsers that a user can subscribe,
+// the oldest subscriptions are removed when it is exceeded.
+const MaxPushSubscriptionsPerUser = 10
+
+type CreatePushSubscriptionOptions struct {
+	UserID    uint
+	Endpoint  string
+	P256dh    string
+	Auth      string
+	UserAgent string
+}
+
+// Create saves the subscription of the browser. The endpoint is unique to the browser,
+// so the existing subscription of the endpoint is replaced, even if it belongs to another user.
+func (db *pushSubscriptions) Create(ctx context.Context, opts CreatePushSubscriptionOptions) error {
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("endpoint = ?", opts.Endpoint).Delete(&PushSubscription{}).Error; err != nil {
+			return errors.Wrap(err, "delete existing subscription")
+		}
+
+		subscription := PushSubscription{
+			UserID:    opts.UserID,
+			Endpoint:  opts.Endpoint,
+			P256dh:    opts.P256dh,
+			Auth:      opts.Auth,
+			UserAgent: opts.UserAgent,
+		}
+		if err := tx.Create(&subscription).Error; err != nil {
+			return errors.Wrap(err, "create subscription")
+		}
+
+		var staleIDs []uint
+		if err := tx.Model(&PushSubscription{}).Where("user_id = ?", opts.UserID).
+			Order("id DESC").Offset(MaxPushSubscriptionsPerUser).Limit(MaxPushSubscriptionsPerUser).
+			Pluck("id", &staleIDs).Error; err != nil {
+			return errors.Wrap(err, "get stale subscriptions")
+		}
+		if len(staleIDs) > 0 {
+			if err := tx.Unscoped().Where("id IN (?)", staleIDs).Delete(&PushSubscription{}).Error; err != nil {
+				return errors.Wrap(err, "delete stale subscriptions")
+			}
+		}
+		return nil
+	})
+}
+
+func (db *pushSubscriptions) GetByUserID(ctx context.Context, userID uint) ([]*PushSubscription, error) {
+	var subscriptions []*PushSubscription
+	if err := db.WithContext(ctx).Where("user_id = ?", userID).Order("id ASC").Find(&subscriptions).Error; err != nil {
+		return nil, errors.Wrap(err, "get push subscriptions by user ID")
+	}
+	return subscriptions, nil
+}
+
+// DeleteByEndpoint deletes the subscription when the user turns off the notifications in the browser.
+func (db *pushSubscriptions) DeleteByEndpoint(ctx context.Context, userID uint, endpoint string) error {
+	if err := db.WithContext(ctx).Unscoped().Where("user_id = ? AND endpoint = ?", userID, endpoint).Delete(&PushSubscription{}).Error; err != nil {
+		return errors.Wrap(err, "delete push subscription")
+	}
+	return nil
+}
+
+// DeleteByID deletes the subscription which has expired or been revoked by the browser.
+func (db *pushSubscriptions) DeleteByID(ctx context.Context, id uint) error {
+	if err := db.WithContext(ctx).Unscoped().Where("id = ?", id).Delete(&PushSubscription{}).Error; err != nil {
+		return errors.Wrap(err, "delete push subscription")
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/NekoWheel/NekoBox/internal/dbutil"
+)
+
+var QuestionDrafts QuestionDraftsStore
+
+var _ QuestionDraftsStore = (*questionDrafts)(nil)
+
+type QuestionDraftsStore interface {
+	// Save creates or updates the asker's draft for the box, the draft is deleted if the content is empty.
+	Save(ctx context.Context, userID, receiverUserID uint, content string) error
+	Get(ctx context.Context, userID, receiverUserID uint) (*QuestionDraft, error)
+	Delete(ctx context.Context, userID, receiverUserID uint) error
+}
+
+func NewQuestionDraftsStore(db *gorm.DB) QuestionDraftsStore {
+	return &questionDrafts{db}
+}
+
+// QuestionDraft is the unsent question of the logged-in asker, each asker has at most one draft for a box.
+type QuestionDraft struct {
+	dbutil.Model
+	UserID         uint   `gorm:"uniqueIndex:idx_question_draft_user_receiver" json:"-"`
+	ReceiverUserID uint   `gorm:"uniqueIndex:idx_question_draft_user_receiver" json:"-"`
+	Content        string `json:"content"`
+}
+
+type questionDrafts struct {
+	*gorm.DB
+}
+
+var ErrQuestionDraftNotExist = errors.New("草稿不存在")
+
+func (db *questionDrafts) Save(ctx context.Context, userID, receiverUserID uint, content string) error {
+	if content == "" {
+		return db.Delete(ctx, userID, receiverUserID)
+	}
+
+	draft := QuestionDraft{
+		UserID:         userID,
+		ReceiverUserID: receiverUserID,
+		Content:        content,
+	}
+	if err := db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "receiver_user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"content", "updated_at"}),
+	}).Create(&draft).Error; err != nil {
+		return errors.Wrap(err, "save question draft")
+	}
+	return nil
+}
+
+func (db *questionDrafts) Get(ctx context.Context, userID, receiverUserID uint) (*QuestionDraft, error) {
+	var draft QuestionDraft
+	if err := db.WithContext(ctx).Where("user_id = ? AND receiver_user_id = ?", userID, receiverUserID).First(&draft).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrQuestionDraftNotExist
+		}
+		return nil, errors.Wrap(err, "get question draft")
+	}
+	return &draft, nil
+}
+
+func (db *questionDrafts) Delete(ctx context.Context, userID, receiverUserID uint) error {
+	// The draft is deleted permanently, so that the unique index allows saving a new one.
+	if err := db.WithContext(ctx).Unscoped().Where("user_id = ? AND receiver_user_id = ?", userID, receiverUserID).Delete(&QuestionDraft{}).Error; err != nil {
+		return errors.Wrap(err, "delete question draft")
+	}
+	return nil
+}
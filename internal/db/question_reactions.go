@@ -0,0 +1,134 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+
+	"github.com/NekoWheel/NekoBox/internal/dbutil"
+)
+
+var QuestionReactions QuestionReactionsStore
+
+var _ QuestionReactionsStore = (*questionReactions)(nil)
+
+type QuestionReactionsStore interface {
+	Create(ctx context.Context, opts CreateQuestionReactionOptions) error
+	HasReacted(ctx context.Context, questionID uint, opts HasReactedOptions) (bool, error)
+}
+
+func NewQuestionReactionsStore(db *gorm.DB) QuestionReactionsStore {
+	return &questionReactions{db}
+}
+
+type questionReactions struct {
+	*gorm.DB
+}
+
+type ReactionType string
+
+const (
+	ReactionTypeLike ReactionType = "like"
+)
+
+// QuestionReaction is a visitor's reaction to the answered question, the visitor is identified by
+// the user ID if the visitor has logged in, otherwise by the hashed IP address.
+type QuestionReaction struct {
+	dbutil.Model
+	QuestionID uint         `gorm:"uniqueIndex:idx_question_reaction_source" json:"question_id"`
+	Type       ReactionType `gorm:"uniqueIndex:idx_question_reaction_source;type:varchar(16)" json:"type"`
+	UserID     uint         `gorm:"uniqueIndex:idx_question_reaction_source" json:"-"`
+	IPHash     string       `gorm:"uniqueIndex:idx_question_reaction_source;type:varchar(64)" json:"-"`
+}
+
+var (
+	ErrReactionExists   = errors.New("你已经点过赞了")
+	ErrReactionNoSource = errors.New("无法识别你的来源，请登录后再试")
+)
+
+type CreateQuestionReactionOptions struct {
+	QuestionID uint
+	Type       ReactionType
+	UserID     uint
+	IP         string
+}
+
+// Create adds the reaction to the question and increases the question's like count.
+func (db *questionReactions) Create(ctx context.Context, opts CreateQuestionReactionOptions) error {
+	switch opts.Type {
+	case ReactionTypeLike:
+	default:
+		return errors.Errorf("unexpected reaction type: %q", opts.Type)
+	}
+
+	reaction := QuestionReaction{
+		QuestionID: opts.QuestionID,
+		Type:       opts.Type,
+		UserID:     opts.UserID,
+	}
+	// Prefer the user ID, the IP address of the logged user may change.
+	ipHashes := []string{""}
+	if reaction.UserID == 0 {
+		if opts.IP == "" {
+			return ErrReactionNoSource
+		}
+		reaction.IPHash = saltedHashIP(opts.IP)
+		ipHashes = visitorIPHashes(opts.IP)
+	}
+
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&QuestionReaction{}).
+			Where("question_id = ? AND type = ? AND user_id = ? AND ip_hash IN (?)", reaction.QuestionID, reaction.Type, reaction.UserID, ipHashes).
+			Count(&count).Error; err != nil {
+			return errors.Wrap(err, "count reactions")
+		}
+		if count > 0 {
+			return ErrReactionExists
+		}
+
+		// The concurrent requests of the same visitor may all pass the check above,
+		// the unique index makes sure only one of them is counted.
+		if err := tx.Create(&reaction).Error; err != nil {
+			if dbutil.IsUniqueViolation(err) {
+				return ErrReactionExists
+			}
+			return errors.Wrap(err, "create reaction")
+		}
+		if err := tx.Model(&Question{}).Where("id = ?", reaction.QuestionID).
+			UpdateColumn("like_count", gorm.Expr("like_count + 1")).Error; err != nil {
+			return errors.Wrap(err, "increase like count")
+		}
+		return nil
+	})
+}
+
+type HasReactedOptions struct {
+	Type   ReactionType
+	UserID uint
+	IP     string
+}
+
+// HasReacted checks whether the visitor has reacted to the question.
+func (db *questionReactions) HasReacted(ctx context.Context, questionID uint, opts HasReactedOptions) (bool, error) {
+	q := db.WithContext(ctx).Model(&QuestionReaction{}).Where("question_id = ? AND type = ?", questionID, opts.Type)
+	switch {
+	case opts.UserID != 0:
+		q = q.Where("user_id = ?", opts.UserID)
+	case opts.IP != "":
+		q = q.Where("user_id = 0 AND ip_hash IN (?)", visitorIPHashes(opts.IP))
+	default:
+		return false, nil
+	}
+
+	var count int64
+	if err := q.Count(&count).Error; err != nil {
+		return false, errors.Wrap(err, "count reactions")
+	}
+	return count > 0, nil
+}
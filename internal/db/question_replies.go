@@ -0,0 +1,92 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+
+	"github.com/NekoWheel/NekoBox/internal/dbutil"
+)
+
+var QuestionReplies QuestionRepliesStore
+
+var _ QuestionRepliesStore = (*questionReplies)(nil)
+
+type QuestionRepliesStore interface {
+	Create(ctx context.Context, opts CreateQuestionReplyOptions) (*QuestionReply, error)
+	GetByQuestionID(ctx context.Context, questionID uint) ([]*QuestionReply, error)
+	UpdateCensor(ctx context.Context, id uint, censorMetadata json.RawMessage) error
+	ExpireIPs(ctx context.Context, opts ExpireIPsOptions) (int64, error)
+}
+
+func NewQuestionRepliesStore(db *gorm.DB) QuestionRepliesStore {
+	return &questionReplies{db}
+}
+
+type questionReplies struct {
+	*gorm.DB
+}
+
+// QuestionReply is a follow-up message of an answered question,
+// which is sent by the asker or the box owner.
+type QuestionReply struct {
+	dbutil.Model
+	QuestionID            uint           `gorm:"index:idx_question_reply_question_id" json:"-"`
+	FromIP                string         `json:"-"`
+	IsOwner               bool           `json:"is_owner"`
+	Content               string         `json:"content"`
+	ContentCensorMetadata datatypes.JSON `json:"-"`
+}
+
+type CreateQuestionReplyOptions struct {
+	QuestionID uint
+	FromIP     string
+	IsOwner    bool
+	Content    string
+}
+
+func (db *questionReplies) Create(ctx context.Context, opts CreateQuestionReplyOptions) (*QuestionReply, error) {
+	if err := ValidateQuestionLength(opts.Content); err != nil {
+		return nil, err
+	}
+
+	reply := QuestionReply{
+		QuestionID: opts.QuestionID,
+		FromIP:     storeIP(opts.FromIP),
+		IsOwner:    opts.IsOwner,
+		Content:    opts.Content,
+	}
+	if err := db.WithContext(ctx).Create(&reply).Error; err != nil {
+		return nil, errors.Wrap(err, "create question reply")
+	}
+	return &reply, nil
+}
+
+func (db *questionReplies) GetByQuestionID(ctx context.Context, questionID uint) ([]*QuestionReply, error) {
+	var replies []*QuestionReply
+	if err := db.WithContext(ctx).Where("question_id = ?", questionID).Order("created_at ASC").Find(&replies).Error; err != nil {
+		return nil, errors.Wrap(err, "get question replies by question ID")
+	}
+	return replies, nil
+}
+
+func (db *questionReplies) UpdateCensor(ctx context.Context, id uint, censorMetadata json.RawMessage) error {
+	if !checkTextCensorResponseValid(censorMetadata) {
+		return nil
+	}
+
+	return db.WithContext(ctx).Model(&QuestionReply{}).Where("id = ?", id).Update("content_censor_metadata", datatypes.JSON(censorMetadata)).Error
+}
+
+// ExpireIPs hashes or clears the raw IP addresses of the replies which are expired.
+// It returns the number of the updated replies.
+func (db *questionReplies) ExpireIPs(ctx context.Context, opts ExpireIPsOptions) (int64, error) {
+	return expireIPs(ctx, db.DB, &QuestionReply{}, opts)
+}
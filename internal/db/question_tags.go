@@ -0,0 +1,228 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"context"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+
+	"github.com/NekoWheel/NekoBox/internal/dbutil"
+)
+
+var QuestionTags QuestionTagsStore
+
+var _ QuestionTagsStore = (*questionTags)(nil)
+
+type QuestionTagsStore interface {
+	SetForQuestion(ctx context.Context, userID, questionID uint, names []string) error
+	GetByQuestionID(ctx context.Context, questionID uint) ([]string, error)
+	GetByQuestionIDs(ctx context.Context, questionIDs []uint) (map[uint][]string, error)
+	ListByUserID(ctx context.Context, userID uint, opts ListTagsOptions) ([]*TagCount, error)
+	Rename(ctx context.Context, userID uint, name, newName string) error
+	Delete(ctx context.Context, userID uint, name string) error
+}
+
+func NewQuestionTagsStore(db *gorm.DB) QuestionTagsStore {
+	return &questionTags{db}
+}
+
+type questionTags struct {
+	*gorm.DB
+}
+
+// QuestionTag is a topic assigned to the question by the box owner.
+// The tags are not stored separately, a tag of the user is the set of the rows with the same name.
+type QuestionTag struct {
+	dbutil.Model
+	UserID     uint   `gorm:"index:idx_question_tag_user_name" json:"-"`
+	QuestionID uint   `gorm:"uniqueIndex:idx_question_tag_question_name" json:"question_id"`
+	Name       string `gorm:"index:idx_question_tag_user_name;uniqueIndex:idx_question_tag_question_name;type:varchar(20)" json:"name"`
+}
+
+const (
+	// MaxTagsPerQuestion is the max number of the tags of a question.
+	MaxTagsPerQuestion = 5
+	// MaxTagNameLength is the max length of the tag name in characters.
+	MaxTagNameLength = 20
+)
+
+var (
+	ErrTooManyTags    = errors.New("每个提问最多只能添加 5 个标签")
+	ErrTagNameTooLong = errors.New("标签名最长 20 个字符")
+	ErrTagNotExist    = errors.New("标签不存在")
+)
+
+// ParseTags splits the tags separated by commas, the duplicated and empty ones are removed.
+func ParseTags(s string) []string {
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == '，' || r == '、'
+	})
+
+	tags := make([]string, 0, len(fields))
+	seen := make(map[string]struct{}, len(fields))
+	for _, field := range fields {
+		tag := strings.TrimSpace(field)
+		if tag == "" {
+			continue
+		}
+		if _, ok := seen[tag]; ok {
+			continue
+		}
+		seen[tag] = struct{}{}
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// ValidateTags checks the number of the tags and the length of the tag names.
+func ValidateTags(names []string) error {
+	if len(names) > MaxTagsPerQuestion {
+		return ErrTooManyTags
+	}
+	for _, name := range names {
+		if utf8.RuneCountInString(name) > MaxTagNameLength {
+			return ErrTagNameTooLong
+		}
+	}
+	return nil
+}
+
+// SetForQuestion replaces the tags of the question with the given names.
+func (db *questionTags) SetForQuestion(ctx context.Context, userID, questionID uint, names []string) error {
+	if err := ValidateTags(names); err != nil {
+		return err
+	}
+
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Delete permanently, so the same tag can be added again with the unique index.
+		if err := tx.Unscoped().Where("question_id = ?", questionID).Delete(&QuestionTag{}).Error; err != nil {
+			return errors.Wrap(err, "delete question tags")
+		}
+		if len(names) == 0 {
+			return nil
+		}
+
+		tags := make([]*QuestionTag, 0, len(names))
+		for _, name := range names {
+			tags = append(tags, &QuestionTag{
+				UserID:     userID,
+				QuestionID: questionID,
+				Name:       name,
+			})
+		}
+		if err := tx.Create(&tags).Error; err != nil {
+			return errors.Wrap(err, "create question tags")
+		}
+		return nil
+	})
+}
+
+func (db *questionTags) GetByQuestionID(ctx context.Context, questionID uint) ([]string, error) {
+	var names []string
+	if err := db.WithContext(ctx).Model(&QuestionTag{}).Where("question_id = ?", questionID).Order("id ASC").Pluck("name", &names).Error; err != nil {
+		return nil, errors.Wrap(err, "get tags by question ID")
+	}
+	return names, nil
+}
+
+// GetByQuestionIDs returns the tags of the questions, keyed by the question ID.
+func (db *questionTags) GetByQuestionIDs(ctx context.Context, questionIDs []uint) (map[uint][]string, error) {
+	tagsByQuestion := make(map[uint][]string, len(questionIDs))
+	if len(questionIDs) == 0 {
+		return tagsByQuestion, nil
+	}
+
+	var tags []*QuestionTag
+	if err := db.WithContext(ctx).Where("question_id IN (?)", questionIDs).Order("id ASC").Find(&tags).Error; err != nil {
+		return nil, errors.Wrap(err, "get tags by question IDs")
+	}
+	for _, tag := range tags {
+		tagsByQuestion[tag.QuestionID] = append(tagsByQuestion[tag.QuestionID], tag.Name)
+	}
+	return tagsByQuestion, nil
+}
+
+type TagCount struct {
+	Name  string `json:"name"`
+	Count int64  `json:"count"`
+}
+
+type ListTagsOptions struct {
+	// FilterAnswered only counts the public answered questions, which are visible to the visitors.
+	FilterAnswered bool
+}
+
+// ListByUserID returns the tags used by the user with the number of the questions, the most used ones come first.
+func (db *questionTags) ListByUserID(ctx context.Context, userID uint, opts ListTagsOptions) ([]*TagCount, error) {
+	questions := db.WithContext(ctx).Model(&Question{}).Select("id").Where("user_id = ?", userID)
+	if opts.FilterAnswered {
+		questions = questions.Where(publiclyAnswered)
+	}
+
+	var tags []*TagCount
+	if err := db.WithContext(ctx).Model(&QuestionTag{}).
+		Select("name, COUNT(*) AS count").
+		Where("user_id = ? AND question_id IN (?)", userID, questions).
+		Group("name").
+		Order("count DESC").Order("name ASC").
+		Scan(&tags).Error; err != nil {
+		return nil, errors.Wrap(err, "list tags by user ID")
+	}
+	return tags, nil
+}
+
+// Rename renames the tag of all the user's questions,
+// the tag is merged into the existing one if the new name has been used.
+func (db *questionTags) Rename(ctx context.Context, userID uint, name, newName string) error {
+	if err := ValidateTags([]string{newName}); err != nil {
+		return err
+	}
+	if name == newName {
+		return nil
+	}
+
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&QuestionTag{}).Where("user_id = ? AND name = ?", userID, name).Count(&count).Error; err != nil {
+			return errors.Wrap(err, "count tags")
+		}
+		if count == 0 {
+			return ErrTagNotExist
+		}
+
+		// Remove the tag from the questions which have been tagged with the new name.
+		// MySQL can't delete with a subquery on the same table, so the question IDs are queried first.
+		var mergedQuestionIDs []uint
+		if err := tx.Model(&QuestionTag{}).Where("user_id = ? AND name = ?", userID, newName).Pluck("question_id", &mergedQuestionIDs).Error; err != nil {
+			return errors.Wrap(err, "get merged question IDs")
+		}
+		if len(mergedQuestionIDs) > 0 {
+			if err := tx.Unscoped().Where("user_id = ? AND name = ? AND question_id IN (?)", userID, name, mergedQuestionIDs).Delete(&QuestionTag{}).Error; err != nil {
+				return errors.Wrap(err, "delete merged tags")
+			}
+		}
+
+		if err := tx.Model(&QuestionTag{}).Where("user_id = ? AND name = ?", userID, name).Update("name", newName).Error; err != nil {
+			return errors.Wrap(err, "rename tag")
+		}
+		return nil
+	})
+}
+
+// Delete removes the tag from all the user's questions.
+func (db *questionTags) Delete(ctx context.Context, userID uint, name string) error {
+	result := db.WithContext(ctx).Unscoped().Where("user_id = ? AND name = ?", userID, name).Delete(&QuestionTag{})
+	if result.Error != nil {
+		return errors.Wrap(result.Error, "delete tag")
+	}
+	if result.RowsAffected == 0 {
+		return ErrTagNotExist
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/NekoWheel/NekoBox/internal/dbutil"
+)
+
+var QuestionTranslations QuestionTranslationsStore
+
+var _ QuestionTranslationsStore = (*questionTranslations)(nil)
+
+type QuestionTranslationsStore interface {
+	// Get returns the cached translation of the question into the target language.
+	Get(ctx context.Context, questionID uint, targetLang string) (*QuestionTranslation, error)
+	// Save creates or replaces the cached translation of the question into the target language.
+	Save(ctx context.Context, opts SaveQuestionTranslationOptions) (*QuestionTranslation, error)
+}
+
+func NewQuestionTranslationsStore(db *gorm.DB) QuestionTranslationsStore {
+	return &questionTranslations{db}
+}
+
+// QuestionTranslation is the machine translation of the question's content, which is cached
+// so that the question is translated by the provider only once for each language.
+type QuestionTranslation struct {
+	dbutil.Model
+	QuestionID uint   `gorm:"uniqueIndex:idx_question_translation_question_target" json:"-"`
+	TargetLang string `gorm:"type:varchar(16);uniqueIndex:idx_question_translation_question_target" json:"target_lang"`
+	// SourceLang is the language of the question detected by the provider.
+	SourceLang string `gorm:"type:varchar(16)" json:"source_lang"`
+	Provider   string `gorm:"type:varchar(20)" json:"-"`
+	Text       string `gorm:"type:text" json:"text"`
+}
+
+type questionTranslations struct {
+	*gorm.DB
+}
+
+var ErrQuestionTranslationNotExist = errors.New("翻译不存在")
+
+func (db *questionTranslations) Get(ctx context.Context, questionID uint, targetLang string) (*QuestionTranslation, error) {
+	var translation QuestionTranslation
+	if err := db.WithContext(ctx).Where("question_id = ? AND target_lang = ?", questionID, targetLang).First(&translation).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrQuestionTranslationNotExist
+		}
+		return nil, errors.Wrap(err, "get question translation")
+	}
+	return &translation, nil
+}
+
+type SaveQuestionTranslationOptions struct {
+	QuestionID uint
+	TargetLang string
+	SourceLang string
+	Provider   string
+	Text       string
+}
+
+func (db *questionTranslations) Save(ctx context.Context, opts SaveQuestionTranslationOptions) (*QuestionTranslation, error) {
+	translation := QuestionTranslation{
+		QuestionID: opts.QuestionID,
+		TargetLang: opts.TargetLang,
+		SourceLang: opts.SourceLang,
+		Provider:   opts.Provider,
+		Text:       opts.Text,
+	}
+	if err := db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "question_id"}, {Name: "target_lang"}},
+		DoUpdates: clause.AssignmentColumns([]string{"source_lang", "provider", "text", "updated_at"}),
+	}).Create(&translation).Error; err != nil {
+		return nil, errors.Wrap(err, "save question translation")
+	}
+	return &translation, nil
+}
@@ -7,28 +7,91 @@ package db
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/pkg/errors"
 	"github.com/thanhpk/randstr"
+	"golang.org/x/text/unicode/norm"
 	"gorm.io/datatypes"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
+	"github.com/NekoWheel/NekoBox/internal/conf"
 	"github.com/NekoWheel/NekoBox/internal/dbutil"
+	"github.com/NekoWheel/NekoBox/internal/security/disposable"
+	"github.com/NekoWheel/NekoBox/internal/textstat"
 )
 
 var Questions QuestionsStore
 
 type QuestionsStore interface {
 	Create(ctx context.Context, opts CreateQuestionOptions) (*Question, error)
+	CreateBatch(ctx context.Context, opts CreateQuestionBatchOptions) (int, error)
 	GetByID(ctx context.Context, id uint) (*Question, error)
-	GetByUserID(ctx context.Context, userID uint, opts GetQuestionsByUserIDOptions) ([]*Question, error)
-	GetByAskUserID(ctx context.Context, userID uint, opts GetQuestionsByAskUserIDOptions) ([]*Question, error)
-	AnswerByID(ctx context.Context, id uint, answer string) error
+	GetByIDs(ctx context.Context, userID uint, ids []uint) ([]*Question, error)
+	GetByShortSlug(ctx context.Context, slug string) (*Question, error)
+	FindRecentDuplicate(ctx context.Context, opts FindRecentDuplicateOptions) (*Question, error)
+	CountRecentByAsker(ctx context.Context, opts CountRecentByAskerOptions) (int64, error)
+	GetAskerHistory(ctx context.Context, askerUserID uint) (*AskerHistory, error)
+	GetByUserID(ctx context.Context, userID uint, opts GetQuestionsByUserIDOptions) ([]*Question, *dbutil.PageInfo, error)
+	GetByAskUserID(ctx context.Context, userID uint, opts GetQuestionsByAskUserIDOptions) ([]*Question, *dbutil.PageInfo, error)
+	Search(ctx context.Context, userID uint, keyword string, cursor *dbutil.Cursor) ([]*Question, *dbutil.PageInfo, error)
+	GetHot(ctx context.Context, userID uint) ([]*Question, error)
+	GetLongest(ctx context.Context, userID uint) ([]*Question, error)
+	GetRandomAnswered(ctx context.Context, userID uint) (*Question, error)
+	IterateByUserID(ctx context.Context, userID uint, fn func(*Question) error) error
+	IterateByAskUserID(ctx context.Context, userID uint, fn func(*Question) error) error
+	IterateAnswered(ctx context.Context, fn func(*Question) error) error
+	IterateAnsweredByUserID(ctx context.Context, userID uint, fn func(*Question) error) error
+	IterateFiltered(ctx context.Context, opts IterateFilteredQuestionsOptions, fn func([]*Question) error) error
+	AnswerByID(ctx context.Context, id uint, answer string, answerUserID uint) error
+	AnswerByIDIfVersion(ctx context.Context, id, version uint, answer string, answerUserID uint) error
+	UpdateAnswerByID(ctx context.Context, id uint, answer string, answerUserID uint) error
 	DeleteByID(ctx context.Context, id uint) error
+	DeleteByIDs(ctx context.Context, userID uint, ids []uint) (int64, error)
+	MarkReadByID(ctx context.Context, id uint) error
+	UpdatePrivateNote(ctx context.Context, id uint, note string) error
+	UpdateAnswerAudio(ctx context.Context, id uint, audioURL string, duration int) error
+	GetAnswerAudioURLs(ctx context.Context, userID uint) ([]string, error)
+	Merge(ctx context.Context, userID, id, canonicalID uint) (*Question, error)
+	GetMerged(ctx context.Context, canonicalID uint) ([]*Question, error)
+	MarkReadByIDs(ctx context.Context, userID uint, ids []uint) (int64, error)
+	MarkAllRead(ctx context.Context, userID uint) (int64, error)
+	GetTrashedByID(ctx context.Context, id uint) (*Question, error)
+	GetTrashedByUserID(ctx context.Context, userID uint) ([]*Question, error)
+	Restore(ctx context.Context, id uint) error
+	PurgeTrashed(ctx context.Context, deletedBefore time.Time) (int64, []string, error)
+	ExpireIPs(ctx context.Context, opts ExpireIPsOptions) (int64, error)
+	PinByID(ctx context.Context, id uint) error
+	UnpinByID(ctx context.Context, id uint) error
+	SetVisibility(ctx context.Context, id uint, visibility QuestionVisibility) error
+	SetHidden(ctx context.Context, id uint, hidden bool) error
+	EnsureShortSlug(ctx context.Context, id uint) (string, error)
 	UpdateCensor(ctx context.Context, id uint, opts UpdateQuestionCensorOptions) error
+	ListPendingCensor(ctx context.Context, opts ListPendingCensorOptions) ([]*Question, error)
+	// IncreaseCensorRedriveCount records that the censor jobs of the given questions have been re-driven once more.
+	IncreaseCensorRedriveCount(ctx context.Context, ids ...uint) error
+	ListUnansweredAfter(ctx context.Context, userID, afterID uint, limit int) ([]*Question, error)
+	ArchiveUnanswered(ctx context.Context, userID uint, createdBefore time.Time) (int64, error)
+	ListUnreminded(ctx context.Context, userID uint, createdBefore time.Time, limit int) ([]*Question, error)
+	MarkReminded(ctx context.Context, userID uint, createdBefore time.Time) (int64, error)
+	GetNextInQueue(ctx context.Context, userID uint, n int) ([]*Question, error)
+	GetNextUnanswered(ctx context.Context, userID uint, opts GetNextUnansweredOptions) (*Question, error)
+	GetQueuePosition(ctx context.Context, userID, questionID uint) (int64, error)
+	CountInQueue(ctx context.Context, userID uint) (int64, error)
+	CountAnsweredSince(ctx context.Context, userID uint, since time.Time) (int64, error)
 	Count(ctx context.Context, userID uint, opts GetQuestionsCountOptions) (int64, error)
+	CountBatch(ctx context.Context, userIDs []uint, opts GetQuestionsCountOptions) (map[uint]int64, error)
+	StatsByUserID(ctx context.Context, userID uint, opts QuestionStatsOptions) (*QuestionStats, error)
 }
 
 func NewQuestionsStore(db *gorm.DB) QuestionsStore {
@@ -41,45 +104,431 @@ type questions struct {
 
 type Question struct {
 	dbutil.Model
-	FromIP                string         `json:"-"`
-	UserID                uint           `gorm:"index:idx_question_user_id" json:"-"`
-	Content               string         `json:"content"`
-	ContentCensorMetadata datatypes.JSON `json:"-"`
-	ContentCensorPass     bool           `gorm:"->;type:boolean GENERATED ALWAYS AS (IFNULL(content_censor_metadata->'$.pass' = true, false)) STORED NOT NULL" json:"-"`
-	Token                 string         `json:"-"`
-	Answer                string         `json:"answer"`
-	AnswerCensorMetadata  datatypes.JSON `json:"-"`
-	AnswerCensorPass      bool           `gorm:"->;type:boolean GENERATED ALWAYS AS (IFNULL(answer_censor_metadata->'$.pass' = true, false)) STORED NOT NULL" json:"-"`
-	ReceiveReplyEmail     string         `json:"-"`
-	AskerUserID           uint           `json:"-"`
+	FromIP                  string             `json:"-"`
+	FromRegion              string             `gorm:"type:varchar(64)" json:"-"`
+	SpamScore               int                `gorm:"not null;default:0" json:"-"`
+	RemindedAt              *time.Time         `json:"-"`
+	Source                  datatypes.JSON     `json:"-"`
+	UserID                  uint               `gorm:"index:idx_question_user_id" json:"-"`
+	Content                 string             `json:"content"`
+	ContentCensorMetadata   datatypes.JSON     `json:"-"`
+	ContentCensorPass       bool               `gorm:"not null;default:false" json:"-"`
+	Token                   string             `json:"-"`
+	Answer                  string             `json:"answer"`
+	AnswerCensorMetadata    datatypes.JSON     `json:"-"`
+	AnswerCensorPass        bool               `gorm:"not null;default:false" json:"-"`
+	CensorRedriveCount      int                `gorm:"not null;default:0" json:"-"`
+	AnswerUpdatedAt         *time.Time         `json:"answer_updated_at"`
+	AnsweredAt              *time.Time         `json:"answered_at"`
+	AnswerUserID            uint               `json:"-"`
+	AnswerCharCount         int                `gorm:"not null;default:0" json:"answer_char_count"`
+	AnswerWordCount         int                `gorm:"not null;default:0" json:"answer_word_count"`
+	AnswerReadingSeconds    int                `gorm:"not null;default:0" json:"answer_reading_seconds"`
+	AnswerAudioURL          string             `json:"answer_audio_url,omitempty"`
+	AnswerAudioDuration     int                `gorm:"not null;default:0" json:"answer_audio_duration,omitempty"`
+	Version                 uint               `gorm:"not null;default:0" json:"version"`
+	ReceiveReplyEmail       string             `json:"-"`
+	AskerUserID             uint               `gorm:"index:idx_question_asker_user_id" json:"-"`
+	AskerPseudonym          string             `gorm:"type:varchar(32)" json:"-"`
+	PromptID                uint               `gorm:"index:idx_question_prompt_id" json:"prompt_id"`
+	ImportHash              string             `gorm:"type:varchar(64);index:idx_question_import_hash" json:"-"`
+	ContentHash             string             `gorm:"type:varchar(64);index:idx_question_content_hash" json:"-"`
+	ReadAt                  *time.Time         `json:"read_at"`
+	Pinned                  bool               `gorm:"index:idx_question_pinned" json:"pinned"`
+	PinnedAt                *time.Time         `json:"pinned_at"`
+	LikeCount               uint               `gorm:"not null;default:0" json:"like_count"`
+	Visibility              QuestionVisibility `gorm:"type:varchar(16);not null;default:public" json:"visibility"`
+	ShareToken              string             `gorm:"type:varchar(16)" json:"-"`
+	ShortSlug               *string            `gorm:"type:varchar(16);uniqueIndex:idx_question_short_slug" json:"-"`
+	ForwardedFromQuestionID uint               `gorm:"index:idx_question_forwarded_from_question_id" json:"forwarded_from_question_id"`
+	ForwardedFromUserID     uint               `json:"-"`
+	HiddenAt                *time.Time         `gorm:"index:idx_question_hidden_at" json:"-"`
+	ArchivedAt              *time.Time         `gorm:"index:idx_question_archived_at" json:"archived_at"`
+	ShadowBanned            bool               `gorm:"not null;default:false;index:idx_question_shadow_banned" json:"-"`
+	PrivateNote             string             `json:"-"`
+	MergedIntoID            uint               `gorm:"index:idx_question_merged_into_id" json:"merged_into_id"`
+	Tags                    []string           `gorm:"-" json:"tags"`
+	License                 *LicenseInfo       `gorm:"-" json:"license,omitempty"`
+}
+
+// QuestionVisibility controls who can see the answered question, the unanswered questions
+// can only be seen by the box's owner and the asker regardless of the visibility.
+type QuestionVisibility string
+
+const (
+	// QuestionVisibilityPublic questions are listed on the box page.
+	QuestionVisibilityPublic QuestionVisibility = "public"
+	// QuestionVisibilityUnlisted questions are not listed, but can be seen by anyone with the share link.
+	QuestionVisibilityUnlisted QuestionVisibility = "unlisted"
+	// QuestionVisibilityPrivate questions can only be seen by the box's owner and the asker.
+	QuestionVisibilityPrivate QuestionVisibility = "private"
+)
+
+// IsValid returns whether the visibility is one of the known values.
+func (v QuestionVisibility) IsValid() bool {
+	switch v {
+	case QuestionVisibilityPublic, QuestionVisibilityUnlisted, QuestionVisibilityPrivate:
+		return true
+	}
+	return false
+}
+
+// notShadowBanned is the condition of the questions which are not sent by the shadow-banned askers,
+// the questions in the shadow folder are excluded from the inbox, the queue and the notifications.
+const notShadowBanned = `shadow_banned = FALSE`
+
+// publiclyAnswered is the condition of the questions listed on the public box page,
+// the questions hidden for the reports are excluded.
+const publiclyAnswered = `answer <> '' AND visibility = 'public' AND hidden_at IS NULL`
+
+// IsVisible returns whether the question can be seen by the visitor with the given share token,
+// the box's owner and the asker are not checked here.
+func (q *Question) IsVisible(shareToken string) bool {
+	if q.Answer == "" || q.HiddenAt != nil {
+		return false
+	}
+	switch q.Visibility {
+	case QuestionVisibilityPublic, "":
+		return true
+	case QuestionVisibilityUnlisted:
+		return q.ShareToken != "" && shareToken == q.ShareToken
+	}
+	return false
+}
+
+// IsSpamSuspected returns whether the spam score of the question needs the owner's review.
+func (q *Question) IsSpamSuspected() bool {
+	return conf.Spam.ReviewScore > 0 && q.SpamScore >= conf.Spam.ReviewScore
+}
+
+// AnswerReadingMinutes returns the estimated minutes to read the answer, which is rounded up.
+func (q *Question) AnswerReadingMinutes() int {
+	return (q.AnswerReadingSeconds + 59) / 60
+}
+
+// QuestionReferral is where the asker comes from, which is captured from the `ref` and the UTM
+// parameters of the box link and the referrer when the question is asked.
+type QuestionReferral struct {
+	Ref         string `json:"ref,omitempty"`
+	UTMSource   string `json:"utm_source,omitempty"`
+	UTMMedium   string `json:"utm_medium,omitempty"`
+	UTMCampaign string `json:"utm_campaign,omitempty"`
+	// Referrer is the host of the referring page, the path is not kept.
+	Referrer string `json:"referrer,omitempty"`
+}
+
+// Channel returns the channel which brings the question, the `ref` parameter takes precedence over the UTM source
+// and the referrer. It is empty if the asker opens the box link directly.
+func (r *QuestionReferral) Channel() string {
+	switch {
+	case r.Ref != "":
+		return r.Ref
+	case r.UTMSource != "":
+		return r.UTMSource
+	default:
+		return r.Referrer
+	}
 }
 
 type CreateQuestionOptions struct {
 	FromIP            string
+	FromRegion        string
+	SpamScore         int
 	UserID            uint
 	Content           string
 	ReceiveReplyEmail string
 	AskerUserID       uint
+	AskerPseudonym    string
+	PromptID          uint
+	Source            *QuestionReferral
+	// Archived moves the question to the archive right away, it is used by the owner's word filters.
+	Archived bool
+	// ShadowBanned puts the question into the shadow folder, the owner is not notified.
+	ShadowBanned bool
+
+	// ForwardedFromQuestionID is the ID of the original question if the question is forwarded from another box,
+	// ForwardedFromUserID is zero if the question is forwarded anonymously.
+	ForwardedFromQuestionID uint
+	ForwardedFromUserID     uint
 }
 
 func (db *questions) Create(ctx context.Context, opts CreateQuestionOptions) (*Question, error) {
+	if err := ValidateQuestionLength(opts.Content); err != nil {
+		return nil, err
+	}
+	if err := disposable.Check(opts.ReceiveReplyEmail); err != nil {
+		return nil, err
+	}
+
 	question := Question{
-		FromIP:            opts.FromIP,
+		FromIP:            storeIP(opts.FromIP),
+		FromRegion:        opts.FromRegion,
+		SpamScore:         opts.SpamScore,
 		UserID:            opts.UserID,
 		Token:             randstr.String(6),
 		Content:           opts.Content,
+		ContentHash:       contentHash(opts.Content),
 		ReceiveReplyEmail: opts.ReceiveReplyEmail,
 		AskerUserID:       opts.AskerUserID,
+		AskerPseudonym:    opts.AskerPseudonym,
+		PromptID:          opts.PromptID,
+
+		ForwardedFromQuestionID: opts.ForwardedFromQuestionID,
+		ForwardedFromUserID:     opts.ForwardedFromUserID,
+		Visibility:              QuestionVisibilityPublic,
+		ShadowBanned:            opts.ShadowBanned,
+	}
+	if opts.Archived {
+		now := time.Now()
+		question.ArchivedAt = &now
+	}
+	if opts.Source != nil {
+		source, err := json.Marshal(opts.Source)
+		if err != nil {
+			return nil, errors.Wrap(err, "marshal source")
+		}
+		question.Source = source
 	}
 	return &question, db.WithContext(ctx).Create(&question).Error
 }
 
+type CreateQuestionBatchOptions struct {
+	UserID    uint
+	Questions []BatchQuestion
+}
+
+// BatchQuestion is a question imported from the other Q&A box services or NekoBox instances.
+type BatchQuestion struct {
+	Content         string
+	Answer          string
+	CreatedAt       time.Time
+	AnsweredAt      *time.Time
+	AnswerUpdatedAt *time.Time
+	// Visibility defaults to public if it is empty.
+	Visibility QuestionVisibility
+	Tags       []string
+}
+
+// CreateBatch inserts the imported questions and returns the number of the inserted ones.
+// The questions which have been imported before are skipped by the hash of the content and the answer,
+// so the same export file can be imported again safely.
+func (db *questions) CreateBatch(ctx context.Context, opts CreateQuestionBatchOptions) (int, error) {
+	ctx = WithPrimary(ctx)
+
+	questions := make([]*Question, 0, len(opts.Questions))
+	hashes := make(map[string]struct{}, len(opts.Questions))
+	for _, q := range opts.Questions {
+		hash := importHash(q.Content, q.Answer)
+		if _, ok := hashes[hash]; ok {
+			continue
+		}
+		hashes[hash] = struct{}{}
+
+		visibility := q.Visibility
+		if !visibility.IsValid() {
+			visibility = QuestionVisibilityPublic
+		}
+		stats := textstat.Count(q.Answer)
+		question := &Question{
+			UserID:               opts.UserID,
+			Token:                randstr.String(6),
+			Content:              q.Content,
+			Answer:               q.Answer,
+			AnsweredAt:           q.AnsweredAt,
+			AnswerUpdatedAt:      q.AnswerUpdatedAt,
+			AnswerCharCount:      stats.Characters,
+			AnswerWordCount:      stats.Words,
+			AnswerReadingSeconds: stats.ReadingSeconds,
+			ImportHash:           hash,
+			Visibility:           visibility,
+			Tags:                 q.Tags,
+		}
+		// The time is kept in the local time zone like the ones set by GORM, so that it can be compared
+		// with the cursor of the newest sort. The import time is used if it is zero.
+		if !q.CreatedAt.IsZero() {
+			question.CreatedAt = q.CreatedAt.Local()
+		}
+		questions = append(questions, question)
+	}
+
+	// The trashed questions are also checked, the user may have deleted the imported question on purpose.
+	hashList := make([]string, 0, len(hashes))
+	for hash := range hashes {
+		hashList = append(hashList, hash)
+	}
+	for start := 0; start < len(hashList); start += importHashChunkSize {
+		end := start + importHashChunkSize
+		if end > len(hashList) {
+			end = len(hashList)
+		}
+
+		var imported []string
+		if err := db.WithContext(ctx).Unscoped().Model(&Question{}).
+			Where("user_id = ? AND import_hash IN (?)", opts.UserID, hashList[start:end]).
+			Pluck("import_hash", &imported).Error; err != nil {
+			return 0, errors.Wrap(err, "get imported hashes")
+		}
+		for _, hash := range imported {
+			delete(hashes, hash)
+		}
+	}
+
+	pending := questions[:0]
+	for _, question := range questions {
+		if _, ok := hashes[question.ImportHash]; ok {
+			pending = append(pending, question)
+		}
+	}
+	if len(pending) == 0 {
+		return 0, nil
+	}
+
+	if err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.CreateInBatches(pending, 100).Error; err != nil {
+			return errors.Wrap(err, "create questions")
+		}
+
+		var tags []*QuestionTag
+		for _, question := range pending {
+			for _, name := range question.Tags {
+				tags = append(tags, &QuestionTag{
+					UserID:     opts.UserID,
+					QuestionID: question.ID,
+					Name:       name,
+				})
+			}
+		}
+		if len(tags) == 0 {
+			return nil
+		}
+		return errors.Wrap(tx.CreateInBatches(tags, 100).Error, "create question tags")
+	}); err != nil {
+		return 0, err
+	}
+	return len(pending), nil
+}
+
+// importHashChunkSize is the number of the hashes checked in a query, which keeps the IN clause small.
+const importHashChunkSize = 500
+
+func importHash(content, answer string) string {
+	sum := sha256.Sum256([]byte(content + "\x00" + answer))
+	return hex.EncodeToString(sum[:])
+}
+
+// contentHash returns the hash of the normalized question content, the case, the width of the characters,
+// the whitespaces and the punctuations are ignored, so the near-identical questions have the same hash.
+func contentHash(content string) string {
+	normalized := strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || unicode.IsPunct(r) || unicode.IsSymbol(r) {
+			return -1
+		}
+		return unicode.ToLower(r)
+	}, norm.NFKC.String(content))
+	// The content only made up of the punctuations is compared as it is.
+	if normalized == "" {
+		normalized = content
+	}
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+type FindRecentDuplicateOptions struct {
+	// UserID is the ID of the box's owner.
+	UserID  uint
+	Content string
+	// AskerUserID is the ID of the logged asker, the anonymous asker is identified by the IP address.
+	AskerUserID uint
+	AskerIP     string
+	// CreatedAfter is the start of the time window in which the questions are checked.
+	CreatedAfter time.Time
+}
+
+// FindRecentDuplicate returns the latest question with the same normalized content sent by the same asker
+// to the same box after the given time, ErrQuestionNotExist is returned if there is no such question.
+func (db *questions) FindRecentDuplicate(ctx context.Context, opts FindRecentDuplicateOptions) (*Question, error) {
+	q := db.WithContext(ctx).
+		Where("user_id = ? AND content_hash = ? AND created_at > ?", opts.UserID, contentHash(opts.Content), opts.CreatedAfter)
+	if opts.AskerUserID != 0 {
+		q = q.Where("asker_user_id = ?", opts.AskerUserID)
+	} else if opts.AskerIP != "" {
+		// The IP address may have been hashed by the retention job.
+		q = q.Where("asker_user_id = 0 AND from_ip IN (?)", []string{storeIP(opts.AskerIP), ipHashPrefix + saltedHashIP(opts.AskerIP)})
+	} else {
+		return nil, ErrQuestionNotExist
+	}
+
+	var question Question
+	if err := q.Order("id DESC").First(&question).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrQuestionNotExist
+		}
+		return nil, errors.Wrap(err, "find recent duplicate question")
+	}
+	return &question, nil
+}
+
+type CountRecentByAskerOptions struct {
+	// UserID limits the questions to the box of the user, the questions to all the boxes are counted if it is zero.
+	UserID uint
+	// AskerUserID is the ID of the logged asker, the anonymous asker is identified by the IP address.
+	AskerUserID  uint
+	AskerIP      string
+	CreatedAfter time.Time
+}
+
+// CountRecentByAsker returns the number of the questions sent by the asker after the given time.
+func (db *questions) CountRecentByAsker(ctx context.Context, opts CountRecentByAskerOptions) (int64, error) {
+	q := db.WithContext(ctx).Model(&Question{}).Where("created_at > ?", opts.CreatedAfter)
+	if opts.UserID != 0 {
+		q = q.Where("user_id = ?", opts.UserID)
+	}
+	if opts.AskerUserID != 0 {
+		q = q.Where("asker_user_id = ?", opts.AskerUserID)
+	} else if opts.AskerIP != "" {
+		q = q.Where("asker_user_id = 0 AND from_ip IN (?)", []string{storeIP(opts.AskerIP), ipHashPrefix + saltedHashIP(opts.AskerIP)})
+	} else {
+		return 0, nil
+	}
+
+	var count int64
+	if err := q.Count(&count).Error; err != nil {
+		return 0, errors.Wrap(err, "count recent questions by asker")
+	}
+	return count, nil
+}
+
+// AskerHistory is the summary of the questions sent by a logged asker.
+type AskerHistory struct {
+	// Accepted is the number of the questions which passed the censor and are shown to the owners.
+	Accepted int64
+	// Rejected is the number of the questions which failed the censor, or were hidden by the
+	// administrators or put into the shadow folders.
+	Rejected int64
+}
+
+// GetAskerHistory returns the summary of the questions sent by the logged asker to all the boxes,
+// the questions deleted by the owners are not counted.
+func (db *questions) GetAskerHistory(ctx context.Context, askerUserID uint) (*AskerHistory, error) {
+	var history AskerHistory
+	if err := db.WithContext(ctx).Model(&Question{}).
+		Select(`COUNT(CASE WHEN content_censor_pass = TRUE AND hidden_at IS NULL AND shadow_banned = FALSE THEN 1 END) AS accepted,
+COUNT(CASE WHEN (content_censor_pass = FALSE AND content_censor_metadata IS NOT NULL) OR hidden_at IS NOT NULL OR shadow_banned = TRUE THEN 1 END) AS rejected`).
+		Where("asker_user_id = ?", askerUserID).
+		Scan(&history).Error; err != nil {
+		return nil, errors.Wrap(err, "get asker history")
+	}
+	return &history, nil
+}
+
 type UpdateQuestionCensorOptions struct {
 	ContentCensorMetadata json.RawMessage
 	AnswerCensorMetadata  json.RawMessage
 }
 
 func (db *questions) UpdateCensor(ctx context.Context, id uint, opts UpdateQuestionCensorOptions) error {
+	ctx = WithPrimary(ctx)
+
 	question, err := db.GetByID(ctx, id)
 	if err != nil {
 		return errors.Wrap(err, "get by ID")
@@ -94,12 +543,204 @@ func (db *questions) UpdateCensor(ctx context.Context, id uint, opts UpdateQuest
 		answerCensorMetadata = datatypes.JSON(opts.AnswerCensorMetadata)
 	}
 
-	return db.WithContext(ctx).Model(&Question{}).Where("id = ?", id).Updates(&Question{
-		ContentCensorMetadata: contentCensorMetadata,
-		AnswerCensorMetadata:  answerCensorMetadata,
+	// The pass columns are maintained along with the metadata, so they can be queried on all the dialects.
+	return db.WithContext(ctx).Model(&Question{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"content_censor_metadata": contentCensorMetadata,
+		"content_censor_pass":     checkTextCensorResponsePass(json.RawMessage(contentCensorMetadata)),
+		"answer_censor_metadata":  answerCensorMetadata,
+		"answer_censor_pass":      checkTextCensorResponsePass(json.RawMessage(answerCensorMetadata)),
 	}).Error
 }
 
+type ListPendingCensorOptions struct {
+	// CreatedBefore skips the questions which are just created, their censor jobs may be still in the queue.
+	CreatedBefore time.Time
+	// MaxRedriveCount skips the questions which have been re-driven this many times,
+	// so the text that keeps failing in the censor service is given up at last.
+	MaxRedriveCount int
+	Limit           int
+}
+
+// ListPendingCensor returns the questions whose content or answer has not been censored yet,
+// which is usually caused by the censor service being unavailable.
+func (db *questions) ListPendingCensor(ctx context.Context, opts ListPendingCensorOptions) ([]*Question, error) {
+	var questions []*Question
+	q := db.WithContext(ctx).Model(&Question{}).
+		Where("content_censor_metadata IS NULL OR (answer <> '' AND answer_censor_metadata IS NULL)").
+		Order("censor_redrive_count ASC, id ASC")
+	if !opts.CreatedBefore.IsZero() {
+		q = q.Where("created_at < ?", opts.CreatedBefore)
+	}
+	if opts.MaxRedriveCount > 0 {
+		q = q.Where("censor_redrive_count < ?", opts.MaxRedriveCount)
+	}
+	if opts.Limit > 0 {
+		q = q.Limit(opts.Limit)
+	}
+	if err := q.Find(&questions).Error; err != nil {
+		return nil, errors.Wrap(err, "list pending censor questions")
+	}
+	return questions, nil
+}
+
+func (db *questions) IncreaseCensorRedriveCount(ctx context.Context, ids ...uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return db.WithContext(ctx).Model(&Question{}).Where("id IN (?)", ids).
+		Update("censor_redrive_count", gorm.Expr("censor_redrive_count + 1")).Error
+}
+
+// ListUnansweredAfter returns the user's unanswered questions whose ID is greater than the given ID,
+// which is used to collect the questions for the new question digest.
+func (db *questions) ListUnansweredAfter(ctx context.Context, userID, afterID uint, limit int) ([]*Question, error) {
+	var questions []*Question
+	if err := db.WithContext(ctx).Model(&Question{}).
+		Where("user_id = ? AND id > ? AND answer = '' AND archived_at IS NULL AND "+notShadowBanned, userID, afterID).
+		Order("id ASC").
+		Limit(limit).
+		Find(&questions).Error; err != nil {
+		return nil, errors.Wrap(err, "list unanswered questions")
+	}
+	return questions, nil
+}
+
+// ArchiveUnanswered archives the user's unanswered questions which were created before the given time,
+// the archived questions are not shown in the inbox but kept, and returns the number of the archived questions.
+func (db *questions) ArchiveUnanswered(ctx context.Context, userID uint, createdBefore time.Time) (int64, error) {
+	result := db.WithContext(ctx).Model(&Question{}).
+		Where("user_id = ? AND answer = '' AND archived_at IS NULL AND created_at < ?", userID, createdBefore).
+		Update("archived_at", time.Now())
+	if result.Error != nil {
+		return 0, errors.Wrap(result.Error, "archive unanswered questions")
+	}
+	return result.RowsAffected, nil
+}
+
+// unreminded is the condition of the unanswered questions which the owner has not been reminded of.
+const unreminded = `answer = '' AND archived_at IS NULL AND reminded_at IS NULL AND ` + notShadowBanned
+
+// ListUnreminded returns the user's unanswered questions created before the given time which the owner
+// has not been reminded of, the earlier asked ones come first.
+func (db *questions) ListUnreminded(ctx context.Context, userID uint, createdBefore time.Time, limit int) ([]*Question, error) {
+	var questions []*Question
+	if err := db.WithContext(ctx).Model(&Question{}).
+		Where("user_id = ? AND created_at < ? AND "+unreminded, userID, createdBefore).
+		Order("id ASC").
+		Limit(limit).
+		Find(&questions).Error; err != nil {
+		return nil, errors.Wrap(err, "list unreminded questions")
+	}
+	return questions, nil
+}
+
+// MarkReminded marks all the user's unanswered questions created before the given time as reminded,
+// so the owner is reminded of each question only once, and returns the number of the marked questions.
+func (db *questions) MarkReminded(ctx context.Context, userID uint, createdBefore time.Time) (int64, error) {
+	result := db.WithContext(ctx).Model(&Question{}).
+		Where("user_id = ? AND created_at < ? AND "+unreminded, userID, createdBefore).
+		Update("reminded_at", time.Now())
+	if result.Error != nil {
+		return 0, errors.Wrap(result.Error, "mark questions reminded")
+	}
+	return result.RowsAffected, nil
+}
+
+// inQueue is the condition of the questions waiting in the queue of the queue mode,
+// which are the unanswered and unarchived ones.
+const inQueue = `answer = '' AND archived_at IS NULL AND ` + notShadowBanned
+
+// GetNextInQueue returns the first n questions in the user's queue, the earlier asked ones come first.
+func (db *questions) GetNextInQueue(ctx context.Context, userID uint, n int) ([]*Question, error) {
+	var questions []*Question
+	if n <= 0 {
+		return questions, nil
+	}
+	if err := db.WithContext(ctx).Model(&Question{}).
+		Where("user_id = ? AND "+inQueue, userID).
+		Order("id ASC").
+		Limit(n).
+		Find(&questions).Error; err != nil {
+		return nil, errors.Wrap(err, "get next questions in queue")
+	}
+	return questions, nil
+}
+
+type GetNextUnansweredOptions struct {
+	// AfterID returns the first unanswered question asked after the question.
+	AfterID uint
+	// BeforeID returns the last unanswered question asked before the question, it is ignored if AfterID is set.
+	BeforeID uint
+}
+
+// GetNextUnanswered returns the user's unanswered question next to the given one in the inbox, the earliest
+// unanswered question is returned if neither of them is set. It returns ErrQuestionNotExist if there is none.
+func (db *questions) GetNextUnanswered(ctx context.Context, userID uint, opts GetNextUnansweredOptions) (*Question, error) {
+	q := db.WithContext(ctx).Model(&Question{}).Where("user_id = ? AND "+inQueue, userID)
+	switch {
+	case opts.AfterID != 0:
+		q = q.Where("id > ?", opts.AfterID).Order("id ASC")
+	case opts.BeforeID != 0:
+		q = q.Where("id < ?", opts.BeforeID).Order("id DESC")
+	default:
+		q = q.Order("id ASC")
+	}
+
+	var question Question
+	if err := q.First(&question).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrQuestionNotExist
+		}
+		return nil, errors.Wrap(err, "get next unanswered question")
+	}
+	return &question, nil
+}
+
+// GetQueuePosition returns the 1-based position of the question in the user's queue,
+// it returns zero if the question is not in the queue.
+func (db *questions) GetQueuePosition(ctx context.Context, userID, questionID uint) (int64, error) {
+	var inQueueCount int64
+	if err := db.WithContext(ctx).Model(&Question{}).
+		Where("id = ? AND user_id = ? AND "+inQueue, questionID, userID).
+		Count(&inQueueCount).Error; err != nil {
+		return 0, errors.Wrap(err, "check question in queue")
+	}
+	if inQueueCount == 0 {
+		return 0, nil
+	}
+
+	var position int64
+	if err := db.WithContext(ctx).Model(&Question{}).
+		Where("user_id = ? AND id <= ? AND "+inQueue, userID, questionID).
+		Count(&position).Error; err != nil {
+		return 0, errors.Wrap(err, "count questions ahead in queue")
+	}
+	return position, nil
+}
+
+// CountInQueue returns the number of the questions waiting in the user's queue.
+func (db *questions) CountInQueue(ctx context.Context, userID uint) (int64, error) {
+	var count int64
+	if err := db.WithContext(ctx).Model(&Question{}).
+		Where("user_id = ? AND "+inQueue, userID).
+		Count(&count).Error; err != nil {
+		return 0, errors.Wrap(err, "count questions in queue")
+	}
+	return count, nil
+}
+
+// CountAnsweredSince returns the number of the questions the user answered since the given time,
+// which is used up from the daily quota of the queue mode.
+func (db *questions) CountAnsweredSince(ctx context.Context, userID uint, since time.Time) (int64, error) {
+	var count int64
+	if err := db.WithContext(ctx).Model(&Question{}).
+		Where("user_id = ? AND answered_at >= ?", userID, since).
+		Count(&count).Error; err != nil {
+		return 0, errors.Wrap(err, "count answered questions")
+	}
+	return count, nil
+}
+
 func checkTextCensorResponseValid(raw json.RawMessage) bool {
 	if len(raw) == 0 {
 		return false
@@ -118,7 +759,52 @@ func checkTextCensorResponseValid(raw json.RawMessage) bool {
 	return response.SourceName != ""
 }
 
-var ErrQuestionNotExist = errors.New("提问不存在")
+// checkTextCensorResponsePass returns the `pass` field of the censor metadata,
+// it returns false if the metadata is empty or invalid.
+func checkTextCensorResponsePass(raw json.RawMessage) bool {
+	if len(raw) == 0 {
+		return false
+	}
+
+	var response struct {
+		Pass bool `json:"pass"`
+	}
+	if err := json.Unmarshal(raw, &response); err != nil {
+		return false
+	}
+	return response.Pass
+}
+
+var (
+	ErrQuestionNotExist          = errors.New("提问不存在")
+	ErrQuestionNotAnswered       = errors.New("该提问还没有被回答")
+	ErrInvalidQuestionVisibility = errors.New("提问可见性不合法")
+	ErrQuestionTooLong           = errors.New("问题内容太长了")
+	ErrAnswerTooLong             = errors.New("回答内容太长了")
+	ErrInvalidQuestionCursor     = errors.New("分页游标不合法")
+	ErrPrivateNoteTooLong        = errors.Errorf("私密备注不能超过 %d 个字", MaxPrivateNoteLength)
+	ErrQuestionMergeSelf         = errors.New("不能将提问合并到它自己")
+	ErrQuestionMerged            = errors.New("该提问已经被合并过了")
+	ErrQuestionMergeAnswered     = errors.New("已回答的提问不能被合并")
+	ErrQuestionVersionConflict   = errors.New("该提问已经在别处被修改过了，请刷新后重试")
+)
+
+// ValidateQuestionLength checks the question or the follow-up question against the site limit,
+// the stricter limits of the owners are checked with BoxSettings.
+func ValidateQuestionLength(content string) error {
+	if utf8.RuneCountInString(content) > conf.Limits.QuestionMaxLength {
+		return ErrQuestionTooLong
+	}
+	return nil
+}
+
+// ValidateAnswerLength checks the answer against the site limit.
+func ValidateAnswerLength(answer string) error {
+	if utf8.RuneCountInString(answer) > conf.Limits.AnswerMaxLength {
+		return ErrAnswerTooLong
+	}
+	return nil
+}
 
 func (db *questions) GetByID(ctx context.Context, id uint) (*Question, error) {
 	var question Question
@@ -131,46 +817,247 @@ func (db *questions) GetByID(ctx context.Context, id uint) (*Question, error) {
 	return &question, nil
 }
 
-func (db *questions) getBy(ctx context.Context, cursor *dbutil.Cursor, whereQuery string, args ...interface{}) ([]*Question, error) {
+func (db *questions) GetByShortSlug(ctx context.Context, slug string) (*Question, error) {
+	var question Question
+	if err := db.WithContext(ctx).Where("short_slug = ?", slug).First(&question).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrQuestionNotExist
+		}
+		return nil, errors.Wrap(err, "get question by short slug")
+	}
+	return &question, nil
+}
+
+// GetByIDs returns the user's questions of the given IDs, the IDs of the other users' questions are ignored.
+func (db *questions) GetByIDs(ctx context.Context, userID uint, ids []uint) ([]*Question, error) {
+	if len(ids) == 0 {
+		return []*Question{}, nil
+	}
+
 	var questions []*Question
-	q := db.WithContext(ctx).Where(whereQuery, args...)
+	if err := db.WithContext(ctx).Where("user_id = ? AND id IN (?)", userID, ids).Order("id DESC").Find(&questions).Error; err != nil {
+		return nil, errors.Wrap(err, "get questions by IDs")
+	}
+	return questions, nil
+}
+
+// QuestionSort is the order of the questions in the owner's inbox.
+type QuestionSort string
+
+const (
+	// QuestionSortNewest lists the pinned questions first and then the newest ones, which is the default.
+	QuestionSortNewest QuestionSort = ""
+	QuestionSortOldest QuestionSort = "oldest"
+	// QuestionSortLongestUnanswered only lists the unanswered questions, the ones waiting the longest come first.
+	QuestionSortLongestUnanswered QuestionSort = "unanswered"
+	QuestionSortMostLiked         QuestionSort = "likes"
+	QuestionSortRecentlyUpdated   QuestionSort = "updated"
+)
+
+// IsValid returns whether the sort is one of the known values.
+func (s QuestionSort) IsValid() bool {
+	switch s {
+	case QuestionSortNewest, QuestionSortOldest, QuestionSortLongestUnanswered, QuestionSortMostLiked, QuestionSortRecentlyUpdated:
+		return true
+	}
+	return false
+}
+
+// order adds the ORDER BY clauses of the sort to the query, the ID breaks the ties so the cursor is stable.
+func (s QuestionSort) order(q *gorm.DB) *gorm.DB {
+	switch s {
+	case QuestionSortOldest, QuestionSortLongestUnanswered:
+		return q.Order("id ASC")
+	case QuestionSortMostLiked:
+		return q.Order("like_count DESC").Order("id DESC")
+	case QuestionSortRecentlyUpdated:
+		return q.Order("updated_at DESC").Order("id DESC")
+	}
+	// The imported questions keep their original time, so the ID is not in the order of the time.
+	return q.Order("pinned DESC").Order("pinned_at DESC").Order("created_at DESC").Order("id DESC")
+}
+
+// cursorOf returns the cursor of the next page after the question. The cursor of the sorts by a column
+// other than the ID is the value of the column along with the ID, such as "12_345".
+func (s QuestionSort) cursorOf(question *Question) interface{} {
+	switch s {
+	case QuestionSortNewest:
+		return fmt.Sprintf("%d_%d", question.CreatedAt.UnixNano(), question.ID)
+	case QuestionSortMostLiked:
+		return fmt.Sprintf("%d_%d", question.LikeCount, question.ID)
+	case QuestionSortRecentlyUpdated:
+		return fmt.Sprintf("%d_%d", question.UpdatedAt.UnixNano(), question.ID)
+	}
+	return question.ID
+}
+
+// after returns the SQL condition of the questions after the cursor.
+func (s QuestionSort) after(cursor string) (string, []interface{}, error) {
+	switch s {
+	case QuestionSortNewest:
+		// Pinned questions are always returned in the first page.
+		if !strings.Contains(cursor, "_") {
+			// The cursor issued before the time was added to it.
+			id, err := strconv.ParseUint(cursor, 10, 64)
+			if err != nil {
+				return "", nil, ErrInvalidQuestionCursor
+			}
+			return `pinned = FALSE AND id < ?`, []interface{}{id}, nil
+		}
+		key, id, err := parseQuestionCursor(cursor)
+		if err != nil {
+			return "", nil, err
+		}
+		createdAt := time.Unix(0, key)
+		return `pinned = FALSE AND (created_at < ? OR (created_at = ? AND id < ?))`, []interface{}{createdAt, createdAt, id}, nil
+	case QuestionSortOldest, QuestionSortLongestUnanswered:
+		id, err := strconv.ParseUint(cursor, 10, 64)
+		if err != nil {
+			return "", nil, ErrInvalidQuestionCursor
+		}
+		return `id > ?`, []interface{}{id}, nil
+	case QuestionSortMostLiked, QuestionSortRecentlyUpdated:
+		key, id, err := parseQuestionCursor(cursor)
+		if err != nil {
+			return "", nil, err
+		}
+		if s == QuestionSortMostLiked {
+			return `(like_count < ? OR (like_count = ? AND id < ?))`, []interface{}{key, key, id}, nil
+		}
+		updatedAt := time.Unix(0, key)
+		return `(updated_at < ? OR (updated_at = ? AND id < ?))`, []interface{}{updatedAt, updatedAt, id}, nil
+	}
+	return "", nil, errors.Errorf("unexpected question sort: %q", s)
+}
+
+// parseQuestionCursor parses the cursor in the form of "<key>_<id>".
+func parseQuestionCursor(cursor string) (key int64, id uint64, err error) {
+	rawKey, rawID, ok := strings.Cut(cursor, "_")
+	if !ok {
+		return 0, 0, ErrInvalidQuestionCursor
+	}
+	key, err = strconv.ParseInt(rawKey, 10, 64)
+	if err != nil {
+		return 0, 0, ErrInvalidQuestionCursor
+	}
+	id, err = strconv.ParseUint(rawID, 10, 64)
+	if err != nil {
+		return 0, 0, ErrInvalidQuestionCursor
+	}
+	return key, id, nil
+}
+
+func (db *questions) getBy(ctx context.Context, cursor *dbutil.Cursor, sort QuestionSort, whereQuery string, args ...interface{}) ([]*Question, *dbutil.PageInfo, error) {
+	if sort == QuestionSortLongestUnanswered {
+		whereQuery = `(` + whereQuery + `) AND answer = ''`
+	}
+	q := db.WithContext(ctx).Model(&Question{}).Where(whereQuery, args...).Session(&gorm.Session{})
+
+	// The total of the public profile page is cached along with the first page by cachedQuestions.
+	var total int64
+	if cursor != nil && cursor.WithTotal {
+		if err := q.Count(&total).Error; err != nil {
+			return nil, nil, errors.Wrap(err, "count questions")
+		}
+	}
 
+	var limit int
 	if cursor != nil {
+		limit = cursor.Limit()
+
 		cursorID := cursor.Value
 		if cursorID != nil && fmt.Sprintf("%v", cursorID) != "" {
-			// For we ordered by ID DESC, so we need to use `>` instead of `<`.
-			q = q.Where(`id < ?`, cursorID)
+			cond, condArgs, err := sort.after(fmt.Sprintf("%v", cursorID))
+			if err != nil {
+				return nil, nil, err
+			}
+			q = q.Where(cond, condArgs...)
+		} else if sort == QuestionSortNewest && limit <= MaxPinnedQuestions {
+			// Make sure the last question of the first page is not a pinned one,
+			// otherwise the next page will lose the questions created after it.
+			limit = MaxPinnedQuestions + 1
 		}
-
-		limit := cursor.Limit()
-		q = q.Limit(limit)
 	}
 
-	q = q.Order("created_at DESC")
-	if err := q.Find(&questions).Error; err != nil {
-		return nil, errors.Wrap(err, "get questions by page ID")
+	questions, pageInfo, err := dbutil.Paginate(sort.order(q), limit, sort.cursorOf)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "get questions by page ID")
 	}
-	return questions, nil
+	pageInfo.Total = total
+	return questions, pageInfo, nil
 }
 
 type GetQuestionsByUserIDOptions struct {
 	*dbutil.Cursor
+	// FilterAnswered only returns the public answered questions, which are listed on the box page.
 	FilterAnswered bool
+	// FilterTag only returns the questions tagged with the given tag name.
+	FilterTag string
+	// FilterUnread only returns the questions which the owner has not read.
+	FilterUnread bool
+	// FilterArchived filters the questions by whether they have been archived.
+	FilterArchived ArchivedFilter
+	// ShadowBanned only returns the questions in the shadow folder, which are excluded otherwise.
+	ShadowBanned bool
+	// Sort is the order of the questions, the pinned questions only come first in the default order.
+	Sort QuestionSort
+}
+
+// shadowBannedWhere returns the SQL condition of the questions in or out of the shadow folder.
+func shadowBannedWhere(shadowBanned bool) string {
+	if shadowBanned {
+		return `shadow_banned = TRUE`
+	}
+	return notShadowBanned
+}
+
+// ArchivedFilter filters the questions by whether they have been archived.
+type ArchivedFilter int
+
+const (
+	// ArchivedFilterAll returns both the archived and the not archived questions.
+	ArchivedFilterAll ArchivedFilter = iota
+	// ArchivedFilterExclude skips the archived questions, which is what the inbox shows.
+	ArchivedFilterExclude
+	// ArchivedFilterOnly only returns the archived questions.
+	ArchivedFilterOnly
+)
+
+// where returns the SQL condition of the filter, which is empty for ArchivedFilterAll.
+func (f ArchivedFilter) where() string {
+	switch f {
+	case ArchivedFilterExclude:
+		return `archived_at IS NULL`
+	case ArchivedFilterOnly:
+		return `archived_at IS NOT NULL`
+	}
+	return ""
 }
 
-func (db *questions) GetByUserID(ctx context.Context, userID uint, opts GetQuestionsByUserIDOptions) ([]*Question, error) {
+func (db *questions) GetByUserID(ctx context.Context, userID uint, opts GetQuestionsByUserIDOptions) ([]*Question, *dbutil.PageInfo, error) {
 	where := `user_id = ?`
-	args := userID
+	args := []interface{}{userID}
 
 	if opts.FilterAnswered {
-		where = `user_id = ? AND answer <> ""`
+		where += ` AND ` + publiclyAnswered
 	}
+	if opts.FilterTag != "" {
+		where += ` AND id IN (SELECT question_id FROM question_tags WHERE user_id = ? AND name = ? AND deleted_at IS NULL)`
+		args = append(args, userID, opts.FilterTag)
+	}
+	if opts.FilterUnread {
+		where += ` AND read_at IS NULL`
+	}
+	if cond := opts.FilterArchived.where(); cond != "" {
+		where += ` AND ` + cond
+	}
+	where += ` AND ` + shadowBannedWhere(opts.ShadowBanned)
 
-	questions, err := db.getBy(ctx, opts.Cursor, where, args)
+	questions, pageInfo, err := db.getBy(ctx, opts.Cursor, opts.Sort, where, args...)
 	if err != nil {
-		return nil, errors.Wrap(err, "get by")
+		return nil, nil, errors.Wrap(err, "get by")
 	}
-	return questions, nil
+	return questions, pageInfo, nil
 }
 
 type GetQuestionsByAskUserIDOptions struct {
@@ -178,38 +1065,356 @@ type GetQuestionsByAskUserIDOptions struct {
 	FilterAnswered bool
 }
 
-func (db *questions) GetByAskUserID(ctx context.Context, userID uint, opts GetQuestionsByAskUserIDOptions) ([]*Question, error) {
+func (db *questions) GetByAskUserID(ctx context.Context, userID uint, opts GetQuestionsByAskUserIDOptions) ([]*Question, *dbutil.PageInfo, error) {
 	where := `asker_user_id = ?`
 	args := userID
 
 	if opts.FilterAnswered {
-		where = `asker_user_id = ? AND answer <> ""`
+		where = `asker_user_id = ? AND answer <> ''`
 	}
 
-	questions, err := db.getBy(ctx, opts.Cursor, where, args)
+	questions, pageInfo, err := db.getBy(ctx, opts.Cursor, QuestionSortNewest, where, args)
 	if err != nil {
-		return nil, errors.Wrap(err, "get by")
+		return nil, nil, errors.Wrap(err, "get by")
 	}
-	return questions, nil
+	return questions, pageInfo, nil
 }
 
-func (db *questions) AnswerByID(ctx context.Context, id uint, answer string) error {
-	var question Question
-	if err := db.WithContext(ctx).First(&question, id).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return ErrQuestionNotExist
-		}
-		return errors.Wrap(err, "get question by ID")
-	}
+// questionsIterateBatchSize is the number of questions loaded into memory at once when iterating.
+const questionsIterateBatchSize = 100
 
-	if err := db.WithContext(ctx).Model(&question).Where("id = ?", id).Update("answer", answer).Error; err != nil {
-		return errors.Wrap(err, "update question answer")
-	}
-	return nil
+// IterateByUserID calls fn with every question received by the given user in the creation order,
+// the questions are loaded in batches so that the huge box does not eat up the memory.
+func (db *questions) IterateByUserID(ctx context.Context, userID uint, fn func(*Question) error) error {
+	return db.iterate(ctx, fn, `user_id = ?`, userID)
 }
 
-func (db *questions) DeleteByID(ctx context.Context, id uint) error {
-	var question Question
+// IterateByAskUserID calls fn with every question asked by the given user in the creation order.
+func (db *questions) IterateByAskUserID(ctx context.Context, userID uint, fn func(*Question) error) error {
+	return db.iterate(ctx, fn, `asker_user_id = ?`, userID)
+}
+
+// IterateAnswered calls fn with every public answered question of all the users in the creation order.
+func (db *questions) IterateAnswered(ctx context.Context, fn func(*Question) error) error {
+	return db.iterate(ctx, fn, publiclyAnswered)
+}
+
+// IterateAnsweredByUserID calls fn with every public answered question of the given user in the creation order.
+func (db *questions) IterateAnsweredByUserID(ctx context.Context, userID uint, fn func(*Question) error) error {
+	return db.iterate(ctx, fn, `user_id = ? AND `+publiclyAnswered, userID)
+}
+
+type QuestionCensorStatus string
+
+const (
+	// QuestionCensorStatusPassed questions have passed the censor of both the content and the answer.
+	QuestionCensorStatusPassed QuestionCensorStatus = "passed"
+	// QuestionCensorStatusFailed questions have failed the censor of either the content or the answer.
+	QuestionCensorStatusFailed QuestionCensorStatus = "failed"
+	// QuestionCensorStatusPending questions have not been censored yet.
+	QuestionCensorStatusPending QuestionCensorStatus = "pending"
+)
+
+// IsValid returns whether the status is one of the known values.
+func (s QuestionCensorStatus) IsValid() bool {
+	switch s {
+	case QuestionCensorStatusPassed, QuestionCensorStatusFailed, QuestionCensorStatusPending:
+		return true
+	}
+	return false
+}
+
+func (s QuestionCensorStatus) where() string {
+	switch s {
+	case QuestionCensorStatusPassed:
+		return `content_censor_pass = TRUE AND (answer = '' OR answer_censor_pass = TRUE)`
+	case QuestionCensorStatusFailed:
+		return `(content_censor_metadata IS NOT NULL AND content_censor_pass = FALSE) OR (answer <> '' AND answer_censor_metadata IS NOT NULL AND answer_censor_pass = FALSE)`
+	case QuestionCensorStatusPending:
+		return `content_censor_metadata IS NULL OR (answer <> '' AND answer_censor_metadata IS NULL)`
+	}
+	return ""
+}
+
+type IterateFilteredQuestionsOptions struct {
+	// CreatedAfter and CreatedBefore are ignored if they are zero.
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	UserID        uint
+	CensorStatus  QuestionCensorStatus
+	// MinReports is the minimum number of the reports of any status, it is ignored if it is zero.
+	MinReports int
+}
+
+// IterateFiltered calls fn with the batches of the questions matching the filters of all the users
+// in the creation order. The batches are paged by the ID instead of the offset, so the later batches
+// of the huge result are as fast as the first one.
+func (db *questions) IterateFiltered(ctx context.Context, opts IterateFilteredQuestionsOptions, fn func([]*Question) error) error {
+	q := db.WithContext(ctx).Model(&Question{})
+	if !opts.CreatedAfter.IsZero() {
+		q = q.Where("created_at >= ?", opts.CreatedAfter)
+	}
+	if !opts.CreatedBefore.IsZero() {
+		q = q.Where("created_at < ?", opts.CreatedBefore)
+	}
+	if opts.UserID != 0 {
+		q = q.Where("user_id = ?", opts.UserID)
+	}
+	if cond := opts.CensorStatus.where(); cond != "" {
+		q = q.Where(cond)
+	}
+	if opts.MinReports > 0 {
+		q = q.Where("id IN (?)", db.WithContext(ctx).Model(&Report{}).
+			Select("question_id").
+			Group("question_id").
+			Having("COUNT(*) >= ?", opts.MinReports))
+	}
+
+	var lastID uint
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var questions []*Question
+		if err := q.Session(&gorm.Session{}).
+			Where("id > ?", lastID).
+			Order("id ASC").
+			Limit(questionsIterateBatchSize).
+			Find(&questions).Error; err != nil {
+			return errors.Wrap(err, "find questions")
+		}
+		if len(questions) == 0 {
+			return nil
+		}
+
+		if err := fn(questions); err != nil {
+			return err
+		}
+		if len(questions) < questionsIterateBatchSize {
+			return nil
+		}
+		lastID = questions[len(questions)-1].ID
+	}
+}
+
+func (db *questions) iterate(ctx context.Context, fn func(*Question) error, whereQuery string, args ...interface{}) error {
+	var questions []*Question
+	result := db.WithContext(ctx).Where(whereQuery, args...).FindInBatches(&questions, questionsIterateBatchSize, func(tx *gorm.DB, batch int) error {
+		for _, question := range questions {
+			if err := fn(question); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if result.Error != nil {
+		return errors.Wrap(result.Error, "find in batches")
+	}
+	return nil
+}
+
+// Search returns the public answered questions of the given user whose content or answer
+// matches the keyword. The MySQL full-text index is used when it is available.
+func (db *questions) Search(ctx context.Context, userID uint, keyword string, cursor *dbutil.Cursor) ([]*Question, *dbutil.PageInfo, error) {
+	where := `user_id = ? AND ` + publiclyAnswered + ` AND (content LIKE ? ESCAPE '!' OR answer LIKE ? ESCAPE '!')`
+	args := []interface{}{userID}
+
+	if db.Dialector.Name() == "mysql" {
+		where = `user_id = ? AND ` + publiclyAnswered + ` AND MATCH (content, answer) AGAINST (?)`
+		args = append(args, keyword)
+	} else {
+		pattern := "%" + escapeLikePattern(keyword) + "%"
+		args = append(args, pattern, pattern)
+	}
+
+	questions, pageInfo, err := db.getBy(ctx, cursor, QuestionSortNewest, where, args...)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "get by")
+	}
+	return questions, pageInfo, nil
+}
+
+// HotQuestionsLimit is the max number of the hot questions returned.
+const HotQuestionsLimit = 20
+
+// GetHot returns the public answered questions of the given user which have been liked,
+// ordered by the like count.
+func (db *questions) GetHot(ctx context.Context, userID uint) ([]*Question, error) {
+	var questions []*Question
+	if err := db.WithContext(ctx).
+		Where(`user_id = ? AND `+publiclyAnswered+` AND like_count > 0`, userID).
+		Order("like_count DESC").Order("created_at DESC").
+		Limit(HotQuestionsLimit).
+		Find(&questions).Error; err != nil {
+		return nil, errors.Wrap(err, "get hot questions")
+	}
+	return questions, nil
+}
+
+// LongestQuestionsLimit is the max number of the questions with the longest answers returned.
+const LongestQuestionsLimit = 20
+
+// GetLongest returns the public answered questions of the given user ordered by the length of the answers.
+func (db *questions) GetLongest(ctx context.Context, userID uint) ([]*Question, error) {
+	var questions []*Question
+	if err := db.WithContext(ctx).
+		Where(`user_id = ? AND `+publiclyAnswered, userID).
+		Order("answer_char_count DESC").Order("created_at DESC").
+		Limit(LongestQuestionsLimit).
+		Find(&questions).Error; err != nil {
+		return nil, errors.Wrap(err, "get longest questions")
+	}
+	return questions, nil
+}
+
+// GetRandomAnswered returns a random public answered question of the given user, it returns ErrQuestionNotExist
+// if the user hasn't answered any question publicly.
+func (db *questions) GetRandomAnswered(ctx context.Context, userID uint) (*Question, error) {
+	var count int64
+	if err := db.WithContext(ctx).Model(&Question{}).Where(`user_id = ? AND `+publiclyAnswered, userID).Count(&count).Error; err != nil {
+		return nil, errors.Wrap(err, "count answered questions")
+	}
+	if count == 0 {
+		return nil, ErrQuestionNotExist
+	}
+
+	// The random offset is picked here, the random functions differ among the databases.
+	var question Question
+	if err := db.WithContext(ctx).
+		Where(`user_id = ? AND `+publiclyAnswered, userID).
+		Order("id ASC").
+		Offset(rand.Intn(int(count))).
+		First(&question).Error; err != nil {
+		// The question may have been deleted after it is counted.
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrQuestionNotExist
+		}
+		return nil, errors.Wrap(err, "get random answered question")
+	}
+	return &question, nil
+}
+
+// escapeLikePattern escapes the wildcard characters of the LIKE pattern with `!`.
+func escapeLikePattern(pattern string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(pattern)
+}
+
+func (db *questions) AnswerByID(ctx context.Context, id uint, answer string, answerUserID uint) error {
+	return db.answerByID(ctx, id, nil, answer, answerUserID)
+}
+
+// AnswerByIDIfVersion answers the question only if its version is still the given one, otherwise it returns
+// ErrQuestionVersionConflict. It keeps the answer from being written twice by the clients opened at the same time.
+func (db *questions) AnswerByIDIfVersion(ctx context.Context, id, version uint, answer string, answerUserID uint) error {
+	return db.answerByID(ctx, id, &version, answer, answerUserID)
+}
+
+// answerByID answers the question, the version is checked unless it is nil.
+func (db *questions) answerByID(ctx context.Context, id uint, version *uint, answer string, answerUserID uint) error {
+	if err := ValidateAnswerLength(answer); err != nil {
+		return err
+	}
+	ctx = WithPrimary(ctx)
+
+	var question Question
+	if err := db.WithContext(ctx).First(&question, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrQuestionNotExist
+		}
+		return errors.Wrap(err, "get question by ID")
+	}
+	if version != nil && question.Version != *version {
+		return ErrQuestionVersionConflict
+	}
+
+	stats := textstat.Count(answer)
+	updates := map[string]interface{}{
+		"answer":                 answer,
+		"answer_user_id":         answerUserID,
+		"answer_char_count":      stats.Characters,
+		"answer_word_count":      stats.Words,
+		"answer_reading_seconds": stats.ReadingSeconds,
+		"version":                gorm.Expr("version + 1"),
+		// The new answer is given a fresh chance to be re-driven to the censor queue.
+		"censor_redrive_count": 0,
+	}
+	// The answered time is used to calculate the response time in the statistics.
+	if question.Answer == "" {
+		updates["answered_at"] = time.Now()
+	}
+	// The archived question is brought back once it is answered, so is the question in the shadow folder.
+	if question.ArchivedAt != nil {
+		updates["archived_at"] = nil
+	}
+	if question.ShadowBanned {
+		updates["shadow_banned"] = false
+	}
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := saveAnswerRevision(tx, &question, answer); err != nil {
+			return err
+		}
+		q := tx.Model(&question).Where("id = ?", id)
+		if version != nil {
+			// The question may be answered by another request after it is loaded.
+			q = q.Where("version = ?", *version)
+		}
+		result := q.Updates(updates)
+		if result.Error != nil {
+			return errors.Wrap(result.Error, "update question answer")
+		}
+		if result.RowsAffected == 0 {
+			return ErrQuestionVersionConflict
+		}
+		return nil
+	})
+}
+
+// UpdateAnswerByID updates the answer of the answered question, the answer is attributed to the last editor.
+// The previous answer is kept as a revision.
+func (db *questions) UpdateAnswerByID(ctx context.Context, id uint, answer string, answerUserID uint) error {
+	if err := ValidateAnswerLength(answer); err != nil {
+		return err
+	}
+	ctx = WithPrimary(ctx)
+
+	question, err := db.GetByID(ctx, id)
+	if err != nil {
+		return errors.Wrap(err, "get question by ID")
+	}
+
+	if question.Answer == "" {
+		return ErrQuestionNotAnswered
+	}
+
+	stats := textstat.Count(answer)
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := saveAnswerRevision(tx, question, answer); err != nil {
+			return err
+		}
+		if err := tx.Model(&Question{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"answer":                 answer,
+			"answer_user_id":         answerUserID,
+			"answer_updated_at":      time.Now(),
+			"answer_char_count":      stats.Characters,
+			"answer_word_count":      stats.Words,
+			"answer_reading_seconds": stats.ReadingSeconds,
+			"version":                gorm.Expr("version + 1"),
+			// The new answer needs to be censored again.
+			"answer_censor_metadata": nil,
+			"answer_censor_pass":     false,
+			"censor_redrive_count":   0,
+		}).Error; err != nil {
+			return errors.Wrap(err, "update question answer")
+		}
+		return nil
+	})
+}
+
+func (db *questions) DeleteByID(ctx context.Context, id uint) error {
+	ctx = WithPrimary(ctx)
+
+	var question Question
 	if err := db.WithContext(ctx).First(&question, id).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return ErrQuestionNotExist
@@ -223,18 +1428,496 @@ func (db *questions) DeleteByID(ctx context.Context, id uint) error {
 	return nil
 }
 
+// DeleteByIDs moves the user's questions of the given IDs to the trash and returns the number of
+// the deleted questions. The ownership is checked in the same query, the IDs of the other users'
+// questions are ignored.
+func (db *questions) DeleteByIDs(ctx context.Context, userID uint, ids []uint) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	result := db.WithContext(ctx).Where("user_id = ? AND id IN (?)", userID, ids).Delete(&Question{})
+	if result.Error != nil {
+		return 0, errors.Wrap(result.Error, "delete questions")
+	}
+	return result.RowsAffected, nil
+}
+
+func (db *questions) MarkReadByID(ctx context.Context, id uint) error {
+	if err := db.WithContext(ctx).Model(&Question{}).Where("id = ? AND read_at IS NULL", id).Update("read_at", time.Now()).Error; err != nil {
+		return errors.Wrap(err, "mark question as read")
+	}
+	return nil
+}
+
+// MaxPrivateNoteLength is the maximum length of the private note of the question.
+const MaxPrivateNoteLength = 1000
+
+// UpdatePrivateNote sets the private note of the question, the empty note removes it. The note is only
+// shown to the box's owner and the members, it's never rendered on the public pages or in the API.
+func (db *questions) UpdatePrivateNote(ctx context.Context, id uint, note string) error {
+	if utf8.RuneCountInString(note) > MaxPrivateNoteLength {
+		return ErrPrivateNoteTooLong
+	}
+
+	if err := db.WithContext(ctx).Model(&Question{}).Where("id = ?", id).Update("private_note", note).Error; err != nil {
+		return errors.Wrap(err, "update private note")
+	}
+	return nil
+}
+
+// UpdateAnswerAudio attaches the voice note to the answer of the question, the empty URL removes it.
+// The duration is in seconds, it is 0 if the length of the voice note is unknown.
+func (db *questions) UpdateAnswerAudio(ctx context.Context, id uint, audioURL string, duration int) error {
+	result := db.WithContext(ctx).Model(&Question{}).Where("id = ? AND answer <> ''", id).Updates(map[string]interface{}{
+		"answer_audio_url":      audioURL,
+		"answer_audio_duration": duration,
+	})
+	if result.Error != nil {
+		return errors.Wrap(result.Error, "update answer audio")
+	}
+	if result.RowsAffected == 0 {
+		return ErrQuestionNotAnswered
+	}
+	return nil
+}
+
+// GetAnswerAudioURLs returns the URLs of the voice notes of the user's answers including the trashed ones,
+// so the uploaded files can be removed along with the account.
+func (db *questions) GetAnswerAudioURLs(ctx context.Context, userID uint) ([]string, error) {
+	var audioURLs []string
+	if err := db.WithContext(ctx).Unscoped().Model(&Question{}).
+		Where("user_id = ? AND answer_audio_url <> ''", userID).
+		Pluck("answer_audio_url", &audioURLs).Error; err != nil {
+		return nil, errors.Wrap(err, "get answer audio URLs")
+	}
+	return audioURLs, nil
+}
+
+// Merge merges the duplicate question into the canonical question of the same box and returns the canonical
+// question. The merged question is archived and marked as read, so it leaves the inbox and its asker is notified
+// along with the canonical question's answer. If the canonical question has been merged itself, the question is
+// merged into the question it points to, and the questions merged into the duplicate are moved along with it.
+func (db *questions) Merge(ctx context.Context, userID, id, canonicalID uint) (*Question, error) {
+	var canonical Question
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND user_id = ?", canonicalID, userID).First(&canonical).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrQuestionNotExist
+			}
+			return errors.Wrap(err, "get canonical question")
+		}
+		if canonical.MergedIntoID != 0 {
+			var mergedInto Question
+			if err := tx.Where("id = ? AND user_id = ?", canonical.MergedIntoID, userID).First(&mergedInto).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return ErrQuestionNotExist
+				}
+				return errors.Wrap(err, "get canonical question")
+			}
+			canonical = mergedInto
+		}
+		if canonical.ID == id {
+			return ErrQuestionMergeSelf
+		}
+
+		var question Question
+		if err := tx.Where("id = ? AND user_id = ?", id, userID).First(&question).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrQuestionNotExist
+			}
+			return errors.Wrap(err, "get question")
+		}
+		if question.MergedIntoID != 0 {
+			return ErrQuestionMerged
+		}
+		if question.Answer != "" {
+			return ErrQuestionMergeAnswered
+		}
+
+		now := time.Now()
+		if err := tx.Model(&Question{}).Where("id = ?", question.ID).Updates(map[string]interface{}{
+			"merged_into_id": canonical.ID,
+			"archived_at":    gorm.Expr("COALESCE(archived_at, ?)", now),
+			"read_at":        gorm.Expr("COALESCE(read_at, ?)", now),
+		}).Error; err != nil {
+			return errors.Wrap(err, "merge question")
+		}
+		if err := tx.Model(&Question{}).Where("merged_into_id = ?", question.ID).Update("merged_into_id", canonical.ID).Error; err != nil {
+			return errors.Wrap(err, "move merged questions")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &canonical, nil
+}
+
+// GetMerged returns the questions merged into the canonical question in the creation order.
+func (db *questions) GetMerged(ctx context.Context, canonicalID uint) ([]*Question, error) {
+	var questions []*Question
+	if err := db.WithContext(ctx).Where("merged_into_id = ?", canonicalID).Order("id ASC").Find(&questions).Error; err != nil {
+		return nil, errors.Wrap(err, "get merged questions")
+	}
+	return questions, nil
+}
+
+// MarkReadByIDs marks the user's unread questions of the given IDs as read and returns the number of the marked questions.
+func (db *questions) MarkReadByIDs(ctx context.Context, userID uint, ids []uint) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	result := db.WithContext(ctx).Model(&Question{}).
+		Where("user_id = ? AND id IN (?) AND read_at IS NULL", userID, ids).
+		Update("read_at", time.Now())
+	if result.Error != nil {
+		return 0, errors.Wrap(result.Error, "mark questions as read")
+	}
+	return result.RowsAffected, nil
+}
+
+// MarkAllRead marks all the user's unread questions as read and returns the number of the marked questions.
+func (db *questions) MarkAllRead(ctx context.Context, userID uint) (int64, error) {
+	result := db.WithContext(ctx).Model(&Question{}).Where("user_id = ? AND read_at IS NULL", userID).Update("read_at", time.Now())
+	if result.Error != nil {
+		return 0, errors.Wrap(result.Error, "mark all questions as read")
+	}
+	return result.RowsAffected, nil
+}
+
+// QuestionTrashRetention is the duration the deleted questions are kept in the trash.
+const QuestionTrashRetention = 30 * 24 * time.Hour
+
+func (db *questions) GetTrashedByID(ctx context.Context, id uint) (*Question, error) {
+	var question Question
+	if err := db.WithContext(ctx).Unscoped().Where("id = ? AND deleted_at IS NOT NULL", id).First(&question).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrQuestionNotExist
+		}
+		return nil, errors.Wrap(err, "get trashed question by ID")
+	}
+	return &question, nil
+}
+
+func (db *questions) GetTrashedByUserID(ctx context.Context, userID uint) ([]*Question, error) {
+	var questions []*Question
+	if err := db.WithContext(ctx).Unscoped().
+		Where("user_id = ? AND deleted_at IS NOT NULL", userID).
+		Order("deleted_at DESC").
+		Find(&questions).Error; err != nil {
+		return nil, errors.Wrap(err, "get trashed questions by user ID")
+	}
+	return questions, nil
+}
+
+// Restore restores the deleted question from the trash.
+// The restored question is no longer pinned.
+func (db *questions) Restore(ctx context.Context, id uint) error {
+	ctx = WithPrimary(ctx)
+
+	if _, err := db.GetTrashedByID(ctx, id); err != nil {
+		return errors.Wrap(err, "get trashed question by ID")
+	}
+
+	// The question may have been purged since it is loaded.
+	result := db.WithContext(ctx).Unscoped().Model(&Question{}).Where("id = ? AND deleted_at IS NOT NULL", id).Updates(map[string]interface{}{
+		"deleted_at": nil,
+		"pinned":     false,
+		"pinned_at":  nil,
+	})
+	if result.Error != nil {
+		return errors.Wrap(result.Error, "restore question")
+	}
+	if result.RowsAffected == 0 {
+		return ErrQuestionNotExist
+	}
+	return nil
+}
+
+// PurgeTrashed permanently deletes the questions which were deleted before the given time,
+// along with everything attached to them. It returns the number of the purged questions and
+// the URLs of the voice notes of their answers, which are no longer referenced.
+func (db *questions) PurgeTrashed(ctx context.Context, deletedBefore time.Time) (int64, []string, error) {
+	var purged []*Question
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// The selected questions are locked, so they can't be restored until they are purged.
+		if err := tx.Unscoped().Model(&Question{}).Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "answer_audio_url").
+			Where("deleted_at IS NOT NULL AND deleted_at < ?", deletedBefore).
+			Find(&purged).Error; err != nil {
+			return errors.Wrap(err, "get trashed questions")
+		}
+		if len(purged) == 0 {
+			return nil
+		}
+
+		ids := make([]uint, 0, len(purged))
+		for _, question := range purged {
+			ids = append(ids, question.ID)
+		}
+		return deleteQuestions(tx, ids)
+	})
+	if err != nil {
+		return 0, nil, errors.Wrap(err, "purge trashed questions")
+	}
+
+	var audioURLs []string
+	for _, question := range purged {
+		if question.AnswerAudioURL != "" {
+			audioURLs = append(audioURLs, question.AnswerAudioURL)
+		}
+	}
+	return int64(len(purged)), audioURLs, nil
+}
+
+// deleteQuestions permanently deletes the questions with their replies, reactions, tags, translations,
+// answer revisions and reports in the transaction.
+func deleteQuestions(tx *gorm.DB, ids []uint) error {
+	if err := tx.Unscoped().Where("question_id IN (?)", ids).Delete(&QuestionReply{}).Error; err != nil {
+		return errors.Wrap(err, "delete question replies")
+	}
+	if err := tx.Unscoped().Where("question_id IN (?)", ids).Delete(&QuestionReaction{}).Error; err != nil {
+		return errors.Wrap(err, "delete question reactions")
+	}
+	if err := tx.Unscoped().Where("question_id IN (?)", ids).Delete(&QuestionTag{}).Error; err != nil {
+		return errors.Wrap(err, "delete question tags")
+	}
+	if err := tx.Unscoped().Where("question_id IN (?)", ids).Delete(&QuestionTranslation{}).Error; err != nil {
+		return errors.Wrap(err, "delete question translations")
+	}
+	if err := tx.Unscoped().Where("question_id IN (?)", ids).Delete(&AnswerRevision{}).Error; err != nil {
+		return errors.Wrap(err, "delete answer revisions")
+	}
+	if err := tx.Unscoped().Where("question_id IN (?)", ids).Delete(&Report{}).Error; err != nil {
+		return errors.Wrap(err, "delete reports")
+	}
+	if err := tx.Unscoped().Where("id IN (?)", ids).Delete(&Question{}).Error; err != nil {
+		return errors.Wrap(err, "delete questions")
+	}
+	return nil
+}
+
+// ExpireIPs hashes or clears the raw IP addresses of the askers which are expired.
+// It returns the number of the updated questions.
+func (db *questions) ExpireIPs(ctx context.Context, opts ExpireIPsOptions) (int64, error) {
+	return expireIPs(ctx, db.DB, &Question{}, opts)
+}
+
+// MaxPinnedQuestions is the max number of pinned questions of a user.
+const MaxPinnedQuestions = 3
+
+var ErrTooManyPinnedQuestions = errors.New("最多只能置顶 3 个提问")
+
+func (db *questions) PinByID(ctx context.Context, id uint) error {
+	ctx = WithPrimary(ctx)
+
+	question, err := db.GetByID(ctx, id)
+	if err != nil {
+		return errors.Wrap(err, "get question by ID")
+	}
+
+	if question.Pinned {
+		return nil
+	}
+	if question.Answer == "" {
+		return ErrQuestionNotAnswered
+	}
+
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// The owner is locked, so the concurrent pins of the same box are counted one by one
+		// and can't exceed the limit together.
+		var owner User
+		if err := tx.Model(&User{}).Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id").Where("id = ?", question.UserID).
+			First(&owner).Error; err != nil {
+			return errors.Wrap(err, "lock user")
+		}
+
+		var pinnedCount int64
+		if err := tx.Model(&Question{}).Where("user_id = ? AND pinned = TRUE AND id <> ?", question.UserID, id).Count(&pinnedCount).Error; err != nil {
+			return errors.Wrap(err, "count pinned questions")
+		}
+		if pinnedCount >= MaxPinnedQuestions {
+			return ErrTooManyPinnedQuestions
+		}
+
+		if err := tx.Model(&Question{}).Where("id = ? AND pinned = FALSE", id).Updates(map[string]interface{}{
+			"pinned":    true,
+			"pinned_at": time.Now(),
+		}).Error; err != nil {
+			return errors.Wrap(err, "pin question")
+		}
+		return nil
+	})
+}
+
+func (db *questions) UnpinByID(ctx context.Context, id uint) error {
+	ctx = WithPrimary(ctx)
+
+	if _, err := db.GetByID(ctx, id); err != nil {
+		return errors.Wrap(err, "get question by ID")
+	}
+
+	if err := db.WithContext(ctx).Model(&Question{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"pinned":    false,
+		"pinned_at": nil,
+	}).Error; err != nil {
+		return errors.Wrap(err, "unpin question")
+	}
+	return nil
+}
+
 type GetQuestionsCountOptions struct {
+	// FilterAnswered only counts the public answered questions, which are listed on the box page.
 	FilterAnswered bool
+	FilterUnread   bool
+	FilterArchived ArchivedFilter
+	// ShadowBanned only counts the questions in the shadow folder, which are excluded otherwise.
+	ShadowBanned bool
 }
 
 func (db *questions) Count(ctx context.Context, userID uint, opts GetQuestionsCountOptions) (int64, error) {
 	q := db.WithContext(ctx).Model(&Question{})
 	if opts.FilterAnswered {
-		q = q.Where(`user_id = ? AND answer <> ""`, userID)
+		q = q.Where(`user_id = ? AND `+publiclyAnswered, userID)
 	} else {
 		q = q.Where(`user_id = ?`, userID)
 	}
+	if opts.FilterUnread {
+		q = q.Where(`read_at IS NULL`)
+	}
+	if cond := opts.FilterArchived.where(); cond != "" {
+		q = q.Where(cond)
+	}
+	q = q.Where(shadowBannedWhere(opts.ShadowBanned))
 
 	var count int64
 	return count, q.Count(&count).Error
 }
+
+// CountBatch counts the questions of the users with a single query, keyed by the user ID.
+// The users without any question are counted as zero.
+func (db *questions) CountBatch(ctx context.Context, userIDs []uint, opts GetQuestionsCountOptions) (map[uint]int64, error) {
+	counts := make(map[uint]int64, len(userIDs))
+	if len(userIDs) == 0 {
+		return counts, nil
+	}
+	for _, userID := range userIDs {
+		counts[userID] = 0
+	}
+
+	q := db.WithContext(ctx).Model(&Question{}).Where("user_id IN (?)", userIDs)
+	if opts.FilterAnswered {
+		q = q.Where(publiclyAnswered)
+	}
+	if opts.FilterUnread {
+		q = q.Where("read_at IS NULL")
+	}
+	if cond := opts.FilterArchived.where(); cond != "" {
+		q = q.Where(cond)
+	}
+	q = q.Where(shadowBannedWhere(opts.ShadowBanned))
+
+	var rows []struct {
+		UserID uint
+		Count  int64
+	}
+	if err := q.Select("user_id, COUNT(*) AS count").Group("user_id").Scan(&rows).Error; err != nil {
+		return nil, errors.Wrap(err, "count questions by user IDs")
+	}
+	for _, row := range rows {
+		counts[row.UserID] = row.Count
+	}
+	return counts, nil
+}
+
+// SetVisibility sets the visibility of the question, the share token is generated
+// the first time the question becomes unlisted and kept afterwards, so the shared links keep working.
+func (db *questions) SetVisibility(ctx context.Context, id uint, visibility QuestionVisibility) error {
+	if !visibility.IsValid() {
+		return ErrInvalidQuestionVisibility
+	}
+
+	ctx = WithPrimary(ctx)
+
+	question, err := db.GetByID(ctx, id)
+	if err != nil {
+		return errors.Wrap(err, "get question by ID")
+	}
+
+	updates := map[string]interface{}{"visibility": visibility}
+	if visibility == QuestionVisibilityUnlisted && question.ShareToken == "" {
+		updates["share_token"] = randstr.Hex(8)
+	}
+	if err := db.WithContext(ctx).Model(&Question{}).Where("id = ?", id).Updates(updates).Error; err != nil {
+		return errors.Wrap(err, "update visibility")
+	}
+	return nil
+}
+
+// SetHidden hides the question from the visitors or brings it back, the question is hidden
+// when it has been reported too many times, and the administrator decides whether to keep it hidden.
+func (db *questions) SetHidden(ctx context.Context, id uint, hidden bool) error {
+	var hiddenAt *time.Time
+	if hidden {
+		now := time.Now()
+		hiddenAt = &now
+	}
+	if err := db.WithContext(ctx).Model(&Question{}).Where("id = ?", id).Update("hidden_at", hiddenAt).Error; err != nil {
+		return errors.Wrap(err, "update hidden at")
+	}
+	return nil
+}
+
+// shortSlugLength is the length of the short link slugs, there are 62^7 possible slugs.
+const shortSlugLength = 7
+
+// maxShortSlugAttempts is how many times the slug is regenerated when it collides with an existing one.
+const maxShortSlugAttempts = 5
+
+// EnsureShortSlug returns the slug of the question's short link, it is generated the first time
+// and never changes afterwards, so the printed links keep working.
+func (db *questions) EnsureShortSlug(ctx context.Context, id uint) (string, error) {
+	ctx = WithPrimary(ctx)
+
+	question, err := db.GetByID(ctx, id)
+	if err != nil {
+		return "", errors.Wrap(err, "get question by ID")
+	}
+	if question.ShortSlug != nil {
+		return *question.ShortSlug, nil
+	}
+
+	for i := 0; i < maxShortSlugAttempts; i++ {
+		slug := randstr.Base62(shortSlugLength)
+
+		var count int64
+		if err := db.WithContext(ctx).Model(&Question{}).Unscoped().Where("short_slug = ?", slug).Count(&count).Error; err != nil {
+			return "", errors.Wrap(err, "count short slug")
+		}
+		if count > 0 {
+			continue
+		}
+
+		// The slug may be taken by a concurrent request, which is rejected by the unique index and retried.
+		result := db.WithContext(ctx).Model(&Question{}).Where("id = ? AND short_slug IS NULL", id).Update("short_slug", slug)
+		if result.Error != nil {
+			continue
+		}
+		if result.RowsAffected == 0 {
+			// The slug has been generated by a concurrent request.
+			question, err := db.GetByID(ctx, id)
+			if err != nil {
+				return "", errors.Wrap(err, "get question by ID")
+			}
+			if question.ShortSlug == nil {
+				return "", errors.New("short slug is not generated")
+			}
+			return *question.ShortSlug, nil
+		}
+		return slug, nil
+	}
+	return "", errors.Errorf("failed to generate short slug after %d attempts", maxShortSlugAttempts)
+}
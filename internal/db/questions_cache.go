@@ -0,0 +1,309 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/flamego/cache"
+	cacheRedis "github.com/flamego/cache/redis"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/conf"
+	"github.com/NekoWheel/NekoBox/internal/dbutil"
+)
+
+var _ QuestionsStore = (*cachedQuestions)(nil)
+
+const (
+	// questionsCacheLifetime is how long the cached questions are kept, the cache is also
+	// invalidated when the user's questions are changed, so it only needs to cover the
+	// changes made outside the store, e.g. the like count.
+	questionsCacheLifetime = time.Minute
+	// questionsCacheGenerationLifetime must be longer than questionsCacheLifetime,
+	// otherwise the reset generation may hit the cache written before the invalidation.
+	questionsCacheGenerationLifetime = 24 * time.Hour
+)
+
+// cachedQuestions wraps the QuestionsStore to cache the first page of the answered questions
+// and the question counts, which are read on every view of the public profile page.
+//
+// The cache keys contain the generation of the user's questions, the generation is renewed
+// when the user's questions are changed, so all the cached results of the user are invalidated at once.
+type cachedQuestions struct {
+	QuestionsStore
+	cache cache.Cache
+}
+
+// newQuestionsCache returns the Redis cache if it is configured, otherwise the memory cache is used,
+// which only works for a single instance.
+func newQuestionsCache() (cache.Cache, error) {
+	ctx := context.Background()
+	if conf.Redis.Addr != "" {
+		return cacheRedis.Initer()(ctx, cacheRedis.Config{
+			Options: &cacheRedis.Options{
+				Addr:     conf.Redis.Addr,
+				Password: conf.Redis.Password,
+				DB:       0,
+			},
+		})
+	}
+
+	memoryCache, err := cache.MemoryIniter()(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// The expired items of the memory cache are only removed by GC.
+	go func() {
+		for range time.Tick(5 * time.Minute) {
+			_ = memoryCache.GC(ctx)
+		}
+	}()
+	return memoryCache, nil
+}
+
+func newCachedQuestionsStore(store QuestionsStore, cache cache.Cache) QuestionsStore {
+	return &cachedQuestions{
+		QuestionsStore: store,
+		cache:          cache,
+	}
+}
+
+type cachedQuestionPage struct {
+	Questions []*Question
+	PageInfo  *dbutil.PageInfo
+}
+
+func (s *cachedQuestions) GetByUserID(ctx context.Context, userID uint, opts GetQuestionsByUserIDOptions) ([]*Question, *dbutil.PageInfo, error) {
+	// Only the first page of the public profile page is cached, the owner always reads the latest questions.
+	isFirstPage := opts.Cursor != nil && (opts.Cursor.Value == nil || fmt.Sprintf("%v", opts.Cursor.Value) == "")
+	if !opts.FilterAnswered || opts.FilterTag != "" || opts.FilterUnread || opts.FilterArchived != ArchivedFilterAll || opts.ShadowBanned || opts.Sort != QuestionSortNewest || !isFirstPage {
+		return s.QuestionsStore.GetByUserID(ctx, userID, opts)
+	}
+
+	key := s.key(ctx, userID, fmt.Sprintf("answered:%d:%t", opts.Cursor.Limit(), opts.Cursor.WithTotal))
+	var page cachedQuestionPage
+	if s.get(ctx, key, &page) {
+		return page.Questions, page.PageInfo, nil
+	}
+
+	questions, pageInfo, err := s.QuestionsStore.GetByUserID(ctx, userID, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	s.set(ctx, key, cachedQuestionPage{
+		Questions: questions,
+		PageInfo:  pageInfo,
+	})
+	return questions, pageInfo, nil
+}
+
+func (s *cachedQuestions) Count(ctx context.Context, userID uint, opts GetQuestionsCountOptions) (int64, error) {
+	// The unread count is changed by reading the questions, which doesn't invalidate the cache.
+	if opts.FilterUnread {
+		return s.QuestionsStore.Count(ctx, userID, opts)
+	}
+
+	key := s.key(ctx, userID, fmt.Sprintf("count:%t:%d:%t", opts.FilterAnswered, opts.FilterArchived, opts.ShadowBanned))
+	var count int64
+	if s.get(ctx, key, &count) {
+		return count, nil
+	}
+
+	count, err := s.QuestionsStore.Count(ctx, userID, opts)
+	if err != nil {
+		return 0, err
+	}
+	s.set(ctx, key, count)
+	return count, nil
+}
+
+func (s *cachedQuestions) Create(ctx context.Context, opts CreateQuestionOptions) (*Question, error) {
+	question, err := s.QuestionsStore.Create(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	s.invalidate(ctx, opts.UserID)
+	return question, nil
+}
+
+func (s *cachedQuestions) CreateBatch(ctx context.Context, opts CreateQuestionBatchOptions) (int, error) {
+	created, err := s.QuestionsStore.CreateBatch(ctx, opts)
+	if err != nil {
+		return 0, err
+	}
+	if created > 0 {
+		s.invalidate(ctx, opts.UserID)
+	}
+	return created, nil
+}
+
+func (s *cachedQuestions) UpdateCensor(ctx context.Context, id uint, opts UpdateQuestionCensorOptions) error {
+	return s.invalidateByID(ctx, id, func() error { return s.QuestionsStore.UpdateCensor(ctx, id, opts) })
+}
+
+func (s *cachedQuestions) AnswerByID(ctx context.Context, id uint, answer string, answerUserID uint) error {
+	return s.invalidateByID(ctx, id, func() error { return s.QuestionsStore.AnswerByID(ctx, id, answer, answerUserID) })
+}
+
+func (s *cachedQuestions) AnswerByIDIfVersion(ctx context.Context, id, version uint, answer string, answerUserID uint) error {
+	return s.invalidateByID(ctx, id, func() error {
+		return s.QuestionsStore.AnswerByIDIfVersion(ctx, id, version, answer, answerUserID)
+	})
+}
+
+func (s *cachedQuestions) UpdateAnswerByID(ctx context.Context, id uint, answer string, answerUserID uint) error {
+	return s.invalidateByID(ctx, id, func() error { return s.QuestionsStore.UpdateAnswerByID(ctx, id, answer, answerUserID) })
+}
+
+func (s *cachedQuestions) UpdateAnswerAudio(ctx context.Context, id uint, audioURL string, duration int) error {
+	return s.invalidateByID(ctx, id, func() error { return s.QuestionsStore.UpdateAnswerAudio(ctx, id, audioURL, duration) })
+}
+
+func (s *cachedQuestions) DeleteByID(ctx context.Context, id uint) error {
+	return s.invalidateByID(ctx, id, func() error { return s.QuestionsStore.DeleteByID(ctx, id) })
+}
+
+func (s *cachedQuestions) DeleteByIDs(ctx context.Context, userID uint, ids []uint) (int64, error) {
+	deleted, err := s.QuestionsStore.DeleteByIDs(ctx, userID, ids)
+	if err != nil {
+		return 0, err
+	}
+	if deleted > 0 {
+		s.invalidate(ctx, userID)
+	}
+	return deleted, nil
+}
+
+func (s *cachedQuestions) ArchiveUnanswered(ctx context.Context, userID uint, createdBefore time.Time) (int64, error) {
+	archived, err := s.QuestionsStore.ArchiveUnanswered(ctx, userID, createdBefore)
+	if err != nil {
+		return 0, err
+	}
+	if archived > 0 {
+		s.invalidate(ctx, userID)
+	}
+	return archived, nil
+}
+
+func (s *cachedQuestions) PinByID(ctx context.Context, id uint) error {
+	return s.invalidateByID(ctx, id, func() error { return s.QuestionsStore.PinByID(ctx, id) })
+}
+
+func (s *cachedQuestions) UnpinByID(ctx context.Context, id uint) error {
+	return s.invalidateByID(ctx, id, func() error { return s.QuestionsStore.UnpinByID(ctx, id) })
+}
+
+func (s *cachedQuestions) SetVisibility(ctx context.Context, id uint, visibility QuestionVisibility) error {
+	return s.invalidateByID(ctx, id, func() error { return s.QuestionsStore.SetVisibility(ctx, id, visibility) })
+}
+
+func (s *cachedQuestions) SetHidden(ctx context.Context, id uint, hidden bool) error {
+	return s.invalidateByID(ctx, id, func() error { return s.QuestionsStore.SetHidden(ctx, id, hidden) })
+}
+
+func (s *cachedQuestions) EnsureShortSlug(ctx context.Context, id uint) (slug string, err error) {
+	err = s.invalidateByID(ctx, id, func() error {
+		slug, err = s.QuestionsStore.EnsureShortSlug(ctx, id)
+		return err
+	})
+	return slug, err
+}
+
+func (s *cachedQuestions) Restore(ctx context.Context, id uint) error {
+	question, err := s.QuestionsStore.GetTrashedByID(WithPrimary(ctx), id)
+	if err != nil {
+		return errors.Wrap(err, "get trashed question by ID")
+	}
+
+	if err := s.QuestionsStore.Restore(ctx, id); err != nil {
+		return err
+	}
+	s.invalidate(ctx, question.UserID)
+	return nil
+}
+
+// invalidateByID invalidates the cache of the question's owner after the question is changed by fn.
+func (s *cachedQuestions) invalidateByID(ctx context.Context, id uint, fn func() error) error {
+	// The question is read before the change, it can not be found after it is deleted.
+	question, err := s.QuestionsStore.GetByID(WithPrimary(ctx), id)
+	if err != nil {
+		return errors.Wrap(err, "get question by ID")
+	}
+
+	if err := fn(); err != nil {
+		return err
+	}
+	s.invalidate(ctx, question.UserID)
+	return nil
+}
+
+func (s *cachedQuestions) generationKey(userID uint) string {
+	return fmt.Sprintf("questions:%d:generation", userID)
+}
+
+func (s *cachedQuestions) generation(ctx context.Context, userID uint) string {
+	generation, err := s.cache.Get(ctx, s.generationKey(userID))
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			logrus.WithContext(ctx).WithError(err).Error("Failed to get questions cache generation")
+		}
+		return "0"
+	}
+	return fmt.Sprintf("%v", generation)
+}
+
+func (s *cachedQuestions) invalidate(ctx context.Context, userID uint) {
+	generation := fmt.Sprintf("%d", time.Now().UnixNano())
+	if err := s.cache.Set(ctx, s.generationKey(userID), generation, questionsCacheGenerationLifetime); err != nil {
+		logrus.WithContext(ctx).WithError(err).WithField("user_id", userID).Error("Failed to invalidate questions cache")
+	}
+}
+
+// key returns the cache key of the user's current generation. The key must be computed before
+// reading the database, so the result read before an invalidation is never saved to the new generation.
+func (s *cachedQuestions) key(ctx context.Context, userID uint, name string) string {
+	return fmt.Sprintf("questions:%d:%s:%s", userID, s.generation(ctx, userID), name)
+}
+
+// get reads the cached value into v, it returns false if the value is not cached.
+// The cache errors are logged rather than returned, the caller falls back to the database.
+func (s *cachedQuestions) get(ctx context.Context, key string, v interface{}) bool {
+	data, err := s.cache.Get(ctx, key)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			logrus.WithContext(ctx).WithError(err).Error("Failed to get questions cache")
+		}
+		return false
+	}
+
+	// The value is encoded by ourselves, so the cache backend doesn't need to know the types.
+	b, ok := data.([]byte)
+	if !ok {
+		return false
+	}
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(v); err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("Failed to decode questions cache")
+		return false
+	}
+	return true
+}
+
+func (s *cachedQuestions) set(ctx context.Context, key string, v interface{}) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("Failed to encode questions cache")
+		return
+	}
+	if err := s.cache.Set(ctx, key, buf.Bytes(), questionsCacheLifetime); err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("Failed to set questions cache")
+	}
+}
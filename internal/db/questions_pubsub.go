@@ -0,0 +1,42 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/pubsub"
+)
+
+var _ QuestionsStore = (*publishedQuestions)(nil)
+
+// publishedQuestions wraps the QuestionsStore to publish the new questions to the open inbox pages of the owner.
+// The imported, the archived and the shadow-banned questions are not published, they are not shown in the inbox.
+type publishedQuestions struct {
+	QuestionsStore
+}
+
+func newPublishedQuestionsStore(store QuestionsStore) QuestionsStore {
+	return &publishedQuestions{store}
+}
+
+func (s *publishedQuestions) Create(ctx context.Context, opts CreateQuestionOptions) (*Question, error) {
+	question, err := s.QuestionsStore.Create(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if question.ArchivedAt != nil || question.ShadowBanned {
+		return question, nil
+	}
+
+	// The question has been created, failing to publish it only loses the live update.
+	if err := pubsub.Publish(ctx, question.UserID, pubsub.EventQuestionCreated, question); err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("Failed to publish new question")
+	}
+	return question, nil
+}
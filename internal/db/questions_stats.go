@@ -0,0 +1,161 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// QuestionSource is where the question comes from, which is shown in the statistics.
+type QuestionSource string
+
+const (
+	QuestionSourceAnonymous  QuestionSource = "anonymous"
+	QuestionSourceRegistered QuestionSource = "registered"
+	QuestionSourcePrompt     QuestionSource = "prompt"
+	QuestionSourceForwarded  QuestionSource = "forwarded"
+	QuestionSourceImported   QuestionSource = "imported"
+)
+
+// questionSourceExpr classifies the questions by the source, the earlier cases take precedence.
+const questionSourceExpr = `CASE
+	WHEN forwarded_from_question_id <> 0 THEN 'forwarded'
+	WHEN import_hash <> '' THEN 'imported'
+	WHEN prompt_id <> 0 THEN 'prompt'
+	WHEN asker_user_id <> 0 THEN 'registered'
+	ELSE 'anonymous'
+END`
+
+type QuestionStatsOptions struct {
+	// Since is the beginning of the period, the questions created before it are not counted.
+	Since time.Time
+}
+
+// QuestionDailyStats is the number of the questions received on the day,
+// and how many of them have been answered.
+type QuestionDailyStats struct {
+	Date     string
+	Count    int64
+	Answered int64
+}
+
+type QuestionSourceStats struct {
+	Source QuestionSource
+	Count  int64
+}
+
+// QuestionChannelStats is the number of the questions brought by the referral channel,
+// the channel is empty for the questions asked via the box link directly.
+type QuestionChannelStats struct {
+	Channel string
+	Count   int64
+}
+
+// QuestionStats is the statistics of the questions received by the user in the period.
+type QuestionStats struct {
+	Total    int64
+	Answered int64
+	// AverageResponseTime is the average time from receiving to answering the questions, it is
+	// zero if no question is answered, the questions answered before it is tracked are not counted.
+	AverageResponseTime time.Duration
+	// CensorChecked is the number of the questions checked by the text censor,
+	// and CensorRejected is the number of them which are rejected.
+	CensorChecked  int64
+	CensorRejected int64
+	Daily          []*QuestionDailyStats
+	Sources        []*QuestionSourceStats
+	// Channels only counts the questions whose referral is tracked.
+	Channels []*QuestionChannelStats
+}
+
+// StatsByUserID returns the statistics of the questions received by the user,
+// the days without any question are not included in the daily statistics.
+func (db *questions) StatsByUserID(ctx context.Context, userID uint, opts QuestionStatsOptions) (*QuestionStats, error) {
+	q := func() *gorm.DB {
+		return db.WithContext(ctx).Model(&Question{}).Where("user_id = ? AND created_at >= ? AND "+notShadowBanned, userID, opts.Since)
+	}
+
+	var stats QuestionStats
+	var totals struct {
+		Total          int64
+		Answered       int64
+		CensorChecked  int64
+		CensorRejected int64
+	}
+	if err := q().Select(`COUNT(*) AS total,
+		COALESCE(SUM(CASE WHEN answer <> '' THEN 1 ELSE 0 END), 0) AS answered,
+		COALESCE(SUM(CASE WHEN content_censor_metadata IS NOT NULL THEN 1 ELSE 0 END), 0) AS censor_checked,
+		COALESCE(SUM(CASE WHEN content_censor_metadata IS NOT NULL AND content_censor_pass = ? THEN 1 ELSE 0 END), 0) AS censor_rejected`, false).
+		Scan(&totals).Error; err != nil {
+		return nil, errors.Wrap(err, "count questions")
+	}
+	stats.Total = totals.Total
+	stats.Answered = totals.Answered
+	stats.CensorChecked = totals.CensorChecked
+	stats.CensorRejected = totals.CensorRejected
+
+	var responseSeconds sql.NullFloat64
+	if err := q().Where("answered_at IS NOT NULL").
+		Select("AVG(" + secondsBetween(db.DB, "created_at", "answered_at") + ")").
+		Scan(&responseSeconds).Error; err != nil {
+		return nil, errors.Wrap(err, "average response time")
+	}
+	if responseSeconds.Valid {
+		stats.AverageResponseTime = time.Duration(responseSeconds.Float64) * time.Second
+	}
+
+	dateExpr := dateOf(db.DB, "created_at")
+	if err := q().Select(dateExpr + ` AS date, COUNT(*) AS count,
+		COALESCE(SUM(CASE WHEN answer <> '' THEN 1 ELSE 0 END), 0) AS answered`).
+		Group(dateExpr).Order(dateExpr + " ASC").
+		Scan(&stats.Daily).Error; err != nil {
+		return nil, errors.Wrap(err, "count questions by date")
+	}
+	// The drivers return the date as the date string or the time, only the date part is kept.
+	for _, daily := range stats.Daily {
+		if len(daily.Date) > len("2006-01-02") {
+			daily.Date = daily.Date[:len("2006-01-02")]
+		}
+	}
+
+	if err := q().Select(questionSourceExpr + ` AS source, COUNT(*) AS count`).
+		Group("source").Order("count DESC").
+		Scan(&stats.Sources).Error; err != nil {
+		return nil, errors.Wrap(err, "count questions by source")
+	}
+
+	// The JSON functions vary between the databases, so the referrals are grouped by the channels here.
+	var sources []datatypes.JSON
+	if err := q().Where("source IS NOT NULL").Pluck("source", &sources).Error; err != nil {
+		return nil, errors.Wrap(err, "get question sources")
+	}
+	channelCounts := make(map[string]int64)
+	for _, source := range sources {
+		var referral QuestionReferral
+		if err := json.Unmarshal(source, &referral); err != nil {
+			continue
+		}
+		channelCounts[referral.Channel()]++
+	}
+	stats.Channels = make([]*QuestionChannelStats, 0, len(channelCounts))
+	for channel, count := range channelCounts {
+		stats.Channels = append(stats.Channels, &QuestionChannelStats{Channel: channel, Count: count})
+	}
+	sort.Slice(stats.Channels, func(i, j int) bool {
+		if stats.Channels[i].Count != stats.Channels[j].Count {
+			return stats.Channels[i].Count > stats.Channels[j].Count
+		}
+		return stats.Channels[i].Channel < stats.Channels[j].Channel
+	})
+	return &stats, nil
+}
@@ -0,0 +1,109 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db_test
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/db/migrations"
+	"github.com/NekoWheel/NekoBox/internal/dbutil"
+)
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	database, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "nekobox.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	if err := migrations.Migrate(database); err != nil {
+		t.Fatalf("migrate database: %v", err)
+	}
+	return database
+}
+
+// listAllQuestions pages through the questions of the user in the default order.
+func listAllQuestions(t *testing.T, store db.QuestionsStore, userID uint, pageSize int) []*db.Question {
+	t.Helper()
+
+	var all []*db.Question
+	var cursor string
+	for page := 0; ; page++ {
+		if page > 100 {
+			t.Fatal("too many pages")
+		}
+		questions, pageInfo, err := store.GetByUserID(context.Background(), userID, db.GetQuestionsByUserIDOptions{
+			Cursor: &dbutil.Cursor{Value: cursor, PageSize: pageSize},
+		})
+		if err != nil {
+			t.Fatalf("get questions: %v", err)
+		}
+		all = append(all, questions...)
+		if !pageInfo.HasMore {
+			return all
+		}
+		cursor = pageInfo.NextCursor
+	}
+}
+
+// checkQuestionsListed checks all the questions are listed once and from the newest to the oldest.
+func checkQuestionsListed(t *testing.T, questions []*db.Question, want int) {
+	t.Helper()
+
+	seen := make(map[uint]struct{}, len(questions))
+	for i, question := range questions {
+		if _, ok := seen[question.ID]; ok {
+			t.Fatalf("question %d is listed twice", question.ID)
+		}
+		seen[question.ID] = struct{}{}
+
+		if i > 0 && question.CreatedAt.After(questions[i-1].CreatedAt) {
+			t.Fatalf("question %d is listed after the older question %d", question.ID, questions[i-1].ID)
+		}
+	}
+	if len(seen) != want {
+		t.Fatalf("got %d questions, want %d", len(seen), want)
+	}
+}
+
+func TestQuestions_GetByUserID_Backdated(t *testing.T) {
+	store := db.NewQuestionsStore(newTestDB(t))
+
+	// The IDs of the backdated questions are larger than the ones of the newer questions.
+	now := time.Now()
+	var batch []db.BatchQuestion
+	for i := 0; i < 50; i++ {
+		createdAt := now.Add(-time.Duration(i) * time.Hour)
+		if i%2 == 1 {
+			createdAt = now.Add(-time.Duration(1000-i) * time.Hour)
+		}
+		batch = append(batch, db.BatchQuestion{
+			Content:   fmt.Sprintf("question %d", i),
+			CreatedAt: createdAt,
+		})
+	}
+	// Some of the questions have the same time, which are ordered by the ID.
+	batch[10].CreatedAt = batch[12].CreatedAt
+	if _, err := store.CreateBatch(context.Background(), db.CreateQuestionBatchOptions{UserID: 1, Questions: batch}); err != nil {
+		t.Fatalf("create questions: %v", err)
+	}
+
+	for _, pageSize := range []int{1, 7, 10, 50} {
+		t.Run(fmt.Sprintf("page size %d", pageSize), func(t *testing.T) {
+			checkQuestionsListed(t, listAllQuestions(t, store, 1, pageSize), 50)
+		})
+	}
+}
@@ -0,0 +1,315 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/NekoWheel/NekoBox/internal/dbutil"
+	"github.com/NekoWheel/NekoBox/internal/tracing"
+)
+
+var _ QuestionsStore = (*tracedQuestions)(nil)
+
+// tracedQuestions wraps the QuestionsStore to start a span for each method,
+// the SQL queries executed by the method are traced as the children of the span.
+type tracedQuestions struct {
+	QuestionsStore
+}
+
+func newTracedQuestionsStore(store QuestionsStore) QuestionsStore {
+	return &tracedQuestions{store}
+}
+
+func (s *tracedQuestions) Create(ctx context.Context, opts CreateQuestionOptions) (question *Question, err error) {
+	ctx, span := tracing.Start(ctx, "QuestionsStore.Create")
+	defer func() { tracing.End(span, err) }()
+	return s.QuestionsStore.Create(ctx, opts)
+}
+
+func (s *tracedQuestions) CreateBatch(ctx context.Context, opts CreateQuestionBatchOptions) (created int, err error) {
+	ctx, span := tracing.Start(ctx, "QuestionsStore.CreateBatch", attribute.Int("questions.count", len(opts.Questions)))
+	defer func() { tracing.End(span, err) }()
+	return s.QuestionsStore.CreateBatch(ctx, opts)
+}
+
+func (s *tracedQuestions) GetByID(ctx context.Context, id uint) (question *Question, err error) {
+	ctx, span := tracing.Start(ctx, "QuestionsStore.GetByID", attribute.Int64("question.id", int64(id)))
+	defer func() { tracing.End(span, err) }()
+	return s.QuestionsStore.GetByID(ctx, id)
+}
+
+func (s *tracedQuestions) GetByUserID(ctx context.Context, userID uint, opts GetQuestionsByUserIDOptions) (questions []*Question, pageInfo *dbutil.PageInfo, err error) {
+	ctx, span := tracing.Start(ctx, "QuestionsStore.GetByUserID", attribute.Int64("user.id", int64(userID)))
+	defer func() { tracing.End(span, err) }()
+	return s.QuestionsStore.GetByUserID(ctx, userID, opts)
+}
+
+func (s *tracedQuestions) GetByAskUserID(ctx context.Context, userID uint, opts GetQuestionsByAskUserIDOptions) (questions []*Question, pageInfo *dbutil.PageInfo, err error) {
+	ctx, span := tracing.Start(ctx, "QuestionsStore.GetByAskUserID", attribute.Int64("user.id", int64(userID)))
+	defer func() { tracing.End(span, err) }()
+	return s.QuestionsStore.GetByAskUserID(ctx, userID, opts)
+}
+
+func (s *tracedQuestions) Search(ctx context.Context, userID uint, keyword string, cursor *dbutil.Cursor) (questions []*Question, pageInfo *dbutil.PageInfo, err error) {
+	ctx, span := tracing.Start(ctx, "QuestionsStore.Search", attribute.Int64("user.id", int64(userID)))
+	defer func() { tracing.End(span, err) }()
+	return s.QuestionsStore.Search(ctx, userID, keyword, cursor)
+}
+
+func (s *tracedQuestions) GetHot(ctx context.Context, userID uint) (questions []*Question, err error) {
+	ctx, span := tracing.Start(ctx, "QuestionsStore.GetHot", attribute.Int64("user.id", int64(userID)))
+	defer func() { tracing.End(span, err) }()
+	return s.QuestionsStore.GetHot(ctx, userID)
+}
+
+func (s *tracedQuestions) GetNextUnanswered(ctx context.Context, userID uint, opts GetNextUnansweredOptions) (question *Question, err error) {
+	ctx, span := tracing.Start(ctx, "QuestionsStore.GetNextUnanswered", attribute.Int64("user.id", int64(userID)))
+	defer func() { tracing.End(span, err) }()
+	return s.QuestionsStore.GetNextUnanswered(ctx, userID, opts)
+}
+
+func (s *tracedQuestions) GetRandomAnswered(ctx context.Context, userID uint) (question *Question, err error) {
+	ctx, span := tracing.Start(ctx, "QuestionsStore.GetRandomAnswered", attribute.Int64("user.id", int64(userID)))
+	defer func() { tracing.End(span, err) }()
+	return s.QuestionsStore.GetRandomAnswered(ctx, userID)
+}
+
+func (s *tracedQuestions) IterateByUserID(ctx context.Context, userID uint, fn func(*Question) error) (err error) {
+	ctx, span := tracing.Start(ctx, "QuestionsStore.IterateByUserID", attribute.Int64("user.id", int64(userID)))
+	defer func() { tracing.End(span, err) }()
+	return s.QuestionsStore.IterateByUserID(ctx, userID, fn)
+}
+
+func (s *tracedQuestions) IterateAnswered(ctx context.Context, fn func(*Question) error) (err error) {
+	ctx, span := tracing.Start(ctx, "QuestionsStore.IterateAnswered")
+	defer func() { tracing.End(span, err) }()
+	return s.QuestionsStore.IterateAnswered(ctx, fn)
+}
+
+func (s *tracedQuestions) IterateAnsweredByUserID(ctx context.Context, userID uint, fn func(*Question) error) (err error) {
+	ctx, span := tracing.Start(ctx, "QuestionsStore.IterateAnsweredByUserID", attribute.Int64("user.id", int64(userID)))
+	defer func() { tracing.End(span, err) }()
+	return s.QuestionsStore.IterateAnsweredByUserID(ctx, userID, fn)
+}
+
+func (s *tracedQuestions) IterateByAskUserID(ctx context.Context, userID uint, fn func(*Question) error) (err error) {
+	ctx, span := tracing.Start(ctx, "QuestionsStore.IterateByAskUserID", attribute.Int64("user.id", int64(userID)))
+	defer func() { tracing.End(span, err) }()
+	return s.QuestionsStore.IterateByAskUserID(ctx, userID, fn)
+}
+
+func (s *tracedQuestions) AnswerByID(ctx context.Context, id uint, answer string, answerUserID uint) (err error) {
+	ctx, span := tracing.Start(ctx, "QuestionsStore.AnswerByID", attribute.Int64("question.id", int64(id)))
+	defer func() { tracing.End(span, err) }()
+	return s.QuestionsStore.AnswerByID(ctx, id, answer, answerUserID)
+}
+
+func (s *tracedQuestions) AnswerByIDIfVersion(ctx context.Context, id, version uint, answer string, answerUserID uint) (err error) {
+	ctx, span := tracing.Start(ctx, "QuestionsStore.AnswerByIDIfVersion", attribute.Int64("question.id", int64(id)))
+	defer func() { tracing.End(span, err) }()
+	return s.QuestionsStore.AnswerByIDIfVersion(ctx, id, version, answer, answerUserID)
+}
+
+func (s *tracedQuestions) UpdateAnswerByID(ctx context.Context, id uint, answer string, answerUserID uint) (err error) {
+	ctx, span := tracing.Start(ctx, "QuestionsStore.UpdateAnswerByID", attribute.Int64("question.id", int64(id)))
+	defer func() { tracing.End(span, err) }()
+	return s.QuestionsStore.UpdateAnswerByID(ctx, id, answer, answerUserID)
+}
+
+func (s *tracedQuestions) UpdateAnswerAudio(ctx context.Context, id uint, audioURL string, duration int) (err error) {
+	ctx, span := tracing.Start(ctx, "QuestionsStore.UpdateAnswerAudio", attribute.Int64("question.id", int64(id)))
+	defer func() { tracing.End(span, err) }()
+	return s.QuestionsStore.UpdateAnswerAudio(ctx, id, audioURL, duration)
+}
+
+func (s *tracedQuestions) GetAnswerAudioURLs(ctx context.Context, userID uint) (_ []string, err error) {
+	ctx, span := tracing.Start(ctx, "QuestionsStore.GetAnswerAudioURLs", attribute.Int64("user.id", int64(userID)))
+	defer func() { tracing.End(span, err) }()
+	return s.QuestionsStore.GetAnswerAudioURLs(ctx, userID)
+}
+
+func (s *tracedQuestions) GetByShortSlug(ctx context.Context, slug string) (question *Question, err error) {
+	ctx, span := tracing.Start(ctx, "QuestionsStore.GetByShortSlug")
+	defer func() { tracing.End(span, err) }()
+	return s.QuestionsStore.GetByShortSlug(ctx, slug)
+}
+
+func (s *tracedQuestions) FindRecentDuplicate(ctx context.Context, opts FindRecentDuplicateOptions) (question *Question, err error) {
+	ctx, span := tracing.Start(ctx, "QuestionsStore.FindRecentDuplicate", attribute.Int64("user.id", int64(opts.UserID)))
+	defer func() { tracing.End(span, err) }()
+	return s.QuestionsStore.FindRecentDuplicate(ctx, opts)
+}
+
+func (s *tracedQuestions) GetByIDs(ctx context.Context, userID uint, ids []uint) (questions []*Question, err error) {
+	ctx, span := tracing.Start(ctx, "QuestionsStore.GetByIDs", attribute.Int64("user.id", int64(userID)), attribute.Int("questions.count", len(ids)))
+	defer func() { tracing.End(span, err) }()
+	return s.QuestionsStore.GetByIDs(ctx, userID, ids)
+}
+
+func (s *tracedQuestions) DeleteByIDs(ctx context.Context, userID uint, ids []uint) (deleted int64, err error) {
+	ctx, span := tracing.Start(ctx, "QuestionsStore.DeleteByIDs", attribute.Int64("user.id", int64(userID)), attribute.Int("questions.count", len(ids)))
+	defer func() { tracing.End(span, err) }()
+	return s.QuestionsStore.DeleteByIDs(ctx, userID, ids)
+}
+
+func (s *tracedQuestions) MarkReadByID(ctx context.Context, id uint) (err error) {
+	ctx, span := tracing.Start(ctx, "QuestionsStore.MarkReadByID", attribute.Int64("question.id", int64(id)))
+	defer func() { tracing.End(span, err) }()
+	return s.QuestionsStore.MarkReadByID(ctx, id)
+}
+
+func (s *tracedQuestions) MarkAllRead(ctx context.Context, userID uint) (marked int64, err error) {
+	ctx, span := tracing.Start(ctx, "QuestionsStore.MarkAllRead", attribute.Int64("user.id", int64(userID)))
+	defer func() { tracing.End(span, err) }()
+	return s.QuestionsStore.MarkAllRead(ctx, userID)
+}
+
+func (s *tracedQuestions) MarkReadByIDs(ctx context.Context, userID uint, ids []uint) (marked int64, err error) {
+	ctx, span := tracing.Start(ctx, "QuestionsStore.MarkReadByIDs", attribute.Int64("user.id", int64(userID)), attribute.Int("questions.count", len(ids)))
+	defer func() { tracing.End(span, err) }()
+	return s.QuestionsStore.MarkReadByIDs(ctx, userID, ids)
+}
+
+func (s *tracedQuestions) DeleteByID(ctx context.Context, id uint) (err error) {
+	ctx, span := tracing.Start(ctx, "QuestionsStore.DeleteByID", attribute.Int64("question.id", int64(id)))
+	defer func() { tracing.End(span, err) }()
+	return s.QuestionsStore.DeleteByID(ctx, id)
+}
+
+func (s *tracedQuestions) GetTrashedByID(ctx context.Context, id uint) (question *Question, err error) {
+	ctx, span := tracing.Start(ctx, "QuestionsStore.GetTrashedByID", attribute.Int64("question.id", int64(id)))
+	defer func() { tracing.End(span, err) }()
+	return s.QuestionsStore.GetTrashedByID(ctx, id)
+}
+
+func (s *tracedQuestions) GetTrashedByUserID(ctx context.Context, userID uint) (questions []*Question, err error) {
+	ctx, span := tracing.Start(ctx, "QuestionsStore.GetTrashedByUserID", attribute.Int64("user.id", int64(userID)))
+	defer func() { tracing.End(span, err) }()
+	return s.QuestionsStore.GetTrashedByUserID(ctx, userID)
+}
+
+func (s *tracedQuestions) Restore(ctx context.Context, id uint) (err error) {
+	ctx, span := tracing.Start(ctx, "QuestionsStore.Restore", attribute.Int64("question.id", int64(id)))
+	defer func() { tracing.End(span, err) }()
+	return s.QuestionsStore.Restore(ctx, id)
+}
+
+func (s *tracedQuestions) PurgeTrashed(ctx context.Context, deletedBefore time.Time) (count int64, audioURLs []string, err error) {
+	ctx, span := tracing.Start(ctx, "QuestionsStore.PurgeTrashed")
+	defer func() { tracing.End(span, err) }()
+	return s.QuestionsStore.PurgeTrashed(ctx, deletedBefore)
+}
+
+func (s *tracedQuestions) ExpireIPs(ctx context.Context, opts ExpireIPsOptions) (count int64, err error) {
+	ctx, span := tracing.Start(ctx, "QuestionsStore.ExpireIPs")
+	defer func() { tracing.End(span, err) }()
+	return s.QuestionsStore.ExpireIPs(ctx, opts)
+}
+
+func (s *tracedQuestions) PinByID(ctx context.Context, id uint) (err error) {
+	ctx, span := tracing.Start(ctx, "QuestionsStore.PinByID", attribute.Int64("question.id", int64(id)))
+	defer func() { tracing.End(span, err) }()
+	return s.QuestionsStore.PinByID(ctx, id)
+}
+
+func (s *tracedQuestions) UnpinByID(ctx context.Context, id uint) (err error) {
+	ctx, span := tracing.Start(ctx, "QuestionsStore.UnpinByID", attribute.Int64("question.id", int64(id)))
+	defer func() { tracing.End(span, err) }()
+	return s.QuestionsStore.UnpinByID(ctx, id)
+}
+
+func (s *tracedQuestions) SetVisibility(ctx context.Context, id uint, visibility QuestionVisibility) (err error) {
+	ctx, span := tracing.Start(ctx, "QuestionsStore.SetVisibility", attribute.Int64("question.id", int64(id)))
+	defer func() { tracing.End(span, err) }()
+	return s.QuestionsStore.SetVisibility(ctx, id, visibility)
+}
+
+func (s *tracedQuestions) SetHidden(ctx context.Context, id uint, hidden bool) (err error) {
+	ctx, span := tracing.Start(ctx, "QuestionsStore.SetHidden", attribute.Int64("question.id", int64(id)))
+	defer func() { tracing.End(span, err) }()
+	return s.QuestionsStore.SetHidden(ctx, id, hidden)
+}
+
+func (s *tracedQuestions) EnsureShortSlug(ctx context.Context, id uint) (slug string, err error) {
+	ctx, span := tracing.Start(ctx, "QuestionsStore.EnsureShortSlug", attribute.Int64("question.id", int64(id)))
+	defer func() { tracing.End(span, err) }()
+	return s.QuestionsStore.EnsureShortSlug(ctx, id)
+}
+
+func (s *tracedQuestions) UpdateCensor(ctx context.Context, id uint, opts UpdateQuestionCensorOptions) (err error) {
+	ctx, span := tracing.Start(ctx, "QuestionsStore.UpdateCensor", attribute.Int64("question.id", int64(id)))
+	defer func() { tracing.End(span, err) }()
+	return s.QuestionsStore.UpdateCensor(ctx, id, opts)
+}
+
+func (s *tracedQuestions) ListPendingCensor(ctx context.Context, opts ListPendingCensorOptions) (questions []*Question, err error) {
+	ctx, span := tracing.Start(ctx, "QuestionsStore.ListPendingCensor")
+	defer func() { tracing.End(span, err) }()
+	return s.QuestionsStore.ListPendingCensor(ctx, opts)
+}
+
+func (s *tracedQuestions) IncreaseCensorRedriveCount(ctx context.Context, ids ...uint) (err error) {
+	ctx, span := tracing.Start(ctx, "QuestionsStore.IncreaseCensorRedriveCount", attribute.Int("questions.count", len(ids)))
+	defer func() { tracing.End(span, err) }()
+	return s.QuestionsStore.IncreaseCensorRedriveCount(ctx, ids...)
+}
+
+func (s *tracedQuestions) ListUnansweredAfter(ctx context.Context, userID, afterID uint, limit int) (questions []*Question, err error) {
+	ctx, span := tracing.Start(ctx, "QuestionsStore.ListUnansweredAfter", attribute.Int64("user.id", int64(userID)))
+	defer func() { tracing.End(span, err) }()
+	return s.QuestionsStore.ListUnansweredAfter(ctx, userID, afterID, limit)
+}
+
+func (s *tracedQuestions) ArchiveUnanswered(ctx context.Context, userID uint, createdBefore time.Time) (count int64, err error) {
+	ctx, span := tracing.Start(ctx, "QuestionsStore.ArchiveUnanswered", attribute.Int64("user.id", int64(userID)))
+	defer func() { tracing.End(span, err) }()
+	return s.QuestionsStore.ArchiveUnanswered(ctx, userID, createdBefore)
+}
+
+func (s *tracedQuestions) GetNextInQueue(ctx context.Context, userID uint, n int) (questions []*Question, err error) {
+	ctx, span := tracing.Start(ctx, "QuestionsStore.GetNextInQueue", attribute.Int64("user.id", int64(userID)))
+	defer func() { tracing.End(span, err) }()
+	return s.QuestionsStore.GetNextInQueue(ctx, userID, n)
+}
+
+func (s *tracedQuestions) GetQueuePosition(ctx context.Context, userID, questionID uint) (position int64, err error) {
+	ctx, span := tracing.Start(ctx, "QuestionsStore.GetQueuePosition", attribute.Int64("user.id", int64(userID)), attribute.Int64("question.id", int64(questionID)))
+	defer func() { tracing.End(span, err) }()
+	return s.QuestionsStore.GetQueuePosition(ctx, userID, questionID)
+}
+
+func (s *tracedQuestions) CountInQueue(ctx context.Context, userID uint) (count int64, err error) {
+	ctx, span := tracing.Start(ctx, "QuestionsStore.CountInQueue", attribute.Int64("user.id", int64(userID)))
+	defer func() { tracing.End(span, err) }()
+	return s.QuestionsStore.CountInQueue(ctx, userID)
+}
+
+func (s *tracedQuestions) CountAnsweredSince(ctx context.Context, userID uint, since time.Time) (count int64, err error) {
+	ctx, span := tracing.Start(ctx, "QuestionsStore.CountAnsweredSince", attribute.Int64("user.id", int64(userID)))
+	defer func() { tracing.End(span, err) }()
+	return s.QuestionsStore.CountAnsweredSince(ctx, userID, since)
+}
+
+func (s *tracedQuestions) Count(ctx context.Context, userID uint, opts GetQuestionsCountOptions) (count int64, err error) {
+	ctx, span := tracing.Start(ctx, "QuestionsStore.Count", attribute.Int64("user.id", int64(userID)))
+	defer func() { tracing.End(span, err) }()
+	return s.QuestionsStore.Count(ctx, userID, opts)
+}
+
+func (s *tracedQuestions) CountBatch(ctx context.Context, userIDs []uint, opts GetQuestionsCountOptions) (counts map[uint]int64, err error) {
+	ctx, span := tracing.Start(ctx, "QuestionsStore.CountBatch", attribute.Int("users.count", len(userIDs)))
+	defer func() { tracing.End(span, err) }()
+	return s.QuestionsStore.CountBatch(ctx, userIDs, opts)
+}
+
+func (s *tracedQuestions) StatsByUserID(ctx context.Context, userID uint, opts QuestionStatsOptions) (stats *QuestionStats, err error) {
+	ctx, span := tracing.Start(ctx, "QuestionsStore.StatsByUserID", attribute.Int64("user.id", int64(userID)))
+	defer func() { tracing.End(span, err) }()
+	return s.QuestionsStore.StatsByUserID(ctx, userID, opts)
+}
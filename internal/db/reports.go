@@ -0,0 +1,227 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+
+	"github.com/NekoWheel/NekoBox/internal/dbutil"
+)
+
+var Reports ReportsStore
+
+var _ ReportsStore = (*reports)(nil)
+
+type ReportsStore interface {
+	Create(ctx context.Context, opts CreateReportOptions) error
+	CountPendingByQuestionID(ctx context.Context, questionID uint) (int64, error)
+	CountByQuestionIDs(ctx context.Context, questionIDs []uint) (map[uint]int64, error)
+	ListPending(ctx context.Context, opts ListPendingReportsOptions) ([]*Report, *dbutil.PageInfo, error)
+	Resolve(ctx context.Context, questionID uint, opts ResolveReportsOptions) (int64, error)
+}
+
+func NewReportsStore(db *gorm.DB) ReportsStore {
+	return &reports{db}
+}
+
+type reports struct {
+	*gorm.DB
+}
+
+type ReportReason string
+
+const (
+	ReportReasonSpam       ReportReason = "spam"
+	ReportReasonHarassment ReportReason = "harassment"
+	ReportReasonIllegal    ReportReason = "illegal"
+)
+
+// ReportReasons are all the report reasons in the order shown to the visitors.
+var ReportReasons = []ReportReason{
+	ReportReasonSpam,
+	ReportReasonHarassment,
+	ReportReasonIllegal,
+}
+
+// IsValid returns whether the reason is one of the known values.
+func (r ReportReason) IsValid() bool {
+	for _, reason := range ReportReasons {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
+
+type ReportStatus string
+
+const (
+	ReportStatusPending ReportStatus = "pending"
+	// ReportStatusUpheld reports are confirmed by the administrator, the question is kept hidden.
+	ReportStatusUpheld ReportStatus = "upheld"
+	// ReportStatusDismissed reports are rejected by the administrator, the question is shown again.
+	ReportStatusDismissed ReportStatus = "dismissed"
+)
+
+// Report is the abuse report of an answered question. The reporter is identified by
+// the user ID if the visitor has logged in, otherwise by the hashed IP address.
+type Report struct {
+	dbutil.Model
+	QuestionID       uint         `gorm:"index:idx_report_question_id" json:"question_id"`
+	QuestionUserID   uint         `json:"question_user_id"`
+	ReporterUserID   uint         `json:"-"`
+	ReporterIPHash   string       `gorm:"type:varchar(64)" json:"-"`
+	Reason           ReportReason `gorm:"type:varchar(32)" json:"reason"`
+	Detail           string       `gorm:"type:varchar(500)" json:"detail"`
+	Status           ReportStatus `gorm:"type:varchar(16);not null;default:pending;index:idx_report_status" json:"status"`
+	ResolvedByUserID uint         `json:"-"`
+	ResolvedAt       *time.Time   `json:"resolved_at"`
+}
+
+var (
+	ErrReportExists        = errors.New("你已经举报过这个提问了，请等待管理员处理")
+	ErrReportNoSource      = errors.New("无法识别你的来源，请登录后再试")
+	ErrInvalidReportReason = errors.New("举报原因不合法")
+)
+
+type CreateReportOptions struct {
+	QuestionID     uint
+	QuestionUserID uint
+	ReporterUserID uint
+	ReporterIP     string
+	Reason         ReportReason
+	Detail         string
+}
+
+// Create saves the report, a visitor can only have one pending report for each question.
+func (db *reports) Create(ctx context.Context, opts CreateReportOptions) error {
+	if !opts.Reason.IsValid() {
+		return ErrInvalidReportReason
+	}
+
+	report := Report{
+		QuestionID:     opts.QuestionID,
+		QuestionUserID: opts.QuestionUserID,
+		ReporterUserID: opts.ReporterUserID,
+		Reason:         opts.Reason,
+		Detail:         opts.Detail,
+		Status:         ReportStatusPending,
+	}
+	// Prefer the user ID, the IP address of the logged user may change.
+	ipHashes := []string{""}
+	if report.ReporterUserID == 0 {
+		if opts.ReporterIP == "" {
+			return ErrReportNoSource
+		}
+		report.ReporterIPHash = saltedHashIP(opts.ReporterIP)
+		ipHashes = visitorIPHashes(opts.ReporterIP)
+	}
+
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&Report{}).
+			Where("question_id = ? AND status = ? AND reporter_user_id = ? AND reporter_ip_hash IN (?)", report.QuestionID, ReportStatusPending, report.ReporterUserID, ipHashes).
+			Count(&count).Error; err != nil {
+			return errors.Wrap(err, "count reports")
+		}
+		if count > 0 {
+			return ErrReportExists
+		}
+
+		if err := tx.Create(&report).Error; err != nil {
+			return errors.Wrap(err, "create report")
+		}
+		return nil
+	})
+}
+
+// CountPendingByQuestionID returns the number of the pending reports of the question,
+// it is compared with the threshold to hide the question automatically.
+func (db *reports) CountPendingByQuestionID(ctx context.Context, questionID uint) (int64, error) {
+	var count int64
+	if err := db.WithContext(ctx).Model(&Report{}).Where("question_id = ? AND status = ?", questionID, ReportStatusPending).Count(&count).Error; err != nil {
+		return 0, errors.Wrap(err, "count pending reports")
+	}
+	return count, nil
+}
+
+// CountByQuestionIDs returns the number of the reports of any status of each question.
+func (db *reports) CountByQuestionIDs(ctx context.Context, questionIDs []uint) (map[uint]int64, error) {
+	counts := make(map[uint]int64, len(questionIDs))
+	if len(questionIDs) == 0 {
+		return counts, nil
+	}
+
+	var rows []struct {
+		QuestionID uint
+		Count      int64
+	}
+	if err := db.WithContext(ctx).Model(&Report{}).
+		Where("question_id IN (?)", questionIDs).
+		Select("question_id, COUNT(*) AS count").
+		Group("question_id").
+		Scan(&rows).Error; err != nil {
+		return nil, errors.Wrap(err, "count reports by question IDs")
+	}
+	for _, row := range rows {
+		counts[row.QuestionID] = row.Count
+	}
+	return counts, nil
+}
+
+type ListPendingReportsOptions struct {
+	*dbutil.Cursor
+}
+
+// ListPending returns the pending reports, the oldest reports come first so they are handled in order.
+func (db *reports) ListPending(ctx context.Context, opts ListPendingReportsOptions) ([]*Report, *dbutil.PageInfo, error) {
+	q := db.WithContext(ctx).Model(&Report{}).Where("status = ?", ReportStatusPending)
+
+	var limit int
+	if opts.Cursor != nil {
+		limit = opts.Cursor.Limit()
+		if cursorID := opts.Cursor.Value; cursorID != nil && fmt.Sprintf("%v", cursorID) != "" {
+			q = q.Where("id > ?", cursorID)
+		}
+	}
+
+	reports, pageInfo, err := dbutil.Paginate(q.Order("id ASC"), limit, func(report *Report) interface{} { return report.ID })
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "paginate")
+	}
+	return reports, pageInfo, nil
+}
+
+type ResolveReportsOptions struct {
+	Status           ReportStatus
+	ResolvedByUserID uint
+}
+
+// Resolve closes all the pending reports of the question with the administrator's decision.
+// It returns the number of the resolved reports.
+func (db *reports) Resolve(ctx context.Context, questionID uint, opts ResolveReportsOptions) (int64, error) {
+	switch opts.Status {
+	case ReportStatusUpheld, ReportStatusDismissed:
+	default:
+		return 0, errors.Errorf("unexpected report status: %q", opts.Status)
+	}
+
+	result := db.WithContext(ctx).Model(&Report{}).
+		Where("question_id = ? AND status = ?", questionID, ReportStatusPending).
+		Updates(map[string]interface{}{
+			"status":              opts.Status,
+			"resolved_by_user_id": opts.ResolvedByUserID,
+			"resolved_at":         time.Now(),
+		})
+	if result.Error != nil {
+		return 0, errors.Wrap(result.Error, "update reports")
+	}
+	return result.RowsAffected, nil
+}
@@ -0,0 +1,69 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+
+	"github.com/NekoWheel/NekoBox/internal/conf"
+)
+
+// registerReplicas sends the read queries to the configured replicas, the writes and
+// the transactions are still sent to the primary database.
+func registerReplicas(db *gorm.DB) error {
+	if len(conf.Database.Replicas) == 0 {
+		return nil
+	}
+
+	replicas := make([]gorm.Dialector, 0, len(conf.Database.Replicas))
+	for _, address := range conf.Database.Replicas {
+		dialector, _, err := openDialector(address)
+		if err != nil {
+			return errors.Wrapf(err, "open replica %q", address)
+		}
+		replicas = append(replicas, dialector)
+	}
+
+	if err := db.Use(dbresolver.Register(dbresolver.Config{
+		Replicas: replicas,
+		Policy:   dbresolver.RandomPolicy{},
+	})); err != nil {
+		return errors.Wrap(err, "register dbresolver plugin")
+	}
+
+	if err := db.Callback().Query().Before("gorm:query").Register("nekobox:read_primary", readPrimary); err != nil {
+		return errors.Wrap(err, "register query callback")
+	}
+	if err := db.Callback().Row().Before("gorm:row").Register("nekobox:read_primary", readPrimary); err != nil {
+		return errors.Wrap(err, "register row callback")
+	}
+	if err := db.Callback().Raw().Before("gorm:raw").Register("nekobox:read_primary", readPrimary); err != nil {
+		return errors.Wrap(err, "register raw callback")
+	}
+	return nil
+}
+
+type primaryContextKey struct{}
+
+// WithPrimary returns a context whose queries are read from the primary database.
+// It is used by the read-after-write paths, which can not tolerate the replication lag.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryContextKey{}, true)
+}
+
+// readPrimary switches the query of the WithPrimary context to the primary database,
+// the operation clause resolves the connection again after the dbresolver callback.
+func readPrimary(db *gorm.DB) {
+	if db.Statement.Context == nil {
+		return
+	}
+	if primary, _ := db.Statement.Context.Value(primaryContextKey{}).(bool); primary {
+		dbresolver.Write.ModifyStatement(db.Statement)
+	}
+}
@@ -0,0 +1,197 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/thanhpk/randstr"
+	"gorm.io/gorm"
+
+	"github.com/NekoWheel/NekoBox/internal/dbutil"
+)
+
+var TwoFactors TwoFactorsStore
+
+var _ TwoFactorsStore = (*twoFactors)(nil)
+
+type TwoFactorsStore interface {
+	Create(ctx context.Context, userID uint, secret string) ([]string, error)
+	GetByUserID(ctx context.Context, userID uint) (*TwoFactor, error)
+	IsEnabled(ctx context.Context, userID uint) (bool, error)
+	UseRecoveryCode(ctx context.Context, userID uint, code string) error
+	RegenerateRecoveryCodes(ctx context.Context, userID uint) ([]string, error)
+	CountUnusedRecoveryCodes(ctx context.Context, userID uint) (int64, error)
+	DeleteByUserID(ctx context.Context, userID uint) error
+}
+
+func NewTwoFactorsStore(db *gorm.DB) TwoFactorsStore {
+	return &twoFactors{db}
+}
+
+type twoFactors struct {
+	*gorm.DB
+}
+
+// TwoFactor is the TOTP two-factor authentication of the user.
+type TwoFactor struct {
+	dbutil.Model
+	UserID uint   `gorm:"uniqueIndex:idx_two_factor_user_id" json:"-"`
+	Secret string `json:"-"`
+}
+
+// TwoFactorRecoveryCode is the one-time code to log in when the user lost the TOTP device.
+// Only the hash of the code is stored.
+type TwoFactorRecoveryCode struct {
+	dbutil.Model
+	UserID   uint   `gorm:"index:idx_two_factor_recovery_code_user_id" json:"-"`
+	CodeHash string `gorm:"type:varchar(64)" json:"-"`
+	IsUsed   bool   `json:"-"`
+}
+
+// TwoFactorRecoveryCodesCount is the number of the recovery codes generated at once.
+const TwoFactorRecoveryCodesCount = 10
+
+var (
+	ErrTwoFactorExists          = errors.New("已经开启过两步验证了")
+	ErrTwoFactorNotExist        = errors.New("没有开启两步验证")
+	ErrTwoFactorRecoveryCodeBad = errors.New("恢复码错误或已被使用")
+)
+
+// Create enables the two-factor authentication of the user with the secret,
+// it returns the plain recovery codes which can only be shown to the user once.
+func (db *twoFactors) Create(ctx context.Context, userID uint, secret string) ([]string, error) {
+	var codes []string
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&TwoFactor{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+			return errors.Wrap(err, "count two factors")
+		}
+		if count > 0 {
+			return ErrTwoFactorExists
+		}
+
+		if err := tx.Create(&TwoFactor{
+			UserID: userID,
+			Secret: secret,
+		}).Error; err != nil {
+			return errors.Wrap(err, "create two factor")
+		}
+
+		var err error
+		codes, err = createRecoveryCodes(tx, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (db *twoFactors) GetByUserID(ctx context.Context, userID uint) (*TwoFactor, error) {
+	var twoFactor TwoFactor
+	if err := db.WithContext(ctx).Where("user_id = ?", userID).First(&twoFactor).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTwoFactorNotExist
+		}
+		return nil, errors.Wrap(err, "get two factor by user ID")
+	}
+	return &twoFactor, nil
+}
+
+func (db *twoFactors) IsEnabled(ctx context.Context, userID uint) (bool, error) {
+	var count int64
+	if err := db.WithContext(ctx).Model(&TwoFactor{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+		return false, errors.Wrap(err, "count two factors")
+	}
+	return count > 0, nil
+}
+
+// UseRecoveryCode marks the unused recovery code of the user as used.
+func (db *twoFactors) UseRecoveryCode(ctx context.Context, userID uint, code string) error {
+	result := db.WithContext(ctx).Model(&TwoFactorRecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND is_used = FALSE", userID, hashRecoveryCode(code)).
+		Update("is_used", true)
+	if result.Error != nil {
+		return errors.Wrap(result.Error, "use recovery code")
+	}
+	if result.RowsAffected == 0 {
+		return ErrTwoFactorRecoveryCodeBad
+	}
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces all the recovery codes of the user with the new ones.
+func (db *twoFactors) RegenerateRecoveryCodes(ctx context.Context, userID uint) ([]string, error) {
+	var codes []string
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&TwoFactorRecoveryCode{}).Error; err != nil {
+			return errors.Wrap(err, "delete recovery codes")
+		}
+
+		var err error
+		codes, err = createRecoveryCodes(tx, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (db *twoFactors) CountUnusedRecoveryCodes(ctx context.Context, userID uint) (int64, error) {
+	var count int64
+	if err := db.WithContext(ctx).Model(&TwoFactorRecoveryCode{}).Where("user_id = ? AND is_used = FALSE", userID).Count(&count).Error; err != nil {
+		return 0, errors.Wrap(err, "count unused recovery codes")
+	}
+	return count, nil
+}
+
+// DeleteByUserID disables the two-factor authentication of the user and deletes the recovery codes.
+func (db *twoFactors) DeleteByUserID(ctx context.Context, userID uint) error {
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Delete permanently, so the user can enable it again with the unique index.
+		result := tx.Unscoped().Where("user_id = ?", userID).Delete(&TwoFactor{})
+		if result.Error != nil {
+			return errors.Wrap(result.Error, "delete two factor")
+		}
+		if result.RowsAffected == 0 {
+			return ErrTwoFactorNotExist
+		}
+
+		if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&TwoFactorRecoveryCode{}).Error; err != nil {
+			return errors.Wrap(err, "delete recovery codes")
+		}
+		return nil
+	})
+}
+
+func createRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	codes := make([]string, 0, TwoFactorRecoveryCodesCount)
+	recoveryCodes := make([]*TwoFactorRecoveryCode, 0, TwoFactorRecoveryCodesCount)
+	for i := 0; i < TwoFactorRecoveryCodesCount; i++ {
+		code := randstr.Hex(4) + "-" + randstr.Hex(4)
+		codes = append(codes, code)
+		recoveryCodes = append(recoveryCodes, &TwoFactorRecoveryCode{
+			UserID:   userID,
+			CodeHash: hashRecoveryCode(code),
+		})
+	}
+
+	if err := tx.Create(&recoveryCodes).Error; err != nil {
+		return nil, errors.Wrap(err, "create recovery codes")
+	}
+	return codes, nil
+}
+
+// hashRecoveryCode hashes the recovery code case-insensitively.
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(code))))
+	return hex.EncodeToString(sum[:])
+}
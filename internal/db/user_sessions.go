@@ -0,0 +1,159 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/thanhpk/randstr"
+	"gorm.io/gorm"
+
+	"github.com/NekoWheel/NekoBox/internal/dbutil"
+)
+
+var UserSessions UserSessionsStore
+
+var _ UserSessionsStore = (*userSessions)(nil)
+
+type UserSessionsStore interface {
+	Create(ctx context.Context, opts CreateUserSessionOptions) (*UserSession, string, error)
+	GetByToken(ctx context.Context, token string) (*UserSession, error)
+	GetByUserID(ctx context.Context, userID uint) ([]*UserSession, error)
+	Touch(ctx context.Context, id uint, ip string) error
+	DeleteByID(ctx context.Context, userID, id uint) error
+	DeleteByUserID(ctx context.Context, userID uint, exceptID uint) (int64, error)
+	DeleteInactive(ctx context.Context, lastSeenBefore time.Time) (int64, error)
+}
+
+func NewUserSessionsStore(db *gorm.DB) UserSessionsStore {
+	return &userSessions{db}
+}
+
+type userSessions struct {
+	*gorm.DB
+}
+
+// UserSession is the signed-in session of the user on a device. The session cookie carries the token,
+// and the session is signed out once the record is deleted.
+// Only the SHA-256 hash of the token is stored.
+type UserSession struct {
+	dbutil.Model
+	UserID     uint      `gorm:"index:idx_user_session_user_id" json:"-"`
+	TokenHash  string    `gorm:"type:varchar(64);uniqueIndex:idx_user_session_token_hash" json:"-"`
+	UserAgent  string    `gorm:"type:varchar(255)" json:"user_agent"`
+	IP         string    `gorm:"type:varchar(255)" json:"ip"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+}
+
+// UserSessionLifetime is how long the session lasts without any activity.
+const UserSessionLifetime = 7 * 24 * time.Hour
+
+// userSessionTouchInterval is how often the last seen time is updated, so that the database
+// isn't written on every request.
+const userSessionTouchInterval = time.Minute
+
+var ErrUserSessionNotExist = errors.New("登录会话不存在")
+
+// hashUserSessionToken returns the hash of the token stored in the database.
+func hashUserSessionToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+type CreateUserSessionOptions struct {
+	UserID    uint
+	UserAgent string
+	IP        string
+}
+
+// Create creates a new session, it returns the token which should be stored in the session cookie.
+func (db *userSessions) Create(ctx context.Context, opts CreateUserSessionOptions) (*UserSession, string, error) {
+	userAgent := opts.UserAgent
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+
+	token := randstr.Hex(20)
+	userSession := UserSession{
+		UserID:     opts.UserID,
+		TokenHash:  hashUserSessionToken(token),
+		UserAgent:  userAgent,
+		IP:         opts.IP,
+		LastSeenAt: time.Now(),
+	}
+	if err := db.WithContext(ctx).Create(&userSession).Error; err != nil {
+		return nil, "", errors.Wrap(err, "create user session")
+	}
+	return &userSession, token, nil
+}
+
+func (db *userSessions) GetByToken(ctx context.Context, token string) (*UserSession, error) {
+	var userSession UserSession
+	if err := db.WithContext(ctx).Where("token_hash = ?", hashUserSessionToken(token)).First(&userSession).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserSessionNotExist
+		}
+		return nil, errors.Wrap(err, "get user session by token")
+	}
+	return &userSession, nil
+}
+
+func (db *userSessions) GetByUserID(ctx context.Context, userID uint) ([]*UserSession, error) {
+	var userSessions []*UserSession
+	if err := db.WithContext(ctx).Where("user_id = ?", userID).Order("last_seen_at DESC").Find(&userSessions).Error; err != nil {
+		return nil, errors.Wrap(err, "get user sessions by user ID")
+	}
+	return userSessions, nil
+}
+
+// Touch records the session is active now from the given IP, it is skipped if the session has been active recently.
+func (db *userSessions) Touch(ctx context.Context, id uint, ip string) error {
+	now := time.Now()
+	if err := db.WithContext(ctx).Model(&UserSession{}).
+		Where("id = ? AND last_seen_at < ?", id, now.Add(-userSessionTouchInterval)).
+		Updates(map[string]interface{}{
+			"last_seen_at": now,
+			"ip":           ip,
+		}).Error; err != nil {
+		return errors.Wrap(err, "update last seen at")
+	}
+	return nil
+}
+
+// DeleteByID signs out the session of the user.
+func (db *userSessions) DeleteByID(ctx context.Context, userID, id uint) error {
+	result := db.WithContext(ctx).Unscoped().Where("user_id = ? AND id = ?", userID, id).Delete(&UserSession{})
+	if result.Error != nil {
+		return errors.Wrap(result.Error, "delete user session")
+	}
+	if result.RowsAffected == 0 {
+		return ErrUserSessionNotExist
+	}
+	return nil
+}
+
+// DeleteByUserID signs out all the sessions of the user except the given one,
+// it returns the number of the sessions signed out. All the sessions are signed out if exceptID is zero.
+func (db *userSessions) DeleteByUserID(ctx context.Context, userID uint, exceptID uint) (int64, error) {
+	result := db.WithContext(ctx).Unscoped().Where("user_id = ? AND id <> ?", userID, exceptID).Delete(&UserSession{})
+	if result.Error != nil {
+		return 0, errors.Wrap(result.Error, "delete user sessions")
+	}
+	return result.RowsAffected, nil
+}
+
+// DeleteInactive deletes the sessions which have not been active since the given time,
+// their session cookies have expired already.
+func (db *userSessions) DeleteInactive(ctx context.Context, lastSeenBefore time.Time) (int64, error) {
+	result := db.WithContext(ctx).Unscoped().Where("last_seen_at < ?", lastSeenBefore).Delete(&UserSession{})
+	if result.Error != nil {
+		return 0, errors.Wrap(result.Error, "delete inactive user sessions")
+	}
+	return result.RowsAffected, nil
+}
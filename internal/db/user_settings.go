@@ -0,0 +1,492 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"html/template"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+
+	"github.com/NekoWheel/NekoBox/internal/conf"
+	"github.com/NekoWheel/NekoBox/internal/theme"
+)
+
+const (
+	DefaultQuestionPlaceholder = "在此处撰写你的问题..."
+	maxQuestionPlaceholderLen  = 100
+	minAutoArchiveDays         = 7
+	maxAutoArchiveDays         = 365
+	MaxDailyQuota              = 50
+	MaxEmbedOrigins            = 10
+	MaxReminderDays            = 30
+	MaxDailyAskLimit           = 100
+)
+
+// BoxSettings is the customization of the user's ask box, which is stored as a JSON column.
+// The zero value keeps the default behaviour.
+type BoxSettings struct {
+	QuestionPlaceholder string `json:"question_placeholder"`
+	QuestionMinLength   int    `json:"question_min_length"`
+	QuestionMaxLength   int    `json:"question_max_length"`
+	// AnswerMaxLength and DailyAskLimit only tighten the site limits in the configuration.
+	AnswerMaxLength int  `json:"answer_max_length,omitempty"`
+	DailyAskLimit   int  `json:"daily_ask_limit,omitempty"`
+	HideReplyEmail  bool `json:"hide_reply_email"`
+	// EnableMarkdown renders the answers as Markdown, the questions are rendered as well
+	// if EnableQuestionMarkdown is set.
+	EnableMarkdown         bool `json:"enable_markdown"`
+	EnableQuestionMarkdown bool `json:"enable_question_markdown"`
+	// AutoArchiveDays archives the unanswered questions older than the given days, zero disables the auto-archival.
+	AutoArchiveDays int `json:"auto_archive_days,omitempty"`
+	// QueueMode surfaces the unanswered questions first-in first-out in the inbox,
+	// only DailyQuota of them are answered each day.
+	QueueMode  bool `json:"queue_mode,omitempty"`
+	DailyQuota int  `json:"daily_quota,omitempty"`
+	// MaskProfanity accepts the questions which fail the censor only mildly, and displays them with the
+	// flagged spans masked instead of rejecting them.
+	MaskProfanity bool `json:"mask_profanity,omitempty"`
+	// EmbedOrigins are the origins of the sites allowed to embed the ask widget, such as
+	// "https://blog.example.com". The widget is disabled if it's empty.
+	EmbedOrigins []string `json:"embed_origins,omitempty"`
+	// DisableAchievements opts out of the achievements, they are neither awarded nor shown on the box page.
+	DisableAchievements bool `json:"disable_achievements,omitempty"`
+}
+
+func (s *BoxSettings) Scan(value interface{}) error {
+	var raw []byte
+	switch v := value.(type) {
+	case nil:
+		*s = BoxSettings{}
+		return nil
+	case []byte:
+		raw = v
+	case string:
+		raw = []byte(v)
+	default:
+		return errors.Errorf("unexpected box settings type: %T", value)
+	}
+	if len(raw) == 0 {
+		*s = BoxSettings{}
+		return nil
+	}
+	return json.Unmarshal(raw, s)
+}
+
+func (s BoxSettings) Value() (driver.Value, error) {
+	raw, err := json.Marshal(s)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal box settings")
+	}
+	return string(raw), nil
+}
+
+// Placeholder returns the placeholder of the question textarea.
+func (s BoxSettings) Placeholder() string {
+	if s.QuestionPlaceholder == "" {
+		return DefaultQuestionPlaceholder
+	}
+	return s.QuestionPlaceholder
+}
+
+// MinLength returns the minimum length of the question content.
+func (s BoxSettings) MinLength() int {
+	if s.QuestionMinLength < 1 {
+		return 1
+	}
+	return s.QuestionMinLength
+}
+
+// MaxLength returns the maximum length of the question content.
+func (s BoxSettings) MaxLength() int {
+	if s.QuestionMaxLength < 1 || s.QuestionMaxLength > conf.Limits.QuestionMaxLength {
+		return conf.Limits.QuestionMaxLength
+	}
+	return s.QuestionMaxLength
+}
+
+// MaxAnswerLength returns the maximum length of the answer.
+func (s BoxSettings) MaxAnswerLength() int {
+	if s.AnswerMaxLength < 1 || s.AnswerMaxLength > conf.Limits.AnswerMaxLength {
+		return conf.Limits.AnswerMaxLength
+	}
+	return s.AnswerMaxLength
+}
+
+// AskLimit returns the most questions an asker can send to the box in 24 hours,
+// which is the stricter one of the site and the owner's limits. Zero means no limit.
+func (s BoxSettings) AskLimit() int {
+	limit := conf.Limits.DailyAskLimit
+	if s.DailyAskLimit > 0 && (limit == 0 || s.DailyAskLimit < limit) {
+		limit = s.DailyAskLimit
+	}
+	return limit
+}
+
+var ErrInvalidBoxSettings = errors.New("提问长度限制不合法，最小长度不能大于最大长度，且不能超过站点的最大长度")
+
+// Validate checks the box settings are valid.
+func (s BoxSettings) Validate() error {
+	if utf8.RuneCountInString(s.QuestionPlaceholder) > maxQuestionPlaceholderLen {
+		return errors.New("提问框提示文字不能超过 100 个字")
+	}
+	if s.QuestionMinLength < 0 || s.QuestionMaxLength < 0 || s.QuestionMaxLength > conf.Limits.QuestionMaxLength {
+		return ErrInvalidBoxSettings
+	}
+	if s.MinLength() > s.MaxLength() {
+		return ErrInvalidBoxSettings
+	}
+	if s.AnswerMaxLength < 0 || s.AnswerMaxLength > conf.Limits.AnswerMaxLength {
+		return errors.Errorf("回答最大长度应在 1 到 %d 个字之间", conf.Limits.AnswerMaxLength)
+	}
+	if s.DailyAskLimit < 0 || s.DailyAskLimit > MaxDailyAskLimit {
+		return errors.Errorf("每日提问次数限制应在 1 到 %d 个之间", MaxDailyAskLimit)
+	}
+	if s.AutoArchiveDays != 0 && (s.AutoArchiveDays < minAutoArchiveDays || s.AutoArchiveDays > maxAutoArchiveDays) {
+		return errors.Errorf("自动归档天数应在 %d 到 %d 天之间", minAutoArchiveDays, maxAutoArchiveDays)
+	}
+	if (s.QueueMode || s.DailyQuota != 0) && (s.DailyQuota < 1 || s.DailyQuota > MaxDailyQuota) {
+		return errors.Errorf("每日回答数量应在 1 到 %d 个之间", MaxDailyQuota)
+	}
+	if len(s.EmbedOrigins) > MaxEmbedOrigins {
+		return errors.Errorf("嵌入网站不能超过 %d 个", MaxEmbedOrigins)
+	}
+	for _, origin := range s.EmbedOrigins {
+		if !IsOrigin(origin) {
+			return errors.Errorf("嵌入网站 %q 不合法，应为 https://example.com 的形式", origin)
+		}
+	}
+	return nil
+}
+
+// IsOrigin reports whether the string is a web origin, which is the scheme, the host and the optional port.
+func IsOrigin(origin string) bool {
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	// The origin has nothing else than the scheme and the host, such as the path or the user info.
+	return (u.Scheme == "https" || u.Scheme == "http") && u.Host != "" && origin == u.Scheme+"://"+u.Host
+}
+
+// AllowsEmbedOrigin reports whether the ask widget can be embedded in the site of the origin.
+func (s BoxSettings) AllowsEmbedOrigin(origin string) bool {
+	for _, allowed := range s.EmbedOrigins {
+		if strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckQuestionLength checks the length of the question content meets the settings.
+func (s BoxSettings) CheckQuestionLength(content string) error {
+	length := utf8.RuneCountInString(content)
+	if length < s.MinLength() {
+		return errors.Errorf("问题内容至少需要 %d 个字", s.MinLength())
+	}
+	if length > s.MaxLength() {
+		return errors.Errorf("问题内容不能超过 %d 个字", s.MaxLength())
+	}
+	return nil
+}
+
+// CheckAnswerLength checks the length of the answer meets the settings.
+func (s BoxSettings) CheckAnswerLength(answer string) error {
+	if utf8.RuneCountInString(answer) > s.MaxAnswerLength() {
+		return errors.Errorf("回答内容不能超过 %d 个字", s.MaxAnswerLength())
+	}
+	return nil
+}
+
+// ProfileSettings is the appearance of the user's box page, which is stored as a JSON column.
+// The zero value keeps the default theme.
+type ProfileSettings struct {
+	Theme           string `json:"theme"`
+	AccentColor     string `json:"accent_color"`
+	BackgroundImage string `json:"background_image"`
+	// License is the license of the answers, the empty license isn't shown.
+	License ContentLicense `json:"license,omitempty"`
+}
+
+func (s *ProfileSettings) Scan(value interface{}) error {
+	var raw []byte
+	switch v := value.(type) {
+	case nil:
+		*s = ProfileSettings{}
+		return nil
+	case []byte:
+		raw = v
+	case string:
+		raw = []byte(v)
+	default:
+		return errors.Errorf("unexpected profile settings type: %T", value)
+	}
+	if len(raw) == 0 {
+		*s = ProfileSettings{}
+		return nil
+	}
+	return json.Unmarshal(raw, s)
+}
+
+func (s ProfileSettings) Value() (driver.Value, error) {
+	raw, err := json.Marshal(s)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal profile settings")
+	}
+	return string(raw), nil
+}
+
+var ErrInvalidProfileSettings = errors.New("主题设置不合法")
+
+// Validate checks the profile settings are valid.
+func (s ProfileSettings) Validate() error {
+	if s.Theme != "" {
+		if _, ok := theme.Get(s.Theme); !ok {
+			return ErrInvalidProfileSettings
+		}
+	}
+	if s.AccentColor != "" && !theme.IsColor(s.AccentColor) {
+		return ErrInvalidProfileSettings
+	}
+	if s.BackgroundImage != "" && !theme.IsBackgroundImage(s.BackgroundImage) {
+		return ErrInvalidProfileSettings
+	}
+	if s.License != "" && !s.License.IsValid() {
+		return ErrInvalidContentLicense
+	}
+	return nil
+}
+
+// ContentLicense is the license which tells how the answers of the box can be reused.
+type ContentLicense string
+
+const (
+	ContentLicenseAllRightsReserved ContentLicense = "all-rights-reserved"
+	ContentLicenseCCBY              ContentLicense = "cc-by-4.0"
+	ContentLicenseCC0               ContentLicense = "cc0-1.0"
+)
+
+// ContentLicenses are all the licenses the owners can choose, which are listed in the settings.
+var ContentLicenses = []ContentLicense{
+	ContentLicenseAllRightsReserved,
+	ContentLicenseCCBY,
+	ContentLicenseCC0,
+}
+
+var ErrInvalidContentLicense = errors.New("授权协议不合法")
+
+func (l ContentLicense) IsValid() bool {
+	switch l {
+	case ContentLicenseAllRightsReserved, ContentLicenseCCBY, ContentLicenseCC0:
+		return true
+	}
+	return false
+}
+
+// Name returns the name of the license shown on the pages.
+func (l ContentLicense) Name() string {
+	switch l {
+	case ContentLicenseAllRightsReserved:
+		return "保留所有权利"
+	case ContentLicenseCCBY:
+		return "CC BY 4.0"
+	case ContentLicenseCC0:
+		return "CC0 1.0"
+	}
+	return string(l)
+}
+
+// URL returns the link of the license text, it's empty for all rights reserved.
+func (l ContentLicense) URL() string {
+	switch l {
+	case ContentLicenseCCBY:
+		return "https://creativecommons.org/licenses/by/4.0/"
+	case ContentLicenseCC0:
+		return "https://creativecommons.org/publicdomain/zero/1.0/"
+	}
+	return ""
+}
+
+// LicenseInfo is the license of the answers in the API responses.
+type LicenseInfo struct {
+	ID   ContentLicense `json:"id"`
+	Name string         `json:"name"`
+	URL  string         `json:"url,omitempty"`
+}
+
+// Info returns the license with its name and link, it returns nil if no license is chosen.
+func (l ContentLicense) Info() *LicenseInfo {
+	if l == "" {
+		return nil
+	}
+	return &LicenseInfo{ID: l, Name: l.Name(), URL: l.URL()}
+}
+
+// CSS returns the CSS variables of the box page.
+func (s ProfileSettings) CSS() template.CSS {
+	return theme.Style(s.Theme, s.AccentColor, s.BackgroundImage)
+}
+
+// DigestFrequency is how often the new question notifications are sent to the box's owner.
+type DigestFrequency string
+
+const (
+	DigestFrequencyInstant DigestFrequency = "instant"
+	DigestFrequencyHourly  DigestFrequency = "hourly"
+	DigestFrequencyDaily   DigestFrequency = "daily"
+)
+
+// Interval returns the minimum interval between two digests, it returns zero for the instant frequency.
+func (f DigestFrequency) Interval() time.Duration {
+	switch f {
+	case DigestFrequencyHourly:
+		return time.Hour
+	case DigestFrequencyDaily:
+		return 24 * time.Hour
+	default:
+		return 0
+	}
+}
+
+// NotificationPreferences is how the user receives the notifications, which is stored as a JSON column.
+// The zero value keeps the default behaviour.
+type NotificationPreferences struct {
+	DigestFrequency DigestFrequency `json:"digest_frequency"`
+	// ReminderDays reminds the user of the questions unanswered for the given days, zero disables the reminders.
+	ReminderDays int `json:"reminder_days,omitempty"`
+}
+
+func (p *NotificationPreferences) Scan(value interface{}) error {
+	var raw []byte
+	switch v := value.(type) {
+	case nil:
+		*p = NotificationPreferences{}
+		return nil
+	case []byte:
+		raw = v
+	case string:
+		raw = []byte(v)
+	default:
+		return errors.Errorf("unexpected notification preferences type: %T", value)
+	}
+	if len(raw) == 0 {
+		*p = NotificationPreferences{}
+		return nil
+	}
+	return json.Unmarshal(raw, p)
+}
+
+func (p NotificationPreferences) Value() (driver.Value, error) {
+	raw, err := json.Marshal(p)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal notification preferences")
+	}
+	return string(raw), nil
+}
+
+// Frequency returns the digest frequency of the new question notifications.
+func (p NotificationPreferences) Frequency() DigestFrequency {
+	if p.DigestFrequency == "" {
+		return DigestFrequencyInstant
+	}
+	return p.DigestFrequency
+}
+
+// Validate checks the notification preferences are valid.
+func (p NotificationPreferences) Validate() error {
+	switch p.Frequency() {
+	case DigestFrequencyInstant, DigestFrequencyHourly, DigestFrequencyDaily:
+	default:
+		return errors.Errorf("unexpected digest frequency: %q", p.DigestFrequency)
+	}
+	if p.ReminderDays < 0 || p.ReminderDays > MaxReminderDays {
+		return errors.Errorf("unexpected reminder days: %d", p.ReminderDays)
+	}
+	return nil
+}
+
+const (
+	maxAwayMessageLen = 200
+	MaxAwayDays       = 365
+)
+
+// AwayStatus is the notice of the owner being away from the box, which is stored as a JSON column.
+// The status ends by itself on the return date.
+type AwayStatus struct {
+	Enabled bool `json:"enabled"`
+	// Until is the return date of the owner, the owner is away until it's changed if it's nil.
+	Until   *time.Time `json:"until,omitempty"`
+	Message string     `json:"message,omitempty"`
+	// DisableAsk closes the ask form while the owner is away.
+	DisableAsk bool `json:"disable_ask,omitempty"`
+}
+
+func (s *AwayStatus) Scan(value interface{}) error {
+	var raw []byte
+	switch v := value.(type) {
+	case nil:
+		*s = AwayStatus{}
+		return nil
+	case []byte:
+		raw = v
+	case string:
+		raw = []byte(v)
+	default:
+		return errors.Errorf("unexpected away status type: %T", value)
+	}
+	if len(raw) == 0 {
+		*s = AwayStatus{}
+		return nil
+	}
+	return json.Unmarshal(raw, s)
+}
+
+func (s AwayStatus) Value() (driver.Value, error) {
+	raw, err := json.Marshal(s)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal away status")
+	}
+	return string(raw), nil
+}
+
+// IsAway reports whether the owner is away now.
+func (s AwayStatus) IsAway() bool {
+	return s.Enabled && (s.Until == nil || time.Now().Before(*s.Until))
+}
+
+// AsksClosed reports whether the box doesn't receive the questions because the owner is away.
+func (s AwayStatus) AsksClosed() bool {
+	return s.IsAway() && s.DisableAsk
+}
+
+// ReturnDate returns the return date of the owner in the form of "2006-01-02", it's empty if it's not set.
+func (s AwayStatus) ReturnDate() string {
+	if s.Until == nil {
+		return ""
+	}
+	return s.Until.In(time.Local).Format("2006-01-02")
+}
+
+// Validate checks the away status is valid.
+func (s AwayStatus) Validate() error {
+	if utf8.RuneCountInString(s.Message) > maxAwayMessageLen {
+		return errors.Errorf("离开说明不能超过 %d 个字", maxAwayMessageLen)
+	}
+	if s.Enabled && s.Until != nil {
+		if !s.Until.After(time.Now()) {
+			return errors.New("返回日期应在今天之后")
+		}
+		if s.Until.After(time.Now().AddDate(0, 0, MaxAwayDays)) {
+			return errors.Errorf("返回日期不能超过 %d 天之后", MaxAwayDays)
+		}
+	}
+	return nil
+}
@@ -6,12 +6,15 @@ package db
 
 import (
 	"context"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/wuhan005/gadget"
 	"gorm.io/gorm"
 
 	"github.com/NekoWheel/NekoBox/internal/conf"
+	"github.com/NekoWheel/NekoBox/internal/security/boxdomain"
+	"github.com/NekoWheel/NekoBox/internal/security/disposable"
 )
 
 var Users UsersStore
@@ -23,12 +26,25 @@ type UsersStore interface {
 	GetByID(ctx context.Context, id uint) (*User, error)
 	GetByEmail(ctx context.Context, email string) (*User, error)
 	GetByDomain(ctx context.Context, domain string) (*User, error)
+	IsDomainAvailable(ctx context.Context, domain string) (bool, error)
 	Update(ctx context.Context, id uint, opts UpdateUserOptions) error
 	UpdateHarassmentSetting(ctx context.Context, id uint, typ HarassmentSettingType) error
+	UpdateBoxSettings(ctx context.Context, id uint, settings BoxSettings) error
+	UpdateProfileSettings(ctx context.Context, id uint, settings ProfileSettings) error
+	UpdateAwayStatus(ctx context.Context, id uint, status AwayStatus) error
+	UpdateNotificationPreferences(ctx context.Context, id uint, preferences NotificationPreferences) error
+	Iterate(ctx context.Context, fn func(*User) error) error
+	ListDigestSubscribers(ctx context.Context, frequency DigestFrequency, sentBefore time.Time) ([]*User, error)
+	UpdateDigestWatermark(ctx context.Context, id uint, questionID uint, sentAt time.Time) error
+	ListAutoArchiveEnabled(ctx context.Context) ([]*User, error)
+	ListReminderEnabled(ctx context.Context) ([]*User, error)
 	Authenticate(ctx context.Context, email, password string) (*User, error)
 	ChangePassword(ctx context.Context, id uint, oldPassword, newPassword string) error
 	UpdatePassword(ctx context.Context, id uint, newPassword string) error
+	SetAdmin(ctx context.Context, id uint, isAdmin bool) error
+	SetState(ctx context.Context, id uint, opts SetUserStateOptions) error
 	Deactivate(ctx context.Context, id uint) error
+	Delete(ctx context.Context, id uint) error
 }
 
 func NewUsersStore(db *gorm.DB) UsersStore {
@@ -46,10 +62,24 @@ type User struct {
 	Email             string                `json:"email"`
 	Avatar            string                `json:"avatar"`
 	Domain            string                `json:"domain"`
+	DomainSkeleton    string                `gorm:"type:varchar(20);index:idx_user_domain_skeleton" json:"-"`
 	Background        string                `json:"background"`
 	Intro             string                `json:"intro"`
 	Notify            NotifyType            `json:"notify"`
 	HarassmentSetting HarassmentSettingType `json:"harassment_setting"`
+	BoxSettings       BoxSettings           `gorm:"type:json" json:"box_settings"`
+	ProfileSettings   ProfileSettings       `gorm:"type:json" json:"profile_settings"`
+	AwayStatus        AwayStatus            `gorm:"type:json" json:"away_status"`
+	Locale            string                `gorm:"type:varchar(16)" json:"locale"`
+	IsAdmin           bool                  `gorm:"not null;default:false" json:"-"`
+	State             UserState             `gorm:"type:varchar(16);not null;default:active" json:"-"`
+	// SuspendedUntil is when the suspension expires, the suspended user is active again after it.
+	SuspendedUntil *time.Time `json:"-"`
+
+	NotificationPreferences NotificationPreferences `gorm:"type:json" json:"notification_preferences"`
+	// DigestWatermark is the ID of the last question included in the new question digest.
+	DigestWatermark uint       `json:"-"`
+	DigestSentAt    *time.Time `json:"-"`
 }
 
 type NotifyType string
@@ -66,6 +96,64 @@ const (
 	HarassmentSettingTypeRegisterOnly HarassmentSettingType = "register_only"
 )
 
+// UserState is the moderation state of the account, which is changed by the administrators.
+type UserState string
+
+const (
+	UserStateActive UserState = "active"
+	// UserStateSuspended users can't sign in, and their boxes don't receive questions until the suspension expires.
+	UserStateSuspended UserState = "suspended"
+	// UserStateBanned users can't sign in, and their boxes are not shown to anyone.
+	UserStateBanned UserState = "banned"
+	// UserStateShadowBanned users can use NekoBox as usual, but the questions they ask are archived silently.
+	UserStateShadowBanned UserState = "shadow_banned"
+)
+
+// UserStates are all the user states, which are listed in the options of the administrators.
+var UserStates = []UserState{
+	UserStateActive,
+	UserStateSuspended,
+	UserStateBanned,
+	UserStateShadowBanned,
+}
+
+// IsValid returns whether the state is one of the known values.
+func (s UserState) IsValid() bool {
+	switch s {
+	case UserStateActive, UserStateSuspended, UserStateBanned, UserStateShadowBanned:
+		return true
+	}
+	return false
+}
+
+// EffectiveState returns the state of the user in effect, the user whose suspension has expired is active.
+func (u *User) EffectiveState() UserState {
+	switch {
+	case u.State == "":
+		return UserStateActive
+	case u.State == UserStateSuspended && u.SuspendedUntil != nil && !time.Now().Before(*u.SuspendedUntil):
+		return UserStateActive
+	}
+	return u.State
+}
+
+// IsRestricted returns whether the user is suspended or banned, who can't sign in or receive questions.
+func (u *User) IsRestricted() bool {
+	state := u.EffectiveState()
+	return state == UserStateSuspended || state == UserStateBanned
+}
+
+// RestrictedError returns the error shown to the restricted user who tries to sign in.
+func (u *User) RestrictedError() error {
+	switch u.EffectiveState() {
+	case UserStateSuspended:
+		return ErrUserSuspended
+	case UserStateBanned:
+		return ErrUserBanned
+	}
+	return nil
+}
+
 func (u *User) EncodePassword() {
 	u.Password = gadget.HmacSha1(u.Password, conf.Server.Salt)
 }
@@ -90,22 +178,27 @@ var (
 	ErrBadCredential   = errors.New("邮箱或密码错误")
 	ErrDuplicateEmail  = errors.New("这个邮箱已经注册过账号了！")
 	ErrDuplicateDomain = errors.New("个性域名重复了，换一个吧~")
+	ErrUserSuspended   = errors.New("账号已被暂停使用，请在暂停结束后再登录")
+	ErrUserBanned      = errors.New("账号已被封禁")
 )
 
 func (db *users) Create(ctx context.Context, opts CreateUserOptions) error {
+	ctx = WithPrimary(ctx)
+
 	if err := db.validate(ctx, opts); err != nil {
 		return err
 	}
 
 	newUser := &User{
-		Name:       opts.Name,
-		Password:   opts.Password,
-		Email:      opts.Email,
-		Avatar:     opts.Avatar,
-		Domain:     opts.Domain,
-		Background: opts.Background,
-		Intro:      opts.Intro,
-		Notify:     NotifyTypeEmail,
+		Name:           opts.Name,
+		Password:       opts.Password,
+		Email:          opts.Email,
+		Avatar:         opts.Avatar,
+		Domain:         opts.Domain,
+		DomainSkeleton: boxdomain.Skeleton(opts.Domain),
+		Background:     opts.Background,
+		Intro:          opts.Intro,
+		Notify:         NotifyTypeEmail,
 	}
 	newUser.EncodePassword()
 
@@ -138,15 +231,30 @@ func (db *users) GetByDomain(ctx context.Context, domain string) (*User, error)
 	return db.getBy(ctx, "domain = ?", domain)
 }
 
+// IsDomainAvailable returns whether the domain is not taken by other users,
+// the domain confusable with a taken one is not available either.
+func (db *users) IsDomainAvailable(ctx context.Context, domain string) (bool, error) {
+	var count int64
+	if err := db.WithContext(ctx).Model(&User{}).
+		Where("domain = ? OR domain_skeleton = ?", domain, boxdomain.Skeleton(domain)).
+		Count(&count).Error; err != nil {
+		return false, errors.Wrap(err, "count")
+	}
+	return count == 0, nil
+}
+
 type UpdateUserOptions struct {
 	Name       string
 	Avatar     string
 	Background string
 	Intro      string
 	Notify     NotifyType
+	Locale     string
 }
 
 func (db *users) Update(ctx context.Context, id uint, opts UpdateUserOptions) error {
+	ctx = WithPrimary(ctx)
+
 	_, err := db.GetByID(ctx, id)
 	if err != nil {
 		return errors.Wrap(err, "get user by id")
@@ -164,6 +272,7 @@ func (db *users) Update(ctx context.Context, id uint, opts UpdateUserOptions) er
 		Background: opts.Background,
 		Intro:      opts.Intro,
 		Notify:     opts.Notify,
+		Locale:     opts.Locale,
 	}).Error; err != nil {
 		return errors.Wrap(err, "update user")
 	}
@@ -185,6 +294,158 @@ func (db *users) UpdateHarassmentSetting(ctx context.Context, id uint, typ Haras
 	return nil
 }
 
+func (db *users) UpdateBoxSettings(ctx context.Context, id uint, settings BoxSettings) error {
+	if err := settings.Validate(); err != nil {
+		return errors.Wrap(err, "validate box settings")
+	}
+
+	if err := db.WithContext(ctx).Model(&User{}).Where("id = ?", id).Update("box_settings", settings).Error; err != nil {
+		return errors.Wrap(err, "update user")
+	}
+	return nil
+}
+
+func (db *users) UpdateProfileSettings(ctx context.Context, id uint, settings ProfileSettings) error {
+	if err := settings.Validate(); err != nil {
+		return errors.Wrap(err, "validate profile settings")
+	}
+
+	if err := db.WithContext(ctx).Model(&User{}).Where("id = ?", id).Update("profile_settings", settings).Error; err != nil {
+		return errors.Wrap(err, "update user")
+	}
+	return nil
+}
+
+func (db *users) UpdateAwayStatus(ctx context.Context, id uint, status AwayStatus) error {
+	if err := status.Validate(); err != nil {
+		return errors.Wrap(err, "validate away status")
+	}
+
+	if err := db.WithContext(ctx).Model(&User{}).Where("id = ?", id).Update("away_status", status).Error; err != nil {
+		return errors.Wrap(err, "update user")
+	}
+	return nil
+}
+
+// UpdateNotificationPreferences updates the user's notification preferences.
+// When the user switches from the instant notifications to the digest, the digest watermark is moved to
+// the latest received question, so the questions which have been notified are not sent again.
+func (db *users) UpdateNotificationPreferences(ctx context.Context, id uint, preferences NotificationPreferences) error {
+	if err := preferences.Validate(); err != nil {
+		return errors.Wrap(err, "validate notification preferences")
+	}
+
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var user User
+		if err := tx.Where("id = ?", id).First(&user).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrUserNotExists
+			}
+			return errors.Wrap(err, "get user")
+		}
+
+		updates := map[string]interface{}{
+			"notification_preferences": preferences,
+		}
+		if user.NotificationPreferences.Frequency() == DigestFrequencyInstant && preferences.Frequency() != DigestFrequencyInstant {
+			var watermark uint
+			if err := tx.Unscoped().Model(&Question{}).Where("user_id = ?", id).Select("COALESCE(MAX(id), 0)").Scan(&watermark).Error; err != nil {
+				return errors.Wrap(err, "get latest question ID")
+			}
+			updates["digest_watermark"] = watermark
+			updates["digest_sent_at"] = time.Now()
+		}
+
+		if err := tx.Model(&User{}).Where("id = ?", id).Updates(updates).Error; err != nil {
+			return errors.Wrap(err, "update user")
+		}
+		return nil
+	})
+}
+
+// usersIterateBatchSize is the number of users loaded into memory at once when iterating.
+const usersIterateBatchSize = 100
+
+// Iterate calls fn with every active user in the registration order, the users are loaded in batches.
+func (db *users) Iterate(ctx context.Context, fn func(*User) error) error {
+	var users []*User
+	result := db.WithContext(ctx).FindInBatches(&users, usersIterateBatchSize, func(tx *gorm.DB, batch int) error {
+		for _, user := range users {
+			if err := fn(user); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if result.Error != nil {
+		return errors.Wrap(result.Error, "find in batches")
+	}
+	return nil
+}
+
+// ListDigestSubscribers returns the users who receive the new question notifications by email
+// with the given digest frequency, and whose last digest was sent before the given time.
+func (db *users) ListDigestSubscribers(ctx context.Context, frequency DigestFrequency, sentBefore time.Time) ([]*User, error) {
+	var users []*User
+	if err := db.WithContext(ctx).
+		Where("notify = ?", NotifyTypeEmail).
+		Where(jsonExtractText(db.DB, "notification_preferences", "digest_frequency")+" = ?", frequency).
+		Where("digest_sent_at IS NULL OR digest_sent_at < ?", sentBefore).
+		Find(&users).Error; err != nil {
+		return nil, errors.Wrap(err, "list digest subscribers")
+	}
+	return users, nil
+}
+
+// ListReminderEnabled returns the users who have enabled the reminders of the unanswered questions.
+func (db *users) ListReminderEnabled(ctx context.Context) ([]*User, error) {
+	var users []*User
+	if err := db.WithContext(ctx).
+		Where(jsonExtractText(db.DB, "notification_preferences", "reminder_days") + " IS NOT NULL").
+		Find(&users).Error; err != nil {
+		return nil, errors.Wrap(err, "list reminder enabled users")
+	}
+
+	// The key is omitted when the reminders are disabled, check the value again in case it is set to zero.
+	enabled := make([]*User, 0, len(users))
+	for _, user := range users {
+		if user.NotificationPreferences.ReminderDays > 0 {
+			enabled = append(enabled, user)
+		}
+	}
+	return enabled, nil
+}
+
+// ListAutoArchiveEnabled returns the users who have enabled the auto-archival of the unanswered questions.
+func (db *users) ListAutoArchiveEnabled(ctx context.Context) ([]*User, error) {
+	var users []*User
+	if err := db.WithContext(ctx).
+		Where(jsonExtractText(db.DB, "box_settings", "auto_archive_days") + " IS NOT NULL").
+		Find(&users).Error; err != nil {
+		return nil, errors.Wrap(err, "list auto-archive enabled users")
+	}
+
+	// The key is omitted when the auto-archival is disabled, check the value again in case it is set to zero.
+	enabled := make([]*User, 0, len(users))
+	for _, user := range users {
+		if user.BoxSettings.AutoArchiveDays > 0 {
+			enabled = append(enabled, user)
+		}
+	}
+	return enabled, nil
+}
+
+// UpdateDigestWatermark records the last question included in the digest and when the digest was sent.
+func (db *users) UpdateDigestWatermark(ctx context.Context, id uint, questionID uint, sentAt time.Time) error {
+	if err := db.WithContext(ctx).Model(&User{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"digest_watermark": questionID,
+		"digest_sent_at":   sentAt,
+	}).Error; err != nil {
+		return errors.Wrap(err, "update digest watermark")
+	}
+	return nil
+}
+
 func (db *users) Authenticate(ctx context.Context, email, password string) (*User, error) {
 	u, err := db.GetByEmail(ctx, email)
 	if err != nil {
@@ -199,6 +460,8 @@ func (db *users) Authenticate(ctx context.Context, email, password string) (*Use
 }
 
 func (db *users) ChangePassword(ctx context.Context, id uint, oldPassword, newPassword string) error {
+	ctx = WithPrimary(ctx)
+
 	u, err := db.GetByID(ctx, id)
 	if err != nil {
 		return errors.Wrap(err, "get user by id")
@@ -211,13 +474,26 @@ func (db *users) ChangePassword(ctx context.Context, id uint, oldPassword, newPa
 	u.Password = newPassword
 	u.EncodePassword()
 
-	if err := db.WithContext(ctx).Model(&User{}).Where("id = ?", u.ID).Update("password", u.Password).Error; err != nil {
-		return errors.Wrap(err, "change password")
-	}
-	return nil
+	return db.setPassword(ctx, u)
+}
+
+// setPassword saves the encoded password of the user, and signs out all the sessions of the user
+// so that whoever knows the old password can't stay signed in.
+func (db *users) setPassword(ctx context.Context, u *User) error {
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&User{}).Where("id = ?", u.ID).Update("password", u.Password).Error; err != nil {
+			return errors.Wrap(err, "change password")
+		}
+		if err := tx.Unscoped().Where("user_id = ?", u.ID).Delete(&UserSession{}).Error; err != nil {
+			return errors.Wrap(err, "delete user sessions")
+		}
+		return nil
+	})
 }
 
 func (db *users) UpdatePassword(ctx context.Context, id uint, newPassword string) error {
+	ctx = WithPrimary(ctx)
+
 	u, err := db.GetByID(ctx, id)
 	if err != nil {
 		return errors.Wrap(err, "get user by id")
@@ -226,13 +502,58 @@ func (db *users) UpdatePassword(ctx context.Context, id uint, newPassword string
 	u.Password = newPassword
 	u.EncodePassword()
 
-	if err := db.WithContext(ctx).Model(&User{}).Where("id = ?", u.ID).Update("password", u.Password).Error; err != nil {
-		return errors.Wrap(err, "change password")
+	return db.setPassword(ctx, u)
+}
+
+// SetAdmin grants or revokes the administrator privilege of the user.
+func (db *users) SetAdmin(ctx context.Context, id uint, isAdmin bool) error {
+	if err := db.WithContext(ctx).Model(&User{}).Where("id = ?", id).Update("is_admin", isAdmin).Error; err != nil {
+		return errors.Wrap(err, "update")
 	}
 	return nil
 }
 
+type SetUserStateOptions struct {
+	State UserState
+	// SuspendedUntil is only used by the suspended state, the suspension never expires if it is nil.
+	SuspendedUntil *time.Time
+}
+
+// SetState changes the moderation state of the user. The sessions of the suspended or banned user
+// are revoked, so the user is signed out on all the devices.
+func (db *users) SetState(ctx context.Context, id uint, opts SetUserStateOptions) error {
+	if !opts.State.IsValid() {
+		return errors.Errorf("invalid user state %q", opts.State)
+	}
+	if opts.State != UserStateSuspended {
+		opts.SuspendedUntil = nil
+	}
+
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&User{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"state":           opts.State,
+			"suspended_until": opts.SuspendedUntil,
+		})
+		if result.Error != nil {
+			return errors.Wrap(result.Error, "update")
+		}
+		if result.RowsAffected == 0 {
+			return ErrUserNotExists
+		}
+
+		if opts.State != UserStateSuspended && opts.State != UserStateBanned {
+			return nil
+		}
+		if err := tx.Unscoped().Where("user_id = ?", id).Delete(&UserSession{}).Error; err != nil {
+			return errors.Wrap(err, "delete user sessions")
+		}
+		return nil
+	})
+}
+
 func (db *users) Deactivate(ctx context.Context, id uint) error {
+	ctx = WithPrimary(ctx)
+
 	u, err := db.GetByID(ctx, id)
 	if err != nil {
 		return errors.Wrap(err, "get user by id")
@@ -244,7 +565,115 @@ func (db *users) Deactivate(ctx context.Context, id uint) error {
 	return nil
 }
 
+// Delete permanently deletes the user and all the data belonging to the user.
+// The questions received by the user are deleted, while the questions asked by the user
+// are kept for the boxes' owners but anonymized.
+func (db *users) Delete(ctx context.Context, id uint) error {
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var questionIDs []uint
+		if err := tx.Unscoped().Model(&Question{}).Where("user_id = ?", id).Pluck("id", &questionIDs).Error; err != nil {
+			return errors.Wrap(err, "get received question IDs")
+		}
+		if len(questionIDs) > 0 {
+			if err := deleteQuestions(tx, questionIDs); err != nil {
+				return errors.Wrap(err, "delete received questions")
+			}
+		}
+
+		if err := tx.Unscoped().Model(&QuestionReply{}).
+			Where("is_owner = FALSE AND question_id IN (?)", tx.Unscoped().Model(&Question{}).Select("id").Where("asker_user_id = ?", id)).
+			Update("from_ip", "").Error; err != nil {
+			return errors.Wrap(err, "anonymize asker replies")
+		}
+		if err := tx.Unscoped().Model(&Question{}).Where("asker_user_id = ?", id).Updates(map[string]interface{}{
+			"asker_user_id":       0,
+			"receive_reply_email": "",
+			"from_ip":             "",
+		}).Error; err != nil {
+			return errors.Wrap(err, "anonymize asked questions")
+		}
+
+		// The like counts of the questions are kept, only the link to the user is removed.
+		if err := tx.Unscoped().Where("user_id = ?", id).Delete(&QuestionReaction{}).Error; err != nil {
+			return errors.Wrap(err, "delete reactions")
+		}
+		if err := tx.Unscoped().Where("user_id = ?", id).Delete(&QuestionTag{}).Error; err != nil {
+			return errors.Wrap(err, "delete question tags")
+		}
+		if err := tx.Unscoped().Where("user_id = ? OR receiver_user_id = ?", id, id).Delete(&QuestionDraft{}).Error; err != nil {
+			return errors.Wrap(err, "delete question drafts")
+		}
+		if err := tx.Unscoped().Where("user_id = ?", id).Delete(&Prompt{}).Error; err != nil {
+			return errors.Wrap(err, "delete prompts")
+		}
+		if err := tx.Unscoped().Where("user_id = ?", id).Delete(&Block{}).Error; err != nil {
+			return errors.Wrap(err, "delete blocks")
+		}
+		if err := tx.Unscoped().Where("user_id = ?", id).Delete(&WordFilter{}).Error; err != nil {
+			return errors.Wrap(err, "delete word filters")
+		}
+		if err := tx.Unscoped().Where("user_id = ?", id).Delete(&AnswerTemplate{}).Error; err != nil {
+			return errors.Wrap(err, "delete answer templates")
+		}
+		if err := tx.Unscoped().Where("user_id = ?", id).Delete(&Achievement{}).Error; err != nil {
+			return errors.Wrap(err, "delete achievements")
+		}
+		if err := tx.Unscoped().Where("user_id = ?", id).Delete(&CrosspostAccount{}).Error; err != nil {
+			return errors.Wrap(err, "delete crosspost accounts")
+		}
+		if err := tx.Unscoped().Where("user_id = ?", id).Delete(&Crosspost{}).Error; err != nil {
+			return errors.Wrap(err, "delete crossposts")
+		}
+		if err := tx.Unscoped().Where("box_user_id = ? OR user_id = ?", id, id).Delete(&BoxMember{}).Error; err != nil {
+			return errors.Wrap(err, "delete box members")
+		}
+		if err := tx.Unscoped().Where("user_id = ?", id).Delete(&ActivityPubKey{}).Error; err != nil {
+			return errors.Wrap(err, "delete activitypub keys")
+		}
+		if err := tx.Unscoped().Where("user_id = ?", id).Delete(&ActivityPubFollower{}).Error; err != nil {
+			return errors.Wrap(err, "delete activitypub followers")
+		}
+		if err := tx.Unscoped().Where("user_id = ?", id).Delete(&Webhook{}).Error; err != nil {
+			return errors.Wrap(err, "delete webhooks")
+		}
+		if err := tx.Unscoped().Where("user_id = ?", id).Delete(&PushSubscription{}).Error; err != nil {
+			return errors.Wrap(err, "delete push subscriptions")
+		}
+		if err := tx.Unscoped().Where("user_id = ?", id).Delete(&AccessToken{}).Error; err != nil {
+			return errors.Wrap(err, "delete access tokens")
+		}
+		if err := tx.Unscoped().Where("user_id = ?", id).Delete(&UserSession{}).Error; err != nil {
+			return errors.Wrap(err, "delete user sessions")
+		}
+		if err := tx.Unscoped().Where("user_id = ?", id).Delete(&LoginAttempt{}).Error; err != nil {
+			return errors.Wrap(err, "delete login attempts")
+		}
+		if err := tx.Unscoped().Where("user_id = ?", id).Delete(&LoginLink{}).Error; err != nil {
+			return errors.Wrap(err, "delete login links")
+		}
+		if err := tx.Unscoped().Where("user_id = ?", id).Delete(&EmailChange{}).Error; err != nil {
+			return errors.Wrap(err, "delete email changes")
+		}
+		if err := tx.Unscoped().Where("user_id = ?", id).Delete(&TwoFactor{}).Error; err != nil {
+			return errors.Wrap(err, "delete two factor")
+		}
+		if err := tx.Unscoped().Where("user_id = ?", id).Delete(&TwoFactorRecoveryCode{}).Error; err != nil {
+			return errors.Wrap(err, "delete two factor recovery codes")
+		}
+
+		// The user is deleted permanently, so that the email and domain can be registered again.
+		if err := tx.Unscoped().Where("id = ?", id).Delete(&User{}).Error; err != nil {
+			return errors.Wrap(err, "delete user")
+		}
+		return nil
+	})
+}
+
 func (db *users) validate(ctx context.Context, opts CreateUserOptions) error {
+	if err := disposable.Check(opts.Email); err != nil {
+		return err
+	}
+
 	if err := db.WithContext(ctx).Model(&User{}).Where("email = ?", opts.Email).First(&User{}).Error; err != nil {
 		if err != gorm.ErrRecordNotFound {
 			return errors.Wrap(err, "validate email")
@@ -253,11 +682,14 @@ func (db *users) validate(ctx context.Context, opts CreateUserOptions) error {
 		return ErrDuplicateEmail
 	}
 
-	if err := db.WithContext(ctx).Model(&User{}).Where("domain = ?", opts.Domain).First(&User{}).Error; err != nil {
-		if err != gorm.ErrRecordNotFound {
-			return errors.Wrap(err, "validate name")
-		}
-	} else {
+	if err := boxdomain.Validate(opts.Domain); err != nil {
+		return err
+	}
+	available, err := db.IsDomainAvailable(ctx, opts.Domain)
+	if err != nil {
+		return errors.Wrap(err, "validate domain")
+	}
+	if !available {
 		return ErrDuplicateDomain
 	}
 
@@ -0,0 +1,106 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/thanhpk/randstr"
+	"gorm.io/gorm"
+
+	"github.com/NekoWheel/NekoBox/internal/dbutil"
+)
+
+var Webhooks WebhooksStore
+
+var _ WebhooksStore = (*webhooks)(nil)
+
+type WebhooksStore interface {
+	Create(ctx context.Context, opts CreateWebhookOptions) (*Webhook, error)
+	GetByUserID(ctx context.Context, userID uint) ([]*Webhook, error)
+	DeleteByID(ctx context.Context, userID, id uint) error
+}
+
+func NewWebhooksStore(db *gorm.DB) WebhooksStore {
+	return &webhooks{db}
+}
+
+type webhooks struct {
+	*gorm.DB
+}
+
+// Webhook is the URL registered by the box owner, which receives the question lifecycle events.
+type Webhook struct {
+	dbutil.Model
+	UserID uint   `gorm:"index:idx_webhook_user_id" json:"-"`
+	URL    string `json:"url"`
+	// Secret is used to sign the payload with HMAC-SHA256.
+	Secret string `json:"-"`
+}
+
+// MaxWebhooksPerUser is the maximum number of webhooks that a user can register.
+const MaxWebhooksPerUser = 5
+
+var (
+	ErrWebhookNotExist     = errors.New("Webhook 不存在")
+	ErrTooManyWebhooks     = errors.New("最多只能添加 5 个 Webhook")
+	ErrWebhookAlreadyExist = errors.New("该 Webhook 地址已经添加过了")
+)
+
+type CreateWebhookOptions struct {
+	UserID uint
+	URL    string
+	Secret string
+}
+
+func (db *webhooks) Create(ctx context.Context, opts CreateWebhookOptions) (*Webhook, error) {
+	webhooks, err := db.GetByUserID(ctx, opts.UserID)
+	if err != nil {
+		return nil, errors.Wrap(err, "get webhooks by user ID")
+	}
+	if len(webhooks) >= MaxWebhooksPerUser {
+		return nil, ErrTooManyWebhooks
+	}
+	for _, webhook := range webhooks {
+		if webhook.URL == opts.URL {
+			return nil, ErrWebhookAlreadyExist
+		}
+	}
+
+	secret := opts.Secret
+	if secret == "" {
+		secret = randstr.String(32)
+	}
+
+	webhook := Webhook{
+		UserID: opts.UserID,
+		URL:    opts.URL,
+		Secret: secret,
+	}
+	if err := db.WithContext(ctx).Create(&webhook).Error; err != nil {
+		return nil, errors.Wrap(err, "create webhook")
+	}
+	return &webhook, nil
+}
+
+func (db *webhooks) GetByUserID(ctx context.Context, userID uint) ([]*Webhook, error) {
+	var webhooks []*Webhook
+	if err := db.WithContext(ctx).Where("user_id = ?", userID).Order("id ASC").Find(&webhooks).Error; err != nil {
+		return nil, errors.Wrap(err, "get webhooks by user ID")
+	}
+	return webhooks, nil
+}
+
+func (db *webhooks) DeleteByID(ctx context.Context, userID, id uint) error {
+	result := db.WithContext(ctx).Where("user_id = ? AND id = ?", userID, id).Delete(&Webhook{})
+	if result.Error != nil {
+		return errors.Wrap(result.Error, "delete webhook")
+	}
+	if result.RowsAffected == 0 {
+		return ErrWebhookNotExist
+	}
+	return nil
+}
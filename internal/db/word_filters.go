@@ -0,0 +1,139 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+
+	"github.com/NekoWheel/NekoBox/internal/dbutil"
+)
+
+var WordFilters WordFiltersStore
+
+var _ WordFiltersStore = (*wordFilters)(nil)
+
+type WordFiltersStore interface {
+	Create(ctx context.Context, opts CreateWordFilterOptions) (*WordFilter, error)
+	GetByUserID(ctx context.Context, userID uint) ([]*WordFilter, error)
+	DeleteByID(ctx context.Context, userID, id uint) error
+}
+
+func NewWordFiltersStore(db *gorm.DB) WordFiltersStore {
+	return &wordFilters{db}
+}
+
+type wordFilters struct {
+	*gorm.DB
+}
+
+// WordFilter is a banned word or regular expression pattern of the box owner,
+// the new questions matching it are checked before the global censor.
+type WordFilter struct {
+	dbutil.Model
+	UserID  uint             `gorm:"index:idx_word_filter_user_id" json:"-"`
+	Pattern string           `gorm:"type:varchar(100)" json:"pattern"`
+	IsRegex bool             `gorm:"not null;default:false" json:"is_regex"`
+	Action  WordFilterAction `gorm:"type:varchar(16)" json:"action"`
+}
+
+// WordFilterAction is what to do with the question matching the word filter.
+type WordFilterAction string
+
+const (
+	// WordFilterActionReject rejects the question, the asker is told to change the content.
+	WordFilterActionReject WordFilterAction = "reject"
+	// WordFilterActionArchive accepts the question silently and moves it to the archive.
+	WordFilterActionArchive WordFilterAction = "archive"
+)
+
+// IsValid returns whether the action is one of the known values.
+func (a WordFilterAction) IsValid() bool {
+	switch a {
+	case WordFilterActionReject, WordFilterActionArchive:
+		return true
+	}
+	return false
+}
+
+const (
+	// MaxWordFiltersPerUser is the maximum number of the word filters that a user can have.
+	MaxWordFiltersPerUser = 50
+	// MaxWordFilterPatternLength is the maximum length of the word filter pattern.
+	MaxWordFilterPatternLength = 100
+)
+
+var (
+	ErrWordFilterNotExist      = errors.New("屏蔽词不存在")
+	ErrTooManyWordFilters      = errors.New("最多只能设置 50 个屏蔽词")
+	ErrInvalidWordFilter       = errors.New("屏蔽词不能为空且不能超过 100 个字")
+	ErrInvalidWordFilterRegex  = errors.New("正则表达式格式不正确")
+	ErrInvalidWordFilterAction = errors.New("屏蔽词的处理方式不合法")
+)
+
+type CreateWordFilterOptions struct {
+	UserID  uint
+	Pattern string
+	IsRegex bool
+	Action  WordFilterAction
+}
+
+func (db *wordFilters) Create(ctx context.Context, opts CreateWordFilterOptions) (*WordFilter, error) {
+	pattern := strings.TrimSpace(opts.Pattern)
+	if pattern == "" || utf8.RuneCountInString(pattern) > MaxWordFilterPatternLength {
+		return nil, ErrInvalidWordFilter
+	}
+	if opts.IsRegex {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return nil, ErrInvalidWordFilterRegex
+		}
+	}
+	if !opts.Action.IsValid() {
+		return nil, ErrInvalidWordFilterAction
+	}
+
+	var count int64
+	if err := db.WithContext(WithPrimary(ctx)).Model(&WordFilter{}).Where("user_id = ?", opts.UserID).Count(&count).Error; err != nil {
+		return nil, errors.Wrap(err, "count word filters")
+	}
+	if count >= MaxWordFiltersPerUser {
+		return nil, ErrTooManyWordFilters
+	}
+
+	wordFilter := WordFilter{
+		UserID:  opts.UserID,
+		Pattern: pattern,
+		IsRegex: opts.IsRegex,
+		Action:  opts.Action,
+	}
+	if err := db.WithContext(ctx).Create(&wordFilter).Error; err != nil {
+		return nil, errors.Wrap(err, "create word filter")
+	}
+	return &wordFilter, nil
+}
+
+func (db *wordFilters) GetByUserID(ctx context.Context, userID uint) ([]*WordFilter, error) {
+	var wordFilters []*WordFilter
+	if err := db.WithContext(ctx).Where("user_id = ?", userID).Order("id ASC").Find(&wordFilters).Error; err != nil {
+		return nil, errors.Wrap(err, "get word filters by user ID")
+	}
+	return wordFilters, nil
+}
+
+func (db *wordFilters) DeleteByID(ctx context.Context, userID, id uint) error {
+	result := db.WithContext(ctx).Unscoped().Where("user_id = ? AND id = ?", userID, id).Delete(&WordFilter{})
+	if result.Error != nil {
+		return errors.Wrap(result.Error, "delete word filter")
+	}
+	if result.RowsAffected == 0 {
+		return ErrWordFilterNotExist
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package dbutil
+
+import (
+	"strings"
+)
+
+// uniqueViolationMessages are the error messages of the unique constraint violation
+// in SQLite, MySQL and PostgreSQL.
+var uniqueViolationMessages = []string{
+	"UNIQUE constraint failed",
+	"Error 1062",
+	"SQLSTATE 23505",
+}
+
+// IsUniqueViolation returns whether the error is caused by violating a unique constraint,
+// e.g. the same row is inserted by the concurrent requests.
+func IsUniqueViolation(err error) bool {
+	if err == nil {
+		return false
+	}
+	for _, message := range uniqueViolationMessages {
+		if strings.Contains(err.Error(), message) {
+			return true
+		}
+	}
+	return false
+}
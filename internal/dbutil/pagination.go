@@ -4,6 +4,13 @@
 
 package dbutil
 
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
 var (
 	DefaultPageSize    = 20
 	MaxDefaultPageSize = 100
@@ -32,6 +39,8 @@ func (p Pagination) LimitOffset() (limit, offset int) {
 type Cursor struct {
 	Value    interface{}
 	PageSize int
+	// WithTotal counts the total rows of the query regardless of the cursor.
+	WithTotal bool
 }
 
 func (p Cursor) Limit() int {
@@ -44,3 +53,33 @@ func (p Cursor) Limit() int {
 	}
 	return pageSize
 }
+
+// PageInfo is the metadata of the cursor pagination.
+type PageInfo struct {
+	NextCursor string `json:"next_cursor"`
+	HasMore    bool   `json:"has_more"`
+	Total      int64  `json:"total"`
+}
+
+// Paginate fetches one more row than the limit to know whether there is a next page,
+// the extra row is dropped from the returned items. All the rows are returned if the limit is not positive.
+func Paginate[T any](q *gorm.DB, limit int, cursorOf func(T) interface{}) ([]T, *PageInfo, error) {
+	if limit > 0 {
+		q = q.Limit(limit + 1)
+	}
+
+	var items []T
+	if err := q.Find(&items).Error; err != nil {
+		return nil, nil, errors.Wrap(err, "find")
+	}
+
+	pageInfo := &PageInfo{}
+	if limit > 0 && len(items) > limit {
+		items = items[:limit]
+		pageInfo.HasMore = true
+	}
+	if pageInfo.HasMore {
+		pageInfo.NextCursor = fmt.Sprintf("%v", cursorOf(items[len(items)-1]))
+	}
+	return items, pageInfo, nil
+}
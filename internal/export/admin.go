@@ -0,0 +1,111 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package export
+
+import (
+	"context"
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/NekoWheel/NekoBox/internal/db"
+)
+
+var adminQuestionCSVHeader = []string{
+	"ID", "提问时间", "提问箱用户 ID", "提问者用户 ID", "提问者 IP", "提问者地区",
+	"问题", "问题审核", "回答", "回答时间", "回答审核", "举报数", "隐藏时间",
+}
+
+// censorResult returns the censor result of the text in the export, the text without the censor
+// metadata has not been censored yet.
+func censorResult(metadata []byte, pass bool) string {
+	switch {
+	case len(metadata) == 0 || string(metadata) == "null":
+		return "pending"
+	case pass:
+		return "passed"
+	default:
+		return "failed"
+	}
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+func adminQuestionCSVRecord(question *db.Question, reports int64) []string {
+	answerCensor := ""
+	if question.Answer != "" {
+		answerCensor = censorResult(question.AnswerCensorMetadata, question.AnswerCensorPass)
+	}
+
+	return []string{
+		strconv.Itoa(int(question.ID)),
+		question.CreatedAt.Format(time.RFC3339),
+		strconv.Itoa(int(question.UserID)),
+		strconv.Itoa(int(question.AskerUserID)),
+		csvText(question.FromIP),
+		csvText(question.FromRegion),
+		csvText(question.Content),
+		censorResult(question.ContentCensorMetadata, question.ContentCensorPass),
+		csvText(question.Answer),
+		formatTime(question.AnsweredAt),
+		answerCensor,
+		strconv.FormatInt(reports, 10),
+		formatTime(question.HiddenAt),
+	}
+}
+
+// AdminQuestionsCSV writes the questions of all the users matching the filters to w as a CSV file
+// for the administrators. The questions are streamed in batches, and each batch is flushed to w
+// as soon as it is written, so the huge export neither eats up the memory nor times out the client.
+func AdminQuestionsCSV(ctx context.Context, w io.Writer, opts db.IterateFilteredQuestionsOptions) error {
+	// Write the UTF-8 BOM, so that Excel can recognize the encoding.
+	if _, err := io.WriteString(w, "\uFEFF"); err != nil {
+		return errors.Wrap(err, "write CSV BOM")
+	}
+	csvWriter := csv.NewWriter(w)
+	if err := csvWriter.Write(adminQuestionCSVHeader); err != nil {
+		return errors.Wrap(err, "write CSV header")
+	}
+
+	flusher, _ := w.(interface{ Flush() })
+	if err := db.Questions.IterateFiltered(ctx, opts, func(questions []*db.Question) error {
+		questionIDs := make([]uint, 0, len(questions))
+		for _, question := range questions {
+			questionIDs = append(questionIDs, question.ID)
+		}
+		reports, err := db.Reports.CountByQuestionIDs(ctx, questionIDs)
+		if err != nil {
+			return errors.Wrap(err, "count reports")
+		}
+
+		for _, question := range questions {
+			if err := csvWriter.Write(adminQuestionCSVRecord(question, reports[question.ID])); err != nil {
+				return errors.Wrap(err, "write CSV record")
+			}
+		}
+
+		csvWriter.Flush()
+		if err := csvWriter.Error(); err != nil {
+			return errors.Wrap(err, "flush CSV")
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	}); err != nil {
+		return errors.Wrap(err, "iterate questions")
+	}
+
+	csvWriter.Flush()
+	return errors.Wrap(csvWriter.Error(), "flush CSV")
+}
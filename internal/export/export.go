@@ -0,0 +1,56 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package export
+
+import (
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// maxConcurrentJobs limits the number of the exports running at the same time,
+// the export iterates over all the user's questions, which is expensive for the database.
+const maxConcurrentJobs = 4
+
+var (
+	ErrExportInProgress = errors.New("你的数据正在导出中，请稍后再试")
+	ErrExportBusy       = errors.New("当前导出的用户较多，请稍后再试")
+)
+
+var runner = struct {
+	sync.Mutex
+	slots   chan struct{}
+	running map[uint]struct{}
+}{
+	slots:   make(chan struct{}, maxConcurrentJobs),
+	running: make(map[uint]struct{}),
+}
+
+// Run runs the export job of the user, only one export job is allowed for a user at the same time.
+func Run(userID uint, job func() error) error {
+	runner.Lock()
+	if _, ok := runner.running[userID]; ok {
+		runner.Unlock()
+		return ErrExportInProgress
+	}
+
+	select {
+	case runner.slots <- struct{}{}:
+	default:
+		runner.Unlock()
+		return ErrExportBusy
+	}
+	runner.running[userID] = struct{}{}
+	runner.Unlock()
+
+	defer func() {
+		runner.Lock()
+		delete(runner.running, userID)
+		<-runner.slots
+		runner.Unlock()
+	}()
+
+	return job()
+}
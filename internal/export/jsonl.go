@@ -0,0 +1,98 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package export
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/NekoWheel/NekoBox/internal/db"
+)
+
+// JSONLContentType is the content type of the JSONL files.
+const JSONLContentType = "application/x-ndjson"
+
+// JSONLQuestion is a line of the JSONL interchange format of the questions, which is used to migrate
+// the boxes between the NekoBox instances. Each line of the file is a JSON object of a question:
+//
+//	{"content":"...","answer":"...","created_at":"2022-01-02T15:04:05+08:00","answered_at":"2022-01-03T10:00:00+08:00","visibility":"public","tags":["life"]}
+//
+// Only the content is required. The timestamps are in RFC 3339, the answer is empty for the unanswered
+// questions, and the visibility is one of "public", "unlisted" and "private", defaults to "public".
+// The unknown fields are ignored, so the fields added later are compatible with the older instances.
+type JSONLQuestion struct {
+	Content         string     `json:"content"`
+	Answer          string     `json:"answer,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	AnsweredAt      *time.Time `json:"answered_at,omitempty"`
+	AnswerUpdatedAt *time.Time `json:"answer_updated_at,omitempty"`
+	Visibility      string     `json:"visibility,omitempty"`
+	Tags            []string   `json:"tags,omitempty"`
+}
+
+// jsonlTagsBatchSize is the number of the questions whose tags are loaded in a query.
+const jsonlTagsBatchSize = 100
+
+// QuestionsJSONL writes the questions received by the user to w in the JSONL interchange format.
+// The questions hidden by the administrators are not exported.
+func QuestionsJSONL(ctx context.Context, w io.Writer, userID uint) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+
+	// The tags are loaded for a batch of the questions at once.
+	batch := make([]*db.Question, 0, jsonlTagsBatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		ids := make([]uint, 0, len(batch))
+		for _, question := range batch {
+			ids = append(ids, question.ID)
+		}
+		tags, err := db.QuestionTags.GetByQuestionIDs(ctx, ids)
+		if err != nil {
+			return errors.Wrap(err, "get tags")
+		}
+
+		for _, question := range batch {
+			line := JSONLQuestion{
+				Content:         question.Content,
+				Answer:          question.Answer,
+				CreatedAt:       question.CreatedAt,
+				AnsweredAt:      question.AnsweredAt,
+				AnswerUpdatedAt: question.AnswerUpdatedAt,
+				Visibility:      string(question.Visibility),
+				Tags:            tags[question.ID],
+			}
+			if err := encoder.Encode(line); err != nil {
+				return errors.Wrap(err, "encode question")
+			}
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	if err := db.Questions.IterateByUserID(ctx, userID, func(question *db.Question) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if question.HiddenAt != nil {
+			return nil
+		}
+
+		batch = append(batch, question)
+		if len(batch) < jsonlTagsBatchSize {
+			return nil
+		}
+		return flush()
+	}); err != nil {
+		return errors.Wrap(err, "iterate questions")
+	}
+	return flush()
+}
@@ -0,0 +1,169 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package export
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"html/template"
+	"io"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+
+	"github.com/NekoWheel/NekoBox/internal/db"
+	templatepkg "github.com/NekoWheel/NekoBox/internal/template"
+	"github.com/NekoWheel/NekoBox/templates"
+)
+
+const (
+	// siteQuestionsPerPage is the number of the questions listed on each index page of the static site.
+	siteQuestionsPerPage = 50
+	// siteExcerptLength is the max length of the answer excerpt shown on the index pages.
+	siteExcerptLength = 100
+)
+
+// siteEntry is the summary of the question listed on the index pages, only the summaries are kept
+// in memory while the question pages are streamed into the archive.
+type siteEntry struct {
+	ID        uint
+	CreatedAt time.Time
+	Content   string
+	Excerpt   string
+}
+
+type siteIndexPage struct {
+	Root       string
+	User       *db.User
+	Questions  []siteEntry
+	Page       int
+	PrevURL    string
+	NextURL    string
+	ExportedAt time.Time
+}
+
+type siteQuestionPage struct {
+	Root       string
+	User       *db.User
+	Question   *db.Question
+	ExportedAt time.Time
+}
+
+// Site writes the ZIP archive of the static site of the user's box to w, which can be opened
+// in the browser directly or hosted anywhere. The site contains the index pages, a page for
+// each public answered question and the stylesheet.
+func Site(ctx context.Context, w io.Writer, user *db.User) error {
+	t := template.New("")
+	for _, funcMap := range templatepkg.FuncMap() {
+		t = t.Funcs(funcMap)
+	}
+	t, err := t.ParseFS(templates.FS, "export/index.html", "export/question.html")
+	if err != nil {
+		return errors.Wrap(err, "parse templates")
+	}
+	style, err := templates.FS.ReadFile("export/style.css")
+	if err != nil {
+		return errors.Wrap(err, "read stylesheet")
+	}
+
+	zw := zip.NewWriter(w)
+	exportedAt := time.Now()
+
+	styleWriter, err := zw.Create("assets/style.css")
+	if err != nil {
+		return errors.Wrap(err, "create assets/style.css")
+	}
+	if _, err := styleWriter.Write(style); err != nil {
+		return errors.Wrap(err, "write stylesheet")
+	}
+
+	var entries []siteEntry
+	if err := db.Questions.IterateAnsweredByUserID(ctx, user.ID, func(question *db.Question) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		pageWriter, err := zw.Create(fmt.Sprintf("questions/%d.html", question.ID))
+		if err != nil {
+			return errors.Wrap(err, "create question page")
+		}
+		if err := t.ExecuteTemplate(pageWriter, "question.html", siteQuestionPage{
+			Root:       "../",
+			User:       user,
+			Question:   question,
+			ExportedAt: exportedAt,
+		}); err != nil {
+			return errors.Wrap(err, "render question page")
+		}
+
+		entries = append(entries, siteEntry{
+			ID:        question.ID,
+			CreatedAt: question.CreatedAt,
+			Content:   question.Content,
+			Excerpt:   excerpt(question.Answer, siteExcerptLength),
+		})
+		return nil
+	}); err != nil {
+		return errors.Wrap(err, "iterate answered questions")
+	}
+
+	// The questions are iterated in the creation order, while the latest ones are listed first.
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+
+	pages := (len(entries) + siteQuestionsPerPage - 1) / siteQuestionsPerPage
+	if pages == 0 {
+		pages = 1 // The first index page is always written, even if it is empty.
+	}
+	for page := 1; page <= pages; page++ {
+		start := (page - 1) * siteQuestionsPerPage
+		end := start + siteQuestionsPerPage
+		if end > len(entries) {
+			end = len(entries)
+		}
+
+		data := siteIndexPage{
+			User:       user,
+			Questions:  entries[start:end],
+			Page:       page,
+			ExportedAt: exportedAt,
+		}
+		if page > 1 {
+			data.PrevURL = siteIndexPath(page - 1)
+		}
+		if page < pages {
+			data.NextURL = siteIndexPath(page + 1)
+		}
+
+		indexWriter, err := zw.Create(siteIndexPath(page))
+		if err != nil {
+			return errors.Wrap(err, "create index page")
+		}
+		if err := t.ExecuteTemplate(indexWriter, "index.html", data); err != nil {
+			return errors.Wrap(err, "render index page")
+		}
+	}
+
+	return zw.Close()
+}
+
+// siteIndexPath returns the path of the index page in the archive, the first page is the index.html.
+func siteIndexPath(page int) string {
+	if page == 1 {
+		return "index.html"
+	}
+	return fmt.Sprintf("page-%d.html", page)
+}
+
+// excerpt returns the first n characters of s, an ellipsis is appended if s is truncated.
+func excerpt(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n]) + "..."
+}
@@ -0,0 +1,187 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package export
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/NekoWheel/NekoBox/internal/db"
+)
+
+type takeoutProfile struct {
+	Email      string    `json:"email"`
+	Name       string    `json:"name"`
+	Domain     string    `json:"domain"`
+	Intro      string    `json:"intro"`
+	Avatar     string    `json:"avatar"`
+	Background string    `json:"background"`
+	CreatedAt  time.Time `json:"created_at"`
+	ExportedAt time.Time `json:"exported_at"`
+}
+
+type takeoutQuestion struct {
+	ID              uint       `json:"id"`
+	CreatedAt       time.Time  `json:"created_at"`
+	Content         string     `json:"content"`
+	Answer          string     `json:"answer"`
+	AnswerUpdatedAt *time.Time `json:"answer_updated_at"`
+}
+
+var takeoutQuestionCSVHeader = []string{"ID", "提问时间", "问题", "回答", "回答编辑时间"}
+
+func (q takeoutQuestion) csvRecord() []string {
+	var answerUpdatedAt string
+	if q.AnswerUpdatedAt != nil {
+		answerUpdatedAt = q.AnswerUpdatedAt.Format(time.RFC3339)
+	}
+	return []string{strconv.Itoa(int(q.ID)), q.CreatedAt.Format(time.RFC3339), csvText(q.Content), csvText(q.Answer), answerUpdatedAt}
+}
+
+// csvText escapes the user input written into the CSV cell. The spreadsheet applications evaluate
+// the cell starting with one of these characters as a formula, so a quote is prefixed to keep it as text.
+func csvText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// Takeout writes the ZIP archive of the user's data to w, which contains the profile,
+// the questions received and asked by the user in both JSON and CSV format.
+// The questions are streamed into the archive without being loaded into memory at once.
+func Takeout(ctx context.Context, w io.Writer, user *db.User) error {
+	zw := zip.NewWriter(w)
+
+	profile := takeoutProfile{
+		Email:      user.Email,
+		Name:       user.Name,
+		Domain:     user.Domain,
+		Intro:      user.Intro,
+		Avatar:     user.Avatar,
+		Background: user.Background,
+		CreatedAt:  user.CreatedAt,
+		ExportedAt: time.Now(),
+	}
+	profileWriter, err := zw.Create("profile.json")
+	if err != nil {
+		return errors.Wrap(err, "create profile.json")
+	}
+	encoder := json.NewEncoder(profileWriter)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(profile); err != nil {
+		return errors.Wrap(err, "encode profile")
+	}
+
+	if err := writeQuestions(ctx, zw, "questions_received", func(fn func(*db.Question) error) error {
+		return db.Questions.IterateByUserID(ctx, user.ID, fn)
+	}); err != nil {
+		return errors.Wrap(err, "write received questions")
+	}
+
+	if err := writeQuestions(ctx, zw, "questions_asked", func(fn func(*db.Question) error) error {
+		return db.Questions.IterateByAskUserID(ctx, user.ID, fn)
+	}); err != nil {
+		return errors.Wrap(err, "write asked questions")
+	}
+
+	return zw.Close()
+}
+
+// writeQuestions writes the questions into both `<name>.json` and `<name>.csv` of the archive.
+// For the ZIP writer can only write one file at a time, the questions are iterated twice.
+func writeQuestions(ctx context.Context, zw *zip.Writer, name string, iterate func(fn func(*db.Question) error) error) error {
+	jsonWriter, err := zw.Create(name + ".json")
+	if err != nil {
+		return errors.Wrap(err, "create JSON file")
+	}
+	if _, err := io.WriteString(jsonWriter, "["); err != nil {
+		return errors.Wrap(err, "write JSON")
+	}
+	first := true
+	if err := iterate(func(question *db.Question) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		raw, err := json.Marshal(newTakeoutQuestion(question))
+		if err != nil {
+			return errors.Wrap(err, "marshal question")
+		}
+		if !first {
+			if _, err := io.WriteString(jsonWriter, ","); err != nil {
+				return errors.Wrap(err, "write JSON")
+			}
+		}
+		first = false
+		_, err = jsonWriter.Write(raw)
+		return err
+	}); err != nil {
+		return errors.Wrap(err, "iterate questions for JSON")
+	}
+	if _, err := io.WriteString(jsonWriter, "]\n"); err != nil {
+		return errors.Wrap(err, "write JSON")
+	}
+
+	csvFile, err := zw.Create(name + ".csv")
+	if err != nil {
+		return errors.Wrap(err, "create CSV file")
+	}
+	// Write the UTF-8 BOM, so that Excel can recognize the encoding.
+	if _, err := io.WriteString(csvFile, "\uFEFF"); err != nil {
+		return errors.Wrap(err, "write CSV BOM")
+	}
+	csvWriter := csv.NewWriter(csvFile)
+	if err := csvWriter.Write(takeoutQuestionCSVHeader); err != nil {
+		return errors.Wrap(err, "write CSV header")
+	}
+	if err := iterate(func(question *db.Question) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return csvWriter.Write(newTakeoutQuestion(question).csvRecord())
+	}); err != nil {
+		return errors.Wrap(err, "iterate questions for CSV")
+	}
+	csvWriter.Flush()
+	return errors.Wrap(csvWriter.Error(), "flush CSV")
+}
+
+// QuestionsCSV writes the given questions to w as a CSV file in the same format as the takeout archive.
+func QuestionsCSV(w io.Writer, questions []*db.Question) error {
+	// Write the UTF-8 BOM, so that Excel can recognize the encoding.
+	if _, err := io.WriteString(w, "\uFEFF"); err != nil {
+		return errors.Wrap(err, "write CSV BOM")
+	}
+	csvWriter := csv.NewWriter(w)
+	if err := csvWriter.Write(takeoutQuestionCSVHeader); err != nil {
+		return errors.Wrap(err, "write CSV header")
+	}
+	for _, question := range questions {
+		if err := csvWriter.Write(newTakeoutQuestion(question).csvRecord()); err != nil {
+			return errors.Wrap(err, "write CSV record")
+		}
+	}
+	csvWriter.Flush()
+	return errors.Wrap(csvWriter.Error(), "flush CSV")
+}
+
+func newTakeoutQuestion(question *db.Question) takeoutQuestion {
+	return takeoutQuestion{
+		ID:              question.ID,
+		CreatedAt:       question.CreatedAt,
+		Content:         question.Content,
+		Answer:          question.Answer,
+		AnswerUpdatedAt: question.AnswerUpdatedAt,
+	}
+}
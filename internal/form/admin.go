@@ -0,0 +1,16 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package form
+
+type ResolveReports struct {
+	Status string `form:"status" valid:"required" label:"处理结果"`
+}
+
+type UpdateUserState struct {
+	State string `form:"state" valid:"required" label:"账号状态"`
+	// Days is the days of the suspension, the default days in the configuration are used if it is empty.
+	Days   string `form:"days" label:"暂停天数"`
+	Reason string `form:"reason" valid:"required;maxlen:255" label:"原因"`
+}
@@ -4,27 +4,63 @@
 
 package form
 
+import (
+	"github.com/NekoWheel/NekoBox/internal/context"
+	"github.com/NekoWheel/NekoBox/internal/security/passwordpolicy"
+)
+
 type Register struct {
 	Email          string `valid:"required;email;maxlen:100" label:"电子邮箱"`
-	Domain         string `valid:"required;alphadash;minlen:3;maxlen:20" label:"个性域名"`
+	Domain         string `valid:"required;maxlen:100" label:"个性域名"`
 	Name           string `valid:"required;maxlen:20" label:"昵称"`
 	Password       string `valid:"required;minlen:8;maxlen:30" label:"密码"`
 	RepeatPassword string `valid:"required;equal:Password" label:"重复密码"`
-	Recaptcha      string `form:"g-recaptcha-response" valid:"required" label:"Recaptcha"`
+	Captcha        string `form:"g-recaptcha-response" valid:"required" label:"验证码"`
+}
+
+// Validate checks the password against the password policy, the email, domain and name
+// are not allowed in the password.
+func (f Register) Validate(ctx context.Context) error {
+	return passwordpolicy.Validate(ctx.Request().Context(), f.Password, f.Email, f.Domain, f.Name)
 }
 
 type Login struct {
-	Email     string `valid:"required;email;maxlen:100" label:"电子邮箱"`
-	Password  string `valid:"required" label:"密码"`
-	Recaptcha string `form:"g-recaptcha-response" valid:"required" label:"Recaptcha"`
+	Email    string `valid:"required;email;maxlen:100" label:"电子邮箱"`
+	Password string `valid:"required" label:"密码"`
+	Captcha  string `form:"g-recaptcha-response" valid:"required" label:"验证码"`
+}
+
+type MagicLogin struct {
+	Email   string `valid:"required;email;maxlen:100" label:"电子邮箱"`
+	Captcha string `form:"g-recaptcha-response" valid:"required" label:"验证码"`
 }
 
 type ForgotPassword struct {
-	Email     string `valid:"required;email;maxlen:100" label:"电子邮箱"`
-	Recaptcha string `form:"g-recaptcha-response" valid:"required" label:"Recaptcha"`
+	Email   string `valid:"required;email;maxlen:100" label:"电子邮箱"`
+	Captcha string `form:"g-recaptcha-response" valid:"required" label:"验证码"`
 }
 
 type RecoverPassword struct {
 	NewPassword    string `valid:"required;minlen:8;maxlen:30" label:"新密码"`
 	RepeatPassword string `valid:"required;equal:NewPassword" label:"重复密码"`
 }
+
+// Validate checks the new password against the password policy.
+func (f RecoverPassword) Validate(ctx context.Context) error {
+	return passwordpolicy.Validate(ctx.Request().Context(), f.NewPassword)
+}
+
+type TokenLogin struct {
+	Email    string `valid:"required;email;maxlen:100" label:"电子邮箱"`
+	Password string `valid:"required" label:"密码"`
+	// Passcode is required when the user has enabled the two-factor authentication.
+	Passcode string `label:"两步验证码"`
+}
+
+type TwoFactor struct {
+	Passcode string `valid:"required;minlen:6;maxlen:6" label:"两步验证码"`
+}
+
+type TwoFactorRecovery struct {
+	RecoveryCode string `valid:"required" label:"恢复码"`
+}
@@ -5,10 +5,16 @@
 package form
 
 import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"net/url"
 	"reflect"
+	"strconv"
 
 	"github.com/flamego/flamego"
 	"github.com/flamego/template"
+	"github.com/pkg/errors"
 	"github.com/unknwon/com"
 	"github.com/wuhan005/govalid"
 
@@ -38,7 +44,9 @@ func Bind(model interface{}) flamego.Handler {
 		defer func() { c.Map(obj.Elem().Interface()) }()
 
 		r := c.Request()
-		if err := r.ParseForm(); err != nil {
+		values, err := parseValues(r.Request)
+		if err != nil {
+			c.SetError(errors.New("请求格式错误"))
 			c.Map(Error{Category: ErrorCategoryDeserialization, Error: err})
 			return
 		}
@@ -56,7 +64,7 @@ func Bind(model interface{}) flamego.Handler {
 			if fieldName == "" {
 				fieldName = com.ToSnakeCase(field.Name)
 			}
-			val.Field(i).Set(reflect.ValueOf(r.Form.Get(fieldName)))
+			val.Field(i).Set(reflect.ValueOf(values.Get(fieldName)))
 		}
 
 		errors, ok := govalid.Check(obj.Interface())
@@ -67,7 +75,59 @@ func Bind(model interface{}) flamego.Handler {
 			c.Map(Error{Category: ErrorCategoryValidation, Error: errors[0]})
 			return
 		}
+
+		if v, ok := obj.Interface().(validator); ok {
+			if err := v.Validate(c); err != nil {
+				Assign(obj.Interface(), data)
+
+				c.SetError(err)
+				c.Map(Error{Category: ErrorCategoryValidation, Error: err})
+				return
+			}
+		}
+	}
+}
+
+// validator is implemented by the forms which need the checks that the validation tags can't express,
+// the returned error is shown to the user.
+type validator interface {
+	Validate(ctx context.Context) error
+}
+
+// parseValues parses the request body into values. Both the form body and the
+// JSON object body are supported, the latter is used by the API clients.
+func parseValues(r *http.Request) (url.Values, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/json" {
+		if err := r.ParseForm(); err != nil {
+			return nil, err
+		}
+		return r.Form, nil
+	}
+
+	var body map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+
+	values := make(url.Values, len(body))
+	for key, value := range body {
+		switch v := value.(type) {
+		case nil:
+		case string:
+			values.Set(key, v)
+		case float64:
+			values.Set(key, strconv.FormatFloat(v, 'f', -1, 64))
+		case bool:
+			// Keep the same behavior as the HTML checkbox.
+			if v {
+				values.Set(key, "on")
+			}
+		default:
+			return nil, errors.Errorf("unexpected value type of %q: %T", key, v)
+		}
 	}
+	return values, nil
 }
 
 // Assign assigns form values back to the template data.
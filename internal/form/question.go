@@ -4,17 +4,102 @@
 
 package form
 
+import (
+	"strconv"
+
+	"github.com/pkg/errors"
+
+	"github.com/NekoWheel/NekoBox/internal/context"
+	"github.com/NekoWheel/NekoBox/internal/db"
+)
+
 type NewQuestion struct {
-	Content              string `form:"content" valid:"required;maxlen:1000" label:"问题内容"`
+	Content              string `form:"content" valid:"required" label:"问题内容"`
 	ReceiveReplyViaEmail string
 	ReceiveReplyEmail    string `label:"接收回复的电子邮箱"`
-	Recaptcha            string `form:"g-recaptcha-response" valid:"required" label:"Recaptcha"`
+	Captcha              string `form:"g-recaptcha-response" label:"验证码"`
+	PromptID             string `form:"prompt_id" label:"话题"`
+	PreviewToken         string `form:"preview_token"`
+	// FormToken is the signed time when the ask form is rendered, and Website is the honeypot field
+	// hidden from the humans, they are used to detect the bots.
+	FormToken string `form:"form_token"`
+	Website   string `form:"website"`
+	// Ref, the UTM parameters and Referrer are the referral of the box page view,
+	// which are passed through the hidden fields of the ask form.
+	Ref         string `form:"ref"`
+	UTMSource   string `form:"utm_source"`
+	UTMMedium   string `form:"utm_medium"`
+	UTMCampaign string `form:"utm_campaign"`
+	Referrer    string `form:"referrer"`
+}
+
+func (f NewQuestion) Validate(context.Context) error {
+	return db.ValidateQuestionLength(f.Content)
+}
+
+type SaveQuestionDraft struct {
+	Content string `form:"content" label:"问题内容"`
+}
+
+func (f SaveQuestionDraft) Validate(context.Context) error {
+	return db.ValidateQuestionLength(f.Content)
 }
 
 type PublishAnswerQuestion struct {
-	Answer string `form:"answer" valid:"required;maxlen:1000" label:"回答内容"`
+	Answer     string `form:"answer" valid:"required" label:"回答内容"`
+	Tags       string `form:"tags" valid:"maxlen:200" label:"标签"`
+	Visibility string `form:"visibility" label:"可见性"`
+	// CrosspostTwitter and CrosspostMastodon post the first answer to the linked social accounts.
+	CrosspostTwitter  string `form:"crosspost_twitter"`
+	CrosspostMastodon string `form:"crosspost_mastodon"`
+	// AppendTranslation appends the translation of the answer into the question's language.
+	AppendTranslation string `form:"append_translation"`
+	// Version is the version of the question when the answer is written, the answer is rejected if the question
+	// has been changed since then. The version is not checked if it is empty.
+	Version string `form:"version"`
+}
+
+func (f PublishAnswerQuestion) Validate(context.Context) error {
+	if f.Version != "" {
+		if _, err := strconv.ParseUint(f.Version, 10, 64); err != nil {
+			return errors.New("提问版本不合法")
+		}
+	}
+	return db.ValidateAnswerLength(f.Answer)
 }
 
 type UpdateAnswerQuestion struct {
-	Answer string `form:"answer" valid:"required;maxlen:1000" label:"回答内容"`
+	Answer     string `form:"answer" valid:"required" label:"回答内容"`
+	Tags       string `form:"tags" valid:"maxlen:200" label:"标签"`
+	Visibility string `form:"visibility" label:"可见性"`
+}
+
+func (f UpdateAnswerQuestion) Validate(context.Context) error {
+	return db.ValidateAnswerLength(f.Answer)
+}
+
+type NewQuestionReply struct {
+	Content string `form:"content" valid:"required" label:"追问内容"`
+}
+
+func (f NewQuestionReply) Validate(context.Context) error {
+	return db.ValidateQuestionLength(f.Content)
+}
+
+type ForwardQuestion struct {
+	Domain    string `form:"domain" valid:"required;maxlen:20" label:"目标提问箱"`
+	Anonymous string `form:"anonymous"`
+}
+
+type MergeQuestion struct {
+	Target string `form:"target" valid:"required;maxlen:200" label:"目标提问"`
+}
+
+type UpdateQuestionNote struct {
+	Note string `form:"note" valid:"maxlen:1000" label:"私密备注"`
+}
+
+type ReportQuestion struct {
+	Reason string `form:"reason" valid:"required" label:"举报原因"`
+	Detail string `form:"detail" valid:"maxlen:500" label:"补充说明"`
 }
@@ -4,14 +4,167 @@
 
 package form
 
+import (
+	"github.com/pkg/errors"
+
+	"github.com/NekoWheel/NekoBox/internal/context"
+	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/security/passwordpolicy"
+)
+
 type UpdateProfile struct {
 	Name        string `valid:"required;maxlen:20" label:"昵称"`
 	OldPassword string `label:"旧密码"`
 	NewPassword string `valid:"maxlen:30" label:"新密码"`
 	Intro       string `valid:"required;maxlen:100" label:"介绍"`
 	NotifyEmail string `label:"开启邮箱通知"`
+	// DigestFrequency is kept unchanged when it is empty.
+	DigestFrequency string `label:"通知频率"`
+	// ReminderDays is kept unchanged when it is empty.
+	ReminderDays string `label:"未回答提醒"`
+	// Locale is kept unchanged when it is empty.
+	Locale string `label:"界面语言"`
+}
+
+// Validate checks the new password against the password policy if the password is changed.
+func (f UpdateProfile) Validate(ctx context.Context) error {
+	if f.NewPassword == "" {
+		return nil
+	}
+	if len(f.NewPassword) < 8 {
+		return errors.New("新密码长度应大于8")
+	}
+
+	var userInputs []string
+	if ctx.IsLogged {
+		userInputs = append(userInputs, ctx.User.Email, ctx.User.Domain, ctx.User.Name)
+	}
+	return passwordpolicy.Validate(ctx.Request().Context(), f.NewPassword, userInputs...)
 }
 
 type UpdateHarassment struct {
 	RegisterOnly string `label:"仅允许注册用户"`
 }
+
+type NewPrompt struct {
+	Content string `valid:"required;maxlen:200" label:"话题内容"`
+}
+
+type NewWordFilter struct {
+	Pattern string `valid:"required;maxlen:100" label:"屏蔽词"`
+	IsRegex string `label:"使用正则表达式"`
+	Action  string `valid:"required" label:"处理方式"`
+}
+
+type AnswerTemplate struct {
+	Title   string `valid:"required;maxlen:50" label:"标题"`
+	Content string `valid:"required" label:"内容"`
+}
+
+func (f AnswerTemplate) Validate(context.Context) error {
+	return db.ValidateAnswerLength(f.Content)
+}
+
+type LinkMastodon struct {
+	Instance    string `valid:"required;maxlen:255" label:"实例地址"`
+	AccessToken string `valid:"required;maxlen:255" label:"访问令牌"`
+}
+
+type NewBoxMember struct {
+	Domain string `valid:"required" label:"个性域名"`
+	Role   string `valid:"required" label:"角色"`
+}
+
+type UpdateBoxMember struct {
+	Role string `valid:"required" label:"角色"`
+}
+
+type ImportQuestions struct {
+	Source string `valid:"required" label:"导入来源"`
+}
+
+type BulkQuestions struct {
+	Action string `valid:"required" label:"操作"`
+}
+
+type NewWebhook struct {
+	URL    string `valid:"required;maxlen:255" label:"Webhook 地址"`
+	Secret string `valid:"maxlen:64" label:"签名密钥"`
+}
+
+type ChangeEmail struct {
+	Email    string `valid:"required;email;maxlen:100" label:"新邮箱"`
+	Password string `valid:"required" label:"密码"`
+}
+
+type DeleteProfile struct {
+	Password string `valid:"required" label:"密码"`
+}
+
+type UpdateBoxSettings struct {
+	QuestionPlaceholder string `valid:"maxlen:100" label:"提问框提示文字"`
+	QuestionMinLength   string `label:"提问最小长度"`
+	QuestionMaxLength   string `label:"提问最大长度"`
+	AnswerMaxLength     string `label:"回答最大长度"`
+	DailyAskLimit       string `label:"每日提问次数限制"`
+	ShowReplyEmail      string `label:"显示接收回复邮箱"`
+	EnableMarkdown      string `label:"回答使用 Markdown"`
+	QuestionMarkdown    string `label:"提问使用 Markdown"`
+	AutoArchiveDays     string `label:"自动归档"`
+	QueueMode           string `label:"排队模式"`
+	DailyQuota          string `label:"每日回答数量"`
+	MaskProfanity       string `label:"遮挡不文明用语"`
+	EmbedOrigins        string `valid:"maxlen:1000" label:"允许嵌入的网站"`
+	ShowAchievements    string `label:"展示成就"`
+}
+
+type UpdateAwayStatus struct {
+	Away       string `label:"离开状态"`
+	ReturnDate string `label:"返回日期"`
+	Message    string `valid:"maxlen:200" label:"离开说明"`
+	DisableAsk string `label:"暂停接收提问"`
+}
+
+type DisableTwoFactor struct {
+	Password string `valid:"required" label:"密码"`
+}
+
+type RegenerateRecoveryCodes struct {
+	Password string `valid:"required" label:"密码"`
+}
+
+type RenameTag struct {
+	Name    string `valid:"required" label:"标签"`
+	NewName string `valid:"required;maxlen:20" label:"新标签名"`
+}
+
+type DeleteTag struct {
+	Name string `valid:"required" label:"标签"`
+}
+
+type SubscribePush struct {
+	Endpoint string `valid:"required;maxlen:1024" label:"推送地址"`
+	P256dh   string `valid:"required;maxlen:255" label:"公钥"`
+	Auth     string `valid:"required;maxlen:255" label:"认证密钥"`
+}
+
+type UnsubscribePush struct {
+	Endpoint string `valid:"required;maxlen:1024" label:"推送地址"`
+}
+
+type UpdateProfileTheme struct {
+	Theme                 string `valid:"required;maxlen:20" label:"主题"`
+	CustomAccentColor     string `label:"自定义强调色"`
+	AccentColor           string `valid:"maxlen:7" label:"强调色"`
+	RemoveBackgroundImage string `label:"移除背景图"`
+}
+
+type UpdateContentLicense struct {
+	License string `valid:"maxlen:32" label:"授权协议"`
+}
+
+type NewAccessToken struct {
+	Name          string `form:"name" valid:"required;maxlen:50" label:"令牌名称"`
+	ReadQuestions string `form:"read_questions"`
+	WriteAnswers  string `form:"write_answers"`
+}
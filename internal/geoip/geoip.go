@@ -0,0 +1,102 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package geoip resolves the IP addresses of the askers to the coarse regions with the GeoLite2 database,
+// so the box owners can spot the harassment from the same region without seeing the IP addresses.
+package geoip
+
+import (
+	"net"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/conf"
+)
+
+var (
+	db     *reader
+	dbOnce sync.Once
+)
+
+// load loads the database on the first use, the regions are not resolved if the database is not configured.
+func load() *reader {
+	dbOnce.Do(func() {
+		path := conf.Security.GeoIPDatabase
+		if path == "" {
+			return
+		}
+
+		buf, err := os.ReadFile(path)
+		if err != nil {
+			logrus.WithError(err).WithField("path", path).Error("Failed to read GeoIP database")
+			return
+		}
+		db, err = newReader(buf)
+		if err != nil {
+			logrus.WithError(err).WithField("path", path).Error("Failed to load GeoIP database")
+			return
+		}
+	})
+	return db
+}
+
+// languages are the preferred languages of the region names.
+var languages = []string{"zh-CN", "en"}
+
+// Region returns the country and the province of the IP address such as "中国 浙江",
+// it returns an empty string if the region is unknown.
+func Region(ip string) string {
+	r := load()
+	if r == nil {
+		return ""
+	}
+
+	parsedIP := net.ParseIP(strings.TrimSpace(ip))
+	if parsedIP == nil || parsedIP.IsLoopback() || parsedIP.IsPrivate() || parsedIP.IsUnspecified() {
+		return ""
+	}
+
+	value, err := r.lookup(parsedIP)
+	if err != nil {
+		logrus.WithError(err).WithField("ip", ip).Error("Failed to look up GeoIP database")
+		return ""
+	}
+	record, ok := value.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+
+	var parts []string
+	if country := name(record["country"]); country != "" {
+		parts = append(parts, country)
+	}
+	// Only the first level of the subdivisions is kept, the cities are too precise to be shown.
+	if subdivisions, ok := record["subdivisions"].([]interface{}); ok && len(subdivisions) > 0 {
+		if subdivision := name(subdivisions[0]); subdivision != "" {
+			parts = append(parts, subdivision)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// name returns the localized name of the place record.
+func name(v interface{}) string {
+	place, ok := v.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	names, ok := place["names"].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	for _, language := range languages {
+		if name, ok := names[language].(string); ok && name != "" {
+			return name
+		}
+	}
+	return ""
+}
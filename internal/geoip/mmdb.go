@@ -0,0 +1,302 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"net"
+
+	"github.com/pkg/errors"
+)
+
+// The GeoLite2 databases are in the MaxMind DB format, which is a binary search tree of the IP
+// address bits followed by the data section. Only the lookups are implemented, see
+// https://maxmind.github.io/MaxMind-DB/ for the specification.
+
+var metadataMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+
+// dataSectionSeparator is the size of the zero bytes between the search tree and the data section.
+const dataSectionSeparator = 16
+
+type reader struct {
+	tree      []byte
+	data      decoder
+	nodeCount uint
+	// recordSize is the bits of a record, a node is a pair of the records.
+	recordSize uint
+	ipVersion  uint
+	// ipv4Start is the node of the IPv4 addresses in the IPv6 tree, which is "::/96".
+	ipv4Start uint
+}
+
+func newReader(buf []byte) (*reader, error) {
+	markerIndex := bytes.LastIndex(buf, metadataMarker)
+	if markerIndex < 0 {
+		return nil, errors.New("metadata is not found")
+	}
+
+	metadata := decoder{buf: buf[markerIndex+len(metadataMarker):]}
+	value, _, err := metadata.decode(0)
+	if err != nil {
+		return nil, errors.Wrap(err, "decode metadata")
+	}
+	fields, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("metadata is not a map")
+	}
+
+	r := &reader{
+		nodeCount:  toUint(fields["node_count"]),
+		recordSize: toUint(fields["record_size"]),
+		ipVersion:  toUint(fields["ip_version"]),
+	}
+	if r.recordSize != 24 && r.recordSize != 28 && r.recordSize != 32 {
+		return nil, errors.Errorf("unsupported record size %d", r.recordSize)
+	}
+
+	treeSize := r.nodeCount * r.recordSize / 4
+	if treeSize+dataSectionSeparator > uint(markerIndex) {
+		return nil, errors.New("search tree is out of range")
+	}
+	r.tree = buf[:treeSize]
+	r.data = decoder{buf: buf[treeSize+dataSectionSeparator : markerIndex]}
+
+	if r.ipVersion == 6 {
+		for i := 0; i < 96 && r.ipv4Start < r.nodeCount; i++ {
+			r.ipv4Start = r.record(r.ipv4Start, 0)
+		}
+	}
+	return r, nil
+}
+
+// record returns the left (bit 0) or the right (bit 1) record of the node.
+func (r *reader) record(node uint, bit byte) uint {
+	offset := node * r.recordSize / 4
+	b := r.tree[offset : offset+r.recordSize/4]
+	switch r.recordSize {
+	case 24:
+		if bit == 0 {
+			return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3])<<16 | uint(b[4])<<8 | uint(b[5])
+	case 28:
+		if bit == 0 {
+			return uint(b[3]&0xF0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0F)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		if bit == 0 {
+			return uint(binary.BigEndian.Uint32(b[:4]))
+		}
+		return uint(binary.BigEndian.Uint32(b[4:]))
+	}
+}
+
+// lookup returns the data of the IP address, it returns nil if the address is not in the database.
+func (r *reader) lookup(ip net.IP) (interface{}, error) {
+	node := uint(0)
+	if ipv4 := ip.To4(); ipv4 != nil {
+		ip = ipv4
+		node = r.ipv4Start
+	} else if r.ipVersion == 4 {
+		return nil, nil
+	}
+
+	for i := 0; i < len(ip)*8 && node < r.nodeCount; i++ {
+		bit := (ip[i/8] >> (7 - uint(i%8))) & 1
+		node = r.record(node, bit)
+	}
+
+	switch {
+	case node == r.nodeCount:
+		return nil, nil
+	case node < r.nodeCount:
+		return nil, errors.New("invalid search tree")
+	}
+
+	value, _, err := r.data.decode(node - r.nodeCount - dataSectionSeparator)
+	if err != nil {
+		return nil, errors.Wrap(err, "decode data")
+	}
+	return value, nil
+}
+
+const (
+	typeExtended = iota
+	typePointer
+	typeString
+	typeDouble
+	typeBytes
+	typeUint16
+	typeUint32
+	typeMap
+	typeInt32
+	typeUint64
+	typeUint128
+	typeArray
+	typeContainer
+	typeEndMarker
+	typeBool
+	typeFloat
+)
+
+// decoder decodes the values of the data section, the pointers are offsets from the beginning of the buffer.
+type decoder struct {
+	buf []byte
+}
+
+var errOutOfRange = errors.New("data is out of range")
+
+// decode decodes the value at the offset, it returns the offset of the next value.
+func (d decoder) decode(offset uint) (interface{}, uint, error) {
+	if offset >= uint(len(d.buf)) {
+		return nil, 0, errOutOfRange
+	}
+	ctrl := d.buf[offset]
+	offset++
+
+	typ := uint(ctrl >> 5)
+	if typ == typePointer {
+		pointer, next, err := d.pointer(ctrl, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		value, _, err := d.decode(pointer)
+		return value, next, err
+	}
+	if typ == typeExtended {
+		if offset >= uint(len(d.buf)) {
+			return nil, 0, errOutOfRange
+		}
+		typ = 7 + uint(d.buf[offset])
+		offset++
+	}
+
+	size := uint(ctrl & 0x1F)
+	if size >= 29 {
+		n := size - 28
+		if offset+n > uint(len(d.buf)) {
+			return nil, 0, errOutOfRange
+		}
+		extra := uintFromBytes(d.buf[offset : offset+n])
+		offset += n
+		switch size {
+		case 29:
+			size = 29 + extra
+		case 30:
+			size = 285 + extra
+		default:
+			size = 65821 + extra
+		}
+	}
+
+	switch typ {
+	case typeMap:
+		m := make(map[string]interface{}, size)
+		for i := uint(0); i < size; i++ {
+			key, next, err := d.decode(offset)
+			if err != nil {
+				return nil, 0, errors.Wrap(err, "decode map key")
+			}
+			value, next, err := d.decode(next)
+			if err != nil {
+				return nil, 0, errors.Wrap(err, "decode map value")
+			}
+			keyString, ok := key.(string)
+			if !ok {
+				return nil, 0, errors.Errorf("unexpected map key type %T", key)
+			}
+			m[keyString] = value
+			offset = next
+		}
+		return m, offset, nil
+	case typeArray:
+		array := make([]interface{}, 0, size)
+		for i := uint(0); i < size; i++ {
+			value, next, err := d.decode(offset)
+			if err != nil {
+				return nil, 0, errors.Wrap(err, "decode array element")
+			}
+			array = append(array, value)
+			offset = next
+		}
+		return array, offset, nil
+	case typeBool:
+		return size != 0, offset, nil
+	case typeContainer, typeEndMarker:
+		return nil, offset, nil
+	}
+
+	if offset+size > uint(len(d.buf)) {
+		return nil, 0, errOutOfRange
+	}
+	b := d.buf[offset : offset+size]
+	offset += size
+
+	switch typ {
+	case typeString:
+		return string(b), offset, nil
+	case typeBytes:
+		return append([]byte(nil), b...), offset, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, errors.Errorf("invalid double size %d", size)
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), offset, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, errors.Errorf("invalid float size %d", size)
+		}
+		return math.Float32frombits(binary.BigEndian.Uint32(b)), offset, nil
+	case typeUint16, typeUint32, typeUint64:
+		return uint64(uintFromBytes(b)), offset, nil
+	case typeInt32:
+		return int32(uintFromBytes(b)), offset, nil
+	case typeUint128:
+		// The 128-bit integers are not used by the lookups, they are kept as the raw bytes.
+		return append([]byte(nil), b...), offset, nil
+	}
+	return nil, 0, errors.Errorf("unexpected data type %d", typ)
+}
+
+// pointer returns the offset pointed by the pointer value, and the offset after the pointer.
+func (d decoder) pointer(ctrl byte, offset uint) (uint, uint, error) {
+	n := uint(ctrl>>3&0x3) + 1
+	if offset+n > uint(len(d.buf)) {
+		return 0, 0, errOutOfRange
+	}
+	b := d.buf[offset : offset+n]
+	value := uint(ctrl & 0x7)
+
+	var pointer uint
+	switch n {
+	case 1:
+		pointer = value<<8 | uintFromBytes(b)
+	case 2:
+		pointer = (value<<16 | uintFromBytes(b)) + 2048
+	case 3:
+		pointer = (value<<24 | uintFromBytes(b)) + 526336
+	default:
+		pointer = uintFromBytes(b)
+	}
+	return pointer, offset + n, nil
+}
+
+func uintFromBytes(b []byte) uint {
+	var v uint
+	for _, c := range b {
+		v = v<<8 | uint(c)
+	}
+	return v
+}
+
+func toUint(v interface{}) uint {
+	if n, ok := v.(uint64); ok {
+		return uint(n)
+	}
+	return 0
+}
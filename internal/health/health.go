@@ -0,0 +1,162 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package health checks the dependencies of the instance for the readiness probes,
+// so the deployments only route the traffic to the instances which can serve it.
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+
+	"github.com/NekoWheel/NekoBox/internal/conf"
+	"github.com/NekoWheel/NekoBox/internal/db"
+)
+
+// checkTimeout is the timeout of a single check, the probes of Kubernetes time out in 1 second by default.
+const checkTimeout = 800 * time.Millisecond
+
+// Check checks a dependency, the details such as the queue depth are reported even if the check fails.
+type Check func(ctx context.Context) (details map[string]interface{}, err error)
+
+var checks = struct {
+	sync.RWMutex
+	names []string
+	funcs map[string]Check
+}{
+	funcs: make(map[string]Check),
+}
+
+// Register registers the check with the given name, the check with the same name is replaced.
+func Register(name string, check Check) {
+	checks.Lock()
+	defer checks.Unlock()
+
+	if _, ok := checks.funcs[name]; !ok {
+		checks.names = append(checks.names, name)
+	}
+	checks.funcs[name] = check
+}
+
+const (
+	StatusOK          = "ok"
+	StatusUnavailable = "unavailable"
+)
+
+// Result is the result of a check.
+type Result struct {
+	Status   string                 `json:"status"`
+	Duration int64                  `json:"duration_ms"`
+	Error    string                 `json:"error,omitempty"`
+	Details  map[string]interface{} `json:"details,omitempty"`
+}
+
+// Report is the results of all the checks.
+type Report struct {
+	Status string             `json:"status"`
+	Checks map[string]*Result `json:"checks"`
+}
+
+// Ready runs all the checks concurrently, the instance is ready only if all the checks pass.
+func Ready(ctx context.Context) *Report {
+	checks.RLock()
+	names := append([]string(nil), checks.names...)
+	funcs := make([]Check, 0, len(names))
+	for _, name := range names {
+		funcs = append(funcs, checks.funcs[name])
+	}
+	checks.RUnlock()
+
+	results := make([]*Result, len(names))
+	var wg sync.WaitGroup
+	for i := range funcs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = run(ctx, funcs[i])
+		}(i)
+	}
+	wg.Wait()
+
+	report := &Report{
+		Status: StatusOK,
+		Checks: make(map[string]*Result, len(names)),
+	}
+	for i, name := range names {
+		report.Checks[name] = results[i]
+		if results[i].Status != StatusOK {
+			report.Status = StatusUnavailable
+		}
+	}
+	return report
+}
+
+func run(ctx context.Context, check Check) *Result {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	start := time.Now()
+	details, err := check(ctx)
+	result := &Result{
+		Status:   StatusOK,
+		Duration: time.Since(start).Milliseconds(),
+		Details:  details,
+	}
+	if err != nil {
+		result.Status = StatusUnavailable
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// Database checks the connection to the primary database.
+func Database(gdb *gorm.DB) Check {
+	return func(ctx context.Context) (map[string]interface{}, error) {
+		sqlDB, err := gdb.DB()
+		if err != nil {
+			return nil, errors.Wrap(err, "get database connection")
+		}
+		if err := sqlDB.PingContext(ctx); err != nil {
+			return nil, errors.Wrap(err, "ping")
+		}
+		stats := sqlDB.Stats()
+		return map[string]interface{}{
+			"open_connections": stats.OpenConnections,
+			"in_use":           stats.InUse,
+		}, nil
+	}
+}
+
+// Redis checks the connection to Redis, which keeps the sessions, the cache and the rate limits.
+func Redis(addr, password string) Check {
+	client := redis.NewClient(&redis.Options{
+		Addr:     addr,
+		Password: password,
+	})
+	return func(ctx context.Context) (map[string]interface{}, error) {
+		if err := client.Ping(ctx).Err(); err != nil {
+			return nil, errors.Wrap(err, "ping")
+		}
+		return nil, nil
+	}
+}
+
+// MailQueue checks the depth of the mail outbox, the deep queue means the mail provider is down.
+func MailQueue(ctx context.Context) (map[string]interface{}, error) {
+	count, err := db.MailOutbox.CountPending(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "count pending mails")
+	}
+
+	details := map[string]interface{}{"pending": count}
+	if max := conf.Mail.MaxQueueDepth; max > 0 && count > max {
+		return details, errors.Errorf("%d pending mails exceed the max queue depth %d", count, max)
+	}
+	return details, nil
+}
@@ -0,0 +1,109 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package i18n
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/NekoWheel/NekoBox/internal/conf"
+	"github.com/NekoWheel/NekoBox/internal/crosspost"
+	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/security/boxdomain"
+)
+
+type errorMessage struct {
+	err       error
+	messageID string
+	data      map[string]interface{}
+	// dataFunc returns the data of the message which depends on the configuration,
+	// it takes precedence over the data.
+	dataFunc func() map[string]interface{}
+}
+
+// errorMessages maps the sentinel errors of the db layer to the messages shown to the users.
+var errorMessages = []errorMessage{
+	{err: db.ErrBlockExists, messageID: "error.block_exists"},
+	{err: db.ErrBlockNotExist, messageID: "error.block_not_exist"},
+	{err: db.ErrBlockNoSource, messageID: "error.block_no_source"},
+	{err: db.ErrWordFilterNotExist, messageID: "error.word_filter_not_exist"},
+	{err: db.ErrTooManyWordFilters, messageID: "error.too_many_word_filters", data: map[string]interface{}{"Max": db.MaxWordFiltersPerUser}},
+	{err: db.ErrInvalidWordFilter, messageID: "error.invalid_word_filter", data: map[string]interface{}{"Max": db.MaxWordFilterPatternLength}},
+	{err: db.ErrInvalidWordFilterRegex, messageID: "error.invalid_word_filter_regex"},
+	{err: db.ErrInvalidWordFilterAction, messageID: "error.invalid_word_filter_action"},
+	{err: db.ErrPromptNotExist, messageID: "error.prompt_not_exist"},
+	{err: db.ErrTooManyPrompts, messageID: "error.too_many_prompts", data: map[string]interface{}{"Max": db.MaxPromptsPerUser}},
+	{err: db.ErrAnswerTemplateNotExist, messageID: "error.answer_template_not_exist"},
+	{err: db.ErrTooManyAnswerTemplates, messageID: "error.too_many_answer_templates", data: map[string]interface{}{"Max": db.MaxAnswerTemplatesPerUser}},
+	{err: db.ErrInvalidAnswerTemplate, messageID: "error.invalid_answer_template", data: map[string]interface{}{"Max": db.MaxAnswerTemplateTitleLength}},
+	{err: db.ErrCrosspostAccountNotExist, messageID: "error.crosspost_account_not_exist"},
+	{err: crosspost.ErrTwitterAuthorization, messageID: "error.twitter_authorization"},
+	{err: crosspost.ErrInvalidMastodonInstance, messageID: "error.invalid_mastodon_instance"},
+	{err: crosspost.ErrInvalidMastodonToken, messageID: "error.invalid_mastodon_token"},
+	{err: db.ErrQuestionDraftNotExist, messageID: "error.question_draft_not_exist"},
+	{err: db.ErrReactionExists, messageID: "error.reaction_exists"},
+	{err: db.ErrReactionNoSource, messageID: "error.reaction_no_source"},
+	{err: db.ErrTooManyTags, messageID: "error.too_many_tags", data: map[string]interface{}{"Max": db.MaxTagsPerQuestion}},
+	{err: db.ErrTagNameTooLong, messageID: "error.tag_name_too_long", data: map[string]interface{}{"Max": db.MaxTagNameLength}},
+	{err: db.ErrTagNotExist, messageID: "error.tag_not_exist"},
+	{err: db.ErrQuestionNotExist, messageID: "error.question_not_exist"},
+	{err: db.ErrQuestionNotAnswered, messageID: "error.question_not_answered"},
+	{err: db.ErrInvalidQuestionVisibility, messageID: "error.invalid_question_visibility"},
+	{err: db.ErrReportExists, messageID: "error.report_exists"},
+	{err: db.ErrReportNoSource, messageID: "error.report_no_source"},
+	{err: db.ErrInvalidReportReason, messageID: "error.invalid_report_reason"},
+	{err: db.ErrTooManyPinnedQuestions, messageID: "error.too_many_pinned_questions", data: map[string]interface{}{"Max": db.MaxPinnedQuestions}},
+	{err: db.ErrTwoFactorExists, messageID: "error.two_factor_exists"},
+	{err: db.ErrTwoFactorNotExist, messageID: "error.two_factor_not_exist"},
+	{err: db.ErrTwoFactorRecoveryCodeBad, messageID: "error.two_factor_recovery_code_bad"},
+	{err: db.ErrInvalidBoxSettings, messageID: "error.invalid_box_settings", dataFunc: func() map[string]interface{} {
+		return map[string]interface{}{"Max": conf.Limits.QuestionMaxLength}
+	}},
+	{err: db.ErrQuestionTooLong, messageID: "error.question_too_long", dataFunc: func() map[string]interface{} {
+		return map[string]interface{}{"Max": conf.Limits.QuestionMaxLength}
+	}},
+	{err: db.ErrAnswerTooLong, messageID: "error.answer_too_long", dataFunc: func() map[string]interface{} {
+		return map[string]interface{}{"Max": conf.Limits.AnswerMaxLength}
+	}},
+	{err: db.ErrInvalidProfileSettings, messageID: "error.invalid_profile_settings"},
+	{err: db.ErrInvalidContentLicense, messageID: "error.invalid_content_license"},
+	{err: db.ErrQuestionVersionConflict, messageID: "error.question_version_conflict"},
+	{err: db.ErrUserNotExists, messageID: "error.user_not_exists"},
+	{err: db.ErrBadCredential, messageID: "error.bad_credential"},
+	{err: db.ErrDuplicateEmail, messageID: "error.duplicate_email"},
+	{err: db.ErrDuplicateDomain, messageID: "error.duplicate_domain"},
+	{err: db.ErrUserSuspended, messageID: "error.user_suspended"},
+	{err: db.ErrUserBanned, messageID: "error.user_banned"},
+	{err: boxdomain.ErrInvalid, messageID: "error.invalid_domain", data: map[string]interface{}{"Min": boxdomain.MinLength, "Max": boxdomain.MaxLength}},
+	{err: boxdomain.ErrReserved, messageID: "error.reserved_domain"},
+	{err: db.ErrWebhookNotExist, messageID: "error.webhook_not_exist"},
+	{err: db.ErrTooManyWebhooks, messageID: "error.too_many_webhooks", data: map[string]interface{}{"Max": db.MaxWebhooksPerUser}},
+	{err: db.ErrWebhookAlreadyExist, messageID: "error.webhook_already_exist"},
+	{err: db.ErrAccessTokenNotExist, messageID: "error.access_token_not_exist"},
+	{err: db.ErrUserSessionNotExist, messageID: "error.user_session_not_exist"},
+	{err: db.ErrBoxMemberExists, messageID: "error.box_member_exists"},
+	{err: db.ErrBoxMemberNotExist, messageID: "error.box_member_not_exist"},
+	{err: db.ErrTooManyBoxMembers, messageID: "error.too_many_box_members", data: map[string]interface{}{"Max": db.MaxBoxMembersPerBox}},
+	{err: db.ErrInvalidBoxMemberRole, messageID: "error.invalid_box_member_role"},
+	{err: db.ErrLoginLinkNotExist, messageID: "error.login_link_not_exist"},
+	{err: db.ErrLoginLinkExpired, messageID: "error.login_link_expired"},
+	{err: db.ErrLoginLinkConsumed, messageID: "error.login_link_consumed"},
+	{err: db.ErrTooManyAccessTokens, messageID: "error.too_many_access_tokens", data: map[string]interface{}{"Max": db.MaxAccessTokensPerUser}},
+	{err: db.ErrInvalidAccessTokenScope, messageID: "error.invalid_access_token_scope"},
+}
+
+// Error returns the translated message of the error. The message of the root cause
+// is returned as is if the error is not a known sentinel error.
+func (l *Locale) Error(err error) string {
+	for _, m := range errorMessages {
+		if errors.Is(err, m.err) {
+			data := m.data
+			if m.dataFunc != nil {
+				data = m.dataFunc()
+			}
+			return l.Tr(m.messageID, data)
+		}
+	}
+	return errors.Cause(err).Error()
+}
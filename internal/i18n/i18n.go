@@ -0,0 +1,110 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package i18n contains the locale bundles and translates the messages into the user's language.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"path"
+
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"golang.org/x/text/language"
+)
+
+//go:embed locales/*.json
+var localeFS embed.FS
+
+// Language is a language supported by the locale bundles.
+type Language struct {
+	Tag  string
+	Name string
+}
+
+// Languages are the supported languages, the first one is the default language.
+var Languages = []Language{
+	{Tag: "zh-CN", Name: "简体中文"},
+	{Tag: "en-US", Name: "English"},
+}
+
+var (
+	bundle  *i18n.Bundle
+	matcher language.Matcher
+)
+
+func init() {
+	bundle = i18n.NewBundle(language.MustParse(Languages[0].Tag))
+	bundle.RegisterUnmarshalFunc("json", json.Unmarshal)
+
+	tags := make([]language.Tag, 0, len(Languages))
+	for _, lang := range Languages {
+		data, err := localeFS.ReadFile(path.Join("locales", lang.Tag+".json"))
+		if err != nil {
+			panic("read locale file: " + err.Error())
+		}
+		bundle.MustParseMessageFileBytes(data, lang.Tag+".json")
+		tags = append(tags, language.MustParse(lang.Tag))
+	}
+	matcher = language.NewMatcher(tags)
+}
+
+// IsSupported returns whether the given language tag is one of the supported languages.
+func IsSupported(tag string) bool {
+	for _, lang := range Languages {
+		if lang.Tag == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Locale translates the messages into the negotiated language.
+type Locale struct {
+	lang      string
+	localizer *i18n.Localizer
+}
+
+// NewLocale negotiates the language with the given preferences in order, each preference can
+// be a language tag or an `Accept-Language` header value. The default language is used if none
+// of the preferences is supported.
+func NewLocale(preferences ...string) *Locale {
+	lang := Languages[0].Tag
+	for _, preference := range preferences {
+		if preference == "" {
+			continue
+		}
+		tags, _, err := language.ParseAcceptLanguage(preference)
+		if err != nil || len(tags) == 0 {
+			continue
+		}
+		if _, index, confidence := matcher.Match(tags...); confidence != language.No {
+			lang = Languages[index].Tag
+			break
+		}
+	}
+
+	return &Locale{
+		lang:      lang,
+		localizer: i18n.NewLocalizer(bundle, lang),
+	}
+}
+
+// Lang returns the negotiated language tag.
+func (l *Locale) Lang() string {
+	return l.lang
+}
+
+// Tr returns the translated message, the message ID itself is returned if it is not found.
+func (l *Locale) Tr(messageID string, data ...map[string]interface{}) string {
+	config := &i18n.LocalizeConfig{MessageID: messageID}
+	if len(data) > 0 {
+		config.TemplateData = data[0]
+	}
+	message, err := l.localizer.Localize(config)
+	if err != nil {
+		return messageID
+	}
+	return message
+}
@@ -0,0 +1,91 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// csvColumns are the accepted header names of the columns, the header names
+// differ between the services and the languages of the exports.
+type csvColumns struct {
+	Content   []string
+	Answer    []string
+	CreatedAt []string
+}
+
+// parseCSV parses the CSV file with a header row, the columns are located by the header names.
+func parseCSV(r io.Reader, columns csvColumns) ([]Question, error) {
+	reader := bufio.NewReader(r)
+	// Skip the UTF-8 BOM added by the spreadsheet applications.
+	if bom, err := reader.Peek(3); err == nil && bytes.Equal(bom, []byte{0xEF, 0xBB, 0xBF}) {
+		_, _ = reader.Discard(3)
+	}
+
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	csvReader.LazyQuotes = true
+
+	header, err := csvReader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, ErrEmptyFile
+		}
+		return nil, errors.Wrap(ErrInvalidFile, err.Error())
+	}
+
+	contentIndex := columnIndex(header, columns.Content)
+	if contentIndex < 0 {
+		return nil, errors.Errorf("导入文件缺少提问内容列，应为 %s 之一", strings.Join(columns.Content, "、"))
+	}
+	answerIndex := columnIndex(header, columns.Answer)
+	createdAtIndex := columnIndex(header, columns.CreatedAt)
+
+	var questions []Question
+	for {
+		record, err := csvReader.Read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, errors.Wrap(ErrInvalidFile, err.Error())
+		}
+		if len(questions) > MaxQuestions {
+			return nil, ErrTooManyRows
+		}
+
+		questions = append(questions, Question{
+			Content:   field(record, contentIndex),
+			Answer:    field(record, answerIndex),
+			CreatedAt: parseTime(field(record, createdAtIndex)),
+		})
+	}
+	return questions, nil
+}
+
+func columnIndex(header []string, names []string) int {
+	for i, column := range header {
+		column = strings.ToLower(strings.TrimSpace(column))
+		for _, name := range names {
+			if column == name {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func field(record []string, index int) string {
+	if index < 0 || index >= len(record) {
+		return ""
+	}
+	return record[index]
+}
@@ -0,0 +1,137 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package importer parses the export files of the other Q&A box services and NekoBox instances,
+// so the users migrating to NekoBox can keep their answered questions.
+package importer
+
+import (
+	"context"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/NekoWheel/NekoBox/internal/db"
+)
+
+// Source is the Q&A box service which the export file comes from.
+type Source string
+
+const (
+	SourcePeing       Source = "peing"
+	SourceTellonym    Source = "tellonym"
+	SourceMarshmallow Source = "marshmallow"
+	// SourceNekoBox is the JSONL interchange format exported by NekoBox, see export.JSONLQuestion.
+	SourceNekoBox Source = "nekobox"
+)
+
+const (
+	// MaxFileSize is the max size of the export file which is 10MB.
+	MaxFileSize = 10 * 1024 * 1024
+	// MaxQuestions is the maximum number of the questions in an export file.
+	MaxQuestions = 10000
+)
+
+var (
+	ErrUnknownSource = errors.New("不支持的导入来源")
+	ErrInvalidFile   = errors.New("导入文件的格式不正确")
+	ErrEmptyFile     = errors.New("导入文件中没有找到提问")
+	ErrTooManyRows   = errors.New("导入文件中的提问太多了，每次最多导入 10000 个提问")
+)
+
+// Question is a question parsed from the export file.
+type Question struct {
+	Content   string
+	Answer    string
+	CreatedAt time.Time
+	// The fields below are only given by the NekoBox exports.
+	AnsweredAt      *time.Time
+	AnswerUpdatedAt *time.Time
+	Visibility      db.QuestionVisibility
+	Tags            []string
+}
+
+type parser func(r io.Reader) ([]Question, error)
+
+var parsers = map[Source]parser{
+	SourcePeing:       parsePeing,
+	SourceTellonym:    parseTellonym,
+	SourceMarshmallow: parseMarshmallow,
+	SourceNekoBox:     parseNekoBox,
+}
+
+// Parse parses the export file of the source, the questions without content are skipped.
+func Parse(source Source, r io.Reader) ([]Question, error) {
+	parse, ok := parsers[source]
+	if !ok {
+		return nil, ErrUnknownSource
+	}
+
+	questions, err := parse(r)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed := make([]Question, 0, len(questions))
+	for _, question := range questions {
+		question.Content = strings.TrimSpace(question.Content)
+		question.Answer = strings.TrimSpace(question.Answer)
+		if question.Content == "" {
+			continue
+		}
+		parsed = append(parsed, question)
+	}
+	if len(parsed) == 0 {
+		return nil, ErrEmptyFile
+	}
+	if len(parsed) > MaxQuestions {
+		return nil, ErrTooManyRows
+	}
+	return parsed, nil
+}
+
+// Import saves the parsed questions to the user's box, and returns the number of the imported questions.
+// The questions which have been imported before are skipped.
+func Import(ctx context.Context, userID uint, questions []Question) (int, error) {
+	batch := make([]db.BatchQuestion, 0, len(questions))
+	for _, question := range questions {
+		batch = append(batch, db.BatchQuestion{
+			Content:         question.Content,
+			Answer:          question.Answer,
+			CreatedAt:       question.CreatedAt,
+			AnsweredAt:      question.AnsweredAt,
+			AnswerUpdatedAt: question.AnswerUpdatedAt,
+			Visibility:      question.Visibility,
+			Tags:            question.Tags,
+		})
+	}
+	return db.Questions.CreateBatch(ctx, db.CreateQuestionBatchOptions{
+		UserID:    userID,
+		Questions: batch,
+	})
+}
+
+var timeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006/01/02 15:04:05",
+	"2006-01-02 15:04",
+	"2006/01/02 15:04",
+	"2006-01-02",
+	"2006/01/02",
+}
+
+// parseTime parses the time in the common layouts of the export files,
+// the zero time is returned if the value can not be parsed, the import time is used then.
+func parseTime(value string) time.Time {
+	value = strings.TrimSpace(value)
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
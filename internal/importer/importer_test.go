@@ -0,0 +1,96 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package importer
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/NekoWheel/NekoBox/internal/conf"
+	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/db/migrations"
+	"github.com/NekoWheel/NekoBox/internal/dbutil"
+)
+
+func TestImport_Paginate(t *testing.T) {
+	database, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "nekobox.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	if err := migrations.Migrate(database); err != nil {
+		t.Fatalf("migrate database: %v", err)
+	}
+	questions := db.Questions
+	db.Questions = db.NewQuestionsStore(database)
+	t.Cleanup(func() { db.Questions = questions })
+
+	conf.Limits.QuestionMaxLength = 1000
+
+	ctx := context.Background()
+	const userID = 1
+
+	// The questions received in the box before the import.
+	for i := 0; i < 15; i++ {
+		if _, err := db.Questions.Create(ctx, db.CreateQuestionOptions{
+			UserID:  userID,
+			Content: fmt.Sprintf("received question %d", i),
+		}); err != nil {
+			t.Fatalf("create question: %v", err)
+		}
+	}
+
+	// The imported history is older than the received questions, but has the larger IDs.
+	var file strings.Builder
+	file.WriteString("question,answer,created_at\n")
+	for i := 0; i < 35; i++ {
+		createdAt := time.Now().AddDate(0, 0, -i-1).Format(time.RFC3339)
+		fmt.Fprintf(&file, "imported question %d,answer %d,%s\n", i, i, createdAt)
+	}
+	parsed, err := Parse(SourcePeing, strings.NewReader(file.String()))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	created, err := Import(ctx, userID, parsed)
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if created != 35 {
+		t.Fatalf("got %d imported questions, want 35", created)
+	}
+
+	seen := make(map[uint]struct{})
+	var cursor string
+	for page := 0; page < 10; page++ {
+		questions, pageInfo, err := db.Questions.GetByUserID(ctx, userID, db.GetQuestionsByUserIDOptions{
+			Cursor: &dbutil.Cursor{Value: cursor, PageSize: 10},
+		})
+		if err != nil {
+			t.Fatalf("get questions: %v", err)
+		}
+		for _, question := range questions {
+			if _, ok := seen[question.ID]; ok {
+				t.Fatalf("question %d is listed twice", question.ID)
+			}
+			seen[question.ID] = struct{}{}
+		}
+		if !pageInfo.HasMore {
+			break
+		}
+		cursor = pageInfo.NextCursor
+	}
+	if len(seen) != 50 {
+		t.Fatalf("got %d questions, want 50", len(seen))
+	}
+}
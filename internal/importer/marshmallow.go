@@ -0,0 +1,18 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package importer
+
+import (
+	"io"
+)
+
+// parseMarshmallow parses the CSV export of Marshmallow, the header is in Japanese or English.
+func parseMarshmallow(r io.Reader) ([]Question, error) {
+	return parseCSV(r, csvColumns{
+		Content:   []string{"メッセージ", "message", "content"},
+		Answer:    []string{"回答", "返信", "answer", "reply"},
+		CreatedAt: []string{"受信日時", "日時", "created_at", "date"},
+	})
+}
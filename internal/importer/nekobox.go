@@ -0,0 +1,70 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/export"
+)
+
+// maxJSONLLineSize is the max size of a line in the JSONL file, which is large enough for the longest answer.
+const maxJSONLLineSize = 1024 * 1024
+
+// parseNekoBox parses the JSONL file exported by NekoBox, the blank lines are skipped.
+func parseNekoBox(r io.Reader) ([]Question, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxJSONLLineSize)
+
+	var questions []Question
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		// Skip the UTF-8 BOM at the beginning of the file.
+		line = bytes.TrimPrefix(line, []byte{0xEF, 0xBB, 0xBF})
+		if len(line) == 0 {
+			continue
+		}
+		if len(questions) >= MaxQuestions {
+			return nil, ErrTooManyRows
+		}
+
+		var record export.JSONLQuestion
+		if err := json.Unmarshal(line, &record); err != nil {
+			return nil, ErrInvalidFile
+		}
+
+		visibility := db.QuestionVisibility(record.Visibility)
+		if visibility == "" {
+			visibility = db.QuestionVisibilityPublic
+		}
+		tags := db.ParseTags(strings.Join(record.Tags, ","))
+		if !visibility.IsValid() || db.ValidateTags(tags) != nil {
+			return nil, ErrInvalidFile
+		}
+
+		// The answer time is meaningless for the unanswered questions.
+		question := Question{
+			Content:    record.Content,
+			Answer:     record.Answer,
+			CreatedAt:  record.CreatedAt,
+			Visibility: visibility,
+			Tags:       tags,
+		}
+		if strings.TrimSpace(record.Answer) != "" {
+			question.AnsweredAt = record.AnsweredAt
+			question.AnswerUpdatedAt = record.AnswerUpdatedAt
+		}
+		questions = append(questions, question)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, ErrInvalidFile
+	}
+	return questions, nil
+}
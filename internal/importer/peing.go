@@ -0,0 +1,18 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package importer
+
+import (
+	"io"
+)
+
+// parsePeing parses the CSV export of Peing, the header is in Japanese or English.
+func parsePeing(r io.Reader) ([]Question, error) {
+	return parseCSV(r, csvColumns{
+		Content:   []string{"質問", "question", "body"},
+		Answer:    []string{"回答", "answer", "answer_body"},
+		CreatedAt: []string{"日時", "質問日時", "created_at", "date"},
+	})
+}
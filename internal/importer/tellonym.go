@@ -0,0 +1,53 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package importer
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+type tellonymTell struct {
+	Tell      string `json:"tell"`
+	Answer    string `json:"answer"`
+	CreatedAt string `json:"createdAt"`
+}
+
+// parseTellonym parses the JSON export of Tellonym, which is either an array of the tells
+// or an object with the tells in the `answers` field.
+func parseTellonym(r io.Reader) ([]Question, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidFile, err.Error())
+	}
+	data = bytes.TrimPrefix(data, []byte{0xEF, 0xBB, 0xBF})
+
+	var tells []tellonymTell
+	if err := json.Unmarshal(data, &tells); err != nil {
+		var export struct {
+			Answers []tellonymTell `json:"answers"`
+		}
+		if err := json.Unmarshal(data, &export); err != nil {
+			return nil, errors.Wrap(ErrInvalidFile, err.Error())
+		}
+		tells = export.Answers
+	}
+	if len(tells) > MaxQuestions {
+		return nil, ErrTooManyRows
+	}
+
+	questions := make([]Question, 0, len(tells))
+	for _, tell := range tells {
+		questions = append(questions, Question{
+			Content:   tell.Tell,
+			Answer:    tell.Answer,
+			CreatedAt: parseTime(tell.CreatedAt),
+		})
+	}
+	return questions, nil
+}
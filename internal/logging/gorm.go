@@ -0,0 +1,59 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package logging
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// slowQueryThreshold is the duration of the queries which are logged as slow.
+const slowQueryThreshold = 200 * time.Millisecond
+
+var _ logger.Interface = (*GORMLogger)(nil)
+
+// GORMLogger writes the logs of GORM with the context, so the failed queries are
+// correlated to the requests. The level follows the level of logrus.
+type GORMLogger struct{}
+
+func (l *GORMLogger) LogMode(logger.LogLevel) logger.Interface {
+	return l
+}
+
+func (l *GORMLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	logrus.WithContext(ctx).Infof(msg, args...)
+}
+
+func (l *GORMLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	logrus.WithContext(ctx).Warnf(msg, args...)
+}
+
+func (l *GORMLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	logrus.WithContext(ctx).Errorf(msg, args...)
+}
+
+func (l *GORMLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	elapsed := time.Since(begin)
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound):
+		// The errors are usually returned to the callers, which decide whether to log them.
+		sql, rows := fc()
+		logrus.WithContext(ctx).WithError(err).WithField("sql", sql).WithField("rows", rows).
+			WithField("duration", elapsed.String()).Debug("Query failed")
+	case elapsed > slowQueryThreshold:
+		sql, rows := fc()
+		logrus.WithContext(ctx).WithField("sql", sql).WithField("rows", rows).
+			WithField("duration", elapsed.String()).Warn("Slow query")
+	case logrus.IsLevelEnabled(logrus.TraceLevel):
+		sql, rows := fc()
+		logrus.WithContext(ctx).WithField("sql", sql).WithField("rows", rows).
+			WithField("duration", elapsed.String()).Trace("Query")
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package logging sets up the structured logs. The logs of a request are correlated by the request ID,
+// which is carried by the context into the stores, the censor and the background jobs.
+package logging
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/conf"
+)
+
+// Init sets the level and the format of the logs from the configuration.
+func Init() error {
+	if conf.Log.Format == "json" {
+		logrus.SetFormatter(&logrus.JSONFormatter{})
+	}
+	if err := SetLevel(conf.Log.Level); err != nil {
+		return err
+	}
+	logrus.AddHook(requestIDHook{})
+	return nil
+}
+
+// SetLevel sets the log level, it can be called at runtime. The level defaults to info.
+func SetLevel(level string) error {
+	if level == "" {
+		level = "info"
+	}
+	parsed, err := logrus.ParseLevel(level)
+	if err != nil {
+		return errors.Wrap(err, "parse log level")
+	}
+	logrus.SetLevel(parsed)
+	return nil
+}
+
+// Reload reloads the log level from the configuration file.
+func Reload() error {
+	if err := conf.ReloadLog(); err != nil {
+		return errors.Wrap(err, "reload log configuration")
+	}
+	return SetLevel(conf.Log.Level)
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns the context carrying the request ID.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	if requestID == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestID returns the request ID carried by the context, it returns an empty string
+// if the context does not belong to a request.
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+var _ logrus.Hook = requestIDHook{}
+
+// requestIDHook adds the request ID to the logs written with `logrus.WithContext`.
+type requestIDHook struct{}
+
+func (requestIDHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (requestIDHook) Fire(entry *logrus.Entry) error {
+	if requestID := RequestID(entry.Context); requestID != "" {
+		entry.Data["request_id"] = requestID
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package logging
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/flamego/flamego"
+	"github.com/sirupsen/logrus"
+)
+
+// RequestIDHeader is the header carrying the request ID, the ID set by the reverse proxy is reused
+// so the logs of the proxy and the server can be correlated.
+const RequestIDHeader = "X-Request-ID"
+
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// probePaths are the paths of the health probes, which are logged in the debug level to avoid the noise.
+var probePaths = map[string]struct{}{
+	"/healthz": {},
+	"/readyz":  {},
+}
+
+// Middleware assigns the request ID to the request and writes the access log when the request is done.
+func Middleware() flamego.Handler {
+	return func(c flamego.Context) {
+		requestID := c.Request().Header.Get(RequestIDHeader)
+		if !requestIDPattern.MatchString(requestID) {
+			requestID = newRequestID()
+		}
+		ctx := WithRequestID(c.Request().Context(), requestID)
+		c.Request().Request = c.Request().Request.WithContext(ctx)
+		c.ResponseWriter().Header().Set(RequestIDHeader, requestID)
+
+		started := time.Now()
+		c.Next()
+
+		status := c.ResponseWriter().Status()
+		// The query is not logged, which may contain the tokens of the links in the mails.
+		logger := logrus.WithContext(ctx).
+			WithField("method", c.Request().Method).
+			WithField("path", c.Request().URL.Path).
+			WithField("status", status).
+			WithField("duration", time.Since(started).String())
+
+		switch _, isProbe := probePaths[c.Request().URL.Path]; {
+		case status >= http.StatusInternalServerError:
+			logger.Error("Request completed")
+		case isProbe:
+			logger.Debug("Request completed")
+		default:
+			logger.Info("Request completed")
+		}
+	}
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
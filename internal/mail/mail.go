@@ -6,69 +6,205 @@ package mail
 
 import (
 	"bytes"
-	"crypto/tls"
+	"context"
 	"embed"
 	"fmt"
 	"html/template"
+	"net/url"
+	"strconv"
+	"time"
 
 	"github.com/pkg/errors"
-	"gopkg.in/gomail.v2"
 
 	"github.com/NekoWheel/NekoBox/internal/conf"
+	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/mailer"
+	"github.com/NekoWheel/NekoBox/internal/security/signature"
 	"github.com/NekoWheel/NekoBox/templates"
 )
 
-func SendNewQuestionMail(email string, domain string, questionID uint, questionContent string) error {
-	params := map[string]string{
-		"link":     fmt.Sprintf("https://box.n3ko.co/_/%s/%d", domain, questionID),
+// SendNewQuestionMail notifies the owner of the new question, the owner can answer
+// the question by replying to the mail if the reply domain is configured.
+func SendNewQuestionMail(ctx context.Context, email string, domain string, questionID uint, questionContent string) error {
+	replyTo := ReplyAddress(questionID)
+	params := map[string]interface{}{
+		"link":     fmt.Sprintf("%s/_/%s/%d", conf.App.ExternalURL, domain, questionID),
 		"question": questionContent,
+		"reply":    replyTo != "",
+	}
+	content, err := renderTemplate(templates.FS, "mail/new-question.html", params)
+	if err != nil {
+		return errors.Wrap(err, "render template")
+	}
+	return mailer.Enqueue(ctx, mailer.Message{
+		To:      email,
+		ReplyTo: replyTo,
+		Subject: "【NekoBox】您有一个新的提问",
+		HTML:    content,
+	})
+}
+
+// DigestQuestion is a question listed in the new question digest mail.
+type DigestQuestion struct {
+	ID      uint
+	Content string
+}
+
+func SendNewQuestionDigestMail(ctx context.Context, email, domain string, questions []DigestQuestion) error {
+	type digestItem struct {
+		Link     string
+		Question string
+	}
+	items := make([]digestItem, 0, len(questions))
+	for _, question := range questions {
+		items = append(items, digestItem{
+			Link:     fmt.Sprintf("%s/_/%s/%d", conf.App.ExternalURL, domain, question.ID),
+			Question: question.Content,
+		})
+	}
+
+	params := map[string]interface{}{
+		"link":      fmt.Sprintf("%s/user/questions", conf.App.ExternalURL),
+		"count":     len(questions),
+		"questions": items,
+	}
+	return sendTemplateMail(ctx, email, fmt.Sprintf("【NekoBox】您有 %d 个新的提问", len(questions)), templates.FS, "mail/new-question-digest.html", params)
+}
+
+// SendAnswerReminderMail reminds the user of the questions which have been unanswered for the given days,
+// the questions link to the pages where the user answers them.
+func SendAnswerReminderMail(ctx context.Context, email, domain string, days int, questions []DigestQuestion) error {
+	type reminderItem struct {
+		Link     string
+		Question string
+	}
+	items := make([]reminderItem, 0, len(questions))
+	for _, question := range questions {
+		items = append(items, reminderItem{
+			Link:     fmt.Sprintf("%s/_/%s/%d", conf.App.ExternalURL, domain, question.ID),
+			Question: question.Content,
+		})
+	}
+
+	params := map[string]interface{}{
+		"link":      fmt.Sprintf("%s/user/questions", conf.App.ExternalURL),
+		"settings":  fmt.Sprintf("%s/user/profile", conf.App.ExternalURL),
+		"days":      days,
+		"questions": items,
+	}
+	return sendTemplateMail(ctx, email, fmt.Sprintf("【NekoBox】您有提问已超过 %d 天未回答", days), templates.FS, "mail/answer-reminder.html", params)
+}
+
+// SendNewAnswerMail notifies the asker who left the email address that the question is answered.
+// The link is signed so the asker can view the answer even if it is not public, and the mail is not
+// sent to the addresses which have unsubscribed.
+func SendNewAnswerMail(ctx context.Context, email, domain string, questionID uint, question, answer string) error {
+	suppressed, err := db.EmailSuppressions.IsSuppressed(ctx, email)
+	if err != nil {
+		return errors.Wrap(err, "check email suppression")
+	}
+	if suppressed {
+		return nil
+	}
+
+	params := map[string]string{
+		"link":        fmt.Sprintf("%s/_/%s/%d?v=%s", conf.App.ExternalURL, domain, questionID, signature.Sign(signature.PurposeViewQuestion, strconv.FormatUint(uint64(questionID), 10))),
+		"unsubscribe": UnsubscribeURL(email),
+		"question":    question,
+		"answer":      answer,
 	}
-	return sendTemplateMail(email, "【NekoBox】您有一个新的提问", templates.FS, "mail/new-question.html", params)
+	return sendTemplateMail(ctx, email, "【NekoBox】您的提问有了回复", templates.FS, "mail/new-answer.html", params)
 }
 
-func SendNewAnswerMail(email, domain string, questionID uint, question, answer string) error {
+// UnsubscribeURL returns the signed link to stop the notification mails to the email address.
+func UnsubscribeURL(email string) string {
+	return fmt.Sprintf("%s/mail/unsubscribe?email=%s&sig=%s", conf.App.ExternalURL, url.QueryEscape(email), signature.Sign(signature.PurposeUnsubscribe, email))
+}
+
+func SendPasswordRecoveryMail(ctx context.Context, email, code string) error {
 	params := map[string]string{
-		"link":     fmt.Sprintf("https://box.n3ko.co/_/%s/%d", domain, questionID),
-		"question": question,
-		"answer":   answer,
+		"link":  fmt.Sprintf("%s/recover-password?code=%s", conf.App.ExternalURL, code),
+		"email": email,
 	}
-	return sendTemplateMail(email, "【NekoBox】您的提问有了回复", templates.FS, "mail/new-answer.html", params)
+	return sendTemplateMail(ctx, email, "【NekoBox】账号密码找回", templates.FS, "mail/password-recovery.html", params)
 }
 
-func SendPasswordRecoveryMail(email, code string) error {
+func SendAccountDeletionMail(ctx context.Context, email, code string) error {
 	params := map[string]string{
-		"link":  fmt.Sprintf("https://box.n3ko.co/recover-password?code=%s", code),
+		"link":  fmt.Sprintf("%s/delete-account?code=%s", conf.App.ExternalURL, code),
 		"email": email,
 	}
-	return sendTemplateMail(email, "【NekoBox】账号密码找回", templates.FS, "mail/password-recovery.html", params)
+	return sendTemplateMail(ctx, email, "【NekoBox】确认删除账号", templates.FS, "mail/account-deletion.html", params)
+}
+
+// SendLoginLinkMail sends the one-time login link, which expires after the given duration.
+func SendLoginLinkMail(ctx context.Context, email, token string, expiresIn time.Duration) error {
+	params := map[string]string{
+		"link":    fmt.Sprintf("%s/login/magic/verify?token=%s", conf.App.ExternalURL, token),
+		"email":   email,
+		"expires": strconv.Itoa(int(expiresIn.Minutes())),
+	}
+	return sendTemplateMail(ctx, email, "【NekoBox】登录链接", templates.FS, "mail/login-link.html", params)
 }
 
-func sendTemplateMail(email, title string, templateFS embed.FS, templatePath string, params map[string]string) error {
+// SendEmailChangeVerifyMail sends the link to the new address to confirm the change of the account email,
+// the link expires after the given duration.
+func SendEmailChangeVerifyMail(ctx context.Context, email, token string, expiresIn time.Duration) error {
+	params := map[string]string{
+		"link":    fmt.Sprintf("%s/email/verify?token=%s", conf.App.ExternalURL, token),
+		"email":   email,
+		"expires": strconv.Itoa(int(expiresIn.Hours())),
+	}
+	return sendTemplateMail(ctx, email, "【NekoBox】确认修改账号邮箱", templates.FS, "mail/email-change-verify.html", params)
+}
+
+// SendEmailChangeNoticeMail tells the old address that the account email is being changed,
+// the owner of the old address can cancel the change with the link.
+func SendEmailChangeNoticeMail(ctx context.Context, email, newEmail, token string) error {
+	params := map[string]string{
+		"link":      fmt.Sprintf("%s/email/cancel?token=%s", conf.App.ExternalURL, token),
+		"email":     email,
+		"new_email": newEmail,
+	}
+	return sendTemplateMail(ctx, email, "【NekoBox】账号邮箱修改提醒", templates.FS, "mail/email-change-notice.html", params)
+}
+
+// SendAccountLockedMail tells the user the login of the account is locked until the given time
+// because of too many failed logins.
+func SendAccountLockedMail(ctx context.Context, email string, until time.Time) error {
+	params := map[string]string{
+		"link":  fmt.Sprintf("%s/forgot-password", conf.App.ExternalURL),
+		"email": email,
+		"until": until.Format("2006-01-02 15:04:05"),
+	}
+	return sendTemplateMail(ctx, email, "【NekoBox】账号已被暂时锁定", templates.FS, "mail/account-locked.html", params)
+}
+
+func sendTemplateMail(ctx context.Context, email, title string, templateFS embed.FS, templatePath string, params interface{}) error {
+	content, err := renderTemplate(templateFS, templatePath, params)
+	if err != nil {
+		return errors.Wrap(err, "render template")
+	}
+	return sendMail(ctx, email, title, content)
+}
+
+func renderTemplate(templateFS embed.FS, templatePath string, params interface{}) (string, error) {
 	var content bytes.Buffer
 	t, err := template.ParseFS(templateFS, templatePath)
 	if err != nil {
-		return errors.Wrap(err, "parse template file")
+		return "", errors.Wrap(err, "parse template file")
 	}
 	if err := t.Execute(&content, params); err != nil {
-		return errors.Wrap(err, "execute template")
+		return "", errors.Wrap(err, "execute template")
 	}
-
-	return sendMail(email, title, content.String())
+	return content.String(), nil
 }
 
-func sendMail(to, title, content string) error {
-	m := gomail.NewMessage()
-	m.SetHeader("From", fmt.Sprintf("NekoBox <%s>", conf.Mail.Account))
-	m.SetHeader("To", to)
-	m.SetHeader("Subject", title)
-	m.SetBody("text/html", content)
-
-	d := gomail.NewDialer(
-		conf.Mail.SMTP,
-		conf.Mail.Port,
-		conf.Mail.Account,
-		conf.Mail.Password,
-	)
-	d.TLSConfig = &tls.Config{InsecureSkipVerify: true}
-	return d.DialAndSend(m)
+// sendMail puts the mail into the outbox, it is delivered in the background with retries.
+func sendMail(ctx context.Context, to, title, content string) error {
+	return mailer.Enqueue(ctx, mailer.Message{
+		To:      to,
+		Subject: title,
+		HTML:    content,
+	})
 }
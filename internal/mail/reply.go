@@ -0,0 +1,273 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package mail
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	netmail "net/mail"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/NekoWheel/NekoBox/internal/conf"
+	"github.com/NekoWheel/NekoBox/internal/security/signature"
+)
+
+// replyAddressPrefix is the prefix of the local part of the reply addresses, e.g. "reply+1.<signature>@reply.example.com".
+const replyAddressPrefix = "reply+"
+
+// ReplyAddress returns the address which the owner replies to for answering the question,
+// it is empty if the reply domain is not configured.
+func ReplyAddress(questionID uint) string {
+	if conf.Mail.ReplyDomain == "" {
+		return ""
+	}
+	id := strconv.FormatUint(uint64(questionID), 10)
+	return fmt.Sprintf("%s%s.%s@%s", replyAddressPrefix, id, signature.SignShort(signature.PurposeReplyAnswer, id), conf.Mail.ReplyDomain)
+}
+
+// ParseReplyAddress returns the question ID of the reply address, it returns false if
+// the address is not a reply address or the signature does not match.
+func ParseReplyAddress(address string) (uint, bool) {
+	if conf.Mail.ReplyDomain == "" {
+		return 0, false
+	}
+
+	at := strings.LastIndex(address, "@")
+	if at < 0 || !strings.EqualFold(address[at+1:], conf.Mail.ReplyDomain) {
+		return 0, false
+	}
+	local := strings.ToLower(address[:at])
+	if !strings.HasPrefix(local, replyAddressPrefix) {
+		return 0, false
+	}
+
+	id, sig, ok := strings.Cut(strings.TrimPrefix(local, replyAddressPrefix), ".")
+	if !ok || !signature.VerifyShort(signature.PurposeReplyAnswer, id, sig) {
+		return 0, false
+	}
+	questionID, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return uint(questionID), true
+}
+
+// InboundMail is the mail forwarded by the inbound mail webhook of the mail service.
+type InboundMail struct {
+	Recipients []string
+	// Sender is the address in the From header.
+	Sender string
+	// SenderVerified reports whether the mail service has verified the domain of the sender address
+	// by the DKIM signature or the SPF record, the From header can be forged by anyone otherwise.
+	SenderVerified bool
+	// Text is the plain text body without the quoted original mail.
+	Text string
+}
+
+// maxInboundMailSize is the max size of the inbound mail kept in memory, the rest is stored in temporary files.
+const maxInboundMailSize = 10 << 20
+
+// ParseInbound parses the mail posted by the inbound parse webhook of SendGrid or the inbound routes of Mailgun.
+// See https://docs.sendgrid.com/for-developers/parsing-email/setting-up-the-inbound-parse-webhook
+// and https://documentation.mailgun.com/en/latest/user_manual.html#parsed-messages-parameters
+func ParseInbound(r *http.Request) (*InboundMail, error) {
+	if err := r.ParseMultipartForm(maxInboundMailSize); err != nil {
+		if !errors.Is(err, http.ErrNotMultipart) {
+			return nil, errors.Wrap(err, "parse multipart form")
+		}
+		if err := r.ParseForm(); err != nil {
+			return nil, errors.Wrap(err, "parse form")
+		}
+	}
+	if r.MultipartForm != nil {
+		defer func() { _ = r.MultipartForm.RemoveAll() }()
+	}
+
+	var inbound InboundMail
+	// envelopeSender is the sender address of the SMTP envelope, which is checked by SPF.
+	var envelopeSender string
+
+	// Mailgun puts the envelope recipients in the "recipient" field, and SendGrid puts them in the "envelope" JSON.
+	switch {
+	case r.FormValue("recipient") != "":
+		for _, recipient := range strings.Split(r.FormValue("recipient"), ",") {
+			inbound.Recipients = append(inbound.Recipients, strings.TrimSpace(recipient))
+		}
+		envelopeSender = r.FormValue("sender")
+	case r.FormValue("envelope") != "":
+		var envelope struct {
+			From string   `json:"from"`
+			To   []string `json:"to"`
+		}
+		if err := json.Unmarshal([]byte(r.FormValue("envelope")), &envelope); err != nil {
+			return nil, errors.Wrap(err, "unmarshal envelope")
+		}
+		inbound.Recipients = envelope.To
+		envelopeSender = envelope.From
+	default:
+		addresses, err := netmail.ParseAddressList(r.FormValue("to"))
+		if err != nil {
+			return nil, errors.Wrap(err, "parse to addresses")
+		}
+		for _, address := range addresses {
+			inbound.Recipients = append(inbound.Recipients, address.Address)
+		}
+	}
+
+	from := r.FormValue("from")
+	if from == "" {
+		from = r.FormValue("sender")
+	}
+	sender, err := netmail.ParseAddress(from)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse from address")
+	}
+	inbound.Sender = sender.Address
+	inbound.SenderVerified = inboundSenderVerified(r, inbound.Sender, envelopeSender)
+
+	// Mailgun has stripped the quoted parts and the signature in the "stripped-text" field.
+	text := r.FormValue("stripped-text")
+	if text == "" {
+		text = r.FormValue("text")
+	}
+	if text == "" {
+		text = r.FormValue("body-plain")
+	}
+	inbound.Text = StripQuotedReply(text)
+	return &inbound, nil
+}
+
+// inboundSignatureMaxAge is how long the signed request of Mailgun is accepted, so that
+// the captured request can't be replayed later.
+const inboundSignatureMaxAge = 5 * time.Minute
+
+// VerifyInbound reports whether the parsed inbound mail request is sent by the mail service.
+// Mailgun signs the request with the webhook signing key, and SendGrid sends the secret
+// by the basic auth in the URL, e.g. "https://nekobox:<secret>@example.com/mail/inbound".
+// See https://documentation.mailgun.com/en/latest/user_manual.html#securing-webhooks
+func VerifyInbound(r *http.Request, secret string) bool {
+	if _, password, ok := r.BasicAuth(); ok {
+		return subtle.ConstantTimeCompare([]byte(password), []byte(secret)) == 1
+	}
+
+	timestamp, token, sig := r.FormValue("timestamp"), r.FormValue("token"), r.FormValue("signature")
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || token == "" {
+		return false
+	}
+	if age := time.Since(time.Unix(unix, 0)); age > inboundSignatureMaxAge || age < -inboundSignatureMaxAge {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write([]byte(timestamp + token))
+	return hmac.Equal([]byte(sig), []byte(hex.EncodeToString(mac.Sum(nil))))
+}
+
+// inboundSenderVerified reports whether the domain of the sender address passed the DKIM or SPF check
+// of the mail service. The verdict only counts when the checked domain is aligned with the sender's,
+// since anyone can sign the mail or send it from the domain of their own.
+func inboundSenderVerified(r *http.Request, sender, envelopeSender string) bool {
+	domain := addressDomain(sender)
+	if domain == "" {
+		return false
+	}
+	spfAligned := domainAligned(addressDomain(envelopeSender), domain)
+
+	// SendGrid puts the verdicts in the "dkim" and "SPF" fields, the "dkim" field is like "{@example.com : pass}".
+	if dkim, spf := r.FormValue("dkim"), r.FormValue("SPF"); dkim != "" || spf != "" {
+		for _, result := range strings.Split(dkim, ",") {
+			signer, verdict, ok := strings.Cut(strings.Trim(result, "{} "), ":")
+			if ok && strings.EqualFold(strings.TrimSpace(verdict), "pass") &&
+				domainAligned(strings.TrimPrefix(strings.TrimSpace(signer), "@"), domain) {
+				return true
+			}
+		}
+		return strings.EqualFold(spf, "pass") && spfAligned
+	}
+
+	// Mailgun puts the verdicts in the message headers, along with the DKIM signatures of the mail.
+	var headers [][2]string
+	if err := json.Unmarshal([]byte(r.FormValue("message-headers")), &headers); err != nil {
+		return false
+	}
+	var dkimPassed, spfPassed, dkimAligned bool
+	for _, header := range headers {
+		switch strings.ToLower(header[0]) {
+		case "x-mailgun-dkim-check-result":
+			dkimPassed = strings.EqualFold(header[1], "pass")
+		case "x-mailgun-spf":
+			spfPassed = strings.EqualFold(header[1], "pass")
+		case "dkim-signature":
+			for _, tag := range strings.Split(header[1], ";") {
+				name, value, _ := strings.Cut(strings.TrimSpace(tag), "=")
+				if name == "d" && domainAligned(strings.TrimSpace(value), domain) {
+					dkimAligned = true
+				}
+			}
+		}
+	}
+	return (dkimPassed && dkimAligned) || (spfPassed && spfAligned)
+}
+
+func addressDomain(address string) string {
+	at := strings.LastIndex(address, "@")
+	if at < 0 {
+		return ""
+	}
+	return strings.ToLower(strings.Trim(address[at+1:], "> "))
+}
+
+// domainAligned reports whether the checked domain is the sender's domain, or one of them is
+// the subdomain of the other.
+func domainAligned(checked, domain string) bool {
+	checked = strings.ToLower(checked)
+	return checked != "" && (checked == domain || strings.HasSuffix(domain, "."+checked) || strings.HasSuffix(checked, "."+domain))
+}
+
+// quoteHeaderPatterns match the first line of the quoted original mail added by the mail clients.
+var quoteHeaderPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`^>`),
+	regexp.MustCompile(`^On\s.+wrote:$`),
+	regexp.MustCompile(`^在.+写道[：:]$`),
+	regexp.MustCompile(`^-+\s*(Original Message|原始邮件)\s*-+$`),
+	regexp.MustCompile(`^(From|发件人)\s*[:：]`),
+}
+
+// StripQuotedReply returns the reply text without the quoted original mail and the signature.
+func StripQuotedReply(text string) string {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		// The signature is separated by "-- " by convention.
+		if line == "-- " || line == "--" {
+			lines = lines[:i]
+			break
+		}
+
+		quoted := false
+		for _, pattern := range quoteHeaderPatterns {
+			if pattern.MatchString(trimmed) {
+				quoted = true
+				break
+			}
+		}
+		if quoted {
+			lines = lines[:i]
+			break
+		}
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
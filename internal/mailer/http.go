@@ -0,0 +1,36 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package mailer
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var client = &http.Client{Timeout: 30 * time.Second}
+
+// doAPIRequest sends the request to the mail service's HTTP API. The 4xx responses except
+// 429 Too Many Requests mean the message is rejected, which are not retried.
+func doAPIRequest(req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "do request")
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	err = errors.Errorf("unexpected status code %d: %s", resp.StatusCode, body)
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+		return permanent(err)
+	}
+	return err
+}
@@ -0,0 +1,271 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+
+	"github.com/NekoWheel/NekoBox/internal/conf"
+	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/tracing"
+)
+
+// Message is the HTML mail to be sent.
+type Message struct {
+	To string
+	// ReplyTo is the optional address which the replies are sent to instead of the sender.
+	ReplyTo string
+	Subject string
+	HTML    string
+}
+
+// Provider delivers the mail through a mail service.
+type Provider interface {
+	// Name returns the name of the provider, which is recorded in the outbox.
+	Name() string
+	// Send sends the message, it returns a permanent error if the message is
+	// rejected by the mail service and should not be retried.
+	Send(ctx context.Context, msg Message) error
+}
+
+var factories = map[string]func() (Provider, error){
+	"smtp":     newSMTP,
+	"sendgrid": newSendGrid,
+	"mailgun":  newMailgun,
+}
+
+var (
+	defaultProvider     Provider
+	defaultProviderErr  error
+	defaultProviderOnce sync.Once
+)
+
+// provider returns the configured mail provider, the SMTP provider is used by default.
+func provider() (Provider, error) {
+	defaultProviderOnce.Do(func() {
+		name := strings.TrimSpace(conf.Mail.Provider)
+		if name == "" {
+			name = "smtp"
+		}
+
+		factory, ok := factories[name]
+		if !ok {
+			defaultProviderErr = errors.Errorf("unknown mail provider %q", name)
+			return
+		}
+		defaultProvider, defaultProviderErr = factory()
+	})
+	return defaultProvider, defaultProviderErr
+}
+
+// fromAddress returns the sender address of the mails.
+func fromAddress() string {
+	if conf.Mail.From != "" {
+		return conf.Mail.From
+	}
+	return conf.Mail.Account
+}
+
+// from returns the sender of the mails with the display name.
+func from() string {
+	return fmt.Sprintf("NekoBox <%s>", fromAddress())
+}
+
+// PermanentError is the error that the mail is rejected by the mail service permanently,
+// e.g. the recipient address does not exist. The mail is not retried and is marked as bounced.
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+func permanent(err error) error {
+	return &PermanentError{Err: err}
+}
+
+func isPermanent(err error) bool {
+	var permanentErr *PermanentError
+	return errors.As(err, &permanentErr)
+}
+
+const (
+	pollInterval = 10 * time.Second
+	batchSize    = 50
+	maxAttempts  = 8
+	baseBackoff  = 30 * time.Second
+	maxBackoff   = time.Hour
+	// claimLease is how long a claimed mail is hidden from other workers,
+	// it will be retried after the lease if the delivery is interrupted.
+	claimLease = 5 * time.Minute
+)
+
+// wakeup notifies the worker to deliver the newly enqueued mails without waiting for the next poll.
+var wakeup = make(chan struct{}, 1)
+
+// traceContext propagates the trace from the request which enqueues the mail to the delivery.
+var traceContext = propagation.TraceContext{}
+
+// Enqueue saves the message into the outbox, it is delivered by the worker in the background.
+func Enqueue(ctx context.Context, msg Message) (err error) {
+	ctx, span := tracing.Start(ctx, "mailer.Enqueue", attribute.String("mail.subject", msg.Subject))
+	defer func() { tracing.End(span, err) }()
+
+	carrier := propagation.MapCarrier{}
+	traceContext.Inject(ctx, carrier)
+
+	if _, err := db.MailOutbox.Create(ctx, db.CreateOutboxMailOptions{
+		To:          msg.To,
+		ReplyTo:     msg.ReplyTo,
+		Subject:     msg.Subject,
+		Content:     msg.HTML,
+		TraceParent: carrier.Get("traceparent"),
+	}); err != nil {
+		return errors.Wrap(err, "create outbox mail")
+	}
+
+	select {
+	case wakeup <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Start starts the outbox delivery worker, the worker stops when the context is done.
+func Start(ctx context.Context) {
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		work(ctx)
+	}()
+}
+
+var workers sync.WaitGroup
+
+// Wait waits for the worker to finish the mail being delivered after the context is done.
+func Wait() {
+	workers.Wait()
+}
+
+func work(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := deliverDue(ctx); err != nil {
+			logrus.WithContext(ctx).WithError(err).Error("Failed to deliver outbox mails")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-wakeup:
+		}
+	}
+}
+
+func deliverDue(ctx context.Context) error {
+	mails, err := db.MailOutbox.ListDue(ctx, batchSize)
+	if err != nil {
+		return errors.Wrap(err, "list due outbox mails")
+	}
+
+	// The claimed mail is delivered without the cancellation of the worker's context, so the delivery
+	// is not interrupted on shutdown, otherwise the mail may be sent again after the lease.
+	deliverCtx := context.Background()
+	for _, mail := range mails {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		claimed, err := db.MailOutbox.Claim(deliverCtx, mail.ID, claimLease)
+		if err != nil {
+			return errors.Wrap(err, "claim outbox mail")
+		}
+		if !claimed {
+			continue
+		}
+		deliver(deliverCtx, mail)
+	}
+	return nil
+}
+
+func deliver(ctx context.Context, mail *db.OutboxMail) {
+	logger := logrus.WithContext(ctx).
+		WithField("outbox_mail_id", mail.ID).
+		WithField("to", mail.To).
+		WithField("subject", mail.Subject).
+		WithField("attempt", mail.Attempts+1)
+
+	p, err := provider()
+	if err != nil {
+		logger.WithError(err).Error("Failed to get mail provider")
+		return
+	}
+	logger = logger.WithField("provider", p.Name())
+
+	err = send(ctx, p, mail)
+	if err == nil {
+		if err := db.MailOutbox.MarkSent(ctx, mail.ID, p.Name()); err != nil {
+			logger.WithError(err).Error("Failed to mark outbox mail sent")
+		}
+		return
+	}
+
+	opts := db.MarkOutboxMailFailedOptions{
+		Provider: p.Name(),
+		Error:    err.Error(),
+		Status:   db.OutboxMailStatusPending,
+	}
+	switch {
+	case isPermanent(err):
+		opts.Status = db.OutboxMailStatusBounced
+		logger.WithError(err).Error("Mail bounced")
+	case mail.Attempts+1 >= maxAttempts:
+		opts.Status = db.OutboxMailStatusFailed
+		logger.WithError(err).Error("Failed to send mail, give up")
+	default:
+		backoff := baseBackoff << mail.Attempts
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+		opts.NextAttemptAt = time.Now().Add(backoff)
+		logger.WithError(err).WithField("backoff", backoff.String()).Warn("Failed to send mail, retry later")
+	}
+
+	if err := db.MailOutbox.MarkFailed(ctx, mail.ID, opts); err != nil {
+		logger.WithError(err).Error("Failed to mark outbox mail failed")
+	}
+}
+
+// send sends the outbox mail in a span, which belongs to the trace of the request enqueued the mail.
+func send(ctx context.Context, p Provider, mail *db.OutboxMail) (err error) {
+	if mail.TraceParent != "" {
+		ctx = traceContext.Extract(ctx, propagation.MapCarrier{"traceparent": mail.TraceParent})
+	}
+	ctx, span := tracing.Start(ctx, "mailer.Send",
+		attribute.String("mail.provider", p.Name()),
+		attribute.Int64("mail.outbox_id", int64(mail.ID)),
+		attribute.Int("mail.attempt", mail.Attempts+1),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	return p.Send(ctx, Message{To: mail.To, ReplyTo: mail.ReplyTo, Subject: mail.Subject, HTML: mail.Content})
+}
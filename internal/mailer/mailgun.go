@@ -0,0 +1,74 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/NekoWheel/NekoBox/internal/conf"
+)
+
+// mailgunEndpoint is the API base of the US region, set the endpoint to
+// "https://api.eu.mailgun.net/v3" for the domains in the EU region.
+const mailgunEndpoint = "https://api.mailgun.net/v3"
+
+type mailgunProvider struct {
+	endpoint string
+	domain   string
+	apiKey   string
+}
+
+func newMailgun() (Provider, error) {
+	if conf.Mail.APIKey == "" {
+		return nil, errors.New("empty Mailgun API key")
+	}
+	if conf.Mail.Domain == "" {
+		return nil, errors.New("empty Mailgun domain")
+	}
+
+	endpoint := conf.Mail.Endpoint
+	if endpoint == "" {
+		endpoint = mailgunEndpoint
+	}
+	return &mailgunProvider{
+		endpoint: strings.TrimRight(endpoint, "/"),
+		domain:   conf.Mail.Domain,
+		apiKey:   conf.Mail.APIKey,
+	}, nil
+}
+
+func (*mailgunProvider) Name() string {
+	return "mailgun"
+}
+
+// Send sends the message with the Mailgun messages API.
+// See https://documentation.mailgun.com/en/latest/api-sending.html
+func (p *mailgunProvider) Send(ctx context.Context, msg Message) error {
+	form := url.Values{
+		"from":    {from()},
+		"to":      {msg.To},
+		"subject": {msg.Subject},
+		"html":    {msg.HTML},
+	}
+	if msg.ReplyTo != "" {
+		form.Set("h:Reply-To", msg.ReplyTo)
+	}
+
+	endpoint := fmt.Sprintf("%s/%s/messages", p.endpoint, p.domain)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return errors.Wrap(err, "new request")
+	}
+	req.SetBasicAuth("api", p.apiKey)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	return doAPIRequest(req)
+}
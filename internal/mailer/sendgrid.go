@@ -0,0 +1,90 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/pkg/errors"
+
+	"github.com/NekoWheel/NekoBox/internal/conf"
+)
+
+const sendGridEndpoint = "https://api.sendgrid.com/v3/mail/send"
+
+type sendGridProvider struct {
+	endpoint string
+	apiKey   string
+}
+
+func newSendGrid() (Provider, error) {
+	if conf.Mail.APIKey == "" {
+		return nil, errors.New("empty SendGrid API key")
+	}
+
+	endpoint := conf.Mail.Endpoint
+	if endpoint == "" {
+		endpoint = sendGridEndpoint
+	}
+	return &sendGridProvider{endpoint: endpoint, apiKey: conf.Mail.APIKey}, nil
+}
+
+func (*sendGridProvider) Name() string {
+	return "sendgrid"
+}
+
+type sendGridAddress struct {
+	Email string `json:"email"`
+	Name  string `json:"name,omitempty"`
+}
+
+type sendGridRequest struct {
+	Personalizations []struct {
+		To []sendGridAddress `json:"to"`
+	} `json:"personalizations"`
+	From    sendGridAddress  `json:"from"`
+	ReplyTo *sendGridAddress `json:"reply_to,omitempty"`
+	Subject string           `json:"subject"`
+	Content []struct {
+		Type  string `json:"type"`
+		Value string `json:"value"`
+	} `json:"content"`
+}
+
+// Send sends the message with the SendGrid v3 mail send API.
+// See https://docs.sendgrid.com/api-reference/mail-send/mail-send
+func (p *sendGridProvider) Send(ctx context.Context, msg Message) error {
+	var body sendGridRequest
+	body.Personalizations = make([]struct {
+		To []sendGridAddress `json:"to"`
+	}, 1)
+	body.Personalizations[0].To = []sendGridAddress{{Email: msg.To}}
+	body.From = sendGridAddress{Email: fromAddress(), Name: "NekoBox"}
+	if msg.ReplyTo != "" {
+		body.ReplyTo = &sendGridAddress{Email: msg.ReplyTo}
+	}
+	body.Subject = msg.Subject
+	body.Content = []struct {
+		Type  string `json:"type"`
+		Value string `json:"value"`
+	}{{Type: "text/html", Value: msg.HTML}}
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return errors.Wrap(err, "marshal request")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return errors.Wrap(err, "new request")
+	}
+	req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	return doAPIRequest(req)
+}
@@ -0,0 +1,67 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"net/textproto"
+
+	"github.com/pkg/errors"
+	"gopkg.in/gomail.v2"
+
+	"github.com/NekoWheel/NekoBox/internal/conf"
+)
+
+type smtpProvider struct {
+	dialer *gomail.Dialer
+}
+
+func newSMTP() (Provider, error) {
+	if conf.Mail.SMTP == "" {
+		return nil, errors.New("empty SMTP host")
+	}
+
+	dialer := gomail.NewDialer(
+		conf.Mail.SMTP,
+		conf.Mail.Port,
+		conf.Mail.Account,
+		conf.Mail.Password,
+	)
+	dialer.TLSConfig = &tls.Config{InsecureSkipVerify: true}
+	return &smtpProvider{dialer: dialer}, nil
+}
+
+func (*smtpProvider) Name() string {
+	return "smtp"
+}
+
+func (p *smtpProvider) Send(_ context.Context, msg Message) error {
+	m := gomail.NewMessage()
+	m.SetHeader("From", from())
+	m.SetHeader("To", msg.To)
+	if msg.ReplyTo != "" {
+		m.SetHeader("Reply-To", msg.ReplyTo)
+	}
+	m.SetHeader("Subject", msg.Subject)
+	m.SetBody("text/html", msg.HTML)
+
+	sender, err := p.dialer.Dial()
+	if err != nil {
+		return errors.Wrap(err, "dial")
+	}
+	defer func() { _ = sender.Close() }()
+
+	// Send with the sender directly rather than gomail.Send, which hides the SMTP reply code.
+	if err := sender.Send(fromAddress(), []string{msg.To}, m); err != nil {
+		// The 5yz reply codes are permanent negative completion replies, see RFC 5321 section 4.2.1.
+		var protoErr *textproto.Error
+		if errors.As(err, &protoErr) && protoErr.Code >= 500 {
+			return permanent(err)
+		}
+		return errors.Wrap(err, "send")
+	}
+	return nil
+}
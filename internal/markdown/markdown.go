@@ -0,0 +1,62 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package markdown renders the Markdown content written by the users into the sanitized HTML.
+package markdown
+
+import (
+	"bytes"
+	"html/template"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/sirupsen/logrus"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer/html"
+)
+
+var renderer = goldmark.New(
+	goldmark.WithExtensions(
+		extension.Strikethrough,
+		extension.NewTable(extension.WithTableCellAlignMethod(extension.TableCellAlignAttribute)),
+		extension.Linkify,
+	),
+	// The raw HTML is kept for the sanitizer to strip the tags, otherwise the whole block starting
+	// with a tag is omitted. The hard wraps keep the line breaks the same as the plain text answers.
+	goldmark.WithRendererOptions(
+		html.WithHardWraps(),
+		html.WithUnsafe(),
+	),
+)
+
+// policy only allows the text formatting elements and the links. The images are not allowed,
+// otherwise the visitors' IP addresses can be collected by the image hosts.
+var policy = func() *bluemonday.Policy {
+	p := bluemonday.NewPolicy()
+	p.AllowElements(
+		"p", "br", "hr", "strong", "em", "del", "code", "pre", "blockquote",
+		"ul", "ol", "li", "h1", "h2", "h3", "h4", "h5", "h6",
+		"table", "thead", "tbody", "tr", "th", "td",
+	)
+	p.AllowAttrs("start").Matching(bluemonday.Integer).OnElements("ol")
+	p.AllowAttrs("align").Matching(bluemonday.CellAlign).OnElements("th", "td")
+
+	p.AllowStandardURLs()
+	p.AllowAttrs("href").OnElements("a")
+	p.RequireNoFollowOnLinks(true)
+	p.RequireNoReferrerOnLinks(true)
+	p.AddTargetBlankToFullyQualifiedLinks(true)
+	return p
+}()
+
+// Render renders the Markdown source into the sanitized HTML.
+func Render(source string) template.HTML {
+	var buf bytes.Buffer
+	if err := renderer.Convert([]byte(source), &buf); err != nil {
+		// The source is shown as the escaped plain text if it can't be rendered.
+		logrus.WithError(err).Error("Failed to render markdown")
+		return template.HTML(template.HTMLEscapeString(source))
+	}
+	return template.HTML(policy.SanitizeBytes(buf.Bytes()))
+}
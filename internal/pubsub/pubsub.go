@@ -0,0 +1,104 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package pubsub delivers the live events to the subscribers of a user, e.g. the open inbox pages of the box owner.
+//
+// The events are delivered to the subscribers of the current instance by default. If Redis is configured,
+// the events are published to the Redis channel, so the subscribers connected to any instance receive them.
+// The delivery is best-effort, the events are dropped if the subscriber is too slow or offline.
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+type EventType string
+
+const (
+	EventQuestionCreated EventType = "question.created"
+)
+
+// Event is the message delivered to the subscribers.
+type Event struct {
+	UserID uint            `json:"user_id"`
+	Type   EventType       `json:"type"`
+	Data   json.RawMessage `json:"data"`
+}
+
+// subscriberBufferSize is the number of the pending events of a subscriber, the newer events are dropped when it is full.
+const subscriberBufferSize = 16
+
+type subscriber struct {
+	events chan Event
+}
+
+// hub dispatches the events to the subscribers of the current instance.
+type hub struct {
+	mu          sync.RWMutex
+	subscribers map[uint]map[*subscriber]struct{}
+}
+
+func (h *hub) subscribe(userID uint) (*subscriber, func()) {
+	s := &subscriber{events: make(chan Event, subscriberBufferSize)}
+
+	h.mu.Lock()
+	if h.subscribers[userID] == nil {
+		h.subscribers[userID] = make(map[*subscriber]struct{})
+	}
+	h.subscribers[userID][s] = struct{}{}
+	h.mu.Unlock()
+
+	var once sync.Once
+	return s, func() {
+		once.Do(func() {
+			h.mu.Lock()
+			delete(h.subscribers[userID], s)
+			if len(h.subscribers[userID]) == 0 {
+				delete(h.subscribers, userID)
+			}
+			h.mu.Unlock()
+		})
+	}
+}
+
+func (h *hub) dispatch(event Event) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for s := range h.subscribers[event.UserID] {
+		select {
+		case s.events <- event:
+		default:
+		}
+	}
+}
+
+var local = &hub{subscribers: make(map[uint]map[*subscriber]struct{})}
+
+// transport sends the events to the hubs of all the instances. The default transport
+// dispatches the events to the current instance directly.
+var transport = func(_ context.Context, event Event) error {
+	local.dispatch(event)
+	return nil
+}
+
+// Publish sends the event with the data encoded in JSON to the subscribers of the user.
+func Publish(ctx context.Context, userID uint, typ EventType, data interface{}) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return errors.Wrap(err, "marshal data")
+	}
+	return transport(ctx, Event{UserID: userID, Type: typ, Data: raw})
+}
+
+// Subscribe returns the channel of the events of the user, the returned function must be called
+// to unsubscribe when the subscriber leaves. The channel is never closed.
+func Subscribe(userID uint) (<-chan Event, func()) {
+	s, unsubscribe := local.subscribe(userID)
+	return s.events, unsubscribe
+}
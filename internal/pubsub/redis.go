@@ -0,0 +1,69 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/conf"
+)
+
+// redisChannel is the Redis channel shared by all the instances.
+const redisChannel = "nekobox:pubsub"
+
+// Start relays the events through Redis if it is configured, so the events published
+// on one instance reach the subscribers of the others. It stops when the context is done.
+func Start(ctx context.Context) {
+	if conf.Redis.Addr == "" {
+		return
+	}
+
+	client := redis.NewClient(&redis.Options{
+		Addr:     conf.Redis.Addr,
+		Password: conf.Redis.Password,
+	})
+	transport = func(ctx context.Context, event Event) error {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return errors.Wrap(err, "marshal event")
+		}
+		if err := client.Publish(ctx, redisChannel, payload).Err(); err != nil {
+			return errors.Wrap(err, "publish")
+		}
+		return nil
+	}
+
+	go func() {
+		defer func() { _ = client.Close() }()
+
+		// The subscription reconnects automatically when the connection is lost.
+		subscription := client.Subscribe(ctx, redisChannel)
+		defer func() { _ = subscription.Close() }()
+
+		messages := subscription.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case message, ok := <-messages:
+				if !ok {
+					return
+				}
+
+				var event Event
+				if err := json.Unmarshal([]byte(message.Payload), &event); err != nil {
+					logrus.WithContext(ctx).WithError(err).Error("Failed to unmarshal pubsub event")
+					continue
+				}
+				local.dispatch(event)
+			}
+		}
+	}()
+}
@@ -0,0 +1,158 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package push sends the Web Push notifications to the subscribed browsers of the users.
+package push
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/SherClockHolmes/webpush-go"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/conf"
+	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/security/ssrf"
+)
+
+// Notification is the payload received by the service worker, which shows it with the title and body,
+// and opens the URL when it is clicked.
+type Notification struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	URL   string `json:"url"`
+}
+
+const (
+	workers        = 2
+	queueSize      = 1024
+	requestTimeout = 10 * time.Second
+	// ttl is how long the push service keeps the notification for the offline browser.
+	ttl = 24 * 60 * 60
+	// maxBodyLength keeps the encrypted payload under the 4KB limit of the push services.
+	maxBodyLength = 200
+)
+
+type delivery struct {
+	Subscription *db.PushSubscription
+	Payload      []byte
+}
+
+var (
+	deliveries = make(chan delivery, queueSize)
+
+	// The endpoints are provided by the browsers, which must not point to the internal network.
+	client = &http.Client{
+		Timeout: requestTimeout,
+		Transport: &http.Transport{
+			DialContext: (&net.Dialer{
+				Timeout: requestTimeout,
+				Control: ssrf.DenyPrivateAddress,
+			}).DialContext,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+)
+
+// Enabled returns whether the VAPID keys are configured.
+func Enabled() bool {
+	return conf.Push.VAPIDPublicKey != "" && conf.Push.VAPIDPrivateKey != ""
+}
+
+// Notify sends the notification to all the subscribed browsers of the user,
+// the notifications are delivered by the workers in the background.
+func Notify(ctx context.Context, userID uint, notification Notification) {
+	if !Enabled() {
+		return
+	}
+
+	subscriptions, err := db.PushSubscriptions.GetByUserID(ctx, userID)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("Failed to get push subscriptions by user ID")
+		return
+	}
+	if len(subscriptions) == 0 {
+		return
+	}
+
+	if body := []rune(notification.Body); len(body) > maxBodyLength {
+		notification.Body = string(body[:maxBodyLength]) + "…"
+	}
+	payload, err := json.Marshal(notification)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("Failed to marshal push notification")
+		return
+	}
+
+	for _, subscription := range subscriptions {
+		select {
+		case deliveries <- delivery{Subscription: subscription, Payload: payload}:
+		default:
+			logrus.WithContext(ctx).WithField("push_subscription_id", subscription.ID).Warn("Push queue is full, drop the notification")
+		}
+	}
+}
+
+// Start starts the push delivery workers, the workers stop when the context is done.
+func Start(ctx context.Context) {
+	for i := 0; i < workers; i++ {
+		go work(ctx)
+	}
+}
+
+func work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case d := <-deliveries:
+			if err := send(ctx, d); err != nil {
+				logrus.WithContext(ctx).WithError(err).WithField("push_subscription_id", d.Subscription.ID).Warn("Failed to send push notification")
+			}
+		}
+	}
+}
+
+func send(ctx context.Context, d delivery) error {
+	resp, err := webpush.SendNotificationWithContext(ctx, d.Payload, &webpush.Subscription{
+		Endpoint: d.Subscription.Endpoint,
+		Keys: webpush.Keys{
+			P256dh: d.Subscription.P256dh,
+			Auth:   d.Subscription.Auth,
+		},
+	}, &webpush.Options{
+		HTTPClient:      client,
+		Subscriber:      conf.Push.Subject,
+		VAPIDPublicKey:  conf.Push.VAPIDPublicKey,
+		VAPIDPrivateKey: conf.Push.VAPIDPrivateKey,
+		TTL:             ttl,
+	})
+	if err != nil {
+		return errors.Wrap(err, "send notification")
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		// The subscription has expired or been revoked by the user in the browser.
+		if err := db.PushSubscriptions.DeleteByID(ctx, d.Subscription.ID); err != nil {
+			return errors.Wrap(err, "delete expired subscription")
+		}
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		return errors.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// GenerateKeys returns a new pair of the VAPID keys.
+func GenerateKeys() (privateKey, publicKey string, err error) {
+	return webpush.GenerateVAPIDKeys()
+}
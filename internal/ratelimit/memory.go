@@ -0,0 +1,74 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+var _ Store = (*memoryStore)(nil)
+
+// memoryStore keeps the token buckets in memory, which is only suitable for the single instance deployment.
+type memoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+	expiredAt time.Time
+}
+
+func NewMemoryStore() Store {
+	return &memoryStore{
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+	}
+}
+
+func (s *memoryStore) Take(_ context.Context, key string, rate Rate) (bool, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.sweep(now)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(rate.Burst), updatedAt: now}
+		s.buckets[key] = b
+	}
+
+	// Refill the bucket with the tokens added since the last update.
+	b.tokens = math.Min(float64(rate.Burst), b.tokens+float64(now.Sub(b.updatedAt))/float64(rate.Interval))
+	b.updatedAt = now
+	// The bucket will be full again after the expiration, so it can be removed safely.
+	b.expiredAt = now.Add(time.Duration(rate.Burst) * rate.Interval)
+
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) * float64(rate.Interval)), nil
+	}
+	b.tokens--
+	return true, 0, nil
+}
+
+// sweep removes the expired buckets every minute, so that the map won't grow forever.
+func (s *memoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+
+	for key, b := range s.buckets {
+		if now.After(b.expiredAt) {
+			delete(s.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package ratelimit
+
+import (
+	"math"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/flamego/flamego"
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/conf"
+	"github.com/NekoWheel/NekoBox/internal/context"
+)
+
+var (
+	store     Store
+	storeOnce sync.Once
+)
+
+// defaultStore returns the Redis store if Redis is configured, otherwise the memory store.
+func defaultStore() Store {
+	storeOnce.Do(func() {
+		if conf.Redis.Addr == "" {
+			store = NewMemoryStore()
+			return
+		}
+		store = NewRedisStore(redis.NewClient(&redis.Options{
+			Addr:     conf.Redis.Addr,
+			Password: conf.Redis.Password,
+			DB:       2,
+		}))
+	})
+	return store
+}
+
+// Limit limits the requests with the token bucket of the given name. The requests are limited
+// by the client IP address, and also by the user if the user has signed in.
+func Limit(name string, rate Rate) flamego.Handler {
+	return func(ctx context.Context, endpoint context.EndpointType) error {
+		keys := []string{name + ":ip:" + ctx.RealIP()}
+		if ctx.IsLogged {
+			keys = append(keys, name+":user:"+strconv.Itoa(int(ctx.User.ID)))
+		}
+
+		for _, key := range keys {
+			allowed, retryAfter, err := defaultStore().Take(ctx.Request().Context(), key, rate)
+			if err != nil {
+				// Don't block the request if the store is unavailable.
+				logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to take rate limit token")
+				return nil
+			}
+			if allowed {
+				continue
+			}
+
+			ctx.ResponseWriter().Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			if endpoint.IsAPI() {
+				return ctx.JSONError(http.StatusTooManyRequests*100, "操作太频繁，请稍后再试")
+			}
+			ctx.SetErrorFlash("操作太频繁，请稍后再试")
+			ctx.Redirect(refererPath(ctx))
+			return nil
+		}
+		return nil
+	}
+}
+
+// refererPath returns the path of the page which the limited request was sent from, the limited
+// endpoints only accept POST requests so they can't be redirected back to. The host of the referer
+// is dropped so that the request can't be redirected to the other sites.
+func refererPath(ctx context.Context) string {
+	referer, err := url.Parse(ctx.Request().Referer())
+	if err != nil || referer.Path == "" || strings.Contains(referer.Path, "\\") {
+		return "/"
+	}
+
+	to := path.Clean("/" + referer.Path)
+	if referer.RawQuery != "" {
+		to += "?" + referer.RawQuery
+	}
+	return to
+}
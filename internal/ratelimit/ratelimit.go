@@ -0,0 +1,24 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Rate is the token bucket rate, the bucket holds at most Burst tokens,
+// and a token is added to the bucket every Interval.
+type Rate struct {
+	Burst    int
+	Interval time.Duration
+}
+
+// Store is the storage of the token buckets.
+type Store interface {
+	// Take takes a token from the bucket of the key, it returns false
+	// and the duration to wait for the next token if the bucket is empty.
+	Take(ctx context.Context, key string, rate Rate) (allowed bool, retryAfter time.Duration, err error)
+}
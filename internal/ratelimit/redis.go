@@ -0,0 +1,64 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+)
+
+var _ Store = (*redisStore)(nil)
+
+// redisStore keeps the token buckets in Redis, which is shared by all the instances.
+type redisStore struct {
+	client *redis.Client
+}
+
+func NewRedisStore(client *redis.Client) Store {
+	return &redisStore{client: client}
+}
+
+// takeScript refills and takes a token from the bucket atomically.
+// KEYS[1]: bucket key, ARGV[1]: burst, ARGV[2]: interval in milliseconds, ARGV[3]: now in milliseconds.
+var takeScript = redis.NewScript(`
+local burst = tonumber(ARGV[1])
+local interval = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local bucket = redis.call("HMGET", KEYS[1], "tokens", "updated_at")
+local tokens = tonumber(bucket[1]) or burst
+local updated_at = tonumber(bucket[2]) or now
+
+tokens = math.min(burst, tokens + math.max(0, now - updated_at) / interval)
+
+local allowed = 0
+local retry_after = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	retry_after = math.ceil((1 - tokens) * interval)
+end
+
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "updated_at", now)
+redis.call("PEXPIRE", KEYS[1], math.ceil(burst * interval))
+return {allowed, retry_after}
+`)
+
+func (s *redisStore) Take(ctx context.Context, key string, rate Rate) (bool, time.Duration, error) {
+	result, err := takeScript.Run(ctx, s.client, []string{"ratelimit:" + key},
+		rate.Burst, rate.Interval.Milliseconds(), time.Now().UnixMilli(),
+	).Int64Slice()
+	if err != nil {
+		return false, 0, errors.Wrap(err, "run take script")
+	}
+	if len(result) != 2 {
+		return false, 0, errors.Errorf("unexpected script result: %v", result)
+	}
+	return result[0] == 1, time.Duration(result[1]) * time.Millisecond, nil
+}
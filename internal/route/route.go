@@ -14,27 +14,89 @@ import (
 	cacheRedis "github.com/flamego/cache/redis"
 	"github.com/flamego/csrf"
 	"github.com/flamego/flamego"
-	"github.com/flamego/recaptcha"
 	"github.com/flamego/session"
 	"github.com/flamego/session/mysql"
-	sessionRedis "github.com/flamego/session/redis"
+	"github.com/flamego/session/postgres"
 	"github.com/flamego/template"
 	"github.com/sirupsen/logrus"
 
 	"github.com/NekoWheel/NekoBox/internal/conf"
 	"github.com/NekoWheel/NekoBox/internal/context"
+	"github.com/NekoWheel/NekoBox/internal/db"
 	"github.com/NekoWheel/NekoBox/internal/form"
+	"github.com/NekoWheel/NekoBox/internal/logging"
+	"github.com/NekoWheel/NekoBox/internal/ratelimit"
+	"github.com/NekoWheel/NekoBox/internal/security/captcha"
+	"github.com/NekoWheel/NekoBox/internal/sessionstore"
 	templatepkg "github.com/NekoWheel/NekoBox/internal/template"
 	"github.com/NekoWheel/NekoBox/route"
+	"github.com/NekoWheel/NekoBox/route/admin"
 	"github.com/NekoWheel/NekoBox/route/auth"
+	"github.com/NekoWheel/NekoBox/route/federation"
 	"github.com/NekoWheel/NekoBox/route/question"
 	"github.com/NekoWheel/NekoBox/route/user"
 	"github.com/NekoWheel/NekoBox/static"
 	"github.com/NekoWheel/NekoBox/templates"
 )
 
+// sessionStore returns the session store configured by the `store` of the session section.
+// We prefer to save session into Redis or database if the store is not configured,
+// if no database configuration, the session will be saved into memory instead.
+// SQLite is for the single instance, so the session is saved into files next to the database.
+func sessionStore() (session.Initer, interface{}) {
+	store := conf.Session.Store
+	if store == "" {
+		switch {
+		case conf.Redis.Addr != "":
+			store = "redis"
+		case conf.Database.DSN != "":
+			store = "database"
+		default:
+			store = "memory"
+		}
+	}
+	if store == "database" && conf.Database.Type == "sqlite" {
+		store = "file"
+	}
+
+	switch store {
+	case "redis":
+		// The sessions are shared by all the instances with the sliding expiration.
+		return sessionstore.RedisIniter(), sessionstore.RedisConfig{
+			Options: &cacheRedis.Options{
+				Addr:     conf.Redis.Addr,
+				Password: conf.Redis.Password,
+				DB:       conf.Session.RedisDB,
+			},
+			Lifetime: db.UserSessionLifetime,
+		}
+	case "file":
+		return session.FileIniter(), session.FileConfig{
+			RootDir:  "data/sessions",
+			Lifetime: db.UserSessionLifetime,
+		}
+	case "database":
+		switch conf.Database.Type {
+		case "postgres":
+			return postgres.Initer(), postgres.Config{
+				DSN:       conf.Database.DSN,
+				Lifetime:  db.UserSessionLifetime,
+				InitTable: true,
+			}
+		default:
+			return mysql.Initer(), mysql.Config{
+				DSN:      conf.Database.DSN,
+				Lifetime: db.UserSessionLifetime,
+			}
+		}
+	}
+	return session.MemoryIniter(), nil
+}
+
 func New() *flamego.Flame {
-	f := flamego.Classic()
+	// The access logs are written by the logging middleware with the request IDs.
+	f := flamego.New()
+	f.Use(logging.Middleware(), flamego.Recovery(), flamego.Static())
 	if conf.App.Production {
 		flamego.SetEnv(flamego.EnvTypeProd)
 	}
@@ -44,49 +106,86 @@ func New() *flamego.Flame {
 		logrus.WithError(err).Fatal("Failed to embed templates file system")
 	}
 
-	// We prefer to save session into database,
-	// if no database configuration, the session will be saved into memory instead.
+	captchaProvider, err := captcha.New(captcha.Options{
+		Provider: conf.Recaptcha.Provider,
+		Domain:   conf.Recaptcha.Domain,
+		Secret:   conf.Recaptcha.ServerKey,
+	})
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to create captcha")
+	}
+
 	gob.Register(time.Time{})
 	gob.Register(context.Flash{})
-	var sessionStorage interface{}
-	initer := session.MemoryIniter()
-	if conf.Database.DSN != "" {
-		initer = mysql.Initer()
-		sessionStorage = mysql.Config{
-			DSN:      conf.Database.DSN,
-			Lifetime: 7 * 24 * time.Hour,
-		}
-	}
-	if conf.Redis.Addr != "" {
-		initer = sessionRedis.Initer()
-		sessionStorage = sessionRedis.Config{
-			Options: &cacheRedis.Options{
-				Addr:     conf.Redis.Addr,
-				Password: conf.Redis.Password,
-				DB:       1,
-			},
-			Lifetime: 7 * 24 * time.Hour,
-		}
-	}
+	initer, sessionStorage := sessionStore()
 	sessioner := session.Sessioner(session.Options{
 		Initer: initer,
 		Config: sessionStorage,
 	})
 
+	// The cache is saved into memory if Redis is not configured, which only works for a single instance.
+	cacher := cache.Cacher()
+	if conf.Redis.Addr != "" {
+		cacher = cache.Cacher(cache.Options{
+			Initer: cacheRedis.Initer(),
+			Config: cacheRedis.Config{
+				Options: &cacheRedis.Options{
+					Addr:     conf.Redis.Addr,
+					Password: conf.Redis.Password,
+					DB:       0,
+				},
+			},
+		})
+	}
+
 	f.Use(flamego.Static(flamego.StaticOptions{
 		FileSystem: http.FS(static.FS),
 		Prefix:     "/static",
 	}))
 
+	f.Get("/healthz", route.Healthz)
+	f.Get("/readyz", route.Readyz)
+
 	reqUserSignOut := context.Toggle(&context.ToggleOptions{UserSignOutRequired: true})
 	reqUserSignIn := context.Toggle(&context.ToggleOptions{UserSignInRequired: true})
+	reqAdmin := context.Toggle(&context.ToggleOptions{UserSignInRequired: true, AdminRequired: true})
+	// The API endpoints which can be called with the personal access tokens of the given scopes.
+	reqReadQuestions := context.Toggle(&context.ToggleOptions{UserSignInRequired: true, Scope: db.AccessTokenScopeReadQuestions})
+	reqWriteAnswers := context.Toggle(&context.ToggleOptions{UserSignInRequired: true, Scope: db.AccessTokenScopeWriteAnswers})
+	optReadQuestions := context.Toggle(&context.ToggleOptions{Scope: db.AccessTokenScopeReadQuestions})
+	optWriteAnswers := context.Toggle(&context.ToggleOptions{Scope: db.AccessTokenScopeWriteAnswers})
+	// The API endpoints which can't be called with the personal access tokens.
+	noAccessToken := context.Toggle(&context.ToggleOptions{})
+
+	askRateLimit := ratelimit.Limit("ask", ratelimit.Rate{Burst: 5, Interval: time.Minute})
+	previewRateLimit := ratelimit.Limit("ask-preview", ratelimit.Rate{Burst: 10, Interval: time.Minute})
+	loginRateLimit := ratelimit.Limit("login", ratelimit.Rate{Burst: 10, Interval: time.Minute})
+	passwordResetRateLimit := ratelimit.Limit("password-reset", ratelimit.Rate{Burst: 3, Interval: 10 * time.Minute})
+	magicLoginRateLimit := ratelimit.Limit("magic-login", ratelimit.Rate{Burst: 3, Interval: 10 * time.Minute})
+	reactionRateLimit := ratelimit.Limit("reaction", ratelimit.Rate{Burst: 20, Interval: time.Minute})
+	reportRateLimit := ratelimit.Limit("report", ratelimit.Rate{Burst: 5, Interval: 10 * time.Minute})
+	inboxRateLimit := ratelimit.Limit("activitypub-inbox", ratelimit.Rate{Burst: 60, Interval: time.Minute})
 
 	f.Group("", func() {
 		f.Get("/", route.Home)
 		f.Get("/sponsor", route.Sponsor)
 		f.Get("/change-logs", route.ChangeLogs)
 		f.Get("/robots.txt", func(c context.Context) {
-			_, _ = c.ResponseWriter().Write([]byte("User-agent: *\nDisallow: /_/"))
+			_, _ = c.ResponseWriter().Write([]byte("User-agent: *\nDisallow: /user/\nDisallow: /admin/\nDisallow: /api/\nDisallow: /mail/\nAllow: /_/\nSitemap: " + conf.App.ExternalURL + "/sitemap.xml"))
+		})
+		f.Get("/sitemap.xml", route.Sitemap)
+		f.Get("/sitemaps/{name}", route.SitemapFile)
+		f.Get("/sw.js", func(c context.Context) {
+			fs, _ := static.FS.Open("sw.js")
+			defer func() { _ = fs.Close() }()
+			c.ResponseWriter().Header().Set("Content-Type", "application/javascript")
+			_, _ = io.Copy(c.ResponseWriter(), fs)
+		})
+		f.Get("/embed.js", func(c context.Context) {
+			fs, _ := static.FS.Open("embed.js")
+			defer func() { _ = fs.Close() }()
+			c.ResponseWriter().Header().Set("Content-Type", "application/javascript")
+			_, _ = io.Copy(c.ResponseWriter(), fs)
 		})
 		f.Get("/favicon.ico", func(c context.Context) {
 			fs, _ := static.FS.Open("favicon.ico")
@@ -97,62 +196,217 @@ func New() *flamego.Flame {
 
 		f.Group("", func() {
 			f.Combo("/register").Get(auth.Register).Post(form.Bind(form.Register{}), auth.RegisterAction)
-			f.Combo("/login").Get(auth.Login).Post(form.Bind(form.Login{}), auth.LoginAction)
-			f.Combo("/forgot-password").Get(auth.ForgotPassword).Post(form.Bind(form.ForgotPassword{}), auth.ForgotPasswordAction)
-			f.Combo("/recover-password").Get(auth.RecoverPassword).Post(form.Bind(form.RecoverPassword{}), auth.RecoverPasswordAction)
+			f.Get("/register/domain", auth.CheckDomain)
+			f.Combo("/login").Get(auth.Login).Post(loginRateLimit, form.Bind(form.Login{}), auth.LoginAction)
+			f.Combo("/login/two-factor").Get(auth.LoginTwoFactor).Post(loginRateLimit, form.Bind(form.TwoFactor{}), auth.LoginTwoFactorAction)
+			f.Combo("/login/two-factor/recovery").Get(auth.LoginTwoFactorRecovery).Post(loginRateLimit, form.Bind(form.TwoFactorRecovery{}), auth.LoginTwoFactorRecoveryAction)
+			f.Combo("/login/magic").Get(auth.MagicLogin).Post(magicLoginRateLimit, form.Bind(form.MagicLogin{}), auth.MagicLoginAction)
+			f.Combo("/login/magic/verify").Get(auth.VerifyMagicLogin).Post(loginRateLimit, auth.VerifyMagicLoginAction)
+			f.Combo("/forgot-password").Get(auth.ForgotPassword).Post(passwordResetRateLimit, form.Bind(form.ForgotPassword{}), auth.ForgotPasswordAction)
+			f.Combo("/recover-password").Get(auth.RecoverPassword).Post(passwordResetRateLimit, form.Bind(form.RecoverPassword{}), auth.RecoverPasswordAction)
 		}, reqUserSignOut)
 
+		f.Get("/u/{domain}/feed.atom", question.Feed)
+		f.Get("/u/{domain}/random", question.Random)
+		f.Get("/q/{questionID}/card.png", question.ShareCard)
+		f.Get("/q/{questionID}/qr.png", question.QRCode)
+		f.Get("/s/{slug}", question.ShortLink)
+		f.Combo("/delete-account").Get(user.ConfirmDeleteAccount).Post(user.ConfirmDeleteAccountAction)
+		f.Combo("/email/verify").Get(user.VerifyEmailChange).Post(user.VerifyEmailChangeAction)
+		f.Combo("/email/cancel").Get(user.CancelEmailChangeByMail).Post(user.CancelEmailChangeByMailAction)
+		f.Combo("/mail/unsubscribe").Get(route.Unsubscribe).Post(route.UnsubscribeAction)
+		f.Post("/mail/inbound", question.AnswerByMail)
+
+		f.Get("/.well-known/webfinger", federation.Enabled, federation.WebFinger)
+		f.Group("/ap", func() {
+			f.Post("/inbox", inboxRateLimit, federation.SharedInbox)
+			f.Group("/users/{userID}", func() {
+				f.Get("", federation.Actor)
+				f.Post("/inbox", inboxRateLimit, federation.Inbox)
+				f.Get("/outbox", federation.Outbox)
+				f.Get("/followers", federation.Followers)
+				f.Get("/notes/{questionID}", federation.Note)
+			}, federation.Actorer)
+		}, federation.Enabled, context.APIEndpoint)
+
+		f.Group("/embed/{domain}", func() {
+			f.Get("", question.Embed)
+			f.Group("/questions", func() {
+				f.Options("", question.EmbedCORS)
+				f.Post("", question.EmbedCORS, askRateLimit, form.Bind(form.NewQuestion{}), question.NewEmbed)
+			}, context.APIEndpoint)
+			f.Get("/random", context.APIEndpoint, question.EmbedCORS, question.RandomEmbed)
+		}, question.Embedder)
+
 		f.Group("/_/{domain}", func() {
-			f.Combo("").Get(question.List).Post(form.Bind(form.NewQuestion{}), question.New)
+			f.Combo("").Get(question.List).Post(askRateLimit, form.Bind(form.NewQuestion{}), question.New)
+			f.Post("/preview", previewRateLimit, form.Bind(form.NewQuestion{}), question.Preview)
+			f.Post("/draft", reqUserSignIn, form.Bind(form.SaveQuestionDraft{}), question.SaveDraft)
 			f.Group("/{questionID}", func() {
 				f.Get("", question.Item)
 				f.Post("/delete", question.Delete)
 				f.Post("/answer", reqUserSignIn, form.Bind(form.PublishAnswerQuestion{}), question.PublishAnswer)
+				f.Post("/answer/edit", reqUserSignIn, form.Bind(form.UpdateAnswerQuestion{}), question.UpdateAnswer)
+				f.Post("/answer/audio", reqUserSignIn, question.UploadAnswerAudio)
+				f.Post("/answer/audio/delete", reqUserSignIn, question.DeleteAnswerAudio)
+				f.Post("/reply", form.Bind(form.NewQuestionReply{}), question.Reply)
+				f.Post("/pin", reqUserSignIn, question.Pin)
+				f.Post("/unpin", reqUserSignIn, question.Unpin)
+				f.Post("/block", reqUserSignIn, question.Block)
+				f.Post("/shadow-ban", reqUserSignIn, question.ShadowBan)
+				f.Get("/translation", reqUserSignIn, question.Translation)
+				f.Post("/forward", reqUserSignIn, form.Bind(form.ForwardQuestion{}), question.Forward)
+				f.Post("/note", reqUserSignIn, form.Bind(form.UpdateQuestionNote{}), question.UpdateNote)
+				f.Post("/merge", reqUserSignIn, form.Bind(form.MergeQuestion{}), question.Merge)
+				f.Get("/revisions", reqUserSignIn, question.AnswerRevisions)
+				f.Post("/revisions/{revisionID}/restore", reqAdmin, question.RestoreAnswerRevision)
+				f.Post("/like", reactionRateLimit, question.Like)
+				f.Post("/report", reportRateLimit, form.Bind(form.ReportQuestion{}), question.Report)
 			}, question.Questioner)
 		}, question.Pager)
 
 		f.Group("/user", func() {
 			f.Get("/questions", user.QuestionList)
+			f.Post("/questions/bulk", form.Bind(form.BulkQuestions{}), user.BulkQuestions)
+			f.Post("/questions/read-all", user.MarkAllQuestionsRead)
+			f.Get("/questions/events", user.QuestionEvents)
+			f.Get("/stats", user.Stats)
+			f.Group("/trash", func() {
+				f.Get("", user.Trash)
+				f.Post("/{questionID}/restore", user.RestoreQuestion)
+			})
+			f.Group("/webhooks", func() {
+				f.Combo("").Get(user.Webhooks).Post(form.Bind(form.NewWebhook{}), user.NewWebhook)
+				f.Post("/{webhookID}/delete", user.DeleteWebhook)
+			})
+			f.Group("/access-tokens", func() {
+				f.Combo("").Get(user.AccessTokens).Post(form.Bind(form.NewAccessToken{}), user.NewAccessToken)
+				f.Post("/{accessTokenID}/delete", user.DeleteAccessToken)
+			})
+			f.Group("/sessions", func() {
+				f.Get("", user.Sessions)
+				f.Post("/delete-others", user.DeleteOtherSessions)
+				f.Post("/{sessionID}/delete", user.DeleteSession)
+			})
+			f.Group("/tags", func() {
+				f.Get("", user.Tags)
+				f.Post("/rename", form.Bind(form.RenameTag{}), user.RenameTag)
+				f.Post("/delete", form.Bind(form.DeleteTag{}), user.DeleteTag)
+			})
+			f.Group("/two-factor", func() {
+				f.Get("", user.TwoFactor)
+				f.Combo("/enable").Get(user.EnableTwoFactor).Post(form.Bind(form.TwoFactor{}), user.EnableTwoFactorAction)
+				f.Post("/disable", form.Bind(form.DisableTwoFactor{}), user.DisableTwoFactor)
+				f.Post("/recovery-codes", form.Bind(form.RegenerateRecoveryCodes{}), user.RegenerateRecoveryCodes)
+			})
+			f.Combo("/import").Get(user.ImportQuestions).Post(form.Bind(form.ImportQuestions{}), user.ImportQuestionsAction)
+			f.Group("/prompts", func() {
+				f.Combo("").Get(user.Prompts).Post(form.Bind(form.NewPrompt{}), user.NewPrompt)
+				f.Post("/{promptID}/delete", user.DeletePrompt)
+			})
+			f.Group("/push", func() {
+				f.Post("/subscribe", form.Bind(form.SubscribePush{}), user.SubscribePush)
+				f.Post("/unsubscribe", form.Bind(form.UnsubscribePush{}), user.UnsubscribePush)
+			})
+			f.Group("/blocks", func() {
+				f.Get("", user.Blocks)
+				f.Post("/{blockID}/delete", user.Unblock)
+			})
+			f.Group("/word-filters", func() {
+				f.Combo("").Get(user.WordFilters).Post(form.Bind(form.NewWordFilter{}), user.NewWordFilter)
+				f.Post("/{wordFilterID}/delete", user.DeleteWordFilter)
+			})
+			f.Group("/answer-templates", func() {
+				f.Combo("").Get(user.AnswerTemplates).Post(form.Bind(form.AnswerTemplate{}), user.NewAnswerTemplate)
+				f.Post("/{answerTemplateID}/update", form.Bind(form.AnswerTemplate{}), user.UpdateAnswerTemplate)
+				f.Post("/{answerTemplateID}/delete", user.DeleteAnswerTemplate)
+			})
+			f.Group("/crosspost", func() {
+				f.Get("", user.Crosspost)
+				f.Post("/twitter", user.LinkTwitter)
+				f.Get("/twitter/callback", user.TwitterCallback)
+				f.Post("/mastodon", form.Bind(form.LinkMastodon{}), user.LinkMastodon)
+				f.Post("/{provider}/delete", user.UnlinkCrosspost)
+			})
+			f.Group("/members", func() {
+				f.Combo("").Get(user.Members).Post(form.Bind(form.NewBoxMember{}), user.NewMember)
+				f.Post("/{userID}/role", form.Bind(form.UpdateBoxMember{}), user.UpdateMember)
+				f.Post("/{userID}/delete", user.DeleteMember)
+			})
+			f.Group("/boxes", func() {
+				f.Get("", user.Boxes)
+				f.Get("/{domain}", user.BoxQuestions)
+				f.Post("/{domain}/leave", user.LeaveBox)
+			})
 
 			f.Group("/profile", func() {
 				f.Get("", user.Profile)
 				f.Post("/update", form.Bind(form.UpdateProfile{}), user.UpdateProfile)
 				f.Post("/export", user.ExportProfile)
+				f.Post("/export/zip", user.ProfileExport)
+				f.Post("/export/site", user.ProfileExportSite)
+				f.Combo("/email").Get(user.ChangeEmail).Post(form.Bind(form.ChangeEmail{}), user.ChangeEmailAction)
+				f.Post("/email/cancel", user.CancelEmailChange)
 				f.Combo("/deactivate").Get(user.DeactivateProfile).Post(user.DeactivateProfileAction)
+				f.Combo("/delete").Get(user.DeleteProfile).Post(form.Bind(form.DeleteProfile{}), user.DeleteProfileAction)
 			})
 			f.Post("/harassment/update", form.Bind(form.UpdateHarassment{}), user.UpdateHarassment)
+			f.Post("/box-settings/update", form.Bind(form.UpdateBoxSettings{}), user.UpdateBoxSettings)
+			f.Post("/away/update", form.Bind(form.UpdateAwayStatus{}), user.UpdateAwayStatus)
+			f.Post("/theme/update", form.Bind(form.UpdateProfileTheme{}), user.UpdateProfileTheme)
+			f.Post("/license/update", form.Bind(form.UpdateContentLicense{}), user.UpdateContentLicense)
 
 			f.Get("/logout", auth.Logout)
 		}, reqUserSignIn)
 
+		f.Group("/admin", func() {
+			f.Get("/audit-logs", admin.AuditLogs)
+			f.Get("/reports", admin.Reports)
+			f.Post("/reports/{questionID}/resolve", form.Bind(form.ResolveReports{}), admin.ResolveReports)
+			f.Post("/reports/{questionID}/shadow-ban", admin.ShadowBanAsker)
+			f.Get("/censor-feedback", admin.CensorFeedback)
+			f.Get("/cron", admin.CronJobs)
+			f.Get("/export", admin.Export)
+			f.Get("/export/questions", admin.ExportQuestions)
+			f.Get("/shadow-bans", admin.ShadowBans)
+			f.Post("/shadow-bans/{blockID}/delete", admin.DeleteShadowBan)
+			f.Get("/users", admin.Users)
+			f.Post("/users/{userID}/state", form.Bind(form.UpdateUserState{}), admin.UpdateUserState)
+		}, reqAdmin)
+
 		f.Group("/api/v1", func() {
+			f.Group("/auth", func() {
+				f.Post("/login", loginRateLimit, form.Bind(form.TokenLogin{}), auth.LoginAPI)
+			})
+
 			f.Group("/user", func() {
-				f.Get("", reqUserSignIn, user.ProfileAPI)
+				f.Get("", reqReadQuestions, user.ProfileAPI)
+				f.Post("/profile", reqUserSignIn, form.Bind(form.UpdateProfile{}), user.UpdateProfileAPI)
+				f.Get("/questions", reqReadQuestions, user.QuestionListAPI)
+				f.Get("/questions/queue", reqReadQuestions, user.QuestionQueueAPI)
+				f.Get("/questions/next", reqReadQuestions, user.NextQuestionAPI)
+				f.Get("/questions/export", reqReadQuestions, user.ExportQuestionsAPI)
+				f.Post("/questions/import", reqWriteAnswers, user.ImportQuestionsAPI)
+				f.Get("/answer-templates", reqWriteAnswers, user.AnswerTemplatesAPI)
 
 				f.Group("/{domain}", func() {
 					f.Group("/questions", func() {
-						f.Get("", question.ListAPI)
+						f.Combo("").Get(optReadQuestions, question.ListAPI).Post(noAccessToken, askRateLimit, form.Bind(form.NewQuestion{}), question.NewAPI)
+						f.Post("/preview", noAccessToken, previewRateLimit, form.Bind(form.NewQuestion{}), question.PreviewAPI)
+						f.Group("/{questionID}", func() {
+							f.Combo("").Get(optReadQuestions, question.ItemAPI).Delete(optWriteAnswers, question.DeleteAPI)
+							f.Combo("/answer").
+								Post(reqWriteAnswers, form.Bind(form.PublishAnswerQuestion{}), question.PublishAnswerAPI).
+								Put(reqWriteAnswers, form.Bind(form.UpdateAnswerQuestion{}), question.UpdateAnswerAPI)
+							f.Post("/like", noAccessToken, reactionRateLimit, question.LikeAPI)
+						}, question.QuestionerAPI)
 					})
-				})
+				}, question.PagerAPI)
 			})
 		}, context.APIEndpoint)
 	},
-		cache.Cacher(cache.Options{
-			Initer: cacheRedis.Initer(),
-			Config: cacheRedis.Config{
-				Options: &cacheRedis.Options{
-					Addr:     conf.Redis.Addr,
-					Password: conf.Redis.Password,
-					DB:       0,
-				},
-			},
-		}),
-		recaptcha.V2(
-			recaptcha.Options{
-				Secret:    conf.Recaptcha.ServerKey,
-				VerifyURL: recaptcha.VerifyURLGlobal,
-			},
-		),
+		cacher,
+		captcha.Captcher(captchaProvider),
 		sessioner,
 		csrf.Csrfer(csrf.Options{
 			Secret: conf.Server.XSRFKey,
@@ -0,0 +1,88 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package boxdomain validates the domains of the boxes, which are used in the box URLs, e.g. `/_/{domain}`.
+package boxdomain
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	MinLength = 3
+	MaxLength = 20
+)
+
+var (
+	ErrInvalid  = errors.New("个性域名只能包含字母、数字、“-”和“_”，长度为 3 到 20 个字符")
+	ErrReserved = errors.New("这个个性域名被保留了，换一个吧~")
+)
+
+var pattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// reserved is the list of the domains which may be mistaken for the official accounts or the site's pages.
+var reserved = []string{
+	"about", "admin", "administrator", "api", "help", "official", "moderator", "staff", "support", "system", "root",
+	"nekobox", "neko", "nekowheel",
+	"login", "logout", "register", "signup", "signin", "user", "users", "profile", "settings", "account",
+	"static", "sitemap", "sitemaps", "feed", "robots", "favicon", "sponsor", "change-logs",
+	"www", "mail", "email", "null", "undefined",
+}
+
+var reservedSkeletons = func() map[string]struct{} {
+	skeletons := make(map[string]struct{}, len(reserved))
+	for _, domain := range reserved {
+		skeletons[Skeleton(domain)] = struct{}{}
+	}
+	return skeletons
+}()
+
+// Normalize converts the compatible characters of the domain to the canonical ones,
+// e.g. the full-width letters are converted to the ASCII letters.
+func Normalize(domain string) string {
+	return strings.TrimSpace(norm.NFKC.String(domain))
+}
+
+// Validate checks whether the normalized domain can be used for a new box.
+func Validate(domain string) error {
+	if len(domain) < MinLength || len(domain) > MaxLength || !pattern.MatchString(domain) {
+		return ErrInvalid
+	}
+	if IsReserved(domain) {
+		return ErrReserved
+	}
+	return nil
+}
+
+// IsReserved returns whether the domain is reserved or confusable with a reserved one.
+func IsReserved(domain string) bool {
+	_, ok := reservedSkeletons[Skeleton(domain)]
+	return ok
+}
+
+var (
+	confusableCharacters = strings.NewReplacer(
+		"0", "o",
+		"1", "l",
+		"i", "l",
+		"5", "s",
+		"_", "-",
+	)
+	confusableSequences = strings.NewReplacer(
+		"rn", "m",
+		"vv", "w",
+	)
+)
+
+// Skeleton returns the form of the domain with the lookalike characters folded, the domains
+// with the same skeleton are confusable, e.g. "neko", "NEKO" and "nek0".
+func Skeleton(domain string) string {
+	skeleton := strings.ToLower(Normalize(domain))
+	skeleton = confusableCharacters.Replace(skeleton)
+	return confusableSequences.Replace(skeleton)
+}
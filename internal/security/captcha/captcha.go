@@ -0,0 +1,158 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package captcha
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/flamego/flamego"
+	"github.com/pkg/errors"
+)
+
+type Provider string
+
+const (
+	ProviderRecaptcha Provider = "recaptcha"
+	ProviderHCaptcha  Provider = "hcaptcha"
+	ProviderTurnstile Provider = "turnstile"
+)
+
+// ResponseField is the form field of the captcha response token.
+// hCaptcha fills the field for reCAPTCHA compatibility, and the Turnstile widget
+// is rendered with `data-response-field-name` set to it, so all the providers share the same field.
+const ResponseField = "g-recaptcha-response"
+
+// Captcha verifies the response token of the configured captcha provider.
+type Captcha struct {
+	provider  Provider
+	secret    string
+	verifyURL string
+	scriptURL string
+	name      string
+}
+
+type Options struct {
+	Provider string
+	// Domain is the domain of the reCAPTCHA API, it is ignored by other providers.
+	Domain string
+	Secret string
+}
+
+// New returns the Captcha of the given provider, reCAPTCHA is used if no provider is specified.
+func New(opts Options) (*Captcha, error) {
+	c := &Captcha{
+		provider: Provider(strings.ToLower(strings.TrimSpace(opts.Provider))),
+		secret:   opts.Secret,
+	}
+
+	switch c.provider {
+	case "", ProviderRecaptcha:
+		domain := strings.TrimRight(opts.Domain, "/")
+		if domain == "" {
+			domain = "https://www.recaptcha.net"
+		}
+		c.provider = ProviderRecaptcha
+		c.verifyURL = domain + "/recaptcha/api/siteverify"
+		c.scriptURL = domain + "/recaptcha/api.js"
+		c.name = "reCAPTCHA"
+	case ProviderHCaptcha:
+		c.verifyURL = "https://hcaptcha.com/siteverify"
+		c.scriptURL = "https://js.hcaptcha.com/1/api.js"
+		c.name = "hCaptcha"
+	case ProviderTurnstile:
+		c.verifyURL = "https://challenges.cloudflare.com/turnstile/v0/siteverify"
+		c.scriptURL = "https://challenges.cloudflare.com/turnstile/v0/api.js"
+		c.name = "Turnstile"
+	default:
+		return nil, errors.Errorf("unexpected captcha provider: %q", opts.Provider)
+	}
+	return c, nil
+}
+
+// Captcher returns a middleware handler that injects the *Captcha into the request context.
+func Captcher(c *Captcha) flamego.Handler {
+	return func(ctx flamego.Context) {
+		ctx.Map(c)
+	}
+}
+
+func (c *Captcha) Provider() Provider {
+	return c.provider
+}
+
+func (c *Captcha) ScriptURL() string {
+	return c.scriptURL
+}
+
+// WidgetClass returns the CSS class binding the invisible captcha to the submit button.
+// Turnstile has no invisible button binding, its widget is rendered in the form separately.
+func (c *Captcha) WidgetClass() string {
+	switch c.provider {
+	case ProviderRecaptcha:
+		return "g-recaptcha"
+	case ProviderHCaptcha:
+		return "h-captcha"
+	default:
+		return ""
+	}
+}
+
+// FailedMessage returns the message shown to the user when the verification failed.
+func (c *Captcha) FailedMessage() string {
+	if c.provider == ProviderTurnstile {
+		return "人机验证失败，请等待 " + c.name + " 验证完成后再提交"
+	}
+	return "验证码错误，请重新完成 " + c.name + " 验证"
+}
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+type verifyResponse struct {
+	Success    bool     `json:"success"`
+	ErrorCodes []string `json:"error-codes"`
+}
+
+// Verify checks the response token with the provider's siteverify API,
+// all the supported providers share the same API protocol.
+func (c *Captcha) Verify(ctx context.Context, token, remoteIP string) (bool, error) {
+	if token == "" {
+		return false, nil
+	}
+
+	form := url.Values{
+		"secret":   {c.secret},
+		"response": {token},
+	}
+	if remoteIP != "" {
+		form.Set("remoteip", remoteIP)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.verifyURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return false, errors.Wrap(err, "new request")
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return false, errors.Wrapf(err, "verify %s", c.provider)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, errors.Errorf("verify %s: unexpected status code %d", c.provider, resp.StatusCode)
+	}
+
+	var respJSON verifyResponse
+	if err := json.NewDecoder(resp.Body).Decode(&respJSON); err != nil {
+		return false, errors.Wrap(err, "decode response")
+	}
+	return respJSON.Success, nil
+}
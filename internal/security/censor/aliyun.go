@@ -98,6 +98,10 @@ func (c *AliyunTextCensor) Censor(_ context.Context, text string) (*TextCensorRe
 	return AliyunTextCensorParser(resp.GetHttpContentBytes())
 }
 
+func (*AliyunTextCensor) Parse(raw []byte) (*TextCensorResponse, error) {
+	return AliyunTextCensorParser(raw)
+}
+
 func (*AliyunTextCensor) String() string {
 	return "aliyun"
 }
@@ -132,12 +136,32 @@ func AliyunTextCensorParser(raw []byte) (*TextCensorResponse, error) {
 		break
 	}
 
+	// The spans are only kept when all the flagged labels can be masked.
+	var spans []Span
+	for _, result := range responseJSON.Data[0].Results {
+		if result.Label == "normal" {
+			continue
+		}
+		if !formatAliyunForbiddenType(result.Label).Mild() {
+			spans = nil
+			break
+		}
+		for _, detail := range result.Details {
+			for _, context := range detail.Contexts {
+				for _, position := range context.Positions {
+					spans = append(spans, Span{Start: position.StartPos, End: position.EndPos})
+				}
+			}
+		}
+	}
+
 	return &TextCensorResponse{
 		SourceName:    "aliyun",
 		Pass:          responseJSON.IsPass(),
 		ForbiddenType: formatAliyunForbiddenType(label),
 		Hint:          hint,
 		Confidence:    confidence,
+		Spans:         spans,
 		RawResponse:   raw,
 	}, nil
 }
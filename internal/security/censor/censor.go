@@ -10,17 +10,20 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
 
 	"github.com/NekoWheel/NekoBox/internal/conf"
 	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/tracing"
 )
 
 var (
 	censorCacheNoMoreThan = 31 * 24 * time.Hour // 1 month
 )
 
-// Text checks the text for sensitive content.
-// It will save the censor log to the database and invoke the callback function.
+// Text checks the text for sensitive content with the configured providers in order.
+// The text passes once any of the providers passes it, otherwise the verdict of the last provider is returned.
+// It will save the censor log to the database, the previous censor logs are used as cache.
 func Text(ctx context.Context, text string) (*TextCensorResponse, error) {
 	if !conf.Security.EnableTextCensor {
 		return &TextCensorResponse{Pass: true}, nil
@@ -28,43 +31,34 @@ func Text(ctx context.Context, text string) (*TextCensorResponse, error) {
 
 	var responses []*TextCensorResponse
 
-	for _, censor := range []TextCensor{
-		NewQiniuTextCensor(conf.App.QiniuAccessKey, conf.App.QiniuAccessSecret),
-		NewAliyunTextCensor(conf.App.AliyunAccessKey, conf.App.AliyunAccessKeySecret),
-	} {
-		sourceName := censor.String()
+	for _, provider := range Providers() {
+		sourceName := provider.String()
 
 		// Try to get the censor from the database log.
-		censorLog, err := db.CensorLogs.GetByText(ctx, sourceName, text, time.Now().Add(-censorCacheNoMoreThan))
-		if err != nil {
-			if !errors.Is(err, db.ErrCensorLogsNotFound) {
-				logrus.WithContext(ctx).WithError(err).Error("Failed to get censor log")
-			}
-		} else {
-			raw := censorLog.RawResponse
-			// We got the previous censor log cache, seems like we saved money for the API call.
-			switch censorLog.SourceName {
-			case "qiniu":
-				// HACK: Qiniu's API response is not accurate, so we need to check the text again with aliyun API.
-				response, err := QiniuTextCensorParser(raw)
+		// The local provider is cheap, and its keyword list may be changed at any time, so it is never cached.
+		if _, isLocal := provider.(*LocalTextCensor); !isLocal {
+			censorLog, err := db.CensorLogs.GetByText(ctx, sourceName, text, time.Now().Add(-censorCacheNoMoreThan))
+			if err != nil {
+				if !errors.Is(err, db.ErrCensorLogsNotFound) {
+					logrus.WithContext(ctx).WithError(err).Error("Failed to get censor log")
+				}
+			} else {
+				// We got the previous censor log cache, seems like we saved money for the API call.
+				// HACK: Qiniu's API response is not accurate, so if the text is not passed, we need to check it with the next provider.
+				response, err := provider.Parse(censorLog.RawResponse)
 				if err == nil {
 					if response.Pass {
 						return response, nil
 					}
 
-					responses = append(responses, response) // If the text is not passed, we need to check it with aliyun API.
+					responses = append(responses, response)
 					continue
 				}
-
-			case "aliyun":
-				return AliyunTextCensorParser(raw)
-
-			default:
-				logrus.WithContext(ctx).WithField("censor_source", censorLog.SourceName).Error("Unknown censor source")
+				logrus.WithContext(ctx).WithError(err).WithField("censor_source", sourceName).Error("Failed to parse censor log")
 			}
 		}
 
-		response, err := censor.Censor(ctx, text)
+		response, err := censorText(ctx, provider, text)
 		if err != nil {
 			logrus.WithContext(ctx).WithError(err).WithField("censor_source", sourceName).Error("Failed to censor text")
 			continue
@@ -91,3 +85,16 @@ func Text(ctx context.Context, text string) (*TextCensorResponse, error) {
 	}
 	return responses[len(responses)-1], nil
 }
+
+// censorText calls the provider's censor API in a span.
+func censorText(ctx context.Context, provider Provider, text string) (_ *TextCensorResponse, err error) {
+	ctx, span := tracing.Start(ctx, "censor.Text", attribute.String("censor.source", provider.String()))
+	defer func() { tracing.End(span, err) }()
+
+	response, err := provider.Censor(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+	span.SetAttributes(attribute.Bool("censor.pass", response.Pass))
+	return response, nil
+}
@@ -0,0 +1,127 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package censor
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// LocalTextCensor checks the text with the local keyword list,
+// so that the self-hosted instance can run without any cloud account.
+type LocalTextCensor struct {
+	keywords []string
+	patterns []*regexp.Regexp
+}
+
+// NewLocalTextCensor creates a local text censor with the keywords and the regular expression patterns.
+// The keywords are matched case-insensitively.
+func NewLocalTextCensor(keywords []string, patterns []*regexp.Regexp) *LocalTextCensor {
+	lowerKeywords := make([]string, 0, len(keywords))
+	for _, keyword := range keywords {
+		lowerKeywords = append(lowerKeywords, strings.ToLower(keyword))
+	}
+
+	return &LocalTextCensor{
+		keywords: lowerKeywords,
+		patterns: patterns,
+	}
+}
+
+// LoadLocalTextCensor loads the keyword list file and creates a local text censor.
+// The file contains one keyword per line, the line wrapped with `/` is a regular expression,
+// and the line starts with `#` is a comment.
+func LoadLocalTextCensor(path string) (*LocalTextCensor, error) {
+	if path == "" {
+		return nil, errors.New("keywords file is not configured")
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "open keywords file")
+	}
+	defer func() { _ = f.Close() }()
+
+	var keywords []string
+	var patterns []*regexp.Regexp
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if len(line) > 2 && strings.HasPrefix(line, "/") && strings.HasSuffix(line, "/") {
+			pattern, err := regexp.Compile(line[1 : len(line)-1])
+			if err != nil {
+				return nil, errors.Wrapf(err, "compile pattern %q", line)
+			}
+			patterns = append(patterns, pattern)
+			continue
+		}
+		keywords = append(keywords, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "read keywords file")
+	}
+
+	return NewLocalTextCensor(keywords, patterns), nil
+}
+
+type LocalTextCensorResponse struct {
+	Matched string `json:"matched"`
+}
+
+// Censor censors text with the local keyword list.
+func (c *LocalTextCensor) Censor(_ context.Context, text string) (*TextCensorResponse, error) {
+	var response LocalTextCensorResponse
+
+	lowerText := strings.ToLower(text)
+	for _, keyword := range c.keywords {
+		if strings.Contains(lowerText, keyword) {
+			response.Matched = keyword
+			break
+		}
+	}
+	if response.Matched == "" {
+		for _, pattern := range c.patterns {
+			if matched := pattern.FindString(text); matched != "" {
+				response.Matched = matched
+				break
+			}
+		}
+	}
+
+	raw, err := json.Marshal(response)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal response")
+	}
+	return c.Parse(raw)
+}
+
+func (*LocalTextCensor) Parse(raw []byte) (*TextCensorResponse, error) {
+	var responseJSON LocalTextCensorResponse
+	if err := json.Unmarshal(raw, &responseJSON); err != nil {
+		return nil, errors.Wrap(err, "unmarshal response")
+	}
+
+	return &TextCensorResponse{
+		SourceName:  "local",
+		Pass:        responseJSON.Matched == "",
+		Hint:        responseJSON.Matched,
+		Confidence:  1,
+		RawResponse: raw,
+	}, nil
+}
+
+func (*LocalTextCensor) String() string {
+	return "local"
+}
@@ -0,0 +1,84 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package censor
+
+import (
+	"encoding/json"
+
+	"github.com/NekoWheel/NekoBox/internal/db"
+)
+
+// maskRune replaces each rune of the flagged spans.
+const maskRune = '■'
+
+// Mask replaces the runes in the spans with ■, the parts of the spans out of the text are ignored.
+func Mask(text string, spans []Span) string {
+	runes := []rune(text)
+	for _, span := range spans {
+		start, end := span.Start, span.End
+		if start < 0 {
+			start = 0
+		}
+		if end > len(runes) {
+			end = len(runes)
+		}
+		for i := start; i < end; i++ {
+			runes[i] = maskRune
+		}
+	}
+	return string(runes)
+}
+
+// ParseMetadata parses the censor metadata saved along with the question. The spans are parsed
+// from the raw response again with the provider for the metadata saved before they are recorded.
+func ParseMetadata(metadata []byte) (*TextCensorResponse, bool) {
+	if len(metadata) == 0 {
+		return nil, false
+	}
+
+	var response TextCensorResponse
+	if err := json.Unmarshal(metadata, &response); err != nil {
+		return nil, false
+	}
+	if response.Pass || len(response.Spans) > 0 || len(response.RawResponse) == 0 {
+		return &response, true
+	}
+
+	for _, provider := range Providers() {
+		if provider.String() != response.SourceName {
+			continue
+		}
+		if parsed, err := provider.Parse(response.RawResponse); err == nil {
+			return parsed, true
+		}
+	}
+	return &response, true
+}
+
+// MaskMetadata masks the flagged spans of the text with the censor metadata, the text is returned
+// as it is if it passes the censor or fails not only mildly.
+func MaskMetadata(text string, metadata []byte) string {
+	response, ok := ParseMetadata(metadata)
+	if !ok || !response.Maskable() {
+		return text
+	}
+	return Mask(text, response.Spans)
+}
+
+// QuestionContent returns the content of the question as it is displayed publicly, the flagged spans
+// are masked if the owner's box displays the mildly failed questions masked.
+func QuestionContent(owner *db.User, question *db.Question) string {
+	if !owner.BoxSettings.MaskProfanity || question.ContentCensorPass {
+		return question.Content
+	}
+	return MaskMetadata(question.Content, question.ContentCensorMetadata)
+}
+
+// MaskQuestions masks the content of the questions in place before they are displayed publicly.
+func MaskQuestions(owner *db.User, questions ...*db.Question) {
+	for _, question := range questions {
+		question.Content = QuestionContent(owner, question)
+	}
+}
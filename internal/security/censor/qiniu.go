@@ -113,6 +113,10 @@ func (c *QiniuTextCensor) Censor(ctx context.Context, text string) (*TextCensorR
 	return QiniuTextCensorParser(bodyBytes)
 }
 
+func (*QiniuTextCensor) Parse(raw []byte) (*TextCensorResponse, error) {
+	return QiniuTextCensorParser(raw)
+}
+
 func (*QiniuTextCensor) String() string {
 	return "qiniu"
 }
@@ -140,12 +144,30 @@ func QiniuTextCensorParser(raw []byte) (*TextCensorResponse, error) {
 		break
 	}
 
+	// The spans are only kept when all the flagged labels can be masked.
+	var spans []Span
+	for _, detail := range responseJSON.Result.Scenes.Antispam.Details {
+		if detail.Label == "normal" {
+			continue
+		}
+		if !formatQiniuForbiddenType(detail.Label).Mild() {
+			spans = nil
+			break
+		}
+		for _, context := range detail.Contexts {
+			for _, position := range context.Positions {
+				spans = append(spans, Span{Start: position.StartPos, End: position.EndPos})
+			}
+		}
+	}
+
 	return &TextCensorResponse{
 		SourceName:    "qiniu",
 		Pass:          responseJSON.IsPass(),
 		ForbiddenType: formatQiniuForbiddenType(detailKey),
 		Hint:          hint,
 		Confidence:    confidence,
+		Spans:         spans,
 		RawResponse:   raw,
 	}, nil
 }
@@ -0,0 +1,181 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package censor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/logging"
+)
+
+type JobType string
+
+const (
+	JobTypeQuestionContent JobType = "question_content"
+	JobTypeQuestionAnswer  JobType = "question_answer"
+	JobTypeQuestionReply   JobType = "question_reply"
+)
+
+// Job is a text censor task which is retried in the background when the censor service is unavailable.
+type Job struct {
+	Type JobType
+	ID   uint
+	Text string
+
+	// requestID is the ID of the request which enqueued the job, so the logs of the job
+	// are correlated to the request.
+	requestID string
+	attempt   int
+}
+
+func (j Job) key() string {
+	return fmt.Sprintf("%s:%d", j.Type, j.ID)
+}
+
+const (
+	queueWorkers     = 4
+	queueSize        = 1024
+	queueMaxAttempts = 8
+	queueBaseBackoff = 5 * time.Second
+	queueMaxBackoff  = 30 * time.Minute
+)
+
+var queue = struct {
+	sync.Mutex
+	jobs     chan Job
+	inflight map[string]struct{}
+}{
+	jobs:     make(chan Job, queueSize),
+	inflight: make(map[string]struct{}),
+}
+
+// Enqueue adds the job to the censor queue, it returns false if the job is already in the queue or the queue is full.
+func Enqueue(ctx context.Context, job Job) bool {
+	job.requestID = logging.RequestID(ctx)
+
+	queue.Lock()
+	defer queue.Unlock()
+
+	if _, ok := queue.inflight[job.key()]; ok {
+		return false
+	}
+
+	select {
+	case queue.jobs <- job:
+		queue.inflight[job.key()] = struct{}{}
+		return true
+	default:
+		logrus.WithContext(ctx).WithField("job", job.key()).Warn("Censor queue is full, drop the job")
+		return false
+	}
+}
+
+// StartQueue starts the censor queue workers, the workers stop when the context is done.
+func StartQueue(ctx context.Context) {
+	for i := 0; i < queueWorkers; i++ {
+		queueWorkerGroup.Add(1)
+		go func() {
+			defer queueWorkerGroup.Done()
+			work(ctx)
+		}()
+	}
+}
+
+var queueWorkerGroup sync.WaitGroup
+
+// WaitQueue waits for the censor queue workers to finish the jobs being processed after the context is done.
+// The jobs left in the queue are re-driven by the pending censor cron job after restarting.
+func WaitQueue() {
+	queueWorkerGroup.Wait()
+}
+
+func work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case job := <-queue.jobs:
+			process(ctx, job)
+		}
+	}
+}
+
+func process(ctx context.Context, job Job) {
+	// The job being processed is finished on shutdown instead of being failed by the cancellation.
+	jobCtx := logging.WithRequestID(context.Background(), job.requestID)
+	logger := logrus.WithContext(jobCtx).WithField("job", job.key()).WithField("attempt", job.attempt+1)
+
+	err := runJob(jobCtx, job)
+	if err == nil {
+		done(job)
+		return
+	}
+
+	job.attempt++
+	if job.attempt >= queueMaxAttempts {
+		// Give up, the job will be re-driven by the pending censor cron job later.
+		logger.WithError(err).Error("Failed to censor text, give up")
+		done(job)
+		return
+	}
+
+	backoff := queueBaseBackoff << (job.attempt - 1)
+	if backoff > queueMaxBackoff {
+		backoff = queueMaxBackoff
+	}
+	logger.WithError(err).WithField("backoff", backoff.String()).Warn("Failed to censor text, retry later")
+
+	time.AfterFunc(backoff, func() {
+		select {
+		case <-ctx.Done():
+		case queue.jobs <- job:
+		}
+	})
+}
+
+func done(job Job) {
+	queue.Lock()
+	delete(queue.inflight, job.key())
+	queue.Unlock()
+}
+
+func runJob(ctx context.Context, job Job) error {
+	response, err := Text(ctx, job.Text)
+	if err != nil {
+		return errors.Wrap(err, "censor text")
+	}
+
+	switch job.Type {
+	case JobTypeQuestionContent:
+		err = db.Questions.UpdateCensor(ctx, job.ID, db.UpdateQuestionCensorOptions{
+			ContentCensorMetadata: response.ToJSON(),
+		})
+	case JobTypeQuestionAnswer:
+		err = db.Questions.UpdateCensor(ctx, job.ID, db.UpdateQuestionCensorOptions{
+			AnswerCensorMetadata: response.ToJSON(),
+		})
+	case JobTypeQuestionReply:
+		err = db.QuestionReplies.UpdateCensor(ctx, job.ID, response.ToJSON())
+	default:
+		// Unknown job type, there is no need to retry.
+		logrus.WithContext(ctx).WithField("job", job.key()).Error("Unknown censor job type")
+		return nil
+	}
+	if err != nil {
+		// The question may be deleted before the job runs.
+		if errors.Is(err, db.ErrQuestionNotExist) {
+			return nil
+		}
+		return errors.Wrap(err, "update censor result")
+	}
+	return nil
+}
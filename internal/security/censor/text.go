@@ -8,6 +8,12 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/conf"
 )
 
 type ForbiddenType string
@@ -40,13 +46,32 @@ func (f ForbiddenType) String() string {
 	}[f]
 }
 
+// Mild returns whether the text of the forbidden type can be displayed with the flagged spans masked,
+// which is the profanity right now.
+func (f ForbiddenType) Mild() bool {
+	return f == ForbiddenTypeAbuse
+}
+
+// Span is the flagged part of the text, Start and End are the rune offsets and End is exclusive.
+type Span struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
 type TextCensorResponse struct {
-	SourceName    string          `json:"source_name"`
-	Pass          bool            `json:"pass"`
-	ForbiddenType ForbiddenType   `json:"forbidden_type"`
-	Hint          string          `json:"hint"`
-	Confidence    float64         `json:"confidence"`
-	RawResponse   json.RawMessage `json:"raw_response"`
+	SourceName    string        `json:"source_name"`
+	Pass          bool          `json:"pass"`
+	ForbiddenType ForbiddenType `json:"forbidden_type"`
+	Hint          string        `json:"hint"`
+	Confidence    float64       `json:"confidence"`
+	// Spans are the positions of the flagged text, they are only reported when the text fails mildly.
+	Spans       []Span          `json:"spans,omitempty"`
+	RawResponse json.RawMessage `json:"raw_response"`
+}
+
+// Maskable returns whether the text fails the censor only mildly, so it can be displayed with the spans masked.
+func (r *TextCensorResponse) Maskable() bool {
+	return !r.Pass && r.ForbiddenType.Mild() && len(r.Spans) > 0
 }
 
 func (r *TextCensorResponse) ToJSON() []byte {
@@ -65,7 +90,65 @@ func (r *TextCensorResponse) ErrorMessage() string {
 	return errorMessage
 }
 
-type TextCensor interface {
+// Provider is a text censor service.
+type Provider interface {
+	// Censor checks the text, the raw response is kept in the returned verdict as metadata.
 	Censor(ctx context.Context, text string) (*TextCensorResponse, error)
+	// Parse parses the raw response saved in the censor log.
+	Parse(raw []byte) (*TextCensorResponse, error)
+	// String returns the provider name, which is recorded as the `source_name` of the verdict.
 	String() string
 }
+
+// ProviderFactory creates a text censor provider with the configuration.
+type ProviderFactory func() (Provider, error)
+
+var factories = map[string]ProviderFactory{
+	"qiniu": func() (Provider, error) {
+		return NewQiniuTextCensor(conf.App.QiniuAccessKey, conf.App.QiniuAccessSecret), nil
+	},
+	"aliyun": func() (Provider, error) {
+		return NewAliyunTextCensor(conf.App.AliyunAccessKey, conf.App.AliyunAccessKeySecret), nil
+	},
+	"local": func() (Provider, error) {
+		return LoadLocalTextCensor(conf.Security.TextCensorKeywordsFile)
+	},
+}
+
+// Register registers a text censor provider factory with the given name,
+// the name can be used in the `text_censor_providers` configuration.
+func Register(name string, factory ProviderFactory) {
+	factories[name] = factory
+}
+
+var (
+	providers     []Provider
+	providersOnce sync.Once
+)
+
+// Providers returns the configured text censor providers in order.
+func Providers() []Provider {
+	providersOnce.Do(func() {
+		names := conf.Security.TextCensorProviders
+		if len(names) == 0 {
+			names = []string{"qiniu", "aliyun"}
+		}
+
+		for _, name := range names {
+			name = strings.TrimSpace(name)
+			factory, ok := factories[name]
+			if !ok {
+				logrus.WithField("censor_source", name).Error("Unknown censor provider")
+				continue
+			}
+
+			provider, err := factory()
+			if err != nil {
+				logrus.WithError(err).WithField("censor_source", name).Error("Failed to create censor provider")
+				continue
+			}
+			providers = append(providers, provider)
+		}
+	})
+	return providers
+}
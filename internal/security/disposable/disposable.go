@@ -0,0 +1,116 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package disposable detects the email addresses of the disposable email services, which are rejected
+// for the signups and the reply notifications. The bundled domain list can be refreshed from a URL.
+package disposable
+
+import (
+	"bufio"
+	"context"
+	_ "embed"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/NekoWheel/NekoBox/internal/conf"
+)
+
+var ErrDisposableEmail = errors.New("不支持使用一次性邮箱，请换一个邮箱地址")
+
+//go:embed domains.txt
+var bundledDomains string
+
+var domains = struct {
+	sync.RWMutex
+	set map[string]struct{}
+}{
+	set: parse(strings.NewReader(bundledDomains)),
+}
+
+// parse reads the domains one per line, the empty lines and the comments starting with `#` are skipped.
+func parse(r io.Reader) map[string]struct{} {
+	set := make(map[string]struct{})
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.ToLower(strings.TrimSpace(scanner.Text()))
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		set[line] = struct{}{}
+	}
+	return set
+}
+
+// IsDisposable returns whether the email address belongs to a disposable email service,
+// the subdomains of the listed domains are also matched.
+func IsDisposable(email string) bool {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	domain := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(email[at+1:])), ".")
+
+	domains.RLock()
+	defer domains.RUnlock()
+	for domain != "" {
+		if _, ok := domains.set[domain]; ok {
+			return true
+		}
+		dot := strings.Index(domain, ".")
+		if dot < 0 {
+			break
+		}
+		domain = domain[dot+1:]
+	}
+	return false
+}
+
+// Check returns ErrDisposableEmail if the check is enabled and the email address is disposable.
+// The empty address is not checked.
+func Check(email string) error {
+	if !conf.Security.DisposableEmailCheck || email == "" {
+		return nil
+	}
+	if IsDisposable(email) {
+		return ErrDisposableEmail
+	}
+	return nil
+}
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// Refresh downloads the domain list from the configured URL, the domains are merged with the bundled
+// list so a broken download can't let the known domains pass. It returns the number of the domains.
+func Refresh(ctx context.Context) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, conf.Security.DisposableEmailListURL, nil)
+	if err != nil {
+		return 0, errors.Wrap(err, "new request")
+	}
+	req.Header.Set("User-Agent", "NekoBox")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, errors.Wrap(err, "do request")
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, errors.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	set := parse(resp.Body)
+	for domain := range parse(strings.NewReader(bundledDomains)) {
+		set[domain] = struct{}{}
+	}
+
+	domains.Lock()
+	domains.set = set
+	domains.Unlock()
+	return len(set), nil
+}
@@ -0,0 +1,139 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package loginthrottle slows down the brute-force logins by counting the failed logins
+// of the accounts and the IP addresses.
+package loginthrottle
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/mail"
+)
+
+const (
+	// window is how long the failed logins are counted.
+	window = time.Hour
+	// accountDelayAfter is the number of the failed logins of the account after which
+	// the next login has to wait, the delay doubles with each failed login.
+	accountDelayAfter = 3
+	maxDelay          = time.Minute
+	// accountLockoutAfter is the number of the failed logins of the account which locks the account.
+	accountLockoutAfter = 10
+	// ipLockoutAfter is the number of the failed logins from the IP address which blocks the IP address,
+	// it is larger than the one of the account since the users may share the IP address.
+	ipLockoutAfter = 30
+	lockout        = 15 * time.Minute
+)
+
+// accountWait returns how long the next login has to wait after the latest failed login.
+func accountWait(failures int64) time.Duration {
+	switch {
+	case failures >= accountLockoutAfter:
+		return lockout
+	case failures >= accountDelayAfter:
+		delay := time.Second << (failures - accountDelayAfter)
+		if delay > maxDelay {
+			delay = maxDelay
+		}
+		return delay
+	default:
+		return 0
+	}
+}
+
+// Check returns how long the login of the account from the IP address has to wait,
+// the login is allowed if it is zero.
+func Check(ctx context.Context, email, ip string) (time.Duration, error) {
+	now := time.Now()
+	var wait time.Duration
+
+	failures, latest, err := db.LoginAttempts.Count(ctx, db.CountLoginAttemptsOptions{
+		Email:        email,
+		CreatedAfter: now.Add(-window),
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "count login attempts of account")
+	}
+	if latest != nil {
+		if w := latest.Add(accountWait(failures)).Sub(now); w > wait {
+			wait = w
+		}
+	}
+
+	if ip != "" {
+		failures, latest, err := db.LoginAttempts.Count(ctx, db.CountLoginAttemptsOptions{
+			IP:           ip,
+			CreatedAfter: now.Add(-window),
+		})
+		if err != nil {
+			return 0, errors.Wrap(err, "count login attempts of IP")
+		}
+		if latest != nil && failures >= ipLockoutAfter {
+			if w := latest.Add(lockout).Sub(now); w > wait {
+				wait = w
+			}
+		}
+	}
+	return wait, nil
+}
+
+type FailOptions struct {
+	Email     string
+	IP        string
+	UserAgent string
+}
+
+// Fail records the failed login. The owner of the account is notified by mail when the account gets locked.
+func Fail(ctx context.Context, opts FailOptions) error {
+	// The attempts of the account which doesn't exist are counted as well,
+	// so that the response doesn't tell whether the account exists.
+	var user *db.User
+	if u, err := db.Users.GetByEmail(ctx, opts.Email); err == nil {
+		user = u
+	} else if !errors.Is(err, db.ErrUserNotExists) {
+		return errors.Wrap(err, "get user by email")
+	}
+
+	var userID uint
+	if user != nil {
+		userID = user.ID
+	}
+	if err := db.LoginAttempts.Create(ctx, db.CreateLoginAttemptOptions{
+		UserID:    userID,
+		Email:     opts.Email,
+		IP:        opts.IP,
+		UserAgent: opts.UserAgent,
+	}); err != nil {
+		return errors.Wrap(err, "create login attempt")
+	}
+
+	if user == nil {
+		return nil
+	}
+	failures, _, err := db.LoginAttempts.Count(ctx, db.CountLoginAttemptsOptions{
+		Email:        opts.Email,
+		CreatedAfter: time.Now().Add(-window),
+	})
+	if err != nil {
+		return errors.Wrap(err, "count login attempts")
+	}
+	// Only notify once when the account gets locked, the later failed logins extend the lockout silently.
+	if failures == accountLockoutAfter {
+		if err := mail.SendAccountLockedMail(ctx, user.Email, time.Now().Add(lockout)); err != nil {
+			logrus.WithContext(ctx).WithError(err).WithField("user_id", user.ID).Error("Failed to send account locked mail")
+		}
+	}
+	return nil
+}
+
+// Succeed clears the failed logins of the account after the user signs in.
+func Succeed(ctx context.Context, email string) error {
+	return db.LoginAttempts.ClearByEmail(ctx, email)
+}
@@ -0,0 +1,26 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package passwordpolicy
+
+// commonPasswords are the most common passwords and words in the leaked password lists,
+// in the order of popularity. The shorter ones are matched as the parts of the password as well.
+var commonPasswords = []string{
+	"123456", "password", "123456789", "12345678", "12345", "qwerty", "1234567", "111111",
+	"123123", "abc123", "1234567890", "password1", "iloveyou", "000000", "1q2w3e4r", "qwerty123",
+	"666666", "888888", "654321", "123321", "5201314", "woaini1314", "a123456", "qq123456",
+	"aa123456", "123456a", "a123456789", "woaini", "1314520", "147258369", "11111111", "88888888",
+	"12341234", "112233", "123654", "159357", "520520", "7758521", "abcd1234", "admin",
+	"admin123", "root", "letmein", "welcome", "monkey", "dragon", "football", "baseball",
+	"sunshine", "princess", "master", "shadow", "superman", "batman", "trustno1", "michael",
+	"jennifer", "hunter", "ranger", "buster", "soccer", "hockey", "killer", "pepper",
+	"jordan", "harley", "charlie", "andrew", "thomas", "tigger", "computer", "internet",
+	"starwars", "whatever", "freedom", "secret", "hello", "love", "loveyou", "lovely",
+	"angel", "baby", "cookie", "flower", "summer", "winter", "orange", "banana",
+	"chocolate", "passw0rd", "p@ssword", "p@ssw0rd", "qazwsx", "zxcvbnm", "asdfgh", "asdfghjkl",
+	"qwertyuiop", "google", "apple", "samsung", "iphone", "wechat", "weixin", "taobao",
+	"baidu", "tencent", "china", "beijing", "shanghai", "wang", "zhang", "liu",
+	"chen", "yang", "huang", "zhao", "xiaoming", "aini", "nekobox", "neko",
+	"miku", "pikachu", "naruto", "doraemon", "minecraft", "pokemon", "genshin", "bilibili",
+}
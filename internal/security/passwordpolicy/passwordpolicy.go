@@ -0,0 +1,90 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package passwordpolicy checks the new passwords against the strength policy and the known data breaches.
+package passwordpolicy
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/conf"
+)
+
+var ErrPasswordPwned = errors.New("该密码已出现在公开泄露的密码库中，请换一个密码")
+
+// Validate checks the new password against the policy, the user inputs are the personal information
+// of the user which should not be used in the password. The returned error is shown to the user.
+func Validate(ctx context.Context, password string, userInputs ...string) error {
+	if conf.Security.PasswordMinScore > 0 {
+		strength := Estimate(password, userInputs...)
+		if strength.Score < conf.Security.PasswordMinScore {
+			return errors.Errorf("密码强度太弱，%s", strength.Feedback)
+		}
+	}
+
+	if conf.Security.PasswordBreachCheck {
+		pwned, err := IsPwned(ctx, password)
+		if err != nil {
+			// Don't block the users if the service is unavailable.
+			logrus.WithContext(ctx).WithError(err).Warn("Failed to check the password against the data breaches")
+			return nil
+		}
+		if pwned {
+			return ErrPasswordPwned
+		}
+	}
+	return nil
+}
+
+const pwnedRangeURL = "https://api.pwnedpasswords.com/range/"
+
+var httpClient = &http.Client{Timeout: 5 * time.Second}
+
+// IsPwned returns whether the password appears in the data breaches known by Have I Been Pwned.
+// Only the first 5 characters of the SHA-1 hash are sent, the password can't be recovered from it.
+func IsPwned(ctx context.Context, password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:5], hash[5:]
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pwnedRangeURL+prefix, nil)
+	if err != nil {
+		return false, errors.Wrap(err, "new request")
+	}
+	req.Header.Set("User-Agent", "NekoBox")
+	// The padding hides the number of the hashes in the range from the eavesdroppers.
+	req.Header.Set("Add-Padding", "true")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return false, errors.Wrap(err, "do request")
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, errors.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		// Each line is "<hash suffix>:<count>", the padding lines have the count of 0.
+		hashSuffix, count, ok := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if ok && hashSuffix == suffix && count != "0" {
+			return true, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return false, errors.Wrap(err, "read response")
+	}
+	return false, nil
+}
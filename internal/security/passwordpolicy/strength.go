@@ -0,0 +1,370 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package passwordpolicy
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Strength is the estimated strength of the password.
+type Strength struct {
+	// Score is from 0 (too guessable) to 4 (very unguessable), which is the same as zxcvbn.
+	Score int
+	// Guesses is the estimated number of the guesses needed to crack the password.
+	Guesses float64
+	// Feedback is the suggestion to make the password stronger, it is empty if there is none.
+	Feedback string
+}
+
+const (
+	feedbackCommon     = "请避免使用常见的密码或单词"
+	feedbackUserInput  = "请避免在密码中包含您的邮箱、昵称或个性域名"
+	feedbackSequence   = "请避免使用 abc、123 这样的连续字符"
+	feedbackKeyboard   = "请避免使用键盘上相邻的按键"
+	feedbackRepeat     = "请避免使用重复的字符或片段"
+	feedbackDate       = "请避免使用年份或日期"
+	feedbackBruteforce = "请使用更长的密码，或混合使用大小写字母、数字和符号"
+)
+
+// match is the part of the password which is much easier to guess than the random characters.
+type match struct {
+	start, end int // The runes of [start, end) are matched.
+	guesses    float64
+	feedback   string
+}
+
+// Estimate estimates the strength of the password in the way of zxcvbn: the password is split
+// into the guessable patterns and the random characters, and the guesses of them are multiplied.
+// The user inputs are the personal information which should not be used in the password.
+func Estimate(password string, userInputs ...string) Strength {
+	runes := []rune(password)
+	if len(runes) == 0 {
+		return Strength{Feedback: feedbackBruteforce}
+	}
+	lowered := make([]rune, len(runes))
+	for i, r := range runes {
+		lowered[i] = unicode.ToLower(r)
+	}
+
+	var matches []match
+	matches = append(matches, dictionaryMatches(runes, lowered, userInputs)...)
+	matches = append(matches, sequenceMatches(lowered)...)
+	matches = append(matches, keyboardMatches(lowered)...)
+	matches = append(matches, repeatMatches(lowered)...)
+	matches = append(matches, dateMatches(lowered)...)
+
+	// Find the cheapest way to cover the password, any rune can be guessed by bruteforce.
+	charGuesses := math.Log10(float64(cardinality(runes)))
+	best := make([]float64, len(runes)+1)
+	via := make([]*match, len(runes)+1)
+	for i := 1; i <= len(runes); i++ {
+		best[i] = best[i-1] + charGuesses
+		for k := range matches {
+			m := &matches[k]
+			if m.end != i {
+				continue
+			}
+			if cost := best[m.start] + math.Log10(m.guesses); cost < best[i] {
+				best[i] = cost
+				via[i] = m
+			}
+		}
+	}
+
+	// The feedback is the one of the first pattern in the cheapest cover.
+	var feedback string
+	for i := len(runes); i > 0; {
+		if m := via[i]; m != nil {
+			feedback = m.feedback
+			i = m.start
+		} else {
+			i--
+		}
+	}
+
+	guesses := math.Pow(10, best[len(runes)])
+	score := scoreOf(guesses)
+	if score >= 3 {
+		feedback = ""
+	} else if feedback == "" {
+		feedback = feedbackBruteforce
+	}
+	return Strength{
+		Score:    score,
+		Guesses:  guesses,
+		Feedback: feedback,
+	}
+}
+
+// scoreOf converts the guesses to the score with the thresholds of zxcvbn.
+func scoreOf(guesses float64) int {
+	switch {
+	case guesses < 1e3:
+		return 0
+	case guesses < 1e6:
+		return 1
+	case guesses < 1e8:
+		return 2
+	case guesses < 1e10:
+		return 3
+	default:
+		return 4
+	}
+}
+
+// cardinality returns the size of the character set which the password is chosen from.
+func cardinality(runes []rune) int {
+	var lower, upper, digit, symbol, other bool
+	for _, r := range runes {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		case r < unicode.MaxASCII:
+			symbol = true
+		default:
+			other = true
+		}
+	}
+
+	size := 0
+	if lower {
+		size += 26
+	}
+	if upper {
+		size += 26
+	}
+	if digit {
+		size += 10
+	}
+	if symbol {
+		size += 33
+	}
+	if other {
+		size += 100
+	}
+	return size
+}
+
+// l33tTable is the common character substitutions, e.g. "p@ssw0rd" for "password".
+var l33tTable = map[rune]rune{
+	'4': 'a', '@': 'a',
+	'3': 'e',
+	'1': 'i', '!': 'i',
+	'0': 'o',
+	'$': 's', '5': 's',
+	'7': 't',
+}
+
+func dictionaryMatches(runes, lowered []rune, userInputs []string) []match {
+	unl33ted := make([]rune, len(lowered))
+	hasL33t := false
+	for i, r := range lowered {
+		if sub, ok := l33tTable[r]; ok {
+			unl33ted[i] = sub
+			hasL33t = true
+		} else {
+			unl33ted[i] = r
+		}
+	}
+
+	var matches []match
+	find := func(word string, guesses float64, feedback string) {
+		for _, m := range findAll(lowered, word) {
+			m.guesses = guesses * uppercaseVariations(runes[m.start:m.end])
+			m.feedback = feedback
+			matches = append(matches, m)
+		}
+		if !hasL33t {
+			return
+		}
+		for _, m := range findAll(unl33ted, word) {
+			// The substitutions are common, so they only make the word a little harder to guess.
+			m.guesses = guesses * uppercaseVariations(runes[m.start:m.end]) * 4
+			m.feedback = feedback
+			matches = append(matches, m)
+		}
+	}
+
+	for rank, word := range commonPasswords {
+		find(word, float64(rank+1), feedbackCommon)
+	}
+	for _, input := range splitUserInputs(userInputs) {
+		find(input, 10, feedbackUserInput)
+	}
+	return matches
+}
+
+// splitUserInputs splits the user inputs into the words, e.g. the email is split into the local part and the domain.
+func splitUserInputs(userInputs []string) []string {
+	var words []string
+	for _, input := range userInputs {
+		input = strings.ToLower(input)
+		words = append(words, input)
+		words = append(words, strings.FieldsFunc(input, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})...)
+	}
+
+	filtered := words[:0]
+	for _, word := range words {
+		if len([]rune(word)) >= 3 {
+			filtered = append(filtered, word)
+		}
+	}
+	return filtered
+}
+
+// findAll returns all the occurrences of the word in the runes.
+func findAll(runes []rune, word string) []match {
+	w := []rune(word)
+	var matches []match
+	for i := 0; i+len(w) <= len(runes); i++ {
+		if string(runes[i:i+len(w)]) == word {
+			matches = append(matches, match{start: i, end: i + len(w)})
+		}
+	}
+	return matches
+}
+
+// uppercaseVariations returns how many times the guesses are multiplied by the capitalization of the word.
+func uppercaseVariations(runes []rune) float64 {
+	upper := 0
+	for _, r := range runes {
+		if unicode.IsUpper(r) {
+			upper++
+		}
+	}
+	switch {
+	case upper == 0:
+		return 1
+	case upper == len(runes), upper == 1 && unicode.IsUpper(runes[0]):
+		return 2
+	default:
+		return math.Pow(2, float64(upper))
+	}
+}
+
+// sequenceMatches finds the runs like "abcd", "4321".
+func sequenceMatches(lowered []rune) []match {
+	var matches []match
+	for start := 0; start < len(lowered)-1; {
+		delta := lowered[start+1] - lowered[start]
+		end := start + 1
+		if delta == 1 || delta == -1 {
+			for end < len(lowered) && lowered[end]-lowered[end-1] == delta {
+				end++
+			}
+		}
+		if end-start >= 3 {
+			base := 26.0
+			if unicode.IsDigit(lowered[start]) {
+				base = 10
+			}
+			// The sequences start with the obvious characters are guessed first.
+			if strings.ContainsRune("a1z9", lowered[start]) {
+				base = 4
+			}
+			if delta < 0 {
+				base *= 2
+			}
+			matches = append(matches, match{start: start, end: end, guesses: base * float64(end-start), feedback: feedbackSequence})
+			start = end
+			continue
+		}
+		start++
+	}
+	return matches
+}
+
+// keyboardRows are the rows and the columns of the keyboard, which are commonly used as the passwords.
+var keyboardRows = []string{
+	"qwertyuiop",
+	"asdfghjkl",
+	"zxcvbnm",
+	"1qaz2wsx3edc4rfv5tgb6yhn7ujm8ik9ol0p",
+	"!@#$%^&*()",
+}
+
+// keyboardMatches finds the adjacent keys like "qwer", "asdf", "1qaz2wsx".
+func keyboardMatches(lowered []rune) []match {
+	var matches []match
+	for _, row := range keyboardRows {
+		reversed := []rune(row)
+		for i, j := 0, len(reversed)-1; i < j; i, j = i+1, j-1 {
+			reversed[i], reversed[j] = reversed[j], reversed[i]
+		}
+
+		for _, r := range []string{row, string(reversed)} {
+			for start := 0; start < len(lowered); start++ {
+				end := start
+				for end < len(lowered) && strings.Contains(r, string(lowered[start:end+1])) {
+					end++
+				}
+				if end-start >= 4 {
+					matches = append(matches, match{start: start, end: end, guesses: 50 * float64(end-start), feedback: feedbackKeyboard})
+				}
+			}
+		}
+	}
+	return matches
+}
+
+// repeatMatches finds the repeated characters like "aaaa" and the repeated parts like "abcabc".
+func repeatMatches(lowered []rune) []match {
+	var matches []match
+	for size := 1; size <= len(lowered)/2; size++ {
+		for start := 0; start+size*2 <= len(lowered); start++ {
+			end := start + size
+			for end+size <= len(lowered) && string(lowered[end:end+size]) == string(lowered[start:start+size]) {
+				end += size
+			}
+			count := (end - start) / size
+			if count < 2 || (size == 1 && count < 3) {
+				continue
+			}
+			block := math.Pow(float64(cardinality(lowered[start:start+size])), float64(size))
+			matches = append(matches, match{start: start, end: end, guesses: block * float64(count), feedback: feedbackRepeat})
+		}
+	}
+	return matches
+}
+
+// dateMatches finds the years like "1998" and the dates like "19980101", "980101".
+func dateMatches(lowered []rune) []match {
+	var matches []match
+	for start := 0; start < len(lowered); start++ {
+		end := start
+		for end < len(lowered) && unicode.IsDigit(lowered[end]) {
+			end++
+		}
+		digits := string(lowered[start:end])
+		for size := 4; size <= 8 && size <= len(digits); size += 2 {
+			part := digits[:size]
+			switch {
+			case size == 4 && isYear(part):
+				matches = append(matches, match{start: start, end: start + 4, guesses: 140, feedback: feedbackDate})
+			case size == 6 && isDate(part[2:4], part[4:6]):
+				matches = append(matches, match{start: start, end: start + 6, guesses: 100 * 365, feedback: feedbackDate})
+			case size == 8 && isYear(part[:4]) && isDate(part[4:6], part[6:8]):
+				matches = append(matches, match{start: start, end: start + 8, guesses: 140 * 365, feedback: feedbackDate})
+			}
+		}
+	}
+	return matches
+}
+
+func isYear(s string) bool {
+	return s >= "1900" && s <= "2039"
+}
+
+func isDate(month, day string) bool {
+	return month >= "01" && month <= "12" && day >= "01" && day <= "31"
+}
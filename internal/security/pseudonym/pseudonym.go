@@ -0,0 +1,55 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package pseudonym
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/NekoWheel/NekoBox/internal/conf"
+)
+
+var colors = []string{
+	"红色", "橙色", "金色", "黄色", "青柠色", "绿色", "薄荷色", "青色",
+	"天蓝色", "蓝色", "靛蓝色", "紫色", "粉色", "玫瑰色", "棕色", "银色",
+}
+
+var animals = []string{
+	"猫", "狗", "狐狸", "兔子", "熊猫", "考拉", "企鹅", "海豚",
+	"鲸鱼", "松鼠", "刺猬", "浣熊", "水獭", "仓鼠", "鹦鹉", "猫头鹰",
+	"海豹", "羊驼", "小鹿", "老虎", "狮子", "斑马", "长颈鹿", "树懒",
+}
+
+// Identity describes the anonymous asker, the logged-in asker is identified by the user ID,
+// otherwise the IP address and the user agent are used.
+type Identity struct {
+	UserID    uint
+	IP        string
+	UserAgent string
+}
+
+// Name returns the pseudonym of the asker in the box, e.g. "蓝色的狐狸 42".
+// The same asker always gets the same pseudonym in the same box, while the pseudonyms in
+// different boxes are unrelated. The identity can not be recovered from the pseudonym.
+func Name(boxUserID uint, identity Identity) string {
+	var source string
+	if identity.UserID != 0 {
+		source = fmt.Sprintf("user:%d", identity.UserID)
+	} else {
+		source = fmt.Sprintf("ip:%s|ua:%s", identity.IP, identity.UserAgent)
+	}
+
+	mac := hmac.New(sha256.New, []byte(conf.Server.Salt))
+	_, _ = fmt.Fprintf(mac, "%d|%s", boxUserID, source)
+	sum := binary.BigEndian.Uint64(mac.Sum(nil))
+
+	color := colors[sum%uint64(len(colors))]
+	sum /= uint64(len(colors))
+	animal := animals[sum%uint64(len(animals))]
+	sum /= uint64(len(animals))
+	return fmt.Sprintf("%s的%s %d", color, animal, sum%100)
+}
@@ -0,0 +1,38 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package reputation evaluates the logged askers by their history, so the trusted askers
+// get less friction while the anonymous traffic keeps the full protection.
+package reputation
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/NekoWheel/NekoBox/internal/conf"
+	"github.com/NekoWheel/NekoBox/internal/db"
+)
+
+// CaptchaExempt returns whether the logged asker can send the questions without the captcha.
+// The account must be old enough and have sent enough accepted questions, while none of its
+// questions failed the censor, was hidden by the administrators or put into the shadow folders.
+func CaptchaExempt(ctx context.Context, asker *db.User) (bool, error) {
+	if asker == nil || conf.Security.CaptchaExemptQuestions <= 0 {
+		return false, nil
+	}
+	if asker.EffectiveState() != db.UserStateActive {
+		return false, nil
+	}
+	if time.Since(asker.CreatedAt) < time.Duration(conf.Security.CaptchaExemptDays)*24*time.Hour {
+		return false, nil
+	}
+
+	history, err := db.Questions.GetAskerHistory(ctx, asker.ID)
+	if err != nil {
+		return false, errors.Wrap(err, "get asker history")
+	}
+	return history.Rejected == 0 && history.Accepted >= int64(conf.Security.CaptchaExemptQuestions), nil
+}
@@ -0,0 +1,63 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package signature
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/NekoWheel/NekoBox/internal/conf"
+)
+
+// The purposes of the signatures, a signature issued for one purpose can't be used for another.
+const (
+	PurposeViewQuestion    = "view-question"
+	PurposeUnsubscribe     = "unsubscribe"
+	PurposeReplyAnswer     = "reply-answer"
+	PurposePreviewQuestion = "preview-question"
+	PurposeAskForm         = "ask-form"
+)
+
+// Sign returns the signature of the value for the purpose, it is put in the links of the mails
+// so the receivers can be verified without signing in. The signature is keyed by the server salt,
+// changing the salt invalidates all the issued links.
+func Sign(purpose, value string) string {
+	return base64.RawURLEncoding.EncodeToString(sum(purpose, value))
+}
+
+// shortLength is the number of the bytes kept in the short signature.
+const shortLength = 16
+
+// SignShort returns the short case-insensitive form of the signature, which is used where
+// the case may not be kept, e.g. the local part of the email addresses.
+func SignShort(purpose, value string) string {
+	return hex.EncodeToString(sum(purpose, value)[:shortLength])
+}
+
+func sum(purpose, value string) []byte {
+	mac := hmac.New(sha256.New, []byte(conf.Server.Salt))
+	_, _ = fmt.Fprintf(mac, "%s|%s", purpose, value)
+	return mac.Sum(nil)
+}
+
+// Verify reports whether the signature is issued for the value and the purpose.
+func Verify(purpose, value, signature string) bool {
+	if signature == "" {
+		return false
+	}
+	return hmac.Equal([]byte(Sign(purpose, value)), []byte(signature))
+}
+
+// VerifyShort reports whether the short signature is issued for the value and the purpose.
+func VerifyShort(purpose, value, signature string) bool {
+	if signature == "" {
+		return false
+	}
+	return hmac.Equal([]byte(SignShort(purpose, value)), []byte(strings.ToLower(signature)))
+}
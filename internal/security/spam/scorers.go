@@ -0,0 +1,244 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package spam
+
+import (
+	"bufio"
+	"context"
+	"math"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/pkg/errors"
+
+	"github.com/NekoWheel/NekoBox/internal/conf"
+	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/security/disposable"
+)
+
+var _ Scorer = (*RateScorer)(nil)
+
+// RateScorer scores the asker who sends many questions to the boxes in a short time.
+type RateScorer struct{}
+
+const (
+	rateWindow = time.Hour
+	// rateFreeQuestions is the number of the questions in the window which are not scored.
+	rateFreeQuestions = 5
+	ratePerQuestion   = 10
+	rateMaxScore      = 50
+)
+
+func (s *RateScorer) Score(ctx context.Context, in *Input) (int, error) {
+	count, err := db.Questions.CountRecentByAsker(ctx, db.CountRecentByAskerOptions{
+		AskerUserID:  in.AskerUserID,
+		AskerIP:      in.AskerIP,
+		CreatedAfter: time.Now().Add(-rateWindow),
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "count recent questions by asker")
+	}
+	if count <= rateFreeQuestions {
+		return 0, nil
+	}
+	return capScore(int(count-rateFreeQuestions)*ratePerQuestion, rateMaxScore), nil
+}
+
+func (s *RateScorer) String() string {
+	return "rate"
+}
+
+var _ Scorer = (*EntropyScorer)(nil)
+
+// EntropyScorer scores the meaningless content, such as the keyboard mashing or a character repeated many times,
+// whose Shannon entropy is low for its length.
+type EntropyScorer struct{}
+
+const (
+	// entropyMinLength is the shortest content to be scored, the short questions are naturally low in entropy.
+	entropyMinLength = 20
+	// entropyThreshold is the entropy in bits per character below which the content is meaningless.
+	entropyThreshold = 2.5
+	entropyScore     = 30
+)
+
+func (s *EntropyScorer) Score(_ context.Context, in *Input) (int, error) {
+	counts := make(map[rune]int)
+	length := 0
+	for _, r := range in.Content {
+		if unicode.IsSpace(r) {
+			continue
+		}
+		counts[r]++
+		length++
+	}
+	if length < entropyMinLength {
+		return 0, nil
+	}
+
+	var entropy float64
+	for _, count := range counts {
+		p := float64(count) / float64(length)
+		entropy -= p * math.Log2(p)
+	}
+	if entropy < entropyThreshold {
+		return entropyScore, nil
+	}
+	return 0, nil
+}
+
+func (s *EntropyScorer) String() string {
+	return "entropy"
+}
+
+var _ Scorer = (*URLScorer)(nil)
+
+// URLScorer scores the content with the links, which are rarely in the questions but common in the advertisements.
+type URLScorer struct{}
+
+var urlPattern = regexp.MustCompile(`(?i)(https?://|www\.)\S+`)
+
+const (
+	urlPerLink  = 15
+	urlMaxScore = 60
+)
+
+func (s *URLScorer) Score(_ context.Context, in *Input) (int, error) {
+	count := len(urlPattern.FindAllStringIndex(in.Content, -1))
+	return capScore(count*urlPerLink, urlMaxScore), nil
+}
+
+func (s *URLScorer) String() string {
+	return "url"
+}
+
+var _ Scorer = (*BlocklistScorer)(nil)
+
+// BlocklistScorer scores the asker who has been blocked by the other boxes.
+type BlocklistScorer struct{}
+
+const (
+	blocklistPerBox   = 20
+	blocklistMaxScore = 60
+)
+
+func (s *BlocklistScorer) Score(ctx context.Context, in *Input) (int, error) {
+	count, err := db.Blocks.CountByAsker(ctx, db.IsBlockedOptions{
+		AskerUserID: in.AskerUserID,
+		AskerIP:     in.AskerIP,
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "count blocks by asker")
+	}
+	return capScore(int(count)*blocklistPerBox, blocklistMaxScore), nil
+}
+
+func (s *BlocklistScorer) String() string {
+	return "blocklist"
+}
+
+var _ Scorer = (*DisposableEmailScorer)(nil)
+
+// DisposableEmailScorer scores the question whose reply address is a disposable email, the domains
+// are matched against the shared list of the disposable email services and the extra domains.
+type DisposableEmailScorer struct {
+	domains map[string]struct{}
+}
+
+const disposableEmailScore = 30
+
+// LoadDisposableEmailScorer creates the scorer with the extra domains in the file, one domain per line.
+// The file is optional.
+func LoadDisposableEmailScorer(path string) (*DisposableEmailScorer, error) {
+	s := &DisposableEmailScorer{domains: make(map[string]struct{})}
+	if path == "" {
+		return s, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "open disposable email domains file")
+	}
+	defer func() { _ = f.Close() }()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.ToLower(strings.TrimSpace(scanner.Text()))
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		s.domains[line] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "read disposable email domains file")
+	}
+	return s, nil
+}
+
+func (s *DisposableEmailScorer) Score(_ context.Context, in *Input) (int, error) {
+	_, domain, ok := strings.Cut(in.ReceiveReplyEmail, "@")
+	if !ok {
+		return 0, nil
+	}
+	if disposable.IsDisposable(in.ReceiveReplyEmail) {
+		return disposableEmailScore, nil
+	}
+	if _, ok := s.domains[strings.ToLower(strings.TrimSpace(domain))]; ok {
+		return disposableEmailScore, nil
+	}
+	return 0, nil
+}
+
+func (s *DisposableEmailScorer) String() string {
+	return "disposable_email"
+}
+
+var _ Scorer = (*BotScorer)(nil)
+
+// BotScorer scores the question sent by the bots which fill in the honeypot of the ask form, or submit
+// the form without loading it or faster than a human can type. The captcha may be bypassed or solved by
+// the bots, so the signals are scored even if the captcha is passed.
+type BotScorer struct{}
+
+const (
+	botHoneypotScore = 60
+	botNoFormScore   = 40
+	botFastScore     = 40
+	botMaxScore      = 80
+)
+
+func (s *BotScorer) Score(_ context.Context, in *Input) (int, error) {
+	if in.Form == nil {
+		return 0, nil
+	}
+
+	var score int
+	if in.Form.Honeypot != "" {
+		score += botHoneypotScore
+	}
+	if conf.Spam.MinFillSeconds > 0 {
+		if in.Form.FilledIn <= 0 {
+			score += botNoFormScore
+		} else if in.Form.FilledIn < time.Duration(conf.Spam.MinFillSeconds)*time.Second {
+			score += botFastScore
+		}
+	}
+	return capScore(score, botMaxScore), nil
+}
+
+func (s *BotScorer) String() string {
+	return "bot"
+}
+
+// capScore limits the score of a scorer, so one signal can't reject the question alone.
+func capScore(score, max int) int {
+	if score > max {
+		return max
+	}
+	return score
+}
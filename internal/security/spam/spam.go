@@ -0,0 +1,134 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package spam scores the new questions by the signals of the spam, the scores of the scorers
+// are added up to decide whether the question is rejected or flagged for the owner's review.
+package spam
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/conf"
+)
+
+// MaxScore is the highest spam score, which means the question is spam for sure.
+const MaxScore = 100
+
+// Input is the question to be scored.
+type Input struct {
+	// UserID is the ID of the box's owner.
+	UserID uint
+	// AskerUserID is the ID of the logged asker, the anonymous asker is identified by the IP address.
+	AskerUserID       uint
+	AskerIP           string
+	Content           string
+	ReceiveReplyEmail string
+	// Form is nil if the question is not sent from the ask form, e.g. from the API.
+	Form *FormSignals
+}
+
+// FormSignals are the signals of the bots collected by the ask form.
+type FormSignals struct {
+	// Honeypot is the value of the field hidden from the humans, which is filled in by the naive bots.
+	Honeypot string
+	// FilledIn is how long the form is filled in, it is zero if the signed timestamp of the form is missing or invalid.
+	FilledIn time.Duration
+}
+
+// Scorer scores the question by one kind of the signals.
+type Scorer interface {
+	// Score returns the spam score of the question from 0 to MaxScore.
+	Score(ctx context.Context, in *Input) (int, error)
+	// String returns the scorer name, which is used in the `scorers` configuration.
+	String() string
+}
+
+// ScorerFactory creates a spam scorer with the configuration.
+type ScorerFactory func() (Scorer, error)
+
+var factories = map[string]ScorerFactory{
+	"rate":             func() (Scorer, error) { return &RateScorer{}, nil },
+	"entropy":          func() (Scorer, error) { return &EntropyScorer{}, nil },
+	"url":              func() (Scorer, error) { return &URLScorer{}, nil },
+	"blocklist":        func() (Scorer, error) { return &BlocklistScorer{}, nil },
+	"disposable_email": func() (Scorer, error) { return LoadDisposableEmailScorer(conf.Spam.DisposableEmailDomainsFile) },
+	"bot":              func() (Scorer, error) { return &BotScorer{}, nil },
+}
+
+// defaultScorers are used if no scorers are configured.
+var defaultScorers = []string{"rate", "entropy", "url", "blocklist", "disposable_email", "bot"}
+
+// Register registers a spam scorer factory with the given name,
+// the name can be used in the `scorers` configuration.
+func Register(name string, factory ScorerFactory) {
+	factories[name] = factory
+}
+
+var (
+	scorers     []Scorer
+	scorersOnce sync.Once
+)
+
+// Scorers returns the configured spam scorers.
+func Scorers() []Scorer {
+	scorersOnce.Do(func() {
+		names := conf.Spam.Scorers
+		if len(names) == 0 {
+			names = defaultScorers
+		}
+
+		for _, name := range names {
+			name = strings.TrimSpace(name)
+			factory, ok := factories[name]
+			if !ok {
+				logrus.WithField("spam_scorer", name).Error("Unknown spam scorer")
+				continue
+			}
+
+			scorer, err := factory()
+			if err != nil {
+				logrus.WithError(err).WithField("spam_scorer", name).Error("Failed to create spam scorer")
+				continue
+			}
+			scorers = append(scorers, scorer)
+		}
+	})
+	return scorers
+}
+
+// Verdict is the spam score of the question.
+type Verdict struct {
+	Score int
+}
+
+// Rejected reports whether the question is spam and must be rejected.
+func (v *Verdict) Rejected() bool {
+	return conf.Spam.RejectScore > 0 && v.Score >= conf.Spam.RejectScore
+}
+
+// Score scores the question with all the scorers, the scorer which fails is skipped
+// so the questions are still accepted when the database is busy.
+func Score(ctx context.Context, in *Input) *Verdict {
+	var verdict Verdict
+	for _, scorer := range Scorers() {
+		score, err := scorer.Score(ctx, in)
+		if err != nil {
+			logrus.WithContext(ctx).WithError(err).WithField("spam_scorer", scorer.String()).Error("Failed to score question")
+			continue
+		}
+		verdict.Score += score
+	}
+
+	if verdict.Score < 0 {
+		verdict.Score = 0
+	} else if verdict.Score > MaxScore {
+		verdict.Score = MaxScore
+	}
+	return &verdict
+}
@@ -0,0 +1,32 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package ssrf prevents the requests to the user-provided URLs from reaching the internal network.
+package ssrf
+
+import (
+	"net"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// DenyPrivateAddress is the `Control` function of the net.Dialer, which rejects the connections to
+// the internal network. It checks the resolved address, so it can't be bypassed by the DNS records.
+// The transport must not use a proxy, otherwise only the proxy's address is checked.
+func DenyPrivateAddress(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return errors.Wrap(err, "split host port")
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return errors.Errorf("invalid IP address %q", host)
+	}
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() {
+		return errors.Errorf("the address %q is not allowed", host)
+	}
+	return nil
+}
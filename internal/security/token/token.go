@@ -0,0 +1,77 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package token
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/pkg/errors"
+
+	"github.com/NekoWheel/NekoBox/internal/conf"
+)
+
+// Lifetime is the lifetime of the issued API token. The token is revoked earlier
+// along with its session record, which is purged once it has been inactive.
+const Lifetime = 30 * 24 * time.Hour
+
+var ErrTokenDisabled = errors.New("token authentication is disabled")
+
+// Enabled returns true if the token authentication is enabled.
+func Enabled() bool {
+	return conf.Server.JWTKey != ""
+}
+
+// Issue issues a new API token for the given user, which is bound to the given session token.
+// It returns the signed token and its expire time.
+func Issue(userID uint, sessionToken string) (string, time.Time, error) {
+	if conf.Server.JWTKey == "" {
+		return "", time.Time{}, ErrTokenDisabled
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(Lifetime)
+	claims := jwt.RegisteredClaims{
+		Issuer:    "nekobox",
+		Subject:   strconv.FormatUint(uint64(userID), 10),
+		ID:        sessionToken,
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(expiresAt),
+	}
+
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(conf.Server.JWTKey))
+	if err != nil {
+		return "", time.Time{}, errors.Wrap(err, "sign token")
+	}
+	return signed, expiresAt, nil
+}
+
+// Parse validates the given API token and returns the user ID it was issued for,
+// and the session token it is bound to.
+func Parse(signed string) (userID uint, sessionToken string, err error) {
+	if conf.Server.JWTKey == "" {
+		return 0, "", ErrTokenDisabled
+	}
+
+	var claims jwt.RegisteredClaims
+	if _, err := jwt.ParseWithClaims(signed, &claims, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.Errorf("unexpected signing method: %v", t.Header["alg"])
+		}
+		return []byte(conf.Server.JWTKey), nil
+	}); err != nil {
+		return 0, "", errors.Wrap(err, "parse token")
+	}
+	if claims.ID == "" {
+		return 0, "", errors.New("token is not bound to a session")
+	}
+
+	uid, err := strconv.ParseUint(claims.Subject, 10, 64)
+	if err != nil {
+		return 0, "", errors.Wrap(err, "parse subject")
+	}
+	return uint(uid), claims.ID, nil
+}
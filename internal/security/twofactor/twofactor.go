@@ -0,0 +1,65 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package twofactor
+
+import (
+	"bytes"
+	"encoding/base64"
+	"html/template"
+	"image/png"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+const issuer = "NekoBox"
+
+// GenerateKey generates a new TOTP key of the account.
+func GenerateKey(accountName string) (*otp.Key, error) {
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      issuer,
+		AccountName: accountName,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "generate")
+	}
+	return key, nil
+}
+
+// ParseKey parses the TOTP key from its `otpauth://` URL.
+func ParseKey(url string) (*otp.Key, error) {
+	key, err := otp.NewKeyFromURL(url)
+	if err != nil {
+		return nil, errors.Wrap(err, "new key from URL")
+	}
+	return key, nil
+}
+
+// QRCode returns the PNG QR code of the key as a data URL, which can be used in the <img> tag directly.
+func QRCode(key *otp.Key) (template.URL, error) {
+	img, err := key.Image(200, 200)
+	if err != nil {
+		return "", errors.Wrap(err, "image")
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", errors.Wrap(err, "encode png")
+	}
+	return template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())), nil
+}
+
+// Validate checks the passcode with the secret, one period of clock skew is allowed.
+func Validate(passcode, secret string) bool {
+	ok, _ := totp.ValidateCustom(passcode, secret, time.Now().UTC(), totp.ValidateOpts{
+		Period:    30,
+		Skew:      1,
+		Digits:    otp.DigitsSix,
+		Algorithm: otp.AlgorithmSHA1,
+	})
+	return ok
+}
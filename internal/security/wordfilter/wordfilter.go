@@ -0,0 +1,149 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package wordfilter matches the new questions against the banned words of the box owners.
+package wordfilter
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/text/unicode/norm"
+
+	"github.com/NekoWheel/NekoBox/internal/db"
+)
+
+// matcherCacheLifetime is how long the compiled matcher of the owner is cached, the changes made
+// on the other instances take effect after it.
+const matcherCacheLifetime = time.Minute
+
+// Matcher is the compiled word filters of a box owner.
+type Matcher struct {
+	rules []rule
+}
+
+type rule struct {
+	filter *db.WordFilter
+	word   string
+	re     *regexp.Regexp
+}
+
+// Compile compiles the word filters into a matcher, the invalid regular expressions are skipped.
+func Compile(filters []*db.WordFilter) *Matcher {
+	m := &Matcher{rules: make([]rule, 0, len(filters))}
+	for _, filter := range filters {
+		if filter.IsRegex {
+			re, err := regexp.Compile("(?i)" + filter.Pattern)
+			if err != nil {
+				continue
+			}
+			m.rules = append(m.rules, rule{filter: filter, re: re})
+		} else {
+			m.rules = append(m.rules, rule{filter: filter, word: normalize(filter.Pattern)})
+		}
+	}
+	return m
+}
+
+// Match returns the word filter which the content matches, the rejecting filters take precedence
+// over the archiving ones. It returns nil if the content matches none of the filters.
+func (m *Matcher) Match(content string) *db.WordFilter {
+	normalized := normalize(content)
+
+	var matched *db.WordFilter
+	for _, r := range m.rules {
+		if r.re != nil && !r.re.MatchString(normalized) {
+			continue
+		}
+		if r.re == nil && !strings.Contains(normalized, r.word) {
+			continue
+		}
+
+		if r.filter.Action == db.WordFilterActionReject {
+			return r.filter
+		}
+		if matched == nil {
+			matched = r.filter
+		}
+	}
+	return matched
+}
+
+// normalize folds the width and the case of the characters, so "ＡＢＣ" matches "abc".
+func normalize(s string) string {
+	return strings.ToLower(norm.NFKC.String(s))
+}
+
+type cachedMatcher struct {
+	matcher   *Matcher
+	expiredAt time.Time
+}
+
+var cache = struct {
+	sync.Mutex
+	matchers  map[uint]*cachedMatcher
+	lastSweep time.Time
+}{
+	matchers: make(map[uint]*cachedMatcher),
+}
+
+// Match returns the word filter of the user which the content matches, or nil if there is none.
+func Match(ctx context.Context, userID uint, content string) (*db.WordFilter, error) {
+	matcher, err := get(ctx, userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "get matcher")
+	}
+	return matcher.Match(content), nil
+}
+
+// Invalidate drops the cached matcher of the user, it should be called after the word filters are changed.
+func Invalidate(userID uint) {
+	cache.Lock()
+	defer cache.Unlock()
+	delete(cache.matchers, userID)
+}
+
+func get(ctx context.Context, userID uint) (*Matcher, error) {
+	now := time.Now()
+
+	cache.Lock()
+	cached, ok := cache.matchers[userID]
+	cache.Unlock()
+	if ok && now.Before(cached.expiredAt) {
+		return cached.matcher, nil
+	}
+
+	filters, err := db.WordFilters.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "get word filters by user ID")
+	}
+	matcher := Compile(filters)
+
+	cache.Lock()
+	defer cache.Unlock()
+	sweep(now)
+	cache.matchers[userID] = &cachedMatcher{
+		matcher:   matcher,
+		expiredAt: now.Add(matcherCacheLifetime),
+	}
+	return matcher, nil
+}
+
+// sweep removes the expired matchers every minute, so that the map won't grow forever.
+func sweep(now time.Time) {
+	if now.Sub(cache.lastSweep) < time.Minute {
+		return
+	}
+	cache.lastSweep = now
+
+	for userID, cached := range cache.matchers {
+		if now.After(cached.expiredAt) {
+			delete(cache.matchers, userID)
+		}
+	}
+}
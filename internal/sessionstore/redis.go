@@ -0,0 +1,188 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package sessionstore contains the session stores shared by the instances behind the load balancer.
+package sessionstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/flamego/session"
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// dataVersion is the version of the serialization format of the session data. Bump it when the types
+// saved in the sessions change incompatibly, the sessions of the other versions are dropped on reading
+// instead of failing the requests, so the replicas of the old and the new versions can run side by side.
+const dataVersion byte = 1
+
+func encode(data session.Data) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte(dataVersion)
+	if err := gob.NewEncoder(&buf).Encode(data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decode returns the session data and whether it is saved in the current version. The sessions saved
+// before the versioning are the plain gob data, whose first byte is never the version byte.
+func decode(binary []byte) (session.Data, bool, error) {
+	if len(binary) == 0 {
+		return nil, false, errors.New("empty session data")
+	}
+
+	current := binary[0] == dataVersion
+	if current {
+		binary = binary[1:]
+	}
+
+	var data session.Data
+	if err := gob.NewDecoder(bytes.NewReader(binary)).Decode(&data); err != nil {
+		return nil, false, err
+	}
+	return data, current, nil
+}
+
+// redisSession tracks whether the session data has been changed in the request,
+// so the unchanged session is not written back.
+type redisSession struct {
+	*session.BaseSession
+	changed atomic.Bool
+}
+
+func newRedisSession(sid string) *redisSession {
+	return &redisSession{BaseSession: session.NewBaseSession(sid, encode)}
+}
+
+func (s *redisSession) Set(key, val interface{}) {
+	s.BaseSession.Set(key, val)
+	s.changed.Store(true)
+}
+
+func (s *redisSession) SetFlash(val interface{}) {
+	s.BaseSession.SetFlash(val)
+	s.changed.Store(true)
+}
+
+// Delete only marks the session changed if the key exists, the flash is deleted on every request.
+func (s *redisSession) Delete(key interface{}) {
+	if s.BaseSession.Get(key) == nil {
+		return
+	}
+	s.BaseSession.Delete(key)
+	s.changed.Store(true)
+}
+
+func (s *redisSession) Flush() {
+	s.BaseSession.Flush()
+	s.changed.Store(true)
+}
+
+var _ session.Store = (*redisStore)(nil)
+
+// redisStore saves the sessions in Redis with the sliding expiration, the lifetime of the session
+// is renewed on every request, and the session expires after it is not used for the lifetime.
+type redisStore struct {
+	client   *redis.Client
+	lifetime time.Duration
+}
+
+func (s *redisStore) Exist(ctx context.Context, sid string) bool {
+	result, err := s.client.Exists(ctx, sid).Result()
+	return err == nil && result == 1
+}
+
+func (s *redisStore) Read(ctx context.Context, sid string) (session.Session, error) {
+	sess := newRedisSession(sid)
+
+	binary, err := s.client.Get(ctx, sid).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return sess, nil
+		}
+		return nil, errors.Wrap(err, "get")
+	}
+
+	data, current, err := decode(binary)
+	if err != nil {
+		// The session of an unknown version is dropped, the user needs to sign in again.
+		logrus.WithContext(ctx).WithError(err).Warn("Failed to decode session data, drop the session")
+		sess.changed.Store(true)
+		return sess, nil
+	}
+	sess.SetData(data)
+	// The session of the old version is saved again in the current version.
+	sess.changed.Store(!current)
+	return sess, nil
+}
+
+func (s *redisStore) Destroy(ctx context.Context, sid string) error {
+	return s.client.Del(ctx, sid).Err()
+}
+
+func (s *redisStore) Save(ctx context.Context, sess session.Session) error {
+	// Only renew the lifetime of the unchanged session, which also avoids overwriting the changes
+	// made by the concurrent requests of the same session on the other instances.
+	if rs, ok := sess.(*redisSession); ok && !rs.changed.Load() {
+		if err := s.client.Expire(ctx, sess.ID(), s.lifetime).Err(); err != nil {
+			return errors.Wrap(err, "expire")
+		}
+		return nil
+	}
+
+	binary, err := sess.Encode()
+	if err != nil {
+		return errors.Wrap(err, "encode")
+	}
+	if err := s.client.SetEX(ctx, sess.ID(), binary, s.lifetime).Err(); err != nil {
+		return errors.Wrap(err, "set")
+	}
+	return nil
+}
+
+// GC does nothing, the expired sessions are evicted by Redis.
+func (*redisStore) GC(context.Context) error {
+	return nil
+}
+
+// RedisConfig is the configuration of the Redis session store.
+type RedisConfig struct {
+	Options *redis.Options
+	// Lifetime is how long the session lasts without being used.
+	Lifetime time.Duration
+}
+
+// RedisIniter returns the session.Initer of the Redis session store.
+func RedisIniter() session.Initer {
+	return func(_ context.Context, args ...interface{}) (session.Store, error) {
+		var config *RedisConfig
+		for _, arg := range args {
+			if v, ok := arg.(RedisConfig); ok {
+				config = &v
+			}
+		}
+		if config == nil {
+			return nil, fmt.Errorf("config object with the type '%T' not found", RedisConfig{})
+		}
+		if config.Options == nil {
+			return nil, errors.New("empty Redis options")
+		}
+		if config.Lifetime < time.Second {
+			return nil, errors.Errorf("session lifetime %s is too short", config.Lifetime)
+		}
+
+		return &redisStore{
+			client:   redis.NewClient(config.Options),
+			lifetime: config.Lifetime,
+		}, nil
+	}
+}
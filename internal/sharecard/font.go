@@ -0,0 +1,63 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package sharecard
+
+import (
+	"os"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+
+	"github.com/NekoWheel/NekoBox/internal/conf"
+)
+
+var (
+	fontOnce sync.Once
+	cardFont *opentype.Font
+	fontErr  error
+)
+
+// loadFont loads the font file configured by `share_card_font_file`.
+// The embedded Go font is used if no font file is configured, which only covers the Latin characters,
+// so a CJK font (e.g. Noto Sans SC) should always be configured in production.
+func loadFont() (*opentype.Font, error) {
+	fontOnce.Do(func() {
+		data := goregular.TTF
+		if fontFile := conf.App.ShareCardFontFile; fontFile != "" {
+			var err error
+			data, err = os.ReadFile(fontFile)
+			if err != nil {
+				fontErr = errors.Wrapf(err, "read font file %q", fontFile)
+				return
+			}
+		} else {
+			logrus.Warn("No share card font file configured, the CJK characters can't be rendered")
+		}
+
+		cardFont, fontErr = opentype.Parse(data)
+		if fontErr != nil {
+			fontErr = errors.Wrap(fontErr, "parse font")
+		}
+	})
+	return cardFont, fontErr
+}
+
+// newFace returns a new font face of the given size.
+// The face is not safe for concurrent use, so each rendering creates its own faces.
+func newFace(f *opentype.Font, size float64) (font.Face, error) {
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{
+		Size:    size,
+		DPI:     72,
+		Hinting: font.HintingFull,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "new face")
+	}
+	return face, nil
+}
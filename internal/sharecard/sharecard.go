@@ -0,0 +1,164 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package sharecard
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/fogleman/gg"
+	"github.com/pkg/errors"
+
+	"github.com/NekoWheel/NekoBox/internal/conf"
+	"github.com/NekoWheel/NekoBox/internal/db"
+)
+
+const (
+	cardWidth     = 1080
+	cardPadding   = 80
+	contentWidth  = cardWidth - 2*cardPadding
+	titleSize     = 36
+	questionSize  = 48
+	answerSize    = 40
+	footerSize    = 28
+	lineSpacing   = 1.5
+	maxAnswerLine = 16
+	maxWordLength = 16
+)
+
+// Render renders the share card PNG of the answered question in the given user's box.
+func Render(user *db.User, question *db.Question) ([]byte, error) {
+	f, err := loadFont()
+	if err != nil {
+		return nil, errors.Wrap(err, "load font")
+	}
+
+	titleFace, err := newFace(f, titleSize)
+	if err != nil {
+		return nil, err
+	}
+	questionFace, err := newFace(f, questionSize)
+	if err != nil {
+		return nil, err
+	}
+	answerFace, err := newFace(f, answerSize)
+	if err != nil {
+		return nil, err
+	}
+	footerFace, err := newFace(f, footerSize)
+	if err != nil {
+		return nil, err
+	}
+
+	// Measure the text with a scratch context first, the card's height depends on the line count.
+	measure := gg.NewContext(1, 1)
+	measure.SetFontFace(questionFace)
+	questionLines := wrap(measure, question.Content, contentWidth, 0)
+	measure.SetFontFace(answerFace)
+	answerLines := wrap(measure, question.Answer, contentWidth, maxAnswerLine)
+
+	questionHeight := float64(len(questionLines)) * questionSize * lineSpacing
+	answerHeight := float64(len(answerLines)) * answerSize * lineSpacing
+	height := cardPadding + titleSize*2 + // Title
+		cardPadding/2 + questionHeight + cardPadding/2 + // Question block
+		cardPadding/2 + answerHeight + // Answer
+		cardPadding + footerSize*2 + cardPadding // Footer
+
+	dc := gg.NewContext(cardWidth, int(height))
+	dc.SetHexColor("#f8f8f8")
+	dc.Clear()
+
+	y := float64(cardPadding)
+
+	// Title
+	dc.SetFontFace(titleFace)
+	dc.SetHexColor("#999999")
+	dc.DrawString("@"+user.Name+" 的提问箱", cardPadding, y+titleSize)
+	y += titleSize * 2
+
+	// Question
+	dc.SetHexColor("#ffffff")
+	dc.DrawRoundedRectangle(cardPadding/2, y, cardWidth-cardPadding, questionHeight+cardPadding, 24)
+	dc.Fill()
+	y += cardPadding / 2
+	dc.SetFontFace(questionFace)
+	dc.SetHexColor("#333333")
+	for _, line := range questionLines {
+		dc.DrawString(line, cardPadding, y+questionSize)
+		y += questionSize * lineSpacing
+	}
+	y += cardPadding
+
+	// Answer
+	dc.SetFontFace(answerFace)
+	dc.SetHexColor("#555555")
+	for _, line := range answerLines {
+		dc.DrawString(line, cardPadding, y+answerSize)
+		y += answerSize * lineSpacing
+	}
+	y += cardPadding
+
+	// Footer
+	dc.SetFontFace(footerFace)
+	dc.SetHexColor("#1e87f0")
+	dc.DrawString("NekoBox", cardPadding, y+footerSize)
+	dc.SetHexColor("#999999")
+	dc.DrawStringAnchored(conf.App.ExternalURL+"/_/"+user.Domain, cardWidth-cardPadding, y+footerSize, 1, 0)
+
+	var buf bytes.Buffer
+	if err := dc.EncodePNG(&buf); err != nil {
+		return nil, errors.Wrap(err, "encode png")
+	}
+	return buf.Bytes(), nil
+}
+
+// wrap breaks the text into lines fitting the width. The line is broken at the last space if there is one,
+// otherwise by characters, so that the CJK text without spaces can be wrapped as well.
+// The lines exceeding maxLines are omitted with an ellipsis.
+func wrap(dc *gg.Context, text string, width float64, maxLines int) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		var line []rune
+		for _, r := range paragraph {
+			if w, _ := dc.MeasureString(string(append(line, r))); w > width && len(line) > 0 {
+				next := []rune{}
+				// Only break at the near space, the CJK text may contain spaces far away.
+				if i := lastSpace(line); i > 0 && i >= len(line)-maxWordLength && r != ' ' {
+					next = append(next, line[i+1:]...)
+					line = line[:i]
+				}
+				lines = append(lines, string(line))
+				line = next
+				if r == ' ' {
+					continue
+				}
+			}
+			line = append(line, r)
+		}
+		lines = append(lines, string(line))
+	}
+
+	if maxLines > 0 && len(lines) > maxLines {
+		lines = lines[:maxLines]
+		last := []rune(lines[maxLines-1])
+		for len(last) > 0 {
+			if w, _ := dc.MeasureString(string(last) + "…"); w <= width {
+				break
+			}
+			last = last[:len(last)-1]
+		}
+		lines[maxLines-1] = string(last) + "…"
+	}
+	return lines
+}
+
+func lastSpace(line []rune) int {
+	for i := len(line) - 1; i >= 0; i-- {
+		if line[i] == ' ' {
+			return i
+		}
+	}
+	return -1
+}
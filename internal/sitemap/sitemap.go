@@ -0,0 +1,142 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package sitemap generates the sitemap of the public profile pages and the answered questions.
+//
+// The sitemap is split into the files of at most maxURLsPerFile URLs, which are listed in the
+// sitemap index. The files are generated into the local directory and served as static files.
+package sitemap
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/conf"
+	"github.com/NekoWheel/NekoBox/internal/db"
+)
+
+// Dir is the directory of the generated sitemap files.
+var Dir = filepath.Join("data", "sitemap")
+
+const (
+	// IndexFile is the name of the sitemap index file.
+	IndexFile = "sitemap.xml"
+	// maxURLsPerFile is lower than the limit of 50,000 URLs, so a file is small enough to be fetched at once.
+	maxURLsPerFile = 10000
+)
+
+var fileNamePattern = regexp.MustCompile(`^(profiles|questions)-[0-9]+\.xml$`)
+
+// Path returns the path of the sitemap file with the given name, it returns false if
+// the name is not a sitemap file name.
+func Path(name string) (string, bool) {
+	if name != IndexFile && !fileNamePattern.MatchString(name) {
+		return "", false
+	}
+	return filepath.Join(Dir, name), true
+}
+
+// Generate regenerates all the sitemap files. The users and the questions are streamed from
+// the database in batches, so the memory usage doesn't grow with the number of the questions.
+func Generate(ctx context.Context) error {
+	if err := os.MkdirAll(Dir, 0o755); err != nil {
+		return errors.Wrap(err, "create sitemap directory")
+	}
+
+	// The questions of the deactivated users are not public, only the domains of the active users are kept.
+	domains := make(map[uint]string)
+	profiles := newWriter("profiles")
+	if err := db.Users.Iterate(ctx, func(user *db.User) error {
+		domains[user.ID] = user.Domain
+		return profiles.add(fmt.Sprintf("%s/_/%s", conf.App.ExternalURL, user.Domain), user.UpdatedAt)
+	}); err != nil {
+		profiles.abort()
+		return errors.Wrap(err, "iterate users")
+	}
+	if err := profiles.close(); err != nil {
+		return errors.Wrap(err, "write profile sitemaps")
+	}
+
+	questions := newWriter("questions")
+	if err := db.Questions.IterateAnswered(ctx, func(question *db.Question) error {
+		domain, ok := domains[question.UserID]
+		if !ok {
+			return nil
+		}
+		lastModified := question.UpdatedAt
+		if question.AnswerUpdatedAt != nil && question.AnswerUpdatedAt.After(lastModified) {
+			lastModified = *question.AnswerUpdatedAt
+		}
+		return questions.add(fmt.Sprintf("%s/_/%s/%d", conf.App.ExternalURL, domain, question.ID), lastModified)
+	}); err != nil {
+		questions.abort()
+		return errors.Wrap(err, "iterate answered questions")
+	}
+	if err := questions.close(); err != nil {
+		return errors.Wrap(err, "write question sitemaps")
+	}
+
+	files := append(profiles.files, questions.files...)
+	if err := writeIndex(files); err != nil {
+		return errors.Wrap(err, "write sitemap index")
+	}
+	removeStaleFiles(files)
+
+	logrus.WithContext(ctx).WithField("files", len(files)).Info("Generated sitemap")
+	return nil
+}
+
+// writeIndex writes the sitemap index which lists all the sitemap files.
+func writeIndex(files []string) error {
+	f, err := createFile(IndexFile)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if _, err := fmt.Fprint(f, xmlHeader+`<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">`+"\n"); err != nil {
+		f.abort()
+		return errors.Wrap(err, "write header")
+	}
+	for _, name := range files {
+		if _, err := fmt.Fprintf(f, "<sitemap><loc>%s</loc><lastmod>%s</lastmod></sitemap>\n",
+			escape(fmt.Sprintf("%s/sitemaps/%s", conf.App.ExternalURL, name)), now.Format(time.RFC3339)); err != nil {
+			f.abort()
+			return errors.Wrap(err, "write sitemap")
+		}
+	}
+	if _, err := fmt.Fprint(f, "</sitemapindex>\n"); err != nil {
+		f.abort()
+		return errors.Wrap(err, "write footer")
+	}
+	return f.commit()
+}
+
+// removeStaleFiles removes the sitemap files which are not listed in the index any more,
+// e.g. the number of the files decreases after the questions are deleted.
+func removeStaleFiles(files []string) {
+	listed := make(map[string]struct{}, len(files))
+	for _, name := range files {
+		listed[name] = struct{}{}
+	}
+
+	entries, err := os.ReadDir(Dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if _, ok := listed[name]; ok || !fileNamePattern.MatchString(name) {
+			continue
+		}
+		_ = os.Remove(filepath.Join(Dir, name))
+	}
+}
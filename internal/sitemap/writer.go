@@ -0,0 +1,132 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package sitemap
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const xmlHeader = `<?xml version="1.0" encoding="UTF-8"?>` + "\n"
+
+// file is a sitemap file being written. The content is written into a temporary file,
+// which replaces the served file on commit, so the crawlers never read a partial file.
+type file struct {
+	*bufio.Writer
+	f    *os.File
+	name string
+}
+
+func createFile(name string) (*file, error) {
+	f, err := os.CreateTemp(Dir, name+".*.tmp")
+	if err != nil {
+		return nil, errors.Wrap(err, "create temporary file")
+	}
+	return &file{Writer: bufio.NewWriter(f), f: f, name: name}, nil
+}
+
+func (f *file) commit() error {
+	if err := f.Flush(); err != nil {
+		f.abort()
+		return errors.Wrap(err, "flush")
+	}
+	if err := f.f.Close(); err != nil {
+		_ = os.Remove(f.f.Name())
+		return errors.Wrap(err, "close")
+	}
+	if err := os.Rename(f.f.Name(), filepath.Join(Dir, f.name)); err != nil {
+		_ = os.Remove(f.f.Name())
+		return errors.Wrap(err, "rename")
+	}
+	return nil
+}
+
+func (f *file) abort() {
+	_ = f.f.Close()
+	_ = os.Remove(f.f.Name())
+}
+
+// writer splits the URLs into the numbered sitemap files, e.g. questions-1.xml, questions-2.xml.
+type writer struct {
+	prefix  string
+	current *file
+	count   int
+	// files are the names of the committed files.
+	files []string
+}
+
+func newWriter(prefix string) *writer {
+	return &writer{prefix: prefix}
+}
+
+func (w *writer) add(loc string, lastModified time.Time) error {
+	if w.current != nil && w.count >= maxURLsPerFile {
+		if err := w.commitCurrent(); err != nil {
+			return err
+		}
+	}
+
+	if w.current == nil {
+		f, err := createFile(fmt.Sprintf("%s-%d.xml", w.prefix, len(w.files)+1))
+		if err != nil {
+			return err
+		}
+		if _, err := f.WriteString(xmlHeader + `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">` + "\n"); err != nil {
+			f.abort()
+			return errors.Wrap(err, "write header")
+		}
+		w.current = f
+		w.count = 0
+	}
+
+	if _, err := fmt.Fprintf(w.current, "<url><loc>%s</loc><lastmod>%s</lastmod></url>\n", escape(loc), lastModified.Format(time.RFC3339)); err != nil {
+		return errors.Wrap(err, "write URL")
+	}
+	w.count++
+	return nil
+}
+
+func (w *writer) commitCurrent() error {
+	if _, err := w.current.WriteString("</urlset>\n"); err != nil {
+		w.abort()
+		return errors.Wrap(err, "write footer")
+	}
+	if err := w.current.commit(); err != nil {
+		w.current = nil
+		return err
+	}
+	w.files = append(w.files, w.current.name)
+	w.current = nil
+	return nil
+}
+
+// close commits the last file.
+func (w *writer) close() error {
+	if w.current == nil {
+		return nil
+	}
+	return w.commitCurrent()
+}
+
+// abort removes the file being written, the committed files are kept for the current index.
+func (w *writer) abort() {
+	if w.current != nil {
+		w.current.abort()
+		w.current = nil
+	}
+}
+
+func escape(s string) string {
+	var buf bytes.Buffer
+	_ = xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
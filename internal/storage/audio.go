@@ -0,0 +1,167 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/NekoWheel/NekoBox/internal/conf"
+)
+
+var ErrAudioType = errors.New("不支持的音频格式")
+
+// AudioError is the rejection of the uploaded voice note which is shown to the user.
+type AudioError struct {
+	Err error
+}
+
+func (e *AudioError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *AudioError) Unwrap() error {
+	return e.Err
+}
+
+func rejectAudio(err error) error {
+	return &AudioError{Err: err}
+}
+
+type webAudioType struct {
+	Ext         string
+	ContentType string
+}
+
+// webAudioTypes are the sniffed content types of the voice notes which the browsers can play as they are,
+// with the file extensions and the content types they are served with.
+var webAudioTypes = map[string]webAudioType{
+	"audio/mpeg":      {Ext: ".mp3", ContentType: "audio/mpeg"},
+	"video/mp4":       {Ext: ".m4a", ContentType: "audio/mp4"},
+	"application/ogg": {Ext: ".ogg", ContentType: "audio/ogg"},
+	"video/webm":      {Ext: ".webm", ContentType: "audio/webm"},
+	"audio/wave":      {Ext: ".wav", ContentType: "audio/wav"},
+}
+
+// Audio is the voice note which is ready to be uploaded.
+type Audio struct {
+	Data        []byte
+	Ext         string
+	ContentType string
+	// Duration is the length in seconds, it is 0 if the length is unknown.
+	Duration int
+}
+
+// PrepareAudio checks the size of the uploaded voice note against the configuration, and transcodes it into AAC
+// if ffmpeg is configured. Otherwise only the formats which the browsers can play are accepted as they are,
+// and the length of the voice note is only limited by the size. The rejected voice note returns *AudioError.
+func PrepareAudio(ctx context.Context, file multipart.File, header *multipart.FileHeader) (*Audio, error) {
+	maxSize := conf.Upload.MaxAudioSize * 1024 * 1024
+	if header.Size > maxSize {
+		return nil, rejectAudio(errors.Errorf("音频文件太大，最大支持 %dMB", conf.Upload.MaxAudioSize))
+	}
+
+	data, err := io.ReadAll(io.LimitReader(file, maxSize+1))
+	if err != nil {
+		return nil, errors.Wrap(err, "read audio")
+	}
+	if int64(len(data)) > maxSize {
+		return nil, rejectAudio(errors.Errorf("音频文件太大，最大支持 %dMB", conf.Upload.MaxAudioSize))
+	}
+
+	if conf.Upload.FFmpegPath != "" {
+		return transcodeAudio(ctx, data)
+	}
+
+	audioType, ok := webAudioTypes[http.DetectContentType(data)]
+	if !ok {
+		return nil, rejectAudio(ErrAudioType)
+	}
+	return &Audio{
+		Data:        data,
+		Ext:         audioType.Ext,
+		ContentType: audioType.ContentType,
+	}, nil
+}
+
+// ffmpegTimeout is the max time to transcode a voice note.
+const ffmpegTimeout = time.Minute
+
+// ffmpegTimePattern matches the progress of ffmpeg, the last one is the length of the output.
+var ffmpegTimePattern = regexp.MustCompile(`time=(\d+):(\d{2}):(\d{2}(?:\.\d+)?)`)
+
+func transcodeAudio(ctx context.Context, data []byte) (*Audio, error) {
+	dir, err := os.MkdirTemp("", "nekobox-audio-")
+	if err != nil {
+		return nil, errors.Wrap(err, "create temporary directory")
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	input := filepath.Join(dir, "input")
+	output := filepath.Join(dir, "output.m4a")
+	if err := os.WriteFile(input, data, 0600); err != nil {
+		return nil, errors.Wrap(err, "write audio")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, ffmpegTimeout)
+	defer cancel()
+
+	maxDuration := conf.Upload.MaxAudioDuration
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, conf.Upload.FFmpegPath,
+		"-hide_banner", "-nostdin", "-y",
+		"-i", input,
+		// The overlong voice note is cut a second after the limit, so it is rejected without being transcoded entirely.
+		"-t", strconv.Itoa(maxDuration+1),
+		"-vn", "-ac", "1", "-c:a", "aac", "-b:a", "64k",
+		"-movflags", "+faststart",
+		output,
+	)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && ctx.Err() == nil {
+			// ffmpeg is unable to decode the file.
+			return nil, rejectAudio(ErrAudioType)
+		}
+		return nil, errors.Wrap(err, "run ffmpeg")
+	}
+
+	matches := ffmpegTimePattern.FindAllStringSubmatch(stderr.String(), -1)
+	if len(matches) == 0 {
+		return nil, rejectAudio(ErrAudioType)
+	}
+	last := matches[len(matches)-1]
+	hours, _ := strconv.Atoi(last[1])
+	minutes, _ := strconv.Atoi(last[2])
+	seconds, _ := strconv.ParseFloat(last[3], 64)
+	duration := float64(hours*3600+minutes*60) + seconds
+	if duration > float64(maxDuration) {
+		return nil, rejectAudio(errors.Errorf("语音太长，最长支持 %d 秒", maxDuration))
+	}
+
+	transcoded, err := os.ReadFile(output)
+	if err != nil {
+		return nil, errors.Wrap(err, "read transcoded audio")
+	}
+	return &Audio{
+		Data:        transcoded,
+		Ext:         ".m4a",
+		ContentType: "audio/mp4",
+		Duration:    int(math.Ceil(duration)),
+	}, nil
+}
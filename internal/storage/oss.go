@@ -5,8 +5,11 @@
 package storage
 
 import (
+	"bytes"
 	"fmt"
 	"mime/multipart"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/aliyun/aliyun-oss-go-sdk/oss"
@@ -19,39 +22,109 @@ import (
 
 const (
 	OSSPictureKeyPrefix = "picture/"
+	OSSAudioKeyPrefix   = "audio/"
 )
 
 // UploadPictureToOSS upload user's avatar or background image to OSS.
 // It returns the uploaded asset URL.
 func UploadPictureToOSS(file multipart.File, _ *multipart.FileHeader) (string, error) {
-	client, err := oss.New(conf.Upload.AliyunEndpoint, conf.Upload.AliyunAccessID, conf.Upload.AliyunAccessSecret)
+	bucket, err := ossBucket()
 	if err != nil {
-		return "", errors.Wrap(err, "new oss client")
+		return "", err
 	}
 
-	bucket, err := client.Bucket(conf.Upload.AliyunBucket)
-	if err != nil {
-		return "", errors.Wrap(err, "bucket")
+	key := ossObjectKey(OSSPictureKeyPrefix, "")
+	if err := gadget.Retry(5, func() error {
+		if err := bucket.PutObject(key, file); err != nil {
+			return errors.Wrap(err, "put object")
+		}
+		return nil
+	}); err != nil {
+		return "", errors.Wrap(err, "retry 5 times")
 	}
+	return ossObjectURL(key), nil
+}
 
-	now := time.Now()
-	year := now.Year()
-	month := int(now.Month())
-	day := now.Day()
-
-	key := fmt.Sprintf("%s%d/%02d/%02d/%s", OSSPictureKeyPrefix, year, month, day, randstr.Hex(15))
+// UploadAudioToOSS uploads the voice note prepared by PrepareAudio to OSS.
+// It returns the uploaded asset URL.
+func UploadAudioToOSS(audio *Audio) (string, error) {
+	bucket, err := ossBucket()
+	if err != nil {
+		return "", err
+	}
 
+	key := ossObjectKey(OSSAudioKeyPrefix, audio.Ext)
 	if err := gadget.Retry(5, func() error {
-		if err := bucket.PutObject(key, file); err != nil {
+		if err := bucket.PutObject(key, bytes.NewReader(audio.Data), oss.ContentType(audio.ContentType)); err != nil {
 			return errors.Wrap(err, "put object")
 		}
 		return nil
 	}); err != nil {
 		return "", errors.Wrap(err, "retry 5 times")
 	}
+	return ossObjectURL(key), nil
+}
 
+// DeletePictureFromOSS deletes the picture uploaded by UploadPictureToOSS with its URL.
+// The pictures which are not uploaded to our bucket, such as the default avatar, are skipped.
+func DeletePictureFromOSS(pictureURL string) error {
+	return deleteFromOSS(pictureURL, OSSPictureKeyPrefix)
+}
+
+// DeleteAudioFromOSS deletes the voice note uploaded by UploadAudioToOSS with its URL.
+func DeleteAudioFromOSS(audioURL string) error {
+	return deleteFromOSS(audioURL, OSSAudioKeyPrefix)
+}
+
+// deleteFromOSS deletes the object with its URL, the object is skipped unless it is in our bucket
+// and its key has the given prefix.
+func deleteFromOSS(objectURL, keyPrefix string) error {
+	u, err := url.Parse(objectURL)
+	if err != nil {
+		return errors.Wrap(err, "parse object URL")
+	}
+
+	key := strings.TrimPrefix(u.Path, "/")
+	if u.Host != ossHost() || !strings.HasPrefix(key, keyPrefix) {
+		return nil
+	}
+
+	bucket, err := ossBucket()
+	if err != nil {
+		return err
+	}
+	if err := bucket.DeleteObject(key); err != nil {
+		return errors.Wrap(err, "delete object")
+	}
+	return nil
+}
+
+func ossBucket() (*oss.Bucket, error) {
+	client, err := oss.New(conf.Upload.AliyunEndpoint, conf.Upload.AliyunAccessID, conf.Upload.AliyunAccessSecret)
+	if err != nil {
+		return nil, errors.Wrap(err, "new oss client")
+	}
+
+	bucket, err := client.Bucket(conf.Upload.AliyunBucket)
+	if err != nil {
+		return nil, errors.Wrap(err, "bucket")
+	}
+	return bucket, nil
+}
+
+// ossObjectKey returns a random key of the new object under the prefix, the objects are grouped by the upload date.
+func ossObjectKey(prefix, ext string) string {
+	now := time.Now()
+	return fmt.Sprintf("%s%d/%02d/%02d/%s%s", prefix, now.Year(), int(now.Month()), now.Day(), randstr.Hex(15), ext)
+}
+
+func ossHost() string {
 	if conf.Upload.AliyunBucketCDNHost != "" {
-		return fmt.Sprintf("https://%s/%s", conf.Upload.AliyunBucketCDNHost, key), nil
+		return conf.Upload.AliyunBucketCDNHost
 	}
-	return fmt.Sprintf("https://%s.%s/%s", conf.Upload.AliyunBucket, conf.Upload.AliyunEndpoint, key), nil
+	return conf.Upload.AliyunBucket + "." + conf.Upload.AliyunEndpoint
+}
+
+func ossObjectURL(key string) string {
+	return fmt.Sprintf("https://%s/%s", ossHost(), key)
 }
@@ -4,8 +4,40 @@
 
 package storage
 
-// MaxAvatarSize is the max avatar size which is 2MB.
-const MaxAvatarSize = 2 * 1024 * 1024
+import (
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
 
-// MaxBackgroundSize is the max background size which is 2MB.
-const MaxBackgroundSize = 2 * 1024 * 1024
+	"github.com/pkg/errors"
+
+	"github.com/NekoWheel/NekoBox/internal/conf"
+)
+
+var ErrPictureType = errors.New("不支持的图片格式")
+
+// CheckPicture checks the size and the content type of the uploaded avatar or background against the configuration.
+// The content type is sniffed from the file rather than the one sent by the browser.
+func CheckPicture(file multipart.File, header *multipart.FileHeader) error {
+	if header.Size > conf.Upload.MaxPictureSize*1024*1024 {
+		return errors.Errorf("图片文件太大，最大支持 %dMB", conf.Upload.MaxPictureSize)
+	}
+
+	buf := make([]byte, 512)
+	n, err := io.ReadFull(file, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return errors.Wrap(err, "read picture")
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return errors.Wrap(err, "seek picture")
+	}
+
+	contentType := http.DetectContentType(buf[:n])
+	for _, allowed := range conf.Upload.AllowedPictureTypes {
+		if strings.EqualFold(strings.TrimSpace(allowed), contentType) {
+			return nil
+		}
+	}
+	return ErrPictureType
+}
@@ -12,6 +12,9 @@ import (
 	"time"
 
 	"github.com/NekoWheel/NekoBox/internal/conf"
+	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/markdown"
+	"github.com/NekoWheel/NekoBox/internal/theme"
 )
 
 var (
@@ -25,6 +28,34 @@ func FuncMap() []template.FuncMap {
 			"ICP": func() string {
 				return conf.App.ICP
 			},
+			"ExternalURL": func() string {
+				return conf.App.ExternalURL
+			},
+			"QuestionMaxLength": func() int {
+				return conf.Limits.QuestionMaxLength
+			},
+			"AnswerMaxLength": func() int {
+				return conf.Limits.AnswerMaxLength
+			},
+			// PushPublicKey returns the VAPID public key, it is empty if the Web Push notifications are disabled.
+			"PushPublicKey": func() string {
+				if conf.Push.VAPIDPrivateKey == "" {
+					return ""
+				}
+				return conf.Push.VAPIDPublicKey
+			},
+			"AudioMaxSize": func() int64 {
+				return conf.Upload.MaxAudioSize
+			},
+			"AudioMaxDuration": func() int {
+				return conf.Upload.MaxAudioDuration
+			},
+			"Themes": func() []theme.Theme {
+				return theme.Themes
+			},
+			"ContentLicenses": func() []db.ContentLicense {
+				return db.ContentLicenses
+			},
 			"CommitSHA": func() string {
 				return conf.BuildCommit
 			},
@@ -39,6 +70,10 @@ func FuncMap() []template.FuncMap {
 				format = replacer.Replace(format)
 				return t.Format(format)
 			},
+			"Add": func(a, b int) int {
+				return a + b
+			},
+			"Markdown": markdown.Render,
 			"AnswerFormat": func(input string) template.HTML {
 				input = html.EscapeString(input)
 				input = strings.ReplaceAll(input, "\n", "</br>")
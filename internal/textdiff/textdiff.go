@@ -0,0 +1,178 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package textdiff compares the revisions of the answers, the text is compared by the words,
+// and every CJK character is a word.
+package textdiff
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Op is the operation of the span in the diff.
+type Op string
+
+const (
+	OpEqual  Op = "equal"
+	OpInsert Op = "insert"
+	OpDelete Op = "delete"
+)
+
+// Span is the continuous text of the same operation.
+type Span struct {
+	Op   Op
+	Text string
+}
+
+// maxEdits is the maximum number of the edited words which are compared word by word,
+// the text of more edits is shown as replaced as a whole.
+const maxEdits = 1000
+
+// Diff returns the spans which turn the old text into the new text.
+func Diff(oldText, newText string) []Span {
+	a, b := tokenize(oldText), tokenize(newText)
+
+	// The common prefix and suffix are trimmed before comparing.
+	var prefix, suffix int
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var spans []Span
+	spans = appendSpan(spans, OpEqual, a[:prefix]...)
+	middle, ok := myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])
+	if ok {
+		spans = append(spans, middle...)
+	} else {
+		spans = appendSpan(spans, OpDelete, a[prefix:len(a)-suffix]...)
+		spans = appendSpan(spans, OpInsert, b[prefix:len(b)-suffix]...)
+	}
+	spans = appendSpan(spans, OpEqual, a[len(a)-suffix:]...)
+	return merge(spans)
+}
+
+// myers returns the shortest edit of the words, it returns false if there are more than maxEdits edits.
+func myers(a, b []string) ([]Span, bool) {
+	n, m := len(a), len(b)
+	limit := n + m
+	if limit > maxEdits {
+		limit = maxEdits
+	}
+
+	offset := limit + 1
+	v := make([]int, 2*limit+3)
+	var trace [][]int
+	for d := 0; d <= limit; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(trace, offset, a, b), true
+			}
+		}
+	}
+	return nil, false
+}
+
+// backtrack walks the trace of the edit from the end, the spans are returned from the start.
+func backtrack(trace [][]int, offset int, a, b []string) []Span {
+	var spans []Span
+	x, y := len(a), len(b)
+	for d := len(trace) - 1; d > 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			spans = append(spans, Span{Op: OpEqual, Text: a[x-1]})
+			x--
+			y--
+		}
+		if x == prevX {
+			spans = append(spans, Span{Op: OpInsert, Text: b[y-1]})
+			y--
+		} else {
+			spans = append(spans, Span{Op: OpDelete, Text: a[x-1]})
+			x--
+		}
+	}
+	for x > 0 {
+		spans = append(spans, Span{Op: OpEqual, Text: a[x-1]})
+		x--
+	}
+
+	for i, j := 0, len(spans)-1; i < j; i, j = i+1, j-1 {
+		spans[i], spans[j] = spans[j], spans[i]
+	}
+	return spans
+}
+
+func appendSpan(spans []Span, op Op, words ...string) []Span {
+	if len(words) == 0 {
+		return spans
+	}
+	return append(spans, Span{Op: op, Text: strings.Join(words, "")})
+}
+
+// merge joins the adjacent spans of the same operation.
+func merge(spans []Span) []Span {
+	merged := make([]Span, 0, len(spans))
+	for _, span := range spans {
+		if len(merged) > 0 && merged[len(merged)-1].Op == span.Op {
+			merged[len(merged)-1].Text += span.Text
+			continue
+		}
+		merged = append(merged, span)
+	}
+	return merged
+}
+
+// tokenize splits the text into the words, the letters and the digits of the other languages
+// make up a word, and every CJK character, space and punctuation is a word by itself.
+func tokenize(text string) []string {
+	var words []string
+	start := -1
+	for i, r := range text {
+		if (unicode.IsLetter(r) || unicode.IsDigit(r)) && !isCJK(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			words = append(words, text[start:i])
+			start = -1
+		}
+		words = append(words, string(r))
+	}
+	if start >= 0 {
+		words = append(words, text[start:])
+	}
+	return words
+}
+
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
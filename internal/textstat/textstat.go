@@ -0,0 +1,66 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package textstat counts the length of the answers and estimates how long they take to read.
+package textstat
+
+import (
+	"math"
+	"unicode"
+)
+
+const (
+	// cjkCharsPerMinute is the reading speed of the Chinese, Japanese and Korean text.
+	cjkCharsPerMinute = 300
+	// wordsPerMinute is the reading speed of the text separated by the spaces, such as English.
+	wordsPerMinute = 200
+)
+
+// Stats is the length of the text.
+type Stats struct {
+	// Characters is the number of the characters except the whitespaces.
+	Characters int
+	// Words counts every CJK character as a word, and the other words are separated by the spaces or the punctuations.
+	Words int
+	// ReadingSeconds is the estimated time to read the text.
+	ReadingSeconds int
+}
+
+// Count returns the length of the text.
+func Count(text string) Stats {
+	var stats Stats
+	var cjkChars, words int
+	inWord := false
+	for _, r := range text {
+		if unicode.IsSpace(r) {
+			inWord = false
+			continue
+		}
+		stats.Characters++
+
+		switch {
+		case isCJK(r):
+			cjkChars++
+			inWord = false
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if !inWord {
+				words++
+				inWord = true
+			}
+		case r == '\'' || r == '’' || r == '-':
+			// The contractions and the compound words are counted as one word.
+		default:
+			inWord = false
+		}
+	}
+
+	stats.Words = cjkChars + words
+	minutes := float64(cjkChars)/cjkCharsPerMinute + float64(words)/wordsPerMinute
+	stats.ReadingSeconds = int(math.Ceil(minutes * 60))
+	return stats
+}
+
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
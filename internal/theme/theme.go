@@ -0,0 +1,85 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package theme provides the color schemes of the box pages, which are applied by the CSS variables.
+package theme
+
+import (
+	"fmt"
+	"html/template"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Theme is a built-in color scheme, all the colors are in the `#rrggbb` format.
+type Theme struct {
+	Name       string
+	Label      string
+	Accent     string
+	Background string
+	Card       string
+	Text       string
+	Heading    string
+}
+
+const DefaultName = "default"
+
+// Themes is the list of the built-in themes, the default one keeps the original look of the site.
+var Themes = []Theme{
+	{Name: DefaultName, Label: "默认", Accent: "#1e87f0", Background: "#ffffff", Card: "#ffffff", Text: "#666666", Heading: "#333333"},
+	{Name: "sakura", Label: "樱花", Accent: "#e86f91", Background: "#fff0f3", Card: "#ffffff", Text: "#6b4a52", Heading: "#4a2f36"},
+	{Name: "ocean", Label: "海洋", Accent: "#0f7ea8", Background: "#e6f4f9", Card: "#ffffff", Text: "#35505c", Heading: "#1f3640"},
+	{Name: "forest", Label: "森林", Accent: "#3a8a4f", Background: "#eef5ee", Card: "#ffffff", Text: "#3e4f42", Heading: "#26342a"},
+	{Name: "sunset", Label: "日落", Accent: "#e0752d", Background: "#fff4eb", Card: "#ffffff", Text: "#5c4535", Heading: "#3d2b1f"},
+	{Name: "midnight", Label: "午夜", Accent: "#8c7ae6", Background: "#1e1f2b", Card: "#2a2c3b", Text: "#c5c6d0", Heading: "#ececf1"},
+}
+
+// Get returns the built-in theme with the given name.
+func Get(name string) (Theme, bool) {
+	for _, theme := range Themes {
+		if theme.Name == name {
+			return theme, true
+		}
+	}
+	return Theme{}, false
+}
+
+var colorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// IsColor returns whether the value is a color in the `#rrggbb` format.
+func IsColor(value string) bool {
+	return colorPattern.MatchString(value)
+}
+
+// IsBackgroundImage returns whether the URL can be used as the background image, only the HTTPS URLs
+// without the characters which may break out of the CSS `url()` function are allowed.
+func IsBackgroundImage(value string) bool {
+	if strings.ContainsAny(value, "\"'()\\<>; \t\r\n") {
+		return false
+	}
+	u, err := url.Parse(value)
+	return err == nil && u.Scheme == "https" && u.Host != ""
+}
+
+// Style returns the CSS variables of the theme with the custom accent color and background image,
+// the invalid custom values are ignored so that the stored values are never trusted.
+func Style(name, accent, backgroundImage string) template.CSS {
+	theme, ok := Get(name)
+	if !ok {
+		theme, _ = Get(DefaultName)
+	}
+	if IsColor(accent) {
+		theme.Accent = accent
+	}
+	image := "none"
+	if IsBackgroundImage(backgroundImage) {
+		image = fmt.Sprintf(`url("%s")`, backgroundImage)
+	}
+
+	return template.CSS(fmt.Sprintf(
+		"--theme-accent: %s; --theme-background: %s; --theme-background-image: %s; --theme-card: %s; --theme-text: %s; --theme-heading: %s;",
+		theme.Accent, theme.Background, image, theme.Card, theme.Text, theme.Heading,
+	))
+}
@@ -5,23 +5,77 @@
 package tracing
 
 import (
+	"context"
+	"strings"
+
 	"github.com/pkg/errors"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
 	stdout "go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
 	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+
+	"github.com/NekoWheel/NekoBox/internal/conf"
 )
 
-func Init() (*sdktrace.TracerProvider, error) {
-	exporter, err := stdout.New(stdout.WithPrettyPrint())
+// Init sets up the global tracer provider with the configured exporter,
+// the returned provider should be shut down to flush the remaining spans.
+func Init(ctx context.Context) (*sdktrace.TracerProvider, error) {
+	exporter, err := newExporter(ctx)
 	if err != nil {
-		return nil, errors.Wrap(err, "create stdout exporter")
+		return nil, errors.Wrap(err, "create exporter")
+	}
+
+	sampleRatio := conf.Tracing.SampleRatio
+	if sampleRatio <= 0 || sampleRatio > 1 {
+		sampleRatio = 1
 	}
+
 	tp := sdktrace.NewTracerProvider(
-		sdktrace.WithSampler(sdktrace.AlwaysSample()),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
 		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(
+			semconv.ServiceNameKey.String("nekobox"),
+			semconv.ServiceVersionKey.String(conf.BuildCommit),
+		)),
 	)
 	otel.SetTracerProvider(tp)
 	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
 	return tp, nil
 }
+
+func newExporter(ctx context.Context) (sdktrace.SpanExporter, error) {
+	switch conf.Tracing.Exporter {
+	case "otlp":
+		opts := []otlptracegrpc.Option{}
+		if conf.Tracing.OTLPEndpoint != "" {
+			opts = append(opts, otlptracegrpc.WithEndpoint(conf.Tracing.OTLPEndpoint))
+		}
+		if conf.Tracing.OTLPInsecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		if headers := parseHeaders(conf.Tracing.OTLPHeaders); len(headers) > 0 {
+			opts = append(opts, otlptracegrpc.WithHeaders(headers))
+		}
+		return otlptracegrpc.New(ctx, opts...)
+	case "stdout":
+		return stdout.New(stdout.WithPrettyPrint())
+	default:
+		return nil, errors.Errorf("unknown trace exporter %q", conf.Tracing.Exporter)
+	}
+}
+
+// parseHeaders parses the "key=value" pairs.
+func parseHeaders(pairs []string) map[string]string {
+	headers := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return headers
+}
@@ -0,0 +1,99 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package translate
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DeepL translates the text with the DeepL API.
+type DeepL struct {
+	apiKey string
+	apiURL string
+}
+
+// NewDeepL returns the DeepL provider, the free API is used for the free keys if the API URL is empty.
+func NewDeepL(apiKey, apiURL string) *DeepL {
+	if apiURL == "" {
+		apiURL = "https://api.deepl.com"
+		if strings.HasSuffix(apiKey, ":fx") {
+			apiURL = "https://api-free.deepl.com"
+		}
+	}
+	return &DeepL{
+		apiKey: apiKey,
+		apiURL: strings.TrimRight(apiURL, "/"),
+	}
+}
+
+func (*DeepL) String() string {
+	return "deepl"
+}
+
+type deeplResponse struct {
+	Translations []struct {
+		DetectedSourceLanguage string `json:"detected_source_language"`
+		Text                   string `json:"text"`
+	} `json:"translations"`
+}
+
+func (d *DeepL) Translate(ctx context.Context, text, targetLang string) (*Result, error) {
+	form := url.Values{
+		"text":        {text},
+		"target_lang": {deeplTargetLang(targetLang)},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.apiURL+"/v2/translate", strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, errors.Wrap(err, "new request")
+	}
+	req.Header.Set("Authorization", "DeepL-Auth-Key "+d.apiKey)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "do request")
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, errors.Errorf("unexpected status code %d: %s", resp.StatusCode, body)
+	}
+
+	var response deeplResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, errors.Wrap(err, "decode response")
+	}
+	if len(response.Translations) == 0 {
+		return nil, errors.New("empty translations")
+	}
+	return &Result{
+		Text:       response.Translations[0].Text,
+		SourceLang: baseLanguage(response.Translations[0].DetectedSourceLanguage),
+	}, nil
+}
+
+// deeplTargetLang returns the target language code of DeepL, which is the upper case base language
+// except English and Portuguese, whose variant must be specified.
+func deeplTargetLang(tag string) string {
+	lang := strings.ToUpper(tag)
+	switch {
+	case lang == "EN":
+		return "EN-US"
+	case lang == "PT":
+		return "PT-BR"
+	case strings.HasPrefix(lang, "EN-"), strings.HasPrefix(lang, "PT-"):
+		return lang
+	default:
+		return strings.ToUpper(baseLanguage(tag))
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package translate
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Google translates the text with the Google Cloud Translation API (Basic).
+type Google struct {
+	apiKey string
+	apiURL string
+}
+
+// NewGoogle returns the Google provider, the default endpoint is used if the API URL is empty.
+func NewGoogle(apiKey, apiURL string) *Google {
+	if apiURL == "" {
+		apiURL = "https://translation.googleapis.com"
+	}
+	return &Google{
+		apiKey: apiKey,
+		apiURL: strings.TrimRight(apiURL, "/"),
+	}
+}
+
+func (*Google) String() string {
+	return "google"
+}
+
+type googleResponse struct {
+	Data struct {
+		Translations []struct {
+			TranslatedText         string `json:"translatedText"`
+			DetectedSourceLanguage string `json:"detectedSourceLanguage"`
+		} `json:"translations"`
+	} `json:"data"`
+}
+
+func (g *Google) Translate(ctx context.Context, text, targetLang string) (*Result, error) {
+	form := url.Values{
+		"q":      {text},
+		"target": {googleTargetLang(targetLang)},
+		"format": {"text"},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.apiURL+"/language/translate/v2?key="+url.QueryEscape(g.apiKey), strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, errors.Wrap(err, "new request")
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "do request")
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, errors.Errorf("unexpected status code %d: %s", resp.StatusCode, body)
+	}
+
+	var response googleResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, errors.Wrap(err, "decode response")
+	}
+	if len(response.Data.Translations) == 0 {
+		return nil, errors.New("empty translations")
+	}
+	return &Result{
+		Text:       response.Data.Translations[0].TranslatedText,
+		SourceLang: baseLanguage(response.Data.Translations[0].DetectedSourceLanguage),
+	}, nil
+}
+
+// googleTargetLang returns the target language code of Google, which is the base language
+// except Chinese, whose script is told by the region, e.g. "zh-CN" and "zh-TW".
+func googleTargetLang(tag string) string {
+	if base := baseLanguage(tag); base != "zh" {
+		return base
+	}
+	if strings.EqualFold(tag, "zh-TW") || strings.EqualFold(tag, "zh-HK") || strings.EqualFold(tag, "zh-Hant") {
+		return "zh-TW"
+	}
+	return "zh-CN"
+}
@@ -0,0 +1,145 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package translate translates the questions and the answers with the configured machine translation service.
+package translate
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/text/language"
+
+	"github.com/NekoWheel/NekoBox/internal/conf"
+	"github.com/NekoWheel/NekoBox/internal/db"
+)
+
+// Result is the translated text.
+type Result struct {
+	Text string
+	// SourceLang is the base language of the text detected by the provider, e.g. "en".
+	SourceLang string
+}
+
+// Provider is a machine translation service.
+type Provider interface {
+	// Translate translates the text into the target language, which is a BCP 47 tag, e.g. "zh-CN".
+	Translate(ctx context.Context, text, targetLang string) (*Result, error)
+	// String returns the provider name, which is recorded along with the cached translations.
+	String() string
+}
+
+// ProviderFactory creates a translation provider with the configuration.
+type ProviderFactory func() (Provider, error)
+
+var factories = map[string]ProviderFactory{
+	"deepl": func() (Provider, error) {
+		return NewDeepL(conf.Translation.APIKey, conf.Translation.APIURL), nil
+	},
+	"google": func() (Provider, error) {
+		return NewGoogle(conf.Translation.APIKey, conf.Translation.APIURL), nil
+	},
+}
+
+// Register registers a translation provider factory with the given name,
+// the name can be used in the `provider` configuration of the translation.
+func Register(name string, factory ProviderFactory) {
+	factories[name] = factory
+}
+
+var (
+	provider     Provider
+	providerOnce sync.Once
+)
+
+// configuredProvider returns the configured translation provider, it returns nil if the translation is disabled.
+func configuredProvider() Provider {
+	providerOnce.Do(func() {
+		name := strings.TrimSpace(conf.Translation.Provider)
+		if name == "" {
+			return
+		}
+		factory, ok := factories[name]
+		if !ok {
+			logrus.WithField("translation_provider", name).Error("Unknown translation provider")
+			return
+		}
+
+		var err error
+		provider, err = factory()
+		if err != nil {
+			logrus.WithError(err).WithField("translation_provider", name).Error("Failed to create translation provider")
+		}
+	})
+	return provider
+}
+
+// Enabled returns whether the translation is available.
+func Enabled() bool {
+	return configuredProvider() != nil
+}
+
+var ErrDisabled = errors.New("translation is disabled")
+
+// Text translates the text into the target language with the configured provider.
+func Text(ctx context.Context, text, targetLang string) (*Result, error) {
+	p := configuredProvider()
+	if p == nil {
+		return nil, ErrDisabled
+	}
+	result, err := p.Translate(ctx, text, targetLang)
+	if err != nil {
+		return nil, errors.Wrapf(err, "translate with %s", p)
+	}
+	return result, nil
+}
+
+// Question returns the translation of the question's content into the target language. The translations
+// are cached per question, so each question is sent to the provider only once for a language.
+func Question(ctx context.Context, question *db.Question, targetLang string) (*db.QuestionTranslation, error) {
+	translation, err := db.QuestionTranslations.Get(ctx, question.ID, targetLang)
+	if err == nil {
+		return translation, nil
+	} else if !errors.Is(err, db.ErrQuestionTranslationNotExist) {
+		return nil, errors.Wrap(err, "get question translation")
+	}
+
+	result, err := Text(ctx, question.Content, targetLang)
+	if err != nil {
+		return nil, err
+	}
+	translation, err = db.QuestionTranslations.Save(ctx, db.SaveQuestionTranslationOptions{
+		QuestionID: question.ID,
+		TargetLang: targetLang,
+		SourceLang: result.SourceLang,
+		Provider:   configuredProvider().String(),
+		Text:       result.Text,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "save question translation")
+	}
+	return translation, nil
+}
+
+// SameLanguage reports whether the two BCP 47 tags are of the same base language, e.g. "zh-CN" and "zh".
+func SameLanguage(a, b string) bool {
+	return baseLanguage(a) == baseLanguage(b)
+}
+
+// baseLanguage returns the base language of the BCP 47 tag in lower case, e.g. "zh" for "zh-CN".
+func baseLanguage(tag string) string {
+	t, err := language.Parse(tag)
+	if err != nil {
+		return strings.ToLower(tag)
+	}
+	base, _ := t.Base()
+	return base.String()
+}
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
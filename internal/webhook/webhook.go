@@ -0,0 +1,198 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/thanhpk/randstr"
+
+	"github.com/NekoWheel/NekoBox/internal/conf"
+	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/security/ssrf"
+)
+
+type Event string
+
+const (
+	EventQuestionCreated  Event = "question.created"
+	EventQuestionAnswered Event = "question.answered"
+	EventQuestionDeleted  Event = "question.deleted"
+)
+
+// Payload is the JSON body which is posted to the webhook URL.
+type Payload struct {
+	Event     Event        `json:"event"`
+	Timestamp int64        `json:"timestamp"`
+	Domain    string       `json:"domain"`
+	URL       string       `json:"url"`
+	Question  *db.Question `json:"question"`
+}
+
+const (
+	workers        = 2
+	queueSize      = 1024
+	maxAttempts    = 5
+	baseBackoff    = 10 * time.Second
+	requestTimeout = 10 * time.Second
+
+	SignatureHeader = "X-NekoBox-Signature"
+	EventHeader     = "X-NekoBox-Event"
+	DeliveryHeader  = "X-NekoBox-Delivery"
+)
+
+type delivery struct {
+	ID      string
+	Webhook *db.Webhook
+	Event   Event
+	Body    []byte
+
+	attempt int
+}
+
+var (
+	deliveries = make(chan delivery, queueSize)
+
+	client = &http.Client{
+		Timeout: requestTimeout,
+		Transport: &http.Transport{
+			DialContext: (&net.Dialer{
+				Timeout: requestTimeout,
+				Control: ssrf.DenyPrivateAddress,
+			}).DialContext,
+		},
+		// Don't follow the redirects, which may point to the internal network.
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+)
+
+// Trigger sends the event of the question to all the webhooks of the page's owner,
+// the payloads are delivered by the workers in the background.
+func Trigger(ctx context.Context, event Event, pageUser *db.User, question *db.Question) {
+	webhooks, err := db.Webhooks.GetByUserID(ctx, pageUser.ID)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("Failed to get webhooks by user ID")
+		return
+	}
+	if len(webhooks) == 0 {
+		return
+	}
+
+	body, err := json.Marshal(Payload{
+		Event:     event,
+		Timestamp: time.Now().Unix(),
+		Domain:    pageUser.Domain,
+		URL:       fmt.Sprintf("%s/_/%s/%d", conf.App.ExternalURL, pageUser.Domain, question.ID),
+		Question:  question,
+	})
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("Failed to marshal webhook payload")
+		return
+	}
+
+	for _, webhook := range webhooks {
+		d := delivery{
+			ID:      randstr.Hex(16),
+			Webhook: webhook,
+			Event:   event,
+			Body:    body,
+		}
+		select {
+		case deliveries <- d:
+		default:
+			logrus.WithContext(ctx).WithField("webhook_id", webhook.ID).Warn("Webhook queue is full, drop the delivery")
+		}
+	}
+}
+
+// Start starts the webhook delivery workers, the workers stop when the context is done.
+func Start(ctx context.Context) {
+	for i := 0; i < workers; i++ {
+		go work(ctx)
+	}
+}
+
+func work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case d := <-deliveries:
+			deliver(ctx, d)
+		}
+	}
+}
+
+func deliver(ctx context.Context, d delivery) {
+	logger := logrus.WithContext(ctx).
+		WithField("webhook_id", d.Webhook.ID).
+		WithField("delivery_id", d.ID).
+		WithField("attempt", d.attempt+1)
+
+	err := send(ctx, d)
+	if err == nil {
+		return
+	}
+
+	d.attempt++
+	if d.attempt >= maxAttempts {
+		logger.WithError(err).Error("Failed to deliver webhook, give up")
+		return
+	}
+
+	backoff := baseBackoff << (d.attempt - 1)
+	logger.WithError(err).WithField("backoff", backoff.String()).Warn("Failed to deliver webhook, retry later")
+
+	time.AfterFunc(backoff, func() {
+		select {
+		case <-ctx.Done():
+		case deliveries <- d:
+		}
+	})
+}
+
+func send(ctx context.Context, d delivery) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.Webhook.URL, bytes.NewReader(d.Body))
+	if err != nil {
+		return errors.Wrap(err, "new request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "NekoBox-Webhook")
+	req.Header.Set(EventHeader, string(d.Event))
+	req.Header.Set(DeliveryHeader, d.ID)
+	req.Header.Set(SignatureHeader, Sign(d.Webhook.Secret, d.Body))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "do request")
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Sign returns the signature of the payload, the receiver should compute the
+// HMAC-SHA256 of the request body with the secret and compare it with the signature header.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,58 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package admin
+
+import (
+	"net/url"
+	"strconv"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/context"
+	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/dbutil"
+)
+
+// auditLogsPageSize is the number of the audit logs on each page of the viewer.
+const auditLogsPageSize = 50
+
+func AuditLogs(ctx context.Context) {
+	ctx.SetTitle("审计日志 - NekoBox")
+
+	opts := db.ListAuditLogsOptions{
+		Cursor: &dbutil.Cursor{
+			Value:    ctx.Query("cursor"),
+			PageSize: auditLogsPageSize,
+		},
+		Action: db.AuditAction(ctx.Query("action")),
+	}
+	if actor, err := strconv.ParseUint(ctx.Query("actor"), 10, 64); err == nil {
+		opts.ActorUserID = uint(actor)
+	}
+	if targetType := db.AuditTargetType(ctx.Query("target_type")); targetType != "" {
+		if targetID, err := strconv.ParseUint(ctx.Query("target_id"), 10, 64); err == nil {
+			opts.TargetType = targetType
+			opts.TargetID = uint(targetID)
+		}
+	}
+
+	logs, pageInfo, err := db.AuditLogs.List(ctx.Request().Context(), opts)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to list audit logs")
+		ctx.SetInternalError()
+		ctx.Success("admin/audit-logs")
+		return
+	}
+
+	ctx.Data["AuditLogs"] = logs
+	ctx.Data["AuditActions"] = db.AuditActions
+	ctx.Data["Filter"] = opts
+	if pageInfo.HasMore {
+		query := ctx.Request().URL.Query()
+		query.Set("cursor", pageInfo.NextCursor)
+		ctx.Data["NextPageURL"] = (&url.URL{Path: "/admin/audit-logs", RawQuery: query.Encode()}).String()
+	}
+	ctx.Success("admin/audit-logs")
+}
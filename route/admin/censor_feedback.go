@@ -0,0 +1,28 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package admin
+
+import (
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/context"
+	"github.com/NekoWheel/NekoBox/internal/db"
+)
+
+// CensorFeedback shows how often the resolutions of the reports disagree with each censor provider.
+func CensorFeedback(ctx context.Context) {
+	ctx.SetTitle("内容审核反馈 - NekoBox")
+
+	stats, err := db.CensorFeedbacks.StatsBySource(ctx.Request().Context())
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get censor feedback stats")
+		ctx.SetInternalError()
+		ctx.Success("admin/censor-feedback")
+		return
+	}
+	ctx.Data["Stats"] = stats
+
+	ctx.Success("admin/censor-feedback")
+}
@@ -0,0 +1,60 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package admin
+
+import (
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/context"
+	"github.com/NekoWheel/NekoBox/internal/cron"
+	"github.com/NekoWheel/NekoBox/internal/db"
+)
+
+// recentCronFailuresLimit is how many recent failed runs are shown on the cron jobs page.
+const recentCronFailuresLimit = 20
+
+type cronJobStatus struct {
+	cron.Job
+	// LastRun is nil if the job has never run.
+	LastRun *db.CronRun
+}
+
+// CronJobs lists the background jobs with their last runs and the recent failures.
+func CronJobs(ctx context.Context) {
+	ctx.SetTitle("定时任务 - NekoBox")
+
+	latestRuns, err := db.CronRuns.ListLatest(ctx.Request().Context())
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to list latest cron runs")
+		ctx.SetInternalError()
+		ctx.Success("admin/cron")
+		return
+	}
+	lastRuns := make(map[string]*db.CronRun, len(latestRuns))
+	for _, run := range latestRuns {
+		lastRuns[run.Name] = run
+	}
+
+	jobs := cron.Jobs()
+	statuses := make([]*cronJobStatus, 0, len(jobs))
+	for _, job := range jobs {
+		statuses = append(statuses, &cronJobStatus{
+			Job:     job,
+			LastRun: lastRuns[job.Name],
+		})
+	}
+	ctx.Data["Jobs"] = statuses
+
+	failures, err := db.CronRuns.ListFailed(ctx.Request().Context(), recentCronFailuresLimit)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to list failed cron runs")
+		ctx.SetInternalError()
+		ctx.Success("admin/cron")
+		return
+	}
+	ctx.Data["Failures"] = failures
+
+	ctx.Success("admin/cron")
+}
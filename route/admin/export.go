@@ -0,0 +1,140 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package admin
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/context"
+	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/export"
+)
+
+const exportDateLayout = "2006-01-02"
+
+type exportFilter struct {
+	From         string
+	To           string
+	User         string
+	CensorStatus string
+	MinReports   string
+}
+
+func exportFilterFromQuery(ctx context.Context) exportFilter {
+	return exportFilter{
+		From:         strings.TrimSpace(ctx.Query("from")),
+		To:           strings.TrimSpace(ctx.Query("to")),
+		User:         strings.TrimSpace(ctx.Query("user")),
+		CensorStatus: ctx.Query("censor_status"),
+		MinReports:   strings.TrimSpace(ctx.Query("min_reports")),
+	}
+}
+
+// options parses the filter except the user, the end date is inclusive.
+func (f exportFilter) options() (db.IterateFilteredQuestionsOptions, error) {
+	var opts db.IterateFilteredQuestionsOptions
+
+	if f.From != "" {
+		from, err := time.ParseInLocation(exportDateLayout, f.From, time.Local)
+		if err != nil {
+			return opts, errors.New("开始日期格式不正确")
+		}
+		opts.CreatedAfter = from
+	}
+	if f.To != "" {
+		to, err := time.ParseInLocation(exportDateLayout, f.To, time.Local)
+		if err != nil {
+			return opts, errors.New("结束日期格式不正确")
+		}
+		opts.CreatedBefore = to.AddDate(0, 0, 1)
+	}
+
+	if f.CensorStatus != "" {
+		opts.CensorStatus = db.QuestionCensorStatus(f.CensorStatus)
+		if !opts.CensorStatus.IsValid() {
+			return opts, errors.New("审核状态不合法")
+		}
+	}
+
+	if f.MinReports != "" {
+		minReports, err := strconv.Atoi(f.MinReports)
+		if err != nil || minReports < 0 {
+			return opts, errors.New("最少举报数不合法")
+		}
+		opts.MinReports = minReports
+	}
+	return opts, nil
+}
+
+// Export shows the filters of the question export.
+func Export(ctx context.Context) {
+	ctx.SetTitle("提问导出 - NekoBox")
+	ctx.Data["Filter"] = exportFilterFromQuery(ctx)
+	ctx.Success("admin/export")
+}
+
+// ExportQuestions streams the questions of all the users matching the filters as a CSV file,
+// which is used for the offline review and the legal requests.
+func ExportQuestions(ctx context.Context) {
+	ctx.SetTitle("提问导出 - NekoBox")
+	filter := exportFilterFromQuery(ctx)
+	ctx.Data["Filter"] = filter
+
+	opts, err := filter.options()
+	if err != nil {
+		ctx.SetError(err)
+		ctx.Success("admin/export")
+		return
+	}
+
+	if filter.User != "" {
+		user, err := findUser(ctx, filter.User)
+		if err != nil {
+			if errors.Is(err, db.ErrUserNotExists) {
+				ctx.SetError(err)
+			} else {
+				logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to find user")
+				ctx.SetInternalError()
+			}
+			ctx.Success("admin/export")
+			return
+		}
+		opts.UserID = user.ID
+	}
+
+	if err := export.Run(ctx.User.ID, func() error {
+		ctx.Audit(db.CreateAuditLogOptions{
+			Action: db.AuditActionQuestionExport,
+			Metadata: map[string]interface{}{
+				"from":          filter.From,
+				"to":            filter.To,
+				"user_id":       opts.UserID,
+				"censor_status": opts.CensorStatus,
+				"min_reports":   opts.MinReports,
+			},
+		})
+
+		fileName := fmt.Sprintf("NekoBox提问导出-%s.csv", time.Now().Format("20060102150405"))
+		ctx.ResponseWriter().Header().Set("Content-Type", "text/csv; charset=utf-8")
+		ctx.ResponseWriter().Header().Set("Content-Disposition", "attachment; filename*=UTF-8''"+url.QueryEscape(fileName))
+
+		return export.AdminQuestionsCSV(ctx.Request().Context(), ctx.ResponseWriter(), opts)
+	}); err != nil {
+		if errors.Is(err, export.ErrExportInProgress) || errors.Is(err, export.ErrExportBusy) {
+			ctx.SetError(err)
+			ctx.Success("admin/export")
+			return
+		}
+		// The response has been partly written, so we can only log the error here.
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to export questions")
+	}
+}
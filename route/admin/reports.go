@@ -0,0 +1,178 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package admin
+
+import (
+	"net/url"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/context"
+	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/dbutil"
+	"github.com/NekoWheel/NekoBox/internal/form"
+	"github.com/NekoWheel/NekoBox/internal/security/censor"
+)
+
+// reportsPageSize is the number of the reports on each page of the queue.
+const reportsPageSize = 50
+
+// reportItem is the pending report with its question and the box's owner,
+// which are nil if they have been deleted.
+type reportItem struct {
+	*db.Report
+	Question *db.Question
+	Owner    *db.User
+}
+
+// Reports lists the pending reports, the oldest reports come first.
+func Reports(ctx context.Context) {
+	ctx.SetTitle("举报处理 - NekoBox")
+
+	reports, pageInfo, err := db.Reports.ListPending(ctx.Request().Context(), db.ListPendingReportsOptions{
+		Cursor: &dbutil.Cursor{
+			Value:    ctx.Query("cursor"),
+			PageSize: reportsPageSize,
+		},
+	})
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to list pending reports")
+		ctx.SetInternalError()
+		ctx.Success("admin/reports")
+		return
+	}
+
+	questions := make(map[uint]*db.Question)
+	owners := make(map[uint]*db.User)
+	items := make([]*reportItem, 0, len(reports))
+	for _, report := range reports {
+		item := &reportItem{Report: report}
+
+		question, ok := questions[report.QuestionID]
+		if !ok {
+			question, err = db.Questions.GetByID(ctx.Request().Context(), report.QuestionID)
+			if err != nil && !errors.Is(err, db.ErrQuestionNotExist) {
+				logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get reported question")
+			}
+			questions[report.QuestionID] = question
+		}
+		item.Question = question
+
+		owner, ok := owners[report.QuestionUserID]
+		if !ok {
+			owner, err = db.Users.GetByID(ctx.Request().Context(), report.QuestionUserID)
+			if err != nil && !errors.Is(err, db.ErrUserNotExists) {
+				logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get owner of reported question")
+			}
+			owners[report.QuestionUserID] = owner
+		}
+		item.Owner = owner
+
+		items = append(items, item)
+	}
+
+	ctx.Data["Reports"] = items
+	if pageInfo.HasMore {
+		query := url.Values{"cursor": []string{pageInfo.NextCursor}}
+		ctx.Data["NextPageURL"] = (&url.URL{Path: "/admin/reports", RawQuery: query.Encode()}).String()
+	}
+	ctx.Success("admin/reports")
+}
+
+// ResolveReports closes all the pending reports of the question. The upheld question
+// is kept hidden from the visitors, and the dismissed one is shown again.
+func ResolveReports(ctx context.Context, f form.ResolveReports) {
+	if ctx.HasError() {
+		ctx.SetErrorFlash(ctx.ErrorMessage())
+		ctx.Redirect("/admin/reports")
+		return
+	}
+
+	status := db.ReportStatus(f.Status)
+	if status != db.ReportStatusUpheld && status != db.ReportStatusDismissed {
+		ctx.SetErrorFlash("处理结果不合法")
+		ctx.Redirect("/admin/reports")
+		return
+	}
+
+	questionID := uint(ctx.ParamInt("questionID"))
+	count, err := db.Reports.Resolve(ctx.Request().Context(), questionID, db.ResolveReportsOptions{
+		Status:           status,
+		ResolvedByUserID: ctx.User.ID,
+	})
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to resolve reports")
+		ctx.SetInternalErrorFlash()
+		ctx.Redirect("/admin/reports")
+		return
+	}
+
+	recordCensorFeedback(ctx, questionID, status)
+
+	// The question may have been deleted by its owner, there is nothing to hide then.
+	if err := db.Questions.SetHidden(ctx.Request().Context(), questionID, status == db.ReportStatusUpheld); err != nil && !errors.Is(err, db.ErrQuestionNotExist) {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to set question hidden")
+		ctx.SetInternalErrorFlash()
+		ctx.Redirect("/admin/reports")
+		return
+	}
+
+	ctx.Audit(db.CreateAuditLogOptions{
+		Action:     db.AuditActionReportResolve,
+		TargetType: db.AuditTargetQuestion,
+		TargetID:   questionID,
+		Metadata: map[string]interface{}{
+			"status": status,
+			"count":  count,
+		},
+	})
+
+	if status == db.ReportStatusUpheld {
+		ctx.SetSuccessFlash("已确认举报，该提问将保持隐藏。")
+	} else {
+		ctx.SetSuccessFlash("已驳回举报，该提问已恢复显示。")
+	}
+	ctx.Redirect("/admin/reports")
+}
+
+// recordCensorFeedback compares the resolution of the reports with the censor verdict of the question,
+// so the accuracy of the censor providers can be compared. The verdict is the failed one of the content
+// and the answer if any, the question which has not been censored is skipped.
+func recordCensorFeedback(ctx context.Context, questionID uint, status db.ReportStatus) {
+	question, err := db.Questions.GetByID(ctx.Request().Context(), questionID)
+	if err != nil {
+		if !errors.Is(err, db.ErrQuestionNotExist) {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get question by ID")
+		}
+		return
+	}
+
+	var verdict *censor.TextCensorResponse
+	for _, metadata := range [][]byte{question.ContentCensorMetadata, question.AnswerCensorMetadata} {
+		response, ok := censor.ParseMetadata(metadata)
+		// The verdict without the source is made when the censor is disabled.
+		if !ok || response.SourceName == "" {
+			continue
+		}
+		if verdict == nil || (verdict.Pass && !response.Pass) {
+			verdict = response
+		}
+	}
+	if verdict == nil {
+		return
+	}
+
+	if err := db.CensorFeedbacks.Create(ctx.Request().Context(), db.CreateCensorFeedbackOptions{
+		QuestionID:    question.ID,
+		SourceName:    verdict.SourceName,
+		ForbiddenType: string(verdict.ForbiddenType),
+		CensorPass:    verdict.Pass,
+		AdminPass:     status == db.ReportStatusDismissed,
+		AdminUserID:   ctx.User.ID,
+	}); err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to create censor feedback")
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package admin
+
+import (
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/context"
+	"github.com/NekoWheel/NekoBox/internal/db"
+)
+
+// ShadowBans lists the askers shadow-banned from all the boxes.
+func ShadowBans(ctx context.Context) {
+	ctx.SetTitle("静默屏蔽 - NekoBox")
+
+	blocks, err := db.Blocks.GetByUserID(ctx.Request().Context(), 0)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get shadow bans")
+		ctx.SetInternalError()
+		ctx.Success("admin/shadow-bans")
+		return
+	}
+	ctx.Data["Blocks"] = blocks
+
+	ctx.Success("admin/shadow-bans")
+}
+
+// ShadowBanAsker shadow-bans the asker of the reported question from all the boxes,
+// the later questions of the asker are put into the shadow folders of the owners.
+func ShadowBanAsker(ctx context.Context) {
+	questionID := uint(ctx.ParamInt("questionID"))
+	question, err := db.Questions.GetByID(ctx.Request().Context(), questionID)
+	if err != nil {
+		if errors.Is(err, db.ErrQuestionNotExist) {
+			ctx.SetErrorFlash(ctx.TrError(err))
+		} else {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get question by ID")
+			ctx.SetInternalErrorFlash()
+		}
+		ctx.Redirect("/admin/reports")
+		return
+	}
+
+	if err := db.Blocks.Create(ctx.Request().Context(), db.CreateBlockOptions{
+		AskerUserID: question.AskerUserID,
+		AskerIP:     question.FromIP,
+		Question:    question,
+		Mode:        db.BlockModeShadow,
+	}); err != nil {
+		if errors.Is(err, db.ErrBlockExists) || errors.Is(err, db.ErrBlockNoSource) {
+			ctx.SetErrorFlash(ctx.TrError(err))
+		} else {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to shadow-ban asker")
+			ctx.SetInternalErrorFlash()
+		}
+		ctx.Redirect("/admin/reports")
+		return
+	}
+
+	ctx.Audit(db.CreateAuditLogOptions{
+		Action:     db.AuditActionAskerShadowBan,
+		TargetType: db.AuditTargetQuestion,
+		TargetID:   question.ID,
+		Metadata: map[string]interface{}{
+			"asker_user_id": question.AskerUserID,
+		},
+	})
+
+	ctx.SetSuccessFlash("静默屏蔽提问者成功！该提问者之后的提问会被放入所有提问箱的静默箱。")
+	ctx.Redirect("/admin/reports")
+}
+
+// DeleteShadowBan lifts the shadow-ban of the asker.
+func DeleteShadowBan(ctx context.Context) {
+	blockID := uint(ctx.ParamInt("blockID"))
+	if err := db.Blocks.DeleteByID(ctx.Request().Context(), 0, blockID); err != nil {
+		if errors.Is(err, db.ErrBlockNotExist) {
+			ctx.SetErrorFlash(ctx.TrError(err))
+		} else {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to delete shadow ban")
+			ctx.SetInternalErrorFlash()
+		}
+		ctx.Redirect("/admin/shadow-bans")
+		return
+	}
+
+	ctx.Audit(db.CreateAuditLogOptions{
+		Action: db.AuditActionAskerShadowUnban,
+		Metadata: map[string]interface{}{
+			"block_id": blockID,
+		},
+	})
+
+	ctx.SetSuccessFlash("取消静默屏蔽成功！")
+	ctx.Redirect("/admin/shadow-bans")
+}
@@ -0,0 +1,140 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package admin
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/conf"
+	"github.com/NekoWheel/NekoBox/internal/context"
+	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/form"
+)
+
+// findUser finds the user by the ID, the email or the domain.
+func findUser(ctx context.Context, query string) (*db.User, error) {
+	if id, err := strconv.ParseUint(query, 10, 64); err == nil {
+		return db.Users.GetByID(ctx.Request().Context(), uint(id))
+	}
+	if strings.Contains(query, "@") {
+		return db.Users.GetByEmail(ctx.Request().Context(), query)
+	}
+	return db.Users.GetByDomain(ctx.Request().Context(), query)
+}
+
+// Users looks up the user to change the account state.
+func Users(ctx context.Context) {
+	ctx.SetTitle("用户管理 - NekoBox")
+	ctx.Data["UserStates"] = db.UserStates
+	ctx.Data["SuspensionDays"] = conf.Security.SuspensionDays
+	ctx.Data["MaxSuspensionDays"] = conf.Security.MaxSuspensionDays
+
+	query := strings.TrimSpace(ctx.Query("q"))
+	ctx.Data["Query"] = query
+	if query == "" {
+		ctx.Success("admin/users")
+		return
+	}
+
+	user, err := findUser(ctx, query)
+	if err != nil {
+		if errors.Is(err, db.ErrUserNotExists) {
+			ctx.SetError(err)
+		} else {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to find user")
+			ctx.SetInternalError()
+		}
+		ctx.Success("admin/users")
+		return
+	}
+	ctx.Data["TargetUser"] = user
+	ctx.Success("admin/users")
+}
+
+// UpdateUserState changes the account state of the user, the reason is kept in the audit log.
+func UpdateUserState(ctx context.Context, f form.UpdateUserState) {
+	userID := uint(ctx.ParamInt("userID"))
+	redirectTo := (&url.URL{Path: "/admin/users", RawQuery: url.Values{"q": []string{strconv.Itoa(int(userID))}}.Encode()}).String()
+
+	if ctx.HasError() {
+		ctx.SetErrorFlash(ctx.ErrorMessage())
+		ctx.Redirect(redirectTo)
+		return
+	}
+
+	state := db.UserState(f.State)
+	if !state.IsValid() {
+		ctx.SetErrorFlash("账号状态不合法")
+		ctx.Redirect(redirectTo)
+		return
+	}
+	if userID == ctx.User.ID {
+		ctx.SetErrorFlash("不能修改自己的账号状态")
+		ctx.Redirect(redirectTo)
+		return
+	}
+
+	user, err := db.Users.GetByID(ctx.Request().Context(), userID)
+	if err != nil {
+		if errors.Is(err, db.ErrUserNotExists) {
+			ctx.SetErrorFlash(ctx.TrError(err))
+		} else {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get user")
+			ctx.SetInternalErrorFlash()
+		}
+		ctx.Redirect("/admin/users")
+		return
+	}
+
+	var suspendedUntil *time.Time
+	if state == db.UserStateSuspended {
+		days := conf.Security.SuspensionDays
+		if f.Days != "" {
+			days, err = strconv.Atoi(f.Days)
+			if err != nil || days <= 0 || days > conf.Security.MaxSuspensionDays {
+				ctx.SetErrorFlash(fmt.Sprintf("暂停天数应在 1 到 %d 天之间", conf.Security.MaxSuspensionDays))
+				ctx.Redirect(redirectTo)
+				return
+			}
+		}
+		until := time.Now().AddDate(0, 0, days)
+		suspendedUntil = &until
+	}
+
+	if err := db.Users.SetState(ctx.Request().Context(), user.ID, db.SetUserStateOptions{
+		State:          state,
+		SuspendedUntil: suspendedUntil,
+	}); err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to set user state")
+		ctx.SetInternalErrorFlash()
+		ctx.Redirect(redirectTo)
+		return
+	}
+
+	metadata := map[string]interface{}{
+		"state":          state,
+		"previous_state": user.EffectiveState(),
+		"reason":         f.Reason,
+	}
+	if suspendedUntil != nil {
+		metadata["suspended_until"] = suspendedUntil
+	}
+	ctx.Audit(db.CreateAuditLogOptions{
+		Action:     db.AuditActionUserStateChange,
+		TargetType: db.AuditTargetUser,
+		TargetID:   user.ID,
+		Metadata:   metadata,
+	})
+
+	ctx.SetSuccessFlash("账号状态已更新")
+	ctx.Redirect(redirectTo)
+}
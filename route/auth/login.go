@@ -5,34 +5,42 @@
 package auth
 
 import (
+	"fmt"
+	"math"
+	"net/http"
 	"path"
+	"strconv"
+	"time"
 
-	"github.com/flamego/recaptcha"
+	"github.com/flamego/cache"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 
 	"github.com/NekoWheel/NekoBox/internal/context"
 	"github.com/NekoWheel/NekoBox/internal/db"
 	"github.com/NekoWheel/NekoBox/internal/form"
+	"github.com/NekoWheel/NekoBox/internal/security/captcha"
+	"github.com/NekoWheel/NekoBox/internal/security/loginthrottle"
+	"github.com/NekoWheel/NekoBox/internal/security/token"
 )
 
 func Login(ctx context.Context) {
 	ctx.Success("auth/login")
 }
 
-func LoginAction(ctx context.Context, f form.Login, recaptcha recaptcha.RecaptchaV2) {
+func LoginAction(ctx context.Context, f form.Login, captcha *captcha.Captcha) {
 	uri := ctx.Request().Request.RequestURI // Keep the query when redirecting.
 
-	// Check recaptcha code.
-	resp, err := recaptcha.Verify(f.Recaptcha, ctx.Request().Request.RemoteAddr)
+	// Check captcha code.
+	ok, err := captcha.Verify(ctx.Request().Context(), f.Captcha, ctx.RealIP())
 	if err != nil {
-		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to check recaptcha")
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to check captcha")
 		ctx.SetInternalErrorFlash()
 		ctx.Redirect(uri)
 		return
 	}
-	if !resp.Success {
-		ctx.SetErrorFlash("验证码错误")
+	if !ok {
+		ctx.SetErrorFlash(captcha.FailedMessage())
 		ctx.Redirect(uri)
 		return
 	}
@@ -42,10 +50,17 @@ func LoginAction(ctx context.Context, f form.Login, recaptcha recaptcha.Recaptch
 		return
 	}
 
+	if message, throttled := checkLoginThrottle(ctx, f.Email); throttled {
+		ctx.SetErrorFlash(message)
+		ctx.Redirect(uri)
+		return
+	}
+
 	user, err := db.Users.Authenticate(ctx.Request().Context(), f.Email, f.Password)
 	if err != nil {
 		if errors.Is(err, db.ErrBadCredential) {
-			ctx.SetErrorFlash(errors.Cause(err).Error())
+			auditLoginFailed(ctx, f.Email)
+			ctx.SetErrorFlash(ctx.TrError(err))
 		} else {
 			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to authenticate user")
 			ctx.SetInternalErrorFlash()
@@ -53,13 +68,179 @@ func LoginAction(ctx context.Context, f form.Login, recaptcha recaptcha.Recaptch
 		ctx.Redirect(uri)
 		return
 	}
+	if err := user.RestrictedError(); err != nil {
+		ctx.SetErrorFlash(ctx.TrError(err))
+		ctx.Redirect(uri)
+		return
+	}
 
-	to := ctx.Query("to")
-	to = path.Clean("/" + to)
-	if to == "" {
-		to = "/_/" + user.Domain
+	// The user is redirected to the own box if the page to return to is not given.
+	to := "/_/" + user.Domain
+	if ctx.Query("to") != "" {
+		to = path.Clean("/" + ctx.Query("to"))
 	}
 
-	ctx.Session.Set("uid", user.ID)
+	twoFactorEnabled, err := db.TwoFactors.IsEnabled(ctx.Request().Context(), user.ID)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to check two-factor authentication")
+		ctx.SetInternalErrorFlash()
+		ctx.Redirect(uri)
+		return
+	}
+	if twoFactorEnabled {
+		startTwoFactorLogin(ctx, user, to)
+		return
+	}
+
+	if err := ctx.SignIn(user.ID); err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to sign in")
+		ctx.SetInternalErrorFlash()
+		ctx.Redirect(uri)
+		return
+	}
+	clearLoginFailures(ctx, user.Email)
+	auditLogin(ctx, user, nil)
 	ctx.Redirect(to)
 }
+
+func LoginAPI(ctx context.Context, f form.TokenLogin, cache cache.Cache) error {
+	if ctx.HasError() {
+		return ctx.JSONError(40000, ctx.ErrorMessage())
+	}
+
+	if message, throttled := checkLoginThrottle(ctx, f.Email); throttled {
+		return ctx.JSONError(http.StatusTooManyRequests*100, message)
+	}
+
+	user, err := db.Users.Authenticate(ctx.Request().Context(), f.Email, f.Password)
+	if err != nil {
+		if errors.Is(err, db.ErrBadCredential) {
+			auditLoginFailed(ctx, f.Email)
+			return ctx.JSONError(40100, ctx.TrError(err))
+		}
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to authenticate user")
+		return ctx.ServerError()
+	}
+	if err := user.RestrictedError(); err != nil {
+		return ctx.JSONError(40300, ctx.TrError(err))
+	}
+
+	twoFactorEnabled, err := db.TwoFactors.IsEnabled(ctx.Request().Context(), user.ID)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to check two-factor authentication")
+		return ctx.ServerError()
+	}
+	if twoFactorEnabled {
+		if f.Passcode == "" {
+			return ctx.JSONError(40101, "请输入两步验证码")
+		}
+		if err := checkTwoFactorPasscode(ctx, cache, user.ID, f.Passcode); err != nil {
+			if errors.Is(err, errTwoFactorPasscodeBad) {
+				auditTwoFactorFailed(ctx, user)
+				return ctx.JSONError(40100, err.Error())
+			}
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to check two-factor passcode")
+			return ctx.ServerError()
+		}
+	}
+
+	if !token.Enabled() {
+		return ctx.JSONError(40300, "Token 登录未开启")
+	}
+
+	// The token is bound to a session record, which can be signed out on the sessions page.
+	userSession, sessionToken, err := db.UserSessions.Create(ctx.Request().Context(), db.CreateUserSessionOptions{
+		UserID:    user.ID,
+		UserAgent: ctx.Request().UserAgent(),
+		IP:        ctx.RealIP(),
+	})
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to create user session")
+		return ctx.ServerError()
+	}
+	signed, expiresAt, err := token.Issue(user.ID, sessionToken)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to issue token")
+		if err := db.UserSessions.DeleteByID(ctx.Request().Context(), user.ID, userSession.ID); err != nil {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to delete user session")
+		}
+		return ctx.ServerError()
+	}
+
+	clearLoginFailures(ctx, user.Email)
+	auditLogin(ctx, user, map[string]interface{}{"method": "token"})
+	return ctx.JSON(map[string]interface{}{
+		"token":      signed,
+		"expires_at": expiresAt,
+	})
+}
+
+func auditLogin(ctx context.Context, user *db.User, metadata map[string]interface{}) {
+	ctx.Audit(db.CreateAuditLogOptions{
+		ActorUserID: user.ID,
+		Action:      db.AuditActionLogin,
+		TargetType:  db.AuditTargetUser,
+		TargetID:    user.ID,
+		Metadata:    metadata,
+	})
+}
+
+// auditLoginFailed records the failed login, the email is kept to find out the brute-force attempts.
+// The failed login is counted to throttle the later logins as well.
+func auditLoginFailed(ctx context.Context, email string) {
+	ctx.Audit(db.CreateAuditLogOptions{
+		Action:   db.AuditActionLoginFailed,
+		Metadata: map[string]interface{}{"email": email},
+	})
+	countLoginFailure(ctx, email)
+}
+
+// auditTwoFactorFailed records the failed two-factor authentication of the user who has passed
+// the password check, it is counted as the failed login of the account as well.
+func auditTwoFactorFailed(ctx context.Context, user *db.User) {
+	ctx.Audit(db.CreateAuditLogOptions{
+		ActorUserID: user.ID,
+		Action:      db.AuditActionLoginFailed,
+		TargetType:  db.AuditTargetUser,
+		TargetID:    user.ID,
+		Metadata:    map[string]interface{}{"email": user.Email, "two_factor": true},
+	})
+	countLoginFailure(ctx, user.Email)
+}
+
+// countLoginFailure counts the failed login to throttle the later logins of the account.
+func countLoginFailure(ctx context.Context, email string) {
+	if err := loginthrottle.Fail(ctx.Request().Context(), loginthrottle.FailOptions{
+		Email:     email,
+		IP:        ctx.RealIP(),
+		UserAgent: ctx.Request().UserAgent(),
+	}); err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to record failed login")
+	}
+}
+
+// checkLoginThrottle returns the error message if the login has to wait because of the failed logins before.
+func checkLoginThrottle(ctx context.Context, email string) (string, bool) {
+	wait, err := loginthrottle.Check(ctx.Request().Context(), email, ctx.RealIP())
+	if err != nil {
+		// Don't block the login if the failed logins can't be counted.
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to check login throttle")
+		return "", false
+	}
+	if wait <= 0 {
+		return "", false
+	}
+
+	ctx.ResponseWriter().Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	if wait < time.Minute {
+		return fmt.Sprintf("登录失败次数过多，请 %d 秒后再试", int(math.Ceil(wait.Seconds()))), true
+	}
+	return fmt.Sprintf("登录失败次数过多，账号已被暂时锁定，请 %d 分钟后再试", int(math.Ceil(wait.Minutes()))), true
+}
+
+// clearLoginFailures stops counting the failed logins of the account after the user signs in.
+func clearLoginFailures(ctx context.Context, email string) {
+	if err := loginthrottle.Succeed(ctx.Request().Context(), email); err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to clear failed logins")
+	}
+}
@@ -9,6 +9,6 @@ import (
 )
 
 func Logout(ctx context.Context) {
-	ctx.Session.Flush()
+	ctx.SignOut()
 	ctx.Redirect("/")
 }
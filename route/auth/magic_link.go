@@ -0,0 +1,162 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/context"
+	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/form"
+	"github.com/NekoWheel/NekoBox/internal/mail"
+	"github.com/NekoWheel/NekoBox/internal/security/captcha"
+)
+
+const (
+	// loginLinkTTL is how long the login link can be used after it is sent.
+	loginLinkTTL = 15 * time.Minute
+	// maxLoginLinksPerWindow is the number of the login links which can be sent to a user in the window,
+	// so that the mailbox of the user won't be flooded.
+	maxLoginLinksPerWindow = 3
+	loginLinkWindow        = 10 * time.Minute
+)
+
+func MagicLogin(ctx context.Context) {
+	ctx.Success("auth/magic-login")
+}
+
+// MagicLoginAction sends the one-time login link to the email of the user.
+func MagicLoginAction(ctx context.Context, f form.MagicLogin, captcha *captcha.Captcha) {
+	// Check captcha code.
+	ok, err := captcha.Verify(ctx.Request().Context(), f.Captcha, ctx.RealIP())
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to check captcha")
+		ctx.SetInternalErrorFlash()
+		ctx.Redirect("/login/magic")
+		return
+	}
+	if !ok {
+		ctx.SetErrorFlash(captcha.FailedMessage())
+		ctx.Redirect("/login/magic")
+		return
+	}
+
+	if ctx.HasError() {
+		ctx.Success("auth/magic-login")
+		return
+	}
+
+	user, err := db.Users.GetByEmail(ctx.Request().Context(), f.Email)
+	if err != nil {
+		if errors.Is(err, db.ErrUserNotExists) {
+			ctx.SetErrorFlash("用户邮箱不存在")
+		} else {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get user by email")
+			ctx.SetInternalErrorFlash()
+		}
+		ctx.Redirect("/login/magic")
+		return
+	}
+
+	count, err := db.LoginLinks.Count(ctx.Request().Context(), user.ID, time.Now().Add(-loginLinkWindow))
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to count login links")
+		ctx.SetInternalErrorFlash()
+		ctx.Redirect("/login/magic")
+		return
+	}
+	if count >= maxLoginLinksPerWindow {
+		ctx.SetErrorFlash("邮件发送太频繁，请稍后再试")
+		ctx.Redirect("/login/magic")
+		return
+	}
+
+	_, token, err := db.LoginLinks.Create(ctx.Request().Context(), db.CreateLoginLinkOptions{
+		UserID: user.ID,
+		FromIP: ctx.RealIP(),
+		TTL:    loginLinkTTL,
+	})
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to create login link")
+		ctx.SetInternalErrorFlash()
+		ctx.Redirect("/login/magic")
+		return
+	}
+
+	if err := mail.SendLoginLinkMail(ctx.Request().Context(), user.Email, token, loginLinkTTL); err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to send login link mail")
+		ctx.SetErrorFlash("邮件发送失败，请稍后再试")
+		ctx.Redirect("/login/magic")
+		return
+	}
+
+	ctx.Data["email"] = user.Email
+	ctx.Data["expires"] = int(loginLinkTTL.Minutes())
+	ctx.Success("auth/magic-login-sent")
+}
+
+// VerifyMagicLogin asks the user to confirm the login, the link is not consumed on GET
+// since the mail clients and the security scanners may open the links in the mails.
+func VerifyMagicLogin(ctx context.Context) {
+	if ctx.Query("token") == "" {
+		ctx.Redirect("/login/magic")
+		return
+	}
+	ctx.Success("auth/magic-login-verify")
+}
+
+func VerifyMagicLoginAction(ctx context.Context) {
+	loginLink, err := db.LoginLinks.Consume(ctx.Request().Context(), ctx.Query("token"))
+	if err != nil {
+		if errors.Is(err, db.ErrLoginLinkNotExist) || errors.Is(err, db.ErrLoginLinkExpired) || errors.Is(err, db.ErrLoginLinkConsumed) {
+			ctx.SetErrorFlash(ctx.TrError(err))
+		} else {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to consume login link")
+			ctx.SetInternalErrorFlash()
+		}
+		ctx.Redirect("/login/magic")
+		return
+	}
+
+	user, err := db.Users.GetByID(ctx.Request().Context(), loginLink.UserID)
+	if err != nil {
+		ctx.SetErrorFlash("用户不存在")
+		ctx.Redirect("/login")
+		return
+	}
+	if err := user.RestrictedError(); err != nil {
+		ctx.SetErrorFlash(ctx.TrError(err))
+		ctx.Redirect("/login")
+		return
+	}
+
+	// The owner proves the ownership of the mailbox, so the failed logins are cleared.
+	clearLoginFailures(ctx, user.Email)
+
+	to := "/_/" + user.Domain
+	twoFactorEnabled, err := db.TwoFactors.IsEnabled(ctx.Request().Context(), user.ID)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to check two-factor authentication")
+		ctx.SetInternalErrorFlash()
+		ctx.Redirect("/login")
+		return
+	}
+	if twoFactorEnabled {
+		startTwoFactorLogin(ctx, user, to)
+		return
+	}
+
+	if err := ctx.SignIn(user.ID); err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to sign in")
+		ctx.SetInternalErrorFlash()
+		ctx.Redirect("/login")
+		return
+	}
+	auditLogin(ctx, user, map[string]interface{}{"method": "magic_link"})
+	ctx.Redirect(to)
+}
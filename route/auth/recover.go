@@ -9,7 +9,6 @@ import (
 	"time"
 
 	"github.com/flamego/cache"
-	"github.com/flamego/recaptcha"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"github.com/thanhpk/randstr"
@@ -18,23 +17,25 @@ import (
 	"github.com/NekoWheel/NekoBox/internal/db"
 	"github.com/NekoWheel/NekoBox/internal/form"
 	"github.com/NekoWheel/NekoBox/internal/mail"
+	"github.com/NekoWheel/NekoBox/internal/security/captcha"
+	"github.com/NekoWheel/NekoBox/internal/security/loginthrottle"
 )
 
 func ForgotPassword(ctx context.Context) {
 	ctx.Success("auth/forgot-password")
 }
 
-func ForgotPasswordAction(ctx context.Context, f form.ForgotPassword, cache cache.Cache, recaptcha recaptcha.RecaptchaV2) {
-	// Check recaptcha code.
-	resp, err := recaptcha.Verify(f.Recaptcha, ctx.Request().Request.RemoteAddr)
+func ForgotPasswordAction(ctx context.Context, f form.ForgotPassword, cache cache.Cache, captcha *captcha.Captcha) {
+	// Check captcha code.
+	ok, err := captcha.Verify(ctx.Request().Context(), f.Captcha, ctx.RealIP())
 	if err != nil {
-		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to check recaptcha")
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to check captcha")
 		ctx.SetInternalErrorFlash()
 		ctx.Redirect("/forgot-password")
 		return
 	}
-	if !resp.Success {
-		ctx.SetErrorFlash("验证码错误")
+	if !ok {
+		ctx.SetErrorFlash(captcha.FailedMessage())
 		ctx.Redirect("/forgot-password")
 		return
 	}
@@ -77,7 +78,7 @@ func ForgotPasswordAction(ctx context.Context, f form.ForgotPassword, cache cach
 		return
 	}
 
-	if err := mail.SendPasswordRecoveryMail(user.Email, code); err != nil {
+	if err := mail.SendPasswordRecoveryMail(ctx.Request().Context(), user.Email, code); err != nil {
 		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to send password recovery mail")
 		ctx.SetErrorFlash("邮件发送失败，请稍后再试")
 		ctx.Redirect("/forgot-password")
@@ -162,6 +163,17 @@ func RecoverPasswordAction(ctx context.Context, cache cache.Cache, f form.Recove
 		return
 	}
 
+	// The owner proves the ownership of the account by the mail, so the lockout is lifted.
+	if err := loginthrottle.Succeed(ctx.Request().Context(), user.Email); err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to clear failed logins")
+	}
+
+	ctx.Audit(db.CreateAuditLogOptions{
+		ActorUserID: user.ID,
+		Action:      db.AuditActionPasswordReset,
+		TargetType:  db.AuditTargetUser,
+		TargetID:    user.ID,
+	})
 	ctx.SetSuccessFlash("密码修改成功")
 	ctx.Redirect("/login")
 }
@@ -5,7 +5,6 @@
 package auth
 
 import (
-	"github.com/flamego/recaptcha"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 
@@ -13,23 +12,26 @@ import (
 	"github.com/NekoWheel/NekoBox/internal/context"
 	"github.com/NekoWheel/NekoBox/internal/db"
 	"github.com/NekoWheel/NekoBox/internal/form"
+	"github.com/NekoWheel/NekoBox/internal/security/boxdomain"
+	"github.com/NekoWheel/NekoBox/internal/security/captcha"
+	"github.com/NekoWheel/NekoBox/internal/security/disposable"
 )
 
 func Register(ctx context.Context) {
 	ctx.Success("auth/register")
 }
 
-func RegisterAction(ctx context.Context, f form.Register, recaptcha recaptcha.RecaptchaV2) {
-	// Check recaptcha code.
-	resp, err := recaptcha.Verify(f.Recaptcha, ctx.Request().Request.RemoteAddr)
+func RegisterAction(ctx context.Context, f form.Register, captcha *captcha.Captcha) {
+	// Check captcha code.
+	ok, err := captcha.Verify(ctx.Request().Context(), f.Captcha, ctx.RealIP())
 	if err != nil {
-		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to check recaptcha")
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to check captcha")
 		ctx.SetInternalErrorFlash()
 		ctx.Redirect("/register")
 		return
 	}
-	if !resp.Success {
-		ctx.SetErrorFlash("验证码错误")
+	if !ok {
+		ctx.SetErrorFlash(captcha.FailedMessage())
 		ctx.Redirect("/register")
 		return
 	}
@@ -39,6 +41,9 @@ func RegisterAction(ctx context.Context, f form.Register, recaptcha recaptcha.Re
 		return
 	}
 
+	// The domain is validated along with the user creation, after the compatible characters are normalized.
+	f.Domain = boxdomain.Normalize(f.Domain)
+
 	if err := db.Users.Create(ctx.Request().Context(), db.CreateUserOptions{
 		Name:       f.Name,
 		Password:   f.Password,
@@ -52,7 +57,10 @@ func RegisterAction(ctx context.Context, f form.Register, recaptcha recaptcha.Re
 		case errors.Is(err, db.ErrUserNotExists),
 			errors.Is(err, db.ErrBadCredential),
 			errors.Is(err, db.ErrDuplicateEmail),
-			errors.Is(err, db.ErrDuplicateDomain):
+			errors.Is(err, db.ErrDuplicateDomain),
+			errors.Is(err, disposable.ErrDisposableEmail),
+			errors.Is(err, boxdomain.ErrInvalid),
+			errors.Is(err, boxdomain.ErrReserved):
 			ctx.SetError(errors.Cause(err))
 
 		default:
@@ -67,3 +75,31 @@ func RegisterAction(ctx context.Context, f form.Register, recaptcha recaptcha.Re
 	ctx.SetSuccessFlash("注册成功，欢迎来到 NekoBox！")
 	ctx.Redirect("/login")
 }
+
+// CheckDomain checks whether the domain can be used for a new box, it is called
+// when the user is typing the domain in the registration form.
+func CheckDomain(ctx context.Context) error {
+	domain := boxdomain.Normalize(ctx.Query("domain"))
+	if err := boxdomain.Validate(domain); err != nil {
+		return ctx.JSON(map[string]interface{}{
+			"available": false,
+			"message":   ctx.TrError(err),
+		})
+	}
+
+	available, err := db.Users.IsDomainAvailable(ctx.Request().Context(), domain)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to check domain availability")
+		return ctx.ServerError()
+	}
+	if !available {
+		return ctx.JSON(map[string]interface{}{
+			"available": false,
+			"message":   ctx.TrError(db.ErrDuplicateDomain),
+		})
+	}
+	return ctx.JSON(map[string]interface{}{
+		"available": true,
+		"domain":    domain,
+	})
+}
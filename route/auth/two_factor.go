@@ -0,0 +1,213 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/flamego/cache"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/context"
+	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/form"
+	"github.com/NekoWheel/NekoBox/internal/security/twofactor"
+)
+
+// The user who passed the password check is kept in the session until the two-factor authentication is passed.
+const (
+	twoFactorUserIDSessionKey     = "twoFactorUserID"
+	twoFactorStartedAtSessionKey  = "twoFactorStartedAt"
+	twoFactorRedirectToSessionKey = "twoFactorRedirectTo"
+	twoFactorFailuresSessionKey   = "twoFactorFailures"
+
+	twoFactorLoginTimeout = 5 * time.Minute
+	// twoFactorMaxFailures is the number of the failed attempts after which the pending login
+	// is cancelled, and the password has to be checked again.
+	twoFactorMaxFailures = 5
+)
+
+var errTwoFactorPasscodeBad = errors.New("两步验证码错误")
+
+func startTwoFactorLogin(ctx context.Context, user *db.User, to string) {
+	ctx.Session.Set(twoFactorUserIDSessionKey, user.ID)
+	ctx.Session.Set(twoFactorStartedAtSessionKey, time.Now())
+	ctx.Session.Set(twoFactorRedirectToSessionKey, to)
+	ctx.Session.Delete(twoFactorFailuresSessionKey)
+	ctx.Redirect("/login/two-factor")
+}
+
+func clearTwoFactorLogin(ctx context.Context) {
+	ctx.Session.Delete(twoFactorUserIDSessionKey)
+	ctx.Session.Delete(twoFactorStartedAtSessionKey)
+	ctx.Session.Delete(twoFactorRedirectToSessionKey)
+	ctx.Session.Delete(twoFactorFailuresSessionKey)
+}
+
+// failTwoFactorLogin records the failed attempt of the pending login and redirects to the given page,
+// the pending login is cancelled after too many failed attempts.
+func failTwoFactorLogin(ctx context.Context, user *db.User, message, uri string) {
+	auditTwoFactorFailed(ctx, user)
+
+	failures, _ := ctx.Session.Get(twoFactorFailuresSessionKey).(int)
+	failures++
+	if failures >= twoFactorMaxFailures {
+		clearTwoFactorLogin(ctx)
+		ctx.SetErrorFlash("两步验证失败次数过多，请重新登录")
+		ctx.Redirect("/login")
+		return
+	}
+	ctx.Session.Set(twoFactorFailuresSessionKey, failures)
+	ctx.SetErrorFlash(message)
+	ctx.Redirect(uri)
+}
+
+// twoFactorPendingUser returns the user who is waiting for the two-factor authentication.
+func twoFactorPendingUser(ctx context.Context) (*db.User, bool) {
+	userID, ok := ctx.Session.Get(twoFactorUserIDSessionKey).(uint)
+	startedAt, _ := ctx.Session.Get(twoFactorStartedAtSessionKey).(time.Time)
+	if !ok || time.Since(startedAt) > twoFactorLoginTimeout {
+		clearTwoFactorLogin(ctx)
+		ctx.SetErrorFlash("登录已过期，请重新登录")
+		ctx.Redirect("/login")
+		return nil, false
+	}
+
+	user, err := db.Users.GetByID(ctx.Request().Context(), userID)
+	if err != nil {
+		clearTwoFactorLogin(ctx)
+		ctx.SetErrorFlash("用户不存在")
+		ctx.Redirect("/login")
+		return nil, false
+	}
+	return user, true
+}
+
+func finishTwoFactorLogin(ctx context.Context, user *db.User) {
+	to, _ := ctx.Session.Get(twoFactorRedirectToSessionKey).(string)
+	if to == "" {
+		to = "/_/" + user.Domain
+	}
+	clearTwoFactorLogin(ctx)
+
+	if err := ctx.SignIn(user.ID); err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to sign in")
+		ctx.SetInternalErrorFlash()
+		ctx.Redirect("/login")
+		return
+	}
+	clearLoginFailures(ctx, user.Email)
+	auditLogin(ctx, user, map[string]interface{}{"two_factor": true})
+	ctx.Redirect(to)
+}
+
+// checkTwoFactorPasscode validates the passcode of the user,
+// the passcode can't be used again in its valid period to prevent the replay attack.
+func checkTwoFactorPasscode(ctx context.Context, cache cache.Cache, userID uint, passcode string) error {
+	twoFactor, err := db.TwoFactors.GetByUserID(ctx.Request().Context(), userID)
+	if err != nil {
+		return errors.Wrap(err, "get two factor")
+	}
+
+	if !twofactor.Validate(passcode, twoFactor.Secret) {
+		return errTwoFactorPasscodeBad
+	}
+
+	usedCacheKey := fmt.Sprintf("two-factor-passcode:%d:%s", userID, passcode)
+	if _, err := cache.Get(ctx.Request().Context(), usedCacheKey); err == nil {
+		return errTwoFactorPasscodeBad
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return errors.Wrap(err, "read used passcode cache")
+	}
+	// The passcode is valid for 3 periods at most with the clock skew.
+	if err := cache.Set(ctx.Request().Context(), usedCacheKey, true, 90*time.Second); err != nil {
+		return errors.Wrap(err, "set used passcode cache")
+	}
+	return nil
+}
+
+func LoginTwoFactor(ctx context.Context) {
+	if _, ok := twoFactorPendingUser(ctx); !ok {
+		return
+	}
+	ctx.Success("auth/two-factor")
+}
+
+func LoginTwoFactorAction(ctx context.Context, f form.TwoFactor, cache cache.Cache) {
+	user, ok := twoFactorPendingUser(ctx)
+	if !ok {
+		return
+	}
+
+	if ctx.HasError() {
+		ctx.Success("auth/two-factor")
+		return
+	}
+
+	if message, throttled := checkLoginThrottle(ctx, user.Email); throttled {
+		ctx.SetErrorFlash(message)
+		ctx.Redirect("/login/two-factor")
+		return
+	}
+
+	if err := checkTwoFactorPasscode(ctx, cache, user.ID, f.Passcode); err != nil {
+		if errors.Is(err, errTwoFactorPasscodeBad) {
+			failTwoFactorLogin(ctx, user, err.Error(), "/login/two-factor")
+			return
+		}
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to check two-factor passcode")
+		ctx.SetInternalErrorFlash()
+		ctx.Redirect("/login/two-factor")
+		return
+	}
+
+	finishTwoFactorLogin(ctx, user)
+}
+
+func LoginTwoFactorRecovery(ctx context.Context) {
+	if _, ok := twoFactorPendingUser(ctx); !ok {
+		return
+	}
+	ctx.Success("auth/two-factor-recovery")
+}
+
+func LoginTwoFactorRecoveryAction(ctx context.Context, f form.TwoFactorRecovery) {
+	user, ok := twoFactorPendingUser(ctx)
+	if !ok {
+		return
+	}
+
+	if ctx.HasError() {
+		ctx.Success("auth/two-factor-recovery")
+		return
+	}
+
+	if message, throttled := checkLoginThrottle(ctx, user.Email); throttled {
+		ctx.SetErrorFlash(message)
+		ctx.Redirect("/login/two-factor/recovery")
+		return
+	}
+
+	if err := db.TwoFactors.UseRecoveryCode(ctx.Request().Context(), user.ID, f.RecoveryCode); err != nil {
+		if errors.Is(err, db.ErrTwoFactorRecoveryCodeBad) {
+			failTwoFactorLogin(ctx, user, ctx.TrError(err), "/login/two-factor/recovery")
+			return
+		}
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to use two-factor recovery code")
+		ctx.SetInternalErrorFlash()
+		ctx.Redirect("/login/two-factor/recovery")
+		return
+	}
+
+	count, err := db.TwoFactors.CountUnusedRecoveryCodes(ctx.Request().Context(), user.ID)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to count unused recovery codes")
+	}
+	ctx.SetWarningFlash(fmt.Sprintf("你使用了恢复码登录，还剩余 %d 个恢复码。如果你丢失了验证器，请及时重新设置两步验证。", count))
+	finishTwoFactorLogin(ctx, user)
+}
@@ -0,0 +1,246 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package federation
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/activitypub"
+	"github.com/NekoWheel/NekoBox/internal/conf"
+	"github.com/NekoWheel/NekoBox/internal/context"
+	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/dbutil"
+)
+
+const (
+	outboxPageSize = 20
+	// maxInboxBodySize limits the activities posted to the inboxes.
+	maxInboxBodySize = 1 << 20
+)
+
+// Enabled hides the federation endpoints unless the federation is enabled.
+func Enabled(ctx context.Context) {
+	if !activitypub.Enabled() {
+		ctx.ResponseWriter().WriteHeader(http.StatusNotFound)
+	}
+}
+
+// writeJSON writes the JSON object with the given content type.
+func writeJSON(ctx context.Context, contentType string, v interface{}) {
+	ctx.ResponseWriter().Header().Set("Content-Type", contentType+"; charset=utf-8")
+	ctx.ResponseWriter().WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(ctx.ResponseWriter()).Encode(v); err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to encode ActivityPub object")
+	}
+}
+
+// acceptsHTML reports whether the request comes from the browser, which is redirected to the web page.
+func acceptsHTML(ctx context.Context) bool {
+	return strings.Contains(ctx.Request().Header.Get("Accept"), "text/html")
+}
+
+// WebFinger resolves the fediverse address "acct:<domain>@<host>" to the actor of the box.
+func WebFinger(ctx context.Context) {
+	resource := ctx.Query("resource")
+	name, host, ok := strings.Cut(strings.TrimPrefix(strings.TrimPrefix(resource, "acct:"), "@"), "@")
+	if !ok || !strings.EqualFold(host, activitypub.Host()) {
+		ctx.ResponseWriter().WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	user, err := db.Users.GetByDomain(ctx.Request().Context(), name)
+	if err != nil {
+		if errors.Is(err, db.ErrUserNotExists) {
+			ctx.ResponseWriter().WriteHeader(http.StatusNotFound)
+			return
+		}
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get user by domain")
+		ctx.ResponseWriter().WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	actorURL := activitypub.ActorURL(user.ID)
+	profileURL := fmt.Sprintf("%s/_/%s", conf.App.ExternalURL, user.Domain)
+	writeJSON(ctx, "application/jrd+json", map[string]interface{}{
+		"subject": fmt.Sprintf("acct:%s@%s", user.Domain, activitypub.Host()),
+		"aliases": []string{profileURL, actorURL},
+		"links": []map[string]string{
+			{"rel": "self", "type": activitypub.ContentType, "href": actorURL},
+			{"rel": "http://webfinger.net/rel/profile-page", "type": "text/html", "href": profileURL},
+		},
+	})
+}
+
+// Actorer loads the user of the actor in the URL.
+func Actorer(ctx context.Context) {
+	user, err := db.Users.GetByID(ctx.Request().Context(), uint(ctx.ParamInt("userID")))
+	if err != nil {
+		if errors.Is(err, db.ErrUserNotExists) {
+			ctx.ResponseWriter().WriteHeader(http.StatusNotFound)
+			return
+		}
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get user by ID")
+		ctx.ResponseWriter().WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	ctx.Map(user)
+}
+
+func Actor(ctx context.Context, user *db.User) {
+	if acceptsHTML(ctx) {
+		ctx.Redirect("/_/" + user.Domain)
+		return
+	}
+
+	publicKeyPEM, err := activitypub.PublicKeyPEM(ctx.Request().Context(), user.ID)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get ActivityPub public key")
+		ctx.ResponseWriter().WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	writeJSON(ctx, activitypub.ContentType, activitypub.NewActor(user, publicKeyPEM))
+}
+
+// Outbox lists the Create activities of the public answers, the latest ones come first.
+func Outbox(ctx context.Context, user *db.User) {
+	outboxURL := activitypub.ActorURL(user.ID) + "/outbox"
+
+	if !ctx.QueryBool("page") {
+		_, pageInfo, err := db.Questions.GetByUserID(ctx.Request().Context(), user.ID, db.GetQuestionsByUserIDOptions{
+			Cursor:         &dbutil.Cursor{PageSize: 1, WithTotal: true},
+			FilterAnswered: true,
+		})
+		if err != nil {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get questions by user ID")
+			ctx.ResponseWriter().WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(ctx, activitypub.ContentType, &activitypub.OrderedCollection{
+			Context:    activitypub.ActivityStreamsContext,
+			ID:         outboxURL,
+			Type:       "OrderedCollection",
+			TotalItems: pageInfo.Total,
+			First:      outboxURL + "?page=true",
+		})
+		return
+	}
+
+	cursor := ctx.Query("cursor")
+	questions, pageInfo, err := db.Questions.GetByUserID(ctx.Request().Context(), user.ID, db.GetQuestionsByUserIDOptions{
+		Cursor:         &dbutil.Cursor{Value: cursor, PageSize: outboxPageSize},
+		FilterAnswered: true,
+	})
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get questions by user ID")
+		ctx.ResponseWriter().WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	page := &activitypub.OrderedCollectionPage{
+		Context:      activitypub.ActivityStreamsContext,
+		ID:           outboxURL + "?page=true",
+		Type:         "OrderedCollectionPage",
+		PartOf:       outboxURL,
+		OrderedItems: make([]interface{}, 0, len(questions)),
+	}
+	if cursor != "" {
+		page.ID += "&cursor=" + url.QueryEscape(cursor)
+	}
+	if pageInfo.HasMore {
+		page.Next = outboxURL + "?page=true&cursor=" + url.QueryEscape(pageInfo.NextCursor)
+	}
+	for _, question := range questions {
+		page.OrderedItems = append(page.OrderedItems, activitypub.NewCreate(user, question))
+	}
+	writeJSON(ctx, activitypub.ContentType, page)
+}
+
+// Followers only shows the number of the followers, the followers themselves are not listed for privacy.
+func Followers(ctx context.Context, user *db.User) {
+	count, err := db.ActivityPubFollowers.CountByUserID(ctx.Request().Context(), user.ID)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to count ActivityPub followers")
+		ctx.ResponseWriter().WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(ctx, activitypub.ContentType, &activitypub.OrderedCollection{
+		Context:    activitypub.ActivityStreamsContext,
+		ID:         activitypub.ActorURL(user.ID) + "/followers",
+		Type:       "OrderedCollection",
+		TotalItems: count,
+	})
+}
+
+// Note returns the Note of the public answer, which is fetched when the remote users look it up.
+func Note(ctx context.Context, user *db.User) {
+	questionID := uint(ctx.ParamInt("questionID"))
+	question, err := db.Questions.GetByID(ctx.Request().Context(), questionID)
+	if err != nil {
+		if errors.Is(err, db.ErrQuestionNotExist) {
+			ctx.ResponseWriter().WriteHeader(http.StatusNotFound)
+			return
+		}
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get question by ID")
+		ctx.ResponseWriter().WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if question.UserID != user.ID || !activitypub.IsPublished(question) {
+		ctx.ResponseWriter().WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if acceptsHTML(ctx) {
+		ctx.Redirect(fmt.Sprintf("/_/%s/%d", user.Domain, question.ID))
+		return
+	}
+
+	note := activitypub.NewNote(user, question)
+	note.Context = activitypub.ActivityStreamsContext
+	writeJSON(ctx, activitypub.ContentType, note)
+}
+
+// Inbox receives the activities of the actor, the activities are authenticated by the HTTP signatures.
+func Inbox(ctx context.Context) {
+	receive(ctx, uint(ctx.ParamInt("userID")))
+}
+
+// SharedInbox receives the activities of all the actors, which is used by the servers to
+// deliver the activity once for all the followed actors.
+func SharedInbox(ctx context.Context) {
+	receive(ctx, 0)
+}
+
+func receive(ctx context.Context, userID uint) {
+	body, err := io.ReadAll(io.LimitReader(ctx.Request().Request.Body, maxInboxBodySize))
+	if err != nil {
+		ctx.ResponseWriter().WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if err := activitypub.Receive(ctx.Request().Context(), ctx.Request().Request, body, userID); err != nil {
+		switch {
+		case errors.Is(err, activitypub.ErrInvalidSignature):
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Warn("Rejected ActivityPub activity with invalid signature")
+			ctx.ResponseWriter().WriteHeader(http.StatusUnauthorized)
+		case errors.Is(err, activitypub.ErrInvalidActivity):
+			ctx.ResponseWriter().WriteHeader(http.StatusBadRequest)
+		default:
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to receive ActivityPub activity")
+			ctx.ResponseWriter().WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+	ctx.ResponseWriter().WriteHeader(http.StatusAccepted)
+}
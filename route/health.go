@@ -0,0 +1,39 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package route
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/flamego/flamego"
+
+	"github.com/NekoWheel/NekoBox/internal/health"
+)
+
+// The probes are served without the session and the cache, so they don't depend on Redis
+// and don't create a session for every probe.
+
+// Healthz is the liveness probe, which only reports the process is serving.
+func Healthz(c flamego.Context) {
+	writeHealth(c, http.StatusOK, map[string]string{"status": health.StatusOK})
+}
+
+// Readyz is the readiness probe, which checks the database, Redis and the mail queue.
+func Readyz(c flamego.Context) {
+	report := health.Ready(c.Request().Context())
+	status := http.StatusOK
+	if report.Status != health.StatusOK {
+		status = http.StatusServiceUnavailable
+	}
+	writeHealth(c, status, report)
+}
+
+func writeHealth(c flamego.Context, status int, v interface{}) {
+	c.ResponseWriter().Header().Set("Content-Type", "application/json; charset=utf-8")
+	c.ResponseWriter().Header().Set("Cache-Control", "no-store")
+	c.ResponseWriter().WriteHeader(status)
+	_ = json.NewEncoder(c.ResponseWriter()).Encode(v)
+}
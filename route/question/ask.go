@@ -0,0 +1,325 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package question
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flamego/cache"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/thanhpk/randstr"
+
+	"github.com/NekoWheel/NekoBox/internal/context"
+	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/form"
+	"github.com/NekoWheel/NekoBox/internal/geoip"
+	"github.com/NekoWheel/NekoBox/internal/security/censor"
+	"github.com/NekoWheel/NekoBox/internal/security/disposable"
+	"github.com/NekoWheel/NekoBox/internal/security/pseudonym"
+	"github.com/NekoWheel/NekoBox/internal/security/signature"
+	"github.com/NekoWheel/NekoBox/internal/security/spam"
+	"github.com/NekoWheel/NekoBox/internal/security/wordfilter"
+)
+
+// askError is the rejection of the question which is shown to the asker, Code is the error code of the API.
+type askError struct {
+	Code int
+	Err  error
+}
+
+func (e *askError) Error() string {
+	return e.Err.Error()
+}
+
+func rejectQuestion(code int, err error) error {
+	return &askError{Code: code, Err: err}
+}
+
+// preparedQuestion is the question which has passed the checks of the box and is ready to be created.
+type preparedQuestion struct {
+	Content           string
+	ReceiveReplyEmail string
+	// Prompt is nil for the free question.
+	Prompt       *db.Prompt
+	FromIP       string
+	AskerUserID  uint
+	ShadowBanned bool
+	WordFilter   *db.WordFilter
+	SpamVerdict  *spam.Verdict
+	// CensorResponse is nil if the censor service is unavailable, the question is censored in the background then.
+	CensorResponse *censor.TextCensorResponse
+	// Masked is set if the question fails the censor only mildly and the box displays it masked.
+	Masked bool
+}
+
+// CensorRejected returns whether the question has failed the censor and can't be displayed masked.
+func (q *preparedQuestion) CensorRejected() bool {
+	return q.CensorResponse != nil && !q.CensorResponse.Pass && !q.Masked
+}
+
+// DisplayContent returns the content as it is displayed in the box, the flagged spans are masked
+// if the question is accepted masked.
+func (q *preparedQuestion) DisplayContent() string {
+	if !q.Masked {
+		return q.Content
+	}
+	return censor.Mask(q.Content, q.CensorResponse.Spans)
+}
+
+// prepareQuestion runs the checks of the box on the question before it is created, the rejection
+// is returned as an *askError. The question failing the censor is not rejected here, so the result
+// can be shown in the preview, the caller should check CensorRejected before creating it. The bot
+// signals of the ask form are nil if the question is not sent from the form.
+func prepareQuestion(ctx context.Context, pageUser *db.User, content, receiveReplyEmail, promptID string, formSignals *spam.FormSignals) (*preparedQuestion, error) {
+	if err := pageUser.BoxSettings.CheckQuestionLength(content); err != nil {
+		return nil, rejectQuestion(40000, err)
+	}
+
+	if err := disposable.Check(receiveReplyEmail); err != nil {
+		return nil, rejectQuestion(40000, err)
+	}
+
+	prompt, err := parsePrompt(ctx, pageUser, promptID)
+	if err != nil {
+		if errors.Is(err, db.ErrPromptNotExist) {
+			return nil, rejectQuestion(40000, err)
+		}
+		return nil, errors.Wrap(err, "get prompt")
+	}
+
+	prepared := &preparedQuestion{
+		Content:           content,
+		ReceiveReplyEmail: receiveReplyEmail,
+		Prompt:            prompt,
+		FromIP:            ctx.RealIP(),
+	}
+	if ctx.IsLogged {
+		prepared.AskerUserID = ctx.User.ID
+	}
+
+	// Reject the blocked asker.
+	blocked, err := db.Blocks.IsBlocked(ctx.Request().Context(), pageUser.ID, db.IsBlockedOptions{
+		AskerUserID: prepared.AskerUserID,
+		AskerIP:     prepared.FromIP,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "check block")
+	}
+	if blocked {
+		return nil, rejectQuestion(40300, errBlocked)
+	}
+
+	prepared.ShadowBanned, err = isShadowBanned(ctx, pageUser, prepared.AskerUserID, prepared.FromIP)
+	if err != nil {
+		return nil, errors.Wrap(err, "check shadow ban")
+	}
+
+	exceeded, err := exceedsAskLimit(ctx, pageUser, prepared.AskerUserID, prepared.FromIP)
+	if err != nil {
+		return nil, errors.Wrap(err, "check ask limit")
+	}
+	if exceeded {
+		return nil, rejectQuestion(http.StatusTooManyRequests*100, errAskLimit)
+	}
+
+	duplicate, err := isDuplicateQuestion(ctx, pageUser, prepared.AskerUserID, prepared.FromIP, content)
+	if err != nil {
+		return nil, errors.Wrap(err, "check duplicate question")
+	}
+	if duplicate {
+		return nil, rejectQuestion(40900, errDuplicateQuestion)
+	}
+
+	// The owner's word filters are checked before the global censor.
+	prepared.WordFilter, err = wordfilter.Match(ctx.Request().Context(), pageUser.ID, content)
+	if err != nil {
+		return nil, errors.Wrap(err, "match word filters")
+	}
+	if prepared.WordFilter != nil && prepared.WordFilter.Action == db.WordFilterActionReject {
+		return nil, rejectQuestion(40000, errWordFiltered)
+	}
+
+	// The suspected spam is accepted and flagged for the owner's review.
+	prepared.SpamVerdict = spam.Score(ctx.Request().Context(), &spam.Input{
+		UserID:            pageUser.ID,
+		AskerUserID:       prepared.AskerUserID,
+		AskerIP:           prepared.FromIP,
+		Content:           content,
+		ReceiveReplyEmail: receiveReplyEmail,
+		Form:              formSignals,
+	})
+	if prepared.SpamVerdict.Rejected() {
+		return nil, rejectQuestion(40000, errSpam)
+	}
+
+	// 🚨 Content security check.
+	prepared.CensorResponse, err = censor.Text(ctx.Request().Context(), content)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to censor text")
+		prepared.CensorResponse = nil
+	}
+	prepared.Masked = pageUser.BoxSettings.MaskProfanity && prepared.CensorResponse != nil && prepared.CensorResponse.Maskable()
+	return prepared, nil
+}
+
+// createQuestion saves the prepared question to the box and notifies the owner.
+func createQuestion(ctx context.Context, pageUser *db.User, prepared *preparedQuestion, referral *db.QuestionReferral) (*db.Question, error) {
+	var promptID uint
+	if prepared.Prompt != nil {
+		promptID = prepared.Prompt.ID
+	}
+
+	question, err := db.Questions.Create(ctx.Request().Context(), db.CreateQuestionOptions{
+		FromIP:            prepared.FromIP,
+		FromRegion:        geoip.Region(prepared.FromIP),
+		SpamScore:         prepared.SpamVerdict.Score,
+		UserID:            pageUser.ID,
+		Content:           prepared.Content,
+		ReceiveReplyEmail: prepared.ReceiveReplyEmail,
+		AskerUserID:       prepared.AskerUserID,
+		AskerPseudonym: pseudonym.Name(pageUser.ID, pseudonym.Identity{
+			UserID:    prepared.AskerUserID,
+			IP:        prepared.FromIP,
+			UserAgent: ctx.Request().UserAgent(),
+		}),
+		PromptID:     promptID,
+		Source:       referral,
+		Archived:     prepared.WordFilter != nil && prepared.WordFilter.Action == db.WordFilterActionArchive,
+		ShadowBanned: prepared.ShadowBanned,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "create question")
+	}
+
+	// Update censor result.
+	if err := db.Questions.UpdateCensor(ctx.Request().Context(), question.ID, db.UpdateQuestionCensorOptions{
+		ContentCensorMetadata: prepared.CensorResponse.ToJSON(),
+	}); err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to update question censor result")
+	}
+
+	if prepared.CensorResponse == nil {
+		// The censor service is unavailable now, retry it in the background.
+		censor.Enqueue(ctx.Request().Context(), censor.Job{Type: censor.JobTypeQuestionContent, ID: question.ID, Text: prepared.Content})
+	}
+
+	if prepared.AskerUserID != 0 {
+		if err := db.QuestionDrafts.Delete(ctx.Request().Context(), prepared.AskerUserID, pageUser.ID); err != nil {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to delete question draft")
+		}
+	}
+
+	// The archived question and the question in the shadow folder are accepted silently, the owner is not notified.
+	if question.ArchivedAt == nil && !question.ShadowBanned {
+		notifyNewQuestion(ctx, pageUser, question)
+	}
+	return question, nil
+}
+
+// previewTokenLifetime is how long the asker can confirm the previewed question.
+const previewTokenLifetime = 10 * time.Minute
+
+// previewTokenValue returns the signed value of the previewed question, the token is bound to
+// the box, the asker and the exact content, so it can't be used for another question.
+func previewTokenValue(pageUser *db.User, askerUserID uint, expiresAt int64, nonce, content, receiveReplyEmail, promptID string) string {
+	return fmt.Sprintf("%d|%d|%d|%s|%s|%s|%s", pageUser.ID, askerUserID, expiresAt, nonce, promptID, receiveReplyEmail, content)
+}
+
+func previewTokenCacheKey(nonce string) string {
+	return "preview-token:" + nonce
+}
+
+// signPreviewToken returns the token of the previewed question, which proves the question has passed
+// the captcha when it is confirmed. The nonce of the token is kept in the cache until the token is used,
+// so that the token can only be used once. It returns the empty token if the nonce can't be kept.
+func signPreviewToken(ctx context.Context, cache cache.Cache, pageUser *db.User, content, receiveReplyEmail, promptID string) (string, time.Time) {
+	nonce := randstr.Hex(16)
+	if err := cache.Set(ctx.Request().Context(), previewTokenCacheKey(nonce), true, previewTokenLifetime); err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to set preview token cache")
+		return "", time.Time{}
+	}
+
+	var askerUserID uint
+	if ctx.IsLogged {
+		askerUserID = ctx.User.ID
+	}
+	expiresAt := time.Now().Add(previewTokenLifetime)
+	value := previewTokenValue(pageUser, askerUserID, expiresAt.Unix(), nonce, content, receiveReplyEmail, promptID)
+	return strconv.FormatInt(expiresAt.Unix(), 10) + "." + nonce + "." + signature.Sign(signature.PurposePreviewQuestion, value), expiresAt
+}
+
+// verifyPreviewToken reports whether the token is issued for the question in the preview and not expired,
+// the token is used up once it is verified.
+func verifyPreviewToken(ctx context.Context, cache cache.Cache, pageUser *db.User, token, content, receiveReplyEmail, promptID string) bool {
+	parts := strings.SplitN(token, ".", 3)
+	if len(parts) != 3 {
+		return false
+	}
+	expires, nonce, sig := parts[0], parts[1], parts[2]
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > expiresAt {
+		return false
+	}
+
+	var askerUserID uint
+	if ctx.IsLogged {
+		askerUserID = ctx.User.ID
+	}
+	value := previewTokenValue(pageUser, askerUserID, expiresAt, nonce, content, receiveReplyEmail, promptID)
+	if !signature.Verify(signature.PurposePreviewQuestion, value, sig) {
+		return false
+	}
+
+	cacheKey := previewTokenCacheKey(nonce)
+	if _, err := cache.Get(ctx.Request().Context(), cacheKey); err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get preview token cache")
+		}
+		return false
+	}
+	if err := cache.Delete(ctx.Request().Context(), cacheKey); err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to delete preview token cache")
+		return false
+	}
+	return true
+}
+
+// askFormTokenLifetime is how long the signed time of the ask form is accepted, the bots can't reuse
+// the token of a page fetched once for a long time.
+const askFormTokenLifetime = 24 * time.Hour
+
+// signAskFormToken returns the token of the time when the ask form of the box is rendered,
+// which is used to check how long the form is filled in.
+func signAskFormToken(pageUser *db.User) string {
+	renderedAt := strconv.FormatInt(time.Now().Unix(), 10)
+	return renderedAt + "." + signature.Sign(signature.PurposeAskForm, fmt.Sprintf("%d|%s", pageUser.ID, renderedAt))
+}
+
+// askFormSignals returns the bot signals of the ask form for the spam scorers, the fill duration is
+// zero if the token is missing, forged, issued for another box or expired.
+func askFormSignals(pageUser *db.User, f form.NewQuestion) *spam.FormSignals {
+	signals := &spam.FormSignals{Honeypot: f.Website}
+
+	renderedAt, sig, ok := strings.Cut(f.FormToken, ".")
+	if !ok {
+		return signals
+	}
+	unix, err := strconv.ParseInt(renderedAt, 10, 64)
+	if err != nil || !signature.Verify(signature.PurposeAskForm, fmt.Sprintf("%d|%s", pageUser.ID, renderedAt), sig) {
+		return signals
+	}
+
+	filledIn := time.Since(time.Unix(unix, 0))
+	if filledIn > 0 && filledIn <= askFormTokenLifetime {
+		signals.FilledIn = filledIn
+	}
+	return signals
+}
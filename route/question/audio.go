@@ -0,0 +1,109 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package question
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/context"
+	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/storage"
+)
+
+// UploadAnswerAudio attaches the voice note to the answer, the previous voice note is replaced.
+func UploadAnswerAudio(ctx context.Context, pageUser *db.User, question *db.Question, role db.BoxMemberRole) {
+	if !canEditAnswer(ctx, role, question) {
+		ctx.Redirect("/")
+		return
+	}
+
+	redirectTo := fmt.Sprintf("/_/%s/%d", pageUser.Domain, question.ID)
+	if question.Answer == "" {
+		ctx.SetErrorFlash(ctx.TrError(db.ErrQuestionNotAnswered))
+		ctx.Redirect(redirectTo)
+		return
+	}
+
+	file, header, err := ctx.Request().FormFile("audio")
+	if err != nil {
+		ctx.SetErrorFlash("请选择要上传的语音")
+		ctx.Redirect(redirectTo)
+		return
+	}
+	defer func() { _ = file.Close() }()
+
+	audio, err := storage.PrepareAudio(ctx.Request().Context(), file, header)
+	if err != nil {
+		var audioErr *storage.AudioError
+		if errors.As(err, &audioErr) {
+			ctx.SetErrorFlash(ctx.TrError(audioErr.Err))
+		} else {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to prepare answer audio")
+			ctx.SetInternalErrorFlash()
+		}
+		ctx.Redirect(redirectTo)
+		return
+	}
+
+	audioURL, err := storage.UploadAudioToOSS(audio)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to upload answer audio")
+		ctx.SetInternalErrorFlash()
+		ctx.Redirect(redirectTo)
+		return
+	}
+
+	if err := db.Questions.UpdateAnswerAudio(ctx.Request().Context(), question.ID, audioURL, audio.Duration); err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to update answer audio")
+		ctx.SetInternalErrorFlash()
+		ctx.Redirect(redirectTo)
+		deleteAnswerAudio(audioURL)
+		return
+	}
+	deleteAnswerAudio(question.AnswerAudioURL)
+
+	ctx.SetSuccessFlash("语音回答上传成功！")
+	ctx.Redirect(redirectTo)
+}
+
+// DeleteAnswerAudio removes the voice note of the answer, the text answer is kept.
+func DeleteAnswerAudio(ctx context.Context, pageUser *db.User, question *db.Question, role db.BoxMemberRole) {
+	if !canEditAnswer(ctx, role, question) {
+		ctx.Redirect("/")
+		return
+	}
+
+	redirectTo := fmt.Sprintf("/_/%s/%d", pageUser.Domain, question.ID)
+	if question.AnswerAudioURL == "" {
+		ctx.Redirect(redirectTo)
+		return
+	}
+
+	if err := db.Questions.UpdateAnswerAudio(ctx.Request().Context(), question.ID, "", 0); err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to delete answer audio")
+		ctx.SetInternalErrorFlash()
+		ctx.Redirect(redirectTo)
+		return
+	}
+	deleteAnswerAudio(question.AnswerAudioURL)
+
+	ctx.SetSuccessFlash("语音回答已删除")
+	ctx.Redirect(redirectTo)
+}
+
+// deleteAnswerAudio deletes the voice note which is no longer referenced in the background.
+func deleteAnswerAudio(audioURL string) {
+	if audioURL == "" {
+		return
+	}
+	go func() {
+		if err := storage.DeleteAudioFromOSS(audioURL); err != nil {
+			logrus.WithError(err).WithField("url", audioURL).Error("Failed to delete answer audio")
+		}
+	}()
+}
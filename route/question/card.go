@@ -0,0 +1,81 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package question
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/flamego/cache"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/context"
+	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/sharecard"
+)
+
+// shareCardCacheLifetime is how long the rendered share card is cached,
+// the cache key contains the question's update time, so the edited answer is rendered again.
+const shareCardCacheLifetime = 24 * time.Hour
+
+// ShareCard serves the PNG share card of the answered question.
+func ShareCard(ctx context.Context, cache cache.Cache) {
+	questionID := uint(ctx.ParamInt("questionID"))
+	question, err := db.Questions.GetByID(ctx.Request().Context(), questionID)
+	if err != nil {
+		if !errors.Is(err, db.ErrQuestionNotExist) {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get question by ID")
+			ctx.ResponseWriter().WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		ctx.ResponseWriter().WriteHeader(http.StatusNotFound)
+		return
+	}
+	// Only the answered public questions, or the unlisted ones with the share token, are rendered.
+	if !question.IsVisible(ctx.Query("s")) {
+		ctx.ResponseWriter().WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	cacheKey := fmt.Sprintf("share-card:%d:%d", question.ID, question.UpdatedAt.Unix())
+	var card []byte
+	cardItf, err := cache.Get(ctx.Request().Context(), cacheKey)
+	if err == nil {
+		card, _ = cardItf.([]byte)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to read share card cache")
+	}
+
+	if card == nil {
+		pageUser, err := db.Users.GetByID(ctx.Request().Context(), question.UserID)
+		if err != nil {
+			if !errors.Is(err, db.ErrUserNotExists) {
+				logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get user by ID")
+				ctx.ResponseWriter().WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			ctx.ResponseWriter().WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		card, err = sharecard.Render(pageUser, question)
+		if err != nil {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to render share card")
+			ctx.ResponseWriter().WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		if err := cache.Set(ctx.Request().Context(), cacheKey, card, shareCardCacheLifetime); err != nil {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to set share card cache")
+		}
+	}
+
+	ctx.ResponseWriter().Header().Set("Content-Type", "image/png")
+	ctx.ResponseWriter().Header().Set("Cache-Control", "public, max-age=3600")
+	_, _ = ctx.ResponseWriter().Write(card)
+}
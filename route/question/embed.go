@@ -0,0 +1,100 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package question
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/flamego/cache"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/conf"
+	"github.com/NekoWheel/NekoBox/internal/context"
+	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/form"
+	"github.com/NekoWheel/NekoBox/internal/security/captcha"
+)
+
+// Embedder loads the box of the ask widget, the widget is only available if the owner
+// has allowed some sites to embed it.
+func Embedder(ctx context.Context) {
+	pageUser, err := db.Users.GetByDomain(ctx.Request().Context(), ctx.Param("domain"))
+	if err == nil && pageUser.EffectiveState() == db.UserStateBanned {
+		err = db.ErrUserNotExists
+	}
+	if err != nil {
+		if errors.Is(err, db.ErrUserNotExists) {
+			ctx.ResponseWriter().WriteHeader(http.StatusNotFound)
+			return
+		}
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get user by domain")
+		ctx.ResponseWriter().WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if len(pageUser.BoxSettings.EmbedOrigins) == 0 {
+		ctx.ResponseWriter().WriteHeader(http.StatusNotFound)
+		return
+	}
+	ctx.Map(pageUser)
+}
+
+// Embed renders the minimal ask form in the iframe, which can only be framed by the allowed sites.
+func Embed(ctx context.Context, pageUser *db.User) {
+	ctx.ResponseWriter().Header().Del("X-Frame-Options")
+	ctx.ResponseWriter().Header().Set("Content-Security-Policy", "frame-ancestors 'self' "+strings.Join(pageUser.BoxSettings.EmbedOrigins, " "))
+
+	ctx.SetTitle(fmt.Sprintf("%s的提问箱 - NekoBox", pageUser.Name))
+	ctx.Data["PageUser"] = pageUser
+	ctx.Data["CanAsk"] = pageUser.HarassmentSetting != db.HarassmentSettingTypeRegisterOnly
+	ctx.Success("question/embed")
+}
+
+// EmbedCORS allows the allowed sites to post the questions from their own pages, the requests
+// from the other sites are rejected. The iframe of the widget is served by ourselves, so its
+// requests are always allowed.
+func EmbedCORS(ctx context.Context, pageUser *db.User) error {
+	origin := ctx.Request().Header.Get("Origin")
+	if origin == "" || isSelfOrigin(origin) {
+		return nil
+	}
+	if !pageUser.BoxSettings.AllowsEmbedOrigin(origin) {
+		return ctx.JSONError(40300, "该网站不允许嵌入提问箱")
+	}
+
+	header := ctx.ResponseWriter().Header()
+	header.Set("Access-Control-Allow-Origin", origin)
+	header.Add("Vary", "Origin")
+	if ctx.Request().Method == http.MethodOptions {
+		header.Set("Access-Control-Allow-Methods", "POST")
+		header.Set("Access-Control-Allow-Headers", "Content-Type")
+		header.Set("Access-Control-Max-Age", "86400")
+		ctx.ResponseWriter().WriteHeader(http.StatusNoContent)
+	}
+	return nil
+}
+
+// isSelfOrigin reports whether the origin is the site itself.
+func isSelfOrigin(origin string) bool {
+	u, err := url.Parse(conf.App.ExternalURL)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(origin, u.Scheme+"://"+u.Host)
+}
+
+// NewEmbed sends the question from the ask widget. The requests are not protected by the CSRF
+// token since the cookies are not sent to the iframe in the third-party sites, so the asker
+// is always treated as anonymous and the session along with the request is ignored.
+func NewEmbed(ctx context.Context, f form.NewQuestion, pageUser *db.User, cpt *captcha.Captcha, cache cache.Cache) error {
+	ctx.User = nil
+	ctx.IsLogged = false
+	ctx.AccessToken = nil
+	ctx.UserSession = nil
+	return NewAPI(ctx, f, pageUser, cpt, cache)
+}
@@ -0,0 +1,154 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package question
+
+import (
+	"encoding/xml"
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/conf"
+	"github.com/NekoWheel/NekoBox/internal/context"
+	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/dbutil"
+	"github.com/NekoWheel/NekoBox/internal/markdown"
+	"github.com/NekoWheel/NekoBox/internal/security/censor"
+)
+
+const feedEntriesCount = 20
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Author  atomAuthor  `xml:"author"`
+	Rights  string      `xml:"rights,omitempty"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+	URI  string `xml:"uri"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+type atomEntry struct {
+	Title     string      `xml:"title"`
+	ID        string      `xml:"id"`
+	Link      atomLink    `xml:"link"`
+	Published string      `xml:"published"`
+	Updated   string      `xml:"updated"`
+	Content   atomContent `xml:"content"`
+}
+
+// Feed renders the Atom feed of the latest answered questions of the box.
+func Feed(ctx context.Context) {
+	domain := ctx.Param("domain")
+
+	pageUser, err := db.Users.GetByDomain(ctx.Request().Context(), domain)
+	if err == nil && pageUser.EffectiveState() == db.UserStateBanned {
+		err = db.ErrUserNotExists
+	}
+	if err != nil {
+		if errors.Is(err, db.ErrUserNotExists) {
+			ctx.ResponseWriter().WriteHeader(http.StatusNotFound)
+			return
+		}
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get user by domain")
+		ctx.ResponseWriter().WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	questions, _, err := db.Questions.GetByUserID(ctx.Request().Context(), pageUser.ID, db.GetQuestionsByUserIDOptions{
+		Cursor:         &dbutil.Cursor{PageSize: feedEntriesCount},
+		FilterAnswered: true,
+	})
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get questions by user ID")
+		ctx.ResponseWriter().WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	censor.MaskQuestions(pageUser, questions...)
+
+	pageURL := fmt.Sprintf("%s/_/%s", conf.App.ExternalURL, pageUser.Domain)
+	feed := atomFeed{
+		Title: fmt.Sprintf("%s的提问箱 - NekoBox", pageUser.Name),
+		ID:    pageURL,
+		Links: []atomLink{
+			{Rel: "self", Type: "application/atom+xml", Href: fmt.Sprintf("%s/u/%s/feed.atom", conf.App.ExternalURL, pageUser.Domain)},
+			{Rel: "alternate", Type: "text/html", Href: pageURL},
+		},
+		Author: atomAuthor{
+			Name: pageUser.Name,
+			URI:  pageURL,
+		},
+	}
+	if license := pageUser.ProfileSettings.License.Info(); license != nil {
+		feed.Rights = strings.TrimSpace(license.Name + " " + license.URL)
+	}
+
+	updated := pageUser.CreatedAt
+	for _, question := range questions {
+		questionURL := fmt.Sprintf("%s/%d", pageURL, question.ID)
+		answeredAt := question.UpdatedAt
+		if question.AnswerUpdatedAt != nil {
+			answeredAt = *question.AnswerUpdatedAt
+		}
+		if answeredAt.After(updated) {
+			updated = answeredAt
+		}
+
+		feed.Entries = append(feed.Entries, atomEntry{
+			Title:     question.Content,
+			ID:        questionURL,
+			Link:      atomLink{Rel: "alternate", Type: "text/html", Href: questionURL},
+			Published: question.CreatedAt.Format(time.RFC3339),
+			Updated:   answeredAt.Format(time.RFC3339),
+			Content: atomContent{
+				Type: "html",
+				Body: fmt.Sprintf("<p><strong>%s</strong></p>%s", feedFormat(question.Content), feedAnswerFormat(pageUser, question.Answer)),
+			},
+		})
+	}
+	feed.Updated = updated.Format(time.RFC3339)
+
+	ctx.ResponseWriter().Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	ctx.ResponseWriter().WriteHeader(http.StatusOK)
+	_, _ = ctx.ResponseWriter().Write([]byte(xml.Header))
+	if err := xml.NewEncoder(ctx.ResponseWriter()).Encode(feed); err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to encode feed")
+	}
+}
+
+func feedFormat(input string) string {
+	return strings.ReplaceAll(html.EscapeString(input), "\n", "<br>")
+}
+
+// feedAnswerFormat returns the HTML of the answer, which is rendered as Markdown if the box enables it.
+func feedAnswerFormat(pageUser *db.User, answer string) string {
+	if pageUser.BoxSettings.EnableMarkdown {
+		return string(markdown.Render(answer))
+	}
+	return "<p>" + feedFormat(answer) + "</p>"
+}
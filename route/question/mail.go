@@ -0,0 +1,173 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package question
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/conf"
+	"github.com/NekoWheel/NekoBox/internal/context"
+	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/mail"
+	"github.com/NekoWheel/NekoBox/internal/push"
+	"github.com/NekoWheel/NekoBox/internal/security/censor"
+	"github.com/NekoWheel/NekoBox/internal/webhook"
+)
+
+// AnswerByMail is the inbound mail webhook, the owner answers the question by replying to the new question mail.
+// The mails which can't be used as the answer are dropped with 200 OK, so the mail service won't retry them.
+func AnswerByMail(ctx context.Context) {
+	if conf.Mail.InboundSecret == "" {
+		ctx.ResponseWriter().WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	inbound, err := mail.ParseInbound(ctx.Request().Request)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Warn("Failed to parse inbound mail")
+		ctx.ResponseWriter().WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if !mail.VerifyInbound(ctx.Request().Request, conf.Mail.InboundSecret) {
+		ctx.ResponseWriter().WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	logger := logrus.WithContext(ctx.Request().Context()).WithField("sender", inbound.Sender)
+
+	// The sender address is the only thing which identifies the answerer, so the forged ones are dropped.
+	if !inbound.SenderVerified {
+		logger.Warn("Inbound mail dropped: sender not verified")
+		ctx.ResponseWriter().WriteHeader(http.StatusOK)
+		return
+	}
+
+	var questionID uint
+	for _, recipient := range inbound.Recipients {
+		if id, ok := mail.ParseReplyAddress(recipient); ok {
+			questionID = id
+			break
+		}
+	}
+	if questionID == 0 {
+		logger.Warn("Inbound mail dropped: no valid reply address")
+		ctx.ResponseWriter().WriteHeader(http.StatusOK)
+		return
+	}
+	logger = logger.WithField("question_id", questionID)
+
+	question, err := db.Questions.GetByID(ctx.Request().Context(), questionID)
+	if err != nil {
+		if !errors.Is(err, db.ErrQuestionNotExist) {
+			logger.WithError(err).Error("Failed to get question by ID")
+			ctx.ResponseWriter().WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		logger.Warn("Inbound mail dropped: question not exist")
+		ctx.ResponseWriter().WriteHeader(http.StatusOK)
+		return
+	}
+
+	pageUser, err := db.Users.GetByID(ctx.Request().Context(), question.UserID)
+	if err != nil {
+		if !errors.Is(err, db.ErrUserNotExists) {
+			logger.WithError(err).Error("Failed to get user by ID")
+			ctx.ResponseWriter().WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		logger.Warn("Inbound mail dropped: user not exist")
+		ctx.ResponseWriter().WriteHeader(http.StatusOK)
+		return
+	}
+
+	// The reply mail may be forwarded, so the sender must be able to answer the question as on the website.
+	sender, err := db.Users.GetByEmail(ctx.Request().Context(), inbound.Sender)
+	if err != nil {
+		if !errors.Is(err, db.ErrUserNotExists) {
+			logger.WithError(err).Error("Failed to get user by email")
+			ctx.ResponseWriter().WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		logger.Warn("Inbound mail dropped: sender not exist")
+		ctx.ResponseWriter().WriteHeader(http.StatusOK)
+		return
+	}
+	if sender.IsRestricted() {
+		logger.Warn("Inbound mail dropped: sender is restricted")
+		ctx.ResponseWriter().WriteHeader(http.StatusOK)
+		return
+	}
+	ctx.User, ctx.IsLogged = sender, true
+	role := boxRole(ctx, pageUser)
+	if !role.CanAnswer() || (question.Answer != "" && !canEditAnswer(ctx, role, question)) {
+		logger.Warn("Inbound mail dropped: sender can't answer the question")
+		ctx.ResponseWriter().WriteHeader(http.StatusOK)
+		return
+	}
+	// Only the unanswered questions are answered by mail, the answers are edited on the website.
+	if question.Answer != "" {
+		logger.Info("Inbound mail dropped: question has been answered")
+		ctx.ResponseWriter().WriteHeader(http.StatusOK)
+		return
+	}
+
+	answer := inbound.Text
+	if answer == "" || pageUser.BoxSettings.CheckAnswerLength(answer) != nil {
+		logger.Warn("Inbound mail dropped: invalid answer length")
+		ctx.ResponseWriter().WriteHeader(http.StatusOK)
+		return
+	}
+
+	// 🚨 Content security check.
+	censorResponse, err := censor.Text(ctx.Request().Context(), answer)
+	if err != nil {
+		logger.WithError(err).Error("Failed to censor text")
+	}
+	if err == nil && !censorResponse.Pass {
+		logger.WithField("reason", censorResponse.ErrorMessage()).Warn("Inbound mail dropped: answer rejected by censor")
+		ctx.ResponseWriter().WriteHeader(http.StatusOK)
+		return
+	}
+
+	if err := db.Questions.AnswerByID(ctx.Request().Context(), question.ID, answer, sender.ID); err != nil {
+		logger.WithError(err).Error("Failed to answer question")
+		ctx.ResponseWriter().WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// Update censor result.
+	if err := db.Questions.UpdateCensor(ctx.Request().Context(), question.ID, db.UpdateQuestionCensorOptions{
+		AnswerCensorMetadata: censorResponse.ToJSON(),
+	}); err != nil {
+		logger.WithError(err).Error("Failed to update answer censor result")
+	}
+
+	if censorResponse == nil {
+		// The censor service is unavailable now, retry it in the background.
+		censor.Enqueue(ctx.Request().Context(), censor.Job{Type: censor.JobTypeQuestionAnswer, ID: question.ID, Text: answer})
+	}
+
+	answeredQuestion := *question
+	answeredQuestion.Answer = answer
+	webhook.Trigger(ctx.Request().Context(), webhook.EventQuestionAnswered, pageUser, &answeredQuestion)
+	federateAnswer(ctx, pageUser, question.ID, true)
+
+	if question.AskerUserID != 0 {
+		push.Notify(ctx.Request().Context(), question.AskerUserID, push.Notification{
+			Title: "你的提问收到了回答",
+			Body:  answer,
+			URL:   fmt.Sprintf("%s/_/%s/%d", conf.App.ExternalURL, pageUser.Domain, question.ID),
+		})
+	}
+
+	notifyAnswerByMail(ctx, pageUser, question, answer)
+	notifyMergedAskers(ctx, pageUser, question.ID, answer)
+
+	logger.Info("Question answered by mail")
+	ctx.ResponseWriter().WriteHeader(http.StatusOK)
+}
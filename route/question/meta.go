@@ -0,0 +1,100 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package question
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/NekoWheel/NekoBox/internal/conf"
+	"github.com/NekoWheel/NekoBox/internal/db"
+)
+
+// maxMetaDescriptionLength is the max rune length of the description meta,
+// most of the link unfurlers cut the description at about this length.
+const maxMetaDescriptionLength = 120
+
+// OpenGraph is the metadata of the page used by the Open Graph and Twitter card meta tags.
+type OpenGraph struct {
+	Title       string
+	Description string
+	URL         string
+	Image       string
+	Type        string
+	SiteName    string
+}
+
+// questionOpenGraph returns the Open Graph metadata of the answered question.
+func questionOpenGraph(pageUser *db.User, question *db.Question) *OpenGraph {
+	return &OpenGraph{
+		Title:       truncateMeta(question.Content),
+		Description: truncateMeta(question.Answer),
+		URL:         questionURL(pageUser, question),
+		Image:       shareCardURL(question),
+		Type:        "article",
+		SiteName:    "NekoBox",
+	}
+}
+
+// questionStructuredData returns the QAPage JSON-LD structured data of the answered question.
+// See https://developers.google.com/search/docs/appearance/structured-data/qapage
+func questionStructuredData(pageUser *db.User, question *db.Question) map[string]interface{} {
+	answer := map[string]interface{}{
+		"@type":       "Answer",
+		"text":        question.Answer,
+		"upvoteCount": question.LikeCount,
+		"url":         questionURL(pageUser, question),
+		"author": map[string]interface{}{
+			"@type": "Person",
+			"name":  pageUser.Name,
+			"url":   fmt.Sprintf("%s/_/%s", conf.App.ExternalURL, pageUser.Domain),
+		},
+	}
+	if question.AnswerUpdatedAt != nil {
+		answer["dateModified"] = question.AnswerUpdatedAt.Format(time.RFC3339)
+	}
+
+	return map[string]interface{}{
+		"@context": "https://schema.org",
+		"@type":    "QAPage",
+		"mainEntity": map[string]interface{}{
+			"@type":       "Question",
+			"name":        question.Content,
+			"text":        question.Content,
+			"answerCount": 1,
+			"dateCreated": question.CreatedAt.Format(time.RFC3339),
+			"author": map[string]interface{}{
+				"@type": "Person",
+				"name":  "匿名提问者",
+			},
+			"acceptedAnswer": answer,
+		},
+	}
+}
+
+// shareCardURL returns the URL of the question's share card, the unlisted question's card
+// is only served with the share token, so the token is carried for the link unfurlers.
+func shareCardURL(question *db.Question) string {
+	cardURL := fmt.Sprintf("%s/q/%d/card.png", conf.App.ExternalURL, question.ID)
+	if question.Visibility == db.QuestionVisibilityUnlisted && question.ShareToken != "" {
+		cardURL += "?s=" + url.QueryEscape(question.ShareToken)
+	}
+	return cardURL
+}
+
+func questionURL(pageUser *db.User, question *db.Question) string {
+	return fmt.Sprintf("%s/_/%s/%d", conf.App.ExternalURL, pageUser.Domain, question.ID)
+}
+
+func truncateMeta(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	runes := []rune(s)
+	if len(runes) <= maxMetaDescriptionLength {
+		return s
+	}
+	return string(runes[:maxMetaDescriptionLength]) + "…"
+}
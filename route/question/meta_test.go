@@ -0,0 +1,99 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package question
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+
+	"github.com/flamego/cache"
+	"github.com/flamego/flamego"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/NekoWheel/NekoBox/internal/conf"
+	nekoctx "github.com/NekoWheel/NekoBox/internal/context"
+	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/db/migrations"
+)
+
+func TestQuestionOpenGraph_UnlistedShareCard(t *testing.T) {
+	database, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "nekobox.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	if err := migrations.Migrate(database); err != nil {
+		t.Fatalf("migrate database: %v", err)
+	}
+	users, questions := db.Users, db.Questions
+	db.Users, db.Questions = db.NewUsersStore(database), db.NewQuestionsStore(database)
+	t.Cleanup(func() { db.Users, db.Questions = users, questions })
+
+	conf.App.ExternalURL = "https://box.example.com"
+	conf.Limits.QuestionMaxLength = 1000
+
+	ctx := context.Background()
+	pageUser := &db.User{Name: "E99p1ant", Email: "i@github.red", Domain: "e99p1ant"}
+	if err := database.Create(pageUser).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	question, err := db.Questions.Create(ctx, db.CreateQuestionOptions{
+		UserID:  pageUser.ID,
+		Content: "Is the share card unfurled?",
+	})
+	if err != nil {
+		t.Fatalf("create question: %v", err)
+	}
+	if err := database.Model(&db.Question{}).Where("id = ?", question.ID).Update("answer", "Yes").Error; err != nil {
+		t.Fatalf("answer question: %v", err)
+	}
+	if err := db.Questions.SetVisibility(ctx, question.ID, db.QuestionVisibilityUnlisted); err != nil {
+		t.Fatalf("set visibility: %v", err)
+	}
+	question, err = db.Questions.GetByID(ctx, question.ID)
+	if err != nil {
+		t.Fatalf("get question: %v", err)
+	}
+
+	f := flamego.New()
+	f.Use(cache.Cacher())
+	f.Use(func(c flamego.Context) { c.Map(nekoctx.Context{Context: c}) })
+	f.Get("/q/{questionID}/card.png", ShareCard)
+
+	fetch := func(imageURL string) *httptest.ResponseRecorder {
+		t.Helper()
+
+		u, err := url.Parse(imageURL)
+		if err != nil {
+			t.Fatalf("parse image URL: %v", err)
+		}
+		resp := httptest.NewRecorder()
+		f.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, u.RequestURI(), nil))
+		return resp
+	}
+
+	image := questionOpenGraph(pageUser, question).Image
+	resp := fetch(image)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("fetch %q: got status %d, want %d", image, resp.Code, http.StatusOK)
+	}
+	if got := resp.Header().Get("Content-Type"); got != "image/png" {
+		t.Fatalf("fetch %q: got content type %q, want %q", image, got, "image/png")
+	}
+
+	// The card of the unlisted question is not served without the share token.
+	resp = fetch(fmt.Sprintf("%s/q/%d/card.png", conf.App.ExternalURL, question.ID))
+	if resp.Code != http.StatusNotFound {
+		t.Fatalf("fetch without share token: got status %d, want %d", resp.Code, http.StatusNotFound)
+	}
+}
@@ -6,24 +6,114 @@ package question
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
-	"github.com/flamego/recaptcha"
+	"github.com/flamego/cache"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"github.com/wuhan005/govalid"
 
+	"github.com/NekoWheel/NekoBox/internal/conf"
 	"github.com/NekoWheel/NekoBox/internal/context"
 	"github.com/NekoWheel/NekoBox/internal/db"
 	"github.com/NekoWheel/NekoBox/internal/dbutil"
 	"github.com/NekoWheel/NekoBox/internal/form"
 	"github.com/NekoWheel/NekoBox/internal/mail"
+	"github.com/NekoWheel/NekoBox/internal/push"
+	"github.com/NekoWheel/NekoBox/internal/security/captcha"
 	"github.com/NekoWheel/NekoBox/internal/security/censor"
+	"github.com/NekoWheel/NekoBox/internal/security/reputation"
+	"github.com/NekoWheel/NekoBox/internal/webhook"
 )
 
+const (
+	// sortHot is the value of the `sort` query to order the questions by the like count.
+	sortHot = "hot"
+	// sortLong is the value of the `sort` query to order the questions by the length of the answers.
+	sortLong = "long"
+)
+
+// errBlocked is the generic rejection for the blocked asker,
+// we don't tell the asker that they have been blocked.
+var errBlocked = errors.New("提问失败，请稍后再试")
+
+// duplicateQuestionWindow is the time window in which the same asker can't send the same question to the box again.
+const duplicateQuestionWindow = 24 * time.Hour
+
+var errDuplicateQuestion = errors.New("你在 24 小时内已经发送过相同的问题了，请耐心等待回答~")
+
+var errWordFiltered = errors.New("问题包含提问箱主人设置的屏蔽词，请修改后再试")
+
+var errSpam = errors.New("问题被识别为垃圾信息，请修改后再试")
+
+var errAskLimit = errors.New("你今天向这个提问箱发送的问题太多了，请明天再来吧~")
+
+var errBoxUnavailable = errors.New("这个提问箱暂时无法接收提问")
+
+var errBoxAway = errors.New("提问箱的主人暂时离开了，离开期间暂停接收提问")
+
+// isShadowBanned returns whether the asker is shadow-banned by the administrators or the box's owner.
+// The questions of the shadow-banned asker are accepted as usual but put into the owner's shadow folder,
+// so the asker doesn't notice it.
+func isShadowBanned(ctx context.Context, pageUser *db.User, askerUserID uint, fromIP string) (bool, error) {
+	if ctx.IsLogged && ctx.User.EffectiveState() == db.UserStateShadowBanned {
+		return true, nil
+	}
+	return db.Blocks.IsShadowBanned(ctx.Request().Context(), pageUser.ID, db.IsBlockedOptions{
+		AskerUserID: askerUserID,
+		AskerIP:     fromIP,
+	})
+}
+
+// askLimitWindow is the time window of the daily ask limit.
+const askLimitWindow = 24 * time.Hour
+
+// exceedsAskLimit returns whether the asker has sent as many questions to the box as the daily ask limit.
+func exceedsAskLimit(ctx context.Context, pageUser *db.User, askerUserID uint, fromIP string) (bool, error) {
+	limit := pageUser.BoxSettings.AskLimit()
+	if limit == 0 {
+		return false, nil
+	}
+
+	count, err := db.Questions.CountRecentByAsker(ctx.Request().Context(), db.CountRecentByAskerOptions{
+		UserID:       pageUser.ID,
+		AskerUserID:  askerUserID,
+		AskerIP:      fromIP,
+		CreatedAfter: time.Now().Add(-askLimitWindow),
+	})
+	if err != nil {
+		return false, err
+	}
+	return count >= int64(limit), nil
+}
+
+// isDuplicateQuestion returns whether the asker has sent the identical or near-identical question to the box recently.
+func isDuplicateQuestion(ctx context.Context, pageUser *db.User, askerUserID uint, fromIP, content string) (bool, error) {
+	_, err := db.Questions.FindRecentDuplicate(ctx.Request().Context(), db.FindRecentDuplicateOptions{
+		UserID:       pageUser.ID,
+		Content:      content,
+		AskerUserID:  askerUserID,
+		AskerIP:      fromIP,
+		CreatedAfter: time.Now().Add(-duplicateQuestionWindow),
+	})
+	if err != nil {
+		if errors.Is(err, db.ErrQuestionNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
 func Pager(ctx context.Context) {
 	domain := ctx.Param("domain")
 
 	pageUser, err := db.Users.GetByDomain(ctx.Request().Context(), domain)
+	if err == nil && pageUser.EffectiveState() == db.UserStateBanned {
+		err = db.ErrUserNotExists
+	}
 	if err != nil {
 		if errors.Is(err, db.ErrUserNotExists) {
 			ctx.Redirect("/")
@@ -37,9 +127,12 @@ func Pager(ctx context.Context) {
 	}
 	ctx.Map(pageUser)
 
-	pageQuestions, err := db.Questions.GetByUserID(ctx.Request().Context(), pageUser.ID, db.GetQuestionsByUserIDOptions{
-		Cursor:         &dbutil.Cursor{},
+	// The total count of the answered questions is returned along with the first page.
+	tag := strings.TrimSpace(ctx.Query("tag"))
+	pageQuestions, pageInfo, err := db.Questions.GetByUserID(ctx.Request().Context(), pageUser.ID, db.GetQuestionsByUserIDOptions{
+		Cursor:         &dbutil.Cursor{WithTotal: true},
 		FilterAnswered: true,
+		FilterTag:      tag,
 	})
 	if err != nil {
 		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get questions by page id")
@@ -47,15 +140,61 @@ func Pager(ctx context.Context) {
 		ctx.Success("question/page")
 		return
 	}
+	answeredCount := pageInfo.Total
 
-	answeredCount, err := db.Questions.Count(ctx.Request().Context(), pageUser.ID, db.GetQuestionsCountOptions{
-		FilterAnswered: true,
-	})
+	searchKeyword := strings.TrimSpace(ctx.Query("q"))
+	isSortHot := ctx.Query("sort") == sortHot
+	isSortLong := ctx.Query("sort") == sortLong
+	if searchKeyword != "" {
+		pageQuestions, pageInfo, err = db.Questions.Search(ctx.Request().Context(), pageUser.ID, searchKeyword, &dbutil.Cursor{})
+		if err != nil {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to search questions")
+			ctx.SetInternalError()
+			ctx.Success("question/page")
+			return
+		}
+	} else if isSortHot {
+		pageQuestions, err = db.Questions.GetHot(ctx.Request().Context(), pageUser.ID)
+		if err != nil {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get hot questions")
+			ctx.SetInternalError()
+			ctx.Success("question/page")
+			return
+		}
+		// The hot questions are not paginated.
+		pageInfo = &dbutil.PageInfo{}
+	} else if isSortLong {
+		pageQuestions, err = db.Questions.GetLongest(ctx.Request().Context(), pageUser.ID)
+		if err != nil {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get longest questions")
+			ctx.SetInternalError()
+			ctx.Success("question/page")
+			return
+		}
+		pageInfo = &dbutil.PageInfo{}
+	}
+
+	loadQuestionTags(ctx, pageQuestions...)
+	censor.MaskQuestions(pageUser, pageQuestions...)
+
+	tags, err := db.QuestionTags.ListByUserID(ctx.Request().Context(), pageUser.ID, db.ListTagsOptions{FilterAnswered: true})
 	if err != nil {
-		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to count questions")
-		ctx.SetInternalError()
-		ctx.Success("question/page")
-		return
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to list tags")
+	}
+
+	prompts, err := db.Prompts.GetByUserID(ctx.Request().Context(), pageUser.ID)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get prompts")
+	}
+
+	// Restore the unsent question of the logged-in asker, it is overwritten by the submitted form if any.
+	if ctx.IsLogged {
+		draft, err := db.QuestionDrafts.Get(ctx.Request().Context(), ctx.User.ID, pageUser.ID)
+		if err == nil {
+			ctx.Data["content"] = draft.Content
+		} else if !errors.Is(err, db.ErrQuestionDraftNotExist) {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get question draft")
+		}
 	}
 
 	ctx.SetTitle(fmt.Sprintf("%s的提问箱 - NekoBox", pageUser.Name))
@@ -64,45 +203,201 @@ func Pager(ctx context.Context) {
 	ctx.Data["PageUser"] = pageUser
 	ctx.Data["PageQuestions"] = pageQuestions
 	ctx.Data["CanAsk"] = ctx.IsLogged || pageUser.HarassmentSetting != db.HarassmentSettingTypeRegisterOnly
+	ctx.Data["CaptchaExempt"] = captchaExempt(ctx)
+	ctx.Data["AskFormToken"] = signAskFormToken(pageUser)
 	ctx.Data["AnsweredCount"] = answeredCount
-	if len(pageQuestions) > 0 {
-		ctx.Data["PageQuestionCursor"] = pageQuestions[len(pageQuestions)-1].ID
+	ctx.Data["SearchKeyword"] = searchKeyword
+	ctx.Data["SortHot"] = isSortHot
+	ctx.Data["SortLong"] = isSortLong
+	ctx.Data["Tag"] = tag
+	ctx.Data["Tags"] = tags
+	ctx.Data["Prompts"] = prompts
+	ctx.Data["Referral"] = questionReferral(ctx, nil)
+	ctx.Data["PageQuestionCursor"] = pageInfo.NextCursor
+	ctx.Data["PageQuestionHasMore"] = pageInfo.HasMore
+}
+
+// captchaExempt returns whether the logged asker is trusted to ask without the captcha,
+// the captcha is required if the reputation can't be evaluated.
+func captchaExempt(ctx context.Context) bool {
+	if !ctx.IsLogged {
+		return false
+	}
+	exempt, err := reputation.CaptchaExempt(ctx.Request().Context(), ctx.User)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to check captcha exemption")
+		return false
+	}
+	return exempt
+}
+
+// loadQuestionTags fills the tags of the questions, the questions are shown without tags if it fails.
+func loadQuestionTags(ctx context.Context, questions ...*db.Question) {
+	questionIDs := make([]uint, 0, len(questions))
+	for _, question := range questions {
+		questionIDs = append(questionIDs, question.ID)
+	}
+
+	tags, err := db.QuestionTags.GetByQuestionIDs(ctx.Request().Context(), questionIDs)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get question tags")
+		return
+	}
+	for _, question := range questions {
+		question.Tags = tags[question.ID]
+	}
+}
+
+// loadAnswerLicense fills the license of the box in the answered questions of the API responses.
+func loadAnswerLicense(pageUser *db.User, questions ...*db.Question) {
+	license := pageUser.ProfileSettings.License.Info()
+	for _, question := range questions {
+		if question.Answer != "" {
+			question.License = license
+		}
+	}
+}
+
+// parsePrompt returns the page user's prompt which the question is asked under,
+// it returns nil for the free question.
+func parsePrompt(ctx context.Context, pageUser *db.User, value string) (*db.Prompt, error) {
+	if value == "" {
+		return nil, nil
 	}
+	id, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return nil, db.ErrPromptNotExist
+	}
+
+	prompt, err := db.Prompts.GetByID(ctx.Request().Context(), uint(id))
+	if err != nil {
+		return nil, err
+	}
+	if prompt.UserID != pageUser.ID {
+		return nil, db.ErrPromptNotExist
+	}
+	return prompt, nil
 }
 
-func List(ctx context.Context) {
+func List(ctx context.Context, pageUser *db.User) {
+	// The achievements are hidden if the owner has opted out of them.
+	if !pageUser.BoxSettings.DisableAchievements {
+		achievements, err := db.Achievements.GetByUserID(ctx.Request().Context(), pageUser.ID)
+		if err != nil {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get achievements")
+		}
+		ctx.Data["Achievements"] = achievements
+	}
+
 	ctx.Success("question/list")
 }
 
-func ListAPI(ctx context.Context) error {
+// SaveDraft autosaves the question draft of the logged-in asker, the empty content deletes the draft.
+func SaveDraft(ctx context.Context, pageUser *db.User, f form.SaveQuestionDraft) error {
+	if ctx.HasError() {
+		return ctx.JSONError(40000, ctx.ErrorMessage())
+	}
+
+	if err := db.QuestionDrafts.Save(ctx.Request().Context(), ctx.User.ID, pageUser.ID, f.Content); err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to save question draft")
+		return ctx.ServerError()
+	}
+	return ctx.JSON(map[string]interface{}{
+		"saved": f.Content != "",
+	})
+}
+
+// PagerAPI injects the page user of the API request.
+func PagerAPI(ctx context.Context) error {
 	domain := ctx.Param("domain")
-	pageSize := ctx.QueryInt("page_size")
-	cursorValue := ctx.Query("cursor")
 
 	pageUser, err := db.Users.GetByDomain(ctx.Request().Context(), domain)
+	if err == nil && pageUser.EffectiveState() == db.UserStateBanned {
+		err = db.ErrUserNotExists
+	}
 	if err != nil {
 		if errors.Is(err, db.ErrUserNotExists) {
 			return ctx.JSONError(40400, "用户不存在")
 		}
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get user by domain")
 		return ctx.ServerError()
 	}
+	ctx.Map(pageUser)
+	return nil
+}
 
-	pageQuestions, err := db.Questions.GetByUserID(ctx.Request().Context(), pageUser.ID, db.GetQuestionsByUserIDOptions{
-		Cursor: &dbutil.Cursor{
-			Value:    cursorValue,
-			PageSize: pageSize,
-		},
-		FilterAnswered: true,
-	})
+func ListAPI(ctx context.Context, pageUser *db.User) error {
+	pageSize := ctx.QueryInt("page_size")
+	cursorValue := ctx.Query("cursor")
+
+	var err error
+	cursor := &dbutil.Cursor{
+		Value:     cursorValue,
+		PageSize:  pageSize,
+		WithTotal: ctx.QueryBool("with_total"),
+	}
+
+	var pageQuestions []*db.Question
+	var pageInfo *dbutil.PageInfo
+	if searchKeyword := strings.TrimSpace(ctx.Query("q")); searchKeyword != "" {
+		pageQuestions, pageInfo, err = db.Questions.Search(ctx.Request().Context(), pageUser.ID, searchKeyword, cursor)
+	} else if ctx.Query("sort") == sortHot {
+		pageQuestions, err = db.Questions.GetHot(ctx.Request().Context(), pageUser.ID)
+		pageInfo = &dbutil.PageInfo{Total: int64(len(pageQuestions))}
+	} else if ctx.Query("sort") == sortLong {
+		pageQuestions, err = db.Questions.GetLongest(ctx.Request().Context(), pageUser.ID)
+		pageInfo = &dbutil.PageInfo{Total: int64(len(pageQuestions))}
+	} else {
+		pageQuestions, pageInfo, err = db.Questions.GetByUserID(ctx.Request().Context(), pageUser.ID, db.GetQuestionsByUserIDOptions{
+			Cursor:         cursor,
+			FilterAnswered: true,
+			FilterTag:      strings.TrimSpace(ctx.Query("tag")),
+		})
+	}
 	if err != nil {
 		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get questions by page id")
 		return ctx.ServerError()
 	}
+	loadQuestionTags(ctx, pageQuestions...)
+	censor.MaskQuestions(pageUser, pageQuestions...)
+	loadAnswerLicense(pageUser, pageQuestions...)
 
-	return ctx.JSON(pageQuestions)
+	return ctx.JSON(map[string]interface{}{
+		"questions":   pageQuestions,
+		"next_cursor": pageInfo.NextCursor,
+		"has_more":    pageInfo.HasMore,
+		"total":       pageInfo.Total,
+	})
+}
+
+// New creates the question sent by the ask form, which is usually confirmed from the preview.
+func New(ctx context.Context, f form.NewQuestion, pageUser *db.User, cpt *captcha.Captcha, cache cache.Cache) {
+	askQuestion(ctx, f, pageUser, cpt, cache, false)
 }
 
-func New(ctx context.Context, f form.NewQuestion, pageUser *db.User, recaptcha recaptcha.RecaptchaV2) {
+// Preview shows how the question will look in the box and the result of the censor,
+// the asker confirms it to send the question.
+func Preview(ctx context.Context, f form.NewQuestion, pageUser *db.User, cpt *captcha.Captcha, cache cache.Cache) {
+	askQuestion(ctx, f, pageUser, cpt, cache, true)
+}
+
+func askQuestion(ctx context.Context, f form.NewQuestion, pageUser *db.User, cpt *captcha.Captcha, cache cache.Cache, preview bool) {
+	// The referral is kept in the form when the page is rendered again for the errors.
+	referral := questionReferral(ctx, &f)
+	ctx.Data["Referral"] = referral
+
+	if pageUser.IsRestricted() {
+		ctx.SetErrorFlash(errBoxUnavailable.Error())
+		ctx.Redirect("/_/" + pageUser.Domain)
+		return
+	}
+
+	if pageUser.AwayStatus.AsksClosed() {
+		ctx.SetErrorFlash(errBoxAway.Error())
+		ctx.Redirect("/_/" + pageUser.Domain)
+		return
+	}
+
 	if !ctx.IsLogged && pageUser.HarassmentSetting == db.HarassmentSettingTypeRegisterOnly {
 		ctx.SetErrorFlash("提问箱的主人设置了仅注册用户才能提问，请先登录。")
 		ctx.Redirect(fmt.Sprintf("/login?to=%s", ctx.Request().Request.RequestURI))
@@ -110,7 +405,7 @@ func New(ctx context.Context, f form.NewQuestion, pageUser *db.User, recaptcha r
 	}
 
 	var receiveReplyEmail string
-	if f.ReceiveReplyViaEmail != "" {
+	if f.ReceiveReplyViaEmail != "" && !pageUser.BoxSettings.HideReplyEmail {
 		// Check the email address is valid.
 		if errs, ok := govalid.Check(struct {
 			Email string `valid:"required;email" label:"邮箱地址"`
@@ -125,18 +420,22 @@ func New(ctx context.Context, f form.NewQuestion, pageUser *db.User, recaptcha r
 		receiveReplyEmail = f.ReceiveReplyEmail
 	}
 
-	// Check recaptcha code.
-	resp, err := recaptcha.Verify(f.Recaptcha, ctx.Request().Request.RemoteAddr)
-	if err != nil {
-		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to check recaptcha")
-		ctx.SetInternalErrorFlash()
-		ctx.Redirect("/_/" + pageUser.Domain)
-		return
-	}
-	if !resp.Success {
-		ctx.SetErrorFlash("验证码错误")
-		ctx.Redirect("/_/" + pageUser.Domain)
-		return
+	// Check captcha code, the trusted logged askers are exempted. The question confirmed
+	// from the preview has passed the captcha already.
+	previewed := !preview && verifyPreviewToken(ctx, cache, pageUser, f.PreviewToken, f.Content, receiveReplyEmail, f.PromptID)
+	if !previewed && !captchaExempt(ctx) {
+		ok, err := cpt.Verify(ctx.Request().Context(), f.Captcha, ctx.RealIP())
+		if err != nil {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to check captcha")
+			ctx.SetInternalErrorFlash()
+			ctx.Redirect("/_/" + pageUser.Domain)
+			return
+		}
+		if !ok {
+			ctx.SetErrorFlash(cpt.FailedMessage())
+			ctx.Redirect("/_/" + pageUser.Domain)
+			return
+		}
 	}
 
 	if ctx.HasError() {
@@ -144,40 +443,37 @@ func New(ctx context.Context, f form.NewQuestion, pageUser *db.User, recaptcha r
 		return
 	}
 
-	content := f.Content
-
-	// 🚨 Content security check.
-	censorResponse, err := censor.Text(ctx.Request().Context(), content)
+	prepared, err := prepareQuestion(ctx, pageUser, f.Content, receiveReplyEmail, f.PromptID, askFormSignals(pageUser, f))
 	if err != nil {
-		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to censor text")
-	}
-	if err == nil && !censorResponse.Pass {
-		errorMessage := censorResponse.ErrorMessage()
-		ctx.SetError(errors.New(errorMessage), f)
+		var askErr *askError
+		if errors.As(err, &askErr) {
+			ctx.SetError(askErr.Err, f)
+		} else {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to prepare question")
+			ctx.SetInternalError(f)
+		}
 		ctx.Success("question/list")
 		return
 	}
 
-	// ⚠️ Here is the aliyun CDN origin IP header.
-	// A security problem may occur if the CDN is enabled and users can modify the header.
-	fromIP := ctx.Request().Header.Get("Ali-CDN-Real-IP")
-	if fromIP == "" {
-		fromIP = ctx.Request().Header.Get("X-Real-IP")
+	if preview {
+		ctx.SetTitle(fmt.Sprintf("预览提问 - %s的提问箱 - NekoBox", pageUser.Name))
+		ctx.Data["Preview"] = prepared
+		ctx.Data["Form"] = f
+		if !prepared.CensorRejected() {
+			ctx.Data["PreviewToken"], _ = signPreviewToken(ctx, cache, pageUser, f.Content, receiveReplyEmail, f.PromptID)
+		}
+		ctx.Success("question/preview")
+		return
 	}
 
-	// Try to get current logged user.
-	var askerUserID uint
-	if ctx.IsLogged {
-		askerUserID = ctx.User.ID
+	if prepared.CensorRejected() {
+		ctx.SetError(errors.New(prepared.CensorResponse.ErrorMessage()), f)
+		ctx.Success("question/list")
+		return
 	}
 
-	question, err := db.Questions.Create(ctx.Request().Context(), db.CreateQuestionOptions{
-		FromIP:            fromIP,
-		UserID:            pageUser.ID,
-		Content:           content,
-		ReceiveReplyEmail: receiveReplyEmail,
-		AskerUserID:       askerUserID,
-	})
+	question, err := createQuestion(ctx, pageUser, prepared, referral)
 	if err != nil {
 		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to create new question")
 		ctx.SetInternalError(f)
@@ -185,22 +481,134 @@ func New(ctx context.Context, f form.NewQuestion, pageUser *db.User, recaptcha r
 		return
 	}
 
-	// Update censor result.
-	if err := db.Questions.UpdateCensor(ctx.Request().Context(), question.ID, db.UpdateQuestionCensorOptions{
-		ContentCensorMetadata: censorResponse.ToJSON(),
-	}); err != nil {
-		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to update question censor result")
+	ctx.SetSuccessFlash("发送问题成功！", fmt.Sprintf("请保存该链接，提问被回答后可以通过它查看回答并追问：%s/_/%s/%d?t=%s", conf.App.ExternalURL, pageUser.Domain, question.ID, question.Token))
+	ctx.Redirect("/_/" + pageUser.Domain)
+}
+
+// NewAPI creates the question, the question previewed by PreviewAPI is sent with its preview token
+// instead of the captcha.
+func NewAPI(ctx context.Context, f form.NewQuestion, pageUser *db.User, cpt *captcha.Captcha, cache cache.Cache) error {
+	return askQuestionAPI(ctx, f, pageUser, cpt, cache, false)
+}
+
+// PreviewAPI returns how the question will look and the result of the censor without creating it,
+// along with the preview token to confirm it by NewAPI.
+func PreviewAPI(ctx context.Context, f form.NewQuestion, pageUser *db.User, cpt *captcha.Captcha, cache cache.Cache) error {
+	return askQuestionAPI(ctx, f, pageUser, cpt, cache, true)
+}
+
+func askQuestionAPI(ctx context.Context, f form.NewQuestion, pageUser *db.User, cpt *captcha.Captcha, cache cache.Cache, preview bool) error {
+	referral := questionReferral(ctx, &f)
+
+	if pageUser.IsRestricted() {
+		return ctx.JSONError(40300, errBoxUnavailable.Error())
+	}
+
+	if pageUser.AwayStatus.AsksClosed() {
+		return ctx.JSONError(40300, errBoxAway.Error())
+	}
+
+	if !ctx.IsLogged && pageUser.HarassmentSetting == db.HarassmentSettingTypeRegisterOnly {
+		return ctx.JSONError(40100, "提问箱的主人设置了仅注册用户才能提问，请先登录。")
+	}
+
+	if ctx.HasError() {
+		return ctx.JSONError(40000, ctx.ErrorMessage())
+	}
+
+	var receiveReplyEmail string
+	if f.ReceiveReplyViaEmail != "" && !pageUser.BoxSettings.HideReplyEmail {
+		// Check the email address is valid.
+		if errs, ok := govalid.Check(struct {
+			Email string `valid:"required;email" label:"邮箱地址"`
+		}{
+			Email: f.ReceiveReplyEmail,
+		}); !ok {
+			return ctx.JSONError(40000, errs[0].Error())
+		}
+
+		receiveReplyEmail = f.ReceiveReplyEmail
+	}
+
+	// Check captcha code, the trusted logged askers are exempted. The question confirmed
+	// from the preview has passed the captcha already.
+	previewed := !preview && verifyPreviewToken(ctx, cache, pageUser, f.PreviewToken, f.Content, receiveReplyEmail, f.PromptID)
+	if !previewed && !captchaExempt(ctx) {
+		ok, err := cpt.Verify(ctx.Request().Context(), f.Captcha, ctx.RealIP())
+		if err != nil {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to check captcha")
+			return ctx.ServerError()
+		}
+		if !ok {
+			return ctx.JSONError(40000, cpt.FailedMessage())
+		}
+	}
+
+	prepared, err := prepareQuestion(ctx, pageUser, f.Content, receiveReplyEmail, f.PromptID, nil)
+	if err != nil {
+		var askErr *askError
+		if errors.As(err, &askErr) {
+			return ctx.JSONError(askErr.Code, ctx.TrError(askErr.Err))
+		}
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to prepare question")
+		return ctx.ServerError()
 	}
 
-	go func() {
-		if pageUser.Notify == db.NotifyTypeEmail {
-			// Send notification to page user.
-			if err := mail.SendNewQuestionMail(pageUser.Email, pageUser.Domain, question.ID, question.Content); err != nil {
-				logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to send new question mail to user")
+	if preview {
+		censorResult := map[string]interface{}{
+			// The pass is null if the censor service is unavailable, the question is censored after it is sent then.
+			"pass":    nil,
+			"message": "",
+			"masked":  prepared.Masked,
+		}
+		if prepared.CensorResponse != nil {
+			censorResult["pass"] = prepared.CensorResponse.Pass
+			if !prepared.CensorResponse.Pass {
+				censorResult["message"] = prepared.CensorResponse.ErrorMessage()
+			}
+		}
+
+		result := map[string]interface{}{
+			"content":             prepared.Content,
+			"display_content":     prepared.DisplayContent(),
+			"prompt":              prepared.Prompt,
+			"receive_reply_email": prepared.ReceiveReplyEmail,
+			"censor":              censorResult,
+		}
+		if !prepared.CensorRejected() {
+			if token, expiresAt := signPreviewToken(ctx, cache, pageUser, f.Content, receiveReplyEmail, f.PromptID); token != "" {
+				result["preview_token"], result["expires_at"] = token, expiresAt
 			}
 		}
-	}()
+		return ctx.JSON(result)
+	}
 
-	ctx.SetSuccessFlash("发送问题成功！")
-	ctx.Redirect("/_/" + pageUser.Domain)
+	if prepared.CensorRejected() {
+		return ctx.JSONError(40000, prepared.CensorResponse.ErrorMessage())
+	}
+
+	question, err := createQuestion(ctx, pageUser, prepared, referral)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to create new question")
+		return ctx.ServerError()
+	}
+	return ctx.JSON(question)
+}
+
+// notifyNewQuestion notifies the page's owner of the new question by the webhooks, the browser pushes and the email.
+func notifyNewQuestion(ctx context.Context, pageUser *db.User, question *db.Question) {
+	webhook.Trigger(ctx.Request().Context(), webhook.EventQuestionCreated, pageUser, question)
+	push.Notify(ctx.Request().Context(), pageUser.ID, push.Notification{
+		Title: "你收到了一个新提问",
+		Body:  question.Content,
+		URL:   fmt.Sprintf("%s/_/%s/%d", conf.App.ExternalURL, pageUser.Domain, question.ID),
+	})
+
+	// The digest subscribers are notified by the cron job in batches.
+	if pageUser.Notify == db.NotifyTypeEmail && pageUser.NotificationPreferences.Frequency() == db.DigestFrequencyInstant {
+		// Send notification to page user.
+		if err := mail.SendNewQuestionMail(ctx.Request().Context(), pageUser.Email, pageUser.Domain, question.ID, question.Content); err != nil {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to send new question mail to user")
+		}
+	}
 }
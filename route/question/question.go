@@ -6,17 +6,56 @@ package question
 
 import (
 	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
 
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 
+	"github.com/NekoWheel/NekoBox/internal/activitypub"
+	"github.com/NekoWheel/NekoBox/internal/conf"
 	"github.com/NekoWheel/NekoBox/internal/context"
+	"github.com/NekoWheel/NekoBox/internal/crosspost"
 	"github.com/NekoWheel/NekoBox/internal/db"
 	"github.com/NekoWheel/NekoBox/internal/form"
 	"github.com/NekoWheel/NekoBox/internal/mail"
+	"github.com/NekoWheel/NekoBox/internal/push"
 	"github.com/NekoWheel/NekoBox/internal/security/censor"
+	"github.com/NekoWheel/NekoBox/internal/security/signature"
+	"github.com/NekoWheel/NekoBox/internal/translate"
+	"github.com/NekoWheel/NekoBox/internal/webhook"
 )
 
+// boxRole returns the role of the logged user in the page user's box, the page user is always the owner.
+// It returns the empty role if the user is not a member of the box.
+func boxRole(ctx context.Context, pageUser *db.User) db.BoxMemberRole {
+	if !ctx.IsLogged {
+		return ""
+	}
+	if ctx.User.ID == pageUser.ID {
+		return db.BoxMemberRoleOwner
+	}
+
+	role, err := db.BoxMembers.GetRole(ctx.Request().Context(), pageUser.ID, ctx.User.ID)
+	if err != nil {
+		if !errors.Is(err, db.ErrBoxMemberNotExist) {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get box member role")
+		}
+		return ""
+	}
+	return role
+}
+
+// canEditAnswer reports whether the role can edit the answer of the question,
+// the answerers can only edit the answers written by themselves.
+func canEditAnswer(ctx context.Context, role db.BoxMemberRole, question *db.Question) bool {
+	if role == db.BoxMemberRoleAnswerer {
+		return question.AnswerUserID == ctx.User.ID
+	}
+	return role.CanAnswer()
+}
+
 func Questioner(ctx context.Context, pageUser *db.User) {
 	questionID := uint(ctx.ParamInt("questionID"))
 	question, err := db.Questions.GetByID(ctx.Request().Context(), questionID)
@@ -30,39 +69,214 @@ func Questioner(ctx context.Context, pageUser *db.User) {
 	}
 	ctx.Data["Question"] = question
 
+	// The asker can be identified by the question's token or the logged user.
+	token := ctx.Query("t")
+	// The members of the team box can see the questions as the owner.
+	role := boxRole(ctx, pageUser)
+	isMember := role.CanAnswer()
+	isAsker := (token == question.Token && question.Token != "") ||
+		(ctx.IsLogged && question.AskerUserID != 0 && ctx.User.ID == question.AskerUserID)
+
 	// Check the question is belongs to the correct page user.
-	// If the question has not been answered, we should check the question is belongs to the correct page user.
-	if question.UserID != pageUser.ID || (question.Answer == "" && (!ctx.IsLogged || ctx.User.ID != question.UserID)) {
+	// If the question has not been answered or is not public, only the page user and the asker can see it,
+	// the unlisted question can also be seen with the share token.
+	// The answer notification mail carries a signed view token, which only grants the permission to view.
+	shareToken := ctx.Query("s")
+	canView := question.Answer != "" &&
+		signature.Verify(signature.PurposeViewQuestion, strconv.FormatUint(uint64(question.ID), 10), ctx.Query("v"))
+	// The administrators can see the hidden questions to review the reports.
+	isAdmin := ctx.IsLogged && ctx.User.IsAdmin
+	if question.UserID != pageUser.ID || (!isMember && !isAsker && !isAdmin && !canView && !question.IsVisible(shareToken)) {
 		ctx.Redirect("/")
 		return
 	}
+	// The members and the administrators see the original content to moderate it.
+	if !isMember && !isAdmin {
+		question.Content = censor.QuestionContent(pageUser, question)
+	}
+	if question.Visibility == db.QuestionVisibilityUnlisted && question.ShareToken != "" &&
+		(isMember || shareToken == question.ShareToken) {
+		ctx.Data["ShareToken"] = question.ShareToken
+		ctx.Data["ShareURL"] = fmt.Sprintf("%s/_/%s/%d?s=%s", conf.App.ExternalURL, pageUser.Domain, question.ID, question.ShareToken)
+	}
 
-	// The page's owner or the question's token can have the permission to delete the question.
+	// The page's owner, the moderators or the question's token can have the permission to delete the question.
 	// Inject the permission into the context.
-	token := ctx.Query("t")
-	canDelete := (ctx.IsLogged && ctx.User.ID == pageUser.ID) || (token == question.Token && question.Token != "")
+	canDelete := role.CanModerate() || (token == question.Token && question.Token != "")
 	ctx.Map(canDelete)
+	ctx.Map(role)
 	ctx.Data["CanDelete"] = canDelete
+	ctx.Data["BoxRole"] = role
+	ctx.Data["CanAnswer"] = role.CanAnswer()
+	ctx.Data["CanEditAnswer"] = canEditAnswer(ctx, role, question)
+	ctx.Data["CanViewRevisions"] = isMember || isAdmin
+	if role.CanAnswer() && question.Answer == "" {
+		accounts, err := db.CrosspostAccounts.GetByUserID(ctx.Request().Context(), ctx.User.ID)
+		if err != nil {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get crosspost accounts by user ID")
+		}
+		ctx.Data["CrosspostAccounts"] = accounts
+	}
+	ctx.Data["TranslationEnabled"] = role.CanAnswer() && translate.Enabled()
+	ctx.Data["CanModerate"] = role.CanModerate()
+	ctx.Data["IsBoxOwner"] = role == db.BoxMemberRoleOwner
+	ctx.Data["IsAsker"] = isAsker
+	ctx.Data["ReportReasons"] = db.ReportReasons
+	if token == question.Token {
+		ctx.Data["QuestionToken"] = token
+	}
+
+	replies, err := db.QuestionReplies.GetByQuestionID(ctx.Request().Context(), question.ID)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get question replies")
+	}
+	ctx.Data["QuestionReplies"] = replies
+
+	hasLiked, err := db.QuestionReactions.HasReacted(ctx.Request().Context(), question.ID, likeReactionOptions(ctx))
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to check question reaction")
+	}
+	ctx.Data["HasLiked"] = hasLiked
+
+	loadQuestionTags(ctx, question)
+
+	// The prompt may have been closed by the owner, the question is shown as a free question then.
+	if question.PromptID != 0 {
+		prompt, err := db.Prompts.GetByID(ctx.Request().Context(), question.PromptID)
+		if err == nil {
+			ctx.Data["Prompt"] = prompt
+		} else if !errors.Is(err, db.ErrPromptNotExist) {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get prompt by ID")
+		}
+	}
+
+	// The answer written by the member of the team box is attributed to the member.
+	if question.AnswerUserID != 0 && question.AnswerUserID != pageUser.ID {
+		answerUser, err := db.Users.GetByID(ctx.Request().Context(), question.AnswerUserID)
+		if err == nil {
+			ctx.Data["AnswerUser"] = answerUser
+		} else if !errors.Is(err, db.ErrUserNotExists) {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get answer user by ID")
+		}
+	}
+
+	// The merged question links to the canonical question, the askers can view its answer with the signed link.
+	// The canonical question may have been deleted, the pointer is not shown then.
+	if question.MergedIntoID != 0 {
+		canonical, err := db.Questions.GetByID(ctx.Request().Context(), question.MergedIntoID)
+		if err == nil {
+			ctx.Data["MergedInto"] = canonical
+			mergedIntoURL := fmt.Sprintf("/_/%s/%d", pageUser.Domain, canonical.ID)
+			if canonical.Answer != "" {
+				mergedIntoURL += "?v=" + signature.Sign(signature.PurposeViewQuestion, strconv.FormatUint(uint64(canonical.ID), 10))
+			}
+			ctx.Data["MergedIntoURL"] = mergedIntoURL
+		} else if !errors.Is(err, db.ErrQuestionNotExist) {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get canonical question by ID")
+		}
+	}
+	if isMember {
+		merged, err := db.Questions.GetMerged(ctx.Request().Context(), question.ID)
+		if err != nil {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get merged questions")
+		}
+		ctx.Data["MergedQuestions"] = merged
+	}
+
+	// The forwarder may have deleted the account, the question is shown as an anonymous forwarded one then.
+	if question.ForwardedFromUserID != 0 {
+		forwardedFrom, err := db.Users.GetByID(ctx.Request().Context(), question.ForwardedFromUserID)
+		if err == nil {
+			ctx.Data["ForwardedFrom"] = forwardedFrom
+		} else if !errors.Is(err, db.ErrUserNotExists) {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get forwarder by ID")
+		}
+	}
 
 	ctx.Map(question)
 }
 
-func Item(ctx context.Context) {
+func Item(ctx context.Context, pageUser *db.User, question *db.Question, role db.BoxMemberRole) {
+	// The question is read once the owner opens it.
+	if ctx.IsLogged && ctx.User.ID == pageUser.ID && question.ReadAt == nil {
+		if err := db.Questions.MarkReadByID(ctx.Request().Context(), question.ID); err != nil {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to mark question as read")
+		}
+	}
+
+	// The asker sees how many questions are ahead of theirs in the queue mode.
+	if question.Answer == "" && pageUser.BoxSettings.QueueMode && !role.CanAnswer() {
+		position, err := db.Questions.GetQueuePosition(ctx.Request().Context(), pageUser.ID, question.ID)
+		if err != nil {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get queue position")
+		} else {
+			ctx.Data["QueuePosition"] = position
+		}
+	}
+
+	// Only the public answered questions are indexed, the unlisted ones are unfurled for the shared links.
+	if question.Visibility != db.QuestionVisibilityPublic || question.HiddenAt != nil {
+		ctx.Data["NoIndex"] = true
+	}
+	if question.Answer != "" && question.Visibility != db.QuestionVisibilityPrivate && question.HiddenAt == nil {
+		ctx.SetTitle(fmt.Sprintf("%s - %s的提问箱 - NekoBox", truncateMeta(question.Content), pageUser.Name))
+		ctx.Data["OpenGraph"] = questionOpenGraph(pageUser, question)
+		ctx.Data["StructuredData"] = questionStructuredData(pageUser, question)
+
+		// The short link of the unlisted question is only shown to the ones holding the share link.
+		if question.Visibility == db.QuestionVisibilityPublic || ctx.Data["ShareToken"] != nil {
+			shortURL, err := shortURL(ctx, question)
+			if err != nil {
+				logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get short URL")
+			} else {
+				ctx.Data["ShortURL"] = shortURL
+			}
+		}
+	}
 	ctx.Success("question/item")
 }
 
-func PublishAnswer(ctx context.Context, pageUser *db.User, question *db.Question, f form.PublishAnswerQuestion) {
+func PublishAnswer(ctx context.Context, pageUser *db.User, question *db.Question, role db.BoxMemberRole, f form.PublishAnswerQuestion) {
 	if ctx.HasError() {
 		ctx.Success("question/item")
 		return
 	}
 
-	if ctx.User.ID != pageUser.ID {
+	// The answered question is answered again with the publish form as well, which is an edit.
+	if !role.CanAnswer() || (question.Answer != "" && !canEditAnswer(ctx, role, question)) {
 		ctx.Redirect("/")
 		return
 	}
 
+	tags := db.ParseTags(f.Tags)
+	if err := db.ValidateTags(tags); err != nil {
+		ctx.SetError(err, f)
+		ctx.Success("question/item")
+		return
+	}
+	visibility := db.QuestionVisibility(f.Visibility)
+	if visibility != "" && !visibility.IsValid() {
+		ctx.SetError(db.ErrInvalidQuestionVisibility, f)
+		ctx.Success("question/item")
+		return
+	}
 	answer := f.Answer
+	if f.AppendTranslation != "" {
+		var err error
+		answer, err = appendAnswerTranslation(ctx, question, answer)
+		if err != nil {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to append answer translation")
+			ctx.SetError(errTranslationUnavailable, f)
+			ctx.Success("question/item")
+			return
+		}
+	}
+
+	if err := pageUser.BoxSettings.CheckAnswerLength(answer); err != nil {
+		ctx.SetError(err, f)
+		ctx.Success("question/item")
+		return
+	}
 
 	// 🚨 Content security check.
 	censorResponse, err := censor.Text(ctx.Request().Context(), answer)
@@ -76,9 +290,13 @@ func PublishAnswer(ctx context.Context, pageUser *db.User, question *db.Question
 		return
 	}
 
-	if err := db.Questions.AnswerByID(ctx.Request().Context(), question.ID, f.Answer); err != nil {
-		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to answer question")
-		ctx.SetInternalError(f)
+	if err := answerQuestion(ctx, question, answer, f.Version); err != nil {
+		if errors.Is(err, db.ErrQuestionVersionConflict) {
+			ctx.SetError(err, f)
+		} else {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to answer question")
+			ctx.SetInternalError(f)
+		}
 		ctx.Success("question/item")
 		return
 	}
@@ -90,19 +308,317 @@ func PublishAnswer(ctx context.Context, pageUser *db.User, question *db.Question
 		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to update answer censor result")
 	}
 
-	go func() {
-		if question.ReceiveReplyEmail != "" && question.Answer == "" { // We only send the email when the question has not been answered.
-			// Send notification to questioner.
-			if err := mail.SendNewAnswerMail(question.ReceiveReplyEmail, pageUser.Domain, question.ID, question.Content, f.Answer); err != nil {
-				logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to send receive reply mail to questioner")
-			}
-		}
-	}()
+	if censorResponse == nil {
+		// The censor service is unavailable now, retry it in the background.
+		censor.Enqueue(ctx.Request().Context(), censor.Job{Type: censor.JobTypeQuestionAnswer, ID: question.ID, Text: answer})
+	}
+
+	if err := db.QuestionTags.SetForQuestion(ctx.Request().Context(), pageUser.ID, question.ID, tags); err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to set question tags")
+	}
+
+	updateVisibility(ctx, question, visibility)
+
+	answeredQuestion := *question
+	answeredQuestion.Answer = answer
+	if visibility != "" {
+		answeredQuestion.Visibility = visibility
+	}
+	webhook.Trigger(ctx.Request().Context(), webhook.EventQuestionAnswered, pageUser, &answeredQuestion)
+	federateAnswer(ctx, pageUser, question.ID, question.Answer == "")
+	crosspostAnswer(ctx, pageUser, question, &answeredQuestion, f)
+
+	if question.AskerUserID != 0 && question.Answer == "" {
+		push.Notify(ctx.Request().Context(), question.AskerUserID, push.Notification{
+			Title: "你的提问收到了回答",
+			Body:  answer,
+			URL:   fmt.Sprintf("%s/_/%s/%d", conf.App.ExternalURL, pageUser.Domain, question.ID),
+		})
+	}
+
+	notifyAnswerByMail(ctx, pageUser, question, answer)
+	if question.Answer == "" {
+		notifyMergedAskers(ctx, pageUser, question.ID, answer)
+	}
 
 	ctx.SetSuccessFlash("回答发布成功！")
 	ctx.Redirect(fmt.Sprintf("/_/%s/%d", pageUser.Domain, question.ID))
 }
 
+// answerQuestion answers the question by the current user, the version of the question is checked
+// if it is given, so the question answered by another tab or client is not overwritten.
+func answerQuestion(ctx context.Context, question *db.Question, answer, version string) error {
+	if version == "" {
+		return db.Questions.AnswerByID(ctx.Request().Context(), question.ID, answer, ctx.User.ID)
+	}
+	// The version has been validated by the form.
+	v, _ := strconv.ParseUint(version, 10, 64)
+	return db.Questions.AnswerByIDIfVersion(ctx.Request().Context(), question.ID, uint(v), answer, ctx.User.ID)
+}
+
+// federateAnswer delivers the answer to the followers of the box on the fediverse, the Note is
+// created for the first answer and updated for the later edits.
+func federateAnswer(ctx context.Context, pageUser *db.User, questionID uint, firstAnswer bool) {
+	if !activitypub.Enabled() {
+		return
+	}
+
+	question, err := db.Questions.GetByID(db.WithPrimary(ctx.Request().Context()), questionID)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get question by ID")
+		return
+	}
+	if firstAnswer {
+		activitypub.PublishAnswer(ctx.Request().Context(), pageUser, question)
+	} else {
+		activitypub.UpdateAnswer(ctx.Request().Context(), pageUser, question)
+	}
+}
+
+// crosspostAnswer posts the first answer to the answerer's social accounts selected on the answer form,
+// only the public answers are cross-posted.
+func crosspostAnswer(ctx context.Context, pageUser *db.User, question, answeredQuestion *db.Question, f form.PublishAnswerQuestion) {
+	if question.Answer != "" || answeredQuestion.Visibility != db.QuestionVisibilityPublic || answeredQuestion.HiddenAt != nil {
+		return
+	}
+	selected := map[db.CrosspostProvider]bool{
+		db.CrosspostProviderTwitter:  f.CrosspostTwitter != "",
+		db.CrosspostProviderMastodon: f.CrosspostMastodon != "",
+	}
+	if !selected[db.CrosspostProviderTwitter] && !selected[db.CrosspostProviderMastodon] {
+		return
+	}
+
+	accounts, err := db.CrosspostAccounts.GetByUserID(ctx.Request().Context(), ctx.User.ID)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get crosspost accounts by user ID")
+		return
+	}
+	selectedAccounts := make([]*db.CrosspostAccount, 0, len(accounts))
+	for _, account := range accounts {
+		if selected[account.Provider] {
+			selectedAccounts = append(selectedAccounts, account)
+		}
+	}
+	crosspost.Enqueue(ctx.Request().Context(), pageUser, answeredQuestion, selectedAccounts)
+}
+
+// notifyAnswerByMail sends the answer to the email address left by the asker,
+// it is only sent for the first answer, the later edits are not notified.
+func notifyAnswerByMail(ctx context.Context, pageUser *db.User, question *db.Question, answer string) {
+	if question.ReceiveReplyEmail == "" || question.Answer != "" {
+		return
+	}
+	if err := mail.SendNewAnswerMail(ctx.Request().Context(), question.ReceiveReplyEmail, pageUser.Domain, question.ID, question.Content, answer); err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to send receive reply mail to questioner")
+	}
+}
+
+// notifyMergedAskers notifies the askers of the questions merged into the answered question,
+// the links point to the answer of the canonical question.
+func notifyMergedAskers(ctx context.Context, pageUser *db.User, canonicalID uint, answer string) {
+	merged, err := db.Questions.GetMerged(ctx.Request().Context(), canonicalID)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get merged questions")
+		return
+	}
+	for _, question := range merged {
+		notifyMergedAsker(ctx, pageUser, question, canonicalID, answer)
+	}
+}
+
+// notifyMergedAsker notifies the asker of the merged question that the canonical question is answered.
+func notifyMergedAsker(ctx context.Context, pageUser *db.User, question *db.Question, canonicalID uint, answer string) {
+	if question.AskerUserID != 0 {
+		push.Notify(ctx.Request().Context(), question.AskerUserID, push.Notification{
+			Title: "你的提问收到了回答",
+			Body:  answer,
+			URL:   fmt.Sprintf("%s/_/%s/%d", conf.App.ExternalURL, pageUser.Domain, question.ID),
+		})
+	}
+	if question.ReceiveReplyEmail != "" {
+		if err := mail.SendNewAnswerMail(ctx.Request().Context(), question.ReceiveReplyEmail, pageUser.Domain, canonicalID, question.Content, answer); err != nil {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to send receive reply mail to merged questioner")
+		}
+	}
+}
+
+// updateVisibility sets the visibility chosen along with the answer, it is kept if not given.
+func updateVisibility(ctx context.Context, question *db.Question, visibility db.QuestionVisibility) {
+	if visibility == "" || visibility == question.Visibility {
+		return
+	}
+	if err := db.Questions.SetVisibility(ctx.Request().Context(), question.ID, visibility); err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to set question visibility")
+	}
+}
+
+// Reply posts a follow-up message to the answered question.
+// Both the asker and the page's owners can reply.
+func Reply(ctx context.Context, pageUser *db.User, question *db.Question, role db.BoxMemberRole, f form.NewQuestionReply) {
+	isOwner := role == db.BoxMemberRoleOwner
+	isAsker, _ := ctx.Data["IsAsker"].(bool)
+	if !isOwner && !isAsker {
+		ctx.Redirect("/_/" + pageUser.Domain)
+		return
+	}
+
+	questionURL := fmt.Sprintf("/_/%s/%d", pageUser.Domain, question.ID)
+	if token, ok := ctx.Data["QuestionToken"].(string); ok && token != "" {
+		questionURL += "?t=" + url.QueryEscape(token)
+	}
+
+	if question.Answer == "" {
+		ctx.SetErrorFlash("提问还没有被回答，暂时不能追问")
+		ctx.Redirect(questionURL)
+		return
+	}
+
+	if !isOwner {
+		var askerUserID uint
+		if ctx.IsLogged {
+			askerUserID = ctx.User.ID
+		}
+		blocked, err := db.Blocks.IsBlocked(ctx.Request().Context(), pageUser.ID, db.IsBlockedOptions{
+			AskerUserID: askerUserID,
+			AskerIP:     ctx.RealIP(),
+		})
+		if err != nil {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to check block")
+			ctx.SetInternalErrorFlash()
+			ctx.Redirect(questionURL)
+			return
+		}
+		if blocked {
+			ctx.SetErrorFlash(errBlocked.Error())
+			ctx.Redirect(questionURL)
+			return
+		}
+	}
+
+	if ctx.HasError() {
+		ctx.Success("question/item")
+		return
+	}
+
+	content := f.Content
+
+	// 🚨 Content security check.
+	censorResponse, err := censor.Text(ctx.Request().Context(), content)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to censor text")
+	}
+	if err == nil && !censorResponse.Pass {
+		errorMessage := censorResponse.ErrorMessage()
+		ctx.SetError(errors.New(errorMessage), f)
+		ctx.Success("question/item")
+		return
+	}
+
+	reply, err := db.QuestionReplies.Create(ctx.Request().Context(), db.CreateQuestionReplyOptions{
+		QuestionID: question.ID,
+		FromIP:     ctx.RealIP(),
+		IsOwner:    isOwner,
+		Content:    content,
+	})
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to create question reply")
+		ctx.SetInternalError(f)
+		ctx.Success("question/item")
+		return
+	}
+
+	// Update censor result.
+	if err := db.QuestionReplies.UpdateCensor(ctx.Request().Context(), reply.ID, censorResponse.ToJSON()); err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to update question reply censor result")
+	}
+
+	if censorResponse == nil {
+		// The censor service is unavailable now, retry it in the background.
+		censor.Enqueue(ctx.Request().Context(), censor.Job{Type: censor.JobTypeQuestionReply, ID: reply.ID, Text: content})
+	}
+
+	ctx.SetSuccessFlash("追问发送成功！")
+	ctx.Redirect(questionURL)
+}
+
+func UpdateAnswer(ctx context.Context, pageUser *db.User, question *db.Question, role db.BoxMemberRole, f form.UpdateAnswerQuestion) {
+	if ctx.HasError() {
+		ctx.Success("question/item")
+		return
+	}
+
+	if !canEditAnswer(ctx, role, question) {
+		ctx.Redirect("/")
+		return
+	}
+
+	tags := db.ParseTags(f.Tags)
+	if err := db.ValidateTags(tags); err != nil {
+		ctx.SetError(err, f)
+		ctx.Success("question/item")
+		return
+	}
+	visibility := db.QuestionVisibility(f.Visibility)
+	if visibility != "" && !visibility.IsValid() {
+		ctx.SetError(db.ErrInvalidQuestionVisibility, f)
+		ctx.Success("question/item")
+		return
+	}
+	if err := pageUser.BoxSettings.CheckAnswerLength(f.Answer); err != nil {
+		ctx.SetError(err, f)
+		ctx.Success("question/item")
+		return
+	}
+
+	answer := f.Answer
+
+	// 🚨 Content security check.
+	censorResponse, err := censor.Text(ctx.Request().Context(), answer)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to censor text")
+	}
+	if err == nil && !censorResponse.Pass {
+		errorMessage := censorResponse.ErrorMessage()
+		ctx.SetError(errors.New(errorMessage), f)
+		ctx.Success("question/item")
+		return
+	}
+
+	if err := db.Questions.UpdateAnswerByID(ctx.Request().Context(), question.ID, answer, ctx.User.ID); err != nil {
+		if errors.Is(err, db.ErrQuestionNotAnswered) {
+			ctx.SetError(errors.Cause(err), f)
+		} else {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to update answer")
+			ctx.SetInternalError(f)
+		}
+		ctx.Success("question/item")
+		return
+	}
+
+	// Update censor result.
+	if err := db.Questions.UpdateCensor(ctx.Request().Context(), question.ID, db.UpdateQuestionCensorOptions{
+		AnswerCensorMetadata: censorResponse.ToJSON(),
+	}); err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to update answer censor result")
+	}
+
+	if censorResponse == nil {
+		// The censor service is unavailable now, retry it in the background.
+		censor.Enqueue(ctx.Request().Context(), censor.Job{Type: censor.JobTypeQuestionAnswer, ID: question.ID, Text: answer})
+	}
+
+	if err := db.QuestionTags.SetForQuestion(ctx.Request().Context(), pageUser.ID, question.ID, tags); err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to set question tags")
+	}
+
+	updateVisibility(ctx, question, visibility)
+	federateAnswer(ctx, pageUser, question.ID, false)
+
+	ctx.SetSuccessFlash("回答更新成功！")
+	ctx.Redirect(fmt.Sprintf("/_/%s/%d", pageUser.Domain, question.ID))
+}
+
 func Delete(ctx context.Context, pageUser *db.User, question *db.Question, canDelete bool) {
 	if !canDelete {
 		ctx.Redirect("/_/" + pageUser.Domain)
@@ -116,5 +632,591 @@ func Delete(ctx context.Context, pageUser *db.User, question *db.Question, canDe
 		return
 	}
 
+	auditQuestionDelete(ctx, pageUser, question)
+	webhook.Trigger(ctx.Request().Context(), webhook.EventQuestionDeleted, pageUser, question)
+	activitypub.DeleteAnswer(ctx.Request().Context(), pageUser, question)
+
 	ctx.Redirect("/_/" + pageUser.Domain)
 }
+
+// auditQuestionDelete records the deletion of the question, the content is kept
+// since the question may be purged from the trash later.
+func auditQuestionDelete(ctx context.Context, pageUser *db.User, question *db.Question) {
+	ctx.Audit(db.CreateAuditLogOptions{
+		Action:     db.AuditActionQuestionDelete,
+		TargetType: db.AuditTargetQuestion,
+		TargetID:   question.ID,
+		Metadata: map[string]interface{}{
+			"user_id": pageUser.ID,
+			"content": question.Content,
+		},
+	})
+}
+
+func Pin(ctx context.Context, pageUser *db.User, question *db.Question, role db.BoxMemberRole) {
+	if !role.CanModerate() {
+		ctx.Redirect("/")
+		return
+	}
+
+	if err := db.Questions.PinByID(ctx.Request().Context(), question.ID); err != nil {
+		if errors.Is(err, db.ErrQuestionNotAnswered) || errors.Is(err, db.ErrTooManyPinnedQuestions) {
+			ctx.SetErrorFlash(ctx.TrError(err))
+		} else {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to pin question")
+			ctx.SetInternalErrorFlash()
+		}
+		ctx.Redirect(fmt.Sprintf("/_/%s/%d", pageUser.Domain, question.ID))
+		return
+	}
+
+	ctx.SetSuccessFlash("置顶提问成功！")
+	ctx.Redirect(fmt.Sprintf("/_/%s/%d", pageUser.Domain, question.ID))
+}
+
+// Block blocks the asker of the question, the blocked asker can't ask the page's owner any more.
+func Block(ctx context.Context, pageUser *db.User, question *db.Question, role db.BoxMemberRole) {
+	blockAsker(ctx, pageUser, question, role, db.BlockModeReject)
+}
+
+// ShadowBan shadow-bans the asker of the question, the later questions of the asker
+// are put into the shadow folder without the asker noticing it.
+func ShadowBan(ctx context.Context, pageUser *db.User, question *db.Question, role db.BoxMemberRole) {
+	blockAsker(ctx, pageUser, question, role, db.BlockModeShadow)
+}
+
+func blockAsker(ctx context.Context, pageUser *db.User, question *db.Question, role db.BoxMemberRole, mode db.BlockMode) {
+	if !role.CanModerate() {
+		ctx.Redirect("/")
+		return
+	}
+
+	if err := db.Blocks.Create(ctx.Request().Context(), db.CreateBlockOptions{
+		UserID:      pageUser.ID,
+		AskerUserID: question.AskerUserID,
+		AskerIP:     question.FromIP,
+		Question:    question,
+		Mode:        mode,
+	}); err != nil {
+		if errors.Is(err, db.ErrBlockExists) || errors.Is(err, db.ErrBlockNoSource) {
+			ctx.SetErrorFlash(ctx.TrError(err))
+		} else {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to block asker")
+			ctx.SetInternalErrorFlash()
+		}
+		ctx.Redirect(fmt.Sprintf("/_/%s/%d", pageUser.Domain, question.ID))
+		return
+	}
+
+	if mode == db.BlockModeShadow {
+		ctx.SetSuccessFlash("静默屏蔽提问者成功！该提问者之后的提问会被放入静默箱，你可以在屏蔽列表中取消屏蔽。")
+	} else {
+		ctx.SetSuccessFlash("屏蔽提问者成功！你可以在屏蔽列表中取消屏蔽。")
+	}
+	ctx.Redirect(fmt.Sprintf("/_/%s/%d", pageUser.Domain, question.ID))
+}
+
+// Forward creates a copy of the question in another user's box, which is linked to the original question.
+// The page's owner is shown as the forwarder unless the question is forwarded anonymously.
+func Forward(ctx context.Context, pageUser *db.User, question *db.Question, role db.BoxMemberRole, f form.ForwardQuestion) {
+	if role != db.BoxMemberRoleOwner {
+		ctx.Redirect("/")
+		return
+	}
+
+	redirectTo := fmt.Sprintf("/_/%s/%d", pageUser.Domain, question.ID)
+	if ctx.HasError() {
+		ctx.SetErrorFlash(ctx.ErrorMessage())
+		ctx.Redirect(redirectTo)
+		return
+	}
+
+	targetUser, err := db.Users.GetByDomain(ctx.Request().Context(), strings.TrimSpace(f.Domain))
+	if err != nil {
+		if errors.Is(err, db.ErrUserNotExists) {
+			ctx.SetErrorFlash("目标提问箱不存在")
+		} else {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get user by domain")
+			ctx.SetInternalErrorFlash()
+		}
+		ctx.Redirect(redirectTo)
+		return
+	}
+	if targetUser.ID == pageUser.ID {
+		ctx.SetErrorFlash("不能转发到自己的提问箱")
+		ctx.Redirect(redirectTo)
+		return
+	}
+	if targetUser.IsRestricted() {
+		ctx.SetErrorFlash(errBoxUnavailable.Error())
+		ctx.Redirect(redirectTo)
+		return
+	}
+	if targetUser.AwayStatus.AsksClosed() {
+		ctx.SetErrorFlash(errBoxAway.Error())
+		ctx.Redirect(redirectTo)
+		return
+	}
+
+	// The forwarder is treated as the asker of the target box.
+	blocked, err := db.Blocks.IsBlocked(ctx.Request().Context(), targetUser.ID, db.IsBlockedOptions{
+		AskerUserID: pageUser.ID,
+	})
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to check block")
+		ctx.SetInternalErrorFlash()
+		ctx.Redirect(redirectTo)
+		return
+	}
+	if blocked {
+		ctx.SetErrorFlash("转发失败，请稍后再试")
+		ctx.Redirect(redirectTo)
+		return
+	}
+
+	// 🚨 Content security check.
+	censorResponse, err := censor.Text(ctx.Request().Context(), question.Content)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to censor text")
+	}
+	if err == nil && !censorResponse.Pass {
+		ctx.SetErrorFlash(censorResponse.ErrorMessage())
+		ctx.Redirect(redirectTo)
+		return
+	}
+
+	// The asker's identity and email are not forwarded, since the asker only asked the page's owner.
+	var forwardedFromUserID uint
+	if f.Anonymous == "" {
+		forwardedFromUserID = pageUser.ID
+	}
+	forwarded, err := db.Questions.Create(ctx.Request().Context(), db.CreateQuestionOptions{
+		UserID:                  targetUser.ID,
+		Content:                 question.Content,
+		ForwardedFromQuestionID: question.ID,
+		ForwardedFromUserID:     forwardedFromUserID,
+	})
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to create forwarded question")
+		ctx.SetInternalErrorFlash()
+		ctx.Redirect(redirectTo)
+		return
+	}
+
+	// Update censor result.
+	if err := db.Questions.UpdateCensor(ctx.Request().Context(), forwarded.ID, db.UpdateQuestionCensorOptions{
+		ContentCensorMetadata: censorResponse.ToJSON(),
+	}); err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to update question censor result")
+	}
+
+	if censorResponse == nil {
+		// The censor service is unavailable now, retry it in the background.
+		censor.Enqueue(ctx.Request().Context(), censor.Job{Type: censor.JobTypeQuestionContent, ID: forwarded.ID, Text: forwarded.Content})
+	}
+
+	notifyNewQuestion(ctx, targetUser, forwarded)
+
+	ctx.SetSuccessFlash(fmt.Sprintf("已将提问转发给@%s！", targetUser.Name))
+	ctx.Redirect(redirectTo)
+}
+
+func Unpin(ctx context.Context, pageUser *db.User, question *db.Question, role db.BoxMemberRole) {
+	if !role.CanModerate() {
+		ctx.Redirect("/")
+		return
+	}
+
+	if err := db.Questions.UnpinByID(ctx.Request().Context(), question.ID); err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to unpin question")
+		ctx.SetInternalErrorFlash()
+		ctx.Redirect(fmt.Sprintf("/_/%s/%d", pageUser.Domain, question.ID))
+		return
+	}
+
+	ctx.SetSuccessFlash("取消置顶成功！")
+	ctx.Redirect(fmt.Sprintf("/_/%s/%d", pageUser.Domain, question.ID))
+}
+
+// parseQuestionTarget returns the ID of the question given by its ID or its link, such as
+// "https://example.com/_/domain/123?t=token".
+func parseQuestionTarget(target string) (uint, bool) {
+	target = strings.TrimSpace(target)
+	if i := strings.IndexAny(target, "?#"); i >= 0 {
+		target = target[:i]
+	}
+	target = strings.TrimSuffix(target, "/")
+	if i := strings.LastIndex(target, "/"); i >= 0 {
+		target = target[i+1:]
+	}
+	id, err := strconv.ParseUint(target, 10, 64)
+	if err != nil || id == 0 {
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// Merge merges the question into another question of the box which asks the same thing. The merged
+// question links to the canonical question, and its asker is notified once the canonical question
+// is answered, or right away if it has been answered already.
+func Merge(ctx context.Context, pageUser *db.User, question *db.Question, role db.BoxMemberRole, f form.MergeQuestion) {
+	if !role.CanModerate() {
+		ctx.Redirect("/")
+		return
+	}
+
+	redirectTo := fmt.Sprintf("/_/%s/%d", pageUser.Domain, question.ID)
+	if ctx.HasError() {
+		ctx.SetErrorFlash(ctx.ErrorMessage())
+		ctx.Redirect(redirectTo)
+		return
+	}
+	canonicalID, ok := parseQuestionTarget(f.Target)
+	if !ok {
+		ctx.SetErrorFlash("请填写目标提问的链接或 ID")
+		ctx.Redirect(redirectTo)
+		return
+	}
+
+	canonical, err := db.Questions.Merge(ctx.Request().Context(), pageUser.ID, question.ID, canonicalID)
+	if err != nil {
+		if errors.Is(err, db.ErrQuestionNotExist) ||
+			errors.Is(err, db.ErrQuestionMergeSelf) ||
+			errors.Is(err, db.ErrQuestionMerged) ||
+			errors.Is(err, db.ErrQuestionMergeAnswered) {
+			ctx.SetErrorFlash(ctx.TrError(err))
+		} else {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to merge question")
+			ctx.SetInternalErrorFlash()
+		}
+		ctx.Redirect(redirectTo)
+		return
+	}
+
+	ctx.Audit(db.CreateAuditLogOptions{
+		Action:     db.AuditActionQuestionMerge,
+		TargetType: db.AuditTargetQuestion,
+		TargetID:   question.ID,
+		Metadata: map[string]interface{}{
+			"user_id":      pageUser.ID,
+			"canonical_id": canonical.ID,
+		},
+	})
+
+	if canonical.Answer != "" {
+		notifyMergedAsker(ctx, pageUser, question, canonical.ID, canonical.Answer)
+	}
+
+	ctx.SetSuccessFlash("合并提问成功！提问者会在该提问被回答时收到通知。")
+	ctx.Redirect(fmt.Sprintf("/_/%s/%d", pageUser.Domain, canonical.ID))
+}
+
+// UpdateNote saves the private note of the question, which is only shown to the box's owner and the members.
+func UpdateNote(ctx context.Context, pageUser *db.User, question *db.Question, role db.BoxMemberRole, f form.UpdateQuestionNote) {
+	if !role.CanAnswer() {
+		ctx.Redirect("/")
+		return
+	}
+
+	redirectTo := fmt.Sprintf("/_/%s/%d", pageUser.Domain, question.ID)
+	if ctx.HasError() {
+		ctx.SetErrorFlash(ctx.ErrorMessage())
+		ctx.Redirect(redirectTo)
+		return
+	}
+
+	if err := db.Questions.UpdatePrivateNote(ctx.Request().Context(), question.ID, strings.TrimSpace(f.Note)); err != nil {
+		if errors.Is(err, db.ErrPrivateNoteTooLong) {
+			ctx.SetErrorFlash(ctx.TrError(err))
+		} else {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to update private note")
+			ctx.SetInternalErrorFlash()
+		}
+		ctx.Redirect(redirectTo)
+		return
+	}
+
+	ctx.SetSuccessFlash("保存备注成功！")
+	ctx.Redirect(redirectTo)
+}
+
+func likeReactionOptions(ctx context.Context) db.HasReactedOptions {
+	opts := db.HasReactedOptions{
+		Type: db.ReactionTypeLike,
+		IP:   ctx.RealIP(),
+	}
+	if ctx.IsLogged {
+		opts.UserID = ctx.User.ID
+	}
+	return opts
+}
+
+func createLikeReaction(ctx context.Context, question *db.Question) error {
+	if question.Answer == "" {
+		return db.ErrQuestionNotAnswered
+	}
+
+	opts := likeReactionOptions(ctx)
+	return db.QuestionReactions.Create(ctx.Request().Context(), db.CreateQuestionReactionOptions{
+		QuestionID: question.ID,
+		Type:       opts.Type,
+		UserID:     opts.UserID,
+		IP:         opts.IP,
+	})
+}
+
+// Like adds the visitor's like to the answered question.
+func Like(ctx context.Context, pageUser *db.User, question *db.Question) {
+	redirectTo := fmt.Sprintf("/_/%s/%d", pageUser.Domain, question.ID)
+	if shareToken := ctx.Query("s"); shareToken != "" {
+		redirectTo += "?s=" + url.QueryEscape(shareToken)
+	}
+
+	if err := createLikeReaction(ctx, question); err != nil {
+		if errors.Is(err, db.ErrQuestionNotAnswered) || errors.Is(err, db.ErrReactionExists) || errors.Is(err, db.ErrReactionNoSource) {
+			ctx.SetErrorFlash(ctx.TrError(err))
+		} else {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to like question")
+			ctx.SetInternalErrorFlash()
+		}
+		ctx.Redirect(redirectTo)
+		return
+	}
+
+	ctx.SetSuccessFlash("点赞成功！")
+	ctx.Redirect(redirectTo)
+}
+
+// QuestionerAPI injects the question of the API request and the permission to delete it.
+func QuestionerAPI(ctx context.Context, pageUser *db.User) error {
+	questionID := uint(ctx.ParamInt("questionID"))
+	question, err := db.Questions.GetByID(ctx.Request().Context(), questionID)
+	if err != nil {
+		if errors.Is(err, db.ErrQuestionNotExist) {
+			return ctx.JSONError(40400, ctx.TrError(err))
+		}
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get question by ID")
+		return ctx.ServerError()
+	}
+
+	// Only the page's owner and the members can access the unanswered or non-public question,
+	// the unlisted question can also be accessed with the share token.
+	role := boxRole(ctx, pageUser)
+	if question.UserID != pageUser.ID || (!role.CanAnswer() && !question.IsVisible(ctx.Query("s"))) {
+		return ctx.JSONError(40400, ctx.TrError(db.ErrQuestionNotExist))
+	}
+
+	token := ctx.Query("t")
+	canDelete := role.CanModerate() || (token == question.Token && question.Token != "")
+	ctx.Map(canDelete)
+	ctx.Map(role)
+
+	loadQuestionTags(ctx, question)
+
+	ctx.Map(question)
+	return nil
+}
+
+func ItemAPI(ctx context.Context, pageUser *db.User, question *db.Question) error {
+	loadAnswerLicense(pageUser, question)
+	return ctx.JSON(question)
+}
+
+func LikeAPI(ctx context.Context, question *db.Question) error {
+	if err := createLikeReaction(ctx, question); err != nil {
+		switch {
+		case errors.Is(err, db.ErrQuestionNotAnswered), errors.Is(err, db.ErrReactionNoSource):
+			return ctx.JSONError(40000, ctx.TrError(err))
+		case errors.Is(err, db.ErrReactionExists):
+			return ctx.JSONError(40900, ctx.TrError(err))
+		}
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to like question")
+		return ctx.ServerError()
+	}
+
+	question, err := db.Questions.GetByID(db.WithPrimary(ctx.Request().Context()), question.ID)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get question by ID")
+		return ctx.ServerError()
+	}
+	return ctx.JSON(question)
+}
+
+func PublishAnswerAPI(ctx context.Context, pageUser *db.User, question *db.Question, role db.BoxMemberRole, f form.PublishAnswerQuestion) error {
+	if !role.CanAnswer() || (question.Answer != "" && !canEditAnswer(ctx, role, question)) {
+		return ctx.JSONError(40300, "无权回答该提问")
+	}
+
+	if ctx.HasError() {
+		return ctx.JSONError(40000, ctx.ErrorMessage())
+	}
+
+	tags := db.ParseTags(f.Tags)
+	if err := db.ValidateTags(tags); err != nil {
+		return ctx.JSONError(40000, ctx.TrError(err))
+	}
+	visibility := db.QuestionVisibility(f.Visibility)
+	if visibility != "" && !visibility.IsValid() {
+		return ctx.JSONError(40000, ctx.TrError(db.ErrInvalidQuestionVisibility))
+	}
+	if err := pageUser.BoxSettings.CheckAnswerLength(f.Answer); err != nil {
+		return ctx.JSONError(40000, ctx.TrError(err))
+	}
+
+	answer := f.Answer
+
+	// 🚨 Content security check.
+	censorResponse, err := censor.Text(ctx.Request().Context(), answer)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to censor text")
+	}
+	if err == nil && !censorResponse.Pass {
+		return ctx.JSONError(40000, censorResponse.ErrorMessage())
+	}
+
+	if err := answerQuestion(ctx, question, answer, f.Version); err != nil {
+		if errors.Is(err, db.ErrQuestionVersionConflict) {
+			return ctx.JSONError(40900, ctx.TrError(err))
+		}
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to answer question")
+		return ctx.ServerError()
+	}
+
+	// Update censor result.
+	if err := db.Questions.UpdateCensor(ctx.Request().Context(), question.ID, db.UpdateQuestionCensorOptions{
+		AnswerCensorMetadata: censorResponse.ToJSON(),
+	}); err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to update answer censor result")
+	}
+
+	if censorResponse == nil {
+		// The censor service is unavailable now, retry it in the background.
+		censor.Enqueue(ctx.Request().Context(), censor.Job{Type: censor.JobTypeQuestionAnswer, ID: question.ID, Text: answer})
+	}
+
+	// The tags are kept if they are not given, so that the API clients without tags don't clear them.
+	if len(tags) > 0 {
+		if err := db.QuestionTags.SetForQuestion(ctx.Request().Context(), pageUser.ID, question.ID, tags); err != nil {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to set question tags")
+		}
+	}
+
+	if question.AskerUserID != 0 && question.Answer == "" {
+		push.Notify(ctx.Request().Context(), question.AskerUserID, push.Notification{
+			Title: "你的提问收到了回答",
+			Body:  answer,
+			URL:   fmt.Sprintf("%s/_/%s/%d", conf.App.ExternalURL, pageUser.Domain, question.ID),
+		})
+	}
+
+	notifyAnswerByMail(ctx, pageUser, question, answer)
+	if question.Answer == "" {
+		notifyMergedAskers(ctx, pageUser, question.ID, answer)
+	}
+
+	updateVisibility(ctx, question, visibility)
+
+	answeredQuestion, err := db.Questions.GetByID(db.WithPrimary(ctx.Request().Context()), question.ID)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get question by ID")
+		return ctx.ServerError()
+	}
+	loadQuestionTags(ctx, answeredQuestion)
+	webhook.Trigger(ctx.Request().Context(), webhook.EventQuestionAnswered, pageUser, answeredQuestion)
+	if question.Answer == "" {
+		activitypub.PublishAnswer(ctx.Request().Context(), pageUser, answeredQuestion)
+	} else {
+		activitypub.UpdateAnswer(ctx.Request().Context(), pageUser, answeredQuestion)
+	}
+	crosspostAnswer(ctx, pageUser, question, answeredQuestion, f)
+
+	return ctx.JSON(answeredQuestion)
+}
+
+func UpdateAnswerAPI(ctx context.Context, pageUser *db.User, question *db.Question, role db.BoxMemberRole, f form.UpdateAnswerQuestion) error {
+	if !canEditAnswer(ctx, role, question) {
+		return ctx.JSONError(40300, "无权回答该提问")
+	}
+
+	if ctx.HasError() {
+		return ctx.JSONError(40000, ctx.ErrorMessage())
+	}
+
+	tags := db.ParseTags(f.Tags)
+	if err := db.ValidateTags(tags); err != nil {
+		return ctx.JSONError(40000, ctx.TrError(err))
+	}
+	visibility := db.QuestionVisibility(f.Visibility)
+	if visibility != "" && !visibility.IsValid() {
+		return ctx.JSONError(40000, ctx.TrError(db.ErrInvalidQuestionVisibility))
+	}
+	if err := pageUser.BoxSettings.CheckAnswerLength(f.Answer); err != nil {
+		return ctx.JSONError(40000, ctx.TrError(err))
+	}
+
+	answer := f.Answer
+
+	// 🚨 Content security check.
+	censorResponse, err := censor.Text(ctx.Request().Context(), answer)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to censor text")
+	}
+	if err == nil && !censorResponse.Pass {
+		return ctx.JSONError(40000, censorResponse.ErrorMessage())
+	}
+
+	if err := db.Questions.UpdateAnswerByID(ctx.Request().Context(), question.ID, answer, ctx.User.ID); err != nil {
+		if errors.Is(err, db.ErrQuestionNotAnswered) {
+			return ctx.JSONError(40000, ctx.TrError(err))
+		}
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to update answer")
+		return ctx.ServerError()
+	}
+
+	// Update censor result.
+	if err := db.Questions.UpdateCensor(ctx.Request().Context(), question.ID, db.UpdateQuestionCensorOptions{
+		AnswerCensorMetadata: censorResponse.ToJSON(),
+	}); err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to update answer censor result")
+	}
+
+	if censorResponse == nil {
+		// The censor service is unavailable now, retry it in the background.
+		censor.Enqueue(ctx.Request().Context(), censor.Job{Type: censor.JobTypeQuestionAnswer, ID: question.ID, Text: answer})
+	}
+
+	// The tags are kept if they are not given, so that the API clients without tags don't clear them.
+	if len(tags) > 0 {
+		if err := db.QuestionTags.SetForQuestion(ctx.Request().Context(), pageUser.ID, question.ID, tags); err != nil {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to set question tags")
+		}
+	}
+
+	updateVisibility(ctx, question, visibility)
+
+	updatedQuestion, err := db.Questions.GetByID(db.WithPrimary(ctx.Request().Context()), question.ID)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get question by ID")
+		return ctx.ServerError()
+	}
+	loadQuestionTags(ctx, updatedQuestion)
+	activitypub.UpdateAnswer(ctx.Request().Context(), pageUser, updatedQuestion)
+	return ctx.JSON(updatedQuestion)
+}
+
+func DeleteAPI(ctx context.Context, pageUser *db.User, question *db.Question, canDelete bool) error {
+	if !canDelete {
+		return ctx.JSONError(40300, "无权删除该提问")
+	}
+
+	if err := db.Questions.DeleteByID(ctx.Request().Context(), question.ID); err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to delete question")
+		return ctx.ServerError()
+	}
+
+	auditQuestionDelete(ctx, pageUser, question)
+	webhook.Trigger(ctx.Request().Context(), webhook.EventQuestionDeleted, pageUser, question)
+	activitypub.DeleteAnswer(ctx.Request().Context(), pageUser, question)
+
+	return ctx.JSON(nil)
+}
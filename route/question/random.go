@@ -0,0 +1,74 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package question
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/conf"
+	"github.com/NekoWheel/NekoBox/internal/context"
+	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/security/censor"
+)
+
+// Random redirects to a random public answered question of the box, the box page is shown
+// if there is no answered question yet.
+func Random(ctx context.Context) {
+	domain := ctx.Param("domain")
+
+	pageUser, err := db.Users.GetByDomain(ctx.Request().Context(), domain)
+	if err == nil && pageUser.EffectiveState() == db.UserStateBanned {
+		err = db.ErrUserNotExists
+	}
+	if err != nil {
+		if !errors.Is(err, db.ErrUserNotExists) {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get user by domain")
+		}
+		ctx.Redirect("/")
+		return
+	}
+
+	// Every visit picks another question.
+	ctx.ResponseWriter().Header().Set("Cache-Control", "no-store")
+
+	question, err := db.Questions.GetRandomAnswered(ctx.Request().Context(), pageUser.ID)
+	if err != nil {
+		if !errors.Is(err, db.ErrQuestionNotExist) {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get random answered question")
+		}
+		ctx.Redirect("/_/" + pageUser.Domain)
+		return
+	}
+	ctx.Redirect(fmt.Sprintf("/_/%s/%d", pageUser.Domain, question.ID))
+}
+
+// RandomEmbed returns a random public answered question of the box for the allowed sites,
+// so the owners can showcase a rotating answer on their own pages.
+func RandomEmbed(ctx context.Context, pageUser *db.User) error {
+	ctx.ResponseWriter().Header().Set("Cache-Control", "no-store")
+
+	question, err := db.Questions.GetRandomAnswered(ctx.Request().Context(), pageUser.ID)
+	if err != nil {
+		if errors.Is(err, db.ErrQuestionNotExist) {
+			return ctx.JSONError(40400, "还没有公开的回答")
+		}
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get random answered question")
+		return ctx.ServerError()
+	}
+	censor.MaskQuestions(pageUser, question)
+
+	return ctx.JSON(map[string]interface{}{
+		"id":          question.ID,
+		"content":     question.Content,
+		"answer":      question.Answer,
+		"created_at":  question.CreatedAt,
+		"answered_at": question.AnsweredAt,
+		"url":         fmt.Sprintf("%s/_/%s/%d", conf.App.ExternalURL, pageUser.Domain, question.ID),
+		"license":     pageUser.ProfileSettings.License.Info(),
+	})
+}
@@ -0,0 +1,71 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package question
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/NekoWheel/NekoBox/internal/conf"
+	"github.com/NekoWheel/NekoBox/internal/context"
+	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/form"
+)
+
+// maxReferralLength is the max length of each referral field, the longer values are truncated.
+const maxReferralLength = 64
+
+// questionReferral returns where the asker comes from. The referral of the box page view is passed
+// through the hidden fields of the ask form, the query and the referrer of the request are used
+// if the form has none of them, e.g. the API clients.
+func questionReferral(ctx context.Context, f *form.NewQuestion) *db.QuestionReferral {
+	var referral db.QuestionReferral
+	if f != nil {
+		referral = db.QuestionReferral{
+			Ref:         normalizeReferral(f.Ref),
+			UTMSource:   normalizeReferral(f.UTMSource),
+			UTMMedium:   normalizeReferral(f.UTMMedium),
+			UTMCampaign: normalizeReferral(f.UTMCampaign),
+			Referrer:    normalizeReferral(f.Referrer),
+		}
+	}
+	if referral != (db.QuestionReferral{}) {
+		return &referral
+	}
+
+	return &db.QuestionReferral{
+		Ref:         normalizeReferral(ctx.Query("ref")),
+		UTMSource:   normalizeReferral(ctx.Query("utm_source")),
+		UTMMedium:   normalizeReferral(ctx.Query("utm_medium")),
+		UTMCampaign: normalizeReferral(ctx.Query("utm_campaign")),
+		Referrer:    referrerHost(ctx),
+	}
+}
+
+// referrerHost returns the host of the external page which links to the box,
+// the pages of the site itself are not referrers.
+func referrerHost(ctx context.Context) string {
+	referrer, err := url.Parse(ctx.Request().Referer())
+	if err != nil {
+		return ""
+	}
+	host := strings.TrimPrefix(strings.ToLower(referrer.Hostname()), "www.")
+	if host == "" || host == strings.ToLower(ctx.Request().Host) {
+		return ""
+	}
+	if externalURL, err := url.Parse(conf.App.ExternalURL); err == nil && strings.EqualFold(host, externalURL.Hostname()) {
+		return ""
+	}
+	return normalizeReferral(host)
+}
+
+// normalizeReferral lowercases and truncates the referral value, so the same channel is counted together.
+func normalizeReferral(value string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if runes := []rune(value); len(runes) > maxReferralLength {
+		value = string(runes[:maxReferralLength])
+	}
+	return value
+}
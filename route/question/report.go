@@ -0,0 +1,80 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package question
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/conf"
+	"github.com/NekoWheel/NekoBox/internal/context"
+	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/form"
+)
+
+// Report reports the answered question to the administrators. The question is hidden
+// once it has enough pending reports, until an administrator reviews it.
+func Report(ctx context.Context, pageUser *db.User, question *db.Question, f form.ReportQuestion) {
+	redirectTo := fmt.Sprintf("/_/%s/%d", pageUser.Domain, question.ID)
+	if shareToken := ctx.Query("s"); shareToken != "" {
+		redirectTo += "?s=" + url.QueryEscape(shareToken)
+	}
+
+	if ctx.HasError() {
+		ctx.SetErrorFlash(ctx.ErrorMessage())
+		ctx.Redirect(redirectTo)
+		return
+	}
+
+	if ctx.IsLogged && ctx.User.ID == pageUser.ID {
+		ctx.SetErrorFlash("不能举报自己提问箱中的提问")
+		ctx.Redirect(redirectTo)
+		return
+	}
+	if question.Answer == "" {
+		ctx.SetErrorFlash(ctx.TrError(db.ErrQuestionNotAnswered))
+		ctx.Redirect(redirectTo)
+		return
+	}
+
+	opts := db.CreateReportOptions{
+		QuestionID:     question.ID,
+		QuestionUserID: pageUser.ID,
+		ReporterIP:     ctx.RealIP(),
+		Reason:         db.ReportReason(f.Reason),
+		Detail:         strings.TrimSpace(f.Detail),
+	}
+	if ctx.IsLogged {
+		opts.ReporterUserID = ctx.User.ID
+	}
+	if err := db.Reports.Create(ctx.Request().Context(), opts); err != nil {
+		if errors.Is(err, db.ErrReportExists) || errors.Is(err, db.ErrReportNoSource) || errors.Is(err, db.ErrInvalidReportReason) {
+			ctx.SetErrorFlash(ctx.TrError(err))
+		} else {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to create report")
+			ctx.SetInternalErrorFlash()
+		}
+		ctx.Redirect(redirectTo)
+		return
+	}
+
+	if threshold := conf.Security.ReportHideThreshold; threshold > 0 && question.HiddenAt == nil {
+		count, err := db.Reports.CountPendingByQuestionID(ctx.Request().Context(), question.ID)
+		if err != nil {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to count pending reports")
+		} else if count >= int64(threshold) {
+			if err := db.Questions.SetHidden(ctx.Request().Context(), question.ID, true); err != nil {
+				logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to hide reported question")
+			}
+		}
+	}
+
+	ctx.SetSuccessFlash("举报成功，感谢你的反馈，管理员会尽快处理。")
+	ctx.Redirect(redirectTo)
+}
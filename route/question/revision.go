@@ -0,0 +1,135 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package question
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/context"
+	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/security/censor"
+	"github.com/NekoWheel/NekoBox/internal/textdiff"
+)
+
+// answerRevision is the previous answer shown with the changes of the next version.
+type answerRevision struct {
+	*db.AnswerRevision
+	// Editor is nil if the editor has deleted the account.
+	Editor *db.User
+	Diff   []textdiff.Span
+}
+
+// AnswerRevisions lists the previous answers of the question, every revision is compared with the version
+// which replaced it. Only the members of the box and the administrators can see the revisions.
+func AnswerRevisions(ctx context.Context, pageUser *db.User, question *db.Question, role db.BoxMemberRole) {
+	questionURL := fmt.Sprintf("/_/%s/%d", pageUser.Domain, question.ID)
+	if !role.CanAnswer() && !ctx.User.IsAdmin {
+		ctx.Redirect(questionURL)
+		return
+	}
+	ctx.SetTitle(fmt.Sprintf("回答的历史版本 - %s的提问箱 - NekoBox", pageUser.Name))
+
+	revisions, err := db.AnswerRevisions.GetByQuestionID(ctx.Request().Context(), question.ID)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get answer revisions")
+		ctx.SetInternalError()
+		ctx.Success("question/revisions")
+		return
+	}
+
+	editors := make(map[uint]*db.User)
+	views := make([]*answerRevision, 0, len(revisions))
+	newer := question.Answer
+	for _, revision := range revisions {
+		editor, ok := editors[revision.EditorUserID]
+		if !ok {
+			editor, err = db.Users.GetByID(ctx.Request().Context(), revision.EditorUserID)
+			if err != nil && !errors.Is(err, db.ErrUserNotExists) {
+				logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get answer editor by ID")
+			}
+			editors[revision.EditorUserID] = editor
+		}
+
+		views = append(views, &answerRevision{
+			AnswerRevision: revision,
+			Editor:         editor,
+			Diff:           textdiff.Diff(revision.Answer, newer),
+		})
+		newer = revision.Answer
+	}
+	ctx.Data["AnswerRevisions"] = views
+	ctx.Data["QuestionURL"] = questionURL
+
+	ctx.Success("question/revisions")
+}
+
+// RestoreAnswerRevision brings the previous answer back by the administrator, the answer is attributed to
+// the editor of the revision, and the replaced answer is kept as a new revision.
+func RestoreAnswerRevision(ctx context.Context, pageUser *db.User, question *db.Question) {
+	revisionsURL := fmt.Sprintf("/_/%s/%d/revisions", pageUser.Domain, question.ID)
+
+	revisionID := uint(ctx.ParamInt("revisionID"))
+	revision, err := db.AnswerRevisions.GetByID(ctx.Request().Context(), question.ID, revisionID)
+	if err != nil {
+		if errors.Is(err, db.ErrAnswerRevisionNotExist) {
+			ctx.SetErrorFlash(ctx.TrError(err))
+		} else {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get answer revision")
+			ctx.SetInternalErrorFlash()
+		}
+		ctx.Redirect(revisionsURL)
+		return
+	}
+
+	// 🚨 Content security check, the revision may have been replaced because of the content.
+	censorResponse, err := censor.Text(ctx.Request().Context(), revision.Answer)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to censor text")
+	}
+	if err == nil && !censorResponse.Pass {
+		ctx.SetErrorFlash(censorResponse.ErrorMessage())
+		ctx.Redirect(revisionsURL)
+		return
+	}
+
+	if err := db.Questions.UpdateAnswerByID(ctx.Request().Context(), question.ID, revision.Answer, revision.EditorUserID); err != nil {
+		if errors.Is(err, db.ErrQuestionNotAnswered) || errors.Is(err, db.ErrAnswerTooLong) {
+			ctx.SetErrorFlash(ctx.TrError(errors.Cause(err)))
+		} else {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to restore answer revision")
+			ctx.SetInternalErrorFlash()
+		}
+		ctx.Redirect(revisionsURL)
+		return
+	}
+
+	// Update censor result.
+	if err := db.Questions.UpdateCensor(ctx.Request().Context(), question.ID, db.UpdateQuestionCensorOptions{
+		AnswerCensorMetadata: censorResponse.ToJSON(),
+	}); err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to update answer censor result")
+	}
+
+	if censorResponse == nil {
+		// The censor service is unavailable now, retry it in the background.
+		censor.Enqueue(ctx.Request().Context(), censor.Job{Type: censor.JobTypeQuestionAnswer, ID: question.ID, Text: revision.Answer})
+	}
+
+	ctx.Audit(db.CreateAuditLogOptions{
+		Action:     db.AuditActionAnswerRestore,
+		TargetType: db.AuditTargetQuestion,
+		TargetID:   question.ID,
+		Metadata: map[string]interface{}{
+			"revision_id": revision.ID,
+		},
+	})
+	federateAnswer(ctx, pageUser, question.ID, false)
+
+	ctx.SetSuccessFlash("回答已恢复到该历史版本！")
+	ctx.Redirect(revisionsURL)
+}
@@ -0,0 +1,120 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package question
+
+import (
+	"bytes"
+	"fmt"
+	"image/png"
+	"net/http"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/qr"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/conf"
+	"github.com/NekoWheel/NekoBox/internal/context"
+	"github.com/NekoWheel/NekoBox/internal/db"
+)
+
+// qrCodeSize is the width and height of the QR code image in pixels.
+const qrCodeSize = 256
+
+// shortURL returns the short link of the question, the slug is generated on the first call.
+func shortURL(ctx context.Context, question *db.Question) (string, error) {
+	slug, err := db.Questions.EnsureShortSlug(ctx.Request().Context(), question.ID)
+	if err != nil {
+		return "", errors.Wrap(err, "ensure short slug")
+	}
+	return conf.App.ExternalURL + "/s/" + slug, nil
+}
+
+// ShortLink redirects the short link to the question page.
+func ShortLink(ctx context.Context) {
+	question, err := db.Questions.GetByShortSlug(ctx.Request().Context(), ctx.Param("slug"))
+	if err != nil {
+		if !errors.Is(err, db.ErrQuestionNotExist) {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get question by short slug")
+			ctx.ResponseWriter().WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		ctx.ResponseWriter().WriteHeader(http.StatusNotFound)
+		return
+	}
+	// The short link stands for the share link of the unlisted question.
+	if !question.IsVisible(question.ShareToken) {
+		ctx.ResponseWriter().WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	pageUser, err := db.Users.GetByID(ctx.Request().Context(), question.UserID)
+	if err != nil {
+		if !errors.Is(err, db.ErrUserNotExists) {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get user by ID")
+			ctx.ResponseWriter().WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		ctx.ResponseWriter().WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	location := fmt.Sprintf("/_/%s/%d", pageUser.Domain, question.ID)
+	if question.Visibility == db.QuestionVisibilityUnlisted {
+		location += "?s=" + question.ShareToken
+	}
+	ctx.Redirect(location)
+}
+
+// QRCode serves the PNG QR code of the question's short link.
+func QRCode(ctx context.Context) {
+	questionID := uint(ctx.ParamInt("questionID"))
+	question, err := db.Questions.GetByID(ctx.Request().Context(), questionID)
+	if err != nil {
+		if !errors.Is(err, db.ErrQuestionNotExist) {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get question by ID")
+			ctx.ResponseWriter().WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		ctx.ResponseWriter().WriteHeader(http.StatusNotFound)
+		return
+	}
+	if !question.IsVisible(ctx.Query("s")) {
+		ctx.ResponseWriter().WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	url, err := shortURL(ctx, question)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get short URL")
+		ctx.ResponseWriter().WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	code, err := qr.Encode(url, qr.M, qr.Auto)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to encode QR code")
+		ctx.ResponseWriter().WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	code, err = barcode.Scale(code, qrCodeSize, qrCodeSize)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to scale QR code")
+		ctx.ResponseWriter().WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, code); err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to encode PNG")
+		ctx.ResponseWriter().WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// The short link never changes, so the QR code can be cached for a long time.
+	ctx.ResponseWriter().Header().Set("Content-Type", "image/png")
+	ctx.ResponseWriter().Header().Set("Cache-Control", "public, max-age=86400")
+	_, _ = ctx.ResponseWriter().Write(buf.Bytes())
+}
@@ -0,0 +1,66 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package question
+
+import (
+	"net/http"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/context"
+	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/translate"
+)
+
+var errTranslationUnavailable = errors.New("翻译服务暂时不可用，请稍后再试")
+
+// Translation returns the machine translation of the question into the language of the answerer,
+// which is shown inline on the question page.
+func Translation(ctx context.Context, question *db.Question, role db.BoxMemberRole) error {
+	if !role.CanAnswer() {
+		return ctx.JSONError(40300, "没有权限翻译该提问")
+	}
+	if !translate.Enabled() {
+		return ctx.JSONError(40400, "翻译功能未开启")
+	}
+
+	targetLang := ctx.Locale.Lang()
+	translation, err := translate.Question(ctx.Request().Context(), question, targetLang)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to translate question")
+		return ctx.JSONError(http.StatusServiceUnavailable*100, errTranslationUnavailable.Error())
+	}
+	return ctx.JSON(map[string]interface{}{
+		"text":        translation.Text,
+		"source_lang": translation.SourceLang,
+		// The question in the answerer's language is not translated at all.
+		"same_language": translate.SameLanguage(translation.SourceLang, targetLang),
+	})
+}
+
+// appendAnswerTranslation appends the translation of the answer into the question's language, so the asker
+// can read the answer written in another language. The answer is returned as is if they are in the same language.
+func appendAnswerTranslation(ctx context.Context, question *db.Question, answer string) (string, error) {
+	if !translate.Enabled() {
+		return answer, nil
+	}
+
+	// The question's language is detected by translating it, which is cached for the inline translation as well.
+	questionTranslation, err := translate.Question(ctx.Request().Context(), question, ctx.Locale.Lang())
+	if err != nil {
+		return "", errors.Wrap(err, "translate question")
+	}
+	sourceLang := questionTranslation.SourceLang
+	if sourceLang == "" || translate.SameLanguage(sourceLang, ctx.Locale.Lang()) {
+		return answer, nil
+	}
+
+	answerTranslation, err := translate.Text(ctx.Request().Context(), answer, sourceLang)
+	if err != nil {
+		return "", errors.Wrap(err, "translate answer")
+	}
+	return answer + "\n\n---\n\n" + answerTranslation.Text, nil
+}
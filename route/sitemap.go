@@ -0,0 +1,38 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package route
+
+import (
+	"net/http"
+	"os"
+
+	"github.com/NekoWheel/NekoBox/internal/context"
+	"github.com/NekoWheel/NekoBox/internal/sitemap"
+)
+
+func Sitemap(ctx context.Context) {
+	serveSitemap(ctx, sitemap.IndexFile)
+}
+
+func SitemapFile(ctx context.Context) {
+	serveSitemap(ctx, ctx.Param("name"))
+}
+
+func serveSitemap(ctx context.Context, name string) {
+	path, ok := sitemap.Path(name)
+	if !ok {
+		ctx.ResponseWriter().WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	// The sitemap has not been generated yet.
+	if _, err := os.Stat(path); err != nil {
+		ctx.ResponseWriter().WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	ctx.ResponseWriter().Header().Set("Content-Type", "application/xml; charset=utf-8")
+	http.ServeFile(ctx.ResponseWriter(), ctx.Request().Request, path)
+}
@@ -0,0 +1,54 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package route
+
+import (
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/context"
+	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/security/signature"
+)
+
+// checkUnsubscribeLink returns the email address of the unsubscribe link in the notification mail.
+func checkUnsubscribeLink(ctx context.Context) (string, bool) {
+	email := ctx.Query("email")
+	if email == "" || !signature.Verify(signature.PurposeUnsubscribe, email, ctx.Query("sig")) {
+		ctx.SetErrorFlash("退订链接无效")
+		ctx.Redirect("/")
+		return "", false
+	}
+	return email, true
+}
+
+// Unsubscribe asks to confirm the unsubscription, so the link is not followed by the mail scanners.
+func Unsubscribe(ctx context.Context) {
+	email, ok := checkUnsubscribeLink(ctx)
+	if !ok {
+		return
+	}
+
+	ctx.Data["Email"] = email
+	ctx.Success("unsubscribe")
+}
+
+func UnsubscribeAction(ctx context.Context) {
+	email, ok := checkUnsubscribeLink(ctx)
+	if !ok {
+		return
+	}
+
+	ctx.Data["Email"] = email
+	if err := db.EmailSuppressions.Create(ctx.Request().Context(), email, db.EmailSuppressionReasonUnsubscribed); err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to create email suppression")
+		ctx.SetInternalError()
+		ctx.Success("unsubscribe")
+		return
+	}
+
+	ctx.Data["Unsubscribed"] = true
+	ctx.Data["Success"] = "退订成功，" + email + " 将不会再收到提问的回答通知。"
+	ctx.Success("unsubscribe")
+}
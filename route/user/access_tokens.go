@@ -0,0 +1,99 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package user
+
+import (
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/context"
+	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/form"
+)
+
+func AccessTokens(ctx context.Context) {
+	accessTokens, err := db.AccessTokens.GetByUserID(ctx.Request().Context(), ctx.User.ID)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get access tokens by user ID")
+		ctx.Redirect("/")
+		return
+	}
+	ctx.Data["AccessTokens"] = accessTokens
+
+	ctx.Success("user/access-tokens")
+}
+
+// NewAccessToken creates the access token and shows it on the page, it can't be seen again afterwards.
+func NewAccessToken(ctx context.Context, f form.NewAccessToken) {
+	if ctx.HasError() {
+		AccessTokens(ctx)
+		return
+	}
+
+	var scopes []db.AccessTokenScope
+	if f.ReadQuestions != "" {
+		scopes = append(scopes, db.AccessTokenScopeReadQuestions)
+	}
+	if f.WriteAnswers != "" {
+		scopes = append(scopes, db.AccessTokenScopeWriteAnswers)
+	}
+
+	accessToken, token, err := db.AccessTokens.Create(ctx.Request().Context(), db.CreateAccessTokenOptions{
+		UserID: ctx.User.ID,
+		Name:   f.Name,
+		Scopes: scopes,
+	})
+	if err != nil {
+		if errors.Is(err, db.ErrTooManyAccessTokens) || errors.Is(err, db.ErrInvalidAccessTokenScope) {
+			ctx.SetErrorFlash(ctx.TrError(err))
+		} else {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to create access token")
+			ctx.SetInternalErrorFlash()
+		}
+		ctx.Redirect("/user/access-tokens")
+		return
+	}
+
+	ctx.Audit(db.CreateAuditLogOptions{
+		Action:     db.AuditActionAccessTokenCreate,
+		TargetType: db.AuditTargetUser,
+		TargetID:   ctx.User.ID,
+		Metadata: map[string]interface{}{
+			"access_token_id": accessToken.ID,
+			"name":            accessToken.Name,
+			"scopes":          accessToken.Scopes,
+		},
+	})
+
+	ctx.Data["NewAccessToken"] = token
+	ctx.Data["Success"] = "访问令牌创建成功，请立即复制保存，离开页面后将无法再次查看。"
+	AccessTokens(ctx)
+}
+
+func DeleteAccessToken(ctx context.Context) {
+	accessTokenID := uint(ctx.ParamInt("accessTokenID"))
+	if err := db.AccessTokens.DeleteByID(ctx.Request().Context(), ctx.User.ID, accessTokenID); err != nil {
+		if errors.Is(err, db.ErrAccessTokenNotExist) {
+			ctx.SetErrorFlash(ctx.TrError(err))
+		} else {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to delete access token")
+			ctx.SetInternalErrorFlash()
+		}
+		ctx.Redirect("/user/access-tokens")
+		return
+	}
+
+	ctx.Audit(db.CreateAuditLogOptions{
+		Action:     db.AuditActionAccessTokenDelete,
+		TargetType: db.AuditTargetUser,
+		TargetID:   ctx.User.ID,
+		Metadata: map[string]interface{}{
+			"access_token_id": accessTokenID,
+		},
+	})
+
+	ctx.SetSuccessFlash("访问令牌已撤销！")
+	ctx.Redirect("/user/access-tokens")
+}
@@ -0,0 +1,112 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package user
+
+import (
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/context"
+	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/form"
+)
+
+func AnswerTemplates(ctx context.Context) {
+	answerTemplates, err := db.AnswerTemplates.GetByUserID(ctx.Request().Context(), ctx.User.ID)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get answer templates by user ID")
+		ctx.Redirect("/")
+		return
+	}
+	ctx.Data["AnswerTemplates"] = answerTemplates
+	ctx.Data["MaxAnswerTemplates"] = db.MaxAnswerTemplatesPerUser
+
+	ctx.Success("user/answer-templates")
+}
+
+// AnswerTemplatesAPI returns the answer templates of the user, which are inserted into the answer form.
+func AnswerTemplatesAPI(ctx context.Context) error {
+	answerTemplates, err := db.AnswerTemplates.GetByUserID(ctx.Request().Context(), ctx.User.ID)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get answer templates by user ID")
+		return ctx.ServerError()
+	}
+	return ctx.JSON(map[string]interface{}{
+		"answer_templates": answerTemplates,
+	})
+}
+
+func NewAnswerTemplate(ctx context.Context, f form.AnswerTemplate) {
+	if ctx.HasError() {
+		AnswerTemplates(ctx)
+		return
+	}
+
+	if _, err := db.AnswerTemplates.Create(ctx.Request().Context(), db.CreateAnswerTemplateOptions{
+		UserID:  ctx.User.ID,
+		Title:   f.Title,
+		Content: f.Content,
+	}); err != nil {
+		switch {
+		case errors.Is(err, db.ErrTooManyAnswerTemplates),
+			errors.Is(err, db.ErrInvalidAnswerTemplate),
+			errors.Is(err, db.ErrAnswerTooLong):
+			ctx.SetErrorFlash(ctx.TrError(err))
+		default:
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to create answer template")
+			ctx.SetInternalErrorFlash()
+		}
+		ctx.Redirect("/user/answer-templates")
+		return
+	}
+
+	ctx.SetSuccessFlash("添加回答模板成功！")
+	ctx.Redirect("/user/answer-templates")
+}
+
+func UpdateAnswerTemplate(ctx context.Context, f form.AnswerTemplate) {
+	if ctx.HasError() {
+		AnswerTemplates(ctx)
+		return
+	}
+
+	answerTemplateID := uint(ctx.ParamInt("answerTemplateID"))
+	if err := db.AnswerTemplates.Update(ctx.Request().Context(), ctx.User.ID, answerTemplateID, db.UpdateAnswerTemplateOptions{
+		Title:   f.Title,
+		Content: f.Content,
+	}); err != nil {
+		switch {
+		case errors.Is(err, db.ErrAnswerTemplateNotExist),
+			errors.Is(err, db.ErrInvalidAnswerTemplate),
+			errors.Is(err, db.ErrAnswerTooLong):
+			ctx.SetErrorFlash(ctx.TrError(err))
+		default:
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to update answer template")
+			ctx.SetInternalErrorFlash()
+		}
+		ctx.Redirect("/user/answer-templates")
+		return
+	}
+
+	ctx.SetSuccessFlash("修改回答模板成功！")
+	ctx.Redirect("/user/answer-templates")
+}
+
+func DeleteAnswerTemplate(ctx context.Context) {
+	answerTemplateID := uint(ctx.ParamInt("answerTemplateID"))
+	if err := db.AnswerTemplates.DeleteByID(ctx.Request().Context(), ctx.User.ID, answerTemplateID); err != nil {
+		if errors.Is(err, db.ErrAnswerTemplateNotExist) {
+			ctx.SetErrorFlash(ctx.TrError(err))
+		} else {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to delete answer template")
+			ctx.SetInternalErrorFlash()
+		}
+		ctx.Redirect("/user/answer-templates")
+		return
+	}
+
+	ctx.SetSuccessFlash("删除回答模板成功！")
+	ctx.Redirect("/user/answer-templates")
+}
@@ -0,0 +1,61 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package user
+
+import (
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/context"
+	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/form"
+)
+
+// UpdateAwayStatus sets the notice of the owner being away, which is shown on the box page.
+func UpdateAwayStatus(ctx context.Context, f form.UpdateAwayStatus) {
+	if ctx.HasError() {
+		ctx.SetErrorFlash(ctx.ErrorMessage())
+		ctx.Redirect("/user/profile")
+		return
+	}
+
+	status := db.AwayStatus{
+		Enabled:    f.Away != "",
+		Message:    strings.TrimSpace(f.Message),
+		DisableAsk: f.DisableAsk != "",
+	}
+	// The return date is only kept while the owner is away, the owner is back at the start of the day.
+	if status.Enabled && f.ReturnDate != "" {
+		until, err := time.ParseInLocation("2006-01-02", f.ReturnDate, time.Local)
+		if err != nil {
+			ctx.SetErrorFlash("返回日期格式不正确")
+			ctx.Redirect("/user/profile")
+			return
+		}
+		status.Until = &until
+	}
+
+	if err := status.Validate(); err != nil {
+		ctx.SetErrorFlash(ctx.TrError(err))
+		ctx.Redirect("/user/profile")
+		return
+	}
+
+	if err := db.Users.UpdateAwayStatus(ctx.Request().Context(), ctx.User.ID, status); err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to update away status")
+		ctx.SetInternalErrorFlash()
+		ctx.Redirect("/user/profile")
+		return
+	}
+
+	if status.Enabled {
+		ctx.SetSuccessFlash("已开启离开状态")
+	} else {
+		ctx.SetSuccessFlash("已关闭离开状态")
+	}
+	ctx.Redirect("/user/profile")
+}
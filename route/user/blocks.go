@@ -0,0 +1,42 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package user
+
+import (
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/context"
+	"github.com/NekoWheel/NekoBox/internal/db"
+)
+
+func Blocks(ctx context.Context) {
+	blocks, err := db.Blocks.GetByUserID(ctx.Request().Context(), ctx.User.ID)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get blocks by user ID")
+		ctx.Redirect("/")
+		return
+	}
+	ctx.Data["Blocks"] = blocks
+
+	ctx.Success("user/blocks")
+}
+
+func Unblock(ctx context.Context) {
+	blockID := uint(ctx.ParamInt("blockID"))
+	if err := db.Blocks.DeleteByID(ctx.Request().Context(), ctx.User.ID, blockID); err != nil {
+		if errors.Is(err, db.ErrBlockNotExist) {
+			ctx.SetErrorFlash(ctx.TrError(err))
+		} else {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to delete block")
+			ctx.SetInternalErrorFlash()
+		}
+		ctx.Redirect("/user/blocks")
+		return
+	}
+
+	ctx.SetSuccessFlash("取消屏蔽成功！")
+	ctx.Redirect("/user/blocks")
+}
@@ -0,0 +1,102 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package user
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/context"
+	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/form"
+)
+
+func UpdateBoxSettings(ctx context.Context, f form.UpdateBoxSettings) {
+	if ctx.HasError() {
+		ctx.Success("user/profile")
+		return
+	}
+
+	settings := db.BoxSettings{
+		QuestionPlaceholder: strings.TrimSpace(f.QuestionPlaceholder),
+		HideReplyEmail:      f.ShowReplyEmail == "",
+		EnableMarkdown:      f.EnableMarkdown != "",
+		QueueMode:           f.QueueMode != "",
+		MaskProfanity:       f.MaskProfanity != "",
+		DisableAchievements: f.ShowAchievements == "",
+	}
+	// The questions are only rendered as Markdown along with the answers.
+	settings.EnableQuestionMarkdown = settings.EnableMarkdown && f.QuestionMarkdown != ""
+
+	var err error
+	if f.QuestionMinLength != "" {
+		if settings.QuestionMinLength, err = strconv.Atoi(f.QuestionMinLength); err != nil {
+			ctx.SetErrorFlash("提问最小长度必须是数字")
+			ctx.Redirect("/user/profile")
+			return
+		}
+	}
+	if f.QuestionMaxLength != "" {
+		if settings.QuestionMaxLength, err = strconv.Atoi(f.QuestionMaxLength); err != nil {
+			ctx.SetErrorFlash("提问最大长度必须是数字")
+			ctx.Redirect("/user/profile")
+			return
+		}
+	}
+	if f.AnswerMaxLength != "" {
+		if settings.AnswerMaxLength, err = strconv.Atoi(f.AnswerMaxLength); err != nil {
+			ctx.SetErrorFlash("回答最大长度必须是数字")
+			ctx.Redirect("/user/profile")
+			return
+		}
+	}
+	if f.DailyAskLimit != "" {
+		if settings.DailyAskLimit, err = strconv.Atoi(f.DailyAskLimit); err != nil {
+			ctx.SetErrorFlash("每日提问次数限制必须是数字")
+			ctx.Redirect("/user/profile")
+			return
+		}
+	}
+	if f.AutoArchiveDays != "" {
+		if settings.AutoArchiveDays, err = strconv.Atoi(f.AutoArchiveDays); err != nil {
+			ctx.SetErrorFlash("自动归档天数必须是数字")
+			ctx.Redirect("/user/profile")
+			return
+		}
+	}
+	// The quota is kept when the queue mode is turned off, so it's restored when the queue mode is turned on again.
+	if f.DailyQuota != "" {
+		if settings.DailyQuota, err = strconv.Atoi(f.DailyQuota); err != nil {
+			ctx.SetErrorFlash("每日回答数量必须是数字")
+			ctx.Redirect("/user/profile")
+			return
+		}
+	}
+
+	// One origin per line, the trailing slashes pasted along with the URLs are trimmed.
+	for _, line := range strings.Split(f.EmbedOrigins, "\n") {
+		if origin := strings.TrimSuffix(strings.TrimSpace(line), "/"); origin != "" {
+			settings.EmbedOrigins = append(settings.EmbedOrigins, origin)
+		}
+	}
+
+	if err := settings.Validate(); err != nil {
+		ctx.SetErrorFlash(ctx.TrError(err))
+		ctx.Redirect("/user/profile")
+		return
+	}
+
+	if err := db.Users.UpdateBoxSettings(ctx.Request().Context(), ctx.User.ID, settings); err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to update box settings")
+		ctx.SetInternalErrorFlash()
+		ctx.Redirect("/user/profile")
+		return
+	}
+
+	ctx.SetSuccessFlash("更新提问箱设置成功")
+	ctx.Redirect("/user/profile")
+}
@@ -0,0 +1,132 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package user
+
+import (
+	"crypto/subtle"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/thanhpk/randstr"
+
+	"github.com/NekoWheel/NekoBox/internal/context"
+	"github.com/NekoWheel/NekoBox/internal/crosspost"
+	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/form"
+)
+
+// The state and the PKCE code verifier are kept in the session until Twitter redirects back.
+const (
+	twitterStateSessionKey        = "crosspostTwitterState"
+	twitterCodeVerifierSessionKey = "crosspostTwitterCodeVerifier"
+)
+
+func Crosspost(ctx context.Context) {
+	accounts, err := db.CrosspostAccounts.GetByUserID(ctx.Request().Context(), ctx.User.ID)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get crosspost accounts by user ID")
+		ctx.Redirect("/")
+		return
+	}
+	for _, account := range accounts {
+		switch account.Provider {
+		case db.CrosspostProviderTwitter:
+			ctx.Data["TwitterAccount"] = account
+		case db.CrosspostProviderMastodon:
+			ctx.Data["MastodonAccount"] = account
+		}
+	}
+	ctx.Data["TwitterEnabled"] = crosspost.Enabled(db.CrosspostProviderTwitter)
+
+	ctx.Success("user/crosspost")
+}
+
+// LinkTwitter redirects the user to Twitter for authorizing the app.
+func LinkTwitter(ctx context.Context) {
+	if !crosspost.Enabled(db.CrosspostProviderTwitter) {
+		ctx.Redirect("/user/crosspost")
+		return
+	}
+
+	state := randstr.Hex(16)
+	codeVerifier := crosspost.NewCodeVerifier()
+	ctx.Session.Set(twitterStateSessionKey, state)
+	ctx.Session.Set(twitterCodeVerifierSessionKey, codeVerifier)
+	ctx.Redirect(crosspost.TwitterAuthorizeURL(state, codeVerifier))
+}
+
+func TwitterCallback(ctx context.Context) {
+	state, _ := ctx.Session.Get(twitterStateSessionKey).(string)
+	codeVerifier, _ := ctx.Session.Get(twitterCodeVerifierSessionKey).(string)
+	ctx.Session.Delete(twitterStateSessionKey)
+	ctx.Session.Delete(twitterCodeVerifierSessionKey)
+
+	// The user may deny the authorization, Twitter redirects back without the code then.
+	code := ctx.Query("code")
+	if state == "" || code == "" || subtle.ConstantTimeCompare([]byte(state), []byte(ctx.Query("state"))) != 1 {
+		ctx.SetErrorFlash(ctx.TrError(crosspost.ErrTwitterAuthorization))
+		ctx.Redirect("/user/crosspost")
+		return
+	}
+
+	if _, err := crosspost.LinkTwitter(ctx.Request().Context(), ctx.User.ID, code, codeVerifier); err != nil {
+		if errors.Is(err, crosspost.ErrTwitterAuthorization) {
+			ctx.SetErrorFlash(ctx.TrError(err))
+		} else {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to link Twitter account")
+			ctx.SetInternalErrorFlash()
+		}
+		ctx.Redirect("/user/crosspost")
+		return
+	}
+
+	ctx.SetSuccessFlash("绑定 Twitter 账号成功！")
+	ctx.Redirect("/user/crosspost")
+}
+
+func LinkMastodon(ctx context.Context, f form.LinkMastodon) {
+	if ctx.HasError() {
+		Crosspost(ctx)
+		return
+	}
+
+	if _, err := crosspost.LinkMastodon(ctx.Request().Context(), ctx.User.ID, f.Instance, f.AccessToken); err != nil {
+		switch {
+		case errors.Is(err, crosspost.ErrInvalidMastodonInstance),
+			errors.Is(err, crosspost.ErrInvalidMastodonToken):
+			ctx.SetErrorFlash(ctx.TrError(err))
+		default:
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to link Mastodon account")
+			ctx.SetInternalErrorFlash()
+		}
+		ctx.Redirect("/user/crosspost")
+		return
+	}
+
+	ctx.SetSuccessFlash("绑定 Mastodon 账号成功！")
+	ctx.Redirect("/user/crosspost")
+}
+
+func UnlinkCrosspost(ctx context.Context) {
+	provider := db.CrosspostProvider(ctx.Param("provider"))
+	if !provider.IsValid() {
+		ctx.Redirect("/user/crosspost")
+		return
+	}
+
+	if err := db.CrosspostAccounts.DeleteByProvider(ctx.Request().Context(), ctx.User.ID, provider); err != nil {
+		if errors.Is(err, db.ErrCrosspostAccountNotExist) {
+			ctx.SetErrorFlash(ctx.TrError(err))
+		} else {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to unlink crosspost account")
+			ctx.SetInternalErrorFlash()
+		}
+		ctx.Redirect("/user/crosspost")
+		return
+	}
+
+	ctx.SetSuccessFlash("解除绑定成功！")
+	ctx.Redirect("/user/crosspost")
+}
@@ -0,0 +1,182 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package user
+
+import (
+	"os"
+	"time"
+
+	"github.com/flamego/cache"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/thanhpk/randstr"
+
+	"github.com/NekoWheel/NekoBox/internal/context"
+	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/form"
+	"github.com/NekoWheel/NekoBox/internal/mail"
+	"github.com/NekoWheel/NekoBox/internal/storage"
+)
+
+const (
+	accountDeletionCodeCacheKeyPrefix = "account-deletion-code:"
+	accountDeletionCodeLifetime       = time.Hour
+)
+
+func DeleteProfile(ctx context.Context) {
+	ctx.Success("user/delete")
+}
+
+// DeleteProfileAction checks the user's password and sends the confirmation email,
+// the account is deleted after the user clicks the link in the email.
+func DeleteProfileAction(ctx context.Context, f form.DeleteProfile, cache cache.Cache) {
+	if ctx.HasError() {
+		ctx.Success("user/delete")
+		return
+	}
+
+	if !ctx.User.Authenticate(f.Password) {
+		ctx.SetErrorFlash("密码错误")
+		ctx.Redirect("/user/profile/delete")
+		return
+	}
+
+	emailSentCacheKey := "account-deletion-email-sent:" + ctx.User.Email
+	_, err := cache.Get(ctx.Request().Context(), emailSentCacheKey)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to read account deletion email sent cache")
+		}
+	} else {
+		ctx.SetErrorFlash("邮件发送太频繁，请稍后再试")
+		ctx.Redirect("/user/profile/delete")
+		return
+	}
+
+	code := randstr.String(64)
+	if err := cache.Set(ctx.Request().Context(), accountDeletionCodeCacheKeyPrefix+code, ctx.User.ID, accountDeletionCodeLifetime); err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to set account deletion code cache")
+		ctx.SetInternalErrorFlash()
+		ctx.Redirect("/user/profile/delete")
+		return
+	}
+
+	if err := mail.SendAccountDeletionMail(ctx.Request().Context(), ctx.User.Email, code); err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to send account deletion mail")
+		ctx.SetErrorFlash("邮件发送失败，请稍后再试")
+		ctx.Redirect("/user/profile/delete")
+		return
+	}
+
+	if err := cache.Set(ctx.Request().Context(), emailSentCacheKey, time.Now(), 2*time.Minute); err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to set account deletion email cache")
+	}
+
+	ctx.SetSuccessFlash("确认邮件已发送至 " + ctx.User.Email + "，请在一小时内点击邮件中的链接完成账号删除。")
+	ctx.Redirect("/user/profile/delete")
+}
+
+func checkAccountDeletionCode(ctx context.Context, cache cache.Cache) (*db.User, bool) {
+	code := ctx.Query("code")
+	userIDItf, err := cache.Get(ctx.Request().Context(), accountDeletionCodeCacheKeyPrefix+code)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			ctx.SetErrorFlash("链接已过期")
+		} else {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to read account deletion code cache")
+			ctx.SetInternalErrorFlash()
+		}
+		ctx.Redirect("/")
+		return nil, false
+	}
+
+	userID, ok := userIDItf.(uint)
+	if !ok {
+		logrus.WithContext(ctx.Request().Context()).WithField("user_id_itf", userIDItf).Error("Failed to convert user id interface to uint")
+		ctx.SetInternalErrorFlash()
+		ctx.Redirect("/")
+		return nil, false
+	}
+
+	user, err := db.Users.GetByID(ctx.Request().Context(), userID)
+	if err != nil {
+		ctx.SetErrorFlash("用户不存在")
+		ctx.Redirect("/")
+		return nil, false
+	}
+	return user, true
+}
+
+func ConfirmDeleteAccount(ctx context.Context, cache cache.Cache) {
+	user, ok := checkAccountDeletionCode(ctx, cache)
+	if !ok {
+		return
+	}
+
+	ctx.Data["User"] = user
+	ctx.Success("user/delete-confirm")
+}
+
+func ConfirmDeleteAccountAction(ctx context.Context, cache cache.Cache) {
+	user, ok := checkAccountDeletionCode(ctx, cache)
+	if !ok {
+		return
+	}
+
+	if err := cache.Delete(ctx.Request().Context(), accountDeletionCodeCacheKeyPrefix+ctx.Query("code")); err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to delete account deletion code cache")
+		ctx.SetInternalErrorFlash()
+		ctx.Redirect("/")
+		return
+	}
+
+	// The voice notes are removed from the storage after the questions are deleted.
+	audioURLs, err := db.Questions.GetAnswerAudioURLs(ctx.Request().Context(), user.ID)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get answer audio URLs")
+		ctx.SetInternalErrorFlash()
+		ctx.Redirect("/")
+		return
+	}
+
+	if err := db.Users.Delete(ctx.Request().Context(), user.ID); err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to delete user")
+		ctx.SetInternalErrorFlash()
+		ctx.Redirect("/")
+		return
+	}
+	ctx.Audit(db.CreateAuditLogOptions{
+		ActorUserID: user.ID,
+		Action:      db.AuditActionAccountDelete,
+		TargetType:  db.AuditTargetUser,
+		TargetID:    user.ID,
+		Metadata:    map[string]interface{}{"email": user.Email, "domain": user.Domain},
+	})
+
+	// All the sessions and tokens of the user become invalid since the user no longer exists,
+	// but we still clean up the current session if it belongs to the user.
+	if ctx.IsLogged && ctx.User.ID == user.ID {
+		ctx.Session.Flush()
+	}
+
+	go func() {
+		for _, pictureURL := range []string{user.Avatar, user.Background, user.ProfileSettings.BackgroundImage} {
+			if pictureURL == "" {
+				continue
+			}
+			if err := storage.DeletePictureFromOSS(pictureURL); err != nil {
+				logrus.WithError(err).WithField("url", pictureURL).Error("Failed to delete picture of the deleted user")
+			}
+		}
+		for _, audioURL := range audioURLs {
+			if err := storage.DeleteAudioFromOSS(audioURL); err != nil {
+				logrus.WithError(err).WithField("url", audioURL).Error("Failed to delete answer audio of the deleted user")
+			}
+		}
+	}()
+
+	ctx.SetSuccessFlash("您的账号已永久删除，感谢您使用 NekoBox。期待未来还能再见 👋🏻")
+	ctx.Redirect("/login")
+}
@@ -0,0 +1,183 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package user
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/flamego/cache"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/context"
+	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/form"
+	"github.com/NekoWheel/NekoBox/internal/mail"
+	"github.com/NekoWheel/NekoBox/internal/security/disposable"
+)
+
+// emailChangeTTL is how long the new address can be verified after the change is requested.
+const emailChangeTTL = 24 * time.Hour
+
+func ChangeEmail(ctx context.Context) {
+	change, err := db.EmailChanges.GetPendingByUserID(ctx.Request().Context(), ctx.User.ID)
+	if err != nil && !errors.Is(err, db.ErrEmailChangeNotExist) {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get pending email change")
+	}
+	ctx.Data["PendingEmailChange"] = change
+	ctx.Success("user/email")
+}
+
+// ChangeEmailAction checks the user's password and sends the verification link to the new address,
+// the old address is notified and can cancel the change. The email is changed after the new address is verified.
+func ChangeEmailAction(ctx context.Context, f form.ChangeEmail, cache cache.Cache) {
+	if ctx.HasError() {
+		ctx.Success("user/email")
+		return
+	}
+
+	if !ctx.User.Authenticate(f.Password) {
+		ctx.SetErrorFlash("密码错误")
+		ctx.Redirect("/user/profile/email")
+		return
+	}
+
+	emailSentCacheKey := "email-change-email-sent:" + strconv.Itoa(int(ctx.User.ID))
+	_, err := cache.Get(ctx.Request().Context(), emailSentCacheKey)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to read email change email sent cache")
+		}
+	} else {
+		ctx.SetErrorFlash("邮件发送太频繁，请稍后再试")
+		ctx.Redirect("/user/profile/email")
+		return
+	}
+
+	_, tokens, err := db.EmailChanges.Create(ctx.Request().Context(), db.CreateEmailChangeOptions{
+		UserID:   ctx.User.ID,
+		OldEmail: ctx.User.Email,
+		NewEmail: f.Email,
+		FromIP:   ctx.RealIP(),
+		TTL:      emailChangeTTL,
+	})
+	if err != nil {
+		if errors.Is(err, db.ErrEmailChangeSame) || errors.Is(err, db.ErrDuplicateEmail) || errors.Is(err, disposable.ErrDisposableEmail) {
+			ctx.SetErrorFlash(ctx.TrError(err))
+		} else {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to create email change")
+			ctx.SetInternalErrorFlash()
+		}
+		ctx.Redirect("/user/profile/email")
+		return
+	}
+
+	if err := mail.SendEmailChangeVerifyMail(ctx.Request().Context(), f.Email, tokens.Verify, emailChangeTTL); err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to send email change verify mail")
+		ctx.SetErrorFlash("邮件发送失败，请稍后再试")
+		ctx.Redirect("/user/profile/email")
+		return
+	}
+	if err := mail.SendEmailChangeNoticeMail(ctx.Request().Context(), ctx.User.Email, f.Email, tokens.Cancel); err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to send email change notice mail")
+	}
+
+	if err := cache.Set(ctx.Request().Context(), emailSentCacheKey, time.Now(), 2*time.Minute); err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to set email change email sent cache")
+	}
+
+	ctx.SetSuccessFlash("确认邮件已发送至 " + f.Email + "，请在 24 小时内点击邮件中的链接完成邮箱修改。")
+	ctx.Redirect("/user/profile/email")
+}
+
+// CancelEmailChange cancels the pending email change of the user.
+func CancelEmailChange(ctx context.Context) {
+	if _, err := db.EmailChanges.CancelByUserID(ctx.Request().Context(), ctx.User.ID); err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to cancel email change")
+		ctx.SetInternalErrorFlash()
+		ctx.Redirect("/user/profile/email")
+		return
+	}
+
+	ctx.SetSuccessFlash("已取消邮箱修改")
+	ctx.Redirect("/user/profile/email")
+}
+
+// VerifyEmailChange asks the user to confirm the change, the change is not confirmed on GET
+// since the mail clients and the security scanners may open the links in the mails.
+func VerifyEmailChange(ctx context.Context) {
+	if ctx.Query("token") == "" {
+		ctx.Redirect("/")
+		return
+	}
+	ctx.Success("user/email-verify")
+}
+
+func VerifyEmailChangeAction(ctx context.Context) {
+	change, err := db.EmailChanges.Confirm(ctx.Request().Context(), ctx.Query("token"))
+	if err != nil {
+		if errors.Is(err, db.ErrEmailChangeNotExist) ||
+			errors.Is(err, db.ErrEmailChangeExpired) ||
+			errors.Is(err, db.ErrEmailChangeConfirmed) ||
+			errors.Is(err, db.ErrEmailChangeCanceled) ||
+			errors.Is(err, db.ErrEmailChangeOutdated) ||
+			errors.Is(err, db.ErrDuplicateEmail) {
+			ctx.SetErrorFlash(ctx.TrError(err))
+		} else {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to confirm email change")
+			ctx.SetInternalErrorFlash()
+		}
+		ctx.Redirect("/")
+		return
+	}
+
+	ctx.Audit(db.CreateAuditLogOptions{
+		ActorUserID: change.UserID,
+		Action:      db.AuditActionEmailChange,
+		TargetType:  db.AuditTargetUser,
+		TargetID:    change.UserID,
+		Metadata: map[string]interface{}{
+			"old_email": change.OldEmail,
+			"new_email": change.NewEmail,
+		},
+	})
+
+	ctx.SetSuccessFlash("邮箱已修改为 " + change.NewEmail + "，请使用新邮箱登录。")
+	if ctx.IsLogged && ctx.User.ID == change.UserID {
+		ctx.Redirect("/user/profile")
+		return
+	}
+	ctx.Redirect("/login")
+}
+
+// CancelEmailChangeByMail asks the owner of the old address to confirm canceling the change.
+func CancelEmailChangeByMail(ctx context.Context) {
+	if ctx.Query("token") == "" {
+		ctx.Redirect("/")
+		return
+	}
+	ctx.Success("user/email-cancel")
+}
+
+func CancelEmailChangeByMailAction(ctx context.Context) {
+	if _, err := db.EmailChanges.Cancel(ctx.Request().Context(), ctx.Query("token")); err != nil {
+		if errors.Is(err, db.ErrEmailChangeNotExist) ||
+			errors.Is(err, db.ErrEmailChangeExpired) ||
+			errors.Is(err, db.ErrEmailChangeConfirmed) ||
+			errors.Is(err, db.ErrEmailChangeCanceled) {
+			ctx.SetErrorFlash(ctx.TrError(err))
+		} else {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to cancel email change")
+			ctx.SetInternalErrorFlash()
+		}
+		ctx.Redirect("/")
+		return
+	}
+
+	ctx.SetSuccessFlash("已取消邮箱修改。若这不是您本人的操作，请及时修改密码。")
+	ctx.Redirect("/")
+}
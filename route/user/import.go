@@ -0,0 +1,112 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package user
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/context"
+	"github.com/NekoWheel/NekoBox/internal/export"
+	"github.com/NekoWheel/NekoBox/internal/form"
+	"github.com/NekoWheel/NekoBox/internal/importer"
+)
+
+func ImportQuestions(ctx context.Context) {
+	ctx.Success("user/import")
+}
+
+// ImportQuestionsAction imports the questions from the export file of the other Q&A box services.
+func ImportQuestionsAction(ctx context.Context, f form.ImportQuestions) {
+	if ctx.HasError() {
+		ctx.Success("user/import")
+		return
+	}
+
+	file, fileHeader, err := ctx.Request().FormFile("file")
+	if err != nil {
+		ctx.SetError(errors.New("请选择要导入的文件"), f)
+		ctx.Success("user/import")
+		return
+	}
+	defer func() { _ = file.Close() }()
+
+	if fileHeader.Size > importer.MaxFileSize {
+		ctx.SetError(errors.New("导入文件太大，最大支持 10MB"), f)
+		ctx.Success("user/import")
+		return
+	}
+
+	questions, err := importer.Parse(importer.Source(f.Source), file)
+	if err != nil {
+		ctx.SetError(err, f)
+		ctx.Success("user/import")
+		return
+	}
+
+	created, err := importer.Import(ctx.Request().Context(), ctx.User.ID, questions)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to import questions")
+		ctx.SetInternalError(f)
+		ctx.Success("user/import")
+		return
+	}
+
+	ctx.SetSuccessFlash(fmt.Sprintf("导入完成！共导入 %d 个提问，跳过了 %d 个重复的提问。", created, len(questions)-created))
+	ctx.Redirect("/user/import")
+}
+
+// ExportQuestionsAPI streams the user's questions in the JSONL interchange format,
+// which can be imported into another NekoBox instance.
+func ExportQuestionsAPI(ctx context.Context) error {
+	if err := export.Run(ctx.User.ID, func() error {
+		fileName := fmt.Sprintf("NekoBox提问导出-%s-%s.jsonl", ctx.User.Domain, time.Now().Format("20060102150405"))
+		ctx.ResponseWriter().Header().Set("Content-Type", export.JSONLContentType)
+		ctx.ResponseWriter().Header().Set("Content-Disposition", "attachment; filename*=UTF-8''"+url.QueryEscape(fileName))
+
+		return export.QuestionsJSONL(ctx.Request().Context(), ctx.ResponseWriter(), ctx.User.ID)
+	}); err != nil {
+		if errors.Is(err, export.ErrExportInProgress) || errors.Is(err, export.ErrExportBusy) {
+			return ctx.JSONError(http.StatusTooManyRequests*100, ctx.TrError(err))
+		}
+		// The response has been partly written, so we can only log the error here.
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to export questions")
+	}
+	return nil
+}
+
+// ImportQuestionsAPI imports the questions from the request body in the JSONL interchange format.
+func ImportQuestionsAPI(ctx context.Context) error {
+	body, err := io.ReadAll(io.LimitReader(ctx.Request().Request.Body, importer.MaxFileSize+1))
+	if err != nil {
+		return ctx.JSONError(40000, ctx.TrError(importer.ErrInvalidFile))
+	}
+	if len(body) > importer.MaxFileSize {
+		return ctx.JSONError(http.StatusRequestEntityTooLarge*100, "导入文件太大，最大支持 10MB")
+	}
+
+	questions, err := importer.Parse(importer.SourceNekoBox, bytes.NewReader(body))
+	if err != nil {
+		return ctx.JSONError(40000, ctx.TrError(err))
+	}
+
+	created, err := importer.Import(ctx.Request().Context(), ctx.User.ID, questions)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to import questions")
+		return ctx.ServerError()
+	}
+
+	return ctx.JSON(map[string]interface{}{
+		"imported": created,
+		"skipped":  len(questions) - created,
+	})
+}
@@ -0,0 +1,41 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package user
+
+import (
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/context"
+	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/form"
+)
+
+// UpdateContentLicense sets the license of the answers, which is shown on the question pages and
+// returned in the API responses. The empty license stops showing it.
+func UpdateContentLicense(ctx context.Context, f form.UpdateContentLicense) {
+	if ctx.HasError() {
+		ctx.SetErrorFlash(ctx.ErrorMessage())
+		ctx.Redirect("/user/profile")
+		return
+	}
+
+	settings := ctx.User.ProfileSettings
+	settings.License = db.ContentLicense(f.License)
+	if err := settings.Validate(); err != nil {
+		ctx.SetErrorFlash(ctx.TrError(err))
+		ctx.Redirect("/user/profile")
+		return
+	}
+
+	if err := db.Users.UpdateProfileSettings(ctx.Request().Context(), ctx.User.ID, settings); err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to update profile settings")
+		ctx.SetInternalErrorFlash()
+		ctx.Redirect("/user/profile")
+		return
+	}
+
+	ctx.SetSuccessFlash("更新授权协议成功")
+	ctx.Redirect("/user/profile")
+}
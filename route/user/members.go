@@ -0,0 +1,248 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package user
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/context"
+	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/form"
+)
+
+// loadBoxMemberUsers loads the users of the box members, the members whose accounts can't be loaded are skipped.
+func loadBoxMemberUsers(ctx context.Context, members []*db.BoxMember, userIDOf func(*db.BoxMember) uint) []*db.BoxMember {
+	loaded := make([]*db.BoxMember, 0, len(members))
+	for _, member := range members {
+		user, err := db.Users.GetByID(ctx.Request().Context(), userIDOf(member))
+		if err != nil {
+			if !errors.Is(err, db.ErrUserNotExists) {
+				logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get box member user by ID")
+			}
+			continue
+		}
+		member.User = user
+		loaded = append(loaded, member)
+	}
+	return loaded
+}
+
+// Members lists the members of the user's box, the box becomes a team box once it has members.
+func Members(ctx context.Context) {
+	members, err := db.BoxMembers.GetByBoxUserID(ctx.Request().Context(), ctx.User.ID)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get box members")
+		ctx.Redirect("/")
+		return
+	}
+	ctx.Data["Members"] = loadBoxMemberUsers(ctx, members, func(member *db.BoxMember) uint { return member.UserID })
+	ctx.Data["MaxMembers"] = db.MaxBoxMembersPerBox
+
+	ctx.Success("user/members")
+}
+
+func NewMember(ctx context.Context, f form.NewBoxMember) {
+	if ctx.HasError() {
+		ctx.SetErrorFlash(ctx.ErrorMessage())
+		ctx.Redirect("/user/members")
+		return
+	}
+
+	memberUser, err := db.Users.GetByDomain(ctx.Request().Context(), strings.TrimSpace(f.Domain))
+	if err != nil {
+		if errors.Is(err, db.ErrUserNotExists) {
+			ctx.SetErrorFlash("该用户不存在")
+		} else {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get user by domain")
+			ctx.SetInternalErrorFlash()
+		}
+		ctx.Redirect("/user/members")
+		return
+	}
+
+	role := db.BoxMemberRole(f.Role)
+	if err := db.BoxMembers.Create(ctx.Request().Context(), db.CreateBoxMemberOptions{
+		BoxUserID: ctx.User.ID,
+		UserID:    memberUser.ID,
+		Role:      role,
+	}); err != nil {
+		switch {
+		case errors.Is(err, db.ErrBoxMemberExists),
+			errors.Is(err, db.ErrTooManyBoxMembers),
+			errors.Is(err, db.ErrInvalidBoxMemberRole):
+			ctx.SetErrorFlash(ctx.TrError(err))
+		default:
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to create box member")
+			ctx.SetInternalErrorFlash()
+		}
+		ctx.Redirect("/user/members")
+		return
+	}
+
+	ctx.Audit(db.CreateAuditLogOptions{
+		Action:     db.AuditActionBoxMemberAdd,
+		TargetType: db.AuditTargetUser,
+		TargetID:   memberUser.ID,
+		Metadata: map[string]interface{}{
+			"box_user_id": ctx.User.ID,
+			"role":        role,
+		},
+	})
+
+	ctx.SetSuccessFlash("添加成员成功！")
+	ctx.Redirect("/user/members")
+}
+
+func UpdateMember(ctx context.Context, f form.UpdateBoxMember) {
+	if ctx.HasError() {
+		ctx.SetErrorFlash(ctx.ErrorMessage())
+		ctx.Redirect("/user/members")
+		return
+	}
+
+	memberUserID := uint(ctx.ParamInt("userID"))
+	role := db.BoxMemberRole(f.Role)
+	if err := db.BoxMembers.UpdateRole(ctx.Request().Context(), ctx.User.ID, memberUserID, role); err != nil {
+		if errors.Is(err, db.ErrBoxMemberNotExist) || errors.Is(err, db.ErrInvalidBoxMemberRole) {
+			ctx.SetErrorFlash(ctx.TrError(err))
+		} else {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to update box member role")
+			ctx.SetInternalErrorFlash()
+		}
+		ctx.Redirect("/user/members")
+		return
+	}
+
+	ctx.Audit(db.CreateAuditLogOptions{
+		Action:     db.AuditActionBoxMemberUpdate,
+		TargetType: db.AuditTargetUser,
+		TargetID:   memberUserID,
+		Metadata: map[string]interface{}{
+			"box_user_id": ctx.User.ID,
+			"role":        role,
+		},
+	})
+
+	ctx.SetSuccessFlash("修改成员角色成功！")
+	ctx.Redirect("/user/members")
+}
+
+func DeleteMember(ctx context.Context) {
+	memberUserID := uint(ctx.ParamInt("userID"))
+	if err := db.BoxMembers.Delete(ctx.Request().Context(), ctx.User.ID, memberUserID); err != nil {
+		if errors.Is(err, db.ErrBoxMemberNotExist) {
+			ctx.SetErrorFlash(ctx.TrError(err))
+		} else {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to delete box member")
+			ctx.SetInternalErrorFlash()
+		}
+		ctx.Redirect("/user/members")
+		return
+	}
+
+	ctx.Audit(db.CreateAuditLogOptions{
+		Action:     db.AuditActionBoxMemberRemove,
+		TargetType: db.AuditTargetUser,
+		TargetID:   memberUserID,
+		Metadata: map[string]interface{}{
+			"box_user_id": ctx.User.ID,
+		},
+	})
+
+	ctx.SetSuccessFlash("移除成员成功！")
+	ctx.Redirect("/user/members")
+}
+
+// Boxes lists the team boxes which the user is a member of.
+func Boxes(ctx context.Context) {
+	memberships, err := db.BoxMembers.GetByUserID(ctx.Request().Context(), ctx.User.ID)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get box memberships")
+		ctx.Redirect("/")
+		return
+	}
+	ctx.Data["Memberships"] = loadBoxMemberUsers(ctx, memberships, func(member *db.BoxMember) uint { return member.BoxUserID })
+
+	ctx.Success("user/boxes")
+}
+
+// BoxQuestions lists the unarchived questions of the team box for the member, which is the inbox of the box.
+func BoxQuestions(ctx context.Context) {
+	boxUser, role, ok := getMembership(ctx)
+	if !ok {
+		return
+	}
+
+	questions, _, err := db.Questions.GetByUserID(ctx.Request().Context(), boxUser.ID, db.GetQuestionsByUserIDOptions{
+		FilterAnswered: false,
+		FilterArchived: db.ArchivedFilterExclude,
+	})
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get questions by user ID")
+		ctx.Redirect("/user/boxes")
+		return
+	}
+	ctx.Data["BoxUser"] = boxUser
+	ctx.Data["BoxRole"] = role
+	ctx.Data["Questions"] = questions
+
+	ctx.Success("user/box-questions")
+}
+
+// LeaveBox removes the user from the team box.
+func LeaveBox(ctx context.Context) {
+	boxUser, _, ok := getMembership(ctx)
+	if !ok {
+		return
+	}
+
+	if err := db.BoxMembers.Delete(ctx.Request().Context(), boxUser.ID, ctx.User.ID); err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to delete box member")
+		ctx.SetInternalErrorFlash()
+		ctx.Redirect("/user/boxes")
+		return
+	}
+
+	ctx.Audit(db.CreateAuditLogOptions{
+		Action:     db.AuditActionBoxMemberRemove,
+		TargetType: db.AuditTargetUser,
+		TargetID:   ctx.User.ID,
+		Metadata: map[string]interface{}{
+			"box_user_id": boxUser.ID,
+		},
+	})
+
+	ctx.SetSuccessFlash("已退出该提问箱！")
+	ctx.Redirect("/user/boxes")
+}
+
+// getMembership returns the team box of the domain and the role of the user in it,
+// the user is redirected if they are not a member of the box.
+func getMembership(ctx context.Context) (*db.User, db.BoxMemberRole, bool) {
+	boxUser, err := db.Users.GetByDomain(ctx.Request().Context(), ctx.Param("domain"))
+	if err != nil {
+		if !errors.Is(err, db.ErrUserNotExists) {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get user by domain")
+		}
+		ctx.Redirect("/user/boxes")
+		return nil, "", false
+	}
+
+	role, err := db.BoxMembers.GetRole(ctx.Request().Context(), boxUser.ID, ctx.User.ID)
+	if err != nil {
+		if errors.Is(err, db.ErrBoxMemberNotExist) {
+			ctx.SetErrorFlash(ctx.TrError(err))
+		} else {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get box member role")
+			ctx.SetInternalErrorFlash()
+		}
+		ctx.Redirect("/user/boxes")
+		return nil, "", false
+	}
+	return boxUser, role, true
+}
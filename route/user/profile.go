@@ -7,6 +7,7 @@ package user
 import (
 	"fmt"
 	"net/url"
+	"strconv"
 	"time"
 
 	"github.com/pkg/errors"
@@ -15,7 +16,9 @@ import (
 
 	"github.com/NekoWheel/NekoBox/internal/context"
 	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/export"
 	"github.com/NekoWheel/NekoBox/internal/form"
+	"github.com/NekoWheel/NekoBox/internal/i18n"
 	"github.com/NekoWheel/NekoBox/internal/storage"
 )
 
@@ -36,8 +39,8 @@ func UpdateProfile(ctx context.Context, f form.UpdateProfile) {
 	var avatarURL string
 	avatarFile, avatarFileHeader, err := ctx.Request().FormFile("avatar")
 	if err == nil {
-		if avatarFileHeader.Size > storage.MaxAvatarSize {
-			ctx.SetError(errors.New("头像文件太大，最大支持 2MB"))
+		if err := storage.CheckPicture(avatarFile, avatarFileHeader); err != nil {
+			ctx.SetError(err)
 			ctx.Success("user/profile")
 			return
 		}
@@ -50,8 +53,8 @@ func UpdateProfile(ctx context.Context, f form.UpdateProfile) {
 	var backgroundURL string
 	backgroundFile, backgroundFileHeader, err := ctx.Request().FormFile("background")
 	if err == nil {
-		if backgroundFileHeader.Size > storage.MaxBackgroundSize {
-			ctx.SetError(errors.New("背景图文件太大，最大支持 2MB"))
+		if err := storage.CheckPicture(backgroundFile, backgroundFileHeader); err != nil {
+			ctx.SetError(err)
 			ctx.Success("user/profile")
 			return
 		}
@@ -72,6 +75,8 @@ func UpdateProfile(ctx context.Context, f form.UpdateProfile) {
 			ctx.Success("user/profile")
 			return
 		}
+		auditPasswordChange(ctx)
+		keepSignedIn(ctx)
 	}
 
 	var notify db.NotifyType
@@ -81,12 +86,34 @@ func UpdateProfile(ctx context.Context, f form.UpdateProfile) {
 		notify = db.NotifyTypeNone
 	}
 
+	if f.Locale != "" && !i18n.IsSupported(f.Locale) {
+		ctx.SetErrorFlash("界面语言不合法")
+		ctx.Redirect("/user/profile")
+		return
+	}
+
+	preferences, err := notificationPreferences(ctx, f)
+	if err != nil {
+		ctx.SetErrorFlash(err.Error())
+		ctx.Redirect("/user/profile")
+		return
+	}
+	if preferences != nil {
+		if err := db.Users.UpdateNotificationPreferences(ctx.Request().Context(), ctx.User.ID, *preferences); err != nil {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to update notification preferences")
+			ctx.SetInternalErrorFlash()
+			ctx.Redirect("/user/profile")
+			return
+		}
+	}
+
 	if err := db.Users.Update(ctx.Request().Context(), ctx.User.ID, db.UpdateUserOptions{
 		Name:       f.Name,
 		Avatar:     avatarURL,
 		Background: backgroundURL,
 		Intro:      f.Intro,
 		Notify:     notify,
+		Locale:     f.Locale,
 	}); err != nil {
 		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to update user profile")
 		ctx.SetInternalErrorFlash()
@@ -96,6 +123,89 @@ func UpdateProfile(ctx context.Context, f form.UpdateProfile) {
 	ctx.Redirect("/user/profile")
 }
 
+// notificationPreferences returns the notification preferences updated by the form, the preferences which
+// are not in the form are kept unchanged. It returns nil if nothing is changed.
+func notificationPreferences(ctx context.Context, f form.UpdateProfile) (*db.NotificationPreferences, error) {
+	if f.DigestFrequency == "" && f.ReminderDays == "" {
+		return nil, nil
+	}
+
+	preferences := ctx.User.NotificationPreferences
+	if f.DigestFrequency != "" {
+		preferences.DigestFrequency = db.DigestFrequency(f.DigestFrequency)
+		if err := preferences.Validate(); err != nil {
+			return nil, errors.New("通知频率不合法")
+		}
+	}
+	if f.ReminderDays != "" {
+		days, err := strconv.Atoi(f.ReminderDays)
+		if err != nil {
+			return nil, errors.New("未回答提醒天数必须是数字")
+		}
+		preferences.ReminderDays = days
+		if err := preferences.Validate(); err != nil {
+			return nil, errors.Errorf("未回答提醒天数应在 0 到 %d 天之间", db.MaxReminderDays)
+		}
+	}
+	return &preferences, nil
+}
+
+func UpdateProfileAPI(ctx context.Context, f form.UpdateProfile) error {
+	if ctx.HasError() {
+		return ctx.JSONError(40000, ctx.ErrorMessage())
+	}
+
+	if f.NewPassword != "" {
+		if err := db.Users.ChangePassword(ctx.Request().Context(), ctx.User.ID, f.OldPassword, f.NewPassword); err != nil {
+			if errors.Is(err, db.ErrBadCredential) {
+				return ctx.JSONError(40000, "旧密码输入错误")
+			}
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to update password")
+			return ctx.ServerError()
+		}
+		auditPasswordChange(ctx)
+		keepSignedIn(ctx)
+	}
+
+	notify := db.NotifyTypeNone
+	if f.NotifyEmail != "" {
+		notify = db.NotifyTypeEmail
+	}
+
+	if f.Locale != "" && !i18n.IsSupported(f.Locale) {
+		return ctx.JSONError(40000, "界面语言不合法")
+	}
+
+	preferences, err := notificationPreferences(ctx, f)
+	if err != nil {
+		return ctx.JSONError(40000, err.Error())
+	}
+	if preferences != nil {
+		if err := db.Users.UpdateNotificationPreferences(ctx.Request().Context(), ctx.User.ID, *preferences); err != nil {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to update notification preferences")
+			return ctx.ServerError()
+		}
+	}
+
+	if err := db.Users.Update(ctx.Request().Context(), ctx.User.ID, db.UpdateUserOptions{
+		Name:   f.Name,
+		Intro:  f.Intro,
+		Notify: notify,
+		Locale: f.Locale,
+	}); err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to update user profile")
+		return ctx.ServerError()
+	}
+
+	// Read the updated profile from the primary database, the replicas may not catch up yet.
+	user, err := db.Users.GetByID(db.WithPrimary(ctx.Request().Context()), ctx.User.ID)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get user")
+		return ctx.ServerError()
+	}
+	return ctx.JSON(user)
+}
+
 func ExportProfile(ctx context.Context) {
 	user, err := db.Users.GetByID(ctx.Request().Context(), ctx.User.ID)
 	if err != nil {
@@ -106,7 +216,7 @@ func ExportProfile(ctx context.Context) {
 		return
 	}
 
-	questions, err := db.Questions.GetByUserID(ctx.Request().Context(), user.ID, db.GetQuestionsByUserIDOptions{
+	questions, _, err := db.Questions.GetByUserID(ctx.Request().Context(), user.ID, db.GetQuestionsByUserIDOptions{
 		FilterAnswered: false,
 	})
 	if err != nil {
@@ -139,6 +249,62 @@ func ExportProfile(ctx context.Context) {
 	}
 }
 
+// ProfileExport streams the ZIP archive of all the user's data.
+func ProfileExport(ctx context.Context) {
+	user, err := db.Users.GetByID(ctx.Request().Context(), ctx.User.ID)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get user")
+
+		ctx.SetError(errors.New("导出失败：获取用户信息失败"))
+		ctx.Success("user/profile")
+		return
+	}
+
+	if err := export.Run(user.ID, func() error {
+		fileName := fmt.Sprintf("NekoBox数据导出-%s-%s.zip", user.Domain, time.Now().Format("20060102150405"))
+		ctx.ResponseWriter().Header().Set("Content-Type", "application/zip")
+		ctx.ResponseWriter().Header().Set("Content-Disposition", "attachment; filename*=UTF-8''"+url.QueryEscape(fileName))
+
+		return export.Takeout(ctx.Request().Context(), ctx.ResponseWriter(), user)
+	}); err != nil {
+		if errors.Is(err, export.ErrExportInProgress) || errors.Is(err, export.ErrExportBusy) {
+			ctx.SetError(err)
+			ctx.Success("user/profile")
+			return
+		}
+		// The response has been partly written, so we can only log the error here.
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to export user data")
+	}
+}
+
+// ProfileExportSite streams the ZIP archive of the static site of the user's public answered questions.
+func ProfileExportSite(ctx context.Context) {
+	user, err := db.Users.GetByID(ctx.Request().Context(), ctx.User.ID)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get user")
+
+		ctx.SetError(errors.New("导出失败：获取用户信息失败"))
+		ctx.Success("user/profile")
+		return
+	}
+
+	if err := export.Run(user.ID, func() error {
+		fileName := fmt.Sprintf("NekoBox静态站点导出-%s-%s.zip", user.Domain, time.Now().Format("20060102150405"))
+		ctx.ResponseWriter().Header().Set("Content-Type", "application/zip")
+		ctx.ResponseWriter().Header().Set("Content-Disposition", "attachment; filename*=UTF-8''"+url.QueryEscape(fileName))
+
+		return export.Site(ctx.Request().Context(), ctx.ResponseWriter(), user)
+	}); err != nil {
+		if errors.Is(err, export.ErrExportInProgress) || errors.Is(err, export.ErrExportBusy) {
+			ctx.SetError(err)
+			ctx.Success("user/profile")
+			return
+		}
+		// The response has been partly written, so we can only log the error here.
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to export static site")
+	}
+}
+
 func createXLSXStreamWriter(xlsx *excelize.File, sheet string, headers []string) (*excelize.StreamWriter, error) {
 	xlsx.NewSheet(sheet)
 	sw, err := xlsx.NewStreamWriter(sheet)
@@ -209,6 +375,25 @@ func createExportExcelFile(user *db.User, questions []*db.Question) (*excelize.F
 	return f, nil
 }
 
+// keepSignedIn signs in the current session again after the password is changed,
+// which has signed out all the sessions of the user.
+func keepSignedIn(ctx context.Context) {
+	if ctx.UserSession == nil {
+		return
+	}
+	if err := ctx.SignIn(ctx.User.ID); err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to sign in again after password change")
+	}
+}
+
+func auditPasswordChange(ctx context.Context) {
+	ctx.Audit(db.CreateAuditLogOptions{
+		Action:     db.AuditActionPasswordChange,
+		TargetType: db.AuditTargetUser,
+		TargetID:   ctx.User.ID,
+	})
+}
+
 func DeactivateProfile(ctx context.Context) {
 	ctx.Success("user/deactivate")
 }
@@ -220,7 +405,12 @@ func DeactivateProfileAction(ctx context.Context) {
 		ctx.Success("user/deactivate")
 		return
 	}
-	ctx.Session.Flush()
+	ctx.Audit(db.CreateAuditLogOptions{
+		Action:     db.AuditActionAccountDeactivate,
+		TargetType: db.AuditTargetUser,
+		TargetID:   ctx.User.ID,
+	})
+	ctx.SignOut()
 	ctx.SetSuccessFlash("您的账号已停用，感谢您使用 NekoBox。期待未来还能再见 👋🏻")
 	ctx.Redirect("/login")
 }
@@ -0,0 +1,80 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package user
+
+import (
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/context"
+	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/form"
+	"github.com/NekoWheel/NekoBox/internal/security/censor"
+)
+
+func Prompts(ctx context.Context) {
+	prompts, err := db.Prompts.GetByUserID(ctx.Request().Context(), ctx.User.ID)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get prompts by user ID")
+		ctx.Redirect("/")
+		return
+	}
+	ctx.Data["Prompts"] = prompts
+	ctx.Data["MaxPrompts"] = db.MaxPromptsPerUser
+
+	ctx.Success("user/prompts")
+}
+
+func NewPrompt(ctx context.Context, f form.NewPrompt) {
+	if ctx.HasError() {
+		Prompts(ctx)
+		return
+	}
+
+	// 🚨 Content security check, the prompt is shown on the public box page.
+	censorResponse, err := censor.Text(ctx.Request().Context(), f.Content)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to censor text")
+	}
+	if err == nil && !censorResponse.Pass {
+		ctx.SetErrorFlash(censorResponse.ErrorMessage())
+		ctx.Redirect("/user/prompts")
+		return
+	}
+
+	if _, err := db.Prompts.Create(ctx.Request().Context(), db.CreatePromptOptions{
+		UserID:  ctx.User.ID,
+		Content: f.Content,
+	}); err != nil {
+		if errors.Is(err, db.ErrTooManyPrompts) {
+			ctx.SetErrorFlash(ctx.TrError(err))
+		} else {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to create prompt")
+			ctx.SetInternalErrorFlash()
+		}
+		ctx.Redirect("/user/prompts")
+		return
+	}
+
+	ctx.SetSuccessFlash("发起话题成功！")
+	ctx.Redirect("/user/prompts")
+}
+
+func DeletePrompt(ctx context.Context) {
+	promptID := uint(ctx.ParamInt("promptID"))
+	if err := db.Prompts.DeleteByID(ctx.Request().Context(), ctx.User.ID, promptID); err != nil {
+		if errors.Is(err, db.ErrPromptNotExist) {
+			ctx.SetErrorFlash(ctx.TrError(err))
+		} else {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to delete prompt")
+			ctx.SetInternalErrorFlash()
+		}
+		ctx.Redirect("/user/prompts")
+		return
+	}
+
+	ctx.SetSuccessFlash("关闭话题成功！")
+	ctx.Redirect("/user/prompts")
+}
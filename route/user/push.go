@@ -0,0 +1,57 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package user
+
+import (
+	"net/url"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/context"
+	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/form"
+	"github.com/NekoWheel/NekoBox/internal/push"
+)
+
+// SubscribePush saves the Web Push subscription of the current browser.
+func SubscribePush(ctx context.Context, f form.SubscribePush) error {
+	if !push.Enabled() {
+		return ctx.JSONError(40300, "浏览器通知未开启")
+	}
+	if ctx.HasError() {
+		return ctx.JSONError(40000, ctx.ErrorMessage())
+	}
+
+	// The push services are always served over HTTPS.
+	endpoint, err := url.Parse(f.Endpoint)
+	if err != nil || endpoint.Scheme != "https" || endpoint.Host == "" {
+		return ctx.JSONError(40000, "推送地址不合法")
+	}
+
+	if err := db.PushSubscriptions.Create(ctx.Request().Context(), db.CreatePushSubscriptionOptions{
+		UserID:    ctx.User.ID,
+		Endpoint:  f.Endpoint,
+		P256dh:    f.P256dh,
+		Auth:      f.Auth,
+		UserAgent: ctx.Request().UserAgent(),
+	}); err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to create push subscription")
+		return ctx.ServerError()
+	}
+	return ctx.JSON(nil)
+}
+
+// UnsubscribePush deletes the Web Push subscription of the current browser.
+func UnsubscribePush(ctx context.Context, f form.UnsubscribePush) error {
+	if ctx.HasError() {
+		return ctx.JSONError(40000, ctx.ErrorMessage())
+	}
+
+	if err := db.PushSubscriptions.DeleteByEndpoint(ctx.Request().Context(), ctx.User.ID, f.Endpoint); err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to delete push subscription")
+		return ctx.ServerError()
+	}
+	return ctx.JSON(nil)
+}
@@ -5,15 +5,68 @@
 package user
 
 import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 
+	"github.com/NekoWheel/NekoBox/internal/activitypub"
+	"github.com/NekoWheel/NekoBox/internal/conf"
 	"github.com/NekoWheel/NekoBox/internal/context"
 	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/dbutil"
+	"github.com/NekoWheel/NekoBox/internal/export"
+	"github.com/NekoWheel/NekoBox/internal/form"
+	"github.com/NekoWheel/NekoBox/internal/pubsub"
+	"github.com/NekoWheel/NekoBox/internal/webhook"
 )
 
 func QuestionList(ctx context.Context) {
-	questions, err := db.Questions.GetByUserID(ctx.Request().Context(), ctx.User.ID, db.GetQuestionsByUserIDOptions{
+	filterUnread := ctx.Query("filter") == "unread"
+	filterArchived := ctx.Query("filter") == "archived"
+	// The questions of the shadow-banned askers are only listed in the shadow folder.
+	filterShadowBanned := ctx.Query("filter") == "shadow"
+
+	// The archived questions are only listed in the archive.
+	archivedFilter := db.ArchivedFilterExclude
+	if filterArchived {
+		archivedFilter = db.ArchivedFilterOnly
+	} else if filterShadowBanned {
+		archivedFilter = db.ArchivedFilterAll
+	}
+	// In the queue mode, the inbox only shows the questions left in today's quota.
+	if ctx.User.BoxSettings.QueueMode && !filterArchived && !filterShadowBanned {
+		queue, err := getQuestionQueue(ctx)
+		if err != nil {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get question queue")
+			ctx.Redirect("/")
+			return
+		}
+
+		// The read questions are skipped by the template when filtering the unread ones, so the positions are kept.
+		ctx.Data["Questions"] = queue.Questions
+		ctx.Data["Queue"] = queue
+		ctx.Data["FilterUnread"] = filterUnread
+		ctx.Success("user/question-list")
+		return
+	}
+
+	// The unknown sort falls back to the newest questions first.
+	sort := db.QuestionSort(ctx.Query("sort"))
+	if !sort.IsValid() {
+		sort = db.QuestionSortNewest
+	}
+
+	questions, _, err := db.Questions.GetByUserID(ctx.Request().Context(), ctx.User.ID, db.GetQuestionsByUserIDOptions{
 		FilterAnswered: false,
+		FilterUnread:   filterUnread,
+		FilterArchived: archivedFilter,
+		ShadowBanned:   filterShadowBanned,
+		Sort:           sort,
 	})
 	if err != nil {
 		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get questions by user ID")
@@ -21,6 +74,216 @@ func QuestionList(ctx context.Context) {
 		return
 	}
 	ctx.Data["Questions"] = questions
+	ctx.Data["FilterUnread"] = filterUnread
+	ctx.Data["FilterArchived"] = filterArchived
+	ctx.Data["FilterShadowBanned"] = filterShadowBanned
+	ctx.Data["Sort"] = string(sort)
 
 	ctx.Success("user/question-list")
 }
+
+func MarkAllQuestionsRead(ctx context.Context) {
+	marked, err := db.Questions.MarkAllRead(ctx.Request().Context(), ctx.User.ID)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to mark all questions as read")
+		ctx.SetInternalErrorFlash()
+		ctx.Redirect("/user/questions")
+		return
+	}
+
+	ctx.SetSuccessFlash(fmt.Sprintf("已将 %d 个提问标记为已读", marked))
+	ctx.Redirect("/user/questions")
+}
+
+// questionEventsKeepAlive is the interval of the comments sent to keep the idle event stream open through the proxies.
+const questionEventsKeepAlive = 30 * time.Second
+
+// QuestionEvents streams the new questions of the owner's inbox as Server-Sent Events,
+// so the open inbox page shows the new questions without refreshing.
+func QuestionEvents(ctx context.Context) {
+	w := ctx.ResponseWriter()
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // Disable the response buffering of Nginx.
+	w.WriteHeader(http.StatusOK)
+	w.Flush()
+
+	events, unsubscribe := pubsub.Subscribe(ctx.User.ID)
+	defer unsubscribe()
+
+	ticker := time.NewTicker(questionEventsKeepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Request().Context().Done():
+			return
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case event := <-events:
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, event.Data); err != nil {
+				return
+			}
+		}
+		w.Flush()
+	}
+}
+
+func QuestionListAPI(ctx context.Context) error {
+	archivedFilter := db.ArchivedFilterExclude
+	if ctx.QueryBool("archived") {
+		archivedFilter = db.ArchivedFilterOnly
+	}
+	sort := db.QuestionSort(ctx.Query("sort"))
+	if !sort.IsValid() {
+		return ctx.JSONError(40000, "排序方式不合法")
+	}
+	questions, pageInfo, err := db.Questions.GetByUserID(ctx.Request().Context(), ctx.User.ID, db.GetQuestionsByUserIDOptions{
+		Cursor: &dbutil.Cursor{
+			Value:     ctx.Query("cursor"),
+			PageSize:  ctx.QueryInt("page_size"),
+			WithTotal: ctx.QueryBool("with_total"),
+		},
+		FilterAnswered: false,
+		FilterUnread:   ctx.QueryBool("unread"),
+		FilterArchived: archivedFilter,
+		Sort:           sort,
+	})
+	if err != nil {
+		if errors.Is(err, db.ErrInvalidQuestionCursor) {
+			return ctx.JSONError(40000, err.Error())
+		}
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get questions by user ID")
+		return ctx.ServerError()
+	}
+
+	return ctx.JSON(map[string]interface{}{
+		"questions":   questions,
+		"next_cursor": pageInfo.NextCursor,
+		"has_more":    pageInfo.HasMore,
+		"total":       pageInfo.Total,
+	})
+}
+
+// NextQuestionAPI returns the unanswered question next to the given one and the number of the unanswered
+// questions, so the clients can answer the inbox one by one. The question is answered through the answer API
+// with its version, which keeps the question from being answered twice by the clients opened at the same time.
+func NextQuestionAPI(ctx context.Context) error {
+	question, err := db.Questions.GetNextUnanswered(ctx.Request().Context(), ctx.User.ID, db.GetNextUnansweredOptions{
+		AfterID:  uint(ctx.QueryInt64("after")),
+		BeforeID: uint(ctx.QueryInt64("before")),
+	})
+	if err != nil && !errors.Is(err, db.ErrQuestionNotExist) {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get next unanswered question")
+		return ctx.ServerError()
+	}
+
+	remaining, err := db.Questions.CountInQueue(ctx.Request().Context(), ctx.User.ID)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to count unanswered questions")
+		return ctx.ServerError()
+	}
+
+	resp := map[string]interface{}{
+		"question":  question,
+		"remaining": remaining,
+	}
+	if question != nil {
+		resp["answer_url"] = fmt.Sprintf("%s/api/v1/user/%s/questions/%d/answer", conf.App.ExternalURL, ctx.User.Domain, question.ID)
+	}
+	return ctx.JSON(resp)
+}
+
+// maxBulkQuestions is the maximum number of the questions in a bulk operation.
+const maxBulkQuestions = 500
+
+// BulkQuestions deletes, marks as read or exports the selected questions of the owner's inbox.
+func BulkQuestions(ctx context.Context, f form.BulkQuestions) {
+	if ctx.HasError() {
+		ctx.SetErrorFlash(ctx.ErrorMessage())
+		ctx.Redirect("/user/questions")
+		return
+	}
+
+	ids := make([]uint, 0, len(ctx.Request().PostForm["ids"]))
+	for _, value := range ctx.Request().PostForm["ids"] {
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, uint(id))
+	}
+	if len(ids) == 0 {
+		ctx.SetErrorFlash("请先选择提问")
+		ctx.Redirect("/user/questions")
+		return
+	}
+	if len(ids) > maxBulkQuestions {
+		ctx.SetErrorFlash(fmt.Sprintf("每次最多只能操作 %d 个提问", maxBulkQuestions))
+		ctx.Redirect("/user/questions")
+		return
+	}
+
+	switch f.Action {
+	case "delete":
+		// The questions are read before deleting for the webhook payloads.
+		questions, err := db.Questions.GetByIDs(db.WithPrimary(ctx.Request().Context()), ctx.User.ID, ids)
+		if err != nil {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get questions by IDs")
+			ctx.SetInternalErrorFlash()
+			break
+		}
+		deleted, err := db.Questions.DeleteByIDs(ctx.Request().Context(), ctx.User.ID, ids)
+		if err != nil {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to delete questions")
+			ctx.SetInternalErrorFlash()
+			break
+		}
+		for _, question := range questions {
+			ctx.Audit(db.CreateAuditLogOptions{
+				Action:     db.AuditActionQuestionDelete,
+				TargetType: db.AuditTargetQuestion,
+				TargetID:   question.ID,
+				Metadata: map[string]interface{}{
+					"user_id": ctx.User.ID,
+					"content": question.Content,
+					"bulk":    true,
+				},
+			})
+			webhook.Trigger(ctx.Request().Context(), webhook.EventQuestionDeleted, ctx.User, question)
+			activitypub.DeleteAnswer(ctx.Request().Context(), ctx.User, question)
+		}
+		ctx.SetSuccessFlash(fmt.Sprintf("已删除 %d 个提问，删除后的提问可以在回收站中恢复。", deleted))
+
+	case "read":
+		marked, err := db.Questions.MarkReadByIDs(ctx.Request().Context(), ctx.User.ID, ids)
+		if err != nil {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to mark questions as read")
+			ctx.SetInternalErrorFlash()
+			break
+		}
+		ctx.SetSuccessFlash(fmt.Sprintf("已将 %d 个提问标记为已读", marked))
+
+	case "export":
+		questions, err := db.Questions.GetByIDs(ctx.Request().Context(), ctx.User.ID, ids)
+		if err != nil {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get questions by IDs")
+			ctx.SetInternalErrorFlash()
+			break
+		}
+
+		fileName := fmt.Sprintf("NekoBox提问导出-%s-%s.csv", ctx.User.Domain, time.Now().Format("20060102150405"))
+		ctx.ResponseWriter().Header().Set("Content-Type", "text/csv; charset=utf-8")
+		ctx.ResponseWriter().Header().Set("Content-Disposition", "attachment; filename*=UTF-8''"+url.QueryEscape(fileName))
+		if err := export.QuestionsCSV(ctx.ResponseWriter(), questions); err != nil {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to export questions")
+		}
+		return
+
+	default:
+		ctx.SetErrorFlash("不支持的操作")
+	}
+	ctx.Redirect("/user/questions")
+}
@@ -0,0 +1,67 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package user
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/context"
+	"github.com/NekoWheel/NekoBox/internal/db"
+)
+
+// questionQueue is today's part of the owner's question queue in the queue mode.
+type questionQueue struct {
+	// Questions are the next questions to answer today, the first one is at the position 1.
+	Questions     []*db.Question `json:"questions"`
+	Quota         int            `json:"quota"`
+	AnsweredToday int64          `json:"answered_today"`
+	Length        int64          `json:"length"`
+}
+
+// getQuestionQueue returns the questions which are left in today's quota of the owner.
+func getQuestionQueue(ctx context.Context) (*questionQueue, error) {
+	now := time.Now()
+	year, month, day := now.Date()
+	startOfToday := time.Date(year, month, day, 0, 0, 0, 0, now.Location())
+
+	answeredToday, err := db.Questions.CountAnsweredSince(ctx.Request().Context(), ctx.User.ID, startOfToday)
+	if err != nil {
+		return nil, errors.Wrap(err, "count answered today")
+	}
+	length, err := db.Questions.CountInQueue(ctx.Request().Context(), ctx.User.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "count in queue")
+	}
+
+	quota := ctx.User.BoxSettings.DailyQuota
+	questions, err := db.Questions.GetNextInQueue(ctx.Request().Context(), ctx.User.ID, quota-int(answeredToday))
+	if err != nil {
+		return nil, errors.Wrap(err, "get next in queue")
+	}
+
+	return &questionQueue{
+		Questions:     questions,
+		Quota:         quota,
+		AnsweredToday: answeredToday,
+		Length:        length,
+	}, nil
+}
+
+// QuestionQueueAPI returns the questions left in today's quota, it's only available in the queue mode.
+func QuestionQueueAPI(ctx context.Context) error {
+	if !ctx.User.BoxSettings.QueueMode {
+		return ctx.JSONError(40000, "未开启排队模式")
+	}
+
+	queue, err := getQuestionQueue(ctx)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get question queue")
+		return ctx.ServerError()
+	}
+	return ctx.JSON(queue)
+}
@@ -0,0 +1,105 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package user
+
+import (
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/context"
+	"github.com/NekoWheel/NekoBox/internal/db"
+)
+
+// recentLoginAttemptsLimit is the number of the recent failed logins shown to the user.
+const recentLoginAttemptsLimit = 10
+
+func Sessions(ctx context.Context) {
+	userSessions, err := db.UserSessions.GetByUserID(ctx.Request().Context(), ctx.User.ID)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get user sessions by user ID")
+		ctx.Redirect("/")
+		return
+	}
+	ctx.Data["UserSessions"] = userSessions
+
+	var currentSessionID uint
+	if ctx.UserSession != nil {
+		currentSessionID = ctx.UserSession.ID
+	}
+	ctx.Data["CurrentSessionID"] = currentSessionID
+
+	loginAttempts, err := db.LoginAttempts.GetByUserID(ctx.Request().Context(), ctx.User.ID, recentLoginAttemptsLimit)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get login attempts by user ID")
+		ctx.Redirect("/")
+		return
+	}
+	ctx.Data["LoginAttempts"] = loginAttempts
+
+	ctx.Success("user/sessions")
+}
+
+// DeleteSession signs out the session on the other device.
+func DeleteSession(ctx context.Context) {
+	sessionID := uint(ctx.ParamInt("sessionID"))
+	if ctx.UserSession != nil && ctx.UserSession.ID == sessionID {
+		ctx.SetErrorFlash("不能移除当前会话，请直接退出登录")
+		ctx.Redirect("/user/sessions")
+		return
+	}
+
+	if err := db.UserSessions.DeleteByID(ctx.Request().Context(), ctx.User.ID, sessionID); err != nil {
+		if errors.Is(err, db.ErrUserSessionNotExist) {
+			ctx.SetErrorFlash(ctx.TrError(err))
+		} else {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to delete user session")
+			ctx.SetInternalErrorFlash()
+		}
+		ctx.Redirect("/user/sessions")
+		return
+	}
+
+	ctx.Audit(db.CreateAuditLogOptions{
+		Action:     db.AuditActionSessionRevoke,
+		TargetType: db.AuditTargetUser,
+		TargetID:   ctx.User.ID,
+		Metadata: map[string]interface{}{
+			"session_id": sessionID,
+		},
+	})
+
+	ctx.SetSuccessFlash("已退出该设备的登录！")
+	ctx.Redirect("/user/sessions")
+}
+
+// DeleteOtherSessions signs out all the sessions except the current one.
+func DeleteOtherSessions(ctx context.Context) {
+	var currentSessionID uint
+	if ctx.UserSession != nil {
+		currentSessionID = ctx.UserSession.ID
+	}
+
+	count, err := db.UserSessions.DeleteByUserID(ctx.Request().Context(), ctx.User.ID, currentSessionID)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to delete other user sessions")
+		ctx.SetInternalErrorFlash()
+		ctx.Redirect("/user/sessions")
+		return
+	}
+
+	if count > 0 {
+		ctx.Audit(db.CreateAuditLogOptions{
+			Action:     db.AuditActionSessionRevoke,
+			TargetType: db.AuditTargetUser,
+			TargetID:   ctx.User.ID,
+			Metadata: map[string]interface{}{
+				"count": count,
+			},
+		})
+	}
+
+	ctx.SetSuccessFlash("已退出其他所有设备的登录！")
+	ctx.Redirect("/user/sessions")
+}
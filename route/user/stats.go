@@ -0,0 +1,174 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package user
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/context"
+	"github.com/NekoWheel/NekoBox/internal/db"
+)
+
+// statsPeriods are the available periods of the statistics in days.
+var statsPeriods = []int{7, 30, 90}
+
+const defaultStatsPeriod = 30
+
+var questionSourceLabels = map[db.QuestionSource]string{
+	db.QuestionSourceAnonymous:  "匿名提问",
+	db.QuestionSourceRegistered: "注册用户提问",
+	db.QuestionSourcePrompt:     "话题提问",
+	db.QuestionSourceForwarded:  "转发的提问",
+	db.QuestionSourceImported:   "导入的提问",
+}
+
+// statsBar is a bar of the chart, the height is the percentage of the highest bar,
+// and the answered height is the percentage of the bar itself.
+type statsBar struct {
+	Label          string
+	Count          int64
+	Answered       int64
+	Height         int
+	AnsweredHeight int
+}
+
+type statsSource struct {
+	Label   string
+	Count   int64
+	Percent int
+}
+
+// Stats shows the statistics of the questions received in the period.
+func Stats(ctx context.Context) {
+	ctx.SetTitle("提问统计 - NekoBox")
+
+	days := defaultStatsPeriod
+	for _, period := range statsPeriods {
+		if ctx.QueryInt("days") == period {
+			days = period
+		}
+	}
+	ctx.Data["Days"] = days
+	ctx.Data["Periods"] = statsPeriods
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	since := today.AddDate(0, 0, -(days - 1))
+
+	stats, err := db.Questions.StatsByUserID(ctx.Request().Context(), ctx.User.ID, db.QuestionStatsOptions{Since: since})
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get question stats")
+		ctx.SetInternalError()
+		ctx.Success("user/stats")
+		return
+	}
+
+	ctx.Data["Stats"] = stats
+	ctx.Data["AnswerRate"] = percent(stats.Answered, stats.Total)
+	ctx.Data["CensorRejectionRate"] = percent(stats.CensorRejected, stats.CensorChecked)
+	ctx.Data["AverageResponseTime"] = formatResponseTime(stats.AverageResponseTime)
+
+	// The days without any question are filled with zero, so the chart is continuous.
+	dailyByDate := make(map[string]*db.QuestionDailyStats, len(stats.Daily))
+	for _, daily := range stats.Daily {
+		dailyByDate[daily.Date] = daily
+	}
+	dailyBars := make([]*statsBar, 0, days)
+	weeklyBars := make([]*statsBar, 0, days/7+1)
+	for day := since; !day.After(today); day = day.AddDate(0, 0, 1) {
+		bar := &statsBar{Label: day.Format("01-02")}
+		if daily, ok := dailyByDate[day.Format("2006-01-02")]; ok {
+			bar.Count = daily.Count
+			bar.Answered = daily.Answered
+		}
+		dailyBars = append(dailyBars, bar)
+
+		// The weeks start on Monday.
+		if len(weeklyBars) == 0 || day.Weekday() == time.Monday {
+			weeklyBars = append(weeklyBars, &statsBar{Label: day.Format("01-02") + " 起"})
+		}
+		week := weeklyBars[len(weeklyBars)-1]
+		week.Count += bar.Count
+		week.Answered += bar.Answered
+	}
+	ctx.Data["DailyBars"] = scaleBars(dailyBars)
+	ctx.Data["FirstDay"] = since.Format("01-02")
+	ctx.Data["LastDay"] = today.Format("01-02")
+	ctx.Data["WeeklyBars"] = scaleBars(weeklyBars)
+
+	sources := make([]*statsSource, 0, len(stats.Sources))
+	for _, source := range stats.Sources {
+		label, ok := questionSourceLabels[source.Source]
+		if !ok {
+			label = string(source.Source)
+		}
+		sources = append(sources, &statsSource{
+			Label:   label,
+			Count:   source.Count,
+			Percent: percent(source.Count, stats.Total),
+		})
+	}
+	ctx.Data["Sources"] = sources
+
+	var trackedTotal int64
+	for _, channel := range stats.Channels {
+		trackedTotal += channel.Count
+	}
+	channels := make([]*statsSource, 0, len(stats.Channels))
+	for _, channel := range stats.Channels {
+		label := channel.Channel
+		if label == "" {
+			label = "直接访问"
+		}
+		channels = append(channels, &statsSource{
+			Label:   label,
+			Count:   channel.Count,
+			Percent: percent(channel.Count, trackedTotal),
+		})
+	}
+	ctx.Data["Channels"] = channels
+
+	ctx.Success("user/stats")
+}
+
+// scaleBars sets the heights of the bars relative to the highest one.
+func scaleBars(bars []*statsBar) []*statsBar {
+	var max int64
+	for _, bar := range bars {
+		if bar.Count > max {
+			max = bar.Count
+		}
+	}
+	for _, bar := range bars {
+		bar.Height = percent(bar.Count, max)
+		bar.AnsweredHeight = percent(bar.Answered, bar.Count)
+	}
+	return bars
+}
+
+// percent returns the rounded percentage of the part in the total, it is zero if the total is zero.
+func percent(part, total int64) int {
+	if total == 0 {
+		return 0
+	}
+	return int((part*100 + total/2) / total)
+}
+
+// formatResponseTime returns the readable response time, e.g. "2 天 3 小时".
+func formatResponseTime(d time.Duration) string {
+	switch {
+	case d <= 0:
+		return "暂无数据"
+	case d < time.Hour:
+		return fmt.Sprintf("%d 分钟", int(d.Minutes())+1)
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%d 小时 %d 分钟", int(d.Hours()), int(d.Minutes())%60)
+	default:
+		return fmt.Sprintf("%d 天 %d 小时", int(d.Hours())/24, int(d.Hours())%24)
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package user
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/context"
+	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/form"
+)
+
+func Tags(ctx context.Context) {
+	tags, err := db.QuestionTags.ListByUserID(ctx.Request().Context(), ctx.User.ID, db.ListTagsOptions{})
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to list tags")
+		ctx.Redirect("/")
+		return
+	}
+	ctx.Data["Tags"] = tags
+
+	ctx.Success("user/tags")
+}
+
+func RenameTag(ctx context.Context, f form.RenameTag) {
+	if ctx.HasError() {
+		ctx.SetErrorFlash(ctx.ErrorMessage())
+		ctx.Redirect("/user/tags")
+		return
+	}
+
+	newName := strings.TrimSpace(f.NewName)
+	if tags := db.ParseTags(newName); len(tags) != 1 || tags[0] != newName {
+		ctx.SetErrorFlash("标签名不能包含逗号")
+		ctx.Redirect("/user/tags")
+		return
+	}
+
+	if err := db.QuestionTags.Rename(ctx.Request().Context(), ctx.User.ID, f.Name, newName); err != nil {
+		if errors.Is(err, db.ErrTagNotExist) || errors.Is(err, db.ErrTagNameTooLong) {
+			ctx.SetErrorFlash(ctx.TrError(err))
+		} else {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to rename tag")
+			ctx.SetInternalErrorFlash()
+		}
+		ctx.Redirect("/user/tags")
+		return
+	}
+
+	ctx.SetSuccessFlash("标签重命名成功！")
+	ctx.Redirect("/user/tags")
+}
+
+func DeleteTag(ctx context.Context, f form.DeleteTag) {
+	if ctx.HasError() {
+		ctx.SetErrorFlash(ctx.ErrorMessage())
+		ctx.Redirect("/user/tags")
+		return
+	}
+
+	if err := db.QuestionTags.Delete(ctx.Request().Context(), ctx.User.ID, f.Name); err != nil {
+		if errors.Is(err, db.ErrTagNotExist) {
+			ctx.SetErrorFlash(ctx.TrError(err))
+		} else {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to delete tag")
+			ctx.SetInternalErrorFlash()
+		}
+		ctx.Redirect("/user/tags")
+		return
+	}
+
+	ctx.SetSuccessFlash("标签删除成功！")
+	ctx.Redirect("/user/tags")
+}
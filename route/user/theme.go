@@ -0,0 +1,77 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package user
+
+import (
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/context"
+	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/form"
+	"github.com/NekoWheel/NekoBox/internal/storage"
+)
+
+func UpdateProfileTheme(ctx context.Context, f form.UpdateProfileTheme) {
+	if ctx.HasError() {
+		ctx.SetErrorFlash(ctx.ErrorMessage())
+		ctx.Redirect("/user/profile")
+		return
+	}
+
+	settings := db.ProfileSettings{
+		Theme:           f.Theme,
+		BackgroundImage: ctx.User.ProfileSettings.BackgroundImage,
+		License:         ctx.User.ProfileSettings.License,
+	}
+	if f.CustomAccentColor != "" {
+		settings.AccentColor = strings.ToLower(f.AccentColor)
+	}
+	if f.RemoveBackgroundImage != "" {
+		settings.BackgroundImage = ""
+	}
+
+	backgroundFile, backgroundFileHeader, err := ctx.Request().FormFile("background_image")
+	if err == nil {
+		if err := storage.CheckPicture(backgroundFile, backgroundFileHeader); err != nil {
+			ctx.SetErrorFlash(ctx.TrError(err))
+			ctx.Redirect("/user/profile")
+			return
+		}
+		settings.BackgroundImage, err = storage.UploadPictureToOSS(backgroundFile, backgroundFileHeader)
+		if err != nil {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to upload theme background image")
+			ctx.SetInternalErrorFlash()
+			ctx.Redirect("/user/profile")
+			return
+		}
+	}
+
+	if err := settings.Validate(); err != nil {
+		ctx.SetErrorFlash(ctx.TrError(err))
+		ctx.Redirect("/user/profile")
+		return
+	}
+
+	if err := db.Users.UpdateProfileSettings(ctx.Request().Context(), ctx.User.ID, settings); err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to update profile settings")
+		ctx.SetInternalErrorFlash()
+		ctx.Redirect("/user/profile")
+		return
+	}
+
+	// The replaced background image is no longer referenced.
+	if oldBackgroundImage := ctx.User.ProfileSettings.BackgroundImage; oldBackgroundImage != "" && oldBackgroundImage != settings.BackgroundImage {
+		go func() {
+			if err := storage.DeletePictureFromOSS(oldBackgroundImage); err != nil {
+				logrus.WithError(err).WithField("url", oldBackgroundImage).Error("Failed to delete replaced theme background image")
+			}
+		}()
+	}
+
+	ctx.SetSuccessFlash("更新主题成功")
+	ctx.Redirect("/user/profile")
+}
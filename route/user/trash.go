@@ -0,0 +1,59 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package user
+
+import (
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/context"
+	"github.com/NekoWheel/NekoBox/internal/db"
+)
+
+func Trash(ctx context.Context) {
+	questions, err := db.Questions.GetTrashedByUserID(ctx.Request().Context(), ctx.User.ID)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get trashed questions by user ID")
+		ctx.Redirect("/")
+		return
+	}
+	ctx.Data["Questions"] = questions
+	ctx.Data["TrashRetentionDays"] = int(db.QuestionTrashRetention.Hours() / 24)
+
+	ctx.Success("user/trash")
+}
+
+func RestoreQuestion(ctx context.Context) {
+	questionID := uint(ctx.ParamInt("questionID"))
+	question, err := db.Questions.GetTrashedByID(ctx.Request().Context(), questionID)
+	if err != nil {
+		if !errors.Is(err, db.ErrQuestionNotExist) {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get trashed question by ID")
+		}
+		ctx.SetErrorFlash("提问不存在")
+		ctx.Redirect("/user/trash")
+		return
+	}
+
+	if question.UserID != ctx.User.ID {
+		ctx.SetErrorFlash("提问不存在")
+		ctx.Redirect("/user/trash")
+		return
+	}
+
+	if err := db.Questions.Restore(ctx.Request().Context(), question.ID); err != nil {
+		if errors.Is(err, db.ErrQuestionNotExist) {
+			ctx.SetErrorFlash("提问不存在")
+		} else {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to restore question")
+			ctx.SetInternalErrorFlash()
+		}
+		ctx.Redirect("/user/trash")
+		return
+	}
+
+	ctx.SetSuccessFlash("恢复提问成功！")
+	ctx.Redirect("/user/trash")
+}
@@ -0,0 +1,188 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package user
+
+import (
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/context"
+	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/form"
+	"github.com/NekoWheel/NekoBox/internal/security/twofactor"
+)
+
+// twoFactorSetupSessionKey keeps the TOTP key URL until the user confirms it with a passcode.
+const twoFactorSetupSessionKey = "twoFactorSetupURL"
+
+func TwoFactor(ctx context.Context) {
+	enabled, err := db.TwoFactors.IsEnabled(ctx.Request().Context(), ctx.User.ID)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to check two-factor authentication")
+		ctx.SetInternalErrorFlash()
+		ctx.Redirect("/user/profile")
+		return
+	}
+	ctx.Data["TwoFactorEnabled"] = enabled
+
+	if enabled {
+		count, err := db.TwoFactors.CountUnusedRecoveryCodes(ctx.Request().Context(), ctx.User.ID)
+		if err != nil {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to count unused recovery codes")
+		}
+		ctx.Data["UnusedRecoveryCodesCount"] = count
+	}
+
+	ctx.Success("user/two-factor")
+}
+
+func EnableTwoFactor(ctx context.Context) {
+	enabled, err := db.TwoFactors.IsEnabled(ctx.Request().Context(), ctx.User.ID)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to check two-factor authentication")
+		ctx.SetInternalErrorFlash()
+		ctx.Redirect("/user/two-factor")
+		return
+	}
+	if enabled {
+		ctx.SetErrorFlash(ctx.TrError(db.ErrTwoFactorExists))
+		ctx.Redirect("/user/two-factor")
+		return
+	}
+
+	key, err := twofactor.GenerateKey(ctx.User.Email)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to generate two-factor key")
+		ctx.SetInternalErrorFlash()
+		ctx.Redirect("/user/two-factor")
+		return
+	}
+
+	qrCode, err := twofactor.QRCode(key)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to generate two-factor QR code")
+		ctx.SetInternalErrorFlash()
+		ctx.Redirect("/user/two-factor")
+		return
+	}
+
+	ctx.Session.Set(twoFactorSetupSessionKey, key.String())
+	ctx.Data["QRCode"] = qrCode
+	ctx.Data["Secret"] = key.Secret()
+	ctx.Success("user/two-factor-enable")
+}
+
+func EnableTwoFactorAction(ctx context.Context, f form.TwoFactor) {
+	keyURL, ok := ctx.Session.Get(twoFactorSetupSessionKey).(string)
+	if !ok {
+		ctx.SetErrorFlash("设置已过期，请重新扫描二维码")
+		ctx.Redirect("/user/two-factor/enable")
+		return
+	}
+
+	key, err := twofactor.ParseKey(keyURL)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to parse two-factor key")
+		ctx.SetInternalErrorFlash()
+		ctx.Redirect("/user/two-factor/enable")
+		return
+	}
+
+	if ctx.HasError() {
+		ctx.SetErrorFlash(ctx.ErrorMessage())
+		ctx.Redirect("/user/two-factor/enable")
+		return
+	}
+
+	if !twofactor.Validate(f.Passcode, key.Secret()) {
+		ctx.SetErrorFlash("两步验证码错误，请重新扫描二维码后再试")
+		ctx.Redirect("/user/two-factor/enable")
+		return
+	}
+
+	recoveryCodes, err := db.TwoFactors.Create(ctx.Request().Context(), ctx.User.ID, key.Secret())
+	if err != nil {
+		if errors.Is(err, db.ErrTwoFactorExists) {
+			ctx.SetErrorFlash(ctx.TrError(err))
+		} else {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to create two factor")
+			ctx.SetInternalErrorFlash()
+		}
+		ctx.Redirect("/user/two-factor")
+		return
+	}
+	ctx.Session.Delete(twoFactorSetupSessionKey)
+
+	ctx.Data["RecoveryCodes"] = recoveryCodes
+	ctx.Data["Success"] = "两步验证已开启！"
+	ctx.Success("user/two-factor-recovery-codes")
+}
+
+func DisableTwoFactor(ctx context.Context, f form.DisableTwoFactor) {
+	if ctx.HasError() {
+		ctx.SetErrorFlash(ctx.ErrorMessage())
+		ctx.Redirect("/user/two-factor")
+		return
+	}
+
+	if !ctx.User.Authenticate(f.Password) {
+		ctx.SetErrorFlash("密码错误")
+		ctx.Redirect("/user/two-factor")
+		return
+	}
+
+	if err := db.TwoFactors.DeleteByUserID(ctx.Request().Context(), ctx.User.ID); err != nil {
+		if errors.Is(err, db.ErrTwoFactorNotExist) {
+			ctx.SetErrorFlash(ctx.TrError(err))
+		} else {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to delete two factor")
+			ctx.SetInternalErrorFlash()
+		}
+		ctx.Redirect("/user/two-factor")
+		return
+	}
+
+	ctx.SetSuccessFlash("两步验证已关闭")
+	ctx.Redirect("/user/two-factor")
+}
+
+func RegenerateRecoveryCodes(ctx context.Context, f form.RegenerateRecoveryCodes) {
+	if ctx.HasError() {
+		ctx.SetErrorFlash(ctx.ErrorMessage())
+		ctx.Redirect("/user/two-factor")
+		return
+	}
+
+	if !ctx.User.Authenticate(f.Password) {
+		ctx.SetErrorFlash("密码错误")
+		ctx.Redirect("/user/two-factor")
+		return
+	}
+
+	enabled, err := db.TwoFactors.IsEnabled(ctx.Request().Context(), ctx.User.ID)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to check two-factor authentication")
+		ctx.SetInternalErrorFlash()
+		ctx.Redirect("/user/two-factor")
+		return
+	}
+	if !enabled {
+		ctx.SetErrorFlash(ctx.TrError(db.ErrTwoFactorNotExist))
+		ctx.Redirect("/user/two-factor")
+		return
+	}
+
+	recoveryCodes, err := db.TwoFactors.RegenerateRecoveryCodes(ctx.Request().Context(), ctx.User.ID)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to regenerate recovery codes")
+		ctx.SetInternalErrorFlash()
+		ctx.Redirect("/user/two-factor")
+		return
+	}
+
+	ctx.Data["RecoveryCodes"] = recoveryCodes
+	ctx.Data["Success"] = "恢复码已重新生成，旧的恢复码已失效。"
+	ctx.Success("user/two-factor-recovery-codes")
+}
@@ -0,0 +1,80 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package user
+
+import (
+	"net/url"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/context"
+	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/form"
+	"github.com/NekoWheel/NekoBox/internal/webhook"
+)
+
+func Webhooks(ctx context.Context) {
+	webhooks, err := db.Webhooks.GetByUserID(ctx.Request().Context(), ctx.User.ID)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get webhooks by user ID")
+		ctx.Redirect("/")
+		return
+	}
+	ctx.Data["Webhooks"] = webhooks
+	ctx.Data["SignatureHeader"] = webhook.SignatureHeader
+	ctx.Data["Events"] = []webhook.Event{webhook.EventQuestionCreated, webhook.EventQuestionAnswered, webhook.EventQuestionDeleted}
+
+	ctx.Success("user/webhooks")
+}
+
+func NewWebhook(ctx context.Context, f form.NewWebhook) {
+	if ctx.HasError() {
+		Webhooks(ctx)
+		return
+	}
+
+	webhookURL, err := url.Parse(f.URL)
+	if err != nil || (webhookURL.Scheme != "http" && webhookURL.Scheme != "https") || webhookURL.Host == "" {
+		ctx.SetErrorFlash("Webhook 地址不是合法的 HTTP(S) 地址")
+		ctx.Redirect("/user/webhooks")
+		return
+	}
+
+	if _, err := db.Webhooks.Create(ctx.Request().Context(), db.CreateWebhookOptions{
+		UserID: ctx.User.ID,
+		URL:    webhookURL.String(),
+		Secret: f.Secret,
+	}); err != nil {
+		if errors.Is(err, db.ErrTooManyWebhooks) || errors.Is(err, db.ErrWebhookAlreadyExist) {
+			ctx.SetErrorFlash(ctx.TrError(err))
+		} else {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to create webhook")
+			ctx.SetInternalErrorFlash()
+		}
+		ctx.Redirect("/user/webhooks")
+		return
+	}
+
+	ctx.SetSuccessFlash("添加 Webhook 成功！")
+	ctx.Redirect("/user/webhooks")
+}
+
+func DeleteWebhook(ctx context.Context) {
+	webhookID := uint(ctx.ParamInt("webhookID"))
+	if err := db.Webhooks.DeleteByID(ctx.Request().Context(), ctx.User.ID, webhookID); err != nil {
+		if errors.Is(err, db.ErrWebhookNotExist) {
+			ctx.SetErrorFlash(ctx.TrError(err))
+		} else {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to delete webhook")
+			ctx.SetInternalErrorFlash()
+		}
+		ctx.Redirect("/user/webhooks")
+		return
+	}
+
+	ctx.SetSuccessFlash("删除 Webhook 成功！")
+	ctx.Redirect("/user/webhooks")
+}
@@ -0,0 +1,77 @@
+// Copyright 2022 E99p1ant. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package user
+
+import (
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/NekoWheel/NekoBox/internal/context"
+	"github.com/NekoWheel/NekoBox/internal/db"
+	"github.com/NekoWheel/NekoBox/internal/form"
+	"github.com/NekoWheel/NekoBox/internal/security/wordfilter"
+)
+
+func WordFilters(ctx context.Context) {
+	wordFilters, err := db.WordFilters.GetByUserID(ctx.Request().Context(), ctx.User.ID)
+	if err != nil {
+		logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to get word filters by user ID")
+		ctx.Redirect("/")
+		return
+	}
+	ctx.Data["WordFilters"] = wordFilters
+	ctx.Data["MaxWordFilters"] = db.MaxWordFiltersPerUser
+
+	ctx.Success("user/word-filters")
+}
+
+func NewWordFilter(ctx context.Context, f form.NewWordFilter) {
+	if ctx.HasError() {
+		WordFilters(ctx)
+		return
+	}
+
+	if _, err := db.WordFilters.Create(ctx.Request().Context(), db.CreateWordFilterOptions{
+		UserID:  ctx.User.ID,
+		Pattern: f.Pattern,
+		IsRegex: f.IsRegex != "",
+		Action:  db.WordFilterAction(f.Action),
+	}); err != nil {
+		switch {
+		case errors.Is(err, db.ErrTooManyWordFilters),
+			errors.Is(err, db.ErrInvalidWordFilter),
+			errors.Is(err, db.ErrInvalidWordFilterRegex),
+			errors.Is(err, db.ErrInvalidWordFilterAction):
+			ctx.SetErrorFlash(ctx.TrError(err))
+		default:
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to create word filter")
+			ctx.SetInternalErrorFlash()
+		}
+		ctx.Redirect("/user/word-filters")
+		return
+	}
+	wordfilter.Invalidate(ctx.User.ID)
+
+	ctx.SetSuccessFlash("添加屏蔽词成功！")
+	ctx.Redirect("/user/word-filters")
+}
+
+func DeleteWordFilter(ctx context.Context) {
+	wordFilterID := uint(ctx.ParamInt("wordFilterID"))
+	if err := db.WordFilters.DeleteByID(ctx.Request().Context(), ctx.User.ID, wordFilterID); err != nil {
+		if errors.Is(err, db.ErrWordFilterNotExist) {
+			ctx.SetErrorFlash(ctx.TrError(err))
+		} else {
+			logrus.WithContext(ctx.Request().Context()).WithError(err).Error("Failed to delete word filter")
+			ctx.SetInternalErrorFlash()
+		}
+		ctx.Redirect("/user/word-filters")
+		return
+	}
+	wordfilter.Invalidate(ctx.User.ID)
+
+	ctx.SetSuccessFlash("删除屏蔽词成功！")
+	ctx.Redirect("/user/word-filters")
+}
@@ -0,0 +1,17 @@
+// The loader of the NekoBox ask widget, which replaces the script tag with the iframe of the widget:
+// <script src="https://box.n3ko.co/embed.js" data-domain="<domain>" async></script>
+(function () {
+    var script = document.currentScript;
+    if (!script || !script.dataset.domain) {
+        return;
+    }
+
+    var iframe = document.createElement('iframe');
+    iframe.src = new URL(script.src).origin + '/embed/' + encodeURIComponent(script.dataset.domain);
+    iframe.title = 'NekoBox';
+    iframe.loading = 'lazy';
+    iframe.style.border = '0';
+    iframe.style.width = script.dataset.width || '100%';
+    iframe.style.height = script.dataset.height || '320px';
+    script.parentNode.insertBefore(iframe, script);
+})();
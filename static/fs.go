@@ -8,5 +8,5 @@ import (
 	"embed"
 )
 
-//go:embed favicon.ico
+//go:embed favicon.ico sw.js embed.js
 var FS embed.FS
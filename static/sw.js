@@ -0,0 +1,19 @@
+// The service worker of the Web Push notifications.
+self.addEventListener('push', (event) => {
+  let notification = {title: 'NekoBox', body: '', url: '/'};
+  try {
+    notification = Object.assign(notification, event.data.json());
+  } catch (e) {
+  }
+
+  event.waitUntil(self.registration.showNotification(notification.title, {
+    body: notification.body,
+    icon: 'https://nekobox-public.oss-cn-hangzhou.aliyuncs.com/images/Neko.png',
+    data: {url: notification.url},
+  }));
+});
+
+self.addEventListener('notificationclick', (event) => {
+  event.notification.close();
+  event.waitUntil(clients.openWindow(event.notification.data.url));
+});